@@ -0,0 +1,410 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// SessionAuditEntry corresponds to a single command that was audited by an audit session.
+type SessionAuditEntry struct {
+	CommandCode CommandCode // The command code of the audited command
+	CpHash      Digest      // The command parameter digest
+	RpHash      Digest      // The response parameter digest
+}
+
+// SessionAuditLog is a record of the commands executed by a TPMContext with an audit session, created by
+// TPMContext.StartSessionAuditLog. It can be used to compute the expected audit digest of the session in software, in order
+// to verify that the digest returned from TPMContext.GetSessionAuditDigest corresponds to the exact sequence of commands that
+// were executed.
+type SessionAuditLog struct {
+	session *handleContext
+
+	HashAlg HashAlgorithmId     // The digest algorithm of the session
+	Entries []SessionAuditEntry // The audited commands, since the session was started or since the digest was last reset
+}
+
+// Digest computes the expected audit digest of the session from the recorded commands.
+func (l *SessionAuditLog) Digest() Digest {
+	digest := make(Digest, l.HashAlg.Size())
+	for _, entry := range l.Entries {
+		h := l.HashAlg.NewHash()
+		h.Write(digest)
+		h.Write(entry.CpHash)
+		h.Write(entry.RpHash)
+		digest = h.Sum(nil)
+	}
+	return digest
+}
+
+// Verify checks that the supplied attestation structure, returned from TPMContext.GetSessionAuditDigest, contains an audit
+// digest that matches the one computed from the recorded commands. Note that this doesn't verify the signature of the
+// attestation structure, which the caller must do separately.
+func (l *SessionAuditLog) Verify(attest *Attest) error {
+	if attest == nil || attest.Type != TagAttestSessionAudit || attest.Attested == nil || attest.Attested.SessionAudit == nil {
+		return errors.New("attestation structure does not contain a session audit digest")
+	}
+	if !bytes.Equal(attest.Attested.SessionAudit.SessionDigest, l.Digest()) {
+		return fmt.Errorf("session audit digest %x does not match the recorded commands (expected %x)", attest.Attested.SessionAudit.SessionDigest, l.Digest())
+	}
+	return nil
+}
+
+// StartSessionAuditLog begins recording the command and response parameter digests of each command that is subsequently
+// executed by this TPMContext where the supplied session is used with the AttrAudit attribute set. If the session is used with
+// the AttrAuditReset attribute set, previously recorded commands are discarded, as the TPM resets the audit digest.
+//
+// Recording must begin before the session is first used for auditing, else the computed digest won't match the one maintained
+// by the TPM. Only commands that are executed by this TPMContext with a SessionContext that corresponds to the same session are
+// recorded. Commands that fail or where the response auth area cannot be verified are not recorded.
+//
+// Recording continues until TPMContext.StopSessionAuditLog is called.
+func (t *TPMContext) StartSessionAuditLog(session SessionContext) (*SessionAuditLog, error) {
+	if session == nil {
+		return nil, makeInvalidArgError("session", "nil value")
+	}
+	s, ok := session.(*sessionContext)
+	if !ok || s.Data() == nil {
+		return nil, makeInvalidArgError("session", "incomplete session")
+	}
+
+	log := &SessionAuditLog{session: s.handleContext, HashAlg: s.Data().HashAlg}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessionAuditLogs = append(t.sessionAuditLogs, log)
+	return log, nil
+}
+
+// StopSessionAuditLog stops recording commands to the supplied log, which remains usable for verification.
+func (t *TPMContext) StopSessionAuditLog(log *SessionAuditLog) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, l := range t.sessionAuditLogs {
+		if l == log {
+			// Copy the slice, as commands may be iterating over the current one.
+			t.sessionAuditLogs = append(t.sessionAuditLogs[:i:i], t.sessionAuditLogs[i+1:]...)
+			return
+		}
+	}
+}
+
+// CommandAuditLogRecordType indicates the type of a CommandAuditLogRecord.
+type CommandAuditLogRecordType uint8
+
+const (
+	// CommandAuditLogRecordCheckpoint indicates that a record contains the audit counter, digest algorithm and audit digest
+	// obtained from the TPM when a CommandAuditRecorder was started.
+	CommandAuditLogRecordCheckpoint CommandAuditLogRecordType = iota + 1
+
+	// CommandAuditLogRecordCommand indicates that a record contains the command and response parameter digests of an
+	// audited command.
+	CommandAuditLogRecordCommand
+
+	// CommandAuditLogRecordReset indicates that the audit digest was cleared by a call to TPMContext.GetCommandAuditDigest
+	// with a signing key.
+	CommandAuditLogRecordReset
+)
+
+// CommandAuditLogRecord is a single record in a command audit log written by CommandAuditRecorder.
+type CommandAuditLogRecord struct {
+	Type CommandAuditLogRecordType
+
+	// Set for CommandAuditLogRecordCheckpoint
+	AuditCounter uint64
+	DigestAlg    HashAlgorithmId
+	AuditDigest  Digest
+
+	// Set for CommandAuditLogRecordCommand
+	CommandCode CommandCode
+	CpHash      Digest
+	RpHash      Digest
+}
+
+// CommandAuditRecorder writes a record of each audited command executed by a TPMContext to a log, so that the log can be
+// verified later on against a signed audit digest with VerifyCommandAuditLog. It is created with
+// TPMContext.StartCommandAuditRecorder.
+type CommandAuditRecorder struct {
+	w        io.Writer
+	alg      HashAlgorithmId
+	commands map[CommandCode]bool
+	err      error
+}
+
+// Err returns the first error that occurred when writing to the log. Once an error occurs, no more records are written.
+func (r *CommandAuditRecorder) Err() error {
+	return r.err
+}
+
+func (r *CommandAuditRecorder) write(record *CommandAuditLogRecord) {
+	if r.err != nil {
+		return
+	}
+	if _, err := mu.MarshalToWriter(r.w, record); err != nil {
+		r.err = err
+	}
+}
+
+// OpenCommandAuditLogFile opens the file at the specified path for appending records with a CommandAuditRecorder, creating
+// it if it doesn't exist.
+func OpenCommandAuditLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+}
+
+// StartCommandAuditRecorder begins recording the command and response parameter digests of each command that is subsequently
+// executed successfully by this TPMContext where the command code is in the TPM's list of audited commands. Records are
+// appended to w, which will normally be a file opened with OpenCommandAuditLogFile.
+//
+// When it starts, the recorder obtains the list of audited commands with TPMContext.GetCapabilityAuditCommands, and the current
+// audit counter, digest algorithm and audit digest with TPMContext.GetCommandAuditDigest (without a signing key, so the digest
+// is not cleared), which are written as a checkpoint record. This requires authorization of privacyContext, which must be a
+// ResourceContext corresponding to HandleEndorsement, with session based authorization provided via
+// privacyContextAuthSession. The checkpoint allows VerifyCommandAuditLog to detect commands that were audited by the TPM but
+// not recorded, such as commands executed by other processes, between successive recorders that append to the same log.
+//
+// If the audit status is changed with TPMContext.SetCommandCodeAuditStatus, the recorder must be stopped and a new one started.
+//
+// Recording continues until TPMContext.StopCommandAuditRecorder is called.
+func (t *TPMContext) StartCommandAuditRecorder(w io.Writer, privacyContext ResourceContext, privacyContextAuthSession SessionContext) (r *CommandAuditRecorder, err error) {
+	// Execute this as a transaction so that commands from other goroutines can't be executed between obtaining the current
+	// audit digest and beginning recording.
+	err = t.Transaction(func(t *TPMContext) error {
+		r, err = t.startCommandAuditRecorder(w, privacyContext, privacyContextAuthSession)
+		return err
+	})
+	return r, err
+}
+
+func (t *TPMContext) startCommandAuditRecorder(w io.Writer, privacyContext ResourceContext, privacyContextAuthSession SessionContext) (*CommandAuditRecorder, error) {
+	commands, err := t.GetCapabilityAuditCommands(CommandFirst, CapabilityMaxProperties)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot obtain audited commands: %w", err)
+	}
+
+	attest, _, err := t.GetCommandAuditDigest(privacyContext, nil, nil, nil, privacyContextAuthSession, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot obtain current audit digest: %w", err)
+	}
+	if attest.Type != TagAttestCommandAudit || attest.Attested.CommandAudit == nil {
+		return nil, &InvalidResponseError{CommandGetCommandAuditDigest, "attestation structure does not contain a command audit digest"}
+	}
+	info := attest.Attested.CommandAudit
+
+	r := &CommandAuditRecorder{
+		w:        w,
+		alg:      HashAlgorithmId(info.DigestAlg),
+		commands: make(map[CommandCode]bool)}
+	for _, code := range commands {
+		r.commands[code] = true
+	}
+	if !r.alg.Available() {
+		return nil, fmt.Errorf("audit digest algorithm %v is not available", r.alg)
+	}
+
+	r.write(&CommandAuditLogRecord{
+		Type:         CommandAuditLogRecordCheckpoint,
+		AuditCounter: info.AuditCounter,
+		DigestAlg:    r.alg,
+		AuditDigest:  info.AuditDigest})
+	if r.err != nil {
+		return nil, xerrors.Errorf("cannot write checkpoint: %w", r.err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.commandAuditLogs = append(t.commandAuditLogs, r)
+	return r, nil
+}
+
+// StopCommandAuditRecorder stops recording commands with the supplied recorder.
+func (t *TPMContext) StopCommandAuditRecorder(r *CommandAuditRecorder) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, l := range t.commandAuditLogs {
+		if l == r {
+			// Copy the slice, as commands may be iterating over the current one.
+			t.commandAuditLogs = append(t.commandAuditLogs[:i:i], t.commandAuditLogs[i+1:]...)
+			return
+		}
+	}
+}
+
+// ReadCommandAuditLog reads all of the records from a command audit log written by CommandAuditRecorder.
+func ReadCommandAuditLog(r io.Reader) ([]*CommandAuditLogRecord, error) {
+	buf := bufio.NewReader(r)
+
+	var records []*CommandAuditLogRecord
+	for {
+		if _, err := buf.Peek(1); err == io.EOF {
+			return records, nil
+		}
+
+		var record *CommandAuditLogRecord
+		if _, err := mu.UnmarshalFromReader(buf, &record); err != nil {
+			return nil, xerrors.Errorf("cannot read record %d: %w", len(records), err)
+		}
+		records = append(records, record)
+	}
+}
+
+type commandAuditState struct {
+	counter uint64
+	alg     HashAlgorithmId
+	digest  Digest
+}
+
+func (s *commandAuditState) matches(info *CommandAuditInfo) bool {
+	return s.counter == info.AuditCounter && HashAlgorithmId(info.DigestAlg) == s.alg && bytes.Equal(s.digest, info.AuditDigest)
+}
+
+// VerifyCommandAuditLog replays the records of a command audit log and verifies that they account for the audit counter and
+// audit digest in the supplied CommandAuditInfo, which should be obtained from an attestation structure returned from
+// TPMContext.GetCommandAuditDigest after its signature has been verified. The supplied info is compared against the state
+// computed at the end of the log and at each point where the audit digest was cleared, so that it may correspond to any
+// attestation obtained by the recorder.
+//
+// An error is returned if the log contains a checkpoint that doesn't match the state computed from the preceding records,
+// which indicates that audited commands were executed outside of a recorder, or if no point in the log matches the supplied
+// info.
+func VerifyCommandAuditLog(records []*CommandAuditLogRecord, info *CommandAuditInfo) error {
+	if len(records) == 0 || records[0].Type != CommandAuditLogRecordCheckpoint {
+		return errors.New("log does not begin with a checkpoint")
+	}
+
+	var state *commandAuditState
+	var mismatch error
+
+	check := func() bool {
+		if state.matches(info) {
+			return true
+		}
+		mismatch = fmt.Errorf("audit counter %d and digest %x do not match the log (expected counter %d and digest %x)", info.AuditCounter, info.AuditDigest, state.counter, state.digest)
+		return false
+	}
+
+	for i, record := range records {
+		switch record.Type {
+		case CommandAuditLogRecordCheckpoint:
+			if !record.DigestAlg.Available() {
+				return fmt.Errorf("invalid checkpoint at record %d: digest algorithm %v is not available", i, record.DigestAlg)
+			}
+			checkpoint := &commandAuditState{counter: record.AuditCounter, alg: record.DigestAlg, digest: record.AuditDigest}
+			if state != nil && !(state.counter == checkpoint.counter && state.alg == checkpoint.alg && bytes.Equal(state.digest, checkpoint.digest)) {
+				return fmt.Errorf("gap detected before record %d: audited commands were executed outside of the recorder", i)
+			}
+			state = checkpoint
+		case CommandAuditLogRecordCommand:
+			if len(state.digest) == 0 {
+				// The first audited command after the digest is cleared increments the audit counter.
+				state.counter++
+				state.digest = make(Digest, state.alg.Size())
+			}
+			h := state.alg.NewHash()
+			h.Write(state.digest)
+			h.Write(record.CpHash)
+			h.Write(record.RpHash)
+			state.digest = h.Sum(nil)
+		case CommandAuditLogRecordReset:
+			if check() {
+				return nil
+			}
+			state.digest = nil
+		default:
+			return fmt.Errorf("invalid record type %d at record %d", record.Type, i)
+		}
+	}
+
+	if check() {
+		return nil
+	}
+	return mismatch
+}
+
+// computeAuditEntries computes the session and command audit entries for the supplied command, and returns a function that
+// records them to the corresponding logs once the response has been verified.
+func (t *TPMContext) computeAuditEntries(cmd *cmdContext) func() {
+	type pendingSessionEntry struct {
+		log   *SessionAuditLog
+		reset bool
+		entry SessionAuditEntry
+	}
+	var pendingSession []pendingSessionEntry
+
+	t.mu.Lock()
+	sessionAuditLogs := t.sessionAuditLogs
+	commandAuditLogs := t.commandAuditLogs
+	t.mu.Unlock()
+
+	for _, log := range sessionAuditLogs {
+		for _, s := range cmd.sessionParams.sessions {
+			if s.session == nil || s.session.handleContext != log.session {
+				continue
+			}
+			attrs := s.session.attrs.canonicalize()
+			if attrs&AttrAudit == 0 {
+				continue
+			}
+			pendingSession = append(pendingSession, pendingSessionEntry{
+				log:   log,
+				reset: attrs&AttrAuditReset != 0,
+				entry: SessionAuditEntry{
+					CommandCode: cmd.commandCode,
+					CpHash:      ComputeCpHash(log.HashAlg, cmd.commandCode, cmd.handleNames, cmd.cpBytes),
+					RpHash:      cryptComputeRpHash(log.HashAlg, cmd.responseCode, cmd.commandCode, cmd.rpBytes)}})
+		}
+	}
+
+	type pendingCommandRecords struct {
+		recorder *CommandAuditRecorder
+		records  []*CommandAuditLogRecord
+	}
+	var pendingCommand []pendingCommandRecords
+
+	// Obtaining a signed audit digest clears it.
+	reset := cmd.commandCode == CommandGetCommandAuditDigest && len(cmd.handleNames) == 2 &&
+		!(cmd.handleNames[1].Type() == NameTypeHandle && cmd.handleNames[1].Handle() == HandleNull)
+
+	for _, r := range commandAuditLogs {
+		var records []*CommandAuditLogRecord
+		if reset {
+			records = append(records, &CommandAuditLogRecord{Type: CommandAuditLogRecordReset})
+		}
+		if r.commands[cmd.commandCode] {
+			records = append(records, &CommandAuditLogRecord{
+				Type:        CommandAuditLogRecordCommand,
+				CommandCode: cmd.commandCode,
+				CpHash:      ComputeCpHash(r.alg, cmd.commandCode, cmd.handleNames, cmd.cpBytes),
+				RpHash:      cryptComputeRpHash(r.alg, cmd.responseCode, cmd.commandCode, cmd.rpBytes)})
+		}
+		if len(records) > 0 {
+			pendingCommand = append(pendingCommand, pendingCommandRecords{recorder: r, records: records})
+		}
+	}
+
+	return func() {
+		for _, p := range pendingSession {
+			if p.reset {
+				p.log.Entries = nil
+			}
+			p.log.Entries = append(p.log.Entries, p.entry)
+		}
+		for _, p := range pendingCommand {
+			for _, record := range p.records {
+				p.recorder.write(record)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+type sessionAuditSuite struct {
+	testutil.TPMTest
+}
+
+func (s *sessionAuditSuite) SetUpSuite(c *C) {
+	s.TPMFeatures = testutil.TPMFeatureEndorsementHierarchy
+}
+
+var _ = Suite(&sessionAuditSuite{})
+
+func (s *sessionAuditSuite) getSessionAuditDigest(c *C, session SessionContext) *Attest {
+	attest, _, err := s.TPM.GetSessionAuditDigest(s.TPM.EndorsementHandleContext(), nil, session, nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+	return attest
+}
+
+func (s *sessionAuditSuite) TestVerify(c *C) {
+	session := s.StartAuthSession(c, nil, nil, SessionTypeHMAC, nil, HashAlgorithmSHA256)
+
+	log, err := s.TPM.StartSessionAuditLog(session)
+	c.Assert(err, IsNil)
+
+	_, err = s.TPM.GetRandom(16, session.WithAttrs(AttrContinueSession|AttrAudit))
+	c.Check(err, IsNil)
+	_, err = s.TPM.ReadClock(session.WithAttrs(AttrContinueSession | AttrAudit))
+	c.Check(err, IsNil)
+	_, err = s.TPM.ReadClock()
+	c.Check(err, IsNil)
+
+	c.Check(log.Entries, HasLen, 2)
+	c.Check(log.Entries[0].CommandCode, Equals, CommandGetRandom)
+	c.Check(log.Entries[1].CommandCode, Equals, CommandReadClock)
+
+	c.Check(log.Verify(s.getSessionAuditDigest(c, session)), IsNil)
+}
+
+func (s *sessionAuditSuite) TestVerifyReset(c *C) {
+	session := s.StartAuthSession(c, nil, nil, SessionTypeHMAC, nil, HashAlgorithmSHA256)
+
+	log, err := s.TPM.StartSessionAuditLog(session)
+	c.Assert(err, IsNil)
+
+	_, err = s.TPM.ReadClock(session.WithAttrs(AttrContinueSession | AttrAudit))
+	c.Check(err, IsNil)
+	_, err = s.TPM.GetRandom(16, session.WithAttrs(AttrContinueSession|AttrAuditReset))
+	c.Check(err, IsNil)
+
+	c.Check(log.Entries, HasLen, 1)
+	c.Check(log.Verify(s.getSessionAuditDigest(c, session)), IsNil)
+}
+
+func (s *sessionAuditSuite) TestVerifyUnrecordedCommand(c *C) {
+	session := s.StartAuthSession(c, nil, nil, SessionTypeHMAC, nil, HashAlgorithmSHA256)
+
+	log, err := s.TPM.StartSessionAuditLog(session)
+	c.Assert(err, IsNil)
+
+	_, err = s.TPM.ReadClock(session.WithAttrs(AttrContinueSession | AttrAudit))
+	c.Check(err, IsNil)
+
+	s.TPM.StopSessionAuditLog(log)
+
+	_, err = s.TPM.ReadClock(session.WithAttrs(AttrContinueSession | AttrAudit))
+	c.Check(err, IsNil)
+
+	c.Check(log.Entries, HasLen, 1)
+	c.Check(log.Verify(s.getSessionAuditDigest(c, session)), ErrorMatches, "session audit digest [[:xdigit:]]+ does not match the recorded commands \\(expected [[:xdigit:]]+\\)")
+}
+
+type sessionAuditLogSuite struct{}
+
+var _ = Suite(&sessionAuditLogSuite{})
+
+func (s *sessionAuditLogSuite) TestDigestEmpty(c *C) {
+	log := &SessionAuditLog{HashAlg: HashAlgorithmSHA256}
+	c.Check(log.Digest(), DeepEquals, make(Digest, 32))
+}
+
+func (s *sessionAuditLogSuite) TestDigest(c *C) {
+	log := &SessionAuditLog{
+		HashAlg: HashAlgorithmSHA256,
+		Entries: []SessionAuditEntry{
+			{CommandCode: CommandReadClock, CpHash: make(Digest, 32), RpHash: make(Digest, 32)}}}
+
+	h := HashAlgorithmSHA256.NewHash()
+	h.Write(make([]byte, 96))
+	c.Check(log.Digest(), DeepEquals, Digest(h.Sum(nil)))
+}
+
+func (s *sessionAuditLogSuite) TestVerifyWrongType(c *C) {
+	log := &SessionAuditLog{HashAlg: HashAlgorithmSHA256}
+	c.Check(log.Verify(&Attest{Type: TagAttestCommandAudit, Attested: &AttestU{CommandAudit: new(CommandAuditInfo)}}), ErrorMatches,
+		"attestation structure does not contain a session audit digest")
+}
+
+func (s *sessionAuditLogSuite) TestVerifyOK(c *C) {
+	log := &SessionAuditLog{HashAlg: HashAlgorithmSHA256}
+	c.Check(log.Verify(&Attest{Type: TagAttestSessionAudit, Attested: &AttestU{SessionAudit: &SessionAuditInfo{SessionDigest: make(Digest, 32)}}}), IsNil)
+}
+
+type commandAuditRecorderSuite struct {
+	testutil.TPMTest
+}
+
+func (s *commandAuditRecorderSuite) SetUpSuite(c *C) {
+	s.TPMFeatures = testutil.TPMFeatureOwnerHierarchy | testutil.TPMFeatureEndorsementHierarchy | testutil.TPMFeatureNV
+}
+
+var _ = Suite(&commandAuditRecorderSuite{})
+
+func (s *commandAuditRecorderSuite) SetUpTest(c *C) {
+	s.TPMTest.SetUpTest(c)
+	c.Assert(s.TPM.SetCommandCodeAuditStatus(s.TPM.OwnerHandleContext(), HashAlgorithmNull, CommandCodeList{CommandGetRandom}, nil, nil), IsNil)
+}
+
+func (s *commandAuditRecorderSuite) getSignedAuditInfo(c *C) *CommandAuditInfo {
+	sign := s.CreatePrimary(c, HandleEndorsement, testutil.NewRestrictedRSASigningKeyTemplate(nil))
+	attest, _, err := s.TPM.GetCommandAuditDigest(s.TPM.EndorsementHandleContext(), sign, nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+	return attest.Attested.CommandAudit
+}
+
+func (s *commandAuditRecorderSuite) TestRecordAndVerify(c *C) {
+	buf := new(bytes.Buffer)
+	recorder, err := s.TPM.StartCommandAuditRecorder(buf, s.TPM.EndorsementHandleContext(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+	_, err = s.TPM.ReadClock()
+	c.Check(err, IsNil)
+	_, err = s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+
+	info := s.getSignedAuditInfo(c)
+	c.Check(recorder.Err(), IsNil)
+
+	records, err := ReadCommandAuditLog(buf)
+	c.Assert(err, IsNil)
+	c.Check(records, HasLen, 4)
+	c.Check(VerifyCommandAuditLog(records, info), IsNil)
+}
+
+func (s *commandAuditRecorderSuite) TestDetectGap(c *C) {
+	buf := new(bytes.Buffer)
+	recorder, err := s.TPM.StartCommandAuditRecorder(buf, s.TPM.EndorsementHandleContext(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+
+	s.TPM.StopCommandAuditRecorder(recorder)
+
+	_, err = s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+
+	_, err = s.TPM.StartCommandAuditRecorder(buf, s.TPM.EndorsementHandleContext(), nil)
+	c.Assert(err, IsNil)
+
+	info := s.getSignedAuditInfo(c)
+
+	records, err := ReadCommandAuditLog(buf)
+	c.Assert(err, IsNil)
+	c.Check(VerifyCommandAuditLog(records, info), ErrorMatches, "gap detected before record 2: audited commands were executed outside of the recorder")
+}
+
+type commandAuditLogSuite struct{}
+
+var _ = Suite(&commandAuditLogSuite{})
+
+func (s *commandAuditLogSuite) extend(digest, cpHash, rpHash Digest) Digest {
+	h := HashAlgorithmSHA256.NewHash()
+	h.Write(digest)
+	h.Write(cpHash)
+	h.Write(rpHash)
+	return h.Sum(nil)
+}
+
+func (s *commandAuditLogSuite) writeLog(c *C, records ...*CommandAuditLogRecord) []*CommandAuditLogRecord {
+	buf := new(bytes.Buffer)
+	for _, r := range records {
+		_, err := mu.MarshalToWriter(buf, r)
+		c.Assert(err, IsNil)
+	}
+	out, err := ReadCommandAuditLog(buf)
+	c.Assert(err, IsNil)
+	c.Check(out, DeepEquals, records)
+	return out
+}
+
+func (s *commandAuditLogSuite) TestVerify(c *C) {
+	initial := s.extend(make(Digest, 32), Digest("foo"), Digest("bar"))
+	cpHash := make(Digest, 32)
+	rpHash := make(Digest, 32)
+	rpHash[0] = 1
+
+	records := s.writeLog(c,
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordCheckpoint, AuditCounter: 5, DigestAlg: HashAlgorithmSHA256, AuditDigest: initial},
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordCommand, CommandCode: CommandGetRandom, CpHash: cpHash, RpHash: rpHash})
+
+	info := &CommandAuditInfo{AuditCounter: 5, DigestAlg: AlgorithmSHA256, AuditDigest: s.extend(initial, cpHash, rpHash)}
+	c.Check(VerifyCommandAuditLog(records, info), IsNil)
+}
+
+func (s *commandAuditLogSuite) TestVerifyAfterReset(c *C) {
+	cpHash := make(Digest, 32)
+	rpHash := make(Digest, 32)
+
+	records := s.writeLog(c,
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordCheckpoint, AuditCounter: 5, DigestAlg: HashAlgorithmSHA256},
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordCommand, CommandCode: CommandGetRandom, CpHash: cpHash, RpHash: rpHash},
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordReset},
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordCommand, CommandCode: CommandGetRandom, CpHash: cpHash, RpHash: rpHash})
+
+	digest := s.extend(make(Digest, 32), cpHash, rpHash)
+
+	// The state before the reset
+	c.Check(VerifyCommandAuditLog(records, &CommandAuditInfo{AuditCounter: 6, DigestAlg: AlgorithmSHA256, AuditDigest: digest}), IsNil)
+	// The state at the end
+	c.Check(VerifyCommandAuditLog(records, &CommandAuditInfo{AuditCounter: 7, DigestAlg: AlgorithmSHA256, AuditDigest: digest}), IsNil)
+	c.Check(VerifyCommandAuditLog(records, &CommandAuditInfo{AuditCounter: 8, DigestAlg: AlgorithmSHA256, AuditDigest: digest}), ErrorMatches,
+		"audit counter 8 and digest [[:xdigit:]]+ do not match the log \\(expected counter 7 and digest [[:xdigit:]]+\\)")
+}
+
+func (s *commandAuditLogSuite) TestVerifyGap(c *C) {
+	cpHash := make(Digest, 32)
+	rpHash := make(Digest, 32)
+
+	records := s.writeLog(c,
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordCheckpoint, AuditCounter: 5, DigestAlg: HashAlgorithmSHA256, AuditDigest: make(Digest, 32)},
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordCommand, CommandCode: CommandGetRandom, CpHash: cpHash, RpHash: rpHash},
+		&CommandAuditLogRecord{Type: CommandAuditLogRecordCheckpoint, AuditCounter: 5, DigestAlg: HashAlgorithmSHA256, AuditDigest: make(Digest, 32)})
+
+	c.Check(VerifyCommandAuditLog(records, &CommandAuditInfo{AuditCounter: 5, DigestAlg: AlgorithmSHA256, AuditDigest: make(Digest, 32)}), ErrorMatches,
+		"gap detected before record 2: audited commands were executed outside of the recorder")
+}
+
+func (s *commandAuditLogSuite) TestVerifyNoCheckpoint(c *C) {
+	c.Check(VerifyCommandAuditLog(nil, new(CommandAuditInfo)), ErrorMatches, "log does not begin with a checkpoint")
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"hash"
+	"io"
 )
 
 type policyHMACType uint8
@@ -163,11 +164,7 @@ func (p *sessionParams) findSessionWithAttr(attr SessionAttributes) (*sessionPar
 	return nil, 0
 }
 
-func (p *sessionParams) validateAndAppend(s *sessionParam) error {
-	if len(p.sessions) >= 3 {
-		return errors.New("too many session parameters")
-	}
-
+func (s *sessionParam) validate() error {
 	if s.session != nil {
 		data := s.session.Data()
 		if data == nil {
@@ -200,6 +197,18 @@ func (p *sessionParams) validateAndAppend(s *sessionParam) error {
 		}
 	}
 
+	return nil
+}
+
+func (p *sessionParams) validateAndAppend(s *sessionParam) error {
+	if len(p.sessions) >= 3 {
+		return errors.New("too many session parameters")
+	}
+
+	if err := s.validate(); err != nil {
+		return err
+	}
+
 	p.sessions = append(p.sessions, s)
 	return nil
 }
@@ -227,21 +236,57 @@ func (p *sessionParams) validateAndAppendExtra(in []SessionContext) error {
 	return nil
 }
 
-func (p *sessionParams) computeCallerNonces() error {
+// attachDefaultSession adds the supplied default session to the session parameters, unless it has already been supplied. It
+// replaces the first password authorization if there is one, else it is added as an extra session if it is required for
+// parameter encryption. The encryption attributes are dropped if another session already provides them.
+func (p *sessionParams) attachDefaultSession(session *sessionContext, attrs SessionAttributes) error {
+	for _, s := range p.sessions {
+		if s.session != nil && s.session.handleContext == session.handleContext {
+			return nil
+		}
+	}
+
+	if p.hasDecryptSession() {
+		attrs &^= AttrCommandEncrypt
+	}
+	if s, _ := p.findEncryptSession(); s != nil {
+		attrs &^= AttrResponseEncrypt
+	}
+	session = session.WithAttrs(attrs | AttrContinueSession).(*sessionContext)
+
+	for i, s := range p.sessions {
+		if s.session != nil {
+			continue
+		}
+		r := &sessionParam{session: session, associatedContext: s.associatedContext}
+		if err := r.validate(); err != nil {
+			return err
+		}
+		p.sessions[i] = r
+		return nil
+	}
+
+	if attrs&(AttrCommandEncrypt|AttrResponseEncrypt) == 0 || len(p.sessions) >= 3 {
+		return nil
+	}
+	return p.validateAndAppend(&sessionParam{session: session})
+}
+
+func (p *sessionParams) computeCallerNonces(rand io.Reader) error {
 	for _, s := range p.sessions {
 		if s.session == nil {
 			continue
 		}
 
-		if err := cryptComputeNonce(s.session.Data().NonceCaller); err != nil {
+		if err := cryptComputeNonce(rand, s.session.Data().NonceCaller); err != nil {
 			return fmt.Errorf("cannot compute new caller nonce: %v", err)
 		}
 	}
 	return nil
 }
 
-func (p *sessionParams) buildCommandAuthArea(commandCode CommandCode, commandHandles []Name, cpBytes []byte) ([]AuthCommand, error) {
-	if err := p.computeCallerNonces(); err != nil {
+func (p *sessionParams) buildCommandAuthArea(rand io.Reader, commandCode CommandCode, commandHandles []Name, cpBytes []byte) ([]AuthCommand, error) {
+	if err := p.computeCallerNonces(rand); err != nil {
 		return nil, fmt.Errorf("cannot compute caller nonces: %v", err)
 	}
 
@@ -0,0 +1,123 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// go-tpm2 is a tool for debugging TPM interactions.
+//
+// The decode subcommand decodes a hex encoded command or response packet, supplied
+// either as arguments or on stdin, and prints it in a human-readable form:
+//
+//	go-tpm2 decode [-json] <command>
+//	go-tpm2 decode [-json] [-encrypt] -response-to <command code> <response>
+//
+// The command code for a response can be supplied as a name (eg, TPM_CC_Unseal or
+// Unseal) or as a number.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/decode"
+)
+
+type output interface {
+	String() string
+}
+
+func parseCommandCode(s string) (tpm2.CommandCode, error) {
+	if n, err := strconv.ParseUint(s, 0, 32); err == nil {
+		return tpm2.CommandCode(n), nil
+	}
+
+	if !strings.HasPrefix(s, "TPM_CC_") {
+		s = "TPM_CC_" + s
+	}
+	for cc := tpm2.CommandFirst; cc <= tpm2.CommandFirst+0x100; cc++ {
+		if cc.String() == s {
+			return cc, nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognized command code %q", s)
+}
+
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the decoded packet as JSON")
+	responseTo := fs.String("response-to", "", "Decode a response to the specified command")
+	encrypt := fs.Bool("encrypt", false, "The first response parameter is encrypted")
+	fs.Parse(args)
+
+	var in string
+	if fs.NArg() > 0 {
+		in = strings.Join(fs.Args(), "")
+	} else {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("cannot read stdin: %v", err)
+		}
+		in = string(b)
+	}
+
+	var out output
+	if *responseTo == "" {
+		cmd, err := decode.DecodeCommandHex(in)
+		if err != nil {
+			return err
+		}
+		out = cmd
+	} else {
+		cc, err := parseCommandCode(*responseTo)
+		if err != nil {
+			return err
+		}
+		var attrs []tpm2.SessionAttributes
+		if *encrypt {
+			attrs = append(attrs, tpm2.AttrResponseEncrypt)
+		}
+		rsp, err := decode.DecodeResponseHex(cc, attrs, in)
+		if err != nil {
+			return err
+		}
+		out = rsp
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	fmt.Print(out.String())
+	return nil
+}
+
+func run() error {
+	if len(os.Args) < 2 {
+		return errors.New("usage: go-tpm2 decode [options] [packet]")
+	}
+
+	switch os.Args[1] {
+	case "decode":
+		return runDecode(os.Args[2:])
+	default:
+		return fmt.Errorf("unknown command %q", os.Args[1])
+	}
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
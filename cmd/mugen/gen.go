@@ -0,0 +1,670 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/types"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// tpmKind mirrors mu.TPMKind.
+type tpmKind int
+
+const (
+	kindUnsupported tpmKind = iota
+	kindPrimitive
+	kindSized
+	kindList
+	kindStruct
+	kindUnion
+	kindCustom
+	kindRaw
+)
+
+// options mirrors the options parsed from the tpm2 struct tag by the mu package.
+type options struct {
+	selector string
+	sized    bool
+	raw      bool
+}
+
+func parseOptions(tag string) (out options) {
+	s := reflect.StructTag(tag).Get("tpm2")
+	for _, part := range strings.Split(s, ",") {
+		switch {
+		case strings.HasPrefix(part, "selector:"):
+			out.selector = part[9:]
+		case part == "sized":
+			out.sized = true
+		case part == "raw":
+			out.raw = true
+		}
+	}
+	return
+}
+
+type checkState int
+
+const (
+	checkInProgress checkState = iota + 1
+	checkOK
+)
+
+type generator struct {
+	pkg *types.Package
+
+	custom   *types.Interface
+	union    *types.Interface
+	rawBytes types.Type
+
+	skip     map[string]bool
+	checked  map[*types.Named]checkState
+	failures map[*types.Named]error
+	imports  map[string]string
+
+	buf     *bytes.Buffer
+	counter int
+}
+
+func newGenerator(pkg *types.Package, skip map[string]bool) (*generator, error) {
+	var mu *types.Package
+	for _, p := range pkg.Imports() {
+		if p.Path() == muPath {
+			mu = p
+			break
+		}
+	}
+	if mu == nil {
+		return nil, fmt.Errorf("package %s does not import %s", pkg.Name(), muPath)
+	}
+
+	lookup := func(name string) types.Type {
+		return mu.Scope().Lookup(name).Type()
+	}
+
+	return &generator{
+		pkg:      pkg,
+		custom:   types.NewInterfaceType(nil, []types.Type{lookup("CustomMarshaller"), lookup("CustomUnmarshaller")}).Complete(),
+		union:    lookup("Union").Underlying().(*types.Interface),
+		rawBytes: lookup("RawBytes"),
+		skip:     skip,
+		checked:  make(map[*types.Named]checkState),
+		failures: make(map[*types.Named]error),
+		imports:  map[string]string{muPath: "mu"}}, nil
+}
+
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	g.imports[p.Path()] = p.Name()
+	return p.Name()
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+func (g *generator) tmp(prefix string) string {
+	g.counter++
+	return fmt.Sprintf("%s%d", prefix, g.counter)
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.buf, format, args...)
+}
+
+func isByte(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Kind() == types.Uint8
+}
+
+func isPointer(t types.Type) bool {
+	_, ok := t.(*types.Pointer)
+	return ok
+}
+
+// kindOf mirrors the tpmKind function in the mu package.
+func (g *generator) kindOf(t types.Type, opts options) tpmKind {
+	if opts.sized {
+		return kindSized
+	}
+
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+
+	if types.Implements(types.NewPointer(t), g.custom) {
+		return kindCustom
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch u.Kind() {
+		case types.Bool, types.Int8, types.Int16, types.Int32, types.Int64, types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+			return kindPrimitive
+		}
+	case *types.Slice:
+		switch {
+		case types.Identical(t, g.rawBytes):
+			return kindRaw
+		case opts.raw:
+			return kindRaw
+		case isByte(u.Elem().Underlying()):
+			return kindSized
+		default:
+			return kindList
+		}
+	case *types.Struct:
+		if types.Implements(types.NewPointer(t), g.union) {
+			return kindUnion
+		}
+		return kindStruct
+	}
+
+	return kindUnsupported
+}
+
+func (g *generator) isLocal(n *types.Named) bool {
+	return n.Obj().Pkg() == g.pkg
+}
+
+// isTestType indicates whether the supplied type is a gocheck test suite or has methods
+// that are tests or benchmarks.
+func isTestType(n *types.Named) bool {
+	ms := types.NewMethodSet(types.NewPointer(n))
+	for i := 0; i < ms.Len(); i++ {
+		params := ms.At(i).Type().(*types.Signature).Params()
+		for j := 0; j < params.Len(); j++ {
+			p, ok := params.At(j).Type().(*types.Pointer)
+			if !ok {
+				continue
+			}
+			e, ok := p.Elem().(*types.Named)
+			if !ok || e.Obj().Pkg() == nil {
+				continue
+			}
+			switch e.Obj().Pkg().Path() + "." + e.Obj().Name() {
+			case "gopkg.in/check.v1.C", "testing.T", "testing.B":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isExcluded indicates whether the supplied local type is not a TPM wire structure.
+func (g *generator) isExcluded(n *types.Named) bool {
+	return g.skip[n.Obj().Name()] || isTestType(n)
+}
+
+// hasGeneratedMethods indicates whether the supplied type from another package has
+// generated marshalling code.
+func hasGeneratedMethods(n *types.Named) bool {
+	ms := types.NewMethodSet(types.NewPointer(n))
+	return ms.Lookup(n.Obj().Pkg(), "MarshalMu") != nil && ms.Lookup(n.Obj().Pkg(), "UnmarshalMu") != nil
+}
+
+// check determines whether the generator supports the supplied type. The hasSelector argument
+// indicates whether the type is contained directly in a structure that can supply a union
+// selector.
+func (g *generator) check(t types.Type, opts options, hasSelector bool) error {
+	if n, ok := t.(*types.Named); ok && !g.isLocal(n) && !n.Obj().Exported() {
+		return fmt.Errorf("type %s is not exported", n)
+	}
+
+	k := g.kindOf(t, opts)
+	if p, ok := t.(*types.Pointer); ok && k != kindSized {
+		return g.check(p.Elem(), opts, hasSelector)
+	}
+
+	switch k {
+	case kindPrimitive, kindCustom:
+		return nil
+	case kindSized:
+		switch u := t.Underlying().(type) {
+		case *types.Pointer:
+			if t != u {
+				break
+			}
+			return g.check(t, options{selector: opts.selector, raw: opts.raw}, hasSelector)
+		case *types.Slice:
+			if isByte(u.Elem()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid sized type %s", t)
+	case kindList:
+		return g.check(t.Underlying().(*types.Slice).Elem(), options{}, false)
+	case kindRaw:
+		elem := t.Underlying().(*types.Slice).Elem()
+		if isByte(elem) {
+			return nil
+		}
+		return g.check(elem, options{}, false)
+	case kindStruct:
+		n, ok := t.(*types.Named)
+		switch {
+		case !ok:
+			return g.checkStruct(t.Underlying().(*types.Struct))
+		case g.isLocal(n):
+			return g.checkNamed(n)
+		case hasGeneratedMethods(n):
+			return nil
+		default:
+			return fmt.Errorf("type %s has no generated marshalling code", n)
+		}
+	case kindUnion:
+		n, ok := t.(*types.Named)
+		switch {
+		case !ok || !g.isLocal(n):
+			return fmt.Errorf("union type %s is not declared in this package", t)
+		case !hasSelector:
+			return fmt.Errorf("union type %s is not inside a structure", t)
+		case opts.raw:
+			return fmt.Errorf("union type %s has the raw option", t)
+		}
+		return g.checkNamed(n)
+	}
+
+	return fmt.Errorf("unsupported type %s", t)
+}
+
+func (g *generator) checkStruct(s *types.Struct) error {
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if !f.Exported() {
+			return fmt.Errorf("field %s is not exported", f.Name())
+		}
+		opts := parseOptions(s.Tag(i))
+		if err := g.checkSelector(s, opts); err != nil {
+			return fmt.Errorf("field %s: %v", f.Name(), err)
+		}
+		if err := g.check(f.Type(), opts, true); err != nil {
+			return fmt.Errorf("field %s: %v", f.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (g *generator) checkSelector(s *types.Struct, opts options) error {
+	name := g.selectorName(s, opts)
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if f.Name() != name {
+			continue
+		}
+		if _, ok := f.Type().Underlying().(*types.Interface); ok {
+			return errors.New("selector field has an interface type")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid selector field name %s", name)
+}
+
+func (g *generator) checkUnion(s *types.Struct) error {
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if !f.Exported() {
+			return fmt.Errorf("field %s is not exported", f.Name())
+		}
+		if err := g.check(f.Type(), options{}, false); err != nil {
+			return fmt.Errorf("field %s: %v", f.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (g *generator) checkNamed(n *types.Named) error {
+	switch g.checked[n] {
+	case checkInProgress, checkOK:
+		return nil
+	}
+	if err, ok := g.failures[n]; ok {
+		return err
+	}
+
+	g.checked[n] = checkInProgress
+
+	var err error
+	switch {
+	case g.isExcluded(n):
+		err = errors.New("type is not a TPM wire structure")
+	case g.kindOf(n, options{}) == kindStruct:
+		err = g.checkStruct(n.Underlying().(*types.Struct))
+	case g.kindOf(n, options{}) == kindUnion:
+		err = g.checkUnion(n.Underlying().(*types.Struct))
+	default:
+		err = g.check(n.Underlying(), options{}, false)
+	}
+	if err == nil {
+		for _, name := range []string{"MarshalMu", "UnmarshalMu", "marshalMu", "unmarshalMu"} {
+			if obj, _, _ := types.LookupFieldOrMethod(n, true, g.pkg, name); obj != nil {
+				err = fmt.Errorf("type already has a field or method named %s", name)
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		delete(g.checked, n)
+		g.failures[n] = err
+		return fmt.Errorf("%s: %v", n.Obj().Name(), err)
+	}
+	g.checked[n] = checkOK
+	return nil
+}
+
+func (g *generator) selectorName(s *types.Struct, opts options) string {
+	if opts.selector != "" {
+		return opts.selector
+	}
+	return s.Field(0).Name()
+}
+
+func (g *generator) primitiveMethod(t types.Type) (method, conv string) {
+	switch t.Underlying().(*types.Basic).Kind() {
+	case types.Bool:
+		return "Bool", "bool"
+	case types.Int8, types.Uint8:
+		return "Uint8", "uint8"
+	case types.Int16, types.Uint16:
+		return "Uint16", "uint16"
+	case types.Int32, types.Uint32:
+		return "Uint32", "uint32"
+	default:
+		return "Uint64", "uint64"
+	}
+}
+
+// marshal emits code to marshal the value of the supplied expression, which must be
+// addressable. The selector argument is an expression for the union selector of the
+// containing structure, if there is one.
+func (g *generator) marshal(expr string, t types.Type, opts options, selector string) {
+	k := g.kindOf(t, opts)
+	if p, ok := t.(*types.Pointer); ok && k != kindSized {
+		v := g.tmp("p")
+		g.printf("{\n%s := %s\nif %s == nil {\n%s = new(%s)\n}\n", v, expr, v, v, g.typeString(p.Elem()))
+		g.marshal("(*"+v+")", p.Elem(), opts, selector)
+		g.printf("}\n")
+		return
+	}
+
+	switch k {
+	case kindPrimitive:
+		method, conv := g.primitiveMethod(t)
+		g.printf("e.Write%s(%s(%s))\n", method, conv, expr)
+	case kindSized:
+		start := g.tmp("start")
+		g.printf("if %s == nil {\ne.WriteUint16(0)\n} else {\n%s := e.BeginSized()\n", expr, start)
+		if isPointer(t) {
+			g.marshal(expr, t, options{selector: opts.selector, raw: opts.raw}, selector)
+		} else {
+			g.printf("e.WriteBytes(%s)\n", expr)
+		}
+		g.printf("if err := e.EndSized(%s); err != nil {\nreturn err\n}\n}\n", start)
+	case kindList:
+		i := g.tmp("i")
+		g.printf("if err := e.WriteListLength(len(%s)); err != nil {\nreturn err\n}\n", expr)
+		g.printf("for %s := range %s {\n", i, expr)
+		g.marshal(fmt.Sprintf("%s[%s]", expr, i), t.Underlying().(*types.Slice).Elem(), options{}, "")
+		g.printf("}\n")
+	case kindRaw:
+		elem := t.Underlying().(*types.Slice).Elem()
+		if isByte(elem) {
+			g.printf("e.WriteBytes(%s)\n", expr)
+			break
+		}
+		i := g.tmp("i")
+		g.printf("for %s := range %s {\n", i, expr)
+		g.marshal(fmt.Sprintf("%s[%s]", expr, i), elem, options{}, "")
+		g.printf("}\n")
+	case kindStruct:
+		if _, ok := t.(*types.Named); ok {
+			g.printf("if err := %s.MarshalMu(e); err != nil {\nreturn err\n}\n", expr)
+			break
+		}
+		g.marshalFields(expr, t.Underlying().(*types.Struct))
+	case kindUnion:
+		g.printf("if err := %s.marshalMu(e, reflect.ValueOf(%s)); err != nil {\nreturn err\n}\n", expr, selector)
+		g.imports["reflect"] = "reflect"
+	case kindCustom:
+		g.printf("if err := %s.Marshal(e); err != nil {\nreturn err\n}\n", expr)
+	}
+}
+
+func (g *generator) marshalFields(expr string, s *types.Struct) {
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		opts := parseOptions(s.Tag(i))
+		g.marshal(expr+"."+f.Name(), f.Type(), opts, expr+"."+g.selectorName(s, opts))
+	}
+}
+
+// unmarshal emits code to unmarshal to the supplied expression, which must be addressable.
+// The selector argument is an expression for the union selector of the containing structure,
+// if there is one.
+func (g *generator) unmarshal(expr string, t types.Type, opts options, selector string) {
+	k := g.kindOf(t, opts)
+	if p, ok := t.(*types.Pointer); ok && k != kindSized {
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", expr, expr, g.typeString(p.Elem()))
+		g.unmarshal("(*"+expr+")", p.Elem(), opts, selector)
+		return
+	}
+
+	switch k {
+	case kindPrimitive:
+		method, _ := g.primitiveMethod(t)
+		x := g.tmp("x")
+		g.printf("{\n%s, err := d.Read%s()\nif err != nil {\nreturn err\n}\n%s = %s(%s)\n}\n", x, method, expr, g.typeString(t), x)
+	case kindSized:
+		n := g.tmp("n")
+		if isPointer(t) {
+			g.printf("{\n%s, err := d.ReadSize(%s != nil)\n", n, expr)
+		} else {
+			g.printf("{\n%s, err := d.ReadSize(false)\n", n)
+		}
+		g.printf("if err != nil {\nreturn err\n}\nif %s > 0 {\n", n)
+		if isPointer(t) {
+			end := g.tmp("end")
+			g.printf("%s := d.BeginSized(%s)\n", end, n)
+			g.unmarshal(expr, t, options{selector: opts.selector, raw: opts.raw}, selector)
+			g.printf("d.EndSized(%s)\n", end)
+		} else {
+			g.printf("%s = make(%s, %s)\nif err := d.ReadBytes(%s); err != nil {\nreturn err\n}\n", expr, g.typeString(t), n, expr)
+		}
+		g.printf("}\n}\n")
+	case kindList:
+		elem := t.Underlying().(*types.Slice).Elem()
+		n := g.tmp("n")
+		i := g.tmp("i")
+		z := g.tmp("zero")
+		g.printf("{\n%s, err := d.ReadListLength()\nif err != nil {\nreturn err\n}\n", n)
+		g.printf("if %s == nil || cap(%s) < %s {\n%s = make(%s, 0, %s)\n}\n", expr, expr, n, expr, g.typeString(t), n)
+		g.printf("%s = %s[:0]\n", expr, expr)
+		g.printf("for %s := 0; %s < %s; %s++ {\nvar %s %s\n%s = append(%s, %s)\n", i, i, n, i, z, g.typeString(elem), expr, expr, z)
+		g.unmarshal(fmt.Sprintf("%s[%s]", expr, i), elem, options{}, "")
+		g.printf("}\n}\n")
+	case kindRaw:
+		elem := t.Underlying().(*types.Slice).Elem()
+		if isByte(elem) {
+			g.printf("if err := d.ReadBytes(%s); err != nil {\nreturn err\n}\n", expr)
+			break
+		}
+		i := g.tmp("i")
+		z := g.tmp("zero")
+		g.printf("for %s := range %s {\nvar %s %s\n%s[%s] = %s\n", i, expr, z, g.typeString(elem), expr, i, z)
+		g.unmarshal(fmt.Sprintf("%s[%s]", expr, i), elem, options{}, "")
+		g.printf("}\n")
+	case kindStruct:
+		if _, ok := t.(*types.Named); ok {
+			g.printf("if err := %s.UnmarshalMu(d); err != nil {\nreturn err\n}\n", expr)
+			break
+		}
+		g.unmarshalFields(expr, t.Underlying().(*types.Struct))
+	case kindUnion:
+		g.printf("if err := %s.unmarshalMu(d, reflect.ValueOf(%s)); err != nil {\nreturn err\n}\n", expr, selector)
+		g.imports["reflect"] = "reflect"
+	case kindCustom:
+		g.printf("if err := %s.Unmarshal(d); err != nil {\nreturn err\n}\n", expr)
+	}
+}
+
+func (g *generator) unmarshalFields(expr string, s *types.Struct) {
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		opts := parseOptions(s.Tag(i))
+		g.unmarshal(expr+"."+f.Name(), f.Type(), opts, expr+"."+g.selectorName(s, opts))
+	}
+}
+
+func (g *generator) generateType(n *types.Named) {
+	name := n.Obj().Name()
+
+	g.printf("func (v %s) MarshalMu(e *mu.Encoder) error {\n", name)
+	if s, ok := n.Underlying().(*types.Struct); ok {
+		g.marshalFields("v", s)
+	} else {
+		g.marshal("v", n, options{}, "")
+	}
+	g.printf("return nil\n}\n\n")
+
+	g.printf("func (v *%s) UnmarshalMu(d *mu.Decoder) error {\n", name)
+	if s, ok := n.Underlying().(*types.Struct); ok {
+		g.unmarshalFields("v", s)
+	} else {
+		g.unmarshal("(*v)", n, options{}, "")
+	}
+	g.printf("return nil\n}\n\n")
+}
+
+func (g *generator) generateUnion(n *types.Named) {
+	name := n.Obj().Name()
+	s := n.Underlying().(*types.Struct)
+	panicMsg := fmt.Sprintf("%q", "Union.Select implementation for type "+name+" returned a non-member pointer")
+
+	g.printf("func (v *%s) marshalMu(e *mu.Encoder, selector reflect.Value) error {\n", name)
+	g.printf("switch v.Select(selector) {\ncase nil, mu.NilUnionValue:\n")
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		g.printf("case &v.%s:\n", f.Name())
+		g.marshal("v."+f.Name(), f.Type(), options{}, "")
+	}
+	g.printf("default:\npanic(%s)\n}\nreturn nil\n}\n\n", panicMsg)
+
+	g.printf("func (v *%s) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {\n", name)
+	g.printf("switch v.Select(selector) {\ncase nil:\nreturn &mu.InvalidSelectorError{Selector: selector}\ncase mu.NilUnionValue:\n")
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		g.printf("case &v.%s:\n", f.Name())
+		g.unmarshal("v."+f.Name(), f.Type(), options{}, "")
+	}
+	g.printf("default:\npanic(%s)\n}\nreturn nil\n}\n\n", panicMsg)
+
+	g.imports["reflect"] = "reflect"
+}
+
+func (g *generator) candidates() (out []*types.Named) {
+	for _, name := range g.pkg.Scope().Names() {
+		obj, ok := g.pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		n, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+
+		switch g.kindOf(n, options{}) {
+		case kindPrimitive, kindSized, kindList, kindStruct, kindUnion:
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (g *generator) generate() ([]byte, error) {
+	candidates := g.candidates()
+
+	// A type that references another type that is still being checked (because of a cycle) is
+	// assumed to be supported. If the other type turns out not to be supported, the first type
+	// has to be checked again, so repeat this until no more failures are found.
+	var named []*types.Named
+	for {
+		failures := len(g.failures)
+		g.checked = make(map[*types.Named]checkState)
+		named = nil
+		for _, n := range candidates {
+			if err := g.checkNamed(n); err == nil {
+				named = append(named, n)
+			}
+		}
+		if len(g.failures) == failures {
+			break
+		}
+	}
+
+	if *verbose {
+		for _, n := range candidates {
+			if err, ok := g.failures[n]; ok {
+				fmt.Fprintf(os.Stderr, "skipping %s: %v\n", n.Obj().Name(), err)
+			}
+		}
+	}
+
+	body := new(bytes.Buffer)
+	g.buf = body
+
+	var registered []string
+	for _, n := range named {
+		if g.kindOf(n, options{}) == kindUnion {
+			g.generateUnion(n)
+			continue
+		}
+		g.generateType(n)
+		registered = append(registered, n.Obj().Name())
+	}
+
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "// Code generated by mugen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkg.Name())
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	for _, path := range std {
+		fmt.Fprintf(out, "%q\n", path)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		fmt.Fprintf(out, "\n")
+	}
+	for _, path := range other {
+		fmt.Fprintf(out, "%q\n", path)
+	}
+	fmt.Fprintf(out, ")\n\nfunc init() {\nmu.RegisterGeneratedTypes(\n")
+	for _, name := range registered {
+		fmt.Fprintf(out, "(*%s)(nil),\n", name)
+	}
+	fmt.Fprintf(out, ")\n}\n\n")
+	body.WriteTo(out)
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("cannot format generated code: %v", err)
+	}
+	return src, nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// mugen generates marshalling code for the types in a package, so that the functions in the
+// mu package can marshal and unmarshal them without using reflection. It is intended to be
+// run with go generate:
+//
+//	//go:generate go run github.com/canonical/go-tpm2/cmd/mugen -o mu_generated.go
+//
+// Code is generated for every named type declared in the package that corresponds to a
+// primitive, sized buffer, list or structure TPM type (see mu.DetermineTPMKind), and for
+// which all of the types that it references are supported. Unions are supported as fields
+// of structures. Types that implement mu.CustomMarshaller are marshalled with their own
+// implementation. Types that aren't supported are skipped, and continue to be marshalled
+// using reflection. The -v flag prints the reason for skipping each type.
+//
+// Types that aren't TPM wire structures should be excluded by adding a line containing
+// only the directive below to the type's doc comment:
+//
+//	//mugen:skip
+//
+// gocheck test suites and types with methods that accept a *testing.T or *testing.B are
+// excluded automatically.
+//
+// The -tests flag generates code for the types declared in the package's external test
+// package, and should be used with an output file name that ends in _test.go.
+//
+// The generated code is only used when all of the values supplied to one of the mu
+// functions are supported. If it returns an error, the reflection based code is used
+// instead in order to obtain a fully described error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const muPath = "github.com/canonical/go-tpm2/mu"
+
+var (
+	output  = flag.String("o", "", "The output file")
+	tests   = flag.Bool("tests", false, "Generate code for the external test package")
+	verbose = flag.Bool("v", false, "Print the types that are skipped")
+)
+
+const skipDirective = "//mugen:skip"
+
+func parseFiles(fset *token.FileSet, dir, output string, files []string) ([]*ast.File, error) {
+	var astFiles []*ast.File
+	for _, f := range files {
+		if f == filepath.Base(output) {
+			// Don't load the output file, which might be out of date.
+			continue
+		}
+		astFile, err := parser.ParseFile(fset, filepath.Join(dir, f), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		astFiles = append(astFiles, astFile)
+	}
+	return astFiles, nil
+}
+
+// testImporter is a types.ImporterFrom that makes the declarations in the internal test
+// files of a package visible to its external test package.
+type testImporter struct {
+	types.ImporterFrom
+	fset   *token.FileSet
+	dir    string
+	output string
+	path   string
+	files  []string
+	pkg    *types.Package
+}
+
+func (i *testImporter) Import(path string) (*types.Package, error) {
+	return i.ImportFrom(path, "", 0)
+}
+
+func (i *testImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if path != i.path {
+		return i.ImporterFrom.ImportFrom(path, dir, mode)
+	}
+	if i.pkg != nil {
+		return i.pkg, nil
+	}
+
+	files, err := parseFiles(i.fset, i.dir, i.output, i.files)
+	if err != nil {
+		return nil, err
+	}
+	conf := types.Config{Importer: i.ImporterFrom}
+	i.pkg, err = conf.Check(path, i.fset, files, nil)
+	return i.pkg, err
+}
+
+func importPath(dir string) (string, error) {
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine import path: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// skippedTypes returns the names of the types in the supplied files that have the
+// skip directive in their doc comment.
+func skippedTypes(files []*ast.File) map[string]bool {
+	hasDirective := func(doc *ast.CommentGroup) bool {
+		if doc == nil {
+			return false
+		}
+		for _, c := range doc.List {
+			if strings.TrimSpace(c.Text) == skipDirective {
+				return true
+			}
+		}
+		return false
+	}
+
+	skip := make(map[string]bool)
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if hasDirective(ts.Doc) || (len(gd.Specs) == 1 && hasDirective(gd.Doc)) {
+					skip[ts.Name.Name] = true
+				}
+			}
+		}
+	}
+	return skip
+}
+
+// loadPackage loads the package in the specified directory, or its external test package
+// if tests is true. It returns the package and the names of the types that have the skip
+// directive.
+func loadPackage(dir, output string, tests bool) (*types.Package, map[string]bool, error) {
+	bp, err := build.Default.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err := importPath(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fset := token.NewFileSet()
+	var imp types.Importer = importer.ForCompiler(fset, "source", nil)
+
+	files := bp.GoFiles
+	if tests {
+		imp = &testImporter{
+			ImporterFrom: imp.(types.ImporterFrom),
+			fset:         fset,
+			dir:          dir,
+			output:       output,
+			path:         path,
+			files:        append(bp.GoFiles, bp.TestGoFiles...)}
+		path += "_test"
+		files = bp.XTestGoFiles
+	}
+
+	astFiles, err := parseFiles(fset, dir, output, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(astFiles) == 0 {
+		return nil, nil, fmt.Errorf("no source files for package %s in %s", path, dir)
+	}
+
+	conf := types.Config{Importer: imp}
+	pkg, err := conf.Check(path, fset, astFiles, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg, skippedTypes(astFiles), nil
+}
+
+// generateFile returns the generated code for the package in the specified directory, or
+// its external test package if tests is true.
+func generateFile(dir, output string, tests bool) ([]byte, error) {
+	pkg, skip, err := loadPackage(dir, output, tests)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load package: %v", err)
+	}
+
+	g, err := newGenerator(pkg, skip)
+	if err != nil {
+		return nil, err
+	}
+	return g.generate()
+}
+
+func run() error {
+	if *output == "" {
+		return fmt.Errorf("no output file specified")
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	src, err := generateFile(dir, *output, *tests)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, filepath.Base(*output)), src, 0644)
+}
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "mugen: %v\n", err)
+		os.Exit(1)
+	}
+}
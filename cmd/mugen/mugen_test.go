@@ -0,0 +1,61 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type mugenSuite struct{}
+
+var _ = Suite(&mugenSuite{})
+
+func (s *mugenSuite) checkUpToDate(c *C, dir, output string, tests bool) {
+	expected, err := ioutil.ReadFile(filepath.Join(dir, output))
+	c.Assert(err, IsNil)
+
+	src, err := generateFile(dir, output, tests)
+	c.Assert(err, IsNil)
+	c.Check(string(src) == string(expected), Equals, true,
+		Commentf("%s is out of date - run go generate in %s", output, dir))
+}
+
+func (s *mugenSuite) TestPackageUpToDate(c *C) {
+	s.checkUpToDate(c, "../..", "mu_generated.go", false)
+}
+
+func (s *mugenSuite) TestMuTestsUpToDate(c *C) {
+	s.checkUpToDate(c, "../../mu", "mu_generated_test.go", true)
+}
+
+func (s *mugenSuite) TestExcludedTypes(c *C) {
+	pkg, skip, err := loadPackage("../..", "mu_generated.go", false)
+	c.Assert(err, IsNil)
+	g, err := newGenerator(pkg, skip)
+	c.Assert(err, IsNil)
+	_, err = g.generate()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"TPMInfo", "TPMMemoryInfo", "TPMNVInfo", "TPMLockoutInfo", "TPMError"} {
+		n := pkg.Scope().Lookup(name)
+		c.Assert(n, NotNil, Commentf(name))
+		_, failed := g.failures[n.Type().(*types.Named)]
+		c.Check(failed, Equals, true, Commentf(name))
+	}
+
+	pkg, skip, err = loadPackage("../../mu", "mu_generated_test.go", true)
+	c.Assert(err, IsNil)
+	n := pkg.Scope().Lookup("optionsSuite")
+	c.Assert(n, NotNil)
+	c.Check(isTestType(n.Type().(*types.Named)), Equals, true)
+	c.Check(skip["testBrokenWriter"], Equals, true)
+}
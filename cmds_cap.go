@@ -111,12 +111,16 @@ func (t *TPMContext) GetCapability(capability Capability, property, propertyCoun
 			}
 		}
 
-		nextProperty += p + 1
-		remaining -= uint32(l)
-
-		if !moreData || remaining <= 0 {
+		// remaining is unsigned, so check whether this response satisfies the request before
+		// subtracting from it.
+		if !moreData || l == 0 || uint32(l) >= remaining {
 			break
 		}
+
+		// The next request starts from the property after the last one returned, which is
+		// not relative to the previous start.
+		nextProperty = p + 1
+		remaining -= uint32(l)
 	}
 
 	return capabilityData, nil
@@ -7,8 +7,10 @@ package tpm2
 // Section 28 - Context Management
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/canonical/go-tpm2/mu"
 
@@ -52,6 +54,8 @@ func (t *TPMContext) ContextSave(saveContext HandleContext) (context *Context, e
 
 	switch c := saveContext.(type) {
 	case *sessionContext:
+		t.mu.Lock()
+		defer t.mu.Unlock()
 		c.handleContext.Data.Session = nil
 		if t.exclusiveSession == c {
 			t.exclusiveSession = nil
@@ -145,6 +149,67 @@ func (t *TPMContext) ContextLoad(context *Context) (loadedContext HandleContext,
 	return hc, nil
 }
 
+// SaveSessionToWriter saves the supplied session with TPMContext.ContextSave and serializes the returned context to w, so that
+// the session can be resumed by another process with TPMContext.LoadSessionFromReader. The serialized context includes the
+// host-side state of the session, including the session key and the current nonces, so the data must be protected in the same
+// way as the session key.
+//
+// On successful completion, the session is saved rather than loaded, and session can only be used as an argument to
+// TPMContext.FlushContext. As the nonces are updated each time a session is used, the session should be saved again after it
+// is used in order to keep the serialized data in sync with the TPM, and the data from each save can only be loaded once.
+//
+// Note that some resource managers (such as the Linux kernel's /dev/tpmrm0 device) flush sessions created by a connection when
+// the connection is closed, in which case a session can't be resumed by another process.
+func (t *TPMContext) SaveSessionToWriter(w io.Writer, session SessionContext) error {
+	if _, ok := session.(*sessionContext); !ok {
+		return makeInvalidArgError("session", "not a session")
+	}
+	context, err := t.ContextSave(session)
+	if err != nil {
+		return err
+	}
+	_, err = mu.MarshalToWriter(w, context)
+	return err
+}
+
+// SaveSessionToBytes saves the supplied session with TPMContext.ContextSave and returns the serialized context, so that the
+// session can be resumed by another process with TPMContext.LoadSessionFromBytes. See TPMContext.SaveSessionToWriter.
+func (t *TPMContext) SaveSessionToBytes(session SessionContext) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := t.SaveSessionToWriter(buf, session); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadSessionFromReader reads a serialized session context from r, created by TPMContext.SaveSessionToWriter, and loads it
+// with TPMContext.ContextLoad. On successful completion, it returns a SessionContext that can be used in place of the one that
+// was saved, with the same host-side state.
+func (t *TPMContext) LoadSessionFromReader(r io.Reader) (SessionContext, error) {
+	var context *Context
+	if _, err := mu.UnmarshalFromReader(r, &context); err != nil {
+		return nil, xerrors.Errorf("cannot unmarshal context: %w", err)
+	}
+
+	switch context.SavedHandle.Type() {
+	case HandleTypeHMACSession, HandleTypePolicySession:
+	default:
+		return nil, errors.New("context does not correspond to a session")
+	}
+
+	hc, err := t.ContextLoad(context)
+	if err != nil {
+		return nil, err
+	}
+	return hc.(SessionContext), nil
+}
+
+// LoadSessionFromBytes loads a serialized session context created by TPMContext.SaveSessionToBytes. See
+// TPMContext.LoadSessionFromReader.
+func (t *TPMContext) LoadSessionFromBytes(b []byte) (SessionContext, error) {
+	return t.LoadSessionFromReader(bytes.NewReader(b))
+}
+
 // FlushContext executes the TPM2_FlushContext command on the handle referenced by flushContext, in order to flush resources
 // associated with it from the TPM. If flushContext does not correspond to a transient object or a session, then it will return
 // with an error.
@@ -5,11 +5,14 @@
 package tpm2_test
 
 import (
+	"bytes"
+
 	. "gopkg.in/check.v1"
 
 	. "github.com/canonical/go-tpm2"
 	"github.com/canonical/go-tpm2/mu"
 	"github.com/canonical/go-tpm2/testutil"
+	"github.com/canonical/go-tpm2/util"
 )
 
 type contextSuiteBase struct {
@@ -198,6 +201,50 @@ func (s *contextSuite) TestContextSaveAndLoadSession(c *C) {
 	c.Check(session.Handle(), testutil.InSlice(Equals), handles)
 }
 
+func (s *contextSuite) TestSaveAndLoadSessionBytes(c *C) {
+	session := s.StartAuthSession(c, nil, nil, SessionTypeHMAC, nil, HashAlgorithmSHA256)
+
+	for i := 0; i < 2; i++ {
+		_, _, _, _, _, err := s.TPM.CreatePrimary(s.TPM.OwnerHandleContext(), nil, testutil.NewRSAStorageKeyTemplate(), nil, nil, session)
+		c.Assert(err, IsNil)
+
+		data, err := s.TPM.SaveSessionToBytes(session)
+		c.Assert(err, IsNil)
+		c.Check(session.(*SessionContextImpl).Data(), IsNil)
+
+		restored, err := s.TPM.LoadSessionFromBytes(data)
+		c.Assert(err, IsNil)
+		c.Check(restored.Handle(), Equals, session.Handle())
+		c.Check(restored.(*SessionContextImpl).Data(), NotNil)
+
+		_, err = s.TPM.LoadSessionFromBytes(data)
+		c.Check(IsTPMParameterError(err, ErrorHandle, CommandContextLoad, 1), testutil.IsTrue)
+
+		session = restored
+	}
+
+	_, _, _, _, _, err := s.TPM.CreatePrimary(s.TPM.OwnerHandleContext(), nil, testutil.NewRSAStorageKeyTemplate(), nil, nil, session)
+	c.Check(err, IsNil)
+}
+
+func (s *contextSuite) TestSaveAndLoadSessionWriter(c *C) {
+	session := s.StartAuthSession(c, nil, nil, SessionTypePolicy, nil, HashAlgorithmSHA256)
+	c.Check(s.TPM.PolicyAuthValue(session), IsNil)
+
+	buf := new(bytes.Buffer)
+	c.Check(s.TPM.SaveSessionToWriter(buf, session), IsNil)
+
+	restored, err := s.TPM.LoadSessionFromReader(buf)
+	c.Assert(err, IsNil)
+
+	digest, err := s.TPM.PolicyGetDigest(restored)
+	c.Check(err, IsNil)
+
+	trial := util.ComputeAuthPolicy(HashAlgorithmSHA256)
+	trial.PolicyAuthValue()
+	c.Check(digest, DeepEquals, trial.GetDigest())
+}
+
 func (s *contextSuite) TestEvictControl(c *C) {
 	s.testEvictControl(c, &testEvictControlData{
 		auth:   s.TPM.OwnerHandleContext(),
@@ -169,8 +169,22 @@ func (t *TPMContext) PolicyPCR(policySession SessionContext, pcrDigest Digest, p
 		pcrDigest, pcrs)
 }
 
-// func (t *TPMContext) PolicyLocality(policySession HandleContext, locality Locality, sessions ...SessionContext) error {
-// }
+// PolicyLocality executes the TPM2_PolicyLocality command to indicate that an authorization policy should be limited to commands
+// executed at the localities selected by the locality argument. This is a deferred assertion. The value of locality has the same
+// encoding as TPMA_LOCALITY, where each of the bits 0 to 4 select one of the localities 0 to 4, and values greater than 31 select a
+// single extended locality.
+//
+// If locality is zero, a *TPMParameterError error with an error code of ErrorRange will be returned. If the session associated
+// with policySession has already been limited to a set of localities and locality is not a subset of these, a *TPMParameterError
+// error with an error code of ErrorRange will be returned.
+//
+// On successful completion, the policy digest of the session context associated with policySession will be extended to include
+// the value of locality, and the localities will be recorded on the session context to limit usage of the session.
+func (t *TPMContext) PolicyLocality(policySession SessionContext, locality Locality, sessions ...SessionContext) error {
+	return t.RunCommand(CommandPolicyLocality, sessions,
+		policySession, Delimiter,
+		locality)
+}
 
 // PolicyNV executes the TPM2_PolicyNV command to gate a policy based on the contents of the NV index associated with nvIndex, and is
 // an immediate assertion. The caller specifies a value to be used for the comparison via the operandB argument, an offset from the
@@ -650,6 +650,82 @@ func TestPolicyCommandCode(t *testing.T) {
 	}
 }
 
+func TestPolicyLocality(t *testing.T) {
+	tpm, _, closeTPM := testutil.NewTPMContextT(t, 0)
+	defer closeTPM()
+
+	for _, data := range []struct {
+		desc     string
+		locality Locality
+	}{
+		{
+			desc:     "0",
+			locality: 1 << 0,
+		},
+		{
+			desc:     "0And3",
+			locality: (1 << 0) | (1 << 3),
+		},
+		{
+			desc:     "Extended",
+			locality: 32,
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			trial := util.ComputeAuthPolicy(HashAlgorithmSHA256)
+			trial.PolicyLocality(data.locality)
+
+			sessionContext, err := tpm.StartAuthSession(nil, nil, SessionTypePolicy, nil, HashAlgorithmSHA256)
+			if err != nil {
+				t.Fatalf("StartAuthSession failed: %v", err)
+			}
+			defer flushContext(t, tpm, sessionContext)
+
+			if err := tpm.PolicyLocality(sessionContext, data.locality); err != nil {
+				t.Fatalf("PolicyLocality failed: %v", err)
+			}
+
+			digest, err := tpm.PolicyGetDigest(sessionContext)
+			if err != nil {
+				t.Fatalf("PolicyGetDigest failed: %v", err)
+			}
+
+			if !bytes.Equal(digest, trial.GetDigest()) {
+				t.Errorf("Unexpected session digest")
+			}
+		})
+	}
+
+	t.Run("Zero", func(t *testing.T) {
+		sessionContext, err := tpm.StartAuthSession(nil, nil, SessionTypePolicy, nil, HashAlgorithmSHA256)
+		if err != nil {
+			t.Fatalf("StartAuthSession failed: %v", err)
+		}
+		defer flushContext(t, tpm, sessionContext)
+
+		err = tpm.PolicyLocality(sessionContext, 0)
+		if !IsTPMParameterError(err, ErrorRange, CommandPolicyLocality, 1) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("NotSubset", func(t *testing.T) {
+		sessionContext, err := tpm.StartAuthSession(nil, nil, SessionTypePolicy, nil, HashAlgorithmSHA256)
+		if err != nil {
+			t.Fatalf("StartAuthSession failed: %v", err)
+		}
+		defer flushContext(t, tpm, sessionContext)
+
+		if err := tpm.PolicyLocality(sessionContext, 1<<0); err != nil {
+			t.Fatalf("PolicyLocality failed: %v", err)
+		}
+		err = tpm.PolicyLocality(sessionContext, 1<<3)
+		if !IsTPMParameterError(err, ErrorRange, CommandPolicyLocality, 1) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
 func TestPolicyCpHash(t *testing.T) {
 	tpm, _, closeTPM := testutil.NewTPMContextT(t, 0)
 	defer closeTPM()
@@ -156,14 +156,15 @@ func (t *TPMContext) EventSequenceComplete(pcrContext, sequenceContext ResourceC
 //
 // On success, the sequence object associated with sequenceContext will be evicted, and sequenceContext will become invalid.
 func (t *TPMContext) SequenceExecute(sequenceContext ResourceContext, buffer []byte, hierarchy Handle, sequenceContextAuthSession SessionContext, sessions ...SessionContext) (result Digest, validation *TkHashcheck, err error) {
-	if err := t.initPropertiesIfNeeded(); err != nil {
+	props, err := t.initPropertiesIfNeeded()
+	if err != nil {
 		return nil, nil, err
 	}
 
 	total := 0
-	for len(buffer)-total > t.maxBufferSize {
+	for len(buffer)-total > props.maxBufferSize {
 		b := buffer[total:]
-		b = b[:t.maxBufferSize]
+		b = b[:props.maxBufferSize]
 		if err := t.SequenceUpdate(sequenceContext, b, sequenceContextAuthSession, sessions...); err != nil {
 			return nil, nil, err
 		}
@@ -195,14 +196,15 @@ func (t *TPMContext) SequenceExecute(sequenceContext ResourceContext, buffer []b
 //
 // On success, the sequence object associated with sequenceContext will be evicted, and sequenceContext will become invalid.
 func (t *TPMContext) EventSequenceExecute(pcrContext, sequenceContext ResourceContext, buffer []byte, pcrContextAuthSession, sequenceContextAuthSession SessionContext, sessions ...SessionContext) (results TaggedHashList, err error) {
-	if err := t.initPropertiesIfNeeded(); err != nil {
+	props, err := t.initPropertiesIfNeeded()
+	if err != nil {
 		return nil, err
 	}
 
 	total := 0
-	for len(buffer)-total > t.maxBufferSize {
+	for len(buffer)-total > props.maxBufferSize {
 		b := buffer[total:]
-		b = b[:t.maxBufferSize]
+		b = b[:props.maxBufferSize]
 		if err := t.SequenceUpdate(sequenceContext, b, sequenceContextAuthSession, sessions...); err != nil {
 			return nil, err
 		}
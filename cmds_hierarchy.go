@@ -185,6 +185,8 @@ func (t *TPMContext) Clear(authContext ResourceContext, authContextAuthSession S
 			// Clear auth values for the owner, endorsement and lockout hierarchies. If the supplied session is not
 			// bound to authContext, the TPM will response with a HMAC generated with a key derived from the empty
 			// auth value.
+			t.mu.Lock()
+			defer t.mu.Unlock()
 			for _, h := range []Handle{HandleOwner, HandleEndorsement, HandleLockout} {
 				if rc, exists := t.permanentResources[h]; exists {
 					rc.SetAuthValue(nil)
@@ -236,16 +236,17 @@ func (t *TPMContext) NVWriteRaw(authContext, nvIndex ResourceContext, data MaxNV
 //
 // On successful completion, the AttrNVWritten flag will be set if this is the first time that the index has been written to.
 func (t *TPMContext) NVWrite(authContext, nvIndex ResourceContext, data []byte, offset uint16, authContextAuthSession SessionContext, sessions ...SessionContext) error {
-	if err := t.initPropertiesIfNeeded(); err != nil {
+	props, err := t.initPropertiesIfNeeded()
+	if err != nil {
 		return err
 	}
 
-	if len(data) > t.maxNVBufferSize {
+	if len(data) > props.maxNVBufferSize {
 		if authContextAuthSession != nil {
 			sessionPrivate := authContextAuthSession.(*sessionContext)
 			if sessionPrivate.attrs&AttrContinueSession == 0 {
 				return makeInvalidArgError("authContextAuthSession",
-					fmt.Sprintf("the AttrContinueSession attribute is required for authorization sessions for writes larger than %d bytes", t.maxNVBufferSize))
+					fmt.Sprintf("the AttrContinueSession attribute is required for authorization sessions for writes larger than %d bytes", props.maxNVBufferSize))
 			}
 			sessionData := sessionPrivate.Data()
 			if sessionData == nil {
@@ -253,34 +254,37 @@ func (t *TPMContext) NVWrite(authContext, nvIndex ResourceContext, data []byte,
 			}
 			if sessionData.SessionType == SessionTypePolicy {
 				return makeInvalidArgError("authContextAuthSession",
-					fmt.Sprintf("a policy authorization session cannot be used for writes larger than %d bytes", t.maxNVBufferSize))
+					fmt.Sprintf("a policy authorization session cannot be used for writes larger than %d bytes", props.maxNVBufferSize))
 			}
 		}
 		for i, s := range sessions {
 			if s.(*sessionContext).attrs&AttrContinueSession == 0 {
 				return makeInvalidArgError("sessions",
-					fmt.Sprintf("the AttrContinueSession attribute is required for session at index %d for writes larger than %d bytes", i, t.maxNVBufferSize))
+					fmt.Sprintf("the AttrContinueSession attribute is required for session at index %d for writes larger than %d bytes", i, props.maxNVBufferSize))
 			}
 		}
 	}
 
-	total := 0
-	for {
-		d := data[total:]
-		if len(d) > t.maxNVBufferSize {
-			d = d[:t.maxNVBufferSize]
-		}
-		if err := t.NVWriteRaw(authContext, nvIndex, d, offset+uint16(total), authContextAuthSession, sessions...); err != nil {
-			return err
-		}
+	// Write all of the chunks in a single transaction so that they aren't interleaved with commands from other goroutines.
+	return t.TransactionContext(t.boundContext(), func(tpm *TPMContext) error {
+		total := 0
+		for {
+			d := data[total:]
+			if len(d) > props.maxNVBufferSize {
+				d = d[:props.maxNVBufferSize]
+			}
+			if err := tpm.NVWriteRaw(authContext, nvIndex, d, offset+uint16(total), authContextAuthSession, sessions...); err != nil {
+				return err
+			}
 
-		total += len(d)
-		if len(data)-total == 0 {
-			break
+			total += len(d)
+			if len(data)-total == 0 {
+				break
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
 // NVSetPinCounterParams is a convenience function for NVWrite for updating the contents of the NV pin pass or NV pin fail index associated
@@ -534,31 +538,40 @@ func (t *TPMContext) NVReadRaw(authContext, nvIndex ResourceContext, size, offse
 //
 // On successful completion, the requested data will be returned.
 func (t *TPMContext) NVRead(authContext, nvIndex ResourceContext, size, offset uint16, authContextAuthSession SessionContext, sessions ...SessionContext) (data []byte, err error) {
-	if err := t.initPropertiesIfNeeded(); err != nil {
+	props, err := t.initPropertiesIfNeeded()
+	if err != nil {
 		return nil, err
 	}
 
 	data = make([]byte, size)
-	total := 0
-	remaining := size
 
-	for {
-		sz := remaining
-		if remaining > uint16(t.maxNVBufferSize) {
-			sz = uint16(t.maxNVBufferSize)
-		}
-		tmpData, err := t.NVReadRaw(authContext, nvIndex, sz, offset+uint16(total), authContextAuthSession, sessions...)
-		if err != nil {
-			return nil, err
-		}
+	// Read all of the chunks in a single transaction so that they aren't interleaved with commands from other goroutines.
+	if err := t.TransactionContext(t.boundContext(), func(tpm *TPMContext) error {
+		total := 0
+		remaining := size
+
+		for {
+			sz := remaining
+			if remaining > uint16(props.maxNVBufferSize) {
+				sz = uint16(props.maxNVBufferSize)
+			}
+			tmpData, err := tpm.NVReadRaw(authContext, nvIndex, sz, offset+uint16(total), authContextAuthSession, sessions...)
+			if err != nil {
+				return err
+			}
 
-		copy(data[total:], tmpData)
-		total += int(sz)
-		remaining -= sz
+			copy(data[total:], tmpData)
+			total += int(sz)
+			remaining -= sz
 
-		if remaining == 0 {
-			break
+			if remaining == 0 {
+				break
+			}
 		}
+
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	return data, nil
@@ -10,37 +10,45 @@ package tpm2
 // random number generator. If the requested bytes cannot be read in a single command, this function will reexecute
 // the TPM2_GetRandom command until all requested bytes have been read.
 func (t *TPMContext) GetRandom(bytesRequested uint16, sessions ...SessionContext) (randomBytes []byte, err error) {
-	if err := t.initPropertiesIfNeeded(); err != nil {
+	props, err := t.initPropertiesIfNeeded()
+	if err != nil {
 		return nil, err
 	}
 
 	randomBytes = make([]byte, bytesRequested)
 
-	total := 0
-	remaining := bytesRequested
-
-	for {
-		sz := remaining
-		if sz > uint16(t.maxDigestSize) {
-			sz = uint16(t.maxDigestSize)
-		}
-
-		var tmpBytes Digest
-		if err := t.RunCommand(CommandGetRandom, sessions,
-			Delimiter,
-			sz, Delimiter,
-			Delimiter,
-			&tmpBytes); err != nil {
-			return nil, err
+	// Read all of the chunks in a single transaction so that they aren't interleaved with commands from other goroutines.
+	if err := t.TransactionContext(t.boundContext(), func(tpm *TPMContext) error {
+		total := 0
+		remaining := bytesRequested
+
+		for {
+			sz := remaining
+			if sz > uint16(props.maxDigestSize) {
+				sz = uint16(props.maxDigestSize)
+			}
+
+			var tmpBytes Digest
+			if err := tpm.RunCommand(CommandGetRandom, sessions,
+				Delimiter,
+				sz, Delimiter,
+				Delimiter,
+				&tmpBytes); err != nil {
+				return err
+			}
+
+			copy(randomBytes[total:], tmpBytes)
+			total += int(sz)
+			remaining -= sz
+
+			if remaining == 0 {
+				break
+			}
 		}
 
-		copy(randomBytes[total:], tmpBytes)
-		total += int(sz)
-		remaining -= sz
-
-		if remaining == 0 {
-			break
-		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	return randomBytes, nil
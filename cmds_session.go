@@ -85,7 +85,7 @@ func (t *TPMContext) StartAuthSession(tpmKey, bind ResourceContext, sessionType
 		tpmKeyHandle = tpmKey.Handle()
 
 		var err error
-		encryptedSalt, salt, err = CryptSecretEncrypt(object.GetPublic(), []byte(SecretKey))
+		encryptedSalt, salt, err = cryptSecretEncrypt(t.randomSource(), object.GetPublic(), []byte(SecretKey))
 		if err != nil {
 			return nil, fmt.Errorf("cannot compute encrypted salt: %v", err)
 		}
@@ -106,7 +106,7 @@ func (t *TPMContext) StartAuthSession(tpmKey, bind ResourceContext, sessionType
 	}
 
 	nonceCaller := make([]byte, digestSize)
-	if err := cryptComputeNonce(nonceCaller); err != nil {
+	if err := cryptComputeNonce(t.randomSource(), nonceCaller); err != nil {
 		return nil, fmt.Errorf("cannot compute initial nonceCaller: %v", err)
 	}
 
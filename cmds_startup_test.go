@@ -17,12 +17,21 @@ type startupSuite struct {
 
 var _ = Suite(&startupSuite{})
 
+func (s *startupSuite) resetTPM(c *C) {
+	switch testutil.TPMBackend {
+	case testutil.TPMBackendSwtpm:
+		c.Check(s.Swtpm(c).Init(0), IsNil)
+	default:
+		c.Check(s.Mssim(c).Reset(), IsNil)
+	}
+}
+
 func (s *startupSuite) runStartupTest(c *C, shutdownType, startupType StartupType) (*TimeInfo, *TimeInfo) {
 	timeBefore, err := s.TPM.ReadClock()
 	c.Assert(err, IsNil)
 
 	c.Check(s.TPM.Shutdown(shutdownType), IsNil)
-	c.Check(s.Mssim(c).Reset(), IsNil)
+	s.resetTPM(c)
 	c.Check(s.TPM.Startup(startupType), IsNil)
 
 	time, err := s.TPM.ReadClock()
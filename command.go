@@ -20,6 +20,13 @@ const (
 	maxResponseSize int = 4096
 )
 
+// responseUnmarshalOptions are the limits applied when unmarshalling responses from the TPM, which
+// shouldn't be trusted to be well formed. The smallest list element in the reference library is 2 bytes.
+var responseUnmarshalOptions = mu.UnmarshalOptions{
+	MaxBytes:       int64(maxResponseSize),
+	MaxListLength:  maxResponseSize / 2,
+	MaxSizedLength: maxResponseSize}
+
 // CommandHeader is the header for a TPM command.
 type CommandHeader struct {
 	Tag         StructTag
@@ -43,7 +50,7 @@ func (p CommandPacket) GetCommandCode() (CommandCode, error) {
 // parameters. The parameters will still be in the TPM wire format. The number of command
 // handles associated with the command must be supplied by the caller.
 func (p CommandPacket) Unmarshal(numHandles int) (handles HandleList, authArea []AuthCommand, parameters []byte, err error) {
-	buf := bytes.NewReader(p)
+	buf := bytes.NewBuffer(p)
 
 	var header CommandHeader
 	if _, err := mu.UnmarshalFromReader(buf, &header); err != nil {
@@ -146,14 +153,14 @@ func (p ResponsePacket) Unmarshal(handle *Handle) (rc ResponseCode, parameters [
 		return 0, nil, nil, fmt.Errorf("packet too large (%d bytes)", len(p))
 	}
 
-	buf := bytes.NewReader(p)
+	buf := bytes.NewBuffer(p)
 
 	var header ResponseHeader
-	if _, err := mu.UnmarshalFromReader(buf, &header); err != nil {
+	if _, err := mu.UnmarshalFromReaderWithOptions(buf, &responseUnmarshalOptions, &header); err != nil {
 		return 0, nil, nil, xerrors.Errorf("cannot unmarshal header: %w", err)
 	}
 
-	if header.ResponseSize != uint32(buf.Size()) {
+	if header.ResponseSize != uint32(len(p)) {
 		return 0, nil, nil, fmt.Errorf("invalid responseSize value (got %d, packet length %d)", header.ResponseSize, len(p))
 	}
 
@@ -173,7 +180,7 @@ func (p ResponsePacket) Unmarshal(handle *Handle) (rc ResponseCode, parameters [
 		fallthrough
 	case TagNoSessions:
 		if header.ResponseCode == ResponseSuccess && handle != nil {
-			if _, err := mu.UnmarshalFromReader(buf, handle); err != nil {
+			if _, err := mu.UnmarshalFromReaderWithOptions(buf, &responseUnmarshalOptions, handle); err != nil {
 				return 0, nil, nil, xerrors.Errorf("cannot unmarshal handle: %w", err)
 			}
 		}
@@ -185,10 +192,14 @@ func (p ResponsePacket) Unmarshal(handle *Handle) (rc ResponseCode, parameters [
 	case TagRspCommand:
 	case TagSessions:
 		var parameterSize uint32
-		if _, err := mu.UnmarshalFromReader(buf, &parameterSize); err != nil {
+		if _, err := mu.UnmarshalFromReaderWithOptions(buf, &responseUnmarshalOptions, &parameterSize); err != nil {
 			return 0, nil, nil, xerrors.Errorf("cannot unmarshal parameterSize: %w", err)
 		}
 
+		if int64(parameterSize) > int64(buf.Len()) {
+			return 0, nil, nil, fmt.Errorf("invalid parameterSize value (got %d, remaining bytes %d)", parameterSize, buf.Len())
+		}
+
 		parameters = make([]byte, parameterSize)
 		if _, err := io.ReadFull(buf, parameters); err != nil {
 			return 0, nil, nil, xerrors.Errorf("cannot read parameters: %w", err)
@@ -200,7 +211,7 @@ func (p ResponsePacket) Unmarshal(handle *Handle) (rc ResponseCode, parameters [
 			}
 
 			var auth AuthResponse
-			if _, err := mu.UnmarshalFromReader(buf, &auth); err != nil {
+			if _, err := mu.UnmarshalFromReaderWithOptions(buf, &responseUnmarshalOptions, &auth); err != nil {
 				return 0, nil, nil, xerrors.Errorf("cannot unmarshal auth: %w", err)
 			}
 
@@ -14,6 +14,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 
 	"github.com/canonical/go-tpm2/internal"
@@ -87,8 +88,8 @@ func cryptComputeRpHash(hashAlg HashAlgorithmId, responseCode ResponseCode, comm
 	return hash.Sum(nil)
 }
 
-func cryptComputeNonce(nonce []byte) error {
-	_, err := rand.Read(nonce)
+func cryptComputeNonce(rand io.Reader, nonce []byte) error {
+	_, err := io.ReadFull(rand, nonce)
 	return err
 }
 
@@ -141,6 +142,10 @@ func zeroExtendBytes(x *big.Int, l int) (out []byte) {
 //
 // It is also used internally by TPMContext.StartAuthSession.
 func CryptSecretEncrypt(public *Public, label []byte) (EncryptedSecret, []byte, error) {
+	return cryptSecretEncrypt(rand.Reader, public, label)
+}
+
+func cryptSecretEncrypt(rand io.Reader, public *Public, label []byte) (EncryptedSecret, []byte, error) {
 	if !public.NameAlg.Available() {
 		return nil, nil, fmt.Errorf("nameAlg %v is not available", public.NameAlg)
 	}
@@ -155,14 +160,14 @@ func CryptSecretEncrypt(public *Public, label []byte) (EncryptedSecret, []byte,
 		pub := public.Public().(*rsa.PublicKey)
 
 		secret := make([]byte, digestSize)
-		if _, err := rand.Read(secret); err != nil {
+		if _, err := io.ReadFull(rand, secret); err != nil {
 			return nil, nil, fmt.Errorf("cannot read random bytes for secret: %v", err)
 		}
 
 		h := public.NameAlg.NewHash()
 		label0 := make([]byte, len(label)+1)
 		copy(label0, label)
-		encryptedSecret, err := rsa.EncryptOAEP(h, rand.Reader, pub, secret, label0)
+		encryptedSecret, err := rsa.EncryptOAEP(h, rand, pub, secret, label0)
 		return encryptedSecret, secret, err
 	case ObjectTypeECC:
 		pub := public.Public().(*ecdsa.PublicKey)
@@ -173,7 +178,7 @@ func CryptSecretEncrypt(public *Public, label []byte) (EncryptedSecret, []byte,
 			return nil, nil, fmt.Errorf("public key is not on curve")
 		}
 
-		ephPriv, ephX, ephY, err := elliptic.GenerateKey(pub.Curve, rand.Reader)
+		ephPriv, ephX, ephY, err := elliptic.GenerateKey(pub.Curve, rand)
 		if err != nil {
 			return nil, nil, fmt.Errorf("cannot generate ephemeral ECC key: %v", err)
 		}
@@ -0,0 +1,459 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/canonical/go-tpm2"
+	. "github.com/canonical/go-tpm2/daemon"
+	"github.com/canonical/go-tpm2/mu"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+// mockTPM is a minimal TPM implementation that supports enough commands to exercise the
+// server's resource management.
+type mockTPM struct {
+	objects  map[tpm2.Handle][]byte
+	sessions map[tpm2.Handle]bool // Indicates whether each session is loaded
+
+	nextObject  tpm2.Handle
+	nextSession tpm2.Handle
+	sequence    uint64
+	locality    uint8
+
+	busy       int32
+	overlapped int32
+
+	mu  sync.Mutex // Only used so that tests can inspect the state without racing
+	rsp *bytes.Reader
+}
+
+func newMockTPM() *mockTPM {
+	return &mockTPM{
+		objects:     make(map[tpm2.Handle][]byte),
+		sessions:    make(map[tpm2.Handle]bool),
+		nextObject:  0x80000000,
+		nextSession: 0x02000000}
+}
+
+var mockCommands = tpm2.CommandAttributesList{
+	tpm2.CommandAttributes(tpm2.CommandContextLoad) | tpm2.AttrRHandle,
+	tpm2.CommandAttributes(tpm2.CommandContextSave) | (1 << 25),
+	tpm2.CommandAttributes(tpm2.CommandFlushContext),
+	tpm2.CommandAttributes(tpm2.CommandReadPublic) | (1 << 25),
+	tpm2.CommandAttributes(tpm2.CommandStartAuthSession) | tpm2.AttrRHandle,
+	tpm2.CommandAttributes(tpm2.CommandGetCapability),
+	tpm2.CommandAttributes(tpm2.CommandLoadExternal) | tpm2.AttrRHandle,
+}
+
+func (t *mockTPM) loadedObjects() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.objects)
+}
+
+func (t *mockTPM) sessionStates() map[tpm2.Handle]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	states := make(map[tpm2.Handle]bool)
+	for h, loaded := range t.sessions {
+		states[h] = loaded
+	}
+	return states
+}
+
+func (t *mockTPM) currentLocality() uint8 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.locality
+}
+
+func makeResponse(rc tpm2.ResponseCode, handle tpm2.Handle, params []byte) []byte {
+	var payload []byte
+	if rc == tpm2.ResponseSuccess {
+		if handle != tpm2.HandleUnassigned {
+			payload = mu.MustMarshalToBytes(handle)
+		}
+		payload = append(payload, params...)
+	}
+	hdr := tpm2.ResponseHeader{Tag: tpm2.TagNoSessions, ResponseSize: uint32(binary.Size(tpm2.ResponseHeader{}) + len(payload)), ResponseCode: rc}
+	return mu.MustMarshalToBytes(hdr, mu.RawBytes(payload))
+}
+
+func handleErrorResponse(command tpm2.CommandCode) []byte {
+	err := &tpm2.TPMHandleError{TPMError: &tpm2.TPMError{Command: command, Code: tpm2.ErrorHandle}, Index: 1}
+	return makeResponse(err.ResponseCode(), tpm2.HandleUnassigned, nil)
+}
+
+func (t *mockTPM) execute(cmd tpm2.CommandPacket) []byte {
+	code, err := cmd.GetCommandCode()
+	if err != nil {
+		return makeResponse(tpm2.ResponseBadTag, tpm2.HandleUnassigned, nil)
+	}
+
+	var numHandles int
+	for _, attrs := range mockCommands {
+		if attrs.CommandCode() == code {
+			numHandles = attrs.NumberOfCommandHandles()
+		}
+	}
+	handles, _, params, err := cmd.Unmarshal(numHandles)
+	if err != nil {
+		return makeResponse(tpm2.ResponseBadTag, tpm2.HandleUnassigned, nil)
+	}
+
+	switch code {
+	case tpm2.CommandGetCapability:
+		data := &tpm2.CapabilityData{Capability: tpm2.CapabilityCommands, Data: &tpm2.CapabilitiesU{Command: mockCommands}}
+		return makeResponse(tpm2.ResponseSuccess, tpm2.HandleUnassigned, mu.MustMarshalToBytes(false, data))
+	case tpm2.CommandLoadExternal:
+		h := t.nextObject
+		t.nextObject++
+		t.objects[h] = params
+		return makeResponse(tpm2.ResponseSuccess, h, nil)
+	case tpm2.CommandStartAuthSession:
+		h := t.nextSession
+		t.nextSession++
+		t.sessions[h] = true
+		return makeResponse(tpm2.ResponseSuccess, h, nil)
+	case tpm2.CommandReadPublic:
+		data, ok := t.objects[handles[0]]
+		if !ok {
+			return handleErrorResponse(code)
+		}
+		return makeResponse(tpm2.ResponseSuccess, tpm2.HandleUnassigned, data)
+	case tpm2.CommandContextSave:
+		t.sequence++
+		context := &tpm2.Context{Sequence: t.sequence, SavedHandle: handles[0], Hierarchy: tpm2.HandleNull}
+		switch handles[0].Type() {
+		case tpm2.HandleTypeTransient:
+			data, ok := t.objects[handles[0]]
+			if !ok {
+				return handleErrorResponse(code)
+			}
+			context.SavedHandle = 0x80000000
+			context.Blob = data
+		default:
+			if !t.sessions[handles[0]] {
+				return handleErrorResponse(code)
+			}
+			t.sessions[handles[0]] = false
+		}
+		return makeResponse(tpm2.ResponseSuccess, tpm2.HandleUnassigned, mu.MustMarshalToBytes(context))
+	case tpm2.CommandContextLoad:
+		var context tpm2.Context
+		if _, err := mu.UnmarshalFromBytes(params, &context); err != nil {
+			return makeResponse(tpm2.ResponseBadTag, tpm2.HandleUnassigned, nil)
+		}
+		if context.SavedHandle.Type() == tpm2.HandleTypeTransient {
+			h := t.nextObject
+			t.nextObject++
+			t.objects[h] = context.Blob
+			return makeResponse(tpm2.ResponseSuccess, h, nil)
+		}
+		t.sessions[context.SavedHandle] = true
+		return makeResponse(tpm2.ResponseSuccess, context.SavedHandle, nil)
+	case tpm2.CommandFlushContext:
+		var h tpm2.Handle
+		mu.UnmarshalFromBytes(params, &h)
+		if _, ok := t.objects[h]; ok {
+			delete(t.objects, h)
+			return makeResponse(tpm2.ResponseSuccess, tpm2.HandleUnassigned, nil)
+		}
+		if _, ok := t.sessions[h]; ok {
+			delete(t.sessions, h)
+			return makeResponse(tpm2.ResponseSuccess, tpm2.HandleUnassigned, nil)
+		}
+		err := &tpm2.TPMParameterError{TPMError: &tpm2.TPMError{Command: code, Code: tpm2.ErrorHandle}, Index: 1}
+		return makeResponse(err.ResponseCode(), tpm2.HandleUnassigned, nil)
+	default:
+		err := &tpm2.TPMError{Command: code, Code: tpm2.ErrorCommandCode}
+		return makeResponse(err.ResponseCode(), tpm2.HandleUnassigned, nil)
+	}
+}
+
+func (t *mockTPM) Read(data []byte) (int, error) {
+	if t.rsp == nil {
+		return 0, io.EOF
+	}
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+		atomic.AddInt32(&t.busy, -1)
+	}
+	return n, err
+}
+
+func (t *mockTPM) Write(data []byte) (int, error) {
+	if atomic.AddInt32(&t.busy, 1) != 1 {
+		atomic.StoreInt32(&t.overlapped, 1)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rsp = bytes.NewReader(t.execute(data))
+	return len(data), nil
+}
+
+func (t *mockTPM) Close() error {
+	return nil
+}
+
+func (t *mockTPM) SetLocality(locality uint8) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.locality = locality
+	return nil
+}
+
+func (t *mockTPM) MakeSticky(handle tpm2.Handle, sticky bool) error {
+	return errors.New("not implemented")
+}
+
+type daemonSuite struct {
+	tpm    *mockTPM
+	server *Server
+	wg     sync.WaitGroup
+}
+
+func (s *daemonSuite) SetUpTest(c *C) {
+	s.tpm = newMockTPM()
+	s.server = NewServer(s.tpm)
+}
+
+func (s *daemonSuite) TearDownTest(c *C) {
+	s.server.Close()
+	s.wg.Wait()
+}
+
+var _ = Suite(&daemonSuite{})
+
+func socketPair(c *C) (net.Conn, net.Conn) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	c.Assert(err, IsNil)
+
+	var conns []net.Conn
+	for _, fd := range fds {
+		f := os.NewFile(uintptr(fd), "")
+		conn, err := net.FileConn(f)
+		c.Assert(err, IsNil)
+		f.Close()
+		conns = append(conns, conn)
+	}
+	return conns[0], conns[1]
+}
+
+// connect returns a new client connected to the server, and a channel that is closed when the
+// server has finished serving the client.
+func (s *daemonSuite) connect(c *C) (*tpm2.TPMContext, chan struct{}) {
+	serverConn, clientConn := socketPair(c)
+
+	done := make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.server.ServeConn(serverConn)
+		close(done)
+	}()
+
+	tpm, err := tpm2.NewTPMContext(NewTCTI(clientConn))
+	c.Assert(err, IsNil)
+	return tpm, done
+}
+
+func runCommand(c *C, tpm *tpm2.TPMContext, command tpm2.CommandCode, handles tpm2.HandleList, params []byte, rspHandle *tpm2.Handle) (tpm2.ResponseCode, []byte) {
+	rsp, err := tpm.RunCommandBytes(tpm2.MarshalCommandPacket(command, handles, nil, params))
+	c.Assert(err, IsNil)
+	rc, rpBytes, _, err := rsp.Unmarshal(rspHandle)
+	c.Assert(err, IsNil)
+	return rc, rpBytes
+}
+
+func loadObject(c *C, tpm *tpm2.TPMContext, data string) tpm2.Handle {
+	var h tpm2.Handle
+	rc, _ := runCommand(c, tpm, tpm2.CommandLoadExternal, nil, []byte(data), &h)
+	c.Assert(rc, Equals, tpm2.ResponseSuccess)
+	return h
+}
+
+func readPublic(c *C, tpm *tpm2.TPMContext, h tpm2.Handle) (tpm2.ResponseCode, string) {
+	rc, data := runCommand(c, tpm, tpm2.CommandReadPublic, tpm2.HandleList{h}, nil, nil)
+	return rc, string(data)
+}
+
+func (s *daemonSuite) TestIsolation(c *C) {
+	tpm1, _ := s.connect(c)
+	tpm2_, _ := s.connect(c)
+
+	h1 := loadObject(c, tpm1, "foo")
+	c.Check(s.tpm.loadedObjects(), Equals, 0)
+	h2 := loadObject(c, tpm2_, "bar")
+	c.Check(s.tpm.loadedObjects(), Equals, 0)
+	c.Check(h1, Equals, h2)
+
+	rc, data := readPublic(c, tpm1, h1)
+	c.Check(rc, Equals, tpm2.ResponseSuccess)
+	c.Check(data, Equals, "foo")
+
+	rc, data = readPublic(c, tpm2_, h2)
+	c.Check(rc, Equals, tpm2.ResponseSuccess)
+	c.Check(data, Equals, "bar")
+
+	c.Check(s.tpm.loadedObjects(), Equals, 0)
+}
+
+func (s *daemonSuite) TestRejectUnownedHandle(c *C) {
+	tpm1, _ := s.connect(c)
+	tpm2_, _ := s.connect(c)
+
+	loadObject(c, tpm1, "foo")
+	h := loadObject(c, tpm1, "bar")
+	loadObject(c, tpm2_, "baz")
+
+	rc, _ := readPublic(c, tpm2_, h)
+	c.Check(tpm2.IsTPMHandleError(tpm2.DecodeResponseCode(tpm2.CommandReadPublic, rc), tpm2.ErrorHandle, tpm2.CommandReadPublic, 1), Equals, true)
+
+	var session tpm2.Handle
+	rc, _ = runCommand(c, tpm1, tpm2.CommandStartAuthSession, nil, nil, &session)
+	c.Assert(rc, Equals, tpm2.ResponseSuccess)
+
+	rc, _ = runCommand(c, tpm2_, tpm2.CommandFlushContext, nil, mu.MustMarshalToBytes(session), nil)
+	c.Check(tpm2.IsTPMParameterError(tpm2.DecodeResponseCode(tpm2.CommandFlushContext, rc), tpm2.ErrorHandle, tpm2.CommandFlushContext, 1), Equals, true)
+	c.Check(s.tpm.sessionStates(), HasLen, 1)
+}
+
+func (s *daemonSuite) TestFlushOnDisconnect(c *C) {
+	tpm, done := s.connect(c)
+
+	loadObject(c, tpm, "foo")
+
+	var session tpm2.Handle
+	rc, _ := runCommand(c, tpm, tpm2.CommandStartAuthSession, nil, nil, &session)
+	c.Assert(rc, Equals, tpm2.ResponseSuccess)
+	c.Check(s.tpm.sessionStates(), DeepEquals, map[tpm2.Handle]bool{session: false})
+
+	rc, _ = runCommand(c, tpm, tpm2.CommandStartAuthSession, nil, nil, &session)
+	c.Assert(rc, Equals, tpm2.ResponseSuccess)
+	rc, _ = runCommand(c, tpm, tpm2.CommandContextSave, tpm2.HandleList{session}, nil, nil)
+	c.Assert(rc, Equals, tpm2.ResponseSuccess)
+	c.Check(s.tpm.sessionStates(), HasLen, 2)
+
+	c.Check(tpm.Close(), IsNil)
+	<-done
+
+	c.Check(s.tpm.loadedObjects(), Equals, 0)
+	c.Check(s.tpm.sessionStates(), HasLen, 0)
+}
+
+func (s *daemonSuite) TestSerializeCommands(c *C) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []string
+
+	for i := 0; i < 4; i++ {
+		tpm, _ := s.connect(c)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				expected := fmt.Sprintf("client%d-%d", i, j)
+
+				var h tpm2.Handle
+				rsp, err := tpm.RunCommandBytes(tpm2.MarshalCommandPacket(tpm2.CommandLoadExternal, nil, nil, []byte(expected)))
+				if err == nil {
+					_, _, _, err = rsp.Unmarshal(&h)
+				}
+				if err == nil {
+					rsp, err = tpm.RunCommandBytes(tpm2.MarshalCommandPacket(tpm2.CommandReadPublic, tpm2.HandleList{h}, nil, nil))
+				}
+				var data []byte
+				if err == nil {
+					_, data, _, err = rsp.Unmarshal(nil)
+				}
+				if err == nil && string(data) != expected {
+					err = fmt.Errorf("unexpected data %q (expected %q)", data, expected)
+				}
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, err.Error())
+					mu.Unlock()
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	c.Check(errs, HasLen, 0)
+	c.Check(atomic.LoadInt32(&s.tpm.overlapped), Equals, int32(0))
+}
+
+func (s *daemonSuite) TestLocality(c *C) {
+	serverConn, clientConn := socketPair(c)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.server.ServeConn(serverConn)
+	}()
+
+	tcti := NewTCTI(clientConn)
+	tpm, err := tpm2.NewTPMContext(tcti)
+	c.Assert(err, IsNil)
+
+	loadObject(c, tpm, "foo")
+	c.Check(s.tpm.currentLocality(), Equals, uint8(0))
+
+	c.Check(tcti.SetLocality(3), IsNil)
+	loadObject(c, tpm, "foo")
+	c.Check(s.tpm.currentLocality(), Equals, uint8(3))
+}
+
+func (s *daemonSuite) TestServerError(c *C) {
+	tpm, _ := s.connect(c)
+
+	_, err := tpm.RunCommandBytes([]byte{0x80, 0x01})
+	c.Check(err, ErrorMatches, "cannot complete write operation on TCTI: server error: cannot send command: cannot decode command code: (.|\n)*")
+	c.Check(err, FitsTypeOf, &tpm2.TctiError{})
+
+	// The connection is still usable.
+	loadObject(c, tpm, "foo")
+}
+
+func (s *daemonSuite) TestListen(c *C) {
+	path := c.MkDir() + "/tpm.sock"
+	l, err := net.Listen("unix", path)
+	c.Assert(err, IsNil)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.server.Serve(l)
+	}()
+
+	tcti, err := Dial(path)
+	c.Assert(err, IsNil)
+	tpm, err := tpm2.NewTPMContext(tcti)
+	c.Assert(err, IsNil)
+
+	h := loadObject(c, tpm, "foo")
+	rc, data := readPublic(c, tpm, h)
+	c.Check(rc, Equals, tpm2.ResponseSuccess)
+	c.Check(data, Equals, "foo")
+	c.Check(tpm.Close(), IsNil)
+}
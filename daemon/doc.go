@@ -0,0 +1,20 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package daemon implements a server that provides shared access to a single TPM for multiple
+clients over a Unix domain socket, along with a matching client TCTI implementation.
+
+Each client connection is served by its own resource manager in isolated mode (see
+tpm2.NewIsolatedResourceManager), so clients cannot access each other's transient objects or
+sessions, and any resources created by a client are flushed when it disconnects. Commands from
+all clients are serialized.
+
+The protocol is a simple framed protocol. A client sends a command as a 1 byte locality (or 0xff
+to use the current locality), a 4 byte big-endian length and the command packet. The server
+responds with a 4 byte big-endian status, a 4 byte big-endian length and a payload. A status of
+zero indicates that the payload is the TPM response packet, and any other status indicates that
+the payload is an error message.
+*/
+package daemon
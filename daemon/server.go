@@ -0,0 +1,220 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package daemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	maxPacketSize = 4096
+
+	// LocalityAny indicates that a command should be executed at the server's current locality.
+	LocalityAny uint8 = 0xff
+
+	statusOK    uint32 = 0
+	statusError uint32 = 1
+)
+
+type commandHeader struct {
+	Locality uint8
+	Size     uint32
+}
+
+type responseHeader struct {
+	Status uint32
+	Size   uint32
+}
+
+// ErrServerClosed is returned from Server.Serve after a call to Server.Close.
+var ErrServerClosed = errors.New("server closed")
+
+// Server provides shared access to a TPM for multiple clients.
+type Server struct {
+	tcti tpm2.TCTI
+
+	tpmMu       sync.Mutex // Serializes access to tcti
+	locality    uint8
+	localitySet bool
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+// NewServer returns a new Server for the supplied TCTI. The server takes ownership of tcti, which
+// is closed by Server.Close.
+func NewServer(tcti tpm2.TCTI) *Server {
+	return &Server{
+		tcti:      tcti,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{})}
+}
+
+// Listen creates a Unix domain socket listener at the specified path and serves clients on it.
+// This blocks until an error occurs or the server is closed.
+func (s *Server) Listen(path string) error {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return xerrors.Errorf("cannot listen on socket: %w", err)
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the supplied listener, serving each one in a new goroutine. This
+// blocks until an error occurs or the server is closed, in which case ErrServerClosed is returned.
+// The listener is closed on return.
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return xerrors.Errorf("cannot accept connection: %w", err)
+		}
+
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves a single client on the supplied connection. This blocks until the client
+// disconnects or the server is closed. Any resources created by the client are flushed and the
+// connection is closed on return.
+func (s *Server) ServeConn(conn net.Conn) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	rm := tpm2.NewIsolatedResourceManager(s.tcti)
+
+	defer func() {
+		s.tpmMu.Lock()
+		rm.Close()
+		s.tpmMu.Unlock()
+
+		conn.Close()
+
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	for {
+		var hdr commandHeader
+		if err := binary.Read(conn, binary.BigEndian, &hdr); err != nil {
+			return
+		}
+		if hdr.Size > maxPacketSize {
+			s.writeResponse(conn, nil, fmt.Errorf("command too large (%d bytes)", hdr.Size))
+			return
+		}
+
+		cmd := make([]byte, hdr.Size)
+		if _, err := io.ReadFull(conn, cmd); err != nil {
+			return
+		}
+
+		rsp, err := s.runCommand(rm, hdr.Locality, cmd)
+		if err := s.writeResponse(conn, rsp, err); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) runCommand(rm *tpm2.TctiResourceManager, locality uint8, cmd []byte) ([]byte, error) {
+	s.tpmMu.Lock()
+	defer s.tpmMu.Unlock()
+
+	if locality != LocalityAny && (!s.localitySet || locality != s.locality) {
+		if err := s.tcti.SetLocality(locality); err != nil {
+			return nil, xerrors.Errorf("cannot set locality: %w", err)
+		}
+		s.locality = locality
+		s.localitySet = true
+	}
+
+	if _, err := rm.Write(cmd); err != nil {
+		return nil, xerrors.Errorf("cannot send command: %w", err)
+	}
+	rsp, err := ioutil.ReadAll(rm)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot receive response: %w", err)
+	}
+	return rsp, nil
+}
+
+func (s *Server) writeResponse(w io.Writer, rsp []byte, err error) error {
+	hdr := responseHeader{Status: statusOK}
+	payload := rsp
+	if err != nil {
+		hdr.Status = statusError
+		payload = []byte(err.Error())
+	}
+	hdr.Size = uint32(len(payload))
+
+	_, err = w.Write(mu.MustMarshalToBytes(hdr, mu.RawBytes(payload)))
+	return err
+}
+
+// Close closes all listeners and client connections, waits for the client resources to be
+// flushed, and then closes the underlying TCTI.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return s.tcti.Close()
+}
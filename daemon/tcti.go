@@ -0,0 +1,110 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package daemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+
+	"golang.org/x/xerrors"
+)
+
+// ServerError is returned from TCTI.Write if the server failed to execute a command.
+type ServerError struct {
+	msg string
+}
+
+func (e *ServerError) Error() string {
+	return "server error: " + e.msg
+}
+
+// TCTI is a tpm2.TCTI implementation for communicating with a Server.
+type TCTI struct {
+	conn     net.Conn
+	locality uint8
+	rsp      *bytes.Reader
+}
+
+// NewTCTI returns a new TCTI for the supplied connection to a Server. The returned TCTI takes
+// ownership of conn, and can be passed to tpm2.NewTPMContext.
+func NewTCTI(conn net.Conn) *TCTI {
+	return &TCTI{conn: conn, locality: LocalityAny}
+}
+
+// Dial connects to a Server listening on the Unix domain socket at the specified path.
+func Dial(path string) (*TCTI, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot connect to socket: %w", err)
+	}
+	return NewTCTI(conn), nil
+}
+
+func (t *TCTI) Read(data []byte) (int, error) {
+	if t.rsp == nil {
+		return 0, io.EOF
+	}
+
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *TCTI) Write(data []byte) (int, error) {
+	if t.rsp != nil {
+		return 0, errors.New("previous response has not been read")
+	}
+	if len(data) > maxPacketSize {
+		return 0, fmt.Errorf("command too large (%d bytes)", len(data))
+	}
+
+	if _, err := t.conn.Write(mu.MustMarshalToBytes(commandHeader{Locality: t.locality, Size: uint32(len(data))}, mu.RawBytes(data))); err != nil {
+		return 0, xerrors.Errorf("cannot send command: %w", err)
+	}
+
+	var hdr responseHeader
+	if err := binary.Read(t.conn, binary.BigEndian, &hdr); err != nil {
+		return 0, xerrors.Errorf("cannot read response header: %w", err)
+	}
+	if hdr.Size > maxPacketSize {
+		return 0, fmt.Errorf("response too large (%d bytes)", hdr.Size)
+	}
+
+	payload := make([]byte, hdr.Size)
+	if _, err := io.ReadFull(t.conn, payload); err != nil {
+		return 0, xerrors.Errorf("cannot read response: %w", err)
+	}
+
+	if hdr.Status != statusOK {
+		return 0, &ServerError{string(payload)}
+	}
+
+	t.rsp = bytes.NewReader(payload)
+	return len(data), nil
+}
+
+func (t *TCTI) Close() error {
+	return t.conn.Close()
+}
+
+// SetLocality sets the locality that will be used for subsequent commands. The server will
+// switch the locality of the underlying TCTI before executing these commands.
+func (t *TCTI) SetLocality(locality uint8) error {
+	t.locality = locality
+	return nil
+}
+
+func (t *TCTI) MakeSticky(handle tpm2.Handle, sticky bool) error {
+	return errors.New("not implemented")
+}
@@ -0,0 +1,574 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package decode
+
+import (
+	"reflect"
+
+	"github.com/canonical/go-tpm2"
+)
+
+type commandParam struct {
+	name  string
+	typ   reflect.Type
+	sized bool
+}
+
+// param returns a parameter with the specified name and the type that val
+// points to.
+func param(name string, val interface{}) commandParam {
+	return commandParam{name: name, typ: reflect.TypeOf(val).Elem()}
+}
+
+// sizedParam returns a sized structure parameter with the specified name and
+// the type that val points to, which must be a pointer to a struct.
+func sizedParam(name string, val interface{}) commandParam {
+	return commandParam{name: name, typ: reflect.TypeOf(val).Elem(), sized: true}
+}
+
+type commandInfo struct {
+	handles   []string // The names of the command handles
+	params    []commandParam
+	rspHandle string // The name of the response handle, if there is one
+	rspParams []commandParam
+}
+
+// commands describes the handles and parameters of each command supported by the
+// tpm2 package.
+var commands = map[tpm2.CommandCode]commandInfo{
+	tpm2.CommandActivateCredential: {
+		handles: []string{"activateContext", "keyContext"},
+		params: []commandParam{
+			param("credentialBlob", new(tpm2.IDObjectRaw)),
+			param("secret", new(tpm2.EncryptedSecret))},
+		rspParams: []commandParam{
+			param("certInfo", new(tpm2.Digest))},
+	},
+	tpm2.CommandCertify: {
+		handles: []string{"objectContext", "signContext"},
+		params: []commandParam{
+			param("qualifyingData", new(tpm2.Data)),
+			param("inScheme", new(*tpm2.SigScheme))},
+		rspParams: []commandParam{
+			sizedParam("certifyInfo", new(*tpm2.Attest)),
+			param("signature", new(*tpm2.Signature))},
+	},
+	tpm2.CommandCertifyCreation: {
+		handles: []string{"signContext", "objectContext"},
+		params: []commandParam{
+			param("qualifyingData", new(tpm2.Data)),
+			param("creationHash", new(tpm2.Digest)),
+			param("inScheme", new(*tpm2.SigScheme)),
+			param("creationTicket", new(*tpm2.TkCreation))},
+		rspParams: []commandParam{
+			sizedParam("certifyInfo", new(*tpm2.Attest)),
+			param("signature", new(*tpm2.Signature))},
+	},
+	tpm2.CommandClear: {
+		handles: []string{"authContext"},
+	},
+	tpm2.CommandClearControl: {
+		handles: []string{"authContext"},
+		params: []commandParam{
+			param("disable", new(bool))},
+	},
+	tpm2.CommandContextLoad: {
+		params: []commandParam{
+			param("context", new(tpm2.Context))},
+		rspHandle: "loadedHandle",
+	},
+	tpm2.CommandContextSave: {
+		handles: []string{"saveContext"},
+		rspParams: []commandParam{
+			param("context", new(*tpm2.Context))},
+	},
+	tpm2.CommandCreate: {
+		handles: []string{"parentContext"},
+		params: []commandParam{
+			sizedParam("inSensitive", new(*tpm2.SensitiveCreate)),
+			sizedParam("inPublic", new(*tpm2.Public)),
+			param("outsideInfo", new(tpm2.Data)),
+			param("creationPCR", new(tpm2.PCRSelectionList))},
+		rspParams: []commandParam{
+			param("outPrivate", new(tpm2.Private)),
+			sizedParam("outPublic", new(*tpm2.Public)),
+			sizedParam("creationData", new(*tpm2.CreationData)),
+			param("creationHash", new(tpm2.Digest)),
+			param("creationTicket", new(*tpm2.TkCreation))},
+	},
+	tpm2.CommandCreateLoaded: {
+		handles: []string{"parentContext"},
+		params: []commandParam{
+			sizedParam("inSensitive", new(*tpm2.SensitiveCreate)),
+			param("inTemplate", new(tpm2.Template))},
+		rspHandle: "objectHandle",
+		rspParams: []commandParam{
+			param("outPrivate", new(tpm2.Private)),
+			sizedParam("outPublic", new(*tpm2.Public)),
+			param("name", new(tpm2.Name))},
+	},
+	tpm2.CommandCreatePrimary: {
+		handles: []string{"primaryObject"},
+		params: []commandParam{
+			sizedParam("inSensitive", new(*tpm2.SensitiveCreate)),
+			sizedParam("inPublic", new(*tpm2.Public)),
+			param("outsideInfo", new(tpm2.Data)),
+			param("creationPCR", new(tpm2.PCRSelectionList))},
+		rspHandle: "objectHandle",
+		rspParams: []commandParam{
+			sizedParam("outPublic", new(*tpm2.Public)),
+			sizedParam("creationData", new(*tpm2.CreationData)),
+			param("creationHash", new(tpm2.Digest)),
+			param("creationTicket", new(*tpm2.TkCreation)),
+			param("name", new(tpm2.Name))},
+	},
+	tpm2.CommandDictionaryAttackLockReset: {
+		handles: []string{"lockContext"},
+	},
+	tpm2.CommandDictionaryAttackParameters: {
+		handles: []string{"lockContext"},
+		params: []commandParam{
+			param("newMaxTries", new(uint32)),
+			param("newRecoveryTime", new(uint32)),
+			param("lockoutRecovery", new(uint32))},
+	},
+	tpm2.CommandDuplicate: {
+		handles: []string{"objectContext", "newParentContext"},
+		params: []commandParam{
+			param("encryptionKeyIn", new(tpm2.Data)),
+			param("symmetricAlg", new(*tpm2.SymDefObject))},
+		rspParams: []commandParam{
+			param("encryptionKeyOut", new(tpm2.Data)),
+			param("duplicate", new(tpm2.Private)),
+			param("outSymSeed", new(tpm2.EncryptedSecret))},
+	},
+	tpm2.CommandEventSequenceComplete: {
+		handles: []string{"pcrContext", "sequenceContext"},
+		params: []commandParam{
+			param("buffer", new(tpm2.MaxBuffer))},
+		rspParams: []commandParam{
+			param("results", new(tpm2.TaggedHashList))},
+	},
+	tpm2.CommandEvictControl: {
+		handles: []string{"auth", "objectHandle"},
+		params: []commandParam{
+			param("persistentHandle", new(tpm2.Handle))},
+	},
+	tpm2.CommandFlushContext: {
+		params: []commandParam{
+			param("flushHandle", new(tpm2.Handle))},
+	},
+	tpm2.CommandGetCapability: {
+		params: []commandParam{
+			param("capability", new(tpm2.Capability)),
+			param("nextProperty", new(uint32)),
+			param("remaining", new(uint32))},
+		rspParams: []commandParam{
+			param("moreData", new(bool)),
+			param("data", new(tpm2.CapabilityData))},
+	},
+	tpm2.CommandGetCommandAuditDigest: {
+		handles: []string{"privacyContext", "signContext"},
+		params: []commandParam{
+			param("qualifyingData", new(tpm2.Data)),
+			param("inScheme", new(*tpm2.SigScheme))},
+		rspParams: []commandParam{
+			sizedParam("auditInfo", new(*tpm2.Attest)),
+			param("signature", new(*tpm2.Signature))},
+	},
+	tpm2.CommandGetRandom: {
+		params: []commandParam{
+			param("bytesRequested", new(uint16))},
+		rspParams: []commandParam{
+			param("randomBytes", new(tpm2.Digest))},
+	},
+	tpm2.CommandGetSessionAuditDigest: {
+		handles: []string{"privacyAdminContext", "signContext", "sessionContext"},
+		params: []commandParam{
+			param("qualifyingData", new(tpm2.Data)),
+			param("inScheme", new(*tpm2.SigScheme))},
+		rspParams: []commandParam{
+			sizedParam("auditInfo", new(*tpm2.Attest)),
+			param("signature", new(*tpm2.Signature))},
+	},
+	tpm2.CommandGetTestResult: {
+		rspParams: []commandParam{
+			param("outData", new(tpm2.MaxBuffer)),
+			param("testResult", new(tpm2.ResponseCode))},
+	},
+	tpm2.CommandGetTime: {
+		handles: []string{"privacyAdminContext", "signContext"},
+		params: []commandParam{
+			param("qualifyingData", new(tpm2.Data)),
+			param("inScheme", new(*tpm2.SigScheme))},
+		rspParams: []commandParam{
+			sizedParam("timeInfo", new(*tpm2.Attest)),
+			param("signature", new(*tpm2.Signature))},
+	},
+	tpm2.CommandHMACStart: {
+		handles: []string{"context"},
+		params: []commandParam{
+			param("auth", new(tpm2.Auth)),
+			param("hashAlg", new(tpm2.HashAlgorithmId))},
+		rspHandle: "sequenceHandle",
+	},
+	tpm2.CommandHashSequenceStart: {
+		params: []commandParam{
+			param("auth", new(tpm2.Auth)),
+			param("hashAlg", new(tpm2.HashAlgorithmId))},
+		rspHandle: "sequenceHandle",
+	},
+	tpm2.CommandHierarchyChangeAuth: {
+		handles: []string{"authContext"},
+		params: []commandParam{
+			param("newAuth", new(tpm2.Auth))},
+	},
+	tpm2.CommandHierarchyControl: {
+		handles: []string{"authContext"},
+		params: []commandParam{
+			param("enable", new(tpm2.Handle)),
+			param("state", new(bool))},
+	},
+	tpm2.CommandImport: {
+		handles: []string{"parentContext"},
+		params: []commandParam{
+			param("encryptionKey", new(tpm2.Data)),
+			sizedParam("objectPublic", new(*tpm2.Public)),
+			param("duplicate", new(tpm2.Private)),
+			param("inSymSeed", new(tpm2.EncryptedSecret)),
+			param("symmetricAlg", new(*tpm2.SymDefObject))},
+		rspParams: []commandParam{
+			param("outPrivate", new(tpm2.Private))},
+	},
+	tpm2.CommandIncrementalSelfTest: {
+		params: []commandParam{
+			param("toTest", new(tpm2.AlgorithmList))},
+		rspParams: []commandParam{
+			param("toDoList", new(tpm2.AlgorithmList))},
+	},
+	tpm2.CommandLoad: {
+		handles: []string{"parentContext"},
+		params: []commandParam{
+			param("inPrivate", new(tpm2.Private)),
+			sizedParam("inPublic", new(*tpm2.Public))},
+		rspHandle: "objectHandle",
+		rspParams: []commandParam{
+			param("name", new(tpm2.Name))},
+	},
+	tpm2.CommandLoadExternal: {
+		params: []commandParam{
+			sizedParam("inPrivate", new(*tpm2.Sensitive)),
+			sizedParam("inPublic", new(*tpm2.Public)),
+			param("hierarchy", new(tpm2.Handle))},
+		rspHandle: "objectHandle",
+		rspParams: []commandParam{
+			param("name", new(tpm2.Name))},
+	},
+	tpm2.CommandMakeCredential: {
+		handles: []string{"context"},
+		params: []commandParam{
+			param("credential", new(tpm2.Digest)),
+			param("objectName", new(tpm2.Name))},
+		rspParams: []commandParam{
+			param("credentialBlob", new(tpm2.IDObjectRaw)),
+			param("secret", new(tpm2.EncryptedSecret))},
+	},
+	tpm2.CommandNVChangeAuth: {
+		handles: []string{"nvIndex"},
+		params: []commandParam{
+			param("newAuth", new(tpm2.Auth))},
+	},
+	tpm2.CommandNVDefineSpace: {
+		handles: []string{"authContext"},
+		params: []commandParam{
+			param("auth", new(tpm2.Auth)),
+			sizedParam("publicInfo", new(*tpm2.NVPublic))},
+	},
+	tpm2.CommandNVExtend: {
+		handles: []string{"authContext", "nvIndex"},
+		params: []commandParam{
+			param("data", new(tpm2.MaxNVBuffer))},
+	},
+	tpm2.CommandNVGlobalWriteLock: {
+		handles: []string{"authContext"},
+	},
+	tpm2.CommandNVIncrement: {
+		handles: []string{"authContext", "nvIndex"},
+	},
+	tpm2.CommandNVRead: {
+		handles: []string{"authContext", "nvIndex"},
+		params: []commandParam{
+			param("size", new(uint16)),
+			param("offset", new(uint16))},
+		rspParams: []commandParam{
+			param("data", new(tpm2.MaxNVBuffer))},
+	},
+	tpm2.CommandNVReadLock: {
+		handles: []string{"authContext", "nvIndex"},
+	},
+	tpm2.CommandNVReadPublic: {
+		handles: []string{"nvIndex"},
+		rspParams: []commandParam{
+			sizedParam("nvPublic", new(*tpm2.NVPublic)),
+			param("nvName", new(tpm2.Name))},
+	},
+	tpm2.CommandNVSetBits: {
+		handles: []string{"authContext", "nvIndex"},
+		params: []commandParam{
+			param("bits", new(uint64))},
+	},
+	tpm2.CommandNVUndefineSpace: {
+		handles: []string{"authContext", "nvIndex"},
+	},
+	tpm2.CommandNVUndefineSpaceSpecial: {
+		handles: []string{"nvIndex", "platform"},
+	},
+	tpm2.CommandNVWrite: {
+		handles: []string{"authContext", "nvIndex"},
+		params: []commandParam{
+			param("data", new(tpm2.MaxNVBuffer)),
+			param("offset", new(uint16))},
+	},
+	tpm2.CommandNVWriteLock: {
+		handles: []string{"authContext", "nvIndex"},
+	},
+	tpm2.CommandObjectChangeAuth: {
+		handles: []string{"objectContext", "parentContext"},
+		params: []commandParam{
+			param("newAuth", new(tpm2.Auth))},
+		rspParams: []commandParam{
+			param("outPrivate", new(tpm2.Private))},
+	},
+	tpm2.CommandPCREvent: {
+		handles: []string{"pcrContext"},
+		params: []commandParam{
+			param("eventData", new(tpm2.Event))},
+		rspParams: []commandParam{
+			param("digests", new(tpm2.TaggedHashList))},
+	},
+	tpm2.CommandPCRExtend: {
+		handles: []string{"pcrContext"},
+		params: []commandParam{
+			param("digests", new(tpm2.TaggedHashList))},
+	},
+	tpm2.CommandPCRRead: {
+		params: []commandParam{
+			param("pcrSelectionIn", new(tpm2.PCRSelectionList))},
+		rspParams: []commandParam{
+			param("updateCounter", new(uint32)),
+			param("pcrSelectionOut", new(tpm2.PCRSelectionList)),
+			param("values", new(tpm2.DigestList))},
+	},
+	tpm2.CommandPCRReset: {
+		handles: []string{"pcrContext"},
+	},
+	tpm2.CommandPolicyAuthValue: {
+		handles: []string{"policySession"},
+	},
+	tpm2.CommandPolicyAuthorize: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("approvedPolicy", new(tpm2.Digest)),
+			param("policyRef", new(tpm2.Nonce)),
+			param("keySign", new(tpm2.Name)),
+			param("checkTicket", new(*tpm2.TkVerified))},
+	},
+	tpm2.CommandPolicyCommandCode: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("code", new(tpm2.CommandCode))},
+	},
+	tpm2.CommandPolicyCounterTimer: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("operandB", new(tpm2.Operand)),
+			param("offset", new(uint16)),
+			param("operation", new(tpm2.ArithmeticOp))},
+	},
+	tpm2.CommandPolicyCpHash: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("cpHashA", new(tpm2.Digest))},
+	},
+	tpm2.CommandPolicyDuplicationSelect: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("objectName", new(tpm2.Name)),
+			param("newParentName", new(tpm2.Name)),
+			param("includeObject", new(bool))},
+	},
+	tpm2.CommandPolicyGetDigest: {
+		handles: []string{"policySession"},
+		rspParams: []commandParam{
+			param("policyDigest", new(tpm2.Digest))},
+	},
+	tpm2.CommandPolicyLocality: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("locality", new(tpm2.Locality))},
+	},
+	tpm2.CommandPolicyNV: {
+		handles: []string{"authContext", "nvIndex", "policySession"},
+		params: []commandParam{
+			param("operandB", new(tpm2.Operand)),
+			param("offset", new(uint16)),
+			param("operation", new(tpm2.ArithmeticOp))},
+	},
+	tpm2.CommandPolicyNameHash: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("nameHash", new(tpm2.Digest))},
+	},
+	tpm2.CommandPolicyNvWritten: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("writtenSet", new(bool))},
+	},
+	tpm2.CommandPolicyOR: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("pHashList", new(tpm2.DigestList))},
+	},
+	tpm2.CommandPolicyPCR: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("pcrDigest", new(tpm2.Digest)),
+			param("pcrs", new(tpm2.PCRSelectionList))},
+	},
+	tpm2.CommandPolicyPassword: {
+		handles: []string{"policySession"},
+	},
+	tpm2.CommandPolicyRestart: {
+		handles: []string{"sessionContext"},
+	},
+	tpm2.CommandPolicySecret: {
+		handles: []string{"authContext", "policySession"},
+		params: []commandParam{
+			param("nonceTPM", new(tpm2.Nonce)),
+			param("cpHashA", new(tpm2.Digest)),
+			param("policyRef", new(tpm2.Nonce)),
+			param("expiration", new(int32))},
+		rspParams: []commandParam{
+			param("timeout", new(tpm2.Timeout)),
+			param("policyTicket", new(*tpm2.TkAuth))},
+	},
+	tpm2.CommandPolicySigned: {
+		handles: []string{"authContext", "policySession"},
+		params: []commandParam{
+			param("nonceTPM", new(tpm2.Nonce)),
+			param("cpHashA", new(tpm2.Digest)),
+			param("policyRef", new(tpm2.Nonce)),
+			param("expiration", new(int32)),
+			param("auth", new(*tpm2.Signature))},
+		rspParams: []commandParam{
+			param("timeout", new(tpm2.Timeout)),
+			param("policyTicket", new(*tpm2.TkAuth))},
+	},
+	tpm2.CommandPolicyTicket: {
+		handles: []string{"policySession"},
+		params: []commandParam{
+			param("timeout", new(tpm2.Timeout)),
+			param("cpHashA", new(tpm2.Digest)),
+			param("policyRef", new(tpm2.Nonce)),
+			param("authName", new(tpm2.Name)),
+			param("ticket", new(*tpm2.TkAuth))},
+	},
+	tpm2.CommandQuote: {
+		handles: []string{"signContext"},
+		params: []commandParam{
+			param("qualifyingData", new(tpm2.Data)),
+			param("inScheme", new(*tpm2.SigScheme)),
+			param("pcrs", new(tpm2.PCRSelectionList))},
+		rspParams: []commandParam{
+			sizedParam("quoted", new(*tpm2.Attest)),
+			param("signature", new(*tpm2.Signature))},
+	},
+	tpm2.CommandReadClock: {
+		rspParams: []commandParam{
+			param("currentTime", new(*tpm2.TimeInfo))},
+	},
+	tpm2.CommandReadPublic: {
+		handles: []string{"objectContext"},
+		rspParams: []commandParam{
+			sizedParam("outPublic", new(*tpm2.Public)),
+			param("name", new(tpm2.Name)),
+			param("qualifiedName", new(tpm2.Name))},
+	},
+	tpm2.CommandSelfTest: {
+		params: []commandParam{
+			param("fullTest", new(bool))},
+	},
+	tpm2.CommandSequenceComplete: {
+		handles: []string{"sequenceContext"},
+		params: []commandParam{
+			param("buffer", new(tpm2.MaxBuffer)),
+			param("hierarchy", new(tpm2.Handle))},
+		rspParams: []commandParam{
+			param("result", new(tpm2.Digest)),
+			param("validation", new(*tpm2.TkHashcheck))},
+	},
+	tpm2.CommandSequenceUpdate: {
+		handles: []string{"sequenceContext"},
+		params: []commandParam{
+			param("buffer", new(tpm2.MaxBuffer))},
+	},
+	tpm2.CommandSetCommandCodeAuditStatus: {
+		handles: []string{"auth"},
+		params: []commandParam{
+			param("auditAlg", new(tpm2.HashAlgorithmId)),
+			param("setList", new(tpm2.CommandCodeList)),
+			param("clearList", new(tpm2.CommandCodeList))},
+	},
+	tpm2.CommandShutdown: {
+		params: []commandParam{
+			param("shutdownType", new(tpm2.StartupType))},
+	},
+	tpm2.CommandSign: {
+		handles: []string{"keyContext"},
+		params: []commandParam{
+			param("digest", new(tpm2.Digest)),
+			param("inScheme", new(*tpm2.SigScheme)),
+			param("validation", new(*tpm2.TkHashcheck))},
+		rspParams: []commandParam{
+			param("signature", new(*tpm2.Signature))},
+	},
+	tpm2.CommandStartAuthSession: {
+		handles: []string{"tpmKey", "bind"},
+		params: []commandParam{
+			param("nonceCaller", new(tpm2.Nonce)),
+			param("encryptedSalt", new(tpm2.EncryptedSecret)),
+			param("sessionType", new(tpm2.SessionType)),
+			param("symmetric", new(*tpm2.SymDef)),
+			param("authHash", new(tpm2.HashAlgorithmId))},
+		rspHandle: "sessionHandle",
+		rspParams: []commandParam{
+			param("nonceTPM", new(tpm2.Nonce))},
+	},
+	tpm2.CommandStartup: {
+		params: []commandParam{
+			param("startupType", new(tpm2.StartupType))},
+	},
+	tpm2.CommandStirRandom: {
+		params: []commandParam{
+			param("inData", new(tpm2.SensitiveData))},
+	},
+	tpm2.CommandTestParms: {
+		params: []commandParam{
+			param("parameters", new(*tpm2.PublicParams))},
+	},
+	tpm2.CommandUnseal: {
+		handles: []string{"itemContext"},
+		rspParams: []commandParam{
+			param("outData", new(tpm2.SensitiveData))},
+	},
+	tpm2.CommandVerifySignature: {
+		handles: []string{"keyContext"},
+		params: []commandParam{
+			param("digest", new(tpm2.Digest)),
+			param("signature", new(*tpm2.Signature))},
+		rspParams: []commandParam{
+			param("validation", new(*tpm2.TkVerified))},
+	},
+}
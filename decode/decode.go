@@ -0,0 +1,253 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package decode implements a decoder for TPM command and response packets, for debugging.
+
+Decoded packets contain the handles, authorization areas and parameters of the corresponding
+command or response, with parameters decoded in to the go types used by the tpm2 package. They can
+be rendered as human-readable text with the String method, or as JSON with encoding/json.
+*/
+package decode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+
+	"golang.org/x/xerrors"
+)
+
+// Handle corresponds to a decoded command or response handle.
+type Handle struct {
+	Name   string      // The name of the handle, if known
+	Handle tpm2.Handle // The handle value
+}
+
+// CommandSession corresponds to a decoded command authorization.
+type CommandSession struct {
+	Handle     tpm2.Handle
+	Nonce      tpm2.Nonce
+	Attributes tpm2.SessionAttributes
+	HMAC       tpm2.Auth
+}
+
+// ResponseSession corresponds to a decoded response authorization.
+type ResponseSession struct {
+	Nonce      tpm2.Nonce
+	Attributes tpm2.SessionAttributes
+	HMAC       tpm2.Auth
+}
+
+// Parameter corresponds to a decoded command or response parameter.
+type Parameter struct {
+	Name string
+
+	// Encrypted indicates that this parameter is encrypted. In this case, Value is the
+	// encrypted contents of the parameter as a byte slice.
+	Encrypted bool
+
+	Value interface{}
+}
+
+// Command corresponds to a decoded command packet.
+type Command struct {
+	Tag         tpm2.StructTag
+	CommandCode tpm2.CommandCode
+	Handles     []Handle
+	Sessions    []CommandSession
+	Parameters  []Parameter
+
+	// RawParameters contains the handle, authorization and parameter areas if the
+	// command is not known by the decoder.
+	RawParameters []byte
+}
+
+// Response corresponds to a decoded response packet.
+type Response struct {
+	Tag          tpm2.StructTag
+	CommandCode  tpm2.CommandCode
+	ResponseCode tpm2.ResponseCode
+	Handle       *Handle
+	Parameters   []Parameter
+	Sessions     []ResponseSession
+
+	// RawParameters contains the parameter area if it could not be decoded, which
+	// is the case for commands that the decoder doesn't know about.
+	RawParameters []byte
+}
+
+func decodeParameters(params []commandParam, data []byte, encrypted bool) ([]Parameter, error) {
+	r := bytes.NewReader(data)
+
+	var out []Parameter
+	for i, p := range params {
+		if i == 0 && encrypted {
+			// Only the contents of the first parameter are encrypted, and its size field
+			// isn't.
+			var value []byte
+			if _, err := mu.UnmarshalFromReader(r, &value); err != nil {
+				return nil, xerrors.Errorf("cannot decode encrypted parameter %s: %w", p.name, err)
+			}
+			out = append(out, Parameter{Name: p.name, Encrypted: true, Value: value})
+			continue
+		}
+
+		v := reflect.New(p.typ)
+		arg := v.Interface()
+		if p.sized {
+			arg = mu.Sized(arg)
+		}
+		if _, err := mu.UnmarshalFromReader(r, arg); err != nil {
+			return nil, xerrors.Errorf("cannot decode parameter %s: %w", p.name, err)
+		}
+		out = append(out, Parameter{Name: p.name, Value: v.Elem().Interface()})
+	}
+
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d trailing byte(s) in parameter area", r.Len())
+	}
+
+	return out, nil
+}
+
+// DecodeCommand decodes the supplied command packet. If the command is not known by
+// the decoder, only the header is decoded and the rest of the packet is returned in
+// Command.RawParameters.
+func DecodeCommand(packet tpm2.CommandPacket) (*Command, error) {
+	var hdr tpm2.CommandHeader
+	if _, err := mu.UnmarshalFromBytes(packet, &hdr); err != nil {
+		return nil, xerrors.Errorf("cannot decode header: %w", err)
+	}
+
+	cmd := &Command{Tag: hdr.Tag, CommandCode: hdr.CommandCode}
+
+	info, known := commands[hdr.CommandCode]
+	if !known {
+		cmd.RawParameters = packet[binary.Size(hdr):]
+		return cmd, nil
+	}
+
+	handles, authArea, params, err := packet.Unmarshal(len(info.handles))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode packet: %w", err)
+	}
+
+	for i, h := range handles {
+		cmd.Handles = append(cmd.Handles, Handle{Name: info.handles[i], Handle: h})
+	}
+
+	encrypted := false
+	for _, auth := range authArea {
+		cmd.Sessions = append(cmd.Sessions, CommandSession{
+			Handle:     auth.SessionHandle,
+			Nonce:      auth.Nonce,
+			Attributes: auth.SessionAttributes,
+			HMAC:       auth.HMAC})
+		if auth.SessionAttributes&tpm2.AttrCommandEncrypt != 0 {
+			encrypted = true
+		}
+	}
+
+	cmd.Parameters, err = decodeParameters(info.params, params, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
+
+// DecodeResponse decodes the supplied response packet for a command with the specified
+// command code. If the command is not known by the decoder, the parameters are not
+// decoded and are returned in Response.RawParameters. In this case, it is assumed that
+// there is no response handle.
+//
+// The attributes of the command sessions must be supplied in order to determine whether
+// the first response parameter is encrypted.
+func DecodeResponse(commandCode tpm2.CommandCode, sessionAttrs []tpm2.SessionAttributes, packet tpm2.ResponsePacket) (*Response, error) {
+	var hdr tpm2.ResponseHeader
+	if _, err := mu.UnmarshalFromBytes(packet, &hdr); err != nil {
+		return nil, xerrors.Errorf("cannot decode header: %w", err)
+	}
+
+	rsp := &Response{Tag: hdr.Tag, CommandCode: commandCode, ResponseCode: hdr.ResponseCode}
+
+	info, known := commands[commandCode]
+
+	var handle tpm2.Handle
+	var pHandle *tpm2.Handle
+	if info.rspHandle != "" {
+		pHandle = &handle
+	}
+
+	_, params, authArea, err := packet.Unmarshal(pHandle)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode packet: %w", err)
+	}
+	if hdr.ResponseCode != tpm2.ResponseSuccess {
+		return rsp, nil
+	}
+
+	if pHandle != nil {
+		rsp.Handle = &Handle{Name: info.rspHandle, Handle: handle}
+	}
+
+	for _, auth := range authArea {
+		rsp.Sessions = append(rsp.Sessions, ResponseSession{
+			Nonce:      auth.Nonce,
+			Attributes: auth.SessionAttributes,
+			HMAC:       auth.HMAC})
+	}
+
+	if !known {
+		rsp.RawParameters = params
+		return rsp, nil
+	}
+
+	encrypted := false
+	for _, attrs := range sessionAttrs {
+		if attrs&tpm2.AttrResponseEncrypt != 0 {
+			encrypted = true
+		}
+	}
+
+	rsp.Parameters, err = decodeParameters(info.rspParams, params, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp, nil
+}
+
+// DecodeCommandHex decodes the supplied hex encoded command packet.
+func DecodeCommandHex(s string) (*Command, error) {
+	packet, err := decodeHex(s)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeCommand(packet)
+}
+
+// DecodeResponseHex decodes the supplied hex encoded response packet.
+func DecodeResponseHex(commandCode tpm2.CommandCode, sessionAttrs []tpm2.SessionAttributes, s string) (*Response, error) {
+	packet, err := decodeHex(s)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeResponse(commandCode, sessionAttrs, packet)
+}
+
+func decodeHex(s string) ([]byte, error) {
+	packet, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode hex: %w", err)
+	}
+	return packet, nil
+}
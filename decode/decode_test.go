@@ -0,0 +1,199 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package decode_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	. "github.com/canonical/go-tpm2/decode"
+	"github.com/canonical/go-tpm2/mu"
+	. "github.com/canonical/go-tpm2/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type decodeSuite struct{}
+
+var _ = Suite(&decodeSuite{})
+
+func (s *decodeSuite) createPrimaryCommand(attrs tpm2.SessionAttributes, params []byte) tpm2.CommandPacket {
+	auth := tpm2.AuthCommand{
+		SessionHandle:     tpm2.HandlePW,
+		SessionAttributes: attrs,
+		HMAC:              tpm2.Auth("foo")}
+	return tpm2.MarshalCommandPacket(tpm2.CommandCreatePrimary, tpm2.HandleList{tpm2.HandleOwner}, []tpm2.AuthCommand{auth}, params)
+}
+
+func (s *decodeSuite) createPrimaryParams() []byte {
+	template := tpm2.Public{
+		Type:    tpm2.ObjectTypeKeyedHash,
+		NameAlg: tpm2.HashAlgorithmSHA256,
+		Attrs:   tpm2.AttrFixedTPM | tpm2.AttrFixedParent | tpm2.AttrUserWithAuth,
+		Params: &tpm2.PublicParamsU{
+			KeyedHashDetail: &tpm2.KeyedHashParams{Scheme: tpm2.KeyedHashScheme{Scheme: tpm2.KeyedHashSchemeNull}}}}
+	return mu.MustMarshalToBytes(
+		mu.Sized(&tpm2.SensitiveCreate{UserAuth: tpm2.Auth("bar")}),
+		mu.Sized(&template),
+		tpm2.Data(nil),
+		tpm2.PCRSelectionList{})
+}
+
+func (s *decodeSuite) TestDecodeCommand(c *C) {
+	cmd, err := DecodeCommand(s.createPrimaryCommand(tpm2.AttrContinueSession, s.createPrimaryParams()))
+	c.Assert(err, IsNil)
+
+	c.Check(cmd.Tag, Equals, tpm2.TagSessions)
+	c.Check(cmd.CommandCode, Equals, tpm2.CommandCreatePrimary)
+	c.Check(cmd.Handles, DeepEquals, []Handle{{Name: "primaryObject", Handle: tpm2.HandleOwner}})
+	c.Check(cmd.Sessions, DeepEquals, []CommandSession{{Handle: tpm2.HandlePW, Attributes: tpm2.AttrContinueSession, HMAC: tpm2.Auth("foo")}})
+	c.Check(cmd.RawParameters, IsNil)
+
+	c.Assert(cmd.Parameters, HasLen, 4)
+	c.Check(cmd.Parameters[0].Name, Equals, "inSensitive")
+	c.Check(cmd.Parameters[0].Value, DeepEquals, &tpm2.SensitiveCreate{UserAuth: tpm2.Auth("bar")})
+	c.Check(cmd.Parameters[1].Name, Equals, "inPublic")
+	c.Assert(cmd.Parameters[1].Value, FitsTypeOf, &tpm2.Public{})
+	c.Check(cmd.Parameters[1].Value.(*tpm2.Public).Type, Equals, tpm2.ObjectTypeKeyedHash)
+	c.Check(cmd.Parameters[2].Name, Equals, "outsideInfo")
+	c.Check(cmd.Parameters[3].Name, Equals, "creationPCR")
+}
+
+func (s *decodeSuite) TestDecodeCommandEncrypted(c *C) {
+	params := s.createPrimaryParams()
+	// Pretend that the contents of the first parameter are encrypted.
+	params[2] ^= 0xff
+
+	cmd, err := DecodeCommand(s.createPrimaryCommand(tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, params))
+	c.Assert(err, IsNil)
+
+	c.Assert(cmd.Parameters, HasLen, 4)
+	c.Check(cmd.Parameters[0].Encrypted, IsTrue)
+	c.Check(cmd.Parameters[0].Value, DeepEquals, params[2:9])
+	c.Check(cmd.Parameters[1].Encrypted, IsFalse)
+
+	c.Check(cmd.String(), Matches, `(?s).*parameters:\n  inSensitive \(encrypted\): ff036261720000\n.*`)
+}
+
+func (s *decodeSuite) TestDecodeCommandUnknown(c *C) {
+	packet := tpm2.MarshalCommandPacket(0x20000001, tpm2.HandleList{0x80000001}, nil, []byte{1, 2, 3})
+
+	cmd, err := DecodeCommand(packet)
+	c.Assert(err, IsNil)
+	c.Check(cmd.CommandCode, Equals, tpm2.CommandCode(0x20000001))
+	c.Check(cmd.Handles, IsNil)
+	c.Check(cmd.Parameters, IsNil)
+	c.Check(cmd.RawParameters, DeepEquals, []byte{0x80, 0x00, 0x00, 0x01, 0x01, 0x02, 0x03})
+}
+
+func (s *decodeSuite) TestDecodeCommandTrailingBytes(c *C) {
+	packet := tpm2.MarshalCommandPacket(tpm2.CommandGetRandom, nil, nil, []byte{0, 32, 0})
+
+	_, err := DecodeCommand(packet)
+	c.Check(err, ErrorMatches, "1 trailing byte\\(s\\) in parameter area")
+}
+
+func (s *decodeSuite) TestDecodeCommandText(c *C) {
+	cmd, err := DecodeCommandHex("80010000000c0000017b 0020")
+	c.Assert(err, IsNil)
+	c.Check(cmd.String(), Equals, `tag: TPM_ST_NO_SESSIONS
+commandCode: TPM_CC_GetRandom
+parameters:
+  bytesRequested: 32
+`)
+}
+
+func (s *decodeSuite) TestDecodeCommandJSON(c *C) {
+	cmd, err := DecodeCommand(s.createPrimaryCommand(tpm2.AttrContinueSession, s.createPrimaryParams()))
+	c.Assert(err, IsNil)
+
+	data, err := json.Marshal(cmd)
+	c.Assert(err, IsNil)
+
+	var tree map[string]interface{}
+	c.Assert(json.Unmarshal(data, &tree), IsNil)
+	c.Check(tree["commandCode"], Equals, "TPM_CC_CreatePrimary")
+	c.Check(tree["handles"], DeepEquals, []interface{}{map[string]interface{}{"name": "primaryObject", "handle": "TPM_RH_OWNER"}})
+	c.Check(tree["sessions"], DeepEquals, []interface{}{map[string]interface{}{
+		"handle":     "TPM_RS_PW",
+		"nonce":      "",
+		"attributes": "continueSession",
+		"hmac":       hex.EncodeToString([]byte("foo"))}})
+
+	params := tree["parameters"].(map[string]interface{})
+	inPublic := params["inPublic"].(map[string]interface{})
+	c.Check(inPublic["Type"], Equals, "TPM_ALG_KEYEDHASH")
+	c.Check(inPublic["NameAlg"], Equals, "TPM_ALG_SHA256")
+	c.Check(inPublic["Attrs"], Equals, float64(tpm2.AttrFixedTPM|tpm2.AttrFixedParent|tpm2.AttrUserWithAuth))
+	c.Check(inPublic["Params"], DeepEquals, map[string]interface{}{
+		"Scheme": map[string]interface{}{"Scheme": "TPM_ALG_NULL"}})
+
+	// Fields are in the order of the packet.
+	c.Check(string(data), Matches, `\{"tag":.*,"commandCode":.*,"handles":.*,"sessions":.*,"parameters":\{"inSensitive":.*,"inPublic":.*,"outsideInfo":.*,"creationPCR":.*\}\}`)
+}
+
+func (s *decodeSuite) TestDecodeResponseWithHandle(c *C) {
+	params := mu.MustMarshalToBytes(tpm2.Nonce{1, 2, 3, 4})
+	rsp := mu.MustMarshalToBytes(
+		tpm2.ResponseHeader{Tag: tpm2.TagNoSessions, ResponseSize: uint32(14 + len(params))},
+		tpm2.Handle(0x03000000), mu.RawBytes(params))
+
+	r, err := DecodeResponse(tpm2.CommandStartAuthSession, nil, rsp)
+	c.Assert(err, IsNil)
+	c.Check(r.ResponseCode, Equals, tpm2.ResponseSuccess)
+	c.Check(r.Handle, DeepEquals, &Handle{Name: "sessionHandle", Handle: 0x03000000})
+	c.Check(r.Parameters, DeepEquals, []Parameter{{Name: "nonceTPM", Value: tpm2.Nonce{1, 2, 3, 4}}})
+
+	c.Check(r.String(), Equals, `tag: TPM_ST_NO_SESSIONS
+commandCode: TPM_CC_StartAuthSession
+responseCode: 0x0
+handle:
+  name: sessionHandle
+  handle: 0x03000000
+parameters:
+  nonceTPM: 01020304
+`)
+}
+
+func (s *decodeSuite) TestDecodeResponseEncrypted(c *C) {
+	params := mu.MustMarshalToBytes(tpm2.SensitiveData{1, 2, 3, 4})
+	auth := mu.MustMarshalToBytes(tpm2.AuthResponse{Nonce: tpm2.Nonce{5}, SessionAttributes: tpm2.AttrContinueSession | tpm2.AttrResponseEncrypt, HMAC: tpm2.Auth{6}})
+	rsp := mu.MustMarshalToBytes(
+		tpm2.ResponseHeader{Tag: tpm2.TagSessions, ResponseSize: uint32(14 + len(params) + len(auth))},
+		uint32(len(params)), mu.RawBytes(params), mu.RawBytes(auth))
+
+	r, err := DecodeResponse(tpm2.CommandUnseal, []tpm2.SessionAttributes{tpm2.AttrContinueSession | tpm2.AttrResponseEncrypt}, rsp)
+	c.Assert(err, IsNil)
+	c.Check(r.Handle, IsNil)
+	c.Check(r.Parameters, DeepEquals, []Parameter{{Name: "outData", Encrypted: true, Value: []byte{1, 2, 3, 4}}})
+	c.Check(r.Sessions, DeepEquals, []ResponseSession{{Nonce: tpm2.Nonce{5}, Attributes: tpm2.AttrContinueSession | tpm2.AttrResponseEncrypt, HMAC: tpm2.Auth{6}}})
+
+	c.Check(r.String(), Equals, `tag: TPM_ST_SESSIONS
+commandCode: TPM_CC_Unseal
+responseCode: 0x0
+parameters:
+  outData (encrypted): 01020304
+sessions:
+  - [0]
+    nonce: 05
+    attributes: continueSession|encrypt
+    hmac: 06
+`)
+}
+
+func (s *decodeSuite) TestDecodeResponseError(c *C) {
+	r, err := DecodeResponseHex(tpm2.CommandStartAuthSession, nil, "80010000000a0000001e")
+	c.Assert(err, IsNil)
+	c.Check(r.ResponseCode, Equals, tpm2.ResponseBadTag)
+	c.Check(r.Handle, IsNil)
+
+	data, err := json.Marshal(r)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"tag":"TPM_ST_NO_SESSIONS","commandCode":"TPM_CC_StartAuthSession","responseCode":30,"error":"TPM returned an error whilst executing command TPM_CC_StartAuthSession: TPM_RC_BAD_TAG"}`)
+}
@@ -0,0 +1,393 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package decode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// field is a named value in an object.
+type field struct {
+	name  string
+	value interface{}
+}
+
+// object is a sequence of named values, which is marshalled to JSON as an object with
+// its fields in order.
+type object []field
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, f := range o {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		name, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteString(":")
+		b.Write(value)
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+// hexNumber is an integer which is rendered as hexadecimal in text, and as a
+// number in JSON.
+type hexNumber uint64
+
+func (n hexNumber) String() string {
+	return fmt.Sprintf("%#x", uint64(n))
+}
+
+func (n hexNumber) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(n), 10)), nil
+}
+
+var (
+	formatterType = reflect.TypeOf((*fmt.Formatter)(nil)).Elem()
+	stringerType  = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	unionType     = reflect.TypeOf((*mu.Union)(nil)).Elem()
+)
+
+// unionSelector returns the selector value for the union in the field of the supplied
+// struct at the specified index.
+func unionSelector(s reflect.Value, index int) reflect.Value {
+	for _, part := range strings.Split(s.Type().Field(index).Tag.Get("tpm2"), ",") {
+		if strings.HasPrefix(part, "selector:") {
+			return s.FieldByName(part[9:])
+		}
+	}
+	return s.Field(0)
+}
+
+// selectUnionMember returns the selected member of the supplied union value.
+func selectUnionMember(u reflect.Value, selector reflect.Value) reflect.Value {
+	if u.Kind() == reflect.Ptr {
+		if u.IsNil() {
+			return reflect.Value{}
+		}
+		u = u.Elem()
+	}
+	if !u.CanAddr() {
+		v := reflect.New(u.Type()).Elem()
+		v.Set(u)
+		u = v
+	}
+
+	p := u.Addr().Interface().(mu.Union).Select(selector)
+	if p == nil || p == mu.NilUnionValue {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(p).Elem()
+}
+
+// makeTree converts the supplied value in to a tree of objects, slices and scalar values
+// that can be rendered as text or JSON.
+func makeTree(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return makeTree(v.Elem())
+	}
+
+	switch {
+	case v.Kind() == reflect.Struct || v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+	case v.Type().Implements(formatterType) || v.Type().Implements(stringerType):
+		return fmt.Sprintf("%v", v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Type().PkgPath() == "" {
+			return v.Uint()
+		}
+		return hexNumber(v.Uint())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return hex.EncodeToString(b)
+		}
+		out := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, makeTree(v.Index(i)))
+		}
+		return out
+	case reflect.Struct:
+		out := object{}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fv := v.Field(i)
+			if reflect.PtrTo(f.Type).Implements(unionType) || (f.Type.Kind() == reflect.Ptr && f.Type.Implements(unionType)) {
+				fv = selectUnionMember(fv, unionSelector(v, i))
+			}
+			value := makeTree(fv)
+			if value == nil {
+				continue
+			}
+			out = append(out, field{f.Name, value})
+		}
+		return out
+	default:
+		return fmt.Sprintf("%v", v.Interface())
+	}
+}
+
+func tagString(tag tpm2.StructTag) interface{} {
+	switch tag {
+	case tpm2.TagSessions:
+		return "TPM_ST_SESSIONS"
+	case tpm2.TagNoSessions:
+		return "TPM_ST_NO_SESSIONS"
+	default:
+		return hexNumber(tag)
+	}
+}
+
+func sessionAttrsTree(attrs tpm2.SessionAttributes) interface{} {
+	names := []string{}
+	for _, a := range []struct {
+		attr tpm2.SessionAttributes
+		name string
+	}{
+		{tpm2.AttrContinueSession, "continueSession"},
+		{tpm2.AttrAuditExclusive, "auditExclusive"},
+		{tpm2.AttrAuditReset, "auditReset"},
+		{tpm2.AttrCommandEncrypt, "decrypt"},
+		{tpm2.AttrResponseEncrypt, "encrypt"},
+		{tpm2.AttrAudit, "audit"},
+	} {
+		if attrs&a.attr != 0 {
+			names = append(names, a.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+func handleTree(h *Handle) interface{} {
+	name := h.Name
+	if name == "" {
+		name = "handle"
+	}
+	return object{{"name", name}, {"handle", h.Handle.String()}}
+}
+
+func parametersTree(params []Parameter) interface{} {
+	out := object{}
+	for _, p := range params {
+		name := p.Name
+		if p.Encrypted {
+			name += " (encrypted)"
+		}
+		value := makeTree(reflect.ValueOf(p.Value))
+		if value == nil {
+			value = object{}
+		}
+		out = append(out, field{name, value})
+	}
+	return out
+}
+
+func (c *Command) tree() object {
+	out := object{
+		{"tag", tagString(c.Tag)},
+		{"commandCode", c.CommandCode.String()}}
+
+	if len(c.Handles) > 0 {
+		var handles []interface{}
+		for i := range c.Handles {
+			handles = append(handles, handleTree(&c.Handles[i]))
+		}
+		out = append(out, field{"handles", handles})
+	}
+
+	if len(c.Sessions) > 0 {
+		var sessions []interface{}
+		for _, s := range c.Sessions {
+			sessions = append(sessions, object{
+				{"handle", s.Handle.String()},
+				{"nonce", hex.EncodeToString(s.Nonce)},
+				{"attributes", sessionAttrsTree(s.Attributes)},
+				{"hmac", hex.EncodeToString(s.HMAC)}})
+		}
+		out = append(out, field{"sessions", sessions})
+	}
+
+	if c.RawParameters != nil {
+		out = append(out, field{"rawParameters", hex.EncodeToString(c.RawParameters)})
+	} else {
+		out = append(out, field{"parameters", parametersTree(c.Parameters)})
+	}
+
+	return out
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c *Command) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.tree())
+}
+
+// WriteText writes a human-readable representation of this command to w.
+func (c *Command) WriteText(w io.Writer) error {
+	return writeText(w, c.tree(), 0)
+}
+
+// String returns a human-readable representation of this command.
+func (c *Command) String() string {
+	var b bytes.Buffer
+	c.WriteText(&b)
+	return b.String()
+}
+
+func (r *Response) tree() object {
+	out := object{
+		{"tag", tagString(r.Tag)},
+		{"commandCode", r.CommandCode.String()},
+		{"responseCode", hexNumber(r.ResponseCode)}}
+
+	if err := tpm2.DecodeResponseCode(r.CommandCode, r.ResponseCode); err != nil {
+		out = append(out, field{"error", err.Error()})
+		return out
+	}
+
+	if r.Handle != nil {
+		out = append(out, field{"handle", handleTree(r.Handle)})
+	}
+
+	if r.RawParameters != nil {
+		out = append(out, field{"rawParameters", hex.EncodeToString(r.RawParameters)})
+	} else {
+		out = append(out, field{"parameters", parametersTree(r.Parameters)})
+	}
+
+	if len(r.Sessions) > 0 {
+		var sessions []interface{}
+		for _, s := range r.Sessions {
+			sessions = append(sessions, object{
+				{"nonce", hex.EncodeToString(s.Nonce)},
+				{"attributes", sessionAttrsTree(s.Attributes)},
+				{"hmac", hex.EncodeToString(s.HMAC)}})
+		}
+		out = append(out, field{"sessions", sessions})
+	}
+
+	return out
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *Response) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.tree())
+}
+
+// WriteText writes a human-readable representation of this response to w.
+func (r *Response) WriteText(w io.Writer) error {
+	return writeText(w, r.tree(), 0)
+}
+
+// String returns a human-readable representation of this response.
+func (r *Response) String() string {
+	var b bytes.Buffer
+	r.WriteText(&b)
+	return b.String()
+}
+
+func isScalar(v interface{}) bool {
+	switch t := v.(type) {
+	case object:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	default:
+		return true
+	}
+}
+
+func scalarString(v interface{}) string {
+	switch t := v.(type) {
+	case object:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	case nil:
+		return "<nil>"
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+// writeText writes the supplied tree to w in a YAML like format.
+func writeText(w io.Writer, v interface{}, depth int) error {
+	indent := strings.Repeat("  ", depth)
+
+	switch t := v.(type) {
+	case object:
+		for _, f := range t {
+			if isScalar(f.value) {
+				if _, err := fmt.Fprintf(w, "%s%s: %s\n", indent, f.name, scalarString(f.value)); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s%s:\n", indent, f.name); err != nil {
+				return err
+			}
+			if err := writeText(w, f.value, depth+1); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			if isScalar(e) {
+				if _, err := fmt.Fprintf(w, "%s- %s\n", indent, scalarString(e)); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s- [%d]\n", indent, i); err != nil {
+				return err
+			}
+			if err := writeText(w, e, depth+1); err != nil {
+				return err
+			}
+		}
+	default:
+		if _, err := fmt.Fprintf(w, "%s%s\n", indent, scalarString(t)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
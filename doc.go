@@ -85,3 +85,5 @@ The type of authorizations permitted for a resource is dependent on the authoriz
 resource and the resource's attributes.
 */
 package tpm2
+
+//go:generate go run ./cmd/mugen -o mu_generated.go
@@ -40,6 +40,8 @@ const (
 // it is called with a handle that does not correspond to a resource that is available
 // on the TPM. This could be because the resource doesn't exist on the TPM, or it lives within
 // a hierarchy that is disabled.
+//
+//mugen:skip
 type ResourceUnavailableError struct {
 	Handle Handle
 }
@@ -89,6 +91,8 @@ func (e *TctiError) Unwrap() error {
 
 // TPM1Error is returned from DecodeResponseCode and any TPMContext method that executes a
 // command on the TPM if the TPM response code indicates an error from a TPM 1.2 device.
+//
+//mugen:skip
 type TPM1Error struct {
 	Command CommandCode  // Command code associated with this error
 	Code    ResponseCode // Response code
@@ -100,6 +104,8 @@ func (e *TPM1Error) Error() string {
 
 // TPMVendorError is returned from DecodeResponseCode and and TPMContext method that executes
 // a command on the TPM if the TPM response code indicates a vendor-specific error.
+//
+//mugen:skip
 type TPMVendorError struct {
 	Command CommandCode  // Command code associated with this error
 	Code    ResponseCode // Response code
@@ -170,6 +176,8 @@ const (
 // TPMWarning is returned from DecodeResponseCode and any TPMContext method that executes
 // a command on the TPM if the TPM response code indicates a condition that is not necessarily
 // an error.
+//
+//mugen:skip
 type TPMWarning struct {
 	Command CommandCode // Command code associated with this error
 	Code    WarningCode // Warning code
@@ -416,6 +424,8 @@ const (
 // TPMError is returned from DecodeResponseCode and any TPMContext method that
 // executes a command on the TPM if the TPM response code indicates an error that
 // is not associated with a handle, parameter or session.
+//
+//mugen:skip
 type TPMError struct {
 	Command CommandCode // Command code associated with this error
 	Code    ErrorCode   // Error code
@@ -620,6 +630,7 @@ func IsTPMWarning(err error, code WarningCode, command CommandCode) bool {
 	return AsTPMWarning(err, code, command, &e)
 }
 
+//mugen:skip
 type InvalidResponseCodeError ResponseCode
 
 func (e InvalidResponseCodeError) Error() string {
@@ -0,0 +1,72 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build go1.18
+// +build go1.18
+
+package tpm2_test
+
+import (
+	"testing"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+func FuzzResponsePacketUnmarshal(f *testing.F) {
+	f.Add([]byte(makeTestResponse(ResponseSuccess, Handle(0x80000001))))
+	f.Add([]byte(makeTestResponse(ResponseSuccess, uint32(0x20), []uint32{1, 2, 3})))
+	f.Add([]byte(makeTestResponse(ResponseBadTag)))
+
+	payload := mu.MustMarshalToBytes(Handle(0x80000001), uint32(4), mu.RawBytes{1, 2, 3, 4},
+		AuthResponse{Nonce: make(Nonce, 20), SessionAttributes: AttrContinueSession, HMAC: make(Auth, 20)})
+	hdr := ResponseHeader{Tag: TagSessions, ResponseSize: uint32(10 + len(payload)), ResponseCode: ResponseSuccess}
+	f.Add(mu.MustMarshalToBytes(hdr, mu.RawBytes(payload)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var handle Handle
+		_, parameters, authArea, err := ResponsePacket(data).Unmarshal(&handle)
+		if err != nil {
+			return
+		}
+		if len(parameters) > len(data) {
+			t.Errorf("parameter area larger than the response")
+		}
+		if len(authArea) > 3 {
+			t.Errorf("too many auth responses")
+		}
+	})
+}
+
+func FuzzCreateHandleContextFromBytes(f *testing.F) {
+	object, err := CreateObjectResourceContextFromPublic(0x80000001, testutil.NewRSAStorageKeyTemplate())
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(object.SerializeToBytes())
+
+	index, err := CreateNVIndexResourceContextFromPublic(&NVPublic{
+		Index:   0x0181f000,
+		NameAlg: HashAlgorithmSHA256,
+		Attrs:   NVTypeOrdinary.WithAttrs(AttrNVAuthWrite | AttrNVAuthRead | AttrNVWritten),
+		Size:    8})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(index.SerializeToBytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hc, n, err := CreateHandleContextFromBytes(data)
+		if err != nil {
+			return
+		}
+		if n > len(data) {
+			t.Errorf("consumed more bytes than supplied")
+		}
+		if hc.Handle().Type() == HandleTypePCR || hc.Handle().Type() == HandleTypePermanent {
+			t.Errorf("unexpected handle type %v", hc.Handle().Type())
+		}
+	})
+}
@@ -0,0 +1,205 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/templates"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+type marshallingSuite struct{}
+
+var _ = Suite(&marshallingSuite{})
+
+// reflectWrap returns a pointer to a value of an anonymous structure type with a single field
+// containing the supplied value. The anonymous type has no generated marshalling code, so
+// this forces the mu package to use reflection.
+func reflectWrap(val interface{}) reflect.Value {
+	t := reflect.StructOf([]reflect.StructField{{Name: "Value", Type: reflect.TypeOf(val)}})
+	w := reflect.New(t)
+	w.Elem().Field(0).Set(reflect.ValueOf(val))
+	return w
+}
+
+func (s *marshallingSuite) testGeneratedMatchesReflection(c *C, val interface{}) {
+	b, err := mu.MarshalToBytes(val)
+	c.Assert(err, IsNil)
+
+	expected, err := mu.MarshalToBytes(reflectWrap(val).Elem().Interface())
+	c.Assert(err, IsNil)
+	c.Check(b, DeepEquals, expected)
+
+	generated := reflect.New(reflect.TypeOf(val))
+	n, err := mu.UnmarshalFromBytes(b, generated.Interface())
+	c.Check(err, IsNil)
+	c.Check(n, Equals, len(b))
+
+	reflective := reflectWrap(reflect.Zero(reflect.TypeOf(val)).Interface())
+	n, err = mu.UnmarshalFromBytes(b, reflective.Interface())
+	c.Check(err, IsNil)
+	c.Check(n, Equals, len(b))
+
+	c.Check(generated.Elem().Interface(), DeepEquals, reflective.Elem().Field(0).Interface())
+}
+
+func (s *marshallingSuite) TestPublicRSAStorageKey(c *C) {
+	s.testGeneratedMatchesReflection(c, testutil.NewRSAStorageKeyTemplate())
+}
+
+func (s *marshallingSuite) TestPublicRestrictedRSASigningKey(c *C) {
+	s.testGeneratedMatchesReflection(c, testutil.NewRestrictedRSASigningKeyTemplate(nil))
+}
+
+func (s *marshallingSuite) TestPublicSealedObject(c *C) {
+	s.testGeneratedMatchesReflection(c, testutil.NewSealedObjectTemplate())
+}
+
+func (s *marshallingSuite) TestPublicECCStorageKey(c *C) {
+	s.testGeneratedMatchesReflection(c, templates.NewECCStorageKeyWithDefaults())
+}
+
+func (s *marshallingSuite) TestPublicRestrictedECCSigningKey(c *C) {
+	s.testGeneratedMatchesReflection(c, templates.NewRestrictedECCSigningKeyWithDefaults())
+}
+
+func (s *marshallingSuite) TestNVPublic(c *C) {
+	s.testGeneratedMatchesReflection(c, &NVPublic{
+		Index:      0x0181f000,
+		NameAlg:    HashAlgorithmSHA256,
+		Attrs:      NVTypeOrdinary.WithAttrs(AttrNVAuthWrite | AttrNVAuthRead),
+		AuthPolicy: make(Digest, 32),
+		Size:       64})
+}
+
+func (s *marshallingSuite) TestPCRSelectionList(c *C) {
+	s.testGeneratedMatchesReflection(c, PCRSelectionList{
+		{Hash: HashAlgorithmSHA1, Select: []int{0, 1, 7}},
+		{Hash: HashAlgorithmSHA256, Select: []int{4, 7, 23}}})
+}
+
+func (s *marshallingSuite) TestAttestQuote(c *C) {
+	s.testGeneratedMatchesReflection(c, &Attest{
+		Magic:           TPMGeneratedValue,
+		Type:            TagAttestQuote,
+		QualifiedSigner: append(Name{0x00, 0x0b}, make(Name, 32)...),
+		ExtraData:       Data("foo"),
+		ClockInfo:       ClockInfo{Clock: 1000, ResetCount: 2, RestartCount: 3, Safe: true},
+		FirmwareVersion: 0x1234567890,
+		Attested: &AttestU{
+			Quote: &QuoteInfo{
+				PCRSelect: PCRSelectionList{{Hash: HashAlgorithmSHA256, Select: []int{7}}},
+				PCRDigest: make(Digest, 32)}}})
+}
+
+func (s *marshallingSuite) TestCreationData(c *C) {
+	s.testGeneratedMatchesReflection(c, &CreationData{
+		PCRSelect:           PCRSelectionList{{Hash: HashAlgorithmSHA256, Select: []int{0, 7}}},
+		PCRDigest:           make(Digest, 32),
+		Locality:            0,
+		ParentNameAlg:       AlgorithmNull,
+		ParentName:          Name{0x40, 0x00, 0x00, 0x01},
+		ParentQualifiedName: Name{0x40, 0x00, 0x00, 0x01},
+		OutsideInfo:         Data("bar")})
+}
+
+func (s *marshallingSuite) TestCapabilityData(c *C) {
+	s.testGeneratedMatchesReflection(c, &CapabilityData{
+		Capability: CapabilityTPMProperties,
+		Data: &CapabilitiesU{
+			TPMProperties: TaggedTPMPropertyList{
+				{Property: PropertyManufacturer, Value: 0x49424d00},
+				{Property: PropertyManufacturer + 1, Value: 0x53572020}}}})
+}
+
+func (s *marshallingSuite) TestSignatureECDSA(c *C) {
+	s.testGeneratedMatchesReflection(c, &Signature{
+		SigAlg: SigSchemeAlgECDSA,
+		Signature: &SignatureU{
+			ECDSA: &SignatureECDSA{
+				Hash:       HashAlgorithmSHA256,
+				SignatureR: make(ECCParameter, 32),
+				SignatureS: make(ECCParameter, 32)}}})
+}
+
+func makeNVReadResponseStream(n int) []byte {
+	data := make(MaxNVBuffer, 1024)
+	var stream []byte
+	for i := 0; i < n; i++ {
+		stream = append(stream, mu.MustMarshalToBytes(data)...)
+	}
+	return stream
+}
+
+// BenchmarkUnmarshalNVReadResponseStream unmarshals the parameters of many TPM2_NV_Read
+// responses from a single reader.
+func BenchmarkUnmarshalNVReadResponseStream(b *testing.B) {
+	stream := makeNVReadResponseStream(256)
+
+	for _, r := range []struct {
+		name string
+		new  func() io.Reader
+	}{
+		{"bytes.Reader", func() io.Reader { return bytes.NewReader(stream) }},
+		{"bytes.Buffer", func() io.Reader { return bytes.NewBuffer(stream) }},
+	} {
+		b.Run(r.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rd := r.new()
+				for j := 0; j < 256; j++ {
+					var data MaxNVBuffer
+					if _, err := mu.UnmarshalFromReader(rd, &data); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPCRExtendAndNVReadPackets marshals a TPM2_PCR_Extend command and decodes the
+// responses to TPM2_PCR_Extend and TPM2_NV_Read, which is the work done by TPMContext for
+// each command other than transmitting it.
+func BenchmarkPCRExtendAndNVReadPackets(b *testing.B) {
+	digests := TaggedHashList{
+		{HashAlg: HashAlgorithmSHA1, Digest: make([]byte, 20)},
+		{HashAlg: HashAlgorithmSHA256, Digest: make([]byte, 32)}}
+
+	auth := mu.MustMarshalToBytes(AuthResponse{Nonce: make(Nonce, 32), SessionAttributes: AttrContinueSession, HMAC: make(Auth, 32)})
+	makeResponse := func(params []byte) ResponsePacket {
+		body := mu.MustMarshalToBytes(uint32(len(params)), mu.RawBytes(params), mu.RawBytes(auth))
+		hdr := ResponseHeader{Tag: TagSessions, ResponseSize: uint32(10 + len(body)), ResponseCode: ResponseSuccess}
+		return append(mu.MustMarshalToBytes(hdr), body...)
+	}
+	extendRsp := makeResponse(nil)
+	nvReadRsp := makeResponse(mu.MustMarshalToBytes(make(MaxNVBuffer, 1024)))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mu.MarshalToBytes(Handle(7), digests); err != nil {
+			b.Fatal(err)
+		}
+		if _, _, _, err := extendRsp.Unmarshal(nil); err != nil {
+			b.Fatal(err)
+		}
+		_, params, _, err := nvReadRsp.Unmarshal(nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var data MaxNVBuffer
+		if _, err := mu.UnmarshalFromBytes(params, &data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
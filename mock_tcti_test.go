@@ -0,0 +1,63 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// mockTCTI is a TCTI for tests that don't need a TPM. Each command written to it is passed to handler, and the
+// returned response packet is returned from the subsequent reads. An error returned from handler is returned from
+// Write. It does no locking of its own, so that the race detector catches concurrent use.
+type mockTCTI struct {
+	handler func(cmd CommandPacket) (ResponsePacket, error)
+	rsp     *bytes.Reader
+}
+
+func (t *mockTCTI) Read(data []byte) (int, error) {
+	if t.rsp == nil {
+		return 0, io.EOF
+	}
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *mockTCTI) Write(data []byte) (int, error) {
+	rsp, err := t.handler(CommandPacket(data))
+	if err != nil {
+		return 0, err
+	}
+	t.rsp = bytes.NewReader(rsp)
+	return len(data), nil
+}
+
+func (t *mockTCTI) Close() error {
+	return nil
+}
+
+func (t *mockTCTI) SetLocality(locality uint8) error {
+	return errors.New("not implemented")
+}
+
+func (t *mockTCTI) MakeSticky(handle Handle, sticky bool) error {
+	return errors.New("not implemented")
+}
+
+// makeTestResponse returns a response packet with no sessions, the supplied response code and the supplied
+// response parameters.
+func makeTestResponse(rc ResponseCode, params ...interface{}) ResponsePacket {
+	payload := mu.MustMarshalToBytes(params...)
+	hdr := ResponseHeader{Tag: TagNoSessions, ResponseSize: uint32(binary.Size(ResponseHeader{}) + len(payload)), ResponseCode: rc}
+	return mu.MustMarshalToBytes(hdr, mu.RawBytes(payload))
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
 
 	"github.com/canonical/go-tpm2/mu"
 
@@ -17,12 +18,32 @@ import (
 )
 
 const (
-	cmdPowerOn        uint32 = 1
-	cmdTPMSendCommand uint32 = 8
-	cmdNVOn           uint32 = 11
-	cmdReset          uint32 = 17
-	cmdSessionEnd     uint32 = 20
-	cmdStop           uint32 = 21
+	cmdPowerOn         uint32 = 1
+	cmdPowerOff        uint32 = 2
+	cmdPhysPresOn      uint32 = 3
+	cmdPhysPresOff     uint32 = 4
+	cmdTPMSendCommand  uint32 = 8
+	cmdCancelOn        uint32 = 9
+	cmdCancelOff       uint32 = 10
+	cmdNVOn            uint32 = 11
+	cmdNVOff           uint32 = 12
+	cmdRemoteHandshake uint32 = 15
+	cmdReset           uint32 = 17
+	cmdSessionEnd      uint32 = 20
+	cmdStop            uint32 = 21
+	cmdTestFailureMode uint32 = 30
+
+	mssimClientVersion uint32 = 1
+)
+
+// MssimServerFlags corresponds to the flags returned from the TPM simulator during the remote handshake.
+type MssimServerFlags uint32
+
+const (
+	MssimPlatformAvailable MssimServerFlags = 0x01 // The platform interface is available
+	MssimUsesTbs           MssimServerFlags = 0x02 // The simulator is backed by TBS
+	MssimInRawMode         MssimServerFlags = 0x04 // The simulator is in raw mode
+	MssimSupportsPP        MssimServerFlags = 0x08 // The simulator supports physical presence
 )
 
 // PlatformCommandError corresponds to an error code in response to a platform command executed on a TPM simulator.
@@ -42,6 +63,9 @@ type TctiMssim struct {
 	tpm      net.Conn
 	platform net.Conn
 
+	platformMu sync.Mutex // Protects the platform channel and cancelled, as Cancel may be called from another goroutine
+	cancelled  bool
+
 	r io.Reader
 }
 
@@ -63,6 +87,10 @@ func (t *TctiMssim) Read(data []byte) (int, error) {
 		if err := binary.Read(t.tpm, binary.BigEndian, &trash); err != nil {
 			return n, xerrors.Errorf("cannot read zero bytes from TPM command channel after response: %w", err)
 		}
+
+		if err := t.clearCancel(); err != nil {
+			return n, xerrors.Errorf("cannot clear cancel signal: %w", err)
+		}
 	}
 
 	return n, err
@@ -101,6 +129,12 @@ func (t *TctiMssim) MakeSticky(handle Handle, sticky bool) error {
 }
 
 func (t *TctiMssim) platformCommand(cmd uint32) error {
+	t.platformMu.Lock()
+	defer t.platformMu.Unlock()
+	return t.platformCommandLocked(cmd)
+}
+
+func (t *TctiMssim) platformCommandLocked(cmd uint32) error {
 	if err := binary.Write(t.platform, binary.BigEndian, cmd); err != nil {
 		return xerrors.Errorf("cannot send command: %w", err)
 	}
@@ -116,6 +150,124 @@ func (t *TctiMssim) platformCommand(cmd uint32) error {
 	return nil
 }
 
+// tpmChannelCommand submits a non-TPM command on the TPM command channel. The args are sent after the
+// command code, and the response values are decoded into the supplied pointers before the final
+// acknowledgement is read.
+func (t *TctiMssim) tpmChannelCommand(cmd uint32, args []interface{}, resp ...interface{}) error {
+	if t.r != nil {
+		return errors.New("the response to the previous command has not been read")
+	}
+
+	if _, err := t.tpm.Write(mu.MustMarshalToBytes(append([]interface{}{cmd}, args...)...)); err != nil {
+		return xerrors.Errorf("cannot send command: %w", err)
+	}
+	for _, r := range resp {
+		if err := binary.Read(t.tpm, binary.BigEndian, r); err != nil {
+			return xerrors.Errorf("cannot read response to command: %w", err)
+		}
+	}
+
+	var ack uint32
+	if err := binary.Read(t.tpm, binary.BigEndian, &ack); err != nil {
+		return xerrors.Errorf("cannot read response to command: %w", err)
+	}
+	if ack != 0 {
+		return &PlatformCommandError{cmd, ack}
+	}
+
+	return nil
+}
+
+// PowerOn submits the power on command on the platform connection.
+func (t *TctiMssim) PowerOn() error {
+	return t.platformCommand(cmdPowerOn)
+}
+
+// PowerOff submits the power off command on the platform connection. The TPM simulator must be powered on
+// again with PowerOn and then initialized with TPMContext.Startup before it can be used.
+func (t *TctiMssim) PowerOff() error {
+	return t.platformCommand(cmdPowerOff)
+}
+
+// PhysicalPresenceOn asserts the physical presence signal on the platform connection. This is required for
+// commands that need physical presence, such as TPM2_PP_Commands and commands authorized with a policy
+// containing TPM2_PolicyPhysicalPresence.
+func (t *TctiMssim) PhysicalPresenceOn() error {
+	return t.platformCommand(cmdPhysPresOn)
+}
+
+// PhysicalPresenceOff deasserts the physical presence signal on the platform connection.
+func (t *TctiMssim) PhysicalPresenceOff() error {
+	return t.platformCommand(cmdPhysPresOff)
+}
+
+// CancelOn asserts the cancel signal on the platform connection. Commands executed whilst this is asserted
+// may fail with TPM_RC_CANCELED. Unlike Cancel, this signal remains asserted until CancelOff is called.
+func (t *TctiMssim) CancelOn() error {
+	return t.platformCommand(cmdCancelOn)
+}
+
+// CancelOff deasserts the cancel signal on the platform connection.
+func (t *TctiMssim) CancelOff() error {
+	t.platformMu.Lock()
+	defer t.platformMu.Unlock()
+
+	t.cancelled = false
+	return t.platformCommandLocked(cmdCancelOff)
+}
+
+// NVOn submits the NV on command on the platform connection, which makes NV memory available to the TPM
+// simulator.
+func (t *TctiMssim) NVOn() error {
+	return t.platformCommand(cmdNVOn)
+}
+
+// NVOff submits the NV off command on the platform connection, which makes NV memory unavailable to the TPM
+// simulator. Commands that need to access NV will fail with TPM_RC_NV_UNAVAILABLE.
+func (t *TctiMssim) NVOff() error {
+	return t.platformCommand(cmdNVOff)
+}
+
+// TestFailureMode submits a command on the TPM command channel that forces the TPM simulator into failure
+// mode the next time a command is executed. The simulator can be restored by power cycling it with
+// PowerOff and PowerOn.
+func (t *TctiMssim) TestFailureMode() error {
+	return t.tpmChannelCommand(cmdTestFailureMode, nil)
+}
+
+// RemoteHandshake performs the remote handshake on the TPM command channel, and returns the version of the
+// TPM simulator's protocol and the flags indicating its capabilities.
+func (t *TctiMssim) RemoteHandshake() (version uint32, flags MssimServerFlags, err error) {
+	if err := t.tpmChannelCommand(cmdRemoteHandshake, []interface{}{mssimClientVersion}, &version, &flags); err != nil {
+		return 0, 0, err
+	}
+	return version, flags, nil
+}
+
+// Cancel asserts the cancel signal on the platform connection, which requests that the TPM simulator
+// cancels the currently executing command. The signal is cleared once the response has been read.
+func (t *TctiMssim) Cancel() error {
+	t.platformMu.Lock()
+	defer t.platformMu.Unlock()
+
+	if err := t.platformCommandLocked(cmdCancelOn); err != nil {
+		return err
+	}
+	t.cancelled = true
+	return nil
+}
+
+func (t *TctiMssim) clearCancel() error {
+	t.platformMu.Lock()
+	defer t.platformMu.Unlock()
+
+	if !t.cancelled {
+		return nil
+	}
+	t.cancelled = false
+	return t.platformCommandLocked(cmdCancelOff)
+}
+
 // Reset submits the reset command on the platform connection, which initiates a reset of the TPM simulator and results in the
 // execution of _TPM_Init().
 func (t *TctiMssim) Reset() error {
@@ -159,10 +311,10 @@ func OpenMssim(host string, port uint) (*TctiMssim, error) {
 	}
 	tcti.platform = platform
 
-	if err := tcti.platformCommand(cmdPowerOn); err != nil {
+	if err := tcti.PowerOn(); err != nil {
 		return nil, xerrors.Errorf("cannot complete power on command: %w", err)
 	}
-	if err := tcti.platformCommand(cmdNVOn); err != nil {
+	if err := tcti.NVOn(); err != nil {
 		return nil, xerrors.Errorf("cannot complete NV on command: %w", err)
 	}
 
@@ -0,0 +1,92 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+type mssimSuite struct {
+	testutil.TPMSimulatorTest
+}
+
+var _ = Suite(&mssimSuite{})
+
+func (s *mssimSuite) SetUpTest(c *C) {
+	if testutil.TPMBackend != testutil.TPMBackendMssim {
+		c.Skip("not the reference TPM simulator")
+	}
+	s.TPMSimulatorTest.SetUpTest(c)
+}
+
+func (s *mssimSuite) powerCycle(c *C) {
+	c.Check(s.Mssim(c).PowerOff(), IsNil)
+	c.Check(s.Mssim(c).PowerOn(), IsNil)
+	c.Check(s.TPM.Startup(StartupClear), IsNil)
+}
+
+func (s *mssimSuite) TestRemoteHandshake(c *C) {
+	version, flags, err := s.Mssim(c).RemoteHandshake()
+	c.Check(err, IsNil)
+	c.Check(version, Not(Equals), uint32(0))
+	c.Check(flags&MssimPlatformAvailable, Equals, MssimPlatformAvailable)
+
+	// The command channel should still be usable.
+	_, err = s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+}
+
+func (s *mssimSuite) TestPowerCycle(c *C) {
+	c.Check(s.TPM.Shutdown(StartupClear), IsNil)
+	s.powerCycle(c)
+
+	_, err := s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+}
+
+func (s *mssimSuite) TestNVOff(c *C) {
+	c.Check(s.Mssim(c).NVOff(), IsNil)
+	defer func() { c.Check(s.Mssim(c).NVOn(), IsNil) }()
+
+	nvPublic := NVPublic{
+		Index:   s.NextAvailableHandle(c, 0x01800000),
+		NameAlg: HashAlgorithmSHA256,
+		Attrs:   NVTypeOrdinary.WithAttrs(AttrNVAuthWrite | AttrNVAuthRead),
+		Size:    8}
+	_, err := s.TPM.NVDefineSpace(s.TPM.OwnerHandleContext(), nil, &nvPublic, nil)
+	c.Check(IsTPMWarning(err, WarningNVUnavailable, CommandNVDefineSpace), testutil.IsTrue)
+}
+
+func (s *mssimSuite) TestPhysicalPresence(c *C) {
+	c.Check(s.Mssim(c).PhysicalPresenceOn(), IsNil)
+	c.Check(s.Mssim(c).PhysicalPresenceOff(), IsNil)
+}
+
+func (s *mssimSuite) TestCancelOn(c *C) {
+	c.Check(s.Mssim(c).CancelOn(), IsNil)
+
+	_, _, _, _, _, err := s.TPM.CreatePrimary(s.TPM.OwnerHandleContext(), nil, testutil.NewRSAStorageKeyTemplate(), nil, nil, nil)
+	c.Check(IsTPMWarning(err, WarningCanceled, CommandCreatePrimary), testutil.IsTrue)
+
+	c.Check(s.Mssim(c).CancelOff(), IsNil)
+
+	_, err = s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+}
+
+func (s *mssimSuite) TestFailureMode(c *C) {
+	c.Check(s.Mssim(c).TestFailureMode(), IsNil)
+
+	_, err := s.TPM.GetRandom(16)
+	c.Check(IsTPMError(err, ErrorFailure, CommandGetRandom), testutil.IsTrue)
+
+	s.powerCycle(c)
+
+	_, err = s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+}
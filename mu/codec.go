@@ -0,0 +1,541 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package mu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+)
+
+var (
+	errNotGenerated = errors.New("no generated marshalling code")
+
+	// generatedTypes contains the types that have generated marshalling code, registered with
+	// RegisterGeneratedTypes. It is only written to from init functions, so it doesn't require
+	// any locking.
+	generatedTypes = make(map[reflect.Type]struct{})
+
+	// disableGenerated disables the generated marshalling code, and is used for testing.
+	disableGenerated bool
+)
+
+type sizedWrapper = struct {
+	Value interface{} `tpm2:"sized"`
+}
+
+// GeneratedMarshaller is implemented by types with marshalling code generated by the mugen tool. It
+// is only used for types that are registered with RegisterGeneratedTypes.
+type GeneratedMarshaller interface {
+	MarshalMu(e *Encoder) error
+}
+
+// GeneratedUnmarshaller is implemented by types with unmarshalling code generated by the mugen tool.
+// It is only used for types that are registered with RegisterGeneratedTypes.
+type GeneratedUnmarshaller interface {
+	UnmarshalMu(d *Decoder) error
+}
+
+// RegisterGeneratedTypes registers types that implement GeneratedMarshaller and GeneratedUnmarshaller,
+// so that MarshalToBytes, MarshalToWriter, UnmarshalFromBytes and UnmarshalFromReader can use the
+// generated code rather than walking the supplied values with reflection. Each value must be a nil
+// pointer to a registered type. Registration applies to exactly the specified types, and not to
+// types that embed them and which inherit their methods.
+//
+// This is called from init functions in code generated by the mugen tool, and must not be called
+// from anywhere else.
+func RegisterGeneratedTypes(ptrs ...interface{}) {
+	for _, p := range ptrs {
+		t := reflect.TypeOf(p)
+		if t.Kind() != reflect.Ptr {
+			panic("RegisterGeneratedTypes: expected a pointer")
+		}
+		if !t.Implements(reflect.TypeOf((*GeneratedMarshaller)(nil)).Elem()) ||
+			!t.Implements(reflect.TypeOf((*GeneratedUnmarshaller)(nil)).Elem()) {
+			panic("RegisterGeneratedTypes: " + t.Elem().String() + " does not have generated marshalling code")
+		}
+		generatedTypes[t.Elem()] = struct{}{}
+	}
+}
+
+func isGenerated(t reflect.Type) bool {
+	_, ok := generatedTypes[t]
+	return ok
+}
+
+// Encoder is used by generated code to marshal values to the TPM wire format. It implements
+// io.Writer so that it can be passed to implementations of CustomMarshaller.
+type Encoder struct {
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (e *Encoder) Write(data []byte) (int, error) {
+	e.buf = append(e.buf, data...)
+	return len(data), nil
+}
+
+// Bytes returns the bytes written to this encoder.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// WriteBool writes the supplied value.
+func (e *Encoder) WriteBool(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// WriteUint8 writes the supplied value.
+func (e *Encoder) WriteUint8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+// WriteUint16 writes the supplied value in big-endian format.
+func (e *Encoder) WriteUint16(v uint16) {
+	e.buf = append(e.buf, byte(v>>8), byte(v))
+}
+
+// WriteUint32 writes the supplied value in big-endian format.
+func (e *Encoder) WriteUint32(v uint32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// WriteUint64 writes the supplied value in big-endian format.
+func (e *Encoder) WriteUint64(v uint64) {
+	e.buf = append(e.buf, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// WriteBytes writes the supplied bytes without a size field.
+func (e *Encoder) WriteBytes(data []byte) {
+	e.buf = append(e.buf, data...)
+}
+
+// WriteListLength writes the length field of a list with the specified number of elements.
+func (e *Encoder) WriteListLength(n int) error {
+	// See the comment in marshaller.marshalList.
+	if int(uint32(n)) != n {
+		return errors.New("slice length greater than 2^32-1")
+	}
+	e.WriteUint32(uint32(n))
+	return nil
+}
+
+// BeginSized reserves space for the size field of a sized value. The returned offset must be passed
+// to EndSized once the value has been written.
+func (e *Encoder) BeginSized() int {
+	e.buf = append(e.buf, 0, 0)
+	return len(e.buf)
+}
+
+// EndSized completes a sized value started with BeginSized, by writing its size field.
+func (e *Encoder) EndSized(start int) error {
+	n := len(e.buf) - start
+	if n > math.MaxUint16 {
+		return errors.New("sized value size greater than 2^16-1")
+	}
+	binary.BigEndian.PutUint16(e.buf[start-2:], uint16(n))
+	return nil
+}
+
+// Decoder is used by generated code to unmarshal values from the TPM wire format. It implements
+// Reader so that it can be passed to implementations of CustomUnmarshaller.
+type Decoder struct {
+	buf  []byte
+	off  int
+	opts *UnmarshalOptions
+}
+
+// Read implements io.Reader.
+func (d *Decoder) Read(data []byte) (int, error) {
+	if d.Len() == 0 {
+		return 0, io.EOF
+	}
+	n := copy(data, d.buf[d.off:])
+	d.off += n
+	return n, nil
+}
+
+// Len returns the number of bytes that can still be read.
+func (d *Decoder) Len() int {
+	return len(d.buf) - d.off
+}
+
+func (d *Decoder) next(n int) ([]byte, error) {
+	if d.Len() < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// ReadBool reads a boolean value.
+func (d *Decoder) ReadBool() (bool, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return false, err
+	}
+	return b[0] != 0, nil
+}
+
+// ReadUint8 reads a single byte.
+func (d *Decoder) ReadUint8() (uint8, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// ReadUint16 reads a big-endian 16-bit value.
+func (d *Decoder) ReadUint16() (uint16, error) {
+	b, err := d.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+// ReadUint32 reads a big-endian 32-bit value.
+func (d *Decoder) ReadUint32() (uint32, error) {
+	b, err := d.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// ReadUint64 reads a big-endian 64-bit value.
+func (d *Decoder) ReadUint64() (uint64, error) {
+	b, err := d.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// ReadBytes fills the supplied slice, which corresponds to a value without a size field.
+func (d *Decoder) ReadBytes(data []byte) error {
+	b, err := d.next(len(data))
+	if err != nil {
+		return err
+	}
+	copy(data, b)
+	return nil
+}
+
+// ReadSize reads the size field of a sized value. If preallocated is true, the destination is a
+// pointer that has already been initialized, and a zero size is an error.
+func (d *Decoder) ReadSize(preallocated bool) (int, error) {
+	n, err := d.ReadUint16()
+	if err != nil {
+		return 0, err
+	}
+	if err := d.opts.checkSizedLength(n); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case n == 0 && preallocated:
+		return 0, errors.New("sized value is zero sized, but destination value has been pre-allocated")
+	case int(n) > d.Len():
+		return 0, errors.New("sized value has a size larger than the remaining bytes")
+	}
+	return int(n), nil
+}
+
+// ReadListLength reads the length field of a list. It returns an error if the length is larger
+// than the number of remaining bytes, so the result can be used to allocate the list.
+func (d *Decoder) ReadListLength() (int, error) {
+	n, err := d.ReadUint32()
+	if err != nil {
+		return 0, err
+	}
+	if err := d.opts.checkListLength(n); err != nil {
+		return 0, err
+	}
+	if int64(n) > int64(d.Len()) {
+		return 0, errors.New("list length is larger than the remaining bytes")
+	}
+	return int(n), nil
+}
+
+// BeginSized limits subsequent reads to the payload of a sized value with the specified size, which
+// must have been returned from ReadSize. The returned value must be passed to EndSized once the
+// payload has been unmarshalled.
+func (d *Decoder) BeginSized(n int) (end int) {
+	end = len(d.buf)
+	d.buf = d.buf[:d.off+n]
+	return end
+}
+
+// EndSized removes the limit applied by BeginSized.
+func (d *Decoder) EndSized(end int) {
+	d.buf = d.buf[:end]
+}
+
+func encodeValue(e *Encoder, val interface{}) error {
+	switch v := val.(type) {
+	case bool:
+		e.WriteBool(v)
+	case int8:
+		e.WriteUint8(uint8(v))
+	case uint8:
+		e.WriteUint8(v)
+	case int16:
+		e.WriteUint16(uint16(v))
+	case uint16:
+		e.WriteUint16(v)
+	case int32:
+		e.WriteUint32(uint32(v))
+	case uint32:
+		e.WriteUint32(v)
+	case int64:
+		e.WriteUint64(uint64(v))
+	case uint64:
+		e.WriteUint64(v)
+	case RawBytes:
+		e.WriteBytes(v)
+	case []byte:
+		if v == nil {
+			e.WriteUint16(0)
+			return nil
+		}
+		start := e.BeginSized()
+		e.WriteBytes(v)
+		return e.EndSized(start)
+	case *sizedWrapper:
+		p := reflect.ValueOf(v.Value)
+		if p.Kind() != reflect.Ptr {
+			return errNotGenerated
+		}
+		if p.IsNil() {
+			e.WriteUint16(0)
+			return nil
+		}
+		start := e.BeginSized()
+		if err := encodeValue(e, v.Value); err != nil {
+			return err
+		}
+		return e.EndSized(start)
+	case GeneratedMarshaller:
+		t := reflect.TypeOf(v)
+		if t.Kind() == reflect.Ptr {
+			if reflect.ValueOf(v).IsNil() {
+				return encodeValue(e, reflect.Zero(t.Elem()).Interface())
+			}
+			t = t.Elem()
+		}
+		if !isGenerated(t) {
+			return errNotGenerated
+		}
+		return v.MarshalMu(e)
+	default:
+		p := reflect.ValueOf(val)
+		if p.Kind() != reflect.Ptr {
+			return errNotGenerated
+		}
+		if p.IsNil() {
+			return encodeValue(e, reflect.Zero(p.Type().Elem()).Interface())
+		}
+		return encodeValue(e, p.Elem().Interface())
+	}
+	return nil
+}
+
+// marshalGenerated attempts to marshal the supplied values using generated code. It returns
+// errNotGenerated if any of the values are not supported, in which case nothing is written to w.
+// Other errors are not returned from here - the caller should repeat the operation using the
+// reflection based marshaller in order to obtain a fully described error.
+func marshalGenerated(w io.Writer, vals ...interface{}) (int, error) {
+	if disableGenerated {
+		return 0, errNotGenerated
+	}
+
+	var e *Encoder
+	switch w := w.(type) {
+	case *Encoder:
+		e = w
+	case *bytes.Buffer:
+		e = new(Encoder)
+	default:
+		return 0, errNotGenerated
+	}
+
+	start := len(e.buf)
+	for _, v := range vals {
+		if err := encodeValue(e, v); err != nil {
+			e.buf = e.buf[:start]
+			return 0, errNotGenerated
+		}
+	}
+
+	if buf, ok := w.(*bytes.Buffer); ok {
+		buf.Write(e.buf)
+	}
+	return len(e.buf) - start, nil
+}
+
+func decodeValue(d *Decoder, val interface{}) error {
+	switch v := val.(type) {
+	case *bool:
+		x, err := d.ReadBool()
+		if err != nil {
+			return err
+		}
+		*v = x
+	case *int8:
+		x, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		*v = int8(x)
+	case *uint8:
+		x, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		*v = x
+	case *int16:
+		x, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		*v = int16(x)
+	case *uint16:
+		x, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		*v = x
+	case *int32:
+		x, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		*v = int32(x)
+	case *uint32:
+		x, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		*v = x
+	case *int64:
+		x, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		*v = int64(x)
+	case *uint64:
+		x, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		*v = x
+	case *RawBytes:
+		return d.ReadBytes(*v)
+	case *[]byte:
+		n, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			*v = make([]byte, n)
+			return d.ReadBytes(*v)
+		}
+	case *sizedWrapper:
+		p := reflect.ValueOf(v.Value)
+		if p.Kind() != reflect.Ptr || p.IsNil() {
+			return errNotGenerated
+		}
+		// The destination is always pre-allocated here, as it is a
+		// pointer to the actual destination.
+		n, err := d.ReadSize(true)
+		if err != nil {
+			return err
+		}
+		end := d.BeginSized(n)
+		defer d.EndSized(end)
+		return decodeValue(d, v.Value)
+	case GeneratedUnmarshaller:
+		if !isGenerated(reflect.TypeOf(v).Elem()) {
+			return errNotGenerated
+		}
+		return v.UnmarshalMu(d)
+	default:
+		p := reflect.ValueOf(val)
+		if p.Kind() != reflect.Ptr || p.Elem().Kind() != reflect.Ptr {
+			return errNotGenerated
+		}
+		if p.Elem().IsNil() {
+			p.Elem().Set(reflect.New(p.Type().Elem().Elem()))
+		}
+		return decodeValue(d, p.Elem().Interface())
+	}
+	return nil
+}
+
+// unmarshalGenerated attempts to unmarshal to the supplied values from b using generated code,
+// returning the number of bytes consumed. If this fails for any reason, the supplied values may
+// have been partially modified and the caller should repeat the operation using the reflection
+// based unmarshaller.
+func unmarshalGenerated(b []byte, opts *UnmarshalOptions, vals ...interface{}) (int, error) {
+	if disableGenerated {
+		return 0, errNotGenerated
+	}
+
+	if opts != nil && opts.MaxBytes > 0 && int64(len(b)) > opts.MaxBytes {
+		// Running out of bytes here results in the operation being repeated
+		// by the reflection based unmarshaller, which returns the appropriate
+		// error.
+		b = b[:opts.MaxBytes]
+	}
+
+	d := &Decoder{buf: b, opts: opts}
+	for _, v := range vals {
+		if err := decodeValue(d, v); err != nil {
+			return 0, err
+		}
+	}
+	return d.off, nil
+}
+
+// unmarshalGeneratedFromReader is a version of unmarshalGenerated that works with the io.Reader
+// implementations that support it. On failure, nothing is consumed from r.
+//
+// Only readers that expose their remaining input without copying it are supported. A
+// *bytes.Reader doesn't, and copying the remaining input on each call would make consuming
+// a reader across many calls quadratic, so it uses the reflection based unmarshaller.
+func unmarshalGeneratedFromReader(r io.Reader, opts *UnmarshalOptions, vals ...interface{}) (int, error) {
+	if disableGenerated {
+		return 0, errNotGenerated
+	}
+
+	switch r := r.(type) {
+	case *Decoder:
+		n, err := unmarshalGenerated(r.buf[r.off:], opts, vals...)
+		if err != nil {
+			return 0, err
+		}
+		r.off += n
+		return n, nil
+	case *bytes.Buffer:
+		n, err := unmarshalGenerated(r.Bytes(), opts, vals...)
+		if err != nil {
+			return 0, err
+		}
+		r.Next(n)
+		return n, nil
+	default:
+		return 0, errNotGenerated
+	}
+}
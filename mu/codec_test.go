@@ -0,0 +1,121 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package mu_test
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+type codecSuite struct{}
+
+var _ = Suite(&codecSuite{})
+
+type testStructEmbeddingGenerated struct {
+	testStruct
+	E uint8
+}
+
+func (s *codecSuite) TestMarshalGeneratedStruct(c *C) {
+	var u32 uint32 = 657763432
+	b, err := MarshalGenerated(testStruct{56324, &u32, true, []uint32{4232, 567785}})
+	c.Check(err, IsNil)
+	c.Check(b, DeepEquals, testutil.DecodeHexString(c, "dc042734ac680100000002000010880008a9e9"))
+}
+
+func (s *codecSuite) TestMarshalGeneratedUnion(c *C) {
+	b, err := MarshalGenerated(testUnionContainer{Select: 2, Union: &testUnion{B: []uint32{3287743, 98731}}})
+	c.Check(err, IsNil)
+	c.Check(b, DeepEquals, testutil.DecodeHexString(c, "000000020000000200322abf000181ab"))
+}
+
+func (s *codecSuite) TestMarshalGeneratedSized(c *C) {
+	b, err := MarshalGenerated(Sized(&testStruct{A: 1}), Sized((*testStruct)(nil)))
+	c.Check(err, IsNil)
+	c.Check(b, DeepEquals, testutil.DecodeHexString(c, "000b"+"0001000000000000000000"+"0000"))
+}
+
+func (s *codecSuite) TestMarshalGeneratedCustomType(c *C) {
+	b, err := MarshalGenerated(testStructContainingCustomType{A: 1, X: &testStructWithCustomMarshaller{A: 44332, B: []uint32{885432}}})
+	c.Check(err, IsNil)
+	c.Check(b, DeepEquals, testutil.DecodeHexString(c, "000000012cad00000001000d82b8"))
+}
+
+func (s *codecSuite) TestMarshalGeneratedUnsupported(c *C) {
+	_, err := MarshalGenerated(uint32(1), []uint32{1})
+	c.Check(err, Equals, ErrNotGenerated)
+}
+
+func (s *codecSuite) TestMarshalGeneratedEmbeddedType(c *C) {
+	// This type inherits the generated methods from testStruct, but
+	// mustn't use them.
+	_, err := MarshalGenerated(testStructEmbeddingGenerated{E: 1})
+	c.Check(err, Equals, ErrNotGenerated)
+
+	b, err := MarshalToBytes(testStructEmbeddingGenerated{E: 1})
+	c.Check(err, IsNil)
+	c.Check(b, DeepEquals, testutil.DecodeHexString(c, "000000000000000000000001"))
+}
+
+func (s *codecSuite) TestMarshalGeneratedError(c *C) {
+	_, err := MarshalGenerated(testStructWithSizedField{B: &testStruct{D: make([]uint32, 20000)}})
+	c.Check(err, Equals, ErrNotGenerated)
+}
+
+func (s *codecSuite) TestUnmarshalGeneratedStruct(c *C) {
+	var u32 uint32 = 657763432
+	var x testStruct
+	n, err := UnmarshalGenerated(testutil.DecodeHexString(c, "dc042734ac680100000002000010880008a9e9"), &x)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 19)
+	c.Check(x, DeepEquals, testStruct{56324, &u32, true, []uint32{4232, 567785}})
+}
+
+func (s *codecSuite) TestUnmarshalGeneratedSized(c *C) {
+	var x *testStruct
+	n, err := UnmarshalGenerated(testutil.DecodeHexString(c, "000b0001000000000000000000"), Sized(&x))
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 13)
+	c.Check(x, DeepEquals, &testStruct{A: 1, B: new(uint32), D: []uint32{}})
+}
+
+func (s *codecSuite) TestUnmarshalGeneratedInvalidSelector(c *C) {
+	var x testUnionContainer
+	_, err := UnmarshalGenerated(testutil.DecodeHexString(c, "00000103"), &x)
+	c.Check(err, ErrorMatches, "invalid selector value: 259")
+}
+
+func (s *codecSuite) TestUnmarshalGeneratedTruncated(c *C) {
+	var x testStruct
+	_, err := UnmarshalGenerated(testutil.DecodeHexString(c, "dc042734ac680100000002000010880008a9"), &x)
+	c.Check(err, ErrorMatches, "unexpected EOF")
+}
+
+func (s *codecSuite) testUnmarshalFromReaderConsumesBytes(c *C, r Reader) {
+	var x, y testStruct
+	n, err := UnmarshalFromReader(r, &x)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 11)
+	n, err = UnmarshalFromReader(r, &y)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 11)
+	c.Check(r.Len(), Equals, 1)
+	c.Check(x.A, Equals, uint16(1))
+	c.Check(y.A, Equals, uint16(2))
+}
+
+func (s *codecSuite) TestUnmarshalFromBytesReaderConsumesBytes(c *C) {
+	b := testutil.DecodeHexString(c, "0001000000000000000000"+"0002000000000000000000"+"ff")
+	s.testUnmarshalFromReaderConsumesBytes(c, bytes.NewReader(b))
+}
+
+func (s *codecSuite) TestUnmarshalFromBytesBufferConsumesBytes(c *C) {
+	b := testutil.DecodeHexString(c, "0001000000000000000000"+"0002000000000000000000"+"ff")
+	s.testUnmarshalFromReaderConsumesBytes(c, bytes.NewBuffer(b))
+}
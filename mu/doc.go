@@ -36,5 +36,16 @@ The marshalling code parses the "tpm2" tag on struct fields, the value of which
  * raw - used on slice fields to indicate that it should be marshalled and unmarshalled without a length (if it represents a list)
  or size (if it represents a sized buffer) field. The slice must be pre-allocated to the correct length by the caller during
  unmarshalling.
+
+The marshalling code uses reflection by default. For better performance, the mugen tool (github.com/canonical/go-tpm2/cmd/mugen)
+can generate code that marshals and unmarshals the types in a package without reflection. The generated code is used when all
+of the values supplied to one of the marshalling or unmarshalling functions are supported by it, and produces identical
+results. If the generated code fails, the operation is repeated using reflection so that the returned error describes the
+failure fully. The tpm2 package uses generated code for its types.
+
+The size and length fields of sized buffers and lists are trusted by UnmarshalFromReader and UnmarshalFromBytes. When
+unmarshalling data from an untrusted source, UnmarshalFromReaderWithOptions and UnmarshalFromBytesWithOptions can be used
+to limit the total number of bytes consumed, the length of lists and the size of sized values, and to reject input that
+contains trailing bytes.
 */
 package mu
@@ -0,0 +1,31 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package mu
+
+import (
+	"bytes"
+)
+
+func DisableGenerated() (restore func()) {
+	orig := disableGenerated
+	disableGenerated = true
+	return func() {
+		disableGenerated = orig
+	}
+}
+
+var ErrNotGenerated = errNotGenerated
+
+func MarshalGenerated(vals ...interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := marshalGenerated(buf, vals...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func UnmarshalGenerated(b []byte, vals ...interface{}) (int, error) {
+	return unmarshalGenerated(b, nil, vals...)
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build go1.18
+// +build go1.18
+
+package mu_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/canonical/go-tpm2/mu"
+)
+
+var fuzzUnmarshalOptions = UnmarshalOptions{
+	MaxBytes:       256,
+	MaxListLength:  16,
+	MaxSizedLength: 64}
+
+type fuzzValues struct {
+	A testStruct
+	B testUnionContainer
+	C testStructWithCustomMarshaller
+	D []byte
+}
+
+func (v *fuzzValues) unmarshal(data []byte) (int, error) {
+	return UnmarshalFromBytesWithOptions(data, &fuzzUnmarshalOptions, &v.A, &v.B, &v.C, &v.D)
+}
+
+func FuzzUnmarshalFromBytesWithOptions(f *testing.F) {
+	var u32 uint32 = 657763432
+	f.Add(MustMarshalToBytes(
+		testStruct{56324, &u32, true, []uint32{4232, 567785}},
+		testUnionContainer{Select: 2, Union: &testUnion{B: []uint32{3287743, 98731}}},
+		testStructWithCustomMarshaller{A: 44332, B: []uint32{885432, 31287554}},
+		[]byte{1, 2, 3, 4}))
+	f.Add(MustMarshalToBytes(
+		testStruct{},
+		testUnionContainer{Select: 1, Union: &testUnion{A: &testStruct{A: 10}}},
+		testStructWithCustomMarshaller{},
+		[]byte(nil)))
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var generated fuzzValues
+		n, err := generated.unmarshal(data)
+		if int64(n) > fuzzUnmarshalOptions.MaxBytes {
+			t.Errorf("consumed %d bytes", n)
+		}
+		if err == nil {
+			if len(generated.A.D) > fuzzUnmarshalOptions.MaxListLength || len(generated.C.B) > fuzzUnmarshalOptions.MaxListLength {
+				t.Errorf("list length limit not applied")
+			}
+			if len(generated.D) > fuzzUnmarshalOptions.MaxSizedLength {
+				t.Errorf("sized length limit not applied")
+			}
+		}
+
+		restore := DisableGenerated()
+		defer restore()
+
+		var reflective fuzzValues
+		n2, err2 := reflective.unmarshal(data)
+		if n2 != n {
+			t.Errorf("generated and reflection based unmarshallers consumed a different number of bytes (%d vs %d)", n, n2)
+		}
+		switch {
+		case (err == nil) != (err2 == nil):
+			t.Errorf("generated and reflection based unmarshallers returned different errors (%v vs %v)", err, err2)
+		case err != nil && err.Error() != err2.Error():
+			t.Errorf("generated and reflection based unmarshallers returned different errors (%v vs %v)", err, err2)
+		case err == nil && !reflect.DeepEqual(generated, reflective):
+			t.Errorf("generated and reflection based unmarshallers produced different values")
+		}
+	})
+}
@@ -37,6 +37,75 @@ func (e *InvalidSelectorError) Error() string {
 	return fmt.Sprintf("invalid selector value: %v", e.Selector)
 }
 
+// ErrTrailingBytes is returned from UnmarshalFromReaderWithOptions and UnmarshalFromBytesWithOptions in strict
+// mode when the input contains bytes after the unmarshalled values.
+var ErrTrailingBytes = errors.New("unexpected trailing bytes")
+
+// LimitError may be returned as a wrapped error from UnmarshalFromReaderWithOptions and UnmarshalFromBytesWithOptions
+// when the input exceeds one of the limits specified in UnmarshalOptions.
+type LimitError struct {
+	Limit string // The name of the limit that was exceeded
+	Max   int64  // The value of the limit that was exceeded
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeds the limit of %d", e.Limit, e.Max)
+}
+
+// UnmarshalOptions specifies limits to apply when unmarshalling data from untrusted sources, such as
+// responses from a TPM or serialized data read from a file. A zero value for any limit means that it
+// isn't applied.
+type UnmarshalOptions struct {
+	// MaxBytes is the maximum number of bytes that may be consumed from the input.
+	MaxBytes int64
+
+	// MaxListLength is the maximum number of elements permitted in a list.
+	MaxListLength int
+
+	// MaxSizedLength is the maximum size of the payload of a sized value.
+	MaxSizedLength int
+
+	// Strict indicates that the input must not contain any bytes after the unmarshalled values.
+	Strict bool
+}
+
+func (o *UnmarshalOptions) checkListLength(n uint32) error {
+	if o == nil || o.MaxListLength <= 0 || int64(n) <= int64(o.MaxListLength) {
+		return nil
+	}
+	return &LimitError{Limit: "list length", Max: int64(o.MaxListLength)}
+}
+
+func (o *UnmarshalOptions) checkSizedLength(n uint16) error {
+	if o == nil || o.MaxSizedLength <= 0 || int(n) <= o.MaxSizedLength {
+		return nil
+	}
+	return &LimitError{Limit: "sized value size", Max: int64(o.MaxSizedLength)}
+}
+
+// limitReader is an io.Reader that returns a *LimitError if more than the specified number
+// of bytes are read from it.
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (l *limitReader) Read(data []byte) (n int, err error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if l.n <= 0 {
+		return 0, &LimitError{Limit: "total size", Max: l.max}
+	}
+	if int64(len(data)) > l.n {
+		data = data[:l.n]
+	}
+	n, err = l.r.Read(data)
+	l.n -= int64(n)
+	return n, err
+}
+
 type customMuIface interface {
 	CustomMarshaller
 	CustomUnmarshaller
@@ -645,6 +714,7 @@ type Reader interface {
 type unmarshaller struct {
 	*context
 	r      io.Reader
+	opts   *UnmarshalOptions
 	sz     int64
 	nbytes int
 }
@@ -675,6 +745,8 @@ func startingSizeOfReader(r io.Reader) (int64, error) {
 		}
 	case *bytes.Reader:
 		return int64(rImpl.Len()), nil
+	case *Decoder:
+		return int64(rImpl.Len()), nil
 	case *bytes.Buffer:
 		return int64(rImpl.Len()), nil
 	case *io.SectionReader:
@@ -689,16 +761,27 @@ func startingSizeOfReader(r io.Reader) (int64, error) {
 			sz = rImpl.N
 		}
 		return sz, nil
+	case *limitReader:
+		sz, err := startingSizeOfReader(rImpl.r)
+		if err != nil {
+			return 0, err
+		}
+		if rImpl.n < sz {
+			sz = rImpl.n
+		}
+		return sz, nil
+	case *unmarshaller:
+		return int64(rImpl.Len()), nil
 	}
 	return 1<<63 - 1, nil
 }
 
-func makeUnmarshaller(ctx *context, r io.Reader) (*unmarshaller, error) {
+func makeUnmarshaller(ctx *context, r io.Reader, opts *UnmarshalOptions) (*unmarshaller, error) {
 	sz, err := startingSizeOfReader(r)
 	if err != nil {
 		return nil, err
 	}
-	return &unmarshaller{context: ctx, r: r, sz: sz}, nil
+	return &unmarshaller{context: ctx, r: r, opts: opts, sz: sz}, nil
 }
 
 func (u *unmarshaller) unmarshalSized(v reflect.Value) error {
@@ -709,6 +792,9 @@ func (u *unmarshaller) unmarshalSized(v reflect.Value) error {
 	if err := binary.Read(u, binary.BigEndian, &size); err != nil {
 		return newError(v, u.context, err)
 	}
+	if err := u.opts.checkSizedLength(size); err != nil {
+		return newError(v, u.context, err)
+	}
 
 	switch {
 	case size == 0 && !v.IsNil() && v.Kind() == reflect.Ptr:
@@ -721,7 +807,7 @@ func (u *unmarshaller) unmarshalSized(v reflect.Value) error {
 		v.Set(reflect.MakeSlice(v.Type(), int(size), int(size)))
 	}
 
-	su, err := makeUnmarshaller(u.context, io.LimitReader(u, int64(size)))
+	su, err := makeUnmarshaller(u.context, io.LimitReader(u, int64(size)), u.opts)
 	if err != nil {
 		return newError(v, u.context, xerrors.Errorf("cannot create new reader for sized payload: %w", err))
 	}
@@ -775,8 +861,19 @@ func (u *unmarshaller) unmarshalList(v reflect.Value) error {
 		return newError(v, u.context, err)
 	}
 
+	if err := u.opts.checkListLength(length); err != nil {
+		return newError(v, u.context, err)
+	}
+
 	if v.IsNil() || v.Cap() < int(length) {
-		v.Set(reflect.MakeSlice(v.Type(), 0, int(length)))
+		// Don't trust the length when deciding how much to allocate up front - TPM
+		// list elements are never zero sized, so there can't be more elements than
+		// there are remaining bytes.
+		n := int(length)
+		if rem := u.Len(); rem >= 0 && rem < n {
+			n = rem
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 0, n))
 	}
 
 	s, err := u.unmarshalRawList(v.Slice(0, 0), int(length))
@@ -864,6 +961,10 @@ func marshalToWriter(skip int, w io.Writer, vals ...interface{}) (int, error) {
 	var caller [1]uintptr
 	runtime.Callers(skip+1, caller[:])
 
+	if n, err := marshalGenerated(w, vals...); err == nil {
+		return n, nil
+	}
+
 	m := &marshaller{context: &context{caller: caller, mode: "marshal"}, w: w}
 	return m.marshal(vals...)
 }
@@ -924,7 +1025,7 @@ func MustMarshalToBytes(vals ...interface{}) []byte {
 	return b
 }
 
-func unmarshalFromReader(skip int, r io.Reader, vals ...interface{}) (int, error) {
+func unmarshalFromReader(skip int, r io.Reader, opts *UnmarshalOptions, vals ...interface{}) (int, error) {
 	var caller [1]uintptr
 	runtime.Callers(skip+1, caller[:])
 
@@ -939,11 +1040,47 @@ func unmarshalFromReader(skip int, r io.Reader, vals ...interface{}) (int, error
 		}
 	}
 
-	u, err := makeUnmarshaller(&context{caller: caller, mode: "unmarshal"}, r)
+	if opts == nil {
+		// Inherit the limits from the original call if this is a recursive call from
+		// an implementation of CustomUnmarshaller. The original call enforces its own
+		// MaxBytes and Strict options.
+		var parent *UnmarshalOptions
+		switch r := r.(type) {
+		case *unmarshaller:
+			parent = r.opts
+		case *Decoder:
+			parent = r.opts
+		}
+		if parent != nil {
+			opts = &UnmarshalOptions{MaxListLength: parent.MaxListLength, MaxSizedLength: parent.MaxSizedLength}
+		}
+	}
+
+	n, err := unmarshalGeneratedFromReader(r, opts, vals...)
 	if err != nil {
-		return 0, err
+		lr := r
+		if opts != nil && opts.MaxBytes > 0 {
+			lr = &limitReader{r: r, n: opts.MaxBytes, max: opts.MaxBytes}
+		}
+
+		u, err := makeUnmarshaller(&context{caller: caller, mode: "unmarshal"}, lr, opts)
+		if err != nil {
+			return 0, err
+		}
+		n, err = u.unmarshal(vals...)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if opts != nil && opts.Strict {
+		var b [1]byte
+		if m, _ := io.ReadFull(r, b[:]); m > 0 {
+			return n, ErrTrailingBytes
+		}
 	}
-	return u.unmarshal(vals...)
+
+	return n, nil
 }
 
 // UnmarshalFromReader unmarshals data in the TPM wire format from r to vals, according to the rules specified in the package
@@ -964,7 +1101,7 @@ func unmarshalFromReader(skip int, r io.Reader, vals ...interface{}) (int, error
 // The number of bytes read from r are returned. If this function does not complete successfully, it will return an error and
 // the number of bytes read. In this case, partial results may have been unmarshalled to the supplied destination values.
 func UnmarshalFromReader(r io.Reader, vals ...interface{}) (int, error) {
-	return unmarshalFromReader(2, r, vals...)
+	return unmarshalFromReader(2, r, nil, vals...)
 }
 
 // UnmarshalFromReader unmarshals data in the TPM wire format from b to vals, according to the rules specified in the package
@@ -985,8 +1122,28 @@ func UnmarshalFromReader(r io.Reader, vals ...interface{}) (int, error) {
 // The number of bytes consumed from b are returned. If this function does not complete successfully, it will return an error and
 // the number of bytes consumed. In this case, partial results may have been unmarshalled to the supplied destination values.
 func UnmarshalFromBytes(b []byte, vals ...interface{}) (int, error) {
-	buf := bytes.NewReader(b)
-	return unmarshalFromReader(2, buf, vals...)
+	return unmarshalFromReader(2, &Decoder{buf: b}, nil, vals...)
+}
+
+// UnmarshalFromReaderWithOptions is the same as UnmarshalFromReader, except that it applies the limits
+// specified in opts, which should be used when r is from an untrusted source. If one of the limits is
+// exceeded, a *LimitError will be returned, wrapped in an *Error if it occurred whilst unmarshalling a
+// value.
+//
+// If opts.Strict is true, ErrTrailingBytes is returned if r contains more bytes after unmarshalling
+// vals. Note that this check consumes a byte from r if it is not at the end of its input.
+func UnmarshalFromReaderWithOptions(r io.Reader, opts *UnmarshalOptions, vals ...interface{}) (int, error) {
+	return unmarshalFromReader(2, r, opts, vals...)
+}
+
+// UnmarshalFromBytesWithOptions is the same as UnmarshalFromBytes, except that it applies the limits
+// specified in opts, which should be used when b is from an untrusted source. If one of the limits is
+// exceeded, a *LimitError will be returned, wrapped in an *Error if it occurred whilst unmarshalling a
+// value.
+//
+// If opts.Strict is true, ErrTrailingBytes is returned if b is not fully consumed.
+func UnmarshalFromBytesWithOptions(b []byte, opts *UnmarshalOptions, vals ...interface{}) (int, error) {
+	return unmarshalFromReader(2, &Decoder{buf: b}, opts, vals...)
 }
 
 func copyValue(skip int, dst, src interface{}) error {
@@ -994,7 +1151,7 @@ func copyValue(skip int, dst, src interface{}) error {
 	if _, err := marshalToWriter(skip+1, buf, src); err != nil {
 		return err
 	}
-	_, err := unmarshalFromReader(skip+1, buf, dst)
+	_, err := unmarshalFromReader(skip+1, buf, nil, dst)
 	return err
 }
 
@@ -0,0 +1,524 @@
+// Code generated by mugen. DO NOT EDIT.
+
+package mu_test
+
+import (
+	"reflect"
+
+	"github.com/canonical/go-tpm2/mu"
+)
+
+func init() {
+	mu.RegisterGeneratedTypes(
+		(*fuzzValues)(nil),
+		(*testSizedBuffer)(nil),
+		(*testStruct)(nil),
+		(*testStructContainingCustomType)(nil),
+		(*testStructWithRawByteField)(nil),
+		(*testStructWithRawListField)(nil),
+		(*testStructWithRawTagFields)(nil),
+		(*testStructWithRawTagSizedFields)(nil),
+		(*testStructWithSizedField)(nil),
+		(*testStructWithSizedField2)(nil),
+		(*testUnionContainer)(nil),
+		(*testUnionContainer2)(nil),
+	)
+}
+
+func (v fuzzValues) MarshalMu(e *mu.Encoder) error {
+	if err := v.A.MarshalMu(e); err != nil {
+		return err
+	}
+	if err := v.B.MarshalMu(e); err != nil {
+		return err
+	}
+	if err := v.C.Marshal(e); err != nil {
+		return err
+	}
+	if v.D == nil {
+		e.WriteUint16(0)
+	} else {
+		start1 := e.BeginSized()
+		e.WriteBytes(v.D)
+		if err := e.EndSized(start1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *fuzzValues) UnmarshalMu(d *mu.Decoder) error {
+	if err := v.A.UnmarshalMu(d); err != nil {
+		return err
+	}
+	if err := v.B.UnmarshalMu(d); err != nil {
+		return err
+	}
+	if err := v.C.Unmarshal(d); err != nil {
+		return err
+	}
+	{
+		n2, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n2 > 0 {
+			v.D = make([]byte, n2)
+			if err := d.ReadBytes(v.D); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v testSizedBuffer) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start3 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start3); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *testSizedBuffer) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n4, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n4 > 0 {
+			(*v) = make(testSizedBuffer, n4)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v testStruct) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.A))
+	{
+		p5 := v.B
+		if p5 == nil {
+			p5 = new(uint32)
+		}
+		e.WriteUint32(uint32((*p5)))
+	}
+	e.WriteBool(bool(v.C))
+	if err := e.WriteListLength(len(v.D)); err != nil {
+		return err
+	}
+	for i6 := range v.D {
+		e.WriteUint32(uint32(v.D[i6]))
+	}
+	return nil
+}
+
+func (v *testStruct) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x7, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.A = uint16(x7)
+	}
+	if v.B == nil {
+		v.B = new(uint32)
+	}
+	{
+		x8, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v.B) = uint32(x8)
+	}
+	{
+		x9, err := d.ReadBool()
+		if err != nil {
+			return err
+		}
+		v.C = bool(x9)
+	}
+	{
+		n10, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if v.D == nil || cap(v.D) < n10 {
+			v.D = make([]uint32, 0, n10)
+		}
+		v.D = v.D[:0]
+		for i11 := 0; i11 < n10; i11++ {
+			var zero12 uint32
+			v.D = append(v.D, zero12)
+			{
+				x13, err := d.ReadUint32()
+				if err != nil {
+					return err
+				}
+				v.D[i11] = uint32(x13)
+			}
+		}
+	}
+	return nil
+}
+
+func (v testStructContainingCustomType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.A))
+	{
+		p14 := v.X
+		if p14 == nil {
+			p14 = new(testStructWithCustomMarshaller)
+		}
+		if err := (*p14).Marshal(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *testStructContainingCustomType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x15, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.A = uint32(x15)
+	}
+	if v.X == nil {
+		v.X = new(testStructWithCustomMarshaller)
+	}
+	if err := (*v.X).Unmarshal(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v testStructWithRawByteField) MarshalMu(e *mu.Encoder) error {
+	e.WriteBytes(v.A)
+	return nil
+}
+
+func (v *testStructWithRawByteField) UnmarshalMu(d *mu.Decoder) error {
+	if err := d.ReadBytes(v.A); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v testStructWithRawListField) MarshalMu(e *mu.Encoder) error {
+	for i16 := range v.A {
+		e.WriteUint16(uint16(v.A[i16]))
+	}
+	return nil
+}
+
+func (v *testStructWithRawListField) UnmarshalMu(d *mu.Decoder) error {
+	for i17 := range v.A {
+		var zero18 uint16
+		v.A[i17] = zero18
+		{
+			x19, err := d.ReadUint16()
+			if err != nil {
+				return err
+			}
+			v.A[i17] = uint16(x19)
+		}
+	}
+	return nil
+}
+
+func (v testStructWithRawTagFields) MarshalMu(e *mu.Encoder) error {
+	for i20 := range v.A {
+		e.WriteUint16(uint16(v.A[i20]))
+	}
+	e.WriteBytes(v.B)
+	return nil
+}
+
+func (v *testStructWithRawTagFields) UnmarshalMu(d *mu.Decoder) error {
+	for i21 := range v.A {
+		var zero22 uint16
+		v.A[i21] = zero22
+		{
+			x23, err := d.ReadUint16()
+			if err != nil {
+				return err
+			}
+			v.A[i21] = uint16(x23)
+		}
+	}
+	if err := d.ReadBytes(v.B); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v testStructWithRawTagSizedFields) MarshalMu(e *mu.Encoder) error {
+	for i24 := range v.A {
+		if v.A[i24] == nil {
+			e.WriteUint16(0)
+		} else {
+			start25 := e.BeginSized()
+			e.WriteBytes(v.A[i24])
+			if err := e.EndSized(start25); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *testStructWithRawTagSizedFields) UnmarshalMu(d *mu.Decoder) error {
+	for i26 := range v.A {
+		var zero27 []byte
+		v.A[i26] = zero27
+		{
+			n28, err := d.ReadSize(false)
+			if err != nil {
+				return err
+			}
+			if n28 > 0 {
+				v.A[i26] = make([]byte, n28)
+				if err := d.ReadBytes(v.A[i26]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v testStructWithSizedField) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.A))
+	if v.B == nil {
+		e.WriteUint16(0)
+	} else {
+		start29 := e.BeginSized()
+		{
+			p30 := v.B
+			if p30 == nil {
+				p30 = new(testStruct)
+			}
+			if err := (*p30).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+		if err := e.EndSized(start29); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *testStructWithSizedField) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x31, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.A = uint32(x31)
+	}
+	{
+		n32, err := d.ReadSize(v.B != nil)
+		if err != nil {
+			return err
+		}
+		if n32 > 0 {
+			end33 := d.BeginSized(n32)
+			if v.B == nil {
+				v.B = new(testStruct)
+			}
+			if err := (*v.B).UnmarshalMu(d); err != nil {
+				return err
+			}
+			d.EndSized(end33)
+		}
+	}
+	return nil
+}
+
+func (v testStructWithSizedField2) MarshalMu(e *mu.Encoder) error {
+	if v.A == nil {
+		e.WriteUint16(0)
+	} else {
+		start34 := e.BeginSized()
+		{
+			p35 := v.A
+			if p35 == nil {
+				p35 = new(testStruct)
+			}
+			if err := (*p35).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+		if err := e.EndSized(start34); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *testStructWithSizedField2) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n36, err := d.ReadSize(v.A != nil)
+		if err != nil {
+			return err
+		}
+		if n36 > 0 {
+			end37 := d.BeginSized(n36)
+			if v.A == nil {
+				v.A = new(testStruct)
+			}
+			if err := (*v.A).UnmarshalMu(d); err != nil {
+				return err
+			}
+			d.EndSized(end37)
+		}
+	}
+	return nil
+}
+
+func (v *testUnion) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.A:
+		{
+			p38 := v.A
+			if p38 == nil {
+				p38 = new(testStruct)
+			}
+			if err := (*p38).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.B:
+		if err := e.WriteListLength(len(v.B)); err != nil {
+			return err
+		}
+		for i39 := range v.B {
+			e.WriteUint32(uint32(v.B[i39]))
+		}
+	case &v.C:
+		e.WriteUint16(uint16(v.C))
+	default:
+		panic("Union.Select implementation for type testUnion returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *testUnion) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.A:
+		if v.A == nil {
+			v.A = new(testStruct)
+		}
+		if err := (*v.A).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.B:
+		{
+			n40, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.B == nil || cap(v.B) < n40 {
+				v.B = make([]uint32, 0, n40)
+			}
+			v.B = v.B[:0]
+			for i41 := 0; i41 < n40; i41++ {
+				var zero42 uint32
+				v.B = append(v.B, zero42)
+				{
+					x43, err := d.ReadUint32()
+					if err != nil {
+						return err
+					}
+					v.B[i41] = uint32(x43)
+				}
+			}
+		}
+	case &v.C:
+		{
+			x44, err := d.ReadUint16()
+			if err != nil {
+				return err
+			}
+			v.C = uint16(x44)
+		}
+	default:
+		panic("Union.Select implementation for type testUnion returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v testUnionContainer) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Select))
+	{
+		p45 := v.Union
+		if p45 == nil {
+			p45 = new(testUnion)
+		}
+		if err := (*p45).marshalMu(e, reflect.ValueOf(v.Select)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *testUnionContainer) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x46, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Select = uint32(x46)
+	}
+	if v.Union == nil {
+		v.Union = new(testUnion)
+	}
+	if err := (*v.Union).unmarshalMu(d, reflect.ValueOf(v.Select)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v testUnionContainer2) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Select))
+	{
+		p47 := v.Union
+		if p47 == nil {
+			p47 = new(testUnion)
+		}
+		if err := (*p47).marshalMu(e, reflect.ValueOf(v.Select)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *testUnionContainer2) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x48, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Select = uint32(x48)
+	}
+	if v.Union == nil {
+		v.Union = new(testUnion)
+	}
+	if err := (*v.Union).unmarshalMu(d, reflect.ValueOf(v.Select)); err != nil {
+		return err
+	}
+	return nil
+}
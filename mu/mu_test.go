@@ -17,6 +17,8 @@ import (
 	. "gopkg.in/check.v1"
 )
 
+//go:generate go run ../cmd/mugen -tests -o mu_generated_test.go
+
 func init() {
 	testutil.AddCommandLineFlags()
 }
@@ -35,7 +37,47 @@ type testMarshalAndUnmarshalData struct {
 	unmarshalExpectedVals []interface{}
 }
 
+// testGeneratedMatchesReflection checks that the generated marshalling code produces the
+// same results as the reflection based marshalling code.
+func (s *muSuite) testGeneratedMatchesReflection(c *C, data *testMarshalAndUnmarshalData) {
+	generated, err := MarshalToBytes(data.values...)
+	c.Assert(err, IsNil)
+
+	var generatedDests []interface{}
+	for _, v := range data.values {
+		generatedDests = append(generatedDests, reflect.New(reflect.TypeOf(v)).Interface())
+	}
+	var generatedN int
+	var generatedErr error
+	if data.unmarshalDests == nil {
+		generatedN, generatedErr = UnmarshalFromBytes(generated, generatedDests...)
+	}
+
+	restore := DisableGenerated()
+	defer restore()
+
+	reflected, err := MarshalToBytes(data.values...)
+	c.Check(err, IsNil)
+	c.Check(reflected, DeepEquals, generated)
+
+	if data.unmarshalDests != nil {
+		// The test requires specific destination values.
+		return
+	}
+
+	var reflectedDests []interface{}
+	for _, v := range data.values {
+		reflectedDests = append(reflectedDests, reflect.New(reflect.TypeOf(v)).Interface())
+	}
+	reflectedN, reflectedErr := UnmarshalFromBytes(generated, reflectedDests...)
+	c.Check(generatedN, Equals, reflectedN)
+	c.Check(generatedErr, DeepEquals, reflectedErr)
+	c.Check(generatedDests, DeepEquals, reflectedDests)
+}
+
 func (s *muSuite) testMarshalAndUnmarshalBytes(c *C, data *testMarshalAndUnmarshalData) {
+	s.testGeneratedMatchesReflection(c, data)
+
 	out, err := MarshalToBytes(data.values...)
 	c.Check(err, IsNil)
 	c.Check(out, DeepEquals, data.expected)
@@ -772,6 +814,7 @@ type testMarshalErrorData struct {
 	err   string
 }
 
+//mugen:skip
 type testBrokenWriter struct{}
 
 func (*testBrokenWriter) Write(data []byte) (int, error) {
@@ -0,0 +1,170 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package mu_test
+
+import (
+	"bytes"
+	"io"
+
+	"golang.org/x/xerrors"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+type optionsSuite struct{}
+
+var _ = Suite(&optionsSuite{})
+
+// opaqueReader hides the type of the underlying reader, so that the size of the input is unknown.
+type opaqueReader struct {
+	r io.Reader
+}
+
+func (r *opaqueReader) Read(data []byte) (int, error) {
+	return r.r.Read(data)
+}
+
+// testWithAndWithoutGenerated runs fn with the generated code enabled and then disabled, as the
+// limits must be applied by both the generated and reflection based unmarshallers.
+func (s *optionsSuite) testWithAndWithoutGenerated(c *C, fn func()) {
+	fn()
+
+	restore := DisableGenerated()
+	defer restore()
+	fn()
+}
+
+func (s *optionsSuite) checkLimitError(c *C, err error, limit string, max int64) {
+	c.Assert(err, NotNil)
+	var e *LimitError
+	c.Assert(xerrors.As(err, &e), testutil.IsTrue)
+	c.Check(e.Limit, Equals, limit)
+	c.Check(e.Max, Equals, max)
+}
+
+func (s *optionsSuite) TestMaxListLength(c *C) {
+	b := MustMarshalToBytes(testStruct{D: []uint32{1, 2, 3}})
+
+	s.testWithAndWithoutGenerated(c, func() {
+		var v testStruct
+		_, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{MaxListLength: 2}, &v)
+		s.checkLimitError(c, err, "list length", 2)
+		c.Check(err, ErrorMatches, "cannot unmarshal argument whilst processing element of type \\[\\]uint32: list length exceeds the limit of 2\n\n"+
+			"=== BEGIN STACK ===\n"+
+			"... mu_test.testStruct field D\n"+
+			"=== END STACK ===\n")
+
+		n, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{MaxListLength: 3}, &v)
+		c.Check(err, IsNil)
+		c.Check(n, Equals, len(b))
+		c.Check(v.D, DeepEquals, []uint32{1, 2, 3})
+	})
+}
+
+func (s *optionsSuite) TestMaxSizedLength(c *C) {
+	b := MustMarshalToBytes(testutil.DecodeHexString(c, "00112233445566778899"))
+
+	s.testWithAndWithoutGenerated(c, func() {
+		var v []byte
+		_, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{MaxSizedLength: 8}, &v)
+		s.checkLimitError(c, err, "sized value size", 8)
+		c.Check(err, ErrorMatches, "cannot unmarshal argument whilst processing element of type \\[\\]uint8: sized value size exceeds the limit of 8")
+
+		n, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{MaxSizedLength: 10}, &v)
+		c.Check(err, IsNil)
+		c.Check(n, Equals, len(b))
+		c.Check(v, DeepEquals, testutil.DecodeHexString(c, "00112233445566778899"))
+	})
+}
+
+func (s *optionsSuite) TestMaxBytes(c *C) {
+	var u32 uint32 = 657763432
+	b := MustMarshalToBytes(testStruct{56324, &u32, true, []uint32{4232, 567785}})
+
+	s.testWithAndWithoutGenerated(c, func() {
+		var v testStruct
+		_, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{MaxBytes: 12}, &v)
+		s.checkLimitError(c, err, "total size", 12)
+
+		n, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{MaxBytes: int64(len(b))}, &v)
+		c.Check(err, IsNil)
+		c.Check(n, Equals, len(b))
+	})
+}
+
+func (s *optionsSuite) TestMaxBytesFromReader(c *C) {
+	b := MustMarshalToBytes(testStruct{D: []uint32{1, 2, 3}})
+
+	var v testStruct
+	_, err := UnmarshalFromReaderWithOptions(&opaqueReader{bytes.NewReader(b)}, &UnmarshalOptions{MaxBytes: 10}, &v)
+	s.checkLimitError(c, err, "total size", 10)
+}
+
+func (s *optionsSuite) TestLargeListLengthFromReader(c *C) {
+	// The list length here is 2^32-1, but the input is only 4 bytes. The preallocation
+	// of the list is limited by MaxBytes when the size of the reader is unknown.
+	b := testutil.DecodeHexString(c, "ffffffff00000001")
+
+	var v []uint32
+	_, err := UnmarshalFromReaderWithOptions(&opaqueReader{bytes.NewReader(b)}, &UnmarshalOptions{MaxBytes: 4096}, &v)
+	c.Check(err, ErrorMatches, "cannot unmarshal argument whilst processing element of type uint32: unexpected EOF\n\n"+
+		"=== BEGIN STACK ===\n"+
+		"... \\[\\]uint32 index 1\n"+
+		"=== END STACK ===\n")
+}
+
+func (s *optionsSuite) TestStrict(c *C) {
+	b := testutil.DecodeHexString(c, "0000000100000002")
+
+	s.testWithAndWithoutGenerated(c, func() {
+		var v uint32
+		n, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{Strict: true}, &v)
+		c.Check(err, Equals, ErrTrailingBytes)
+		c.Check(n, Equals, 4)
+
+		var w uint32
+		n, err = UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{Strict: true}, &v, &w)
+		c.Check(err, IsNil)
+		c.Check(n, Equals, 8)
+		c.Check(v, Equals, uint32(1))
+		c.Check(w, Equals, uint32(2))
+	})
+}
+
+func (s *optionsSuite) TestStrictFromReader(c *C) {
+	b := testutil.DecodeHexString(c, "0000000100000002")
+
+	var v uint32
+	_, err := UnmarshalFromReaderWithOptions(&opaqueReader{bytes.NewReader(b)}, &UnmarshalOptions{Strict: true}, &v)
+	c.Check(err, Equals, ErrTrailingBytes)
+
+	var w uint32
+	_, err = UnmarshalFromReaderWithOptions(&opaqueReader{bytes.NewReader(b)}, &UnmarshalOptions{Strict: true}, &v, &w)
+	c.Check(err, IsNil)
+}
+
+func (s *optionsSuite) TestLimitsInheritedByCustomType(c *C) {
+	b := MustMarshalToBytes(testStructWithCustomMarshaller{A: 44332, B: []uint32{885432, 31287554}})
+
+	s.testWithAndWithoutGenerated(c, func() {
+		var v testStructWithCustomMarshaller
+		_, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{MaxListLength: 1}, &v)
+		s.checkLimitError(c, err, "list length", 1)
+	})
+}
+
+func (s *optionsSuite) TestStrictNotInheritedByCustomType(c *C) {
+	b := MustMarshalToBytes(testStructWithCustomMarshaller{A: 44332, B: []uint32{885432, 31287554}})
+
+	s.testWithAndWithoutGenerated(c, func() {
+		var v testStructWithCustomMarshaller
+		n, err := UnmarshalFromBytesWithOptions(b, &UnmarshalOptions{Strict: true}, &v)
+		c.Check(err, IsNil)
+		c.Check(n, Equals, len(b))
+	})
+}
@@ -0,0 +1,6739 @@
+// Code generated by mugen. DO NOT EDIT.
+
+package tpm2
+
+import (
+	"reflect"
+
+	"github.com/canonical/go-tpm2/mu"
+)
+
+func init() {
+	mu.RegisterGeneratedTypes(
+		(*AlgorithmAttributes)(nil),
+		(*AlgorithmId)(nil),
+		(*AlgorithmList)(nil),
+		(*AlgorithmProperty)(nil),
+		(*AlgorithmPropertyList)(nil),
+		(*ArithmeticOp)(nil),
+		(*AsymParams)(nil),
+		(*AsymScheme)(nil),
+		(*AsymSchemeId)(nil),
+		(*Attest)(nil),
+		(*Auth)(nil),
+		(*AuthCommand)(nil),
+		(*AuthResponse)(nil),
+		(*Capability)(nil),
+		(*CapabilityData)(nil),
+		(*CertifyInfo)(nil),
+		(*ClockInfo)(nil),
+		(*CommandAttributes)(nil),
+		(*CommandAttributesList)(nil),
+		(*CommandAuditInfo)(nil),
+		(*CommandAuditLogRecord)(nil),
+		(*CommandAuditLogRecordType)(nil),
+		(*CommandCode)(nil),
+		(*CommandCodeList)(nil),
+		(*CommandHeader)(nil),
+		(*CommandPacket)(nil),
+		(*Context)(nil),
+		(*ContextData)(nil),
+		(*CreationData)(nil),
+		(*CreationInfo)(nil),
+		(*Data)(nil),
+		(*Derive)(nil),
+		(*Digest)(nil),
+		(*DigestList)(nil),
+		(*ECCCurve)(nil),
+		(*ECCCurveList)(nil),
+		(*ECCParameter)(nil),
+		(*ECCParams)(nil),
+		(*ECCPoint)(nil),
+		(*ECCScheme)(nil),
+		(*ECCSchemeId)(nil),
+		(*Empty)(nil),
+		(*EncSchemeOAEP)(nil),
+		(*EncSchemeRSAES)(nil),
+		(*EncryptedSecret)(nil),
+		(*ErrorCode)(nil),
+		(*Event)(nil),
+		(*Handle)(nil),
+		(*HandleList)(nil),
+		(*HandleType)(nil),
+		(*HashAlgorithmId)(nil),
+		(*IDObjectRaw)(nil),
+		(*KDFAlgorithmId)(nil),
+		(*KDFScheme)(nil),
+		(*KeySchemeECDH)(nil),
+		(*KeySchemeECMQV)(nil),
+		(*KeyedHashParams)(nil),
+		(*KeyedHashScheme)(nil),
+		(*KeyedHashSchemeId)(nil),
+		(*Label)(nil),
+		(*Locality)(nil),
+		(*MaxBuffer)(nil),
+		(*MaxNVBuffer)(nil),
+		(*MemoryAttributes)(nil),
+		(*ModeAttributes)(nil),
+		(*MssimServerFlags)(nil),
+		(*NVAttributes)(nil),
+		(*NVCertifyInfo)(nil),
+		(*NVPinCounterParams)(nil),
+		(*NVPublic)(nil),
+		(*NVType)(nil),
+		(*Name)(nil),
+		(*Nonce)(nil),
+		(*ObjectAttributes)(nil),
+		(*ObjectTypeId)(nil),
+		(*Operand)(nil),
+		(*PCRSelection)(nil),
+		(*PCRSelectionList)(nil),
+		(*PermanentAttributes)(nil),
+		(*Private)(nil),
+		(*PrivateKeyRSA)(nil),
+		(*PrivateVendorSpecific)(nil),
+		(*Property)(nil),
+		(*PropertyPCR)(nil),
+		(*Public)(nil),
+		(*PublicDerived)(nil),
+		(*PublicKeyRSA)(nil),
+		(*PublicParams)(nil),
+		(*QuoteInfo)(nil),
+		(*RSAParams)(nil),
+		(*RSAScheme)(nil),
+		(*RSASchemeId)(nil),
+		(*ResponseCode)(nil),
+		(*ResponseHeader)(nil),
+		(*ResponsePacket)(nil),
+		(*SchemeECDAA)(nil),
+		(*SchemeHMAC)(nil),
+		(*SchemeHash)(nil),
+		(*SchemeKDF1_SP800_108)(nil),
+		(*SchemeKDF1_SP800_56A)(nil),
+		(*SchemeKDF2)(nil),
+		(*SchemeMGF1)(nil),
+		(*SchemeXOR)(nil),
+		(*Sensitive)(nil),
+		(*SensitiveCreate)(nil),
+		(*SensitiveData)(nil),
+		(*SessionAttributes)(nil),
+		(*SessionAuditEntry)(nil),
+		(*SessionAuditInfo)(nil),
+		(*SessionType)(nil),
+		(*SigScheme)(nil),
+		(*SigSchemeECDAA)(nil),
+		(*SigSchemeECDSA)(nil),
+		(*SigSchemeECSCHNORR)(nil),
+		(*SigSchemeId)(nil),
+		(*SigSchemeRSAPSS)(nil),
+		(*SigSchemeRSASSA)(nil),
+		(*SigSchemeSM2)(nil),
+		(*Signature)(nil),
+		(*SignatureECC)(nil),
+		(*SignatureECDAA)(nil),
+		(*SignatureECDSA)(nil),
+		(*SignatureECSCHNORR)(nil),
+		(*SignatureRSA)(nil),
+		(*SignatureRSAPSS)(nil),
+		(*SignatureRSASSA)(nil),
+		(*SignatureSM2)(nil),
+		(*StartupClearAttributes)(nil),
+		(*StartupType)(nil),
+		(*StructTag)(nil),
+		(*SwtpmBlobType)(nil),
+		(*SwtpmCapabilities)(nil),
+		(*SwtpmInitFlags)(nil),
+		(*SymAlgorithmId)(nil),
+		(*SymCipherParams)(nil),
+		(*SymDef)(nil),
+		(*SymDefObject)(nil),
+		(*SymKey)(nil),
+		(*SymModeId)(nil),
+		(*SymObjectAlgorithmId)(nil),
+		(*TPMGenerated)(nil),
+		(*TPMManufacturer)(nil),
+		(*TaggedHashList)(nil),
+		(*TaggedPCRPropertyList)(nil),
+		(*TaggedPCRSelect)(nil),
+		(*TaggedPolicy)(nil),
+		(*TaggedPolicyList)(nil),
+		(*TaggedProperty)(nil),
+		(*TaggedTPMPropertyList)(nil),
+		(*Template)(nil),
+		(*TimeAttestInfo)(nil),
+		(*TimeInfo)(nil),
+		(*Timeout)(nil),
+		(*TkAuth)(nil),
+		(*TkCreation)(nil),
+		(*TkHashcheck)(nil),
+		(*TkVerified)(nil),
+		(*WarningCode)(nil),
+		(*handleContext)(nil),
+		(*handleContextType)(nil),
+		(*policyHMACType)(nil),
+		(*sessionContextData)(nil),
+	)
+}
+
+func (v AlgorithmAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *AlgorithmAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x1, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = AlgorithmAttributes(x1)
+	}
+	return nil
+}
+
+func (v AlgorithmId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *AlgorithmId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x2, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = AlgorithmId(x2)
+	}
+	return nil
+}
+
+func (v AlgorithmList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i3 := range v {
+		e.WriteUint16(uint16(v[i3]))
+	}
+	return nil
+}
+
+func (v *AlgorithmList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n4, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n4 {
+			(*v) = make(AlgorithmList, 0, n4)
+		}
+		(*v) = (*v)[:0]
+		for i5 := 0; i5 < n4; i5++ {
+			var zero6 AlgorithmId
+			(*v) = append((*v), zero6)
+			{
+				x7, err := d.ReadUint16()
+				if err != nil {
+					return err
+				}
+				(*v)[i5] = AlgorithmId(x7)
+			}
+		}
+	}
+	return nil
+}
+
+func (v AlgorithmProperty) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Alg))
+	e.WriteUint32(uint32(v.Properties))
+	return nil
+}
+
+func (v *AlgorithmProperty) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x8, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Alg = AlgorithmId(x8)
+	}
+	{
+		x9, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Properties = AlgorithmAttributes(x9)
+	}
+	return nil
+}
+
+func (v AlgorithmPropertyList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i10 := range v {
+		if err := v[i10].MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *AlgorithmPropertyList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n11, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n11 {
+			(*v) = make(AlgorithmPropertyList, 0, n11)
+		}
+		(*v) = (*v)[:0]
+		for i12 := 0; i12 < n11; i12++ {
+			var zero13 AlgorithmProperty
+			(*v) = append((*v), zero13)
+			if err := (*v)[i12].UnmarshalMu(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v ArithmeticOp) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *ArithmeticOp) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x14, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = ArithmeticOp(x14)
+	}
+	return nil
+}
+
+func (v AsymParams) MarshalMu(e *mu.Encoder) error {
+	if err := v.Symmetric.MarshalMu(e); err != nil {
+		return err
+	}
+	if err := v.Scheme.MarshalMu(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *AsymParams) UnmarshalMu(d *mu.Decoder) error {
+	if err := v.Symmetric.UnmarshalMu(d); err != nil {
+		return err
+	}
+	if err := v.Scheme.UnmarshalMu(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v AsymScheme) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Scheme))
+	{
+		p15 := v.Details
+		if p15 == nil {
+			p15 = new(AsymSchemeU)
+		}
+		if err := (*p15).marshalMu(e, reflect.ValueOf(v.Scheme)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *AsymScheme) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x16, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Scheme = AsymSchemeId(x16)
+	}
+	if v.Details == nil {
+		v.Details = new(AsymSchemeU)
+	}
+	if err := (*v.Details).unmarshalMu(d, reflect.ValueOf(v.Scheme)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v AsymSchemeId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *AsymSchemeId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x17, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = AsymSchemeId(x17)
+	}
+	return nil
+}
+
+func (v *AsymSchemeU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.RSASSA:
+		{
+			p18 := v.RSASSA
+			if p18 == nil {
+				p18 = new(SigSchemeRSASSA)
+			}
+			if err := (*p18).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.RSAES:
+		{
+			p19 := v.RSAES
+			if p19 == nil {
+				p19 = new(EncSchemeRSAES)
+			}
+			if err := (*p19).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.RSAPSS:
+		{
+			p20 := v.RSAPSS
+			if p20 == nil {
+				p20 = new(SigSchemeRSAPSS)
+			}
+			if err := (*p20).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.OAEP:
+		{
+			p21 := v.OAEP
+			if p21 == nil {
+				p21 = new(EncSchemeOAEP)
+			}
+			if err := (*p21).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECDSA:
+		{
+			p22 := v.ECDSA
+			if p22 == nil {
+				p22 = new(SigSchemeECDSA)
+			}
+			if err := (*p22).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECDH:
+		{
+			p23 := v.ECDH
+			if p23 == nil {
+				p23 = new(KeySchemeECDH)
+			}
+			if err := (*p23).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECDAA:
+		{
+			p24 := v.ECDAA
+			if p24 == nil {
+				p24 = new(SigSchemeECDAA)
+			}
+			if err := (*p24).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.SM2:
+		{
+			p25 := v.SM2
+			if p25 == nil {
+				p25 = new(SigSchemeSM2)
+			}
+			if err := (*p25).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECSCHNORR:
+		{
+			p26 := v.ECSCHNORR
+			if p26 == nil {
+				p26 = new(SigSchemeECSCHNORR)
+			}
+			if err := (*p26).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECMQV:
+		{
+			p27 := v.ECMQV
+			if p27 == nil {
+				p27 = new(KeySchemeECMQV)
+			}
+			if err := (*p27).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type AsymSchemeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *AsymSchemeU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.RSASSA:
+		if v.RSASSA == nil {
+			v.RSASSA = new(SigSchemeRSASSA)
+		}
+		if err := (*v.RSASSA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.RSAES:
+		if v.RSAES == nil {
+			v.RSAES = new(EncSchemeRSAES)
+		}
+		if err := (*v.RSAES).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.RSAPSS:
+		if v.RSAPSS == nil {
+			v.RSAPSS = new(SigSchemeRSAPSS)
+		}
+		if err := (*v.RSAPSS).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.OAEP:
+		if v.OAEP == nil {
+			v.OAEP = new(EncSchemeOAEP)
+		}
+		if err := (*v.OAEP).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECDSA:
+		if v.ECDSA == nil {
+			v.ECDSA = new(SigSchemeECDSA)
+		}
+		if err := (*v.ECDSA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECDH:
+		if v.ECDH == nil {
+			v.ECDH = new(KeySchemeECDH)
+		}
+		if err := (*v.ECDH).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECDAA:
+		if v.ECDAA == nil {
+			v.ECDAA = new(SigSchemeECDAA)
+		}
+		if err := (*v.ECDAA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.SM2:
+		if v.SM2 == nil {
+			v.SM2 = new(SigSchemeSM2)
+		}
+		if err := (*v.SM2).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECSCHNORR:
+		if v.ECSCHNORR == nil {
+			v.ECSCHNORR = new(SigSchemeECSCHNORR)
+		}
+		if err := (*v.ECSCHNORR).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECMQV:
+		if v.ECMQV == nil {
+			v.ECMQV = new(KeySchemeECMQV)
+		}
+		if err := (*v.ECMQV).UnmarshalMu(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type AsymSchemeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v Attest) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Magic))
+	e.WriteUint16(uint16(v.Type))
+	if v.QualifiedSigner == nil {
+		e.WriteUint16(0)
+	} else {
+		start28 := e.BeginSized()
+		e.WriteBytes(v.QualifiedSigner)
+		if err := e.EndSized(start28); err != nil {
+			return err
+		}
+	}
+	if v.ExtraData == nil {
+		e.WriteUint16(0)
+	} else {
+		start29 := e.BeginSized()
+		e.WriteBytes(v.ExtraData)
+		if err := e.EndSized(start29); err != nil {
+			return err
+		}
+	}
+	if err := v.ClockInfo.MarshalMu(e); err != nil {
+		return err
+	}
+	e.WriteUint64(uint64(v.FirmwareVersion))
+	{
+		p30 := v.Attested
+		if p30 == nil {
+			p30 = new(AttestU)
+		}
+		if err := (*p30).marshalMu(e, reflect.ValueOf(v.Type)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Attest) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x31, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Magic = TPMGenerated(x31)
+	}
+	{
+		x32, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Type = StructTag(x32)
+	}
+	{
+		n33, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n33 > 0 {
+			v.QualifiedSigner = make(Name, n33)
+			if err := d.ReadBytes(v.QualifiedSigner); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n34, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n34 > 0 {
+			v.ExtraData = make(Data, n34)
+			if err := d.ReadBytes(v.ExtraData); err != nil {
+				return err
+			}
+		}
+	}
+	if err := v.ClockInfo.UnmarshalMu(d); err != nil {
+		return err
+	}
+	{
+		x35, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		v.FirmwareVersion = uint64(x35)
+	}
+	if v.Attested == nil {
+		v.Attested = new(AttestU)
+	}
+	if err := (*v.Attested).unmarshalMu(d, reflect.ValueOf(v.Type)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *AttestU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.Certify:
+		{
+			p36 := v.Certify
+			if p36 == nil {
+				p36 = new(CertifyInfo)
+			}
+			if err := (*p36).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.Creation:
+		{
+			p37 := v.Creation
+			if p37 == nil {
+				p37 = new(CreationInfo)
+			}
+			if err := (*p37).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.Quote:
+		{
+			p38 := v.Quote
+			if p38 == nil {
+				p38 = new(QuoteInfo)
+			}
+			if err := (*p38).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.CommandAudit:
+		{
+			p39 := v.CommandAudit
+			if p39 == nil {
+				p39 = new(CommandAuditInfo)
+			}
+			if err := (*p39).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.SessionAudit:
+		{
+			p40 := v.SessionAudit
+			if p40 == nil {
+				p40 = new(SessionAuditInfo)
+			}
+			if err := (*p40).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.Time:
+		{
+			p41 := v.Time
+			if p41 == nil {
+				p41 = new(TimeAttestInfo)
+			}
+			if err := (*p41).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.NV:
+		{
+			p42 := v.NV
+			if p42 == nil {
+				p42 = new(NVCertifyInfo)
+			}
+			if err := (*p42).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type AttestU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *AttestU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.Certify:
+		if v.Certify == nil {
+			v.Certify = new(CertifyInfo)
+		}
+		if err := (*v.Certify).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.Creation:
+		if v.Creation == nil {
+			v.Creation = new(CreationInfo)
+		}
+		if err := (*v.Creation).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.Quote:
+		if v.Quote == nil {
+			v.Quote = new(QuoteInfo)
+		}
+		if err := (*v.Quote).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.CommandAudit:
+		if v.CommandAudit == nil {
+			v.CommandAudit = new(CommandAuditInfo)
+		}
+		if err := (*v.CommandAudit).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.SessionAudit:
+		if v.SessionAudit == nil {
+			v.SessionAudit = new(SessionAuditInfo)
+		}
+		if err := (*v.SessionAudit).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.Time:
+		if v.Time == nil {
+			v.Time = new(TimeAttestInfo)
+		}
+		if err := (*v.Time).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.NV:
+		if v.NV == nil {
+			v.NV = new(NVCertifyInfo)
+		}
+		if err := (*v.NV).UnmarshalMu(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type AttestU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v Auth) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start43 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start43); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Auth) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n44, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n44 > 0 {
+			(*v) = make(Auth, n44)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v AuthCommand) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.SessionHandle))
+	if v.Nonce == nil {
+		e.WriteUint16(0)
+	} else {
+		start45 := e.BeginSized()
+		e.WriteBytes(v.Nonce)
+		if err := e.EndSized(start45); err != nil {
+			return err
+		}
+	}
+	e.WriteUint8(uint8(v.SessionAttributes))
+	if v.HMAC == nil {
+		e.WriteUint16(0)
+	} else {
+		start46 := e.BeginSized()
+		e.WriteBytes(v.HMAC)
+		if err := e.EndSized(start46); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *AuthCommand) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x47, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.SessionHandle = Handle(x47)
+	}
+	{
+		n48, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n48 > 0 {
+			v.Nonce = make(Nonce, n48)
+			if err := d.ReadBytes(v.Nonce); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		x49, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		v.SessionAttributes = SessionAttributes(x49)
+	}
+	{
+		n50, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n50 > 0 {
+			v.HMAC = make(Auth, n50)
+			if err := d.ReadBytes(v.HMAC); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v AuthResponse) MarshalMu(e *mu.Encoder) error {
+	if v.Nonce == nil {
+		e.WriteUint16(0)
+	} else {
+		start51 := e.BeginSized()
+		e.WriteBytes(v.Nonce)
+		if err := e.EndSized(start51); err != nil {
+			return err
+		}
+	}
+	e.WriteUint8(uint8(v.SessionAttributes))
+	if v.HMAC == nil {
+		e.WriteUint16(0)
+	} else {
+		start52 := e.BeginSized()
+		e.WriteBytes(v.HMAC)
+		if err := e.EndSized(start52); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *AuthResponse) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n53, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n53 > 0 {
+			v.Nonce = make(Nonce, n53)
+			if err := d.ReadBytes(v.Nonce); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		x54, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		v.SessionAttributes = SessionAttributes(x54)
+	}
+	{
+		n55, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n55 > 0 {
+			v.HMAC = make(Auth, n55)
+			if err := d.ReadBytes(v.HMAC); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *CapabilitiesU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.Algorithms:
+		if err := e.WriteListLength(len(v.Algorithms)); err != nil {
+			return err
+		}
+		for i56 := range v.Algorithms {
+			if err := v.Algorithms[i56].MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.Handles:
+		if err := e.WriteListLength(len(v.Handles)); err != nil {
+			return err
+		}
+		for i57 := range v.Handles {
+			e.WriteUint32(uint32(v.Handles[i57]))
+		}
+	case &v.Command:
+		if err := e.WriteListLength(len(v.Command)); err != nil {
+			return err
+		}
+		for i58 := range v.Command {
+			e.WriteUint32(uint32(v.Command[i58]))
+		}
+	case &v.PPCommands:
+		if err := e.WriteListLength(len(v.PPCommands)); err != nil {
+			return err
+		}
+		for i59 := range v.PPCommands {
+			e.WriteUint32(uint32(v.PPCommands[i59]))
+		}
+	case &v.AuditCommands:
+		if err := e.WriteListLength(len(v.AuditCommands)); err != nil {
+			return err
+		}
+		for i60 := range v.AuditCommands {
+			e.WriteUint32(uint32(v.AuditCommands[i60]))
+		}
+	case &v.AssignedPCR:
+		if err := e.WriteListLength(len(v.AssignedPCR)); err != nil {
+			return err
+		}
+		for i61 := range v.AssignedPCR {
+			if err := v.AssignedPCR[i61].MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.TPMProperties:
+		if err := e.WriteListLength(len(v.TPMProperties)); err != nil {
+			return err
+		}
+		for i62 := range v.TPMProperties {
+			if err := v.TPMProperties[i62].MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.PCRProperties:
+		if err := e.WriteListLength(len(v.PCRProperties)); err != nil {
+			return err
+		}
+		for i63 := range v.PCRProperties {
+			if err := v.PCRProperties[i63].MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECCCurves:
+		if err := e.WriteListLength(len(v.ECCCurves)); err != nil {
+			return err
+		}
+		for i64 := range v.ECCCurves {
+			e.WriteUint16(uint16(v.ECCCurves[i64]))
+		}
+	case &v.AuthPolicies:
+		if err := e.WriteListLength(len(v.AuthPolicies)); err != nil {
+			return err
+		}
+		for i65 := range v.AuthPolicies {
+			if err := v.AuthPolicies[i65].MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type CapabilitiesU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *CapabilitiesU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.Algorithms:
+		{
+			n66, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.Algorithms == nil || cap(v.Algorithms) < n66 {
+				v.Algorithms = make(AlgorithmPropertyList, 0, n66)
+			}
+			v.Algorithms = v.Algorithms[:0]
+			for i67 := 0; i67 < n66; i67++ {
+				var zero68 AlgorithmProperty
+				v.Algorithms = append(v.Algorithms, zero68)
+				if err := v.Algorithms[i67].UnmarshalMu(d); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.Handles:
+		{
+			n69, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.Handles == nil || cap(v.Handles) < n69 {
+				v.Handles = make(HandleList, 0, n69)
+			}
+			v.Handles = v.Handles[:0]
+			for i70 := 0; i70 < n69; i70++ {
+				var zero71 Handle
+				v.Handles = append(v.Handles, zero71)
+				{
+					x72, err := d.ReadUint32()
+					if err != nil {
+						return err
+					}
+					v.Handles[i70] = Handle(x72)
+				}
+			}
+		}
+	case &v.Command:
+		{
+			n73, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.Command == nil || cap(v.Command) < n73 {
+				v.Command = make(CommandAttributesList, 0, n73)
+			}
+			v.Command = v.Command[:0]
+			for i74 := 0; i74 < n73; i74++ {
+				var zero75 CommandAttributes
+				v.Command = append(v.Command, zero75)
+				{
+					x76, err := d.ReadUint32()
+					if err != nil {
+						return err
+					}
+					v.Command[i74] = CommandAttributes(x76)
+				}
+			}
+		}
+	case &v.PPCommands:
+		{
+			n77, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.PPCommands == nil || cap(v.PPCommands) < n77 {
+				v.PPCommands = make(CommandCodeList, 0, n77)
+			}
+			v.PPCommands = v.PPCommands[:0]
+			for i78 := 0; i78 < n77; i78++ {
+				var zero79 CommandCode
+				v.PPCommands = append(v.PPCommands, zero79)
+				{
+					x80, err := d.ReadUint32()
+					if err != nil {
+						return err
+					}
+					v.PPCommands[i78] = CommandCode(x80)
+				}
+			}
+		}
+	case &v.AuditCommands:
+		{
+			n81, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.AuditCommands == nil || cap(v.AuditCommands) < n81 {
+				v.AuditCommands = make(CommandCodeList, 0, n81)
+			}
+			v.AuditCommands = v.AuditCommands[:0]
+			for i82 := 0; i82 < n81; i82++ {
+				var zero83 CommandCode
+				v.AuditCommands = append(v.AuditCommands, zero83)
+				{
+					x84, err := d.ReadUint32()
+					if err != nil {
+						return err
+					}
+					v.AuditCommands[i82] = CommandCode(x84)
+				}
+			}
+		}
+	case &v.AssignedPCR:
+		{
+			n85, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.AssignedPCR == nil || cap(v.AssignedPCR) < n85 {
+				v.AssignedPCR = make(PCRSelectionList, 0, n85)
+			}
+			v.AssignedPCR = v.AssignedPCR[:0]
+			for i86 := 0; i86 < n85; i86++ {
+				var zero87 PCRSelection
+				v.AssignedPCR = append(v.AssignedPCR, zero87)
+				if err := v.AssignedPCR[i86].UnmarshalMu(d); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.TPMProperties:
+		{
+			n88, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.TPMProperties == nil || cap(v.TPMProperties) < n88 {
+				v.TPMProperties = make(TaggedTPMPropertyList, 0, n88)
+			}
+			v.TPMProperties = v.TPMProperties[:0]
+			for i89 := 0; i89 < n88; i89++ {
+				var zero90 TaggedProperty
+				v.TPMProperties = append(v.TPMProperties, zero90)
+				if err := v.TPMProperties[i89].UnmarshalMu(d); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.PCRProperties:
+		{
+			n91, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.PCRProperties == nil || cap(v.PCRProperties) < n91 {
+				v.PCRProperties = make(TaggedPCRPropertyList, 0, n91)
+			}
+			v.PCRProperties = v.PCRProperties[:0]
+			for i92 := 0; i92 < n91; i92++ {
+				var zero93 TaggedPCRSelect
+				v.PCRProperties = append(v.PCRProperties, zero93)
+				if err := v.PCRProperties[i92].UnmarshalMu(d); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.ECCCurves:
+		{
+			n94, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.ECCCurves == nil || cap(v.ECCCurves) < n94 {
+				v.ECCCurves = make(ECCCurveList, 0, n94)
+			}
+			v.ECCCurves = v.ECCCurves[:0]
+			for i95 := 0; i95 < n94; i95++ {
+				var zero96 ECCCurve
+				v.ECCCurves = append(v.ECCCurves, zero96)
+				{
+					x97, err := d.ReadUint16()
+					if err != nil {
+						return err
+					}
+					v.ECCCurves[i95] = ECCCurve(x97)
+				}
+			}
+		}
+	case &v.AuthPolicies:
+		{
+			n98, err := d.ReadListLength()
+			if err != nil {
+				return err
+			}
+			if v.AuthPolicies == nil || cap(v.AuthPolicies) < n98 {
+				v.AuthPolicies = make(TaggedPolicyList, 0, n98)
+			}
+			v.AuthPolicies = v.AuthPolicies[:0]
+			for i99 := 0; i99 < n98; i99++ {
+				var zero100 TaggedPolicy
+				v.AuthPolicies = append(v.AuthPolicies, zero100)
+				if err := v.AuthPolicies[i99].UnmarshalMu(d); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		panic("Union.Select implementation for type CapabilitiesU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v Capability) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *Capability) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x101, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = Capability(x101)
+	}
+	return nil
+}
+
+func (v CapabilityData) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Capability))
+	{
+		p102 := v.Data
+		if p102 == nil {
+			p102 = new(CapabilitiesU)
+		}
+		if err := (*p102).marshalMu(e, reflect.ValueOf(v.Capability)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *CapabilityData) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x103, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Capability = Capability(x103)
+	}
+	if v.Data == nil {
+		v.Data = new(CapabilitiesU)
+	}
+	if err := (*v.Data).unmarshalMu(d, reflect.ValueOf(v.Capability)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v CertifyInfo) MarshalMu(e *mu.Encoder) error {
+	if v.Name == nil {
+		e.WriteUint16(0)
+	} else {
+		start104 := e.BeginSized()
+		e.WriteBytes(v.Name)
+		if err := e.EndSized(start104); err != nil {
+			return err
+		}
+	}
+	if v.QualifiedName == nil {
+		e.WriteUint16(0)
+	} else {
+		start105 := e.BeginSized()
+		e.WriteBytes(v.QualifiedName)
+		if err := e.EndSized(start105); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *CertifyInfo) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n106, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n106 > 0 {
+			v.Name = make(Name, n106)
+			if err := d.ReadBytes(v.Name); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n107, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n107 > 0 {
+			v.QualifiedName = make(Name, n107)
+			if err := d.ReadBytes(v.QualifiedName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v ClockInfo) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint64(uint64(v.Clock))
+	e.WriteUint32(uint32(v.ResetCount))
+	e.WriteUint32(uint32(v.RestartCount))
+	e.WriteBool(bool(v.Safe))
+	return nil
+}
+
+func (v *ClockInfo) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x108, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		v.Clock = uint64(x108)
+	}
+	{
+		x109, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.ResetCount = uint32(x109)
+	}
+	{
+		x110, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.RestartCount = uint32(x110)
+	}
+	{
+		x111, err := d.ReadBool()
+		if err != nil {
+			return err
+		}
+		v.Safe = bool(x111)
+	}
+	return nil
+}
+
+func (v CommandAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *CommandAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x112, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = CommandAttributes(x112)
+	}
+	return nil
+}
+
+func (v CommandAttributesList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i113 := range v {
+		e.WriteUint32(uint32(v[i113]))
+	}
+	return nil
+}
+
+func (v *CommandAttributesList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n114, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n114 {
+			(*v) = make(CommandAttributesList, 0, n114)
+		}
+		(*v) = (*v)[:0]
+		for i115 := 0; i115 < n114; i115++ {
+			var zero116 CommandAttributes
+			(*v) = append((*v), zero116)
+			{
+				x117, err := d.ReadUint32()
+				if err != nil {
+					return err
+				}
+				(*v)[i115] = CommandAttributes(x117)
+			}
+		}
+	}
+	return nil
+}
+
+func (v CommandAuditInfo) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint64(uint64(v.AuditCounter))
+	e.WriteUint16(uint16(v.DigestAlg))
+	if v.AuditDigest == nil {
+		e.WriteUint16(0)
+	} else {
+		start118 := e.BeginSized()
+		e.WriteBytes(v.AuditDigest)
+		if err := e.EndSized(start118); err != nil {
+			return err
+		}
+	}
+	if v.CommandDigest == nil {
+		e.WriteUint16(0)
+	} else {
+		start119 := e.BeginSized()
+		e.WriteBytes(v.CommandDigest)
+		if err := e.EndSized(start119); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *CommandAuditInfo) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x120, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		v.AuditCounter = uint64(x120)
+	}
+	{
+		x121, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.DigestAlg = AlgorithmId(x121)
+	}
+	{
+		n122, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n122 > 0 {
+			v.AuditDigest = make(Digest, n122)
+			if err := d.ReadBytes(v.AuditDigest); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n123, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n123 > 0 {
+			v.CommandDigest = make(Digest, n123)
+			if err := d.ReadBytes(v.CommandDigest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v CommandAuditLogRecord) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v.Type))
+	e.WriteUint64(uint64(v.AuditCounter))
+	e.WriteUint16(uint16(v.DigestAlg))
+	if v.AuditDigest == nil {
+		e.WriteUint16(0)
+	} else {
+		start124 := e.BeginSized()
+		e.WriteBytes(v.AuditDigest)
+		if err := e.EndSized(start124); err != nil {
+			return err
+		}
+	}
+	e.WriteUint32(uint32(v.CommandCode))
+	if v.CpHash == nil {
+		e.WriteUint16(0)
+	} else {
+		start125 := e.BeginSized()
+		e.WriteBytes(v.CpHash)
+		if err := e.EndSized(start125); err != nil {
+			return err
+		}
+	}
+	if v.RpHash == nil {
+		e.WriteUint16(0)
+	} else {
+		start126 := e.BeginSized()
+		e.WriteBytes(v.RpHash)
+		if err := e.EndSized(start126); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *CommandAuditLogRecord) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x127, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		v.Type = CommandAuditLogRecordType(x127)
+	}
+	{
+		x128, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		v.AuditCounter = uint64(x128)
+	}
+	{
+		x129, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.DigestAlg = HashAlgorithmId(x129)
+	}
+	{
+		n130, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n130 > 0 {
+			v.AuditDigest = make(Digest, n130)
+			if err := d.ReadBytes(v.AuditDigest); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		x131, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.CommandCode = CommandCode(x131)
+	}
+	{
+		n132, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n132 > 0 {
+			v.CpHash = make(Digest, n132)
+			if err := d.ReadBytes(v.CpHash); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n133, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n133 > 0 {
+			v.RpHash = make(Digest, n133)
+			if err := d.ReadBytes(v.RpHash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v CommandAuditLogRecordType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *CommandAuditLogRecordType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x134, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = CommandAuditLogRecordType(x134)
+	}
+	return nil
+}
+
+func (v CommandCode) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *CommandCode) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x135, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = CommandCode(x135)
+	}
+	return nil
+}
+
+func (v CommandCodeList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i136 := range v {
+		e.WriteUint32(uint32(v[i136]))
+	}
+	return nil
+}
+
+func (v *CommandCodeList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n137, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n137 {
+			(*v) = make(CommandCodeList, 0, n137)
+		}
+		(*v) = (*v)[:0]
+		for i138 := 0; i138 < n137; i138++ {
+			var zero139 CommandCode
+			(*v) = append((*v), zero139)
+			{
+				x140, err := d.ReadUint32()
+				if err != nil {
+					return err
+				}
+				(*v)[i138] = CommandCode(x140)
+			}
+		}
+	}
+	return nil
+}
+
+func (v CommandHeader) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Tag))
+	e.WriteUint32(uint32(v.CommandSize))
+	e.WriteUint32(uint32(v.CommandCode))
+	return nil
+}
+
+func (v *CommandHeader) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x141, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Tag = StructTag(x141)
+	}
+	{
+		x142, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.CommandSize = uint32(x142)
+	}
+	{
+		x143, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.CommandCode = CommandCode(x143)
+	}
+	return nil
+}
+
+func (v CommandPacket) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start144 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start144); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *CommandPacket) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n145, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n145 > 0 {
+			(*v) = make(CommandPacket, n145)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Context) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint64(uint64(v.Sequence))
+	e.WriteUint32(uint32(v.SavedHandle))
+	e.WriteUint32(uint32(v.Hierarchy))
+	if v.Blob == nil {
+		e.WriteUint16(0)
+	} else {
+		start146 := e.BeginSized()
+		e.WriteBytes(v.Blob)
+		if err := e.EndSized(start146); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Context) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x147, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		v.Sequence = uint64(x147)
+	}
+	{
+		x148, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.SavedHandle = Handle(x148)
+	}
+	{
+		x149, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Hierarchy = Handle(x149)
+	}
+	{
+		n150, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n150 > 0 {
+			v.Blob = make(ContextData, n150)
+			if err := d.ReadBytes(v.Blob); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v ContextData) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start151 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start151); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *ContextData) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n152, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n152 > 0 {
+			(*v) = make(ContextData, n152)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v CreationData) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v.PCRSelect)); err != nil {
+		return err
+	}
+	for i153 := range v.PCRSelect {
+		if err := v.PCRSelect[i153].MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	if v.PCRDigest == nil {
+		e.WriteUint16(0)
+	} else {
+		start154 := e.BeginSized()
+		e.WriteBytes(v.PCRDigest)
+		if err := e.EndSized(start154); err != nil {
+			return err
+		}
+	}
+	e.WriteUint8(uint8(v.Locality))
+	e.WriteUint16(uint16(v.ParentNameAlg))
+	if v.ParentName == nil {
+		e.WriteUint16(0)
+	} else {
+		start155 := e.BeginSized()
+		e.WriteBytes(v.ParentName)
+		if err := e.EndSized(start155); err != nil {
+			return err
+		}
+	}
+	if v.ParentQualifiedName == nil {
+		e.WriteUint16(0)
+	} else {
+		start156 := e.BeginSized()
+		e.WriteBytes(v.ParentQualifiedName)
+		if err := e.EndSized(start156); err != nil {
+			return err
+		}
+	}
+	if v.OutsideInfo == nil {
+		e.WriteUint16(0)
+	} else {
+		start157 := e.BeginSized()
+		e.WriteBytes(v.OutsideInfo)
+		if err := e.EndSized(start157); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *CreationData) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n158, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if v.PCRSelect == nil || cap(v.PCRSelect) < n158 {
+			v.PCRSelect = make(PCRSelectionList, 0, n158)
+		}
+		v.PCRSelect = v.PCRSelect[:0]
+		for i159 := 0; i159 < n158; i159++ {
+			var zero160 PCRSelection
+			v.PCRSelect = append(v.PCRSelect, zero160)
+			if err := v.PCRSelect[i159].UnmarshalMu(d); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n161, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n161 > 0 {
+			v.PCRDigest = make(Digest, n161)
+			if err := d.ReadBytes(v.PCRDigest); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		x162, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		v.Locality = Locality(x162)
+	}
+	{
+		x163, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.ParentNameAlg = AlgorithmId(x163)
+	}
+	{
+		n164, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n164 > 0 {
+			v.ParentName = make(Name, n164)
+			if err := d.ReadBytes(v.ParentName); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n165, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n165 > 0 {
+			v.ParentQualifiedName = make(Name, n165)
+			if err := d.ReadBytes(v.ParentQualifiedName); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n166, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n166 > 0 {
+			v.OutsideInfo = make(Data, n166)
+			if err := d.ReadBytes(v.OutsideInfo); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v CreationInfo) MarshalMu(e *mu.Encoder) error {
+	if v.ObjectName == nil {
+		e.WriteUint16(0)
+	} else {
+		start167 := e.BeginSized()
+		e.WriteBytes(v.ObjectName)
+		if err := e.EndSized(start167); err != nil {
+			return err
+		}
+	}
+	if v.CreationHash == nil {
+		e.WriteUint16(0)
+	} else {
+		start168 := e.BeginSized()
+		e.WriteBytes(v.CreationHash)
+		if err := e.EndSized(start168); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *CreationInfo) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n169, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n169 > 0 {
+			v.ObjectName = make(Name, n169)
+			if err := d.ReadBytes(v.ObjectName); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n170, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n170 > 0 {
+			v.CreationHash = make(Digest, n170)
+			if err := d.ReadBytes(v.CreationHash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Data) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start171 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start171); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Data) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n172, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n172 > 0 {
+			(*v) = make(Data, n172)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Derive) MarshalMu(e *mu.Encoder) error {
+	if v.Label == nil {
+		e.WriteUint16(0)
+	} else {
+		start173 := e.BeginSized()
+		e.WriteBytes(v.Label)
+		if err := e.EndSized(start173); err != nil {
+			return err
+		}
+	}
+	if v.Context == nil {
+		e.WriteUint16(0)
+	} else {
+		start174 := e.BeginSized()
+		e.WriteBytes(v.Context)
+		if err := e.EndSized(start174); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Derive) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n175, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n175 > 0 {
+			v.Label = make(Label, n175)
+			if err := d.ReadBytes(v.Label); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n176, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n176 > 0 {
+			v.Context = make(Label, n176)
+			if err := d.ReadBytes(v.Context); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Digest) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start177 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start177); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Digest) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n178, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n178 > 0 {
+			(*v) = make(Digest, n178)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v DigestList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i179 := range v {
+		if v[i179] == nil {
+			e.WriteUint16(0)
+		} else {
+			start180 := e.BeginSized()
+			e.WriteBytes(v[i179])
+			if err := e.EndSized(start180); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *DigestList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n181, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n181 {
+			(*v) = make(DigestList, 0, n181)
+		}
+		(*v) = (*v)[:0]
+		for i182 := 0; i182 < n181; i182++ {
+			var zero183 Digest
+			(*v) = append((*v), zero183)
+			{
+				n184, err := d.ReadSize(false)
+				if err != nil {
+					return err
+				}
+				if n184 > 0 {
+					(*v)[i182] = make(Digest, n184)
+					if err := d.ReadBytes((*v)[i182]); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v ECCCurve) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *ECCCurve) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x185, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = ECCCurve(x185)
+	}
+	return nil
+}
+
+func (v ECCCurveList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i186 := range v {
+		e.WriteUint16(uint16(v[i186]))
+	}
+	return nil
+}
+
+func (v *ECCCurveList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n187, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n187 {
+			(*v) = make(ECCCurveList, 0, n187)
+		}
+		(*v) = (*v)[:0]
+		for i188 := 0; i188 < n187; i188++ {
+			var zero189 ECCCurve
+			(*v) = append((*v), zero189)
+			{
+				x190, err := d.ReadUint16()
+				if err != nil {
+					return err
+				}
+				(*v)[i188] = ECCCurve(x190)
+			}
+		}
+	}
+	return nil
+}
+
+func (v ECCParameter) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start191 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start191); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *ECCParameter) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n192, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n192 > 0 {
+			(*v) = make(ECCParameter, n192)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v ECCParams) MarshalMu(e *mu.Encoder) error {
+	if err := v.Symmetric.MarshalMu(e); err != nil {
+		return err
+	}
+	if err := v.Scheme.MarshalMu(e); err != nil {
+		return err
+	}
+	e.WriteUint16(uint16(v.CurveID))
+	if err := v.KDF.MarshalMu(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *ECCParams) UnmarshalMu(d *mu.Decoder) error {
+	if err := v.Symmetric.UnmarshalMu(d); err != nil {
+		return err
+	}
+	if err := v.Scheme.UnmarshalMu(d); err != nil {
+		return err
+	}
+	{
+		x193, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.CurveID = ECCCurve(x193)
+	}
+	if err := v.KDF.UnmarshalMu(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v ECCPoint) MarshalMu(e *mu.Encoder) error {
+	if v.X == nil {
+		e.WriteUint16(0)
+	} else {
+		start194 := e.BeginSized()
+		e.WriteBytes(v.X)
+		if err := e.EndSized(start194); err != nil {
+			return err
+		}
+	}
+	if v.Y == nil {
+		e.WriteUint16(0)
+	} else {
+		start195 := e.BeginSized()
+		e.WriteBytes(v.Y)
+		if err := e.EndSized(start195); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *ECCPoint) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n196, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n196 > 0 {
+			v.X = make(ECCParameter, n196)
+			if err := d.ReadBytes(v.X); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n197, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n197 > 0 {
+			v.Y = make(ECCParameter, n197)
+			if err := d.ReadBytes(v.Y); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v ECCScheme) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Scheme))
+	{
+		p198 := v.Details
+		if p198 == nil {
+			p198 = new(AsymSchemeU)
+		}
+		if err := (*p198).marshalMu(e, reflect.ValueOf(v.Scheme)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *ECCScheme) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x199, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Scheme = ECCSchemeId(x199)
+	}
+	if v.Details == nil {
+		v.Details = new(AsymSchemeU)
+	}
+	if err := (*v.Details).unmarshalMu(d, reflect.ValueOf(v.Scheme)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v ECCSchemeId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *ECCSchemeId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x200, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = ECCSchemeId(x200)
+	}
+	return nil
+}
+
+func (v Empty) MarshalMu(e *mu.Encoder) error {
+	return nil
+}
+
+func (v *Empty) UnmarshalMu(d *mu.Decoder) error {
+	return nil
+}
+
+func (v EncSchemeOAEP) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *EncSchemeOAEP) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x201, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x201)
+	}
+	return nil
+}
+
+func (v EncSchemeRSAES) MarshalMu(e *mu.Encoder) error {
+	return nil
+}
+
+func (v *EncSchemeRSAES) UnmarshalMu(d *mu.Decoder) error {
+	return nil
+}
+
+func (v EncryptedSecret) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start202 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start202); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *EncryptedSecret) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n203, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n203 > 0 {
+			(*v) = make(EncryptedSecret, n203)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v ErrorCode) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *ErrorCode) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x204, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = ErrorCode(x204)
+	}
+	return nil
+}
+
+func (v Event) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start205 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start205); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Event) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n206, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n206 > 0 {
+			(*v) = make(Event, n206)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Handle) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *Handle) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x207, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = Handle(x207)
+	}
+	return nil
+}
+
+func (v HandleList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i208 := range v {
+		e.WriteUint32(uint32(v[i208]))
+	}
+	return nil
+}
+
+func (v *HandleList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n209, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n209 {
+			(*v) = make(HandleList, 0, n209)
+		}
+		(*v) = (*v)[:0]
+		for i210 := 0; i210 < n209; i210++ {
+			var zero211 Handle
+			(*v) = append((*v), zero211)
+			{
+				x212, err := d.ReadUint32()
+				if err != nil {
+					return err
+				}
+				(*v)[i210] = Handle(x212)
+			}
+		}
+	}
+	return nil
+}
+
+func (v HandleType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *HandleType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x213, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = HandleType(x213)
+	}
+	return nil
+}
+
+func (v HashAlgorithmId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *HashAlgorithmId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x214, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = HashAlgorithmId(x214)
+	}
+	return nil
+}
+
+func (v IDObjectRaw) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start215 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start215); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *IDObjectRaw) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n216, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n216 > 0 {
+			(*v) = make(IDObjectRaw, n216)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v KDFAlgorithmId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *KDFAlgorithmId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x217, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = KDFAlgorithmId(x217)
+	}
+	return nil
+}
+
+func (v KDFScheme) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Scheme))
+	{
+		p218 := v.Details
+		if p218 == nil {
+			p218 = new(KDFSchemeU)
+		}
+		if err := (*p218).marshalMu(e, reflect.ValueOf(v.Scheme)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *KDFScheme) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x219, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Scheme = KDFAlgorithmId(x219)
+	}
+	if v.Details == nil {
+		v.Details = new(KDFSchemeU)
+	}
+	if err := (*v.Details).unmarshalMu(d, reflect.ValueOf(v.Scheme)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *KDFSchemeU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.MGF1:
+		{
+			p220 := v.MGF1
+			if p220 == nil {
+				p220 = new(SchemeMGF1)
+			}
+			if err := (*p220).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.KDF1_SP800_56A:
+		{
+			p221 := v.KDF1_SP800_56A
+			if p221 == nil {
+				p221 = new(SchemeKDF1_SP800_56A)
+			}
+			if err := (*p221).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.KDF2:
+		{
+			p222 := v.KDF2
+			if p222 == nil {
+				p222 = new(SchemeKDF2)
+			}
+			if err := (*p222).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.KDF1_SP800_108:
+		{
+			p223 := v.KDF1_SP800_108
+			if p223 == nil {
+				p223 = new(SchemeKDF1_SP800_108)
+			}
+			if err := (*p223).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type KDFSchemeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *KDFSchemeU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.MGF1:
+		if v.MGF1 == nil {
+			v.MGF1 = new(SchemeMGF1)
+		}
+		if err := (*v.MGF1).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.KDF1_SP800_56A:
+		if v.KDF1_SP800_56A == nil {
+			v.KDF1_SP800_56A = new(SchemeKDF1_SP800_56A)
+		}
+		if err := (*v.KDF1_SP800_56A).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.KDF2:
+		if v.KDF2 == nil {
+			v.KDF2 = new(SchemeKDF2)
+		}
+		if err := (*v.KDF2).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.KDF1_SP800_108:
+		if v.KDF1_SP800_108 == nil {
+			v.KDF1_SP800_108 = new(SchemeKDF1_SP800_108)
+		}
+		if err := (*v.KDF1_SP800_108).UnmarshalMu(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type KDFSchemeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v KeySchemeECDH) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *KeySchemeECDH) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x224, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x224)
+	}
+	return nil
+}
+
+func (v KeySchemeECMQV) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *KeySchemeECMQV) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x225, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x225)
+	}
+	return nil
+}
+
+func (v KeyedHashParams) MarshalMu(e *mu.Encoder) error {
+	if err := v.Scheme.MarshalMu(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *KeyedHashParams) UnmarshalMu(d *mu.Decoder) error {
+	if err := v.Scheme.UnmarshalMu(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v KeyedHashScheme) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Scheme))
+	{
+		p226 := v.Details
+		if p226 == nil {
+			p226 = new(SchemeKeyedHashU)
+		}
+		if err := (*p226).marshalMu(e, reflect.ValueOf(v.Scheme)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *KeyedHashScheme) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x227, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Scheme = KeyedHashSchemeId(x227)
+	}
+	if v.Details == nil {
+		v.Details = new(SchemeKeyedHashU)
+	}
+	if err := (*v.Details).unmarshalMu(d, reflect.ValueOf(v.Scheme)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v KeyedHashSchemeId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *KeyedHashSchemeId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x228, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = KeyedHashSchemeId(x228)
+	}
+	return nil
+}
+
+func (v Label) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start229 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start229); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Label) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n230, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n230 > 0 {
+			(*v) = make(Label, n230)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Locality) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *Locality) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x231, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = Locality(x231)
+	}
+	return nil
+}
+
+func (v MaxBuffer) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start232 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start232); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *MaxBuffer) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n233, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n233 > 0 {
+			(*v) = make(MaxBuffer, n233)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v MaxNVBuffer) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start234 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start234); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *MaxNVBuffer) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n235, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n235 > 0 {
+			(*v) = make(MaxNVBuffer, n235)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v MemoryAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *MemoryAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x236, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = MemoryAttributes(x236)
+	}
+	return nil
+}
+
+func (v ModeAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *ModeAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x237, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = ModeAttributes(x237)
+	}
+	return nil
+}
+
+func (v MssimServerFlags) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *MssimServerFlags) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x238, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = MssimServerFlags(x238)
+	}
+	return nil
+}
+
+func (v NVAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *NVAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x239, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = NVAttributes(x239)
+	}
+	return nil
+}
+
+func (v NVCertifyInfo) MarshalMu(e *mu.Encoder) error {
+	if v.IndexName == nil {
+		e.WriteUint16(0)
+	} else {
+		start240 := e.BeginSized()
+		e.WriteBytes(v.IndexName)
+		if err := e.EndSized(start240); err != nil {
+			return err
+		}
+	}
+	e.WriteUint16(uint16(v.Offset))
+	if v.NVContents == nil {
+		e.WriteUint16(0)
+	} else {
+		start241 := e.BeginSized()
+		e.WriteBytes(v.NVContents)
+		if err := e.EndSized(start241); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *NVCertifyInfo) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n242, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n242 > 0 {
+			v.IndexName = make(Name, n242)
+			if err := d.ReadBytes(v.IndexName); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		x243, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Offset = uint16(x243)
+	}
+	{
+		n244, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n244 > 0 {
+			v.NVContents = make(MaxNVBuffer, n244)
+			if err := d.ReadBytes(v.NVContents); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v NVPinCounterParams) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Count))
+	e.WriteUint32(uint32(v.Limit))
+	return nil
+}
+
+func (v *NVPinCounterParams) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x245, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Count = uint32(x245)
+	}
+	{
+		x246, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Limit = uint32(x246)
+	}
+	return nil
+}
+
+func (v NVPublic) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Index))
+	e.WriteUint16(uint16(v.NameAlg))
+	e.WriteUint32(uint32(v.Attrs))
+	if v.AuthPolicy == nil {
+		e.WriteUint16(0)
+	} else {
+		start247 := e.BeginSized()
+		e.WriteBytes(v.AuthPolicy)
+		if err := e.EndSized(start247); err != nil {
+			return err
+		}
+	}
+	e.WriteUint16(uint16(v.Size))
+	return nil
+}
+
+func (v *NVPublic) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x248, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Index = Handle(x248)
+	}
+	{
+		x249, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.NameAlg = HashAlgorithmId(x249)
+	}
+	{
+		x250, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Attrs = NVAttributes(x250)
+	}
+	{
+		n251, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n251 > 0 {
+			v.AuthPolicy = make(Digest, n251)
+			if err := d.ReadBytes(v.AuthPolicy); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		x252, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Size = uint16(x252)
+	}
+	return nil
+}
+
+func (v NVType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *NVType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x253, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = NVType(x253)
+	}
+	return nil
+}
+
+func (v Name) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start254 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start254); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Name) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n255, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n255 > 0 {
+			(*v) = make(Name, n255)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Nonce) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start256 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start256); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Nonce) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n257, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n257 > 0 {
+			(*v) = make(Nonce, n257)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v ObjectAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *ObjectAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x258, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = ObjectAttributes(x258)
+	}
+	return nil
+}
+
+func (v ObjectTypeId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *ObjectTypeId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x259, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = ObjectTypeId(x259)
+	}
+	return nil
+}
+
+func (v Operand) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start260 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start260); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Operand) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n261, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n261 > 0 {
+			(*v) = make(Operand, n261)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v PCRSelection) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if err := v.Select.Marshal(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *PCRSelection) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x262, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x262)
+	}
+	if err := v.Select.Unmarshal(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v PCRSelectionList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i263 := range v {
+		if err := v[i263].MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *PCRSelectionList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n264, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n264 {
+			(*v) = make(PCRSelectionList, 0, n264)
+		}
+		(*v) = (*v)[:0]
+		for i265 := 0; i265 < n264; i265++ {
+			var zero266 PCRSelection
+			(*v) = append((*v), zero266)
+			if err := (*v)[i265].UnmarshalMu(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v PermanentAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *PermanentAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x267, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = PermanentAttributes(x267)
+	}
+	return nil
+}
+
+func (v Private) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start268 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start268); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Private) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n269, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n269 > 0 {
+			(*v) = make(Private, n269)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v PrivateKeyRSA) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start270 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start270); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *PrivateKeyRSA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n271, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n271 > 0 {
+			(*v) = make(PrivateKeyRSA, n271)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v PrivateVendorSpecific) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start272 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start272); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *PrivateVendorSpecific) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n273, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n273 > 0 {
+			(*v) = make(PrivateVendorSpecific, n273)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Property) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *Property) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x274, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = Property(x274)
+	}
+	return nil
+}
+
+func (v PropertyPCR) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *PropertyPCR) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x275, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = PropertyPCR(x275)
+	}
+	return nil
+}
+
+func (v Public) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Type))
+	e.WriteUint16(uint16(v.NameAlg))
+	e.WriteUint32(uint32(v.Attrs))
+	if v.AuthPolicy == nil {
+		e.WriteUint16(0)
+	} else {
+		start276 := e.BeginSized()
+		e.WriteBytes(v.AuthPolicy)
+		if err := e.EndSized(start276); err != nil {
+			return err
+		}
+	}
+	{
+		p277 := v.Params
+		if p277 == nil {
+			p277 = new(PublicParamsU)
+		}
+		if err := (*p277).marshalMu(e, reflect.ValueOf(v.Type)); err != nil {
+			return err
+		}
+	}
+	{
+		p278 := v.Unique
+		if p278 == nil {
+			p278 = new(PublicIDU)
+		}
+		if err := (*p278).marshalMu(e, reflect.ValueOf(v.Type)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Public) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x279, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Type = ObjectTypeId(x279)
+	}
+	{
+		x280, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.NameAlg = HashAlgorithmId(x280)
+	}
+	{
+		x281, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Attrs = ObjectAttributes(x281)
+	}
+	{
+		n282, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n282 > 0 {
+			v.AuthPolicy = make(Digest, n282)
+			if err := d.ReadBytes(v.AuthPolicy); err != nil {
+				return err
+			}
+		}
+	}
+	if v.Params == nil {
+		v.Params = new(PublicParamsU)
+	}
+	if err := (*v.Params).unmarshalMu(d, reflect.ValueOf(v.Type)); err != nil {
+		return err
+	}
+	if v.Unique == nil {
+		v.Unique = new(PublicIDU)
+	}
+	if err := (*v.Unique).unmarshalMu(d, reflect.ValueOf(v.Type)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v PublicDerived) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Type))
+	e.WriteUint16(uint16(v.NameAlg))
+	e.WriteUint32(uint32(v.Attrs))
+	if v.AuthPolicy == nil {
+		e.WriteUint16(0)
+	} else {
+		start283 := e.BeginSized()
+		e.WriteBytes(v.AuthPolicy)
+		if err := e.EndSized(start283); err != nil {
+			return err
+		}
+	}
+	{
+		p284 := v.Params
+		if p284 == nil {
+			p284 = new(PublicParamsU)
+		}
+		if err := (*p284).marshalMu(e, reflect.ValueOf(v.Type)); err != nil {
+			return err
+		}
+	}
+	{
+		p285 := v.Unique
+		if p285 == nil {
+			p285 = new(Derive)
+		}
+		if err := (*p285).MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *PublicDerived) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x286, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Type = ObjectTypeId(x286)
+	}
+	{
+		x287, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.NameAlg = HashAlgorithmId(x287)
+	}
+	{
+		x288, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Attrs = ObjectAttributes(x288)
+	}
+	{
+		n289, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n289 > 0 {
+			v.AuthPolicy = make(Digest, n289)
+			if err := d.ReadBytes(v.AuthPolicy); err != nil {
+				return err
+			}
+		}
+	}
+	if v.Params == nil {
+		v.Params = new(PublicParamsU)
+	}
+	if err := (*v.Params).unmarshalMu(d, reflect.ValueOf(v.Type)); err != nil {
+		return err
+	}
+	if v.Unique == nil {
+		v.Unique = new(Derive)
+	}
+	if err := (*v.Unique).UnmarshalMu(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *PublicIDU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.KeyedHash:
+		if v.KeyedHash == nil {
+			e.WriteUint16(0)
+		} else {
+			start290 := e.BeginSized()
+			e.WriteBytes(v.KeyedHash)
+			if err := e.EndSized(start290); err != nil {
+				return err
+			}
+		}
+	case &v.Sym:
+		if v.Sym == nil {
+			e.WriteUint16(0)
+		} else {
+			start291 := e.BeginSized()
+			e.WriteBytes(v.Sym)
+			if err := e.EndSized(start291); err != nil {
+				return err
+			}
+		}
+	case &v.RSA:
+		if v.RSA == nil {
+			e.WriteUint16(0)
+		} else {
+			start292 := e.BeginSized()
+			e.WriteBytes(v.RSA)
+			if err := e.EndSized(start292); err != nil {
+				return err
+			}
+		}
+	case &v.ECC:
+		{
+			p293 := v.ECC
+			if p293 == nil {
+				p293 = new(ECCPoint)
+			}
+			if err := (*p293).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type PublicIDU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *PublicIDU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.KeyedHash:
+		{
+			n294, err := d.ReadSize(false)
+			if err != nil {
+				return err
+			}
+			if n294 > 0 {
+				v.KeyedHash = make(Digest, n294)
+				if err := d.ReadBytes(v.KeyedHash); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.Sym:
+		{
+			n295, err := d.ReadSize(false)
+			if err != nil {
+				return err
+			}
+			if n295 > 0 {
+				v.Sym = make(Digest, n295)
+				if err := d.ReadBytes(v.Sym); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.RSA:
+		{
+			n296, err := d.ReadSize(false)
+			if err != nil {
+				return err
+			}
+			if n296 > 0 {
+				v.RSA = make(PublicKeyRSA, n296)
+				if err := d.ReadBytes(v.RSA); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.ECC:
+		if v.ECC == nil {
+			v.ECC = new(ECCPoint)
+		}
+		if err := (*v.ECC).UnmarshalMu(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type PublicIDU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v PublicKeyRSA) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start297 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start297); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *PublicKeyRSA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n298, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n298 > 0 {
+			(*v) = make(PublicKeyRSA, n298)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v PublicParams) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Type))
+	{
+		p299 := v.Parameters
+		if p299 == nil {
+			p299 = new(PublicParamsU)
+		}
+		if err := (*p299).marshalMu(e, reflect.ValueOf(v.Type)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *PublicParams) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x300, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Type = ObjectTypeId(x300)
+	}
+	if v.Parameters == nil {
+		v.Parameters = new(PublicParamsU)
+	}
+	if err := (*v.Parameters).unmarshalMu(d, reflect.ValueOf(v.Type)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *PublicParamsU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.KeyedHashDetail:
+		{
+			p301 := v.KeyedHashDetail
+			if p301 == nil {
+				p301 = new(KeyedHashParams)
+			}
+			if err := (*p301).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.SymDetail:
+		{
+			p302 := v.SymDetail
+			if p302 == nil {
+				p302 = new(SymCipherParams)
+			}
+			if err := (*p302).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.RSADetail:
+		{
+			p303 := v.RSADetail
+			if p303 == nil {
+				p303 = new(RSAParams)
+			}
+			if err := (*p303).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECCDetail:
+		{
+			p304 := v.ECCDetail
+			if p304 == nil {
+				p304 = new(ECCParams)
+			}
+			if err := (*p304).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type PublicParamsU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *PublicParamsU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.KeyedHashDetail:
+		if v.KeyedHashDetail == nil {
+			v.KeyedHashDetail = new(KeyedHashParams)
+		}
+		if err := (*v.KeyedHashDetail).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.SymDetail:
+		if v.SymDetail == nil {
+			v.SymDetail = new(SymCipherParams)
+		}
+		if err := (*v.SymDetail).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.RSADetail:
+		if v.RSADetail == nil {
+			v.RSADetail = new(RSAParams)
+		}
+		if err := (*v.RSADetail).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECCDetail:
+		if v.ECCDetail == nil {
+			v.ECCDetail = new(ECCParams)
+		}
+		if err := (*v.ECCDetail).UnmarshalMu(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type PublicParamsU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v QuoteInfo) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v.PCRSelect)); err != nil {
+		return err
+	}
+	for i305 := range v.PCRSelect {
+		if err := v.PCRSelect[i305].MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	if v.PCRDigest == nil {
+		e.WriteUint16(0)
+	} else {
+		start306 := e.BeginSized()
+		e.WriteBytes(v.PCRDigest)
+		if err := e.EndSized(start306); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *QuoteInfo) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n307, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if v.PCRSelect == nil || cap(v.PCRSelect) < n307 {
+			v.PCRSelect = make(PCRSelectionList, 0, n307)
+		}
+		v.PCRSelect = v.PCRSelect[:0]
+		for i308 := 0; i308 < n307; i308++ {
+			var zero309 PCRSelection
+			v.PCRSelect = append(v.PCRSelect, zero309)
+			if err := v.PCRSelect[i308].UnmarshalMu(d); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n310, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n310 > 0 {
+			v.PCRDigest = make(Digest, n310)
+			if err := d.ReadBytes(v.PCRDigest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v RSAParams) MarshalMu(e *mu.Encoder) error {
+	if err := v.Symmetric.MarshalMu(e); err != nil {
+		return err
+	}
+	if err := v.Scheme.MarshalMu(e); err != nil {
+		return err
+	}
+	e.WriteUint16(uint16(v.KeyBits))
+	e.WriteUint32(uint32(v.Exponent))
+	return nil
+}
+
+func (v *RSAParams) UnmarshalMu(d *mu.Decoder) error {
+	if err := v.Symmetric.UnmarshalMu(d); err != nil {
+		return err
+	}
+	if err := v.Scheme.UnmarshalMu(d); err != nil {
+		return err
+	}
+	{
+		x311, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.KeyBits = uint16(x311)
+	}
+	{
+		x312, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Exponent = uint32(x312)
+	}
+	return nil
+}
+
+func (v RSAScheme) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Scheme))
+	{
+		p313 := v.Details
+		if p313 == nil {
+			p313 = new(AsymSchemeU)
+		}
+		if err := (*p313).marshalMu(e, reflect.ValueOf(v.Scheme)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *RSAScheme) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x314, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Scheme = RSASchemeId(x314)
+	}
+	if v.Details == nil {
+		v.Details = new(AsymSchemeU)
+	}
+	if err := (*v.Details).unmarshalMu(d, reflect.ValueOf(v.Scheme)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v RSASchemeId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *RSASchemeId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x315, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = RSASchemeId(x315)
+	}
+	return nil
+}
+
+func (v ResponseCode) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *ResponseCode) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x316, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = ResponseCode(x316)
+	}
+	return nil
+}
+
+func (v ResponseHeader) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Tag))
+	e.WriteUint32(uint32(v.ResponseSize))
+	e.WriteUint32(uint32(v.ResponseCode))
+	return nil
+}
+
+func (v *ResponseHeader) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x317, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Tag = StructTag(x317)
+	}
+	{
+		x318, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.ResponseSize = uint32(x318)
+	}
+	{
+		x319, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.ResponseCode = ResponseCode(x319)
+	}
+	return nil
+}
+
+func (v ResponsePacket) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start320 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start320); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *ResponsePacket) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n321, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n321 > 0 {
+			(*v) = make(ResponsePacket, n321)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SchemeECDAA) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	e.WriteUint16(uint16(v.Count))
+	return nil
+}
+
+func (v *SchemeECDAA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x322, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x322)
+	}
+	{
+		x323, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Count = uint16(x323)
+	}
+	return nil
+}
+
+func (v SchemeHMAC) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SchemeHMAC) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x324, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x324)
+	}
+	return nil
+}
+
+func (v SchemeHash) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SchemeHash) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x325, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x325)
+	}
+	return nil
+}
+
+func (v SchemeKDF1_SP800_108) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SchemeKDF1_SP800_108) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x326, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x326)
+	}
+	return nil
+}
+
+func (v SchemeKDF1_SP800_56A) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SchemeKDF1_SP800_56A) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x327, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x327)
+	}
+	return nil
+}
+
+func (v SchemeKDF2) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SchemeKDF2) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x328, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x328)
+	}
+	return nil
+}
+
+func (v *SchemeKeyedHashU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.HMAC:
+		{
+			p329 := v.HMAC
+			if p329 == nil {
+				p329 = new(SchemeHMAC)
+			}
+			if err := (*p329).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.XOR:
+		{
+			p330 := v.XOR
+			if p330 == nil {
+				p330 = new(SchemeXOR)
+			}
+			if err := (*p330).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type SchemeKeyedHashU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *SchemeKeyedHashU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.HMAC:
+		if v.HMAC == nil {
+			v.HMAC = new(SchemeHMAC)
+		}
+		if err := (*v.HMAC).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.XOR:
+		if v.XOR == nil {
+			v.XOR = new(SchemeXOR)
+		}
+		if err := (*v.XOR).UnmarshalMu(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type SchemeKeyedHashU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v SchemeMGF1) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SchemeMGF1) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x331, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x331)
+	}
+	return nil
+}
+
+func (v SchemeXOR) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	e.WriteUint16(uint16(v.KDF))
+	return nil
+}
+
+func (v *SchemeXOR) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x332, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x332)
+	}
+	{
+		x333, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.KDF = KDFAlgorithmId(x333)
+	}
+	return nil
+}
+
+func (v Sensitive) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Type))
+	if v.AuthValue == nil {
+		e.WriteUint16(0)
+	} else {
+		start334 := e.BeginSized()
+		e.WriteBytes(v.AuthValue)
+		if err := e.EndSized(start334); err != nil {
+			return err
+		}
+	}
+	if v.SeedValue == nil {
+		e.WriteUint16(0)
+	} else {
+		start335 := e.BeginSized()
+		e.WriteBytes(v.SeedValue)
+		if err := e.EndSized(start335); err != nil {
+			return err
+		}
+	}
+	{
+		p336 := v.Sensitive
+		if p336 == nil {
+			p336 = new(SensitiveCompositeU)
+		}
+		if err := (*p336).marshalMu(e, reflect.ValueOf(v.Type)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Sensitive) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x337, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Type = ObjectTypeId(x337)
+	}
+	{
+		n338, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n338 > 0 {
+			v.AuthValue = make(Auth, n338)
+			if err := d.ReadBytes(v.AuthValue); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n339, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n339 > 0 {
+			v.SeedValue = make(Digest, n339)
+			if err := d.ReadBytes(v.SeedValue); err != nil {
+				return err
+			}
+		}
+	}
+	if v.Sensitive == nil {
+		v.Sensitive = new(SensitiveCompositeU)
+	}
+	if err := (*v.Sensitive).unmarshalMu(d, reflect.ValueOf(v.Type)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *SensitiveCompositeU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.RSA:
+		if v.RSA == nil {
+			e.WriteUint16(0)
+		} else {
+			start340 := e.BeginSized()
+			e.WriteBytes(v.RSA)
+			if err := e.EndSized(start340); err != nil {
+				return err
+			}
+		}
+	case &v.ECC:
+		if v.ECC == nil {
+			e.WriteUint16(0)
+		} else {
+			start341 := e.BeginSized()
+			e.WriteBytes(v.ECC)
+			if err := e.EndSized(start341); err != nil {
+				return err
+			}
+		}
+	case &v.Bits:
+		if v.Bits == nil {
+			e.WriteUint16(0)
+		} else {
+			start342 := e.BeginSized()
+			e.WriteBytes(v.Bits)
+			if err := e.EndSized(start342); err != nil {
+				return err
+			}
+		}
+	case &v.Sym:
+		if v.Sym == nil {
+			e.WriteUint16(0)
+		} else {
+			start343 := e.BeginSized()
+			e.WriteBytes(v.Sym)
+			if err := e.EndSized(start343); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type SensitiveCompositeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *SensitiveCompositeU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.RSA:
+		{
+			n344, err := d.ReadSize(false)
+			if err != nil {
+				return err
+			}
+			if n344 > 0 {
+				v.RSA = make(PrivateKeyRSA, n344)
+				if err := d.ReadBytes(v.RSA); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.ECC:
+		{
+			n345, err := d.ReadSize(false)
+			if err != nil {
+				return err
+			}
+			if n345 > 0 {
+				v.ECC = make(ECCParameter, n345)
+				if err := d.ReadBytes(v.ECC); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.Bits:
+		{
+			n346, err := d.ReadSize(false)
+			if err != nil {
+				return err
+			}
+			if n346 > 0 {
+				v.Bits = make(SensitiveData, n346)
+				if err := d.ReadBytes(v.Bits); err != nil {
+					return err
+				}
+			}
+		}
+	case &v.Sym:
+		{
+			n347, err := d.ReadSize(false)
+			if err != nil {
+				return err
+			}
+			if n347 > 0 {
+				v.Sym = make(SymKey, n347)
+				if err := d.ReadBytes(v.Sym); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		panic("Union.Select implementation for type SensitiveCompositeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v SensitiveCreate) MarshalMu(e *mu.Encoder) error {
+	if v.UserAuth == nil {
+		e.WriteUint16(0)
+	} else {
+		start348 := e.BeginSized()
+		e.WriteBytes(v.UserAuth)
+		if err := e.EndSized(start348); err != nil {
+			return err
+		}
+	}
+	if v.Data == nil {
+		e.WriteUint16(0)
+	} else {
+		start349 := e.BeginSized()
+		e.WriteBytes(v.Data)
+		if err := e.EndSized(start349); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SensitiveCreate) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n350, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n350 > 0 {
+			v.UserAuth = make(Auth, n350)
+			if err := d.ReadBytes(v.UserAuth); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n351, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n351 > 0 {
+			v.Data = make(SensitiveData, n351)
+			if err := d.ReadBytes(v.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SensitiveData) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start352 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start352); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SensitiveData) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n353, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n353 > 0 {
+			(*v) = make(SensitiveData, n353)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SessionAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *SessionAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x354, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = SessionAttributes(x354)
+	}
+	return nil
+}
+
+func (v SessionAuditEntry) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.CommandCode))
+	if v.CpHash == nil {
+		e.WriteUint16(0)
+	} else {
+		start355 := e.BeginSized()
+		e.WriteBytes(v.CpHash)
+		if err := e.EndSized(start355); err != nil {
+			return err
+		}
+	}
+	if v.RpHash == nil {
+		e.WriteUint16(0)
+	} else {
+		start356 := e.BeginSized()
+		e.WriteBytes(v.RpHash)
+		if err := e.EndSized(start356); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SessionAuditEntry) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x357, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.CommandCode = CommandCode(x357)
+	}
+	{
+		n358, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n358 > 0 {
+			v.CpHash = make(Digest, n358)
+			if err := d.ReadBytes(v.CpHash); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n359, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n359 > 0 {
+			v.RpHash = make(Digest, n359)
+			if err := d.ReadBytes(v.RpHash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SessionAuditInfo) MarshalMu(e *mu.Encoder) error {
+	e.WriteBool(bool(v.ExclusiveSession))
+	if v.SessionDigest == nil {
+		e.WriteUint16(0)
+	} else {
+		start360 := e.BeginSized()
+		e.WriteBytes(v.SessionDigest)
+		if err := e.EndSized(start360); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SessionAuditInfo) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x361, err := d.ReadBool()
+		if err != nil {
+			return err
+		}
+		v.ExclusiveSession = bool(x361)
+	}
+	{
+		n362, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n362 > 0 {
+			v.SessionDigest = make(Digest, n362)
+			if err := d.ReadBytes(v.SessionDigest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SessionType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *SessionType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x363, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = SessionType(x363)
+	}
+	return nil
+}
+
+func (v SigScheme) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Scheme))
+	{
+		p364 := v.Details
+		if p364 == nil {
+			p364 = new(SigSchemeU)
+		}
+		if err := (*p364).marshalMu(e, reflect.ValueOf(v.Scheme)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SigScheme) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x365, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Scheme = SigSchemeId(x365)
+	}
+	if v.Details == nil {
+		v.Details = new(SigSchemeU)
+	}
+	if err := (*v.Details).unmarshalMu(d, reflect.ValueOf(v.Scheme)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v SigSchemeECDAA) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	e.WriteUint16(uint16(v.Count))
+	return nil
+}
+
+func (v *SigSchemeECDAA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x366, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x366)
+	}
+	{
+		x367, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Count = uint16(x367)
+	}
+	return nil
+}
+
+func (v SigSchemeECDSA) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SigSchemeECDSA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x368, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x368)
+	}
+	return nil
+}
+
+func (v SigSchemeECSCHNORR) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SigSchemeECSCHNORR) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x369, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x369)
+	}
+	return nil
+}
+
+func (v SigSchemeId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *SigSchemeId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x370, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = SigSchemeId(x370)
+	}
+	return nil
+}
+
+func (v SigSchemeRSAPSS) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SigSchemeRSAPSS) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x371, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x371)
+	}
+	return nil
+}
+
+func (v SigSchemeRSASSA) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SigSchemeRSASSA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x372, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x372)
+	}
+	return nil
+}
+
+func (v SigSchemeSM2) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.HashAlg))
+	return nil
+}
+
+func (v *SigSchemeSM2) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x373, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x373)
+	}
+	return nil
+}
+
+func (v *SigSchemeU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.RSASSA:
+		{
+			p374 := v.RSASSA
+			if p374 == nil {
+				p374 = new(SigSchemeRSASSA)
+			}
+			if err := (*p374).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.RSAPSS:
+		{
+			p375 := v.RSAPSS
+			if p375 == nil {
+				p375 = new(SigSchemeRSAPSS)
+			}
+			if err := (*p375).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECDSA:
+		{
+			p376 := v.ECDSA
+			if p376 == nil {
+				p376 = new(SigSchemeECDSA)
+			}
+			if err := (*p376).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECDAA:
+		{
+			p377 := v.ECDAA
+			if p377 == nil {
+				p377 = new(SigSchemeECDAA)
+			}
+			if err := (*p377).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.SM2:
+		{
+			p378 := v.SM2
+			if p378 == nil {
+				p378 = new(SigSchemeSM2)
+			}
+			if err := (*p378).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECSCHNORR:
+		{
+			p379 := v.ECSCHNORR
+			if p379 == nil {
+				p379 = new(SigSchemeECSCHNORR)
+			}
+			if err := (*p379).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.HMAC:
+		{
+			p380 := v.HMAC
+			if p380 == nil {
+				p380 = new(SchemeHMAC)
+			}
+			if err := (*p380).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type SigSchemeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *SigSchemeU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.RSASSA:
+		if v.RSASSA == nil {
+			v.RSASSA = new(SigSchemeRSASSA)
+		}
+		if err := (*v.RSASSA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.RSAPSS:
+		if v.RSAPSS == nil {
+			v.RSAPSS = new(SigSchemeRSAPSS)
+		}
+		if err := (*v.RSAPSS).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECDSA:
+		if v.ECDSA == nil {
+			v.ECDSA = new(SigSchemeECDSA)
+		}
+		if err := (*v.ECDSA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECDAA:
+		if v.ECDAA == nil {
+			v.ECDAA = new(SigSchemeECDAA)
+		}
+		if err := (*v.ECDAA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.SM2:
+		if v.SM2 == nil {
+			v.SM2 = new(SigSchemeSM2)
+		}
+		if err := (*v.SM2).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECSCHNORR:
+		if v.ECSCHNORR == nil {
+			v.ECSCHNORR = new(SigSchemeECSCHNORR)
+		}
+		if err := (*v.ECSCHNORR).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.HMAC:
+		if v.HMAC == nil {
+			v.HMAC = new(SchemeHMAC)
+		}
+		if err := (*v.HMAC).UnmarshalMu(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type SigSchemeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v Signature) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.SigAlg))
+	{
+		p381 := v.Signature
+		if p381 == nil {
+			p381 = new(SignatureU)
+		}
+		if err := (*p381).marshalMu(e, reflect.ValueOf(v.SigAlg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Signature) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x382, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.SigAlg = SigSchemeId(x382)
+	}
+	if v.Signature == nil {
+		v.Signature = new(SignatureU)
+	}
+	if err := (*v.Signature).unmarshalMu(d, reflect.ValueOf(v.SigAlg)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v SignatureECC) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if v.SignatureR == nil {
+		e.WriteUint16(0)
+	} else {
+		start383 := e.BeginSized()
+		e.WriteBytes(v.SignatureR)
+		if err := e.EndSized(start383); err != nil {
+			return err
+		}
+	}
+	if v.SignatureS == nil {
+		e.WriteUint16(0)
+	} else {
+		start384 := e.BeginSized()
+		e.WriteBytes(v.SignatureS)
+		if err := e.EndSized(start384); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SignatureECC) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x385, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x385)
+	}
+	{
+		n386, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n386 > 0 {
+			v.SignatureR = make(ECCParameter, n386)
+			if err := d.ReadBytes(v.SignatureR); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n387, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n387 > 0 {
+			v.SignatureS = make(ECCParameter, n387)
+			if err := d.ReadBytes(v.SignatureS); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SignatureECDAA) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if v.SignatureR == nil {
+		e.WriteUint16(0)
+	} else {
+		start388 := e.BeginSized()
+		e.WriteBytes(v.SignatureR)
+		if err := e.EndSized(start388); err != nil {
+			return err
+		}
+	}
+	if v.SignatureS == nil {
+		e.WriteUint16(0)
+	} else {
+		start389 := e.BeginSized()
+		e.WriteBytes(v.SignatureS)
+		if err := e.EndSized(start389); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SignatureECDAA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x390, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x390)
+	}
+	{
+		n391, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n391 > 0 {
+			v.SignatureR = make(ECCParameter, n391)
+			if err := d.ReadBytes(v.SignatureR); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n392, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n392 > 0 {
+			v.SignatureS = make(ECCParameter, n392)
+			if err := d.ReadBytes(v.SignatureS); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SignatureECDSA) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if v.SignatureR == nil {
+		e.WriteUint16(0)
+	} else {
+		start393 := e.BeginSized()
+		e.WriteBytes(v.SignatureR)
+		if err := e.EndSized(start393); err != nil {
+			return err
+		}
+	}
+	if v.SignatureS == nil {
+		e.WriteUint16(0)
+	} else {
+		start394 := e.BeginSized()
+		e.WriteBytes(v.SignatureS)
+		if err := e.EndSized(start394); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SignatureECDSA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x395, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x395)
+	}
+	{
+		n396, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n396 > 0 {
+			v.SignatureR = make(ECCParameter, n396)
+			if err := d.ReadBytes(v.SignatureR); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n397, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n397 > 0 {
+			v.SignatureS = make(ECCParameter, n397)
+			if err := d.ReadBytes(v.SignatureS); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SignatureECSCHNORR) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if v.SignatureR == nil {
+		e.WriteUint16(0)
+	} else {
+		start398 := e.BeginSized()
+		e.WriteBytes(v.SignatureR)
+		if err := e.EndSized(start398); err != nil {
+			return err
+		}
+	}
+	if v.SignatureS == nil {
+		e.WriteUint16(0)
+	} else {
+		start399 := e.BeginSized()
+		e.WriteBytes(v.SignatureS)
+		if err := e.EndSized(start399); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SignatureECSCHNORR) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x400, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x400)
+	}
+	{
+		n401, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n401 > 0 {
+			v.SignatureR = make(ECCParameter, n401)
+			if err := d.ReadBytes(v.SignatureR); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n402, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n402 > 0 {
+			v.SignatureS = make(ECCParameter, n402)
+			if err := d.ReadBytes(v.SignatureS); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SignatureRSA) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if v.Sig == nil {
+		e.WriteUint16(0)
+	} else {
+		start403 := e.BeginSized()
+		e.WriteBytes(v.Sig)
+		if err := e.EndSized(start403); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SignatureRSA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x404, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x404)
+	}
+	{
+		n405, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n405 > 0 {
+			v.Sig = make(PublicKeyRSA, n405)
+			if err := d.ReadBytes(v.Sig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SignatureRSAPSS) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if v.Sig == nil {
+		e.WriteUint16(0)
+	} else {
+		start406 := e.BeginSized()
+		e.WriteBytes(v.Sig)
+		if err := e.EndSized(start406); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SignatureRSAPSS) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x407, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x407)
+	}
+	{
+		n408, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n408 > 0 {
+			v.Sig = make(PublicKeyRSA, n408)
+			if err := d.ReadBytes(v.Sig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SignatureRSASSA) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if v.Sig == nil {
+		e.WriteUint16(0)
+	} else {
+		start409 := e.BeginSized()
+		e.WriteBytes(v.Sig)
+		if err := e.EndSized(start409); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SignatureRSASSA) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x410, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x410)
+	}
+	{
+		n411, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n411 > 0 {
+			v.Sig = make(PublicKeyRSA, n411)
+			if err := d.ReadBytes(v.Sig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v SignatureSM2) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Hash))
+	if v.SignatureR == nil {
+		e.WriteUint16(0)
+	} else {
+		start412 := e.BeginSized()
+		e.WriteBytes(v.SignatureR)
+		if err := e.EndSized(start412); err != nil {
+			return err
+		}
+	}
+	if v.SignatureS == nil {
+		e.WriteUint16(0)
+	} else {
+		start413 := e.BeginSized()
+		e.WriteBytes(v.SignatureS)
+		if err := e.EndSized(start413); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SignatureSM2) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x414, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Hash = HashAlgorithmId(x414)
+	}
+	{
+		n415, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n415 > 0 {
+			v.SignatureR = make(ECCParameter, n415)
+			if err := d.ReadBytes(v.SignatureR); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n416, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n416 > 0 {
+			v.SignatureS = make(ECCParameter, n416)
+			if err := d.ReadBytes(v.SignatureS); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *SignatureU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.RSASSA:
+		{
+			p417 := v.RSASSA
+			if p417 == nil {
+				p417 = new(SignatureRSASSA)
+			}
+			if err := (*p417).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.RSAPSS:
+		{
+			p418 := v.RSAPSS
+			if p418 == nil {
+				p418 = new(SignatureRSAPSS)
+			}
+			if err := (*p418).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECDSA:
+		{
+			p419 := v.ECDSA
+			if p419 == nil {
+				p419 = new(SignatureECDSA)
+			}
+			if err := (*p419).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECDAA:
+		{
+			p420 := v.ECDAA
+			if p420 == nil {
+				p420 = new(SignatureECDAA)
+			}
+			if err := (*p420).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.SM2:
+		{
+			p421 := v.SM2
+			if p421 == nil {
+				p421 = new(SignatureSM2)
+			}
+			if err := (*p421).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.ECSCHNORR:
+		{
+			p422 := v.ECSCHNORR
+			if p422 == nil {
+				p422 = new(SignatureECSCHNORR)
+			}
+			if err := (*p422).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.HMAC:
+		{
+			p423 := v.HMAC
+			if p423 == nil {
+				p423 = new(TaggedHash)
+			}
+			if err := (*p423).Marshal(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type SignatureU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *SignatureU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.RSASSA:
+		if v.RSASSA == nil {
+			v.RSASSA = new(SignatureRSASSA)
+		}
+		if err := (*v.RSASSA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.RSAPSS:
+		if v.RSAPSS == nil {
+			v.RSAPSS = new(SignatureRSAPSS)
+		}
+		if err := (*v.RSAPSS).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECDSA:
+		if v.ECDSA == nil {
+			v.ECDSA = new(SignatureECDSA)
+		}
+		if err := (*v.ECDSA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECDAA:
+		if v.ECDAA == nil {
+			v.ECDAA = new(SignatureECDAA)
+		}
+		if err := (*v.ECDAA).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.SM2:
+		if v.SM2 == nil {
+			v.SM2 = new(SignatureSM2)
+		}
+		if err := (*v.SM2).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.ECSCHNORR:
+		if v.ECSCHNORR == nil {
+			v.ECSCHNORR = new(SignatureECSCHNORR)
+		}
+		if err := (*v.ECSCHNORR).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.HMAC:
+		if v.HMAC == nil {
+			v.HMAC = new(TaggedHash)
+		}
+		if err := (*v.HMAC).Unmarshal(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type SignatureU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v StartupClearAttributes) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *StartupClearAttributes) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x424, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = StartupClearAttributes(x424)
+	}
+	return nil
+}
+
+func (v StartupType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *StartupType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x425, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = StartupType(x425)
+	}
+	return nil
+}
+
+func (v StructTag) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *StructTag) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x426, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = StructTag(x426)
+	}
+	return nil
+}
+
+func (v SwtpmBlobType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *SwtpmBlobType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x427, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = SwtpmBlobType(x427)
+	}
+	return nil
+}
+
+func (v SwtpmCapabilities) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint64(uint64(v))
+	return nil
+}
+
+func (v *SwtpmCapabilities) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x428, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		(*v) = SwtpmCapabilities(x428)
+	}
+	return nil
+}
+
+func (v SwtpmInitFlags) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *SwtpmInitFlags) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x429, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = SwtpmInitFlags(x429)
+	}
+	return nil
+}
+
+func (v SymAlgorithmId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *SymAlgorithmId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x430, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = SymAlgorithmId(x430)
+	}
+	return nil
+}
+
+func (v SymCipherParams) MarshalMu(e *mu.Encoder) error {
+	if err := v.Sym.MarshalMu(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *SymCipherParams) UnmarshalMu(d *mu.Decoder) error {
+	if err := v.Sym.UnmarshalMu(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v SymDef) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Algorithm))
+	{
+		p431 := v.KeyBits
+		if p431 == nil {
+			p431 = new(SymKeyBitsU)
+		}
+		if err := (*p431).marshalMu(e, reflect.ValueOf(v.Algorithm)); err != nil {
+			return err
+		}
+	}
+	{
+		p432 := v.Mode
+		if p432 == nil {
+			p432 = new(SymModeU)
+		}
+		if err := (*p432).marshalMu(e, reflect.ValueOf(v.Algorithm)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SymDef) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x433, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Algorithm = SymAlgorithmId(x433)
+	}
+	if v.KeyBits == nil {
+		v.KeyBits = new(SymKeyBitsU)
+	}
+	if err := (*v.KeyBits).unmarshalMu(d, reflect.ValueOf(v.Algorithm)); err != nil {
+		return err
+	}
+	if v.Mode == nil {
+		v.Mode = new(SymModeU)
+	}
+	if err := (*v.Mode).unmarshalMu(d, reflect.ValueOf(v.Algorithm)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v SymDefObject) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Algorithm))
+	{
+		p434 := v.KeyBits
+		if p434 == nil {
+			p434 = new(SymKeyBitsU)
+		}
+		if err := (*p434).marshalMu(e, reflect.ValueOf(v.Algorithm)); err != nil {
+			return err
+		}
+	}
+	{
+		p435 := v.Mode
+		if p435 == nil {
+			p435 = new(SymModeU)
+		}
+		if err := (*p435).marshalMu(e, reflect.ValueOf(v.Algorithm)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SymDefObject) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x436, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Algorithm = SymObjectAlgorithmId(x436)
+	}
+	if v.KeyBits == nil {
+		v.KeyBits = new(SymKeyBitsU)
+	}
+	if err := (*v.KeyBits).unmarshalMu(d, reflect.ValueOf(v.Algorithm)); err != nil {
+		return err
+	}
+	if v.Mode == nil {
+		v.Mode = new(SymModeU)
+	}
+	if err := (*v.Mode).unmarshalMu(d, reflect.ValueOf(v.Algorithm)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v SymKey) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start437 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start437); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SymKey) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n438, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n438 > 0 {
+			(*v) = make(SymKey, n438)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *SymKeyBitsU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.Sym:
+		e.WriteUint16(uint16(v.Sym))
+	case &v.XOR:
+		e.WriteUint16(uint16(v.XOR))
+	default:
+		panic("Union.Select implementation for type SymKeyBitsU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *SymKeyBitsU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.Sym:
+		{
+			x439, err := d.ReadUint16()
+			if err != nil {
+				return err
+			}
+			v.Sym = uint16(x439)
+		}
+	case &v.XOR:
+		{
+			x440, err := d.ReadUint16()
+			if err != nil {
+				return err
+			}
+			v.XOR = HashAlgorithmId(x440)
+		}
+	default:
+		panic("Union.Select implementation for type SymKeyBitsU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v SymModeId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *SymModeId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x441, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = SymModeId(x441)
+	}
+	return nil
+}
+
+func (v *SymModeU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.Sym:
+		e.WriteUint16(uint16(v.Sym))
+	default:
+		panic("Union.Select implementation for type SymModeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *SymModeU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.Sym:
+		{
+			x442, err := d.ReadUint16()
+			if err != nil {
+				return err
+			}
+			v.Sym = SymModeId(x442)
+		}
+	default:
+		panic("Union.Select implementation for type SymModeU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v SymObjectAlgorithmId) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v))
+	return nil
+}
+
+func (v *SymObjectAlgorithmId) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x443, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		(*v) = SymObjectAlgorithmId(x443)
+	}
+	return nil
+}
+
+func (v TPMGenerated) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *TPMGenerated) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x444, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = TPMGenerated(x444)
+	}
+	return nil
+}
+
+func (v TPMManufacturer) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v))
+	return nil
+}
+
+func (v *TPMManufacturer) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x445, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		(*v) = TPMManufacturer(x445)
+	}
+	return nil
+}
+
+func (v TaggedHashList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i446 := range v {
+		if err := v[i446].Marshal(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TaggedHashList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n447, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n447 {
+			(*v) = make(TaggedHashList, 0, n447)
+		}
+		(*v) = (*v)[:0]
+		for i448 := 0; i448 < n447; i448++ {
+			var zero449 TaggedHash
+			(*v) = append((*v), zero449)
+			if err := (*v)[i448].Unmarshal(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v TaggedPCRPropertyList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i450 := range v {
+		if err := v[i450].MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TaggedPCRPropertyList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n451, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n451 {
+			(*v) = make(TaggedPCRPropertyList, 0, n451)
+		}
+		(*v) = (*v)[:0]
+		for i452 := 0; i452 < n451; i452++ {
+			var zero453 TaggedPCRSelect
+			(*v) = append((*v), zero453)
+			if err := (*v)[i452].UnmarshalMu(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v TaggedPCRSelect) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Tag))
+	if err := v.Select.Marshal(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *TaggedPCRSelect) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x454, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Tag = PropertyPCR(x454)
+	}
+	if err := v.Select.Unmarshal(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v TaggedPolicy) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Handle))
+	if err := v.PolicyHash.Marshal(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *TaggedPolicy) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x455, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Handle = Handle(x455)
+	}
+	if err := v.PolicyHash.Unmarshal(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v TaggedPolicyList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i456 := range v {
+		if err := v[i456].MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TaggedPolicyList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n457, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n457 {
+			(*v) = make(TaggedPolicyList, 0, n457)
+		}
+		(*v) = (*v)[:0]
+		for i458 := 0; i458 < n457; i458++ {
+			var zero459 TaggedPolicy
+			(*v) = append((*v), zero459)
+			if err := (*v)[i458].UnmarshalMu(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v TaggedProperty) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint32(uint32(v.Property))
+	e.WriteUint32(uint32(v.Value))
+	return nil
+}
+
+func (v *TaggedProperty) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x460, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Property = Property(x460)
+	}
+	{
+		x461, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Value = uint32(x461)
+	}
+	return nil
+}
+
+func (v TaggedTPMPropertyList) MarshalMu(e *mu.Encoder) error {
+	if err := e.WriteListLength(len(v)); err != nil {
+		return err
+	}
+	for i462 := range v {
+		if err := v[i462].MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TaggedTPMPropertyList) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n463, err := d.ReadListLength()
+		if err != nil {
+			return err
+		}
+		if (*v) == nil || cap((*v)) < n463 {
+			(*v) = make(TaggedTPMPropertyList, 0, n463)
+		}
+		(*v) = (*v)[:0]
+		for i464 := 0; i464 < n463; i464++ {
+			var zero465 TaggedProperty
+			(*v) = append((*v), zero465)
+			if err := (*v)[i464].UnmarshalMu(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Template) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start466 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start466); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Template) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n467, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n467 > 0 {
+			(*v) = make(Template, n467)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v TimeAttestInfo) MarshalMu(e *mu.Encoder) error {
+	if err := v.Time.MarshalMu(e); err != nil {
+		return err
+	}
+	e.WriteUint64(uint64(v.FirmwareVersion))
+	return nil
+}
+
+func (v *TimeAttestInfo) UnmarshalMu(d *mu.Decoder) error {
+	if err := v.Time.UnmarshalMu(d); err != nil {
+		return err
+	}
+	{
+		x468, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		v.FirmwareVersion = uint64(x468)
+	}
+	return nil
+}
+
+func (v TimeInfo) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint64(uint64(v.Time))
+	if err := v.ClockInfo.MarshalMu(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *TimeInfo) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x469, err := d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		v.Time = uint64(x469)
+	}
+	if err := v.ClockInfo.UnmarshalMu(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v Timeout) MarshalMu(e *mu.Encoder) error {
+	if v == nil {
+		e.WriteUint16(0)
+	} else {
+		start470 := e.BeginSized()
+		e.WriteBytes(v)
+		if err := e.EndSized(start470); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Timeout) UnmarshalMu(d *mu.Decoder) error {
+	{
+		n471, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n471 > 0 {
+			(*v) = make(Timeout, n471)
+			if err := d.ReadBytes((*v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v TkAuth) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Tag))
+	e.WriteUint32(uint32(v.Hierarchy))
+	if v.Digest == nil {
+		e.WriteUint16(0)
+	} else {
+		start472 := e.BeginSized()
+		e.WriteBytes(v.Digest)
+		if err := e.EndSized(start472); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TkAuth) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x473, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Tag = StructTag(x473)
+	}
+	{
+		x474, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Hierarchy = Handle(x474)
+	}
+	{
+		n475, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n475 > 0 {
+			v.Digest = make(Digest, n475)
+			if err := d.ReadBytes(v.Digest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v TkCreation) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Tag))
+	e.WriteUint32(uint32(v.Hierarchy))
+	if v.Digest == nil {
+		e.WriteUint16(0)
+	} else {
+		start476 := e.BeginSized()
+		e.WriteBytes(v.Digest)
+		if err := e.EndSized(start476); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TkCreation) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x477, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Tag = StructTag(x477)
+	}
+	{
+		x478, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Hierarchy = Handle(x478)
+	}
+	{
+		n479, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n479 > 0 {
+			v.Digest = make(Digest, n479)
+			if err := d.ReadBytes(v.Digest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v TkHashcheck) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Tag))
+	e.WriteUint32(uint32(v.Hierarchy))
+	if v.Digest == nil {
+		e.WriteUint16(0)
+	} else {
+		start480 := e.BeginSized()
+		e.WriteBytes(v.Digest)
+		if err := e.EndSized(start480); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TkHashcheck) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x481, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Tag = StructTag(x481)
+	}
+	{
+		x482, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Hierarchy = Handle(x482)
+	}
+	{
+		n483, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n483 > 0 {
+			v.Digest = make(Digest, n483)
+			if err := d.ReadBytes(v.Digest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v TkVerified) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint16(uint16(v.Tag))
+	e.WriteUint32(uint32(v.Hierarchy))
+	if v.Digest == nil {
+		e.WriteUint16(0)
+	} else {
+		start484 := e.BeginSized()
+		e.WriteBytes(v.Digest)
+		if err := e.EndSized(start484); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TkVerified) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x485, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.Tag = StructTag(x485)
+	}
+	{
+		x486, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.Hierarchy = Handle(x486)
+	}
+	{
+		n487, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n487 > 0 {
+			v.Digest = make(Digest, n487)
+			if err := d.ReadBytes(v.Digest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v WarningCode) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *WarningCode) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x488, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = WarningCode(x488)
+	}
+	return nil
+}
+
+func (v handleContext) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v.Type))
+	e.WriteUint32(uint32(v.H))
+	if v.N == nil {
+		e.WriteUint16(0)
+	} else {
+		start489 := e.BeginSized()
+		e.WriteBytes(v.N)
+		if err := e.EndSized(start489); err != nil {
+			return err
+		}
+	}
+	{
+		p490 := v.Data
+		if p490 == nil {
+			p490 = new(handleContextU)
+		}
+		if err := (*p490).marshalMu(e, reflect.ValueOf(v.Type)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *handleContext) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x491, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		v.Type = handleContextType(x491)
+	}
+	{
+		x492, err := d.ReadUint32()
+		if err != nil {
+			return err
+		}
+		v.H = Handle(x492)
+	}
+	{
+		n493, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n493 > 0 {
+			v.N = make(Name, n493)
+			if err := d.ReadBytes(v.N); err != nil {
+				return err
+			}
+		}
+	}
+	if v.Data == nil {
+		v.Data = new(handleContextU)
+	}
+	if err := (*v.Data).unmarshalMu(d, reflect.ValueOf(v.Type)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v handleContextType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *handleContextType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x494, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = handleContextType(x494)
+	}
+	return nil
+}
+
+func (v *handleContextU) marshalMu(e *mu.Encoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil, mu.NilUnionValue:
+	case &v.Object:
+		{
+			p495 := v.Object
+			if p495 == nil {
+				p495 = new(Public)
+			}
+			if err := (*p495).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.NV:
+		{
+			p496 := v.NV
+			if p496 == nil {
+				p496 = new(NVPublic)
+			}
+			if err := (*p496).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	case &v.Session:
+		{
+			p497 := v.Session
+			if p497 == nil {
+				p497 = new(sessionContextData)
+			}
+			if err := (*p497).MarshalMu(e); err != nil {
+				return err
+			}
+		}
+	default:
+		panic("Union.Select implementation for type handleContextU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v *handleContextU) unmarshalMu(d *mu.Decoder, selector reflect.Value) error {
+	switch v.Select(selector) {
+	case nil:
+		return &mu.InvalidSelectorError{Selector: selector}
+	case mu.NilUnionValue:
+	case &v.Object:
+		if v.Object == nil {
+			v.Object = new(Public)
+		}
+		if err := (*v.Object).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.NV:
+		if v.NV == nil {
+			v.NV = new(NVPublic)
+		}
+		if err := (*v.NV).UnmarshalMu(d); err != nil {
+			return err
+		}
+	case &v.Session:
+		if v.Session == nil {
+			v.Session = new(sessionContextData)
+		}
+		if err := (*v.Session).UnmarshalMu(d); err != nil {
+			return err
+		}
+	default:
+		panic("Union.Select implementation for type handleContextU returned a non-member pointer")
+	}
+	return nil
+}
+
+func (v policyHMACType) MarshalMu(e *mu.Encoder) error {
+	e.WriteUint8(uint8(v))
+	return nil
+}
+
+func (v *policyHMACType) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x498, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		(*v) = policyHMACType(x498)
+	}
+	return nil
+}
+
+func (v sessionContextData) MarshalMu(e *mu.Encoder) error {
+	e.WriteBool(bool(v.IsAudit))
+	e.WriteBool(bool(v.IsExclusive))
+	e.WriteUint16(uint16(v.HashAlg))
+	e.WriteUint8(uint8(v.SessionType))
+	e.WriteUint8(uint8(v.PolicyHMACType))
+	e.WriteBool(bool(v.IsBound))
+	if v.BoundEntity == nil {
+		e.WriteUint16(0)
+	} else {
+		start499 := e.BeginSized()
+		e.WriteBytes(v.BoundEntity)
+		if err := e.EndSized(start499); err != nil {
+			return err
+		}
+	}
+	if v.SessionKey == nil {
+		e.WriteUint16(0)
+	} else {
+		start500 := e.BeginSized()
+		e.WriteBytes(v.SessionKey)
+		if err := e.EndSized(start500); err != nil {
+			return err
+		}
+	}
+	if v.NonceCaller == nil {
+		e.WriteUint16(0)
+	} else {
+		start501 := e.BeginSized()
+		e.WriteBytes(v.NonceCaller)
+		if err := e.EndSized(start501); err != nil {
+			return err
+		}
+	}
+	if v.NonceTPM == nil {
+		e.WriteUint16(0)
+	} else {
+		start502 := e.BeginSized()
+		e.WriteBytes(v.NonceTPM)
+		if err := e.EndSized(start502); err != nil {
+			return err
+		}
+	}
+	{
+		p503 := v.Symmetric
+		if p503 == nil {
+			p503 = new(SymDef)
+		}
+		if err := (*p503).MarshalMu(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *sessionContextData) UnmarshalMu(d *mu.Decoder) error {
+	{
+		x504, err := d.ReadBool()
+		if err != nil {
+			return err
+		}
+		v.IsAudit = bool(x504)
+	}
+	{
+		x505, err := d.ReadBool()
+		if err != nil {
+			return err
+		}
+		v.IsExclusive = bool(x505)
+	}
+	{
+		x506, err := d.ReadUint16()
+		if err != nil {
+			return err
+		}
+		v.HashAlg = HashAlgorithmId(x506)
+	}
+	{
+		x507, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		v.SessionType = SessionType(x507)
+	}
+	{
+		x508, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		v.PolicyHMACType = policyHMACType(x508)
+	}
+	{
+		x509, err := d.ReadBool()
+		if err != nil {
+			return err
+		}
+		v.IsBound = bool(x509)
+	}
+	{
+		n510, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n510 > 0 {
+			v.BoundEntity = make(Name, n510)
+			if err := d.ReadBytes(v.BoundEntity); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n511, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n511 > 0 {
+			v.SessionKey = make([]byte, n511)
+			if err := d.ReadBytes(v.SessionKey); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n512, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n512 > 0 {
+			v.NonceCaller = make(Nonce, n512)
+			if err := d.ReadBytes(v.NonceCaller); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n513, err := d.ReadSize(false)
+		if err != nil {
+			return err
+		}
+		if n513 > 0 {
+			v.NonceTPM = make(Nonce, n513)
+			if err := d.ReadBytes(v.NonceTPM); err != nil {
+				return err
+			}
+		}
+	}
+	if v.Symmetric == nil {
+		v.Symmetric = new(SymDef)
+	}
+	if err := (*v.Symmetric).UnmarshalMu(d); err != nil {
+		return err
+	}
+	return nil
+}
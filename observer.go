@@ -0,0 +1,125 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2
+
+import (
+	"time"
+
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// CommandEvent describes a command that was executed by a TPMContext. It is supplied to CommandObserver implementations.
+type CommandEvent struct {
+	CommandCode CommandCode
+	Handles     HandleList // The command handles. This is nil for commands executed by TPMContext.RunCommandBytes.
+
+	// Sessions contains the attributes of the sessions used for the command, in the order in which they appear in the
+	// authorization area. This is nil for commands executed by TPMContext.RunCommandBytes.
+	Sessions []SessionAttributes
+
+	// Parameters contains the command parameters, as supplied to TPMContext.RunCommand. This is nil for commands executed
+	// by TPMContext.RunCommandBytes. The first parameter is encrypted on the wire if ParametersEncrypted is true.
+	Parameters          []interface{}
+	ParametersEncrypted bool
+
+	// ResponseParameters contains pointers to the response parameters, as supplied to TPMContext.RunCommand. These are
+	// only populated if the command succeeded. This is nil for commands executed by TPMContext.RunCommandBytes. The
+	// first parameter was encrypted on the wire if ResponseParametersEncrypted is true.
+	//
+	// Observers must not modify or retain these.
+	ResponseParameters          []interface{}
+	ResponseParametersEncrypted bool
+
+	// Submissions is the number of times that the command was submitted to the TPM. This will be greater than 1 if
+	// the TPM asked for the command to be retried.
+	Submissions uint
+
+	// Duration is the time taken to execute the command on the TPM, including any retries.
+	Duration time.Duration
+
+	// ResponseCode is the response code from the last submission. This will be zero if the command failed before a
+	// response was received.
+	ResponseCode ResponseCode
+
+	// Err is the error returned to the caller, if any. This may be a TPM error decoded from ResponseCode, an error
+	// returned from the transmission interface or an error indicating that the response was invalid.
+	Err error
+}
+
+// CommandObserver is implemented by types that want to be notified of the commands executed by a TPMContext, eg, for the
+// purposes of tracing or collecting metrics. See TPMContext.AddCommandObserver.
+type CommandObserver interface {
+	// CommandExecuted is called when a command has completed. It is called from the goroutine that executed the command.
+	CommandExecuted(event *CommandEvent)
+}
+
+// AddCommandObserver registers the supplied observer, which will be notified of every command that is subsequently
+// executed by this TPMContext via RunCommand, RunCommandWithResponseCallback, RunCommandBytes and their variants. This
+// includes commands executed by the methods that wrap these. Observers are notified in the order in which they are
+// added.
+//
+// Commands that fail before they are submitted to the TPM (eg, because of an invalid argument) are not observed.
+func (t *TPMContext) AddCommandObserver(observer CommandObserver) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.commandObservers = append(t.commandObservers, observer)
+}
+
+// RemoveCommandObserver removes a previously registered observer.
+func (t *TPMContext) RemoveCommandObserver(observer CommandObserver) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, o := range t.commandObservers {
+		if o == observer {
+			// Copy the slice, as commands may be iterating over the current one.
+			t.commandObservers = append(t.commandObservers[:i:i], t.commandObservers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *TPMContext) notifyCommandObservers(event *CommandEvent) {
+	if event.Submissions == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	observers := t.commandObservers
+	t.mu.Unlock()
+
+	for _, o := range observers {
+		o.CommandExecuted(event)
+	}
+}
+
+func (t *TPMContext) observeRawCommand(packet CommandPacket, resp ResponsePacket, start time.Time, err error) {
+	t.mu.Lock()
+	hasObservers := len(t.commandObservers) > 0
+	t.mu.Unlock()
+	if !hasObservers {
+		return
+	}
+
+	event := &CommandEvent{
+		Submissions: 1,
+		Duration:    time.Since(start),
+		Err:         err}
+	event.CommandCode, _ = packet.GetCommandCode()
+
+	if err != nil {
+		t.notifyCommandObservers(event)
+		return
+	}
+
+	var hdr ResponseHeader
+	if _, err := mu.UnmarshalFromBytes(resp, &hdr); err != nil {
+		event.Err = &InvalidResponseError{event.CommandCode, "cannot unmarshal response header"}
+	} else {
+		event.ResponseCode = hdr.ResponseCode
+		event.Err = DecodeResponseCode(event.CommandCode, hdr.ResponseCode)
+	}
+	t.notifyCommandObservers(event)
+}
@@ -0,0 +1,19 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package observer provides implementations of tpm2.CommandObserver for collecting metrics about and logging the commands
+executed by a tpm2.TPMContext.
+
+Observers are registered with TPMContext.AddCommandObserver:
+
+	tpm.AddCommandObserver(observer.NewExpvarObserver(expvar.NewMap("tpm2")))
+	tpm.AddCommandObserver(observer.NewLogObserver(log.New(os.Stderr, "", log.LstdFlags)))
+
+The logging observers never log authorization values, sensitive data or the contents of encrypted parameters. Secrets
+that are carried in parameters with types that aren't always sensitive, such as the inner wrapper keys for TPM2_Import
+and TPM2_Duplicate, the plaintext from TPM2_RSA_Decrypt and the shared points from TPM2_ECDH_KeyGen and TPM2_ECDH_ZGen,
+are also omitted.
+*/
+package observer
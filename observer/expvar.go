@@ -0,0 +1,73 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package observer
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// ExpvarObserver is a tpm2.CommandObserver that maintains a set of counters in an expvar.Map. The map contains the
+// following keys:
+//   - commands: the total number of commands executed.
+//   - errors: the total number of commands that failed.
+//   - retries: the total number of times that commands were resubmitted at the request of the TPM.
+//   - durationSeconds: the total time spent executing commands, in seconds.
+//   - responseCodes: a map of non-success response codes to the number of times that they were returned.
+//   - byCommand: a map of command codes to a map containing the commands, errors, retries and durationSeconds counters
+//     for that command.
+type ExpvarObserver struct {
+	m *expvar.Map
+
+	mu        sync.Mutex
+	rcs       *expvar.Map
+	byCommand *expvar.Map
+}
+
+// NewExpvarObserver returns a new ExpvarObserver that maintains counters in the supplied map, which will normally be
+// created with expvar.NewMap.
+func NewExpvarObserver(m *expvar.Map) *ExpvarObserver {
+	o := &ExpvarObserver{
+		m:         m,
+		rcs:       new(expvar.Map).Init(),
+		byCommand: new(expvar.Map).Init()}
+	m.Set("responseCodes", o.rcs)
+	m.Set("byCommand", o.byCommand)
+	return o
+}
+
+func (o *ExpvarObserver) commandMap(commandCode tpm2.CommandCode) *expvar.Map {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	name := commandCode.String()
+	if m, ok := o.byCommand.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	o.byCommand.Set(name, m)
+	return m
+}
+
+func updateCounters(m *expvar.Map, event *tpm2.CommandEvent) {
+	m.Add("commands", 1)
+	if event.Err != nil {
+		m.Add("errors", 1)
+	}
+	m.Add("retries", int64(event.Submissions-1))
+	m.AddFloat("durationSeconds", event.Duration.Seconds())
+}
+
+// CommandExecuted implements tpm2.CommandObserver.CommandExecuted.
+func (o *ExpvarObserver) CommandExecuted(event *tpm2.CommandEvent) {
+	updateCounters(o.m, event)
+	updateCounters(o.commandMap(event.CommandCode), event)
+	if event.ResponseCode != tpm2.ResponseSuccess {
+		o.rcs.Add(fmt.Sprintf("0x%08x", uint32(event.ResponseCode)), 1)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package observer
+
+import (
+	"github.com/canonical/go-tpm2"
+)
+
+// Logger is the interface used by LogObserver to write log messages. It is implemented by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LogObserver is a tpm2.CommandObserver that writes a log message for each command. Authorization values, sensitive
+// data and the contents of encrypted parameters are redacted.
+type LogObserver struct {
+	logger Logger
+}
+
+// NewLogObserver returns a new LogObserver that writes log messages to the supplied logger.
+func NewLogObserver(logger Logger) *LogObserver {
+	return &LogObserver{logger: logger}
+}
+
+// CommandExecuted implements tpm2.CommandObserver.CommandExecuted.
+func (o *LogObserver) CommandExecuted(event *tpm2.CommandEvent) {
+	if event.Err != nil {
+		o.logger.Printf("TPM command %v failed: handles=%s sessions=%s params=%s submissions=%d duration=%v rc=0x%08x err=%q",
+			event.CommandCode, formatHandles(event.Handles), formatSessions(event.Sessions),
+			formatCommandParameters(event), event.Submissions, event.Duration,
+			uint32(event.ResponseCode), event.Err.Error())
+		return
+	}
+	o.logger.Printf("TPM command %v succeeded: handles=%s sessions=%s params=%s rparams=%s submissions=%d duration=%v",
+		event.CommandCode, formatHandles(event.Handles), formatSessions(event.Sessions),
+		formatCommandParameters(event),
+		formatResponseParameters(event), event.Submissions, event.Duration)
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package observer_test
+
+import (
+	"bytes"
+	"errors"
+	"expvar"
+	"log"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	. "github.com/canonical/go-tpm2/observer"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type expvarSuite struct{}
+
+var _ = Suite(&expvarSuite{})
+
+func (s *expvarSuite) TestCommandExecuted(c *C) {
+	m := new(expvar.Map).Init()
+	o := NewExpvarObserver(m)
+
+	o.CommandExecuted(&tpm2.CommandEvent{CommandCode: tpm2.CommandGetRandom, Submissions: 1, Duration: time.Second})
+	o.CommandExecuted(&tpm2.CommandEvent{CommandCode: tpm2.CommandGetRandom, Submissions: 3, Duration: 2 * time.Second})
+	o.CommandExecuted(&tpm2.CommandEvent{
+		CommandCode:  tpm2.CommandUnseal,
+		Submissions:  1,
+		Duration:     time.Second,
+		ResponseCode: tpm2.ResponseBadTag,
+		Err:          errors.New("error")})
+
+	c.Check(m.Get("commands").String(), Equals, "3")
+	c.Check(m.Get("errors").String(), Equals, "1")
+	c.Check(m.Get("retries").String(), Equals, "2")
+	c.Check(m.Get("durationSeconds").String(), Equals, "4")
+	c.Check(m.Get("responseCodes").String(), Equals, `{"0x0000001e": 1}`)
+
+	byCommand := m.Get("byCommand").(*expvar.Map)
+	c.Check(byCommand.Get("TPM_CC_GetRandom").String(), Equals, `{"commands": 2, "durationSeconds": 3, "retries": 2}`)
+	c.Check(byCommand.Get("TPM_CC_Unseal").String(), Equals, `{"commands": 1, "durationSeconds": 1, "errors": 1, "retries": 0}`)
+}
+
+type logSuite struct{}
+
+var _ = Suite(&logSuite{})
+
+func (s *logSuite) TestSuccess(c *C) {
+	var b bytes.Buffer
+	o := NewLogObserver(log.New(&b, "", 0))
+
+	data := tpm2.SensitiveData("secret")
+	o.CommandExecuted(&tpm2.CommandEvent{
+		CommandCode:        tpm2.CommandUnseal,
+		Handles:            tpm2.HandleList{0x80000001},
+		Sessions:           []tpm2.SessionAttributes{tpm2.AttrContinueSession},
+		ResponseParameters: []interface{}{&data},
+		Submissions:        1,
+		Duration:           time.Millisecond})
+	c.Check(b.String(), Equals, "TPM command TPM_CC_Unseal succeeded: handles=[0x80000001] sessions=[0x1] params=[] rparams=[<redacted>] submissions=1 duration=1ms\n")
+}
+
+func (s *logSuite) TestRedactAuth(c *C) {
+	var b bytes.Buffer
+	o := NewLogObserver(log.New(&b, "", 0))
+
+	o.CommandExecuted(&tpm2.CommandEvent{
+		CommandCode: tpm2.CommandCreatePrimary,
+		Handles:     tpm2.HandleList{tpm2.HandleOwner},
+		Parameters: []interface{}{
+			&tpm2.SensitiveCreate{UserAuth: tpm2.Auth("foo"), Data: tpm2.SensitiveData("bar")},
+			tpm2.Data{1, 2},
+			tpm2.PCRSelectionList{{Hash: tpm2.HashAlgorithmSHA256, Select: []int{7}}}},
+		Submissions: 1,
+		Duration:    time.Millisecond})
+	c.Check(b.String(), Equals, "TPM command TPM_CC_CreatePrimary succeeded: handles=[TPM_RH_OWNER] sessions=[] "+
+		"params=[{UserAuth:<redacted> Data:<redacted>} 0102 [{Hash:TPM_ALG_SHA256 Select:[7]}]] rparams=[] submissions=1 duration=1ms\n")
+}
+
+func (s *logSuite) TestRedactEncrypted(c *C) {
+	var b bytes.Buffer
+	o := NewLogObserver(log.New(&b, "", 0))
+
+	o.CommandExecuted(&tpm2.CommandEvent{
+		CommandCode:         tpm2.CommandNVWrite,
+		Handles:             tpm2.HandleList{0x01800000, 0x01800000},
+		Sessions:            []tpm2.SessionAttributes{tpm2.AttrContinueSession | tpm2.AttrCommandEncrypt},
+		Parameters:          []interface{}{tpm2.MaxNVBuffer("secret"), uint16(0)},
+		ParametersEncrypted: true,
+		Submissions:         2,
+		Duration:            time.Millisecond,
+		ResponseCode:        tpm2.ResponseBadTag,
+		Err:                 errors.New("some error")})
+	c.Check(b.String(), Equals, "TPM command TPM_CC_NV_Write failed: handles=[0x01800000 0x01800000] sessions=[0x21] "+
+		"params=[<redacted> 0] submissions=2 duration=1ms rc=0x0000001e err=\"some error\"\n")
+}
+
+func (s *logSuite) TestRedactImport(c *C) {
+	var b bytes.Buffer
+	o := NewLogObserver(log.New(&b, "", 0))
+
+	o.CommandExecuted(&tpm2.CommandEvent{
+		CommandCode: tpm2.CommandImport,
+		Handles:     tpm2.HandleList{0x81000001},
+		Parameters: []interface{}{
+			tpm2.Data("secret"),
+			tpm2.Private{1, 2},
+			tpm2.EncryptedSecret{3, 4}},
+		ResponseParameters: []interface{}{&tpm2.Private{5, 6}},
+		Submissions:        1,
+		Duration:           time.Millisecond})
+	c.Check(b.String(), Equals, "TPM command TPM_CC_Import succeeded: handles=[0x81000001] sessions=[] "+
+		"params=[<redacted> 0102 0304] rparams=[0506] submissions=1 duration=1ms\n")
+}
+
+func (s *logSuite) TestRedactDuplicate(c *C) {
+	var b bytes.Buffer
+	o := NewLogObserver(log.New(&b, "", 0))
+
+	o.CommandExecuted(&tpm2.CommandEvent{
+		CommandCode: tpm2.CommandDuplicate,
+		Handles:     tpm2.HandleList{0x80000001, 0x81000001},
+		Parameters:  []interface{}{tpm2.Data("secret")},
+		ResponseParameters: []interface{}{
+			&tpm2.Data{0x73, 0x65, 0x63},
+			&tpm2.Private{1, 2},
+			&tpm2.EncryptedSecret{3, 4}},
+		Submissions: 1,
+		Duration:    time.Millisecond})
+	c.Check(b.String(), Equals, "TPM command TPM_CC_Duplicate succeeded: handles=[0x80000001 0x81000001] sessions=[] "+
+		"params=[<redacted>] rparams=[<redacted> 0102 0304] submissions=1 duration=1ms\n")
+}
+
+func (s *logSuite) TestRedactRSADecrypt(c *C) {
+	var b bytes.Buffer
+	o := NewLogObserver(log.New(&b, "", 0))
+
+	o.CommandExecuted(&tpm2.CommandEvent{
+		CommandCode:        tpm2.CommandRSADecrypt,
+		Handles:            tpm2.HandleList{0x80000001},
+		Parameters:         []interface{}{tpm2.PublicKeyRSA{1, 2}},
+		ResponseParameters: []interface{}{&tpm2.PublicKeyRSA{0x73, 0x65, 0x63}},
+		Submissions:        1,
+		Duration:           time.Millisecond})
+	c.Check(b.String(), Equals, "TPM command TPM_CC_RSA_Decrypt succeeded: handles=[0x80000001] sessions=[] "+
+		"params=[0102] rparams=[<redacted>] submissions=1 duration=1ms\n")
+}
+
+func (s *logSuite) TestRedactECDHZGen(c *C) {
+	var b bytes.Buffer
+	o := NewLogObserver(log.New(&b, "", 0))
+
+	o.CommandExecuted(&tpm2.CommandEvent{
+		CommandCode:        tpm2.CommandECDHZGen,
+		Handles:            tpm2.HandleList{0x80000001},
+		Parameters:         []interface{}{mu.Sized(&tpm2.ECCPoint{X: []byte{1}, Y: []byte{2}})},
+		ResponseParameters: []interface{}{mu.Sized(&tpm2.ECCPoint{X: []byte{3}, Y: []byte{4}})},
+		Submissions:        1,
+		Duration:           time.Millisecond})
+	c.Check(b.String(), Equals, "TPM command TPM_CC_ECDH_ZGen succeeded: handles=[0x80000001] sessions=[] "+
+		"params=[{Value:{X:01 Y:02}}] rparams=[<redacted>] submissions=1 duration=1ms\n")
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package observer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/canonical/go-tpm2"
+)
+
+const redacted = "<redacted>"
+
+var (
+	// sensitiveTypes are the types whose values are never logged.
+	sensitiveTypes = map[reflect.Type]bool{
+		reflect.TypeOf(tpm2.Auth(nil)):          true,
+		reflect.TypeOf(tpm2.SensitiveData(nil)): true,
+		reflect.TypeOf(tpm2.Sensitive{}):        true,
+		reflect.TypeOf(tpm2.SymKey(nil)):        true,
+		reflect.TypeOf(tpm2.PrivateKeyRSA(nil)): true}
+
+	// sensitiveCommandParameters are the positions of the command parameters that are never logged for each
+	// command. These contain secrets with types that aren't always sensitive.
+	sensitiveCommandParameters = map[tpm2.CommandCode][]int{
+		tpm2.CommandDuplicate:  {0}, // encryptionKeyIn
+		tpm2.CommandImport:     {0}, // encryptionKey
+		tpm2.CommandRSAEncrypt: {0}, // message
+	}
+
+	// sensitiveResponseParameters are the positions of the response parameters that are never logged for each
+	// command.
+	sensitiveResponseParameters = map[tpm2.CommandCode][]int{
+		tpm2.CommandDuplicate:  {0}, // encryptionKeyOut
+		tpm2.CommandRSADecrypt: {0}, // message
+		tpm2.CommandECDHKeyGen: {0}, // zPoint
+		tpm2.CommandECDHZGen:   {0}, // outPoint
+	}
+
+	formatterType = reflect.TypeOf((*fmt.Formatter)(nil)).Elem()
+	stringerType  = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+func formatValue(b *bytes.Buffer, v reflect.Value) {
+	if !v.IsValid() {
+		b.WriteString("nil")
+		return
+	}
+
+	if sensitiveTypes[v.Type()] {
+		b.WriteString(redacted)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		formatValue(b, v.Elem())
+		return
+	case reflect.Struct:
+		b.WriteString("{")
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Ptr && fv.IsNil() {
+				// Skip unselected union members.
+				continue
+			}
+			if !first {
+				b.WriteString(" ")
+			}
+			first = false
+			b.WriteString(f.Name)
+			b.WriteString(":")
+			formatValue(b, fv)
+		}
+		b.WriteString("}")
+		return
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			b.WriteString(hex.EncodeToString(data))
+			return
+		}
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			formatValue(b, v.Index(i))
+		}
+		b.WriteString("]")
+		return
+	}
+
+	if v.Type().Implements(formatterType) || v.Type().Implements(stringerType) || v.CanInterface() {
+		fmt.Fprintf(b, "%v", v.Interface())
+		return
+	}
+	b.WriteString("?")
+}
+
+// formatParameters returns a string representation of the supplied command or response parameters, with
+// sensitive values, the parameters at the positions in sensitive and the contents of the first parameter if it
+// is encrypted omitted.
+func formatParameters(params []interface{}, encrypted bool, sensitive []int) string {
+	var b bytes.Buffer
+	b.WriteString("[")
+	for i, p := range params {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		if (i == 0 && encrypted) || isSensitiveParameter(sensitive, i) {
+			b.WriteString(redacted)
+			continue
+		}
+		formatValue(&b, reflect.ValueOf(p))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func isSensitiveParameter(sensitive []int, i int) bool {
+	for _, j := range sensitive {
+		if i == j {
+			return true
+		}
+	}
+	return false
+}
+
+func formatCommandParameters(event *tpm2.CommandEvent) string {
+	return formatParameters(event.Parameters, event.ParametersEncrypted, sensitiveCommandParameters[event.CommandCode])
+}
+
+func formatResponseParameters(event *tpm2.CommandEvent) string {
+	return formatParameters(event.ResponseParameters, event.ResponseParametersEncrypted, sensitiveResponseParameters[event.CommandCode])
+}
+
+func formatHandles(handles tpm2.HandleList) string {
+	var s []string
+	for _, h := range handles {
+		s = append(s, h.String())
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+func formatSessions(sessions []tpm2.SessionAttributes) string {
+	var s []string
+	for _, attrs := range sessions {
+		s = append(s, fmt.Sprintf("%#x", uint8(attrs)))
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build go1.21
+// +build go1.21
+
+package observer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// SlogObserver is a tpm2.CommandObserver that writes a structured log record for each command. Successful commands are
+// logged at slog.LevelDebug and failed commands are logged at slog.LevelWarn. Authorization values, sensitive data and the
+// contents of encrypted parameters are redacted.
+type SlogObserver struct {
+	logger *slog.Logger
+}
+
+// NewSlogObserver returns a new SlogObserver that writes log records to the supplied logger.
+func NewSlogObserver(logger *slog.Logger) *SlogObserver {
+	return &SlogObserver{logger: logger}
+}
+
+// CommandExecuted implements tpm2.CommandObserver.CommandExecuted.
+func (o *SlogObserver) CommandExecuted(event *tpm2.CommandEvent) {
+	attrs := []slog.Attr{
+		slog.String("command", event.CommandCode.String()),
+		slog.String("handles", formatHandles(event.Handles)),
+		slog.String("sessions", formatSessions(event.Sessions)),
+		slog.String("params", formatCommandParameters(event)),
+		slog.Uint64("submissions", uint64(event.Submissions)),
+		slog.Duration("duration", event.Duration),
+		slog.String("rc", fmt.Sprintf("0x%08x", uint32(event.ResponseCode)))}
+
+	level := slog.LevelDebug
+	if event.Err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("err", event.Err.Error()))
+	} else {
+		attrs = append(attrs, slog.String("rparams", formatResponseParameters(event)))
+	}
+
+	o.logger.LogAttrs(context.Background(), level, "TPM command", attrs...)
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build go1.21
+// +build go1.21
+
+package observer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	. "github.com/canonical/go-tpm2/observer"
+)
+
+type slogSuite struct{}
+
+var _ = Suite(&slogSuite{})
+
+func (s *slogSuite) log(c *C, level slog.Level, event *tpm2.CommandEvent) map[string]interface{} {
+	var b bytes.Buffer
+	o := NewSlogObserver(slog.New(slog.NewJSONHandler(&b, &slog.HandlerOptions{Level: level})))
+	o.CommandExecuted(event)
+	if b.Len() == 0 {
+		return nil
+	}
+
+	var record map[string]interface{}
+	c.Assert(json.Unmarshal(b.Bytes(), &record), IsNil)
+	delete(record, "time")
+	return record
+}
+
+func (s *slogSuite) TestSuccess(c *C) {
+	auth := tpm2.Auth("foo")
+	event := &tpm2.CommandEvent{
+		CommandCode:        tpm2.CommandGetRandom,
+		Parameters:         []interface{}{uint16(4)},
+		ResponseParameters: []interface{}{&auth},
+		Submissions:        1,
+		Duration:           time.Millisecond}
+
+	c.Check(s.log(c, slog.LevelInfo, event), IsNil)
+	c.Check(s.log(c, slog.LevelDebug, event), DeepEquals, map[string]interface{}{
+		"level":       "DEBUG",
+		"msg":         "TPM command",
+		"command":     "TPM_CC_GetRandom",
+		"handles":     "[]",
+		"sessions":    "[]",
+		"params":      "[4]",
+		"submissions": float64(1),
+		"duration":    float64(time.Millisecond),
+		"rc":          "0x00000000",
+		"rparams":     "[<redacted>]"})
+}
+
+func (s *slogSuite) TestFailure(c *C) {
+	event := &tpm2.CommandEvent{
+		CommandCode:         tpm2.CommandNVWrite,
+		Handles:             tpm2.HandleList{0x01800000, 0x01800000},
+		Sessions:            []tpm2.SessionAttributes{tpm2.AttrContinueSession | tpm2.AttrCommandEncrypt},
+		Parameters:          []interface{}{tpm2.MaxNVBuffer("secret"), uint16(0)},
+		ParametersEncrypted: true,
+		Submissions:         1,
+		Duration:            time.Millisecond,
+		ResponseCode:        tpm2.ResponseBadTag,
+		Err:                 errors.New("some error")}
+
+	c.Check(s.log(c, slog.LevelWarn, event), DeepEquals, map[string]interface{}{
+		"level":       "WARN",
+		"msg":         "TPM command",
+		"command":     "TPM_CC_NV_Write",
+		"handles":     "[0x01800000 0x01800000]",
+		"sessions":    "[0x21]",
+		"params":      "[<redacted> 0]",
+		"submissions": float64(1),
+		"duration":    float64(time.Millisecond),
+		"rc":          "0x0000001e",
+		"err":         "some error"})
+}
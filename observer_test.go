@@ -0,0 +1,137 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+type mockCommandObserver struct {
+	events []*CommandEvent
+}
+
+func (o *mockCommandObserver) CommandExecuted(event *CommandEvent) {
+	o.events = append(o.events, event)
+}
+
+type commandObserverSuite struct {
+	tcti     *slowTCTI
+	tpm      *TPMContext
+	observer *mockCommandObserver
+}
+
+var _ = Suite(&commandObserverSuite{})
+
+func (s *commandObserverSuite) SetUpTest(c *C) {
+	s.tcti = newSlowTCTI()
+	s.tpm, _ = NewTPMContext(s.tcti)
+	s.observer = new(mockCommandObserver)
+	s.tpm.AddCommandObserver(s.observer)
+}
+
+func (s *commandObserverSuite) TestRunCommand(c *C) {
+	s.tcti.release <- makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4})
+
+	var random Digest
+	c.Check(s.tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random), IsNil)
+
+	c.Assert(s.observer.events, HasLen, 1)
+	event := s.observer.events[0]
+	c.Check(event.CommandCode, Equals, CommandGetRandom)
+	c.Check(event.Handles, DeepEquals, HandleList{})
+	c.Check(event.Sessions, IsNil)
+	c.Check(event.Parameters, DeepEquals, []interface{}{uint16(4)})
+	c.Check(event.ParametersEncrypted, testutil.IsFalse)
+	c.Check(event.ResponseParameters, DeepEquals, []interface{}{&random})
+	c.Check(event.Submissions, Equals, uint(1))
+	c.Check(event.ResponseCode, Equals, ResponseSuccess)
+	c.Check(event.Err, IsNil)
+}
+
+func (s *commandObserverSuite) TestRunCommandWithHandles(c *C) {
+	authArea := mu.MustMarshalToBytes(AuthResponse{SessionAttributes: AttrContinueSession})
+	s.tcti.release <- mu.MustMarshalToBytes(
+		ResponseHeader{Tag: TagSessions, ResponseSize: uint32(14 + len(authArea))}, uint32(0), mu.RawBytes(authArea))
+
+	c.Check(s.tpm.RunCommand(CommandClear, nil, ResourceContextWithSession{Context: s.tpm.LockoutHandleContext()}), IsNil)
+
+	c.Assert(s.observer.events, HasLen, 1)
+	event := s.observer.events[0]
+	c.Check(event.CommandCode, Equals, CommandClear)
+	c.Check(event.Handles, DeepEquals, HandleList{HandleLockout})
+	c.Check(event.Sessions, DeepEquals, []SessionAttributes{AttrContinueSession})
+}
+
+func (s *commandObserverSuite) TestRunCommandRetry(c *C) {
+	go func() {
+		s.tcti.release <- makeTestResponse((&TPMWarning{Code: WarningRetry}).ResponseCode())
+		s.tcti.release <- makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4})
+	}()
+
+	var random Digest
+	c.Check(s.tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random), IsNil)
+
+	c.Assert(s.observer.events, HasLen, 1)
+	c.Check(s.observer.events[0].Submissions, Equals, uint(2))
+	c.Check(s.observer.events[0].Err, IsNil)
+}
+
+func (s *commandObserverSuite) TestRunCommandError(c *C) {
+	s.tcti.release <- makeTestResponse(ResponseBadTag)
+
+	err := s.tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4))
+	c.Check(err, ErrorMatches, "TPM returned an error whilst executing command TPM_CC_GetRandom: TPM_RC_BAD_TAG")
+
+	c.Assert(s.observer.events, HasLen, 1)
+	event := s.observer.events[0]
+	c.Check(event.ResponseCode, Equals, ResponseBadTag)
+	c.Check(event.Err, Equals, err)
+	c.Check(event.ResponseParameters, IsNil)
+}
+
+func (s *commandObserverSuite) TestRunCommandInvalidResponse(c *C) {
+	s.tcti.release <- makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4}, uint8(0))
+
+	var random Digest
+	err := s.tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random)
+	c.Check(err, FitsTypeOf, &InvalidResponseError{})
+
+	c.Assert(s.observer.events, HasLen, 1)
+	c.Check(s.observer.events[0].ResponseCode, Equals, ResponseSuccess)
+	c.Check(s.observer.events[0].Err, Equals, err)
+}
+
+func (s *commandObserverSuite) TestRunCommandNotSubmitted(c *C) {
+	c.Check(s.tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, uint32(0)), ErrorMatches, "cannot process response handle argument .*")
+	c.Check(s.observer.events, HasLen, 0)
+}
+
+func (s *commandObserverSuite) TestRunCommandBytes(c *C) {
+	s.tcti.release <- makeTestResponse(ResponseBadTag)
+
+	_, err := s.tpm.RunCommandBytes(MarshalCommandPacket(CommandGetRandom, nil, nil, []byte{0, 4}))
+	c.Check(err, IsNil)
+
+	c.Assert(s.observer.events, HasLen, 1)
+	event := s.observer.events[0]
+	c.Check(event.CommandCode, Equals, CommandGetRandom)
+	c.Check(event.Handles, IsNil)
+	c.Check(event.Submissions, Equals, uint(1))
+	c.Check(event.ResponseCode, Equals, ResponseBadTag)
+	c.Check(event.Err, ErrorMatches, "TPM returned an error whilst executing command TPM_CC_GetRandom: TPM_RC_BAD_TAG")
+}
+
+func (s *commandObserverSuite) TestRemoveCommandObserver(c *C) {
+	s.tpm.RemoveCommandObserver(s.observer)
+	s.tcti.release <- makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4})
+
+	var random Digest
+	c.Check(s.tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random), IsNil)
+	c.Check(s.observer.events, HasLen, 0)
+}
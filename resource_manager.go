@@ -0,0 +1,590 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/canonical/go-tpm2/mu"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	rmVirtualHandleFirst Handle = 0x80ff0000
+	rmVirtualHandleLast  Handle = 0x80ffffff
+)
+
+// rmResource corresponds to a transient object or session tracked by the resource manager.
+type rmResource struct {
+	handle    Handle   // The handle exposed to the client
+	tpmHandle Handle   // The handle of the resource on the TPM if it is loaded
+	context   *Context // The saved context if the resource is not loaded
+	lastUsed  uint64
+	sticky    bool
+}
+
+func (r *rmResource) isSession() bool {
+	switch r.handle.Type() {
+	case HandleTypeHMACSession, HandleTypePolicySession:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *rmResource) isLoaded() bool {
+	return r.context == nil
+}
+
+// TctiResourceManager is a TCTI implementation that wraps another TCTI and provides a user-space
+// resource manager, similar to tpm2-abrmd. It tracks transient objects and sessions that are
+// created or loaded via commands sent through it. When the TPM runs out of memory for objects or
+// sessions, the least recently used resources are context saved in order to make space, and they
+// are transparently context loaded again when a subsequent command refers to them.
+//
+// Transient objects are assigned virtual handles, because their handles on the TPM may change
+// each time they are reloaded. Command and response packets are rewritten so that the client only
+// ever sees these virtual handles. Session handles don't change when they are context saved and
+// loaded, so these are not virtualized.
+//
+// Handles returned from TPM2_GetCapability are not virtualized, and TPM_RC_CONTEXT_GAP errors are
+// not handled.
+type TctiResourceManager struct {
+	tcti TCTI
+	tpm  *TPMContext
+
+	commands  map[CommandCode]CommandAttributes
+	resources map[Handle]*rmResource
+
+	nextHandle Handle
+	counter    uint64
+
+	isolated      bool
+	savedSessions map[Handle]bool // Sessions context saved by the client in isolated mode
+
+	rsp *bytes.Reader
+}
+
+// NewResourceManager returns a new TctiResourceManager that wraps the supplied TCTI. The returned
+// TctiResourceManager takes ownership of tcti, and can be passed to NewTPMContext.
+func NewResourceManager(tcti TCTI) *TctiResourceManager {
+	return &TctiResourceManager{
+		tcti:       tcti,
+		tpm:        newTpmContext(tcti),
+		resources:  make(map[Handle]*rmResource),
+		nextHandle: rmVirtualHandleFirst}
+}
+
+// NewIsolatedResourceManager returns a new TctiResourceManager in isolated mode, which permits
+// multiple resource managers to share a single TCTI. In this mode, all resources are context
+// saved after each command so that none remain loaded on the TPM between commands, and commands
+// that refer to transient objects or sessions that were not created or loaded via this resource
+// manager are rejected with a TPM_RC_HANDLE error. The caller is responsible for serializing
+// access to the shared TCTI, and the returned TctiResourceManager does not take ownership of it.
+//
+// Note that sessions belonging to other resource managers are still visible via
+// TPM2_GetCapability.
+func NewIsolatedResourceManager(tcti TCTI) *TctiResourceManager {
+	t := NewResourceManager(tcti)
+	t.isolated = true
+	t.savedSessions = make(map[Handle]bool)
+	return t
+}
+
+func (t *TctiResourceManager) runCommand(commandCode CommandCode, handles HandleList, parameters []byte, rspHandle *Handle) (ResponseCode, []byte, error) {
+	rsp, err := t.tpm.RunCommandBytes(MarshalCommandPacket(commandCode, handles, nil, parameters))
+	if err != nil {
+		return 0, nil, err
+	}
+	rc, rpBytes, _, err := rsp.Unmarshal(rspHandle)
+	if err != nil {
+		return 0, nil, &InvalidResponseError{commandCode, fmt.Sprintf("cannot unmarshal response packet: %v", err)}
+	}
+	return rc, rpBytes, nil
+}
+
+func (t *TctiResourceManager) contextSave(handle Handle) (ResponseCode, *Context, error) {
+	rc, rpBytes, err := t.runCommand(CommandContextSave, HandleList{handle}, nil, nil)
+	if err != nil || rc != ResponseSuccess {
+		return rc, nil, err
+	}
+	var context Context
+	if _, err := mu.UnmarshalFromBytes(rpBytes, &context); err != nil {
+		return 0, nil, &InvalidResponseError{CommandContextSave, fmt.Sprintf("cannot unmarshal response parameters: %v", err)}
+	}
+	return rc, &context, nil
+}
+
+func (t *TctiResourceManager) contextLoad(context *Context) (ResponseCode, Handle, error) {
+	var handle Handle
+	rc, _, err := t.runCommand(CommandContextLoad, nil, mu.MustMarshalToBytes(context), &handle)
+	return rc, handle, err
+}
+
+func (t *TctiResourceManager) flushContext(handle Handle) (ResponseCode, error) {
+	rc, _, err := t.runCommand(CommandFlushContext, nil, mu.MustMarshalToBytes(handle), nil)
+	return rc, err
+}
+
+func (t *TctiResourceManager) checkResponseCode(commandCode CommandCode, rc ResponseCode) error {
+	if err := DecodeResponseCode(commandCode, rc); err != nil {
+		return xerrors.Errorf("cannot execute %s: %w", commandCode, err)
+	}
+	return nil
+}
+
+// memoryWarning returns the warning code if the supplied response code indicates that the TPM
+// has run out of memory for objects or sessions.
+func memoryWarning(rc ResponseCode) (WarningCode, bool) {
+	var e *TPMWarning
+	if !AsTPMWarning(DecodeResponseCode(0, rc), AnyWarningCode, AnyCommandCode, &e) {
+		return 0, false
+	}
+	switch e.Code {
+	case WarningObjectMemory, WarningSessionMemory, WarningMemory:
+		return e.Code, true
+	default:
+		return 0, false
+	}
+}
+
+// evict context saves the least recently used loaded resource of the type indicated by the
+// supplied warning code, excluding resources that are sticky or in use by the current command.
+// It returns false if there is nothing that can be evicted.
+func (t *TctiResourceManager) evict(warning WarningCode, inUse map[*rmResource]bool) (bool, error) {
+	var candidate *rmResource
+	for _, r := range t.resources {
+		switch {
+		case !r.isLoaded():
+			continue
+		case r.sticky || inUse[r]:
+			continue
+		case warning == WarningObjectMemory && r.isSession():
+			continue
+		case warning == WarningSessionMemory && !r.isSession():
+			continue
+		}
+		if candidate == nil || r.lastUsed < candidate.lastUsed {
+			candidate = r
+		}
+	}
+	if candidate == nil {
+		return false, nil
+	}
+
+	if err := t.save(candidate); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// save context saves the supplied loaded resource and removes it from TPM memory.
+func (t *TctiResourceManager) save(r *rmResource) error {
+	rc, context, err := t.contextSave(r.tpmHandle)
+	if err != nil {
+		return err
+	}
+	if err := t.checkResponseCode(CommandContextSave, rc); err != nil {
+		return err
+	}
+
+	if !r.isSession() {
+		// Saving a session removes it from TPM memory, but objects have to be explicitly flushed.
+		rc, err := t.flushContext(r.tpmHandle)
+		if err != nil {
+			return err
+		}
+		if err := t.checkResponseCode(CommandFlushContext, rc); err != nil {
+			return err
+		}
+	}
+
+	r.context = context
+	r.tpmHandle = HandleUnassigned
+	return nil
+}
+
+// saveAll context saves all loaded resources. This is used in isolated mode so that no resources
+// belonging to this resource manager remain loaded between commands.
+func (t *TctiResourceManager) saveAll() error {
+	for _, r := range t.resources {
+		if !r.isLoaded() {
+			continue
+		}
+		if err := t.save(r); err != nil {
+			return xerrors.Errorf("cannot save context for handle 0x%08x: %w", r.handle, err)
+		}
+	}
+	return nil
+}
+
+// load ensures that the supplied resource is loaded on the TPM, evicting other resources if
+// required. If the resource cannot be loaded because of a TPM error, the response code is
+// returned.
+func (t *TctiResourceManager) load(r *rmResource, inUse map[*rmResource]bool) (ResponseCode, error) {
+	t.counter++
+	r.lastUsed = t.counter
+
+	if r.isLoaded() {
+		return ResponseSuccess, nil
+	}
+
+	for {
+		rc, handle, err := t.contextLoad(r.context)
+		if err != nil {
+			return 0, err
+		}
+		if warning, ok := memoryWarning(rc); ok {
+			evicted, err := t.evict(warning, inUse)
+			if err != nil {
+				return 0, err
+			}
+			if evicted {
+				continue
+			}
+		}
+		if rc != ResponseSuccess {
+			return rc, nil
+		}
+
+		r.tpmHandle = handle
+		r.context = nil
+		return ResponseSuccess, nil
+	}
+}
+
+func (t *TctiResourceManager) allocateVirtualHandle() (Handle, error) {
+	for i := rmVirtualHandleFirst; i <= rmVirtualHandleLast; i++ {
+		h := t.nextHandle
+		t.nextHandle++
+		if t.nextHandle > rmVirtualHandleLast {
+			t.nextHandle = rmVirtualHandleFirst
+		}
+		if _, inUse := t.resources[h]; !inUse {
+			return h, nil
+		}
+	}
+	return HandleUnassigned, errors.New("no virtual handles available")
+}
+
+// track begins tracking a newly created or loaded transient object or session, and returns the
+// handle that should be exposed to the client.
+func (t *TctiResourceManager) track(handle Handle) (Handle, error) {
+	r := &rmResource{handle: handle, tpmHandle: handle}
+	switch handle.Type() {
+	case HandleTypeTransient:
+		h, err := t.allocateVirtualHandle()
+		if err != nil {
+			return HandleUnassigned, err
+		}
+		r.handle = h
+	case HandleTypeHMACSession, HandleTypePolicySession:
+		delete(t.savedSessions, handle)
+	default:
+		return handle, nil
+	}
+
+	t.counter++
+	r.lastUsed = t.counter
+	t.resources[r.handle] = r
+	return r.handle, nil
+}
+
+// commandAttributes returns the attributes of the specified command, and whether the command is
+// supported. The attributes are obtained from the TPM the first time that this is called. If the
+// TPM hasn't been initialized by TPM2_Startup yet, this indicates that the command is not supported
+// so that it is passed to the TPM unmodified, and the attributes are obtained on a subsequent call.
+func (t *TctiResourceManager) commandAttributes(commandCode CommandCode) (CommandAttributes, bool, error) {
+	if t.commands == nil {
+		commands, err := t.tpm.GetCapabilityCommands(CommandFirst, CapabilityMaxProperties)
+		if IsTPMError(err, ErrorInitialize, CommandGetCapability) {
+			// No resources can have been created yet.
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, xerrors.Errorf("cannot obtain command attributes: %w", err)
+		}
+		t.commands = make(map[CommandCode]CommandAttributes)
+		for _, attrs := range commands {
+			t.commands[attrs.CommandCode()] = attrs
+		}
+	}
+
+	attrs, ok := t.commands[commandCode]
+	return attrs, ok, nil
+}
+
+// isIsolatedHandle indicates whether the supplied handle refers to a resource that has to be
+// owned by this resource manager in isolated mode.
+func (t *TctiResourceManager) isIsolatedHandle(handle Handle) bool {
+	if !t.isolated {
+		return false
+	}
+	switch handle.Type() {
+	case HandleTypeTransient, HandleTypeHMACSession, HandleTypePolicySession:
+		return true
+	default:
+		return false
+	}
+}
+
+func makeResponseWithCode(rc ResponseCode) ResponsePacket {
+	header := ResponseHeader{Tag: TagNoSessions, ResponseCode: rc}
+	header.ResponseSize = uint32(binary.Size(header))
+	return mu.MustMarshalToBytes(header)
+}
+
+func (t *TctiResourceManager) processFlushContext(cmd CommandPacket, parameters []byte) (ResponsePacket, error) {
+	var handle Handle
+	if _, err := mu.UnmarshalFromBytes(parameters, &handle); err != nil {
+		// Let the TPM deal with this.
+		return t.tpm.RunCommandBytes(cmd)
+	}
+
+	r, tracked := t.resources[handle]
+	switch {
+	case !tracked && t.savedSessions[handle]:
+		rsp, err := t.tpm.RunCommandBytes(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if rc, _, _, err := rsp.Unmarshal(nil); err == nil && rc == ResponseSuccess {
+			delete(t.savedSessions, handle)
+		}
+		return rsp, nil
+	case !tracked && t.isIsolatedHandle(handle):
+		err := &TPMParameterError{TPMError: &TPMError{Command: CommandFlushContext, Code: ErrorHandle}, Index: 1}
+		return makeResponseWithCode(err.ResponseCode()), nil
+	case !tracked:
+		return t.tpm.RunCommandBytes(cmd)
+	case !r.isLoaded() && !r.isSession():
+		// Saved objects don't consume any TPM resources.
+		delete(t.resources, handle)
+		return makeResponseWithCode(ResponseSuccess), nil
+	}
+
+	rsp, err := t.tpm.RunCommandBytes(MarshalCommandPacket(CommandFlushContext, nil, nil, mu.MustMarshalToBytes(r.tpmHandle)))
+	if err != nil {
+		return nil, err
+	}
+	if rc, _, _, err := rsp.Unmarshal(nil); err == nil && rc == ResponseSuccess {
+		delete(t.resources, handle)
+	}
+	return rsp, nil
+}
+
+func (t *TctiResourceManager) processCommand(cmd CommandPacket) (ResponsePacket, error) {
+	commandCode, err := cmd.GetCommandCode()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode command code: %w", err)
+	}
+
+	attrs, supported, err := t.commandAttributes(commandCode)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		// Let the TPM deal with this.
+		return t.tpm.RunCommandBytes(cmd)
+	}
+
+	handles, authArea, parameters, err := cmd.Unmarshal(attrs.NumberOfCommandHandles())
+	if err != nil {
+		return nil, xerrors.Errorf("cannot unmarshal command packet: %w", err)
+	}
+
+	if commandCode == CommandFlushContext {
+		return t.processFlushContext(cmd, parameters)
+	}
+
+	inUse := make(map[*rmResource]bool)
+	var handleResources []*rmResource
+	var flushSessions []*rmResource
+
+	for i, h := range handles {
+		r, tracked := t.resources[h]
+		if !tracked && t.isIsolatedHandle(h) {
+			err := &TPMHandleError{TPMError: &TPMError{Command: commandCode, Code: ErrorHandle}, Index: i + 1}
+			return makeResponseWithCode(err.ResponseCode()), nil
+		}
+		if !tracked {
+			continue
+		}
+		inUse[r] = true
+		handleResources = append(handleResources, r)
+		handles[i] = HandleUnassigned
+	}
+	for i, auth := range authArea {
+		r, tracked := t.resources[auth.SessionHandle]
+		if !tracked && t.isIsolatedHandle(auth.SessionHandle) {
+			err := &TPMSessionError{TPMError: &TPMError{Command: commandCode, Code: ErrorHandle}, Index: i + 1}
+			return makeResponseWithCode(err.ResponseCode()), nil
+		}
+		if !tracked {
+			continue
+		}
+		inUse[r] = true
+		if auth.SessionAttributes&AttrContinueSession == 0 {
+			flushSessions = append(flushSessions, r)
+		}
+	}
+
+	for r := range inUse {
+		rc, err := t.load(r, inUse)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot load context for handle 0x%08x: %w", r.handle, err)
+		}
+		if rc != ResponseSuccess {
+			return makeResponseWithCode(rc), nil
+		}
+	}
+
+	for i, j := 0, 0; i < len(handles); i++ {
+		if handles[i] != HandleUnassigned {
+			continue
+		}
+		handles[i] = handleResources[j].tpmHandle
+		j++
+	}
+
+	cmd = MarshalCommandPacket(commandCode, handles, authArea, parameters)
+
+	for {
+		rsp, err := t.tpm.RunCommandBytes(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		var rspHandle Handle
+		var rspHandlePtr *Handle
+		if attrs&AttrRHandle != 0 {
+			rspHandlePtr = &rspHandle
+		}
+		rc, _, _, err := rsp.Unmarshal(rspHandlePtr)
+		if err != nil {
+			// Let the client deal with this.
+			return rsp, nil
+		}
+
+		if warning, ok := memoryWarning(rc); ok {
+			evicted, err := t.evict(warning, inUse)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot evict context: %w", err)
+			}
+			if evicted {
+				continue
+			}
+		}
+		if rc != ResponseSuccess {
+			return rsp, nil
+		}
+
+		for _, r := range flushSessions {
+			delete(t.resources, r.handle)
+		}
+		for _, r := range handleResources {
+			switch {
+			case attrs&AttrFlushed != 0:
+				delete(t.resources, r.handle)
+			case commandCode == CommandContextSave && r.isSession():
+				// The client now owns the saved session.
+				delete(t.resources, r.handle)
+				if t.isolated {
+					t.savedSessions[r.handle] = true
+				}
+			}
+		}
+
+		if rspHandlePtr != nil {
+			h, err := t.track(rspHandle)
+			if err != nil {
+				return nil, err
+			}
+			binary.BigEndian.PutUint32(rsp[binary.Size(ResponseHeader{}):], uint32(h))
+		}
+
+		return rsp, nil
+	}
+}
+
+func (t *TctiResourceManager) Read(data []byte) (int, error) {
+	if t.rsp == nil {
+		return 0, io.EOF
+	}
+
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *TctiResourceManager) Write(data []byte) (int, error) {
+	if t.rsp != nil {
+		return 0, errors.New("previous response has not been read")
+	}
+
+	rsp, err := t.processCommand(CommandPacket(data))
+	if err != nil {
+		return 0, err
+	}
+	if t.isolated {
+		if err := t.saveAll(); err != nil {
+			return 0, err
+		}
+	}
+
+	t.rsp = bytes.NewReader(rsp)
+	return len(data), nil
+}
+
+// Close flushes all of the resources tracked by this resource manager and then closes the
+// underlying TCTI. In isolated mode, sessions that were context saved by the client are also
+// flushed, and the underlying TCTI is not closed.
+func (t *TctiResourceManager) Close() error {
+	for _, r := range t.resources {
+		switch {
+		case r.isLoaded():
+			t.flushContext(r.tpmHandle)
+		case r.isSession():
+			t.flushContext(r.handle)
+		}
+	}
+	t.resources = nil
+
+	if t.isolated {
+		for h := range t.savedSessions {
+			t.flushContext(h)
+		}
+		t.savedSessions = nil
+		return nil
+	}
+	return t.tcti.Close()
+}
+
+func (t *TctiResourceManager) SetLocality(locality uint8) error {
+	return t.tcti.SetLocality(locality)
+}
+
+// MakeSticky marks the resource associated with the supplied handle as sticky, which prevents it
+// from being context saved by the resource manager in order to make space for other resources.
+func (t *TctiResourceManager) MakeSticky(handle Handle, sticky bool) error {
+	r, tracked := t.resources[handle]
+	if !tracked {
+		return fmt.Errorf("no resource with handle 0x%08x", handle)
+	}
+	r.sticky = sticky
+	return nil
+}
+
+// Unwrap returns the TCTI wrapped by this resource manager.
+func (t *TctiResourceManager) Unwrap() TCTI {
+	return t.tcti
+}
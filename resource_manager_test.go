@@ -0,0 +1,187 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+type resourceManagerSuite struct {
+	testutil.TPMTest
+
+	rm  *TctiResourceManager
+	tpm *TPMContext
+}
+
+func (s *resourceManagerSuite) SetUpSuite(c *C) {
+	s.TPMFeatures = testutil.TPMFeatureOwnerHierarchy
+}
+
+func (s *resourceManagerSuite) SetUpTest(c *C) {
+	s.TPMTest.SetUpTest(c)
+
+	// The resource manager isn't closed at the end of each test because this closes the
+	// underlying TCTI, but resources are tracked and flushed by testutil.TCTI anyway.
+	s.rm = NewResourceManager(s.TCTI)
+	tpm, err := NewTPMContext(s.rm)
+	c.Assert(err, IsNil)
+	s.tpm = tpm
+}
+
+var _ = Suite(&resourceManagerSuite{})
+
+func (s *resourceManagerSuite) loadSealedObject(c *C, data []byte) ResourceContext {
+	pub, sensitive := testutil.NewSealedObject(nil, data)
+	object, err := s.tpm.LoadExternal(sensitive, pub, HandleNull)
+	c.Assert(err, IsNil)
+	return object
+}
+
+func (s *resourceManagerSuite) TestVirtualHandles(c *C) {
+	object := s.loadSealedObject(c, []byte("foo"))
+	c.Check(object.Handle()&0xffff0000, Equals, Handle(0x80ff0000))
+
+	_, name, _, err := s.tpm.ReadPublic(object)
+	c.Check(err, IsNil)
+	c.Check(name, DeepEquals, object.Name())
+}
+
+func (s *resourceManagerSuite) TestEvictObjects(c *C) {
+	var objects []ResourceContext
+	for i := 0; i < 8; i++ {
+		objects = append(objects, s.loadSealedObject(c, []byte(fmt.Sprintf("data%d", i))))
+	}
+
+	for i, object := range objects {
+		data, err := s.tpm.Unseal(object, nil)
+		c.Check(err, IsNil)
+		c.Check(data, DeepEquals, SensitiveData(fmt.Sprintf("data%d", i)))
+	}
+}
+
+func (s *resourceManagerSuite) TestEvictSessions(c *C) {
+	var sessions []SessionContext
+	for i := 0; i < 6; i++ {
+		session, err := s.tpm.StartAuthSession(nil, nil, SessionTypeHMAC, nil, HashAlgorithmSHA256)
+		c.Assert(err, IsNil)
+		sessions = append(sessions, session)
+	}
+
+	object := s.loadSealedObject(c, []byte("foo"))
+
+	for _, session := range sessions {
+		data, err := s.tpm.Unseal(object, session.WithAttrs(AttrContinueSession))
+		c.Check(err, IsNil)
+		c.Check(data, DeepEquals, SensitiveData("foo"))
+	}
+
+	for _, session := range sessions {
+		c.Check(s.tpm.FlushContext(session), IsNil)
+	}
+}
+
+func (s *resourceManagerSuite) TestFlushEvictedObject(c *C) {
+	var objects []ResourceContext
+	for i := 0; i < 8; i++ {
+		objects = append(objects, s.loadSealedObject(c, []byte("foo")))
+	}
+
+	for _, object := range objects {
+		c.Check(s.tpm.FlushContext(object), IsNil)
+	}
+
+	handles, err := s.TPM.GetCapabilityHandles(HandleTypeTransient.BaseHandle(), CapabilityMaxProperties)
+	c.Check(err, IsNil)
+	c.Check(handles, HasLen, 0)
+}
+
+func (s *resourceManagerSuite) TestMakeSticky(c *C) {
+	object := s.loadSealedObject(c, []byte("foo"))
+	c.Check(s.rm.MakeSticky(object.Handle(), true), IsNil)
+
+	for i := 0; i < 8; i++ {
+		s.loadSealedObject(c, []byte("bar"))
+	}
+
+	data, err := s.tpm.Unseal(object, nil)
+	c.Check(err, IsNil)
+	c.Check(data, DeepEquals, SensitiveData("foo"))
+}
+
+func (s *resourceManagerSuite) TestMakeStickyUnknownHandle(c *C) {
+	c.Check(s.rm.MakeSticky(0x80ff1234, true), ErrorMatches, "no resource with handle 0x80ff1234")
+}
+
+type resourceManagerMockSuite struct {
+	tcti     *mockTCTI
+	started  bool
+	commands []CommandCode
+}
+
+var _ = Suite(&resourceManagerMockSuite{})
+
+func (s *resourceManagerMockSuite) SetUpTest(c *C) {
+	s.started = false
+	s.commands = nil
+	s.tcti = &mockTCTI{handler: s.handleCommand}
+}
+
+func (s *resourceManagerMockSuite) handleCommand(cmd CommandPacket) (ResponsePacket, error) {
+	commandCode, err := cmd.GetCommandCode()
+	if err != nil {
+		return nil, err
+	}
+	s.commands = append(s.commands, commandCode)
+
+	switch {
+	case commandCode == CommandStartup:
+		if s.started {
+			return makeTestResponse((&TPMError{Code: ErrorInitialize}).ResponseCode()), nil
+		}
+		s.started = true
+		return makeTestResponse(ResponseSuccess), nil
+	case !s.started:
+		return makeTestResponse((&TPMError{Code: ErrorInitialize}).ResponseCode()), nil
+	case commandCode == CommandGetCapability:
+		data := &CapabilityData{
+			Capability: CapabilityCommands,
+			Data: &CapabilitiesU{Command: CommandAttributesList{
+				CommandAttributes(CommandStartup & 0xffff),
+				CommandAttributes(CommandGetRandom & 0xffff)}}}
+		return makeTestResponse(ResponseSuccess, false, data), nil
+	case commandCode == CommandGetRandom:
+		return makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4}), nil
+	default:
+		return makeTestResponse((&TPMError{Code: ErrorCommandCode}).ResponseCode()), nil
+	}
+}
+
+func (s *resourceManagerMockSuite) TestStartup(c *C) {
+	tpm, _ := NewTPMContext(NewResourceManager(s.tcti))
+
+	c.Check(tpm.Startup(StartupClear), IsNil)
+	c.Check(s.started, Equals, true)
+
+	var random Digest
+	c.Check(tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random), IsNil)
+	c.Check(random, DeepEquals, Digest{1, 2, 3, 4})
+
+	c.Check(s.commands, DeepEquals, []CommandCode{CommandGetCapability, CommandStartup, CommandGetCapability, CommandGetRandom})
+}
+
+func (s *resourceManagerMockSuite) TestCommandBeforeStartup(c *C) {
+	tpm, _ := NewTPMContext(NewResourceManager(s.tcti))
+
+	err := tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4))
+	c.Check(IsTPMError(err, ErrorInitialize, CommandGetRandom), Equals, true)
+
+	c.Check(tpm.Startup(StartupClear), IsNil)
+	c.Check(s.commands, DeepEquals, []CommandCode{CommandGetCapability, CommandGetRandom, CommandGetCapability, CommandStartup})
+}
@@ -491,6 +491,9 @@ func CreatePartialHandleContext(handle Handle) HandleContext {
 func (t *TPMContext) GetPermanentContext(handle Handle) ResourceContext {
 	switch handle.Type() {
 	case HandleTypePermanent, HandleTypePCR:
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
 		if rc, exists := t.permanentResources[handle]; exists {
 			return rc
 		}
@@ -543,6 +546,13 @@ func (t *TPMContext) PCRHandleContext(pcr int) ResourceContext {
 	return t.GetPermanentContext(h)
 }
 
+// handleContextUnmarshalOptions are the limits applied when unmarshalling serialized HandleContexts,
+// which are often read from files and shouldn't be trusted to be well formed.
+var handleContextUnmarshalOptions = mu.UnmarshalOptions{
+	MaxBytes:       8192,
+	MaxListLength:  64,
+	MaxSizedLength: 4096}
+
 // CreateHandleContextFromReader returns a new HandleContext created from the serialized data read from the supplied io.Reader. This
 // should contain data that was previously created by HandleContext.SerializeToBytes or HandleContext.SerializeToWriter.
 //
@@ -554,7 +564,7 @@ func CreateHandleContextFromReader(r io.Reader) (HandleContext, error) {
 	var integrityAlg HashAlgorithmId
 	var integrity []byte
 	var b []byte
-	if _, err := mu.UnmarshalFromReader(r, &integrityAlg, &integrity, &b); err != nil {
+	if _, err := mu.UnmarshalFromReaderWithOptions(r, &handleContextUnmarshalOptions, &integrityAlg, &integrity, &b); err != nil {
 		return nil, xerrors.Errorf("cannot unpack context blob and checksum: %w", err)
 	}
 
@@ -567,13 +577,15 @@ func CreateHandleContextFromReader(r io.Reader) (HandleContext, error) {
 		return nil, errors.New("invalid checksum")
 	}
 
+	opts := handleContextUnmarshalOptions
+	opts.Strict = true
+
 	var data *handleContext
-	n, err := mu.UnmarshalFromBytes(b, &data)
-	if err != nil {
-		return nil, xerrors.Errorf("cannot unmarshal context data: %w", err)
-	}
-	if n < len(b) {
+	switch _, err := mu.UnmarshalFromBytesWithOptions(b, &opts, &data); {
+	case err == mu.ErrTrailingBytes:
 		return nil, errors.New("context blob contains trailing bytes")
+	case err != nil:
+		return nil, xerrors.Errorf("cannot unmarshal context data: %w", err)
 	}
 
 	if data.Type == handleContextTypePermanent {
@@ -607,7 +619,7 @@ func CreateHandleContextFromReader(r io.Reader) (HandleContext, error) {
 // If a ResourceContext is returned and subsequent use of it requires knowledge of the authorization value of the corresponding TPM
 // resource, this should be provided by calling ResourceContext.SetAuthValue.
 func CreateHandleContextFromBytes(b []byte) (HandleContext, int, error) {
-	buf := bytes.NewReader(b)
+	buf := bytes.NewBuffer(b)
 	rc, err := CreateHandleContextFromReader(buf)
 	if err != nil {
 		return nil, 0, err
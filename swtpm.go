@@ -0,0 +1,279 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/canonical/go-tpm2/mu"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	swtpmCmdGetCapability       uint32 = 1
+	swtpmCmdInit                uint32 = 2
+	swtpmCmdShutdown            uint32 = 3
+	swtpmCmdGetTPMEstablished   uint32 = 4
+	swtpmCmdSetLocality         uint32 = 5
+	swtpmCmdCancelTPMCmd        uint32 = 9
+	swtpmCmdResetTPMEstablished uint32 = 11
+	swtpmCmdGetStateBlob        uint32 = 12
+	swtpmCmdSetStateBlob        uint32 = 13
+	swtpmCmdStop                uint32 = 14
+
+	maxSwtpmStateBlobSize uint32 = 1024 * 1024
+)
+
+// SwtpmCapabilities corresponds to the capabilities of the control channel of swtpm.
+type SwtpmCapabilities uint64
+
+const (
+	SwtpmCapInit                SwtpmCapabilities = 0x0001
+	SwtpmCapShutdown            SwtpmCapabilities = 0x0002
+	SwtpmCapGetTPMEstablished   SwtpmCapabilities = 0x0004
+	SwtpmCapSetLocality         SwtpmCapabilities = 0x0008
+	SwtpmCapHashing             SwtpmCapabilities = 0x0010
+	SwtpmCapCancelTPMCmd        SwtpmCapabilities = 0x0020
+	SwtpmCapStoreVolatile       SwtpmCapabilities = 0x0040
+	SwtpmCapResetTPMEstablished SwtpmCapabilities = 0x0080
+	SwtpmCapGetStateBlob        SwtpmCapabilities = 0x0100
+	SwtpmCapSetStateBlob        SwtpmCapabilities = 0x0200
+	SwtpmCapStop                SwtpmCapabilities = 0x0400
+)
+
+// SwtpmInitFlags corresponds to the flags supplied to TctiSwtpm.Init.
+type SwtpmInitFlags uint32
+
+const (
+	// SwtpmInitDeleteVolatile indicates that the volatile state should be deleted on initialization.
+	SwtpmInitDeleteVolatile SwtpmInitFlags = 0x1
+)
+
+// SwtpmBlobType corresponds to the type of a state blob obtained from or supplied to swtpm.
+type SwtpmBlobType uint32
+
+const (
+	SwtpmBlobTypePermanent SwtpmBlobType = 1 // The permanent state
+	SwtpmBlobTypeVolatile  SwtpmBlobType = 2 // The volatile state
+	SwtpmBlobTypeSaveState SwtpmBlobType = 3 // The state saved by TPM2_Shutdown(TPM_SU_STATE)
+)
+
+// SwtpmControlError corresponds to an error code in response to a control channel command executed on swtpm.
+type SwtpmControlError struct {
+	commandCode uint32
+	Code        uint32
+}
+
+func (e *SwtpmControlError) Error() string {
+	return fmt.Sprintf("received error code 0x%08x in response to control command %d", e.Code, e.commandCode)
+}
+
+// TctiSwtpm represents a connection to swtpm running in socket mode, with the server and control channels
+// using either TCP ("--server type=tcp" and "--ctrl type=tcp") or Unix domain sockets ("--server type=unixio"
+// and "--ctrl type=unixio").
+type TctiSwtpm struct {
+	tpm  net.Conn
+	ctrl net.Conn
+
+	ctrlMu sync.Mutex // Protects the control channel, as Cancel may be called from another goroutine
+
+	r io.Reader
+}
+
+func (t *TctiSwtpm) Read(data []byte) (int, error) {
+	if t.r == nil {
+		var hdr ResponseHeader
+		hdrBytes := make([]byte, binary.Size(hdr))
+		if _, err := io.ReadFull(t.tpm, hdrBytes); err != nil {
+			return 0, xerrors.Errorf("cannot read response header from TPM server channel: %w", err)
+		}
+		if _, err := mu.UnmarshalFromBytes(hdrBytes, &hdr); err != nil {
+			return 0, xerrors.Errorf("cannot decode response header: %w", err)
+		}
+		if hdr.ResponseSize < uint32(len(hdrBytes)) || hdr.ResponseSize > uint32(maxResponseSize) {
+			return 0, fmt.Errorf("invalid response size (%d bytes)", hdr.ResponseSize)
+		}
+
+		t.r = io.MultiReader(bytes.NewReader(hdrBytes), io.LimitReader(t.tpm, int64(hdr.ResponseSize)-int64(len(hdrBytes))))
+	}
+
+	n, err := t.r.Read(data)
+	if err == io.EOF {
+		t.r = nil
+	}
+	return n, err
+}
+
+func (t *TctiSwtpm) Write(data []byte) (int, error) {
+	return t.tpm.Write(data)
+}
+
+// Close closes the server and control channels. This doesn't terminate swtpm - see TctiSwtpm.Shutdown.
+func (t *TctiSwtpm) Close() (err error) {
+	if e := t.ctrl.Close(); e != nil {
+		err = xerrors.Errorf("cannot close control channel: %w", e)
+	}
+	if e := t.tpm.Close(); e != nil {
+		err = xerrors.Errorf("cannot close TPM server channel: %w", e)
+	}
+	return err
+}
+
+// SetLocality sets the locality that will be used for subsequent commands, using the control channel.
+func (t *TctiSwtpm) SetLocality(locality uint8) error {
+	return t.ctrlCommand(swtpmCmdSetLocality, []interface{}{locality})
+}
+
+func (t *TctiSwtpm) MakeSticky(handle Handle, sticky bool) error {
+	return errors.New("not implemented")
+}
+
+// ctrlCommand submits a command on the control channel. The request is sent in a single write because
+// swtpm reads each request with a single read. If the command returns a result code, it is checked
+// before the remaining response values are decoded into the supplied pointers.
+func (t *TctiSwtpm) ctrlCommand(cmd uint32, args []interface{}, resp ...interface{}) error {
+	t.ctrlMu.Lock()
+	defer t.ctrlMu.Unlock()
+	return t.ctrlCommandLocked(cmd, args, resp...)
+}
+
+func (t *TctiSwtpm) ctrlCommandLocked(cmd uint32, args []interface{}, resp ...interface{}) error {
+	if _, err := t.ctrl.Write(mu.MustMarshalToBytes(append([]interface{}{cmd}, args...)...)); err != nil {
+		return xerrors.Errorf("cannot send command: %w", err)
+	}
+
+	if cmd != swtpmCmdGetCapability {
+		var result uint32
+		if err := binary.Read(t.ctrl, binary.BigEndian, &result); err != nil {
+			return xerrors.Errorf("cannot read response to command: %w", err)
+		}
+		if result != 0 {
+			return &SwtpmControlError{cmd, result}
+		}
+	}
+
+	for _, r := range resp {
+		if err := binary.Read(t.ctrl, binary.BigEndian, r); err != nil {
+			return xerrors.Errorf("cannot read response to command: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetCapability returns the capabilities of the control channel.
+func (t *TctiSwtpm) GetCapability() (SwtpmCapabilities, error) {
+	var caps SwtpmCapabilities
+	if err := t.ctrlCommand(swtpmCmdGetCapability, nil, &caps); err != nil {
+		return 0, err
+	}
+	return caps, nil
+}
+
+// Init initializes the TPM, which results in the execution of _TPM_Init(). This must be called before
+// the TPM can be used, unless swtpm was started with "--flags not-need-init". It can also be used to
+// reset a running TPM, or to resume a TPM that was stopped with TctiSwtpm.Stop.
+func (t *TctiSwtpm) Init(flags SwtpmInitFlags) error {
+	return t.ctrlCommand(swtpmCmdInit, []interface{}{uint32(flags)})
+}
+
+// Shutdown requests that swtpm shuts down the TPM and exits.
+func (t *TctiSwtpm) Shutdown() error {
+	return t.ctrlCommand(swtpmCmdShutdown, nil)
+}
+
+// Stop stops the TPM without terminating swtpm. The TPM can be restarted with TctiSwtpm.Init. The TPM
+// must be stopped before its state can be set with TctiSwtpm.SetStateBlob.
+func (t *TctiSwtpm) Stop() error {
+	return t.ctrlCommand(swtpmCmdStop, nil)
+}
+
+// GetTPMEstablished returns the value of the TPM established bit.
+func (t *TctiSwtpm) GetTPMEstablished() (bool, error) {
+	var resp [4]byte
+	if err := t.ctrlCommand(swtpmCmdGetTPMEstablished, nil, &resp); err != nil {
+		return false, err
+	}
+	return resp[0] != 0, nil
+}
+
+// ResetTPMEstablished resets the TPM established bit from the specified locality.
+func (t *TctiSwtpm) ResetTPMEstablished(locality uint8) error {
+	return t.ctrlCommand(swtpmCmdResetTPMEstablished, []interface{}{locality})
+}
+
+// Cancel requests that swtpm cancels the currently executing command.
+func (t *TctiSwtpm) Cancel() error {
+	return t.ctrlCommand(swtpmCmdCancelTPMCmd, nil)
+}
+
+// GetStateBlob obtains the state blob of the specified type from swtpm.
+func (t *TctiSwtpm) GetStateBlob(blobType SwtpmBlobType) ([]byte, error) {
+	t.ctrlMu.Lock()
+	defer t.ctrlMu.Unlock()
+
+	var flags, totalLength, length uint32
+	if err := t.ctrlCommandLocked(swtpmCmdGetStateBlob, []interface{}{uint32(0), uint32(blobType), uint32(0)}, &flags, &totalLength, &length); err != nil {
+		return nil, err
+	}
+	if totalLength > maxSwtpmStateBlobSize {
+		return nil, fmt.Errorf("state blob too large (%d bytes)", totalLength)
+	}
+
+	blob := make([]byte, totalLength)
+	if _, err := io.ReadFull(t.ctrl, blob); err != nil {
+		return nil, xerrors.Errorf("cannot read state blob: %w", err)
+	}
+	return blob, nil
+}
+
+// SetStateBlob sets the state blob of the specified type. The TPM must be stopped with TctiSwtpm.Stop
+// first.
+func (t *TctiSwtpm) SetStateBlob(blobType SwtpmBlobType, blob []byte) error {
+	if uint32(len(blob)) > maxSwtpmStateBlobSize {
+		return makeInvalidArgError("blob", "too large")
+	}
+	return t.ctrlCommand(swtpmCmdSetStateBlob, []interface{}{uint32(0), uint32(blobType), uint32(len(blob)), mu.RawBytes(blob)})
+}
+
+// OpenSwtpm attempts to open a connection to swtpm running in socket mode. The network argument must be
+// "tcp" or "unix", and the address and ctrlAddress arguments correspond to the addresses of the server
+// and control channels respectively.
+//
+// Note that this doesn't initialize the TPM. If swtpm was not started with "--flags not-need-init", the
+// caller must call TctiSwtpm.Init before the TPM can be used.
+//
+// If successful, it returns a new TctiSwtpm instance which can be passed to NewTPMContext.
+func OpenSwtpm(network, address, ctrlAddress string) (*TctiSwtpm, error) {
+	switch network {
+	case "tcp", "unix":
+	default:
+		return nil, makeInvalidArgError("network", fmt.Sprintf("unsupported network type %q", network))
+	}
+
+	tcti := new(TctiSwtpm)
+
+	tpm, err := net.Dial(network, address)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot connect to TPM server socket: %w", err)
+	}
+	tcti.tpm = tpm
+
+	ctrl, err := net.Dial(network, ctrlAddress)
+	if err != nil {
+		tcti.tpm.Close()
+		return nil, xerrors.Errorf("cannot connect to control socket: %w", err)
+	}
+	tcti.ctrl = ctrl
+
+	return tcti, nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+
+	. "gopkg.in/check.v1"
+
+	"golang.org/x/xerrors"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// mockSwtpm implements enough of the swtpm server and control channel protocols to test TctiSwtpm.
+type mockSwtpm struct {
+	mu        sync.Mutex
+	commands  []CommandPacket
+	ctrlCmds  []uint32
+	locality  uint8
+	initFlags uint32
+	state     map[uint32][]byte
+}
+
+func (m *mockSwtpm) serveTPM(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var hdr CommandHeader
+		if err := binary.Read(conn, binary.BigEndian, &hdr); err != nil {
+			return
+		}
+		payload := make([]byte, int(hdr.CommandSize)-binary.Size(hdr))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+		m.mu.Lock()
+		m.commands = append(m.commands, mu.MustMarshalToBytes(hdr, mu.RawBytes(payload)))
+		m.mu.Unlock()
+
+		rsp := makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4})
+		// Send the response in 2 parts.
+		conn.Write(rsp[:4])
+		conn.Write(rsp[4:])
+	}
+}
+
+func (m *mockSwtpm) serveCtrl(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var cmd uint32
+		if err := binary.Read(conn, binary.BigEndian, &cmd); err != nil {
+			return
+		}
+		m.mu.Lock()
+		m.ctrlCmds = append(m.ctrlCmds, cmd)
+		m.mu.Unlock()
+
+		switch cmd {
+		case 1: // CMD_GET_CAPABILITY
+			binary.Write(conn, binary.BigEndian, uint64(0x07ff))
+		case 2: // CMD_INIT
+			var flags uint32
+			binary.Read(conn, binary.BigEndian, &flags)
+			m.mu.Lock()
+			m.initFlags = flags
+			m.mu.Unlock()
+			binary.Write(conn, binary.BigEndian, uint32(0))
+		case 4: // CMD_GET_TPMESTABLISHED
+			conn.Write([]byte{0, 0, 0, 0, 1, 0, 0, 0})
+		case 5: // CMD_SET_LOCALITY
+			var locality uint8
+			binary.Read(conn, binary.BigEndian, &locality)
+			if locality > 4 {
+				binary.Write(conn, binary.BigEndian, uint32(0x0a))
+				break
+			}
+			m.mu.Lock()
+			m.locality = locality
+			m.mu.Unlock()
+			binary.Write(conn, binary.BigEndian, uint32(0))
+		case 11: // CMD_RESET_TPMESTABLISHED
+			var locality uint8
+			binary.Read(conn, binary.BigEndian, &locality)
+			binary.Write(conn, binary.BigEndian, uint32(0))
+		case 12: // CMD_GET_STATEBLOB
+			var req [3]uint32
+			binary.Read(conn, binary.BigEndian, &req)
+			m.mu.Lock()
+			blob := m.state[req[1]]
+			m.mu.Unlock()
+			binary.Write(conn, binary.BigEndian, []uint32{0, 0, uint32(len(blob)), uint32(len(blob))})
+			conn.Write(blob)
+		case 13: // CMD_SET_STATEBLOB
+			var req [3]uint32
+			binary.Read(conn, binary.BigEndian, &req)
+			blob := make([]byte, req[2])
+			io.ReadFull(conn, blob)
+			m.mu.Lock()
+			m.state[req[1]] = blob
+			m.mu.Unlock()
+			binary.Write(conn, binary.BigEndian, uint32(0))
+		default:
+			binary.Write(conn, binary.BigEndian, uint32(0))
+		}
+	}
+}
+
+func (m *mockSwtpm) lastCtrlCmd() uint32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ctrlCmds[len(m.ctrlCmds)-1]
+}
+
+type swtpmSuite struct {
+	swtpm *mockSwtpm
+	tcti  *TctiSwtpm
+}
+
+var _ = Suite(&swtpmSuite{})
+
+func (s *swtpmSuite) SetUpTest(c *C) {
+	s.swtpm = &mockSwtpm{state: make(map[uint32][]byte)}
+
+	dir := c.MkDir()
+	serverPath := filepath.Join(dir, "server")
+	ctrlPath := filepath.Join(dir, "ctrl")
+
+	for path, serve := range map[string]func(net.Conn){serverPath: s.swtpm.serveTPM, ctrlPath: s.swtpm.serveCtrl} {
+		l, err := net.Listen("unix", path)
+		c.Assert(err, IsNil)
+		go func(l net.Listener, serve func(net.Conn)) {
+			defer l.Close()
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			serve(conn)
+		}(l, serve)
+	}
+
+	tcti, err := OpenSwtpm("unix", serverPath, ctrlPath)
+	c.Assert(err, IsNil)
+	s.tcti = tcti
+}
+
+func (s *swtpmSuite) TearDownTest(c *C) {
+	c.Check(s.tcti.Close(), IsNil)
+}
+
+func (s *swtpmSuite) TestRunCommand(c *C) {
+	tpm, _ := NewTPMContext(s.tcti)
+
+	for i := 0; i < 2; i++ {
+		var random Digest
+		c.Check(tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random), IsNil)
+		c.Check(random, DeepEquals, Digest{1, 2, 3, 4})
+	}
+
+	s.swtpm.mu.Lock()
+	defer s.swtpm.mu.Unlock()
+	c.Assert(s.swtpm.commands, HasLen, 2)
+	c.Check(s.swtpm.commands[0], DeepEquals, MarshalCommandPacket(CommandGetRandom, nil, nil, mu.MustMarshalToBytes(uint16(4))))
+}
+
+func (s *swtpmSuite) TestGetCapability(c *C) {
+	caps, err := s.tcti.GetCapability()
+	c.Check(err, IsNil)
+	c.Check(caps&SwtpmCapSetLocality, Equals, SwtpmCapSetLocality)
+	c.Check(caps&SwtpmCapStop, Equals, SwtpmCapStop)
+}
+
+func (s *swtpmSuite) TestInit(c *C) {
+	c.Check(s.tcti.Init(SwtpmInitDeleteVolatile), IsNil)
+	s.swtpm.mu.Lock()
+	defer s.swtpm.mu.Unlock()
+	c.Check(s.swtpm.initFlags, Equals, uint32(1))
+}
+
+func (s *swtpmSuite) TestSetLocality(c *C) {
+	c.Check(s.tcti.SetLocality(3), IsNil)
+	s.swtpm.mu.Lock()
+	defer s.swtpm.mu.Unlock()
+	c.Check(s.swtpm.locality, Equals, uint8(3))
+}
+
+func (s *swtpmSuite) TestSetLocalityError(c *C) {
+	err := s.tcti.SetLocality(5)
+	c.Check(err, ErrorMatches, "received error code 0x0000000a in response to control command 5")
+	c.Check(err, FitsTypeOf, &SwtpmControlError{})
+	c.Check(err.(*SwtpmControlError).Code, Equals, uint32(0x0a))
+
+	var e *SwtpmControlError
+	c.Check(xerrors.As(xerrors.Errorf("wrapped: %w", err), &e), Equals, true)
+	c.Check(e.Code, Equals, uint32(0x0a))
+
+	// The control channel should still be usable.
+	c.Check(s.tcti.SetLocality(0), IsNil)
+}
+
+func (s *swtpmSuite) TestTPMEstablished(c *C) {
+	established, err := s.tcti.GetTPMEstablished()
+	c.Check(err, IsNil)
+	c.Check(established, Equals, true)
+
+	c.Check(s.tcti.ResetTPMEstablished(3), IsNil)
+	c.Check(s.swtpm.lastCtrlCmd(), Equals, uint32(11))
+}
+
+func (s *swtpmSuite) TestCancel(c *C) {
+	c.Check(s.tcti.Cancel(), IsNil)
+	c.Check(s.swtpm.lastCtrlCmd(), Equals, uint32(9))
+}
+
+func (s *swtpmSuite) TestShutdown(c *C) {
+	c.Check(s.tcti.Shutdown(), IsNil)
+	c.Check(s.swtpm.lastCtrlCmd(), Equals, uint32(3))
+}
+
+func (s *swtpmSuite) TestStateBlobs(c *C) {
+	c.Check(s.tcti.Stop(), IsNil)
+	c.Check(s.swtpm.lastCtrlCmd(), Equals, uint32(14))
+
+	c.Check(s.tcti.SetStateBlob(SwtpmBlobTypePermanent, []byte("permanent state")), IsNil)
+	c.Check(s.tcti.SetStateBlob(SwtpmBlobTypeVolatile, []byte("volatile state")), IsNil)
+
+	blob, err := s.tcti.GetStateBlob(SwtpmBlobTypePermanent)
+	c.Check(err, IsNil)
+	c.Check(blob, DeepEquals, []byte("permanent state"))
+
+	blob, err = s.tcti.GetStateBlob(SwtpmBlobTypeVolatile)
+	c.Check(err, IsNil)
+	c.Check(blob, DeepEquals, []byte("volatile state"))
+}
+
+func (s *swtpmSuite) TestOpenInvalidNetwork(c *C) {
+	_, err := OpenSwtpm("udp", "localhost:2321", "localhost:2322")
+	c.Check(err, ErrorMatches, `invalid network argument: unsupported network type "udp"`)
+}
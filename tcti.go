@@ -9,11 +9,9 @@ package tpm2
 // - transmit, which is equivalent to io.Writer.
 // - receive, which is equivalent to io.Reader.
 // - finalize, which is equivalent to io.Closer.
-// - cancel, which we don't implement at the moment because there's not a mechanism to cancel
-//   an operation in go-tpm2. Perhaps if we move the call to Read() to a separate go routine and pass
-//   a context around, we could cancel using a deadline. Not today though, and the Linux character
-//   device driver doesn't provide a mechanism to cancel so it probably wouldn't be worth the effort
-//   anyway.
+// - cancel, which is equivalent to CancellableTCTI.Cancel. This is optional because the Linux
+//   character device driver doesn't provide a mechanism to cancel. TPMContext calls this when a
+//   command executed via one of the context aware methods is aborted.
 // - getPollHandles, doesn't really make sense here because go's runtime does the polling on
 //   Read.
 // - setLocality.
@@ -39,3 +37,14 @@ type TCTI interface {
 	// associated with the supplied handle between commands.
 	MakeSticky(handle Handle, sticky bool) error
 }
+
+// CancellableTCTI is implemented by TCTI implementations that can request the TPM to cancel the
+// currently executing command.
+type CancellableTCTI interface {
+	TCTI
+
+	// Cancel requests that the TPM cancels the command that is currently executing. It may be
+	// called from a different goroutine to the one blocked in Read. If the TPM cancels the
+	// command, it will respond with TPM_RC_CANCELED. The response must still be read.
+	Cancel() error
+}
@@ -1586,7 +1586,7 @@ func (s *templatesSuite) TestNewDerivationParentKeyWithDefaults(c *C) {
 func TestMain(m *testing.M) {
 	flag.Parse()
 	os.Exit(func() int {
-		if testutil.TPMBackend == testutil.TPMBackendMssim {
+		if testutil.TPMBackend == testutil.TPMBackendMssim || testutil.TPMBackend == testutil.TPMBackendSwtpm {
 			simulatorCleanup, err := testutil.LaunchTPMSimulator(nil)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Cannot launch TPM simulator: %v\n", err)
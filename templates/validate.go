@@ -0,0 +1,492 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package templates
+
+import (
+	"fmt"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// TemplateError is returned from Validate and CheckAlgorithms when a template is not valid.
+type TemplateError struct {
+	Field string // The offending field, eg "Params.RSADetail.Scheme.Scheme"
+	msg   string
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.msg)
+}
+
+func templateError(field, format string, args ...interface{}) error {
+	return &TemplateError{Field: field, msg: fmt.Sprintf(format, args...)}
+}
+
+const knownObjectAttrs = tpm2.AttrFixedTPM | tpm2.AttrStClear | tpm2.AttrFixedParent |
+	tpm2.AttrSensitiveDataOrigin | tpm2.AttrUserWithAuth | tpm2.AttrAdminWithPolicy | tpm2.AttrNoDA |
+	tpm2.AttrEncryptedDuplication | tpm2.AttrRestricted | tpm2.AttrDecrypt | tpm2.AttrSign
+
+// asymSchemeHash returns the digest algorithm of the supplied asymmetric scheme, along with
+// the name of the field in AsymSchemeU that contains it.
+func asymSchemeHash(scheme tpm2.AsymSchemeId, details *tpm2.AsymSchemeU) (tpm2.HashAlgorithmId, string, bool) {
+	if details == nil {
+		return tpm2.HashAlgorithmNull, "", false
+	}
+	switch scheme {
+	case tpm2.AsymSchemeRSASSA:
+		if details.RSASSA != nil {
+			return details.RSASSA.HashAlg, "RSASSA", true
+		}
+	case tpm2.AsymSchemeRSAPSS:
+		if details.RSAPSS != nil {
+			return details.RSAPSS.HashAlg, "RSAPSS", true
+		}
+	case tpm2.AsymSchemeOAEP:
+		if details.OAEP != nil {
+			return details.OAEP.HashAlg, "OAEP", true
+		}
+	case tpm2.AsymSchemeECDSA:
+		if details.ECDSA != nil {
+			return details.ECDSA.HashAlg, "ECDSA", true
+		}
+	case tpm2.AsymSchemeECDH:
+		if details.ECDH != nil {
+			return details.ECDH.HashAlg, "ECDH", true
+		}
+	case tpm2.AsymSchemeECDAA:
+		if details.ECDAA != nil {
+			return details.ECDAA.HashAlg, "ECDAA", true
+		}
+	case tpm2.AsymSchemeSM2:
+		if details.SM2 != nil {
+			return details.SM2.HashAlg, "SM2", true
+		}
+	case tpm2.AsymSchemeECSCHNORR:
+		if details.ECSCHNORR != nil {
+			return details.ECSCHNORR.HashAlg, "ECSCHNORR", true
+		}
+	case tpm2.AsymSchemeECMQV:
+		if details.ECMQV != nil {
+			return details.ECMQV.HashAlg, "ECMQV", true
+		}
+	}
+	return tpm2.HashAlgorithmNull, "", false
+}
+
+func validateAttrs(template, parent *tpm2.Public) error {
+	attrs := template.Attrs
+
+	if attrs&^knownObjectAttrs != 0 {
+		return templateError("Attrs", "reserved bits 0x%08x are set", uint32(attrs&^knownObjectAttrs))
+	}
+
+	fixedTPM := attrs&tpm2.AttrFixedTPM != 0
+	fixedParent := attrs&tpm2.AttrFixedParent != 0
+	encryptedDuplication := attrs&tpm2.AttrEncryptedDuplication != 0
+
+	// A primary object is treated as having a parent with AttrFixedTPM set.
+	if parent == nil || parent.Attrs&tpm2.AttrFixedTPM != 0 {
+		if fixedTPM != fixedParent {
+			return templateError("Attrs", "AttrFixedTPM and AttrFixedParent must have the same value when the parent has AttrFixedTPM set")
+		}
+	} else if fixedTPM {
+		return templateError("Attrs", "AttrFixedTPM cannot be set when the parent has AttrFixedTPM clear")
+	}
+
+	if fixedTPM && encryptedDuplication {
+		return templateError("Attrs", "AttrEncryptedDuplication cannot be set for an object with AttrFixedTPM set")
+	}
+	if parent != nil && parent.Attrs&tpm2.AttrFixedTPM == 0 && encryptedDuplication != (parent.Attrs&tpm2.AttrEncryptedDuplication != 0) {
+		return templateError("Attrs", "AttrEncryptedDuplication must have the same value as the parent when the parent has AttrFixedTPM clear")
+	}
+
+	sign := attrs&tpm2.AttrSign != 0
+	decrypt := attrs&tpm2.AttrDecrypt != 0
+	if sign == decrypt {
+		if attrs&tpm2.AttrRestricted != 0 {
+			return templateError("Attrs", "exactly one of AttrSign and AttrDecrypt must be set for a restricted key")
+		}
+		if !sign && template.Type != tpm2.ObjectTypeKeyedHash {
+			return templateError("Attrs", "one of AttrSign or AttrDecrypt must be set for a key that isn't a data object")
+		}
+	}
+
+	return nil
+}
+
+// validateSymmetric checks the symmetric algorithm of an asymmetric key. A restricted
+// decrypt key must have a symmetric algorithm in CFB mode, and all other keys must
+// not have one.
+func validateSymmetric(field string, template *tpm2.Public, sym *tpm2.SymDefObject) error {
+	if template.Attrs&(tpm2.AttrRestricted|tpm2.AttrDecrypt) != tpm2.AttrRestricted|tpm2.AttrDecrypt {
+		if sym.Algorithm != tpm2.SymObjectAlgorithmNull {
+			return templateError(field+".Algorithm", "must be SymObjectAlgorithmNull for a key that isn't a restricted decrypt key")
+		}
+		return nil
+	}
+	return validateSymDef(field, sym)
+}
+
+func validateSymDef(field string, sym *tpm2.SymDefObject) error {
+	if !sym.Algorithm.IsValidBlockCipher() {
+		return templateError(field+".Algorithm", "%v is not a valid block cipher", tpm2.AlgorithmId(sym.Algorithm))
+	}
+	if sym.KeyBits == nil || sym.KeyBits.Sym == 0 {
+		return templateError(field+".KeyBits", "no key size specified")
+	}
+	if sym.Mode == nil {
+		return templateError(field+".Mode", "no mode specified")
+	}
+	return nil
+}
+
+func validateAsymScheme(field string, template *tpm2.Public, scheme tpm2.AsymSchemeId, details *tpm2.AsymSchemeU, signSchemes, decryptSchemes []tpm2.AsymSchemeId) error {
+	contains := func(schemes []tpm2.AsymSchemeId) bool {
+		for _, s := range schemes {
+			if s == scheme {
+				return true
+			}
+		}
+		return false
+	}
+
+	attrs := template.Attrs
+	restricted := attrs&tpm2.AttrRestricted != 0
+
+	switch {
+	case attrs&tpm2.AttrSign != 0 && attrs&tpm2.AttrDecrypt != 0:
+		if scheme != tpm2.AsymSchemeNull {
+			return templateError(field+".Scheme", "must be null for a key with both AttrSign and AttrDecrypt set")
+		}
+	case attrs&tpm2.AttrSign != 0:
+		switch {
+		case scheme == tpm2.AsymSchemeNull && restricted:
+			return templateError(field+".Scheme", "a restricted signing key requires a signing scheme")
+		case scheme != tpm2.AsymSchemeNull && !contains(signSchemes):
+			return templateError(field+".Scheme", "%v is not a valid signing scheme", tpm2.AlgorithmId(scheme))
+		}
+	case attrs&tpm2.AttrDecrypt != 0:
+		switch {
+		case scheme != tpm2.AsymSchemeNull && restricted:
+			return templateError(field+".Scheme", "must be null for a restricted decrypt key")
+		case scheme != tpm2.AsymSchemeNull && !contains(decryptSchemes):
+			return templateError(field+".Scheme", "%v is not a valid decrypt scheme", tpm2.AlgorithmId(scheme))
+		}
+	}
+
+	if !scheme.HasDigest() {
+		return nil
+	}
+	hashAlg, name, ok := asymSchemeHash(scheme, details)
+	if !ok {
+		return templateError(field+".Details", "no details for scheme %v", tpm2.AlgorithmId(scheme))
+	}
+	if !hashAlg.IsValid() {
+		return templateError(field+".Details."+name+".HashAlg", "%v is not a valid digest algorithm", tpm2.AlgorithmId(hashAlg))
+	}
+	return nil
+}
+
+func validateKeyedHashScheme(template *tpm2.Public, scheme *tpm2.KeyedHashScheme) error {
+	const field = "Params.KeyedHashDetail.Scheme"
+
+	attrs := template.Attrs
+	restricted := attrs&tpm2.AttrRestricted != 0
+	sign := attrs&tpm2.AttrSign != 0
+	decrypt := attrs&tpm2.AttrDecrypt != 0
+
+	switch {
+	case sign == decrypt:
+		if scheme.Scheme != tpm2.KeyedHashSchemeNull {
+			return templateError(field+".Scheme", "must be null for a keyedhash object with AttrSign and AttrDecrypt both set or both clear")
+		}
+		return nil
+	case sign && (scheme.Scheme == tpm2.KeyedHashSchemeXOR || (restricted && scheme.Scheme == tpm2.KeyedHashSchemeNull)):
+		return templateError(field+".Scheme", "must be KeyedHashSchemeHMAC for a keyedhash signing key")
+	case decrypt && (scheme.Scheme == tpm2.KeyedHashSchemeHMAC || (restricted && scheme.Scheme == tpm2.KeyedHashSchemeNull)):
+		return templateError(field+".Scheme", "must be KeyedHashSchemeXOR for a keyedhash decrypt key")
+	}
+
+	switch scheme.Scheme {
+	case tpm2.KeyedHashSchemeHMAC:
+		if scheme.Details == nil || scheme.Details.HMAC == nil {
+			return templateError(field+".Details", "no details for scheme %v", tpm2.AlgorithmId(scheme.Scheme))
+		}
+		if !scheme.Details.HMAC.HashAlg.IsValid() {
+			return templateError(field+".Details.HMAC.HashAlg", "%v is not a valid digest algorithm", tpm2.AlgorithmId(scheme.Details.HMAC.HashAlg))
+		}
+	case tpm2.KeyedHashSchemeXOR:
+		if scheme.Details == nil || scheme.Details.XOR == nil {
+			return templateError(field+".Details", "no details for scheme %v", tpm2.AlgorithmId(scheme.Scheme))
+		}
+		if !scheme.Details.XOR.HashAlg.IsValid() {
+			return templateError(field+".Details.XOR.HashAlg", "%v is not a valid digest algorithm", tpm2.AlgorithmId(scheme.Details.XOR.HashAlg))
+		}
+		if restricted && scheme.Details.XOR.KDF != tpm2.KDFAlgorithmKDF1_SP800_108 {
+			return templateError(field+".Details.XOR.KDF", "must be KDFAlgorithmKDF1_SP800_108 for a derivation parent")
+		}
+	case tpm2.KeyedHashSchemeNull:
+	default:
+		return templateError(field+".Scheme", "%v is not a valid keyedhash scheme", tpm2.AlgorithmId(scheme.Scheme))
+	}
+	return nil
+}
+
+func validateParams(template *tpm2.Public) error {
+	if template.Params == nil {
+		return templateError("Params", "no parameters")
+	}
+
+	switch template.Type {
+	case tpm2.ObjectTypeRSA:
+		params := template.Params.RSADetail
+		if params == nil {
+			return templateError("Params.RSADetail", "no parameters for type %v", tpm2.AlgorithmId(template.Type))
+		}
+		if err := validateSymmetric("Params.RSADetail.Symmetric", template, &params.Symmetric); err != nil {
+			return err
+		}
+		if err := validateAsymScheme("Params.RSADetail.Scheme", template, tpm2.AsymSchemeId(params.Scheme.Scheme), params.Scheme.Details,
+			[]tpm2.AsymSchemeId{tpm2.AsymSchemeRSASSA, tpm2.AsymSchemeRSAPSS},
+			[]tpm2.AsymSchemeId{tpm2.AsymSchemeRSAES, tpm2.AsymSchemeOAEP}); err != nil {
+			return err
+		}
+		if params.KeyBits == 0 {
+			return templateError("Params.RSADetail.KeyBits", "no key size specified")
+		}
+	case tpm2.ObjectTypeECC:
+		params := template.Params.ECCDetail
+		if params == nil {
+			return templateError("Params.ECCDetail", "no parameters for type %v", tpm2.AlgorithmId(template.Type))
+		}
+		if err := validateSymmetric("Params.ECCDetail.Symmetric", template, &params.Symmetric); err != nil {
+			return err
+		}
+		if err := validateAsymScheme("Params.ECCDetail.Scheme", template, tpm2.AsymSchemeId(params.Scheme.Scheme), params.Scheme.Details,
+			[]tpm2.AsymSchemeId{tpm2.AsymSchemeECDSA, tpm2.AsymSchemeECDAA, tpm2.AsymSchemeSM2, tpm2.AsymSchemeECSCHNORR},
+			[]tpm2.AsymSchemeId{tpm2.AsymSchemeECDH, tpm2.AsymSchemeECMQV}); err != nil {
+			return err
+		}
+	case tpm2.ObjectTypeKeyedHash:
+		params := template.Params.KeyedHashDetail
+		if params == nil {
+			return templateError("Params.KeyedHashDetail", "no parameters for type %v", tpm2.AlgorithmId(template.Type))
+		}
+		if err := validateKeyedHashScheme(template, &params.Scheme); err != nil {
+			return err
+		}
+	case tpm2.ObjectTypeSymCipher:
+		params := template.Params.SymDetail
+		if params == nil {
+			return templateError("Params.SymDetail", "no parameters for type %v", tpm2.AlgorithmId(template.Type))
+		}
+		if err := validateSymDef("Params.SymDetail.Sym", &params.Sym); err != nil {
+			return err
+		}
+	default:
+		return templateError("Type", "%v is not a valid object type", tpm2.AlgorithmId(template.Type))
+	}
+
+	if template.Attrs&(tpm2.AttrRestricted|tpm2.AttrDecrypt) == tpm2.AttrRestricted|tpm2.AttrDecrypt {
+		var field string
+		var sym *tpm2.SymDefObject
+		switch template.Type {
+		case tpm2.ObjectTypeRSA:
+			field, sym = "Params.RSADetail.Symmetric.Mode", &template.Params.RSADetail.Symmetric
+		case tpm2.ObjectTypeECC:
+			field, sym = "Params.ECCDetail.Symmetric.Mode", &template.Params.ECCDetail.Symmetric
+		case tpm2.ObjectTypeSymCipher:
+			field, sym = "Params.SymDetail.Sym.Mode", &template.Params.SymDetail.Sym
+		}
+		if sym != nil && sym.Mode.Sym != tpm2.SymModeCFB {
+			return templateError(field, "must be SymModeCFB for a storage parent")
+		}
+	}
+
+	return nil
+}
+
+func validateSensitive(template *tpm2.Public, sensitive *tpm2.SensitiveCreate) error {
+	if sensitive == nil {
+		sensitive = &tpm2.SensitiveCreate{}
+	}
+
+	if len(sensitive.UserAuth) > template.NameAlg.Size() {
+		return templateError("Sensitive.UserAuth", "larger than the size of the name algorithm")
+	}
+
+	sensitiveDataOrigin := template.Attrs&tpm2.AttrSensitiveDataOrigin != 0
+
+	switch {
+	case template.IsAsymmetric():
+		if !sensitiveDataOrigin {
+			return templateError("Attrs", "AttrSensitiveDataOrigin must be set for an asymmetric key")
+		}
+		if len(sensitive.Data) > 0 {
+			return templateError("Sensitive.Data", "must be empty for an asymmetric key")
+		}
+	case template.Type == tpm2.ObjectTypeKeyedHash && template.Attrs&(tpm2.AttrSign|tpm2.AttrDecrypt) == 0:
+		if sensitiveDataOrigin {
+			return templateError("Attrs", "AttrSensitiveDataOrigin cannot be set for a data object")
+		}
+	default:
+		if sensitiveDataOrigin && len(sensitive.Data) > 0 {
+			return templateError("Sensitive.Data", "must be empty when AttrSensitiveDataOrigin is set")
+		}
+		if !sensitiveDataOrigin && len(sensitive.Data) == 0 {
+			return templateError("Sensitive.Data", "must be supplied when AttrSensitiveDataOrigin is clear")
+		}
+		if template.Type == tpm2.ObjectTypeSymCipher && len(sensitive.Data) > 0 && len(sensitive.Data)*8 != int(template.Params.SymDetail.Sym.KeyBits.Sym) {
+			return templateError("Sensitive.Data", "has the wrong size for a %d-bit key", template.Params.SymDetail.Sym.KeyBits.Sym)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the supplied template is consistent with the rules that the TPM applies
+// when creating an object with TPMContext.Create or TPMContext.CreatePrimary, so that mistakes
+// are reported with the name of the offending field rather than as a TPM parameter error.
+//
+// The parent argument is the public area of the parent object. It should be nil for a primary
+// object. The sensitive argument is the sensitive data that will be supplied to the TPM, and
+// may be nil if none will be supplied.
+//
+// If the template is invalid, a *TemplateError will be returned. This doesn't check whether
+// the TPM supports the algorithms used by the template - use CheckAlgorithms for this.
+func Validate(template, parent *tpm2.Public, sensitive *tpm2.SensitiveCreate) error {
+	if parent != nil && !parent.IsStorageParent() {
+		return fmt.Errorf("parent object is not a storage parent")
+	}
+
+	if !template.NameAlg.IsValid() {
+		return templateError("NameAlg", "%v is not a valid digest algorithm", tpm2.AlgorithmId(template.NameAlg))
+	}
+	if len(template.AuthPolicy) > 0 && len(template.AuthPolicy) != template.NameAlg.Size() {
+		return templateError("AuthPolicy", "has the wrong size for the name algorithm")
+	}
+
+	if err := validateAttrs(template, parent); err != nil {
+		return err
+	}
+	if err := validateParams(template); err != nil {
+		return err
+	}
+	return validateSensitive(template, sensitive)
+}
+
+type templateAlg struct {
+	field string
+	alg   tpm2.AlgorithmId
+}
+
+// templateAlgs returns the algorithms used by the supplied template, along with the
+// name of the field that each one appears in.
+func templateAlgs(template *tpm2.Public) (out []templateAlg) {
+	add := func(field string, alg tpm2.AlgorithmId) {
+		if alg == tpm2.AlgorithmNull {
+			return
+		}
+		out = append(out, templateAlg{field: field, alg: alg})
+	}
+	addSym := func(field string, sym *tpm2.SymDefObject) {
+		add(field+".Algorithm", tpm2.AlgorithmId(sym.Algorithm))
+		if sym.Algorithm != tpm2.SymObjectAlgorithmNull && sym.Mode != nil {
+			add(field+".Mode", tpm2.AlgorithmId(sym.Mode.Sym))
+		}
+	}
+	addAsymScheme := func(field string, scheme tpm2.AsymSchemeId, details *tpm2.AsymSchemeU) {
+		add(field+".Scheme", tpm2.AlgorithmId(scheme))
+		if hashAlg, name, ok := asymSchemeHash(scheme, details); ok {
+			add(field+".Details."+name+".HashAlg", tpm2.AlgorithmId(hashAlg))
+		}
+	}
+
+	add("Type", tpm2.AlgorithmId(template.Type))
+	add("NameAlg", tpm2.AlgorithmId(template.NameAlg))
+
+	if template.Params == nil {
+		return out
+	}
+
+	switch template.Type {
+	case tpm2.ObjectTypeRSA:
+		if params := template.Params.RSADetail; params != nil {
+			addSym("Params.RSADetail.Symmetric", &params.Symmetric)
+			addAsymScheme("Params.RSADetail.Scheme", tpm2.AsymSchemeId(params.Scheme.Scheme), params.Scheme.Details)
+		}
+	case tpm2.ObjectTypeECC:
+		if params := template.Params.ECCDetail; params != nil {
+			addSym("Params.ECCDetail.Symmetric", &params.Symmetric)
+			addAsymScheme("Params.ECCDetail.Scheme", tpm2.AsymSchemeId(params.Scheme.Scheme), params.Scheme.Details)
+			add("Params.ECCDetail.KDF.Scheme", tpm2.AlgorithmId(params.KDF.Scheme))
+		}
+	case tpm2.ObjectTypeKeyedHash:
+		if params := template.Params.KeyedHashDetail; params != nil {
+			scheme := &params.Scheme
+			add("Params.KeyedHashDetail.Scheme.Scheme", tpm2.AlgorithmId(scheme.Scheme))
+			switch {
+			case scheme.Details == nil:
+			case scheme.Scheme == tpm2.KeyedHashSchemeHMAC && scheme.Details.HMAC != nil:
+				add("Params.KeyedHashDetail.Scheme.Details.HMAC.HashAlg", tpm2.AlgorithmId(scheme.Details.HMAC.HashAlg))
+			case scheme.Scheme == tpm2.KeyedHashSchemeXOR && scheme.Details.XOR != nil:
+				add("Params.KeyedHashDetail.Scheme.Details.XOR.HashAlg", tpm2.AlgorithmId(scheme.Details.XOR.HashAlg))
+				add("Params.KeyedHashDetail.Scheme.Details.XOR.KDF", tpm2.AlgorithmId(scheme.Details.XOR.KDF))
+			}
+		}
+	case tpm2.ObjectTypeSymCipher:
+		if params := template.Params.SymDetail; params != nil {
+			addSym("Params.SymDetail.Sym", &params.Sym)
+		}
+	}
+
+	return out
+}
+
+// CheckAlgorithms checks that every algorithm used by the supplied template appears in the
+// supplied list of algorithms, which would normally be obtained from
+// TPMContext.GetCapabilityAlgs. If an algorithm is not supported, a *TemplateError is
+// returned that names the field that the algorithm appears in.
+func CheckAlgorithms(template *tpm2.Public, algs tpm2.AlgorithmPropertyList) error {
+	supported := make(map[tpm2.AlgorithmId]bool)
+	for _, a := range algs {
+		supported[a.Alg] = true
+	}
+
+	for _, a := range templateAlgs(template) {
+		if !supported[a.alg] {
+			return templateError(a.field, "algorithm %v is not supported by the TPM", a.alg)
+		}
+	}
+	return nil
+}
+
+// CheckTPMSupport is a convenience function that checks that the algorithms and ECC curve
+// used by the supplied template are supported by the TPM, using the results of
+// TPMContext.GetCapabilityAlgs and TPMContext.GetCapabilityECCCurves.
+func CheckTPMSupport(tpm *tpm2.TPMContext, template *tpm2.Public, sessions ...tpm2.SessionContext) error {
+	algs, err := tpm.GetCapabilityAlgs(tpm2.AlgorithmFirst, tpm2.CapabilityMaxProperties, sessions...)
+	if err != nil {
+		return err
+	}
+	if err := CheckAlgorithms(template, algs); err != nil {
+		return err
+	}
+
+	if template.Type != tpm2.ObjectTypeECC || template.Params == nil || template.Params.ECCDetail == nil {
+		return nil
+	}
+	curve := template.Params.ECCDetail.CurveID
+	curves, err := tpm.GetCapabilityECCCurves(sessions...)
+	if err != nil {
+		return err
+	}
+	for _, c := range curves {
+		if c == curve {
+			return nil
+		}
+	}
+	return templateError("Params.ECCDetail.CurveID", "curve 0x%04x is not supported by the TPM", uint16(curve))
+}
@@ -0,0 +1,298 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package templates_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	. "github.com/canonical/go-tpm2/templates"
+)
+
+type validateSuite struct{}
+
+var _ = Suite(&validateSuite{})
+
+func (s *validateSuite) checkTemplateError(c *C, err error, field, pattern string) {
+	c.Assert(err, NotNil)
+	c.Check(err, ErrorMatches, pattern)
+	e, ok := err.(*TemplateError)
+	c.Assert(ok, Equals, true, Commentf("unexpected error type %T", err))
+	c.Check(e.Field, Equals, field)
+}
+
+func (s *validateSuite) TestValidTemplates(c *C) {
+	for _, template := range []*tpm2.Public{
+		NewRSAStorageKeyWithDefaults(),
+		NewRestrictedRSASigningKeyWithDefaults(),
+		NewRSAKeyWithDefaults(0),
+		NewRSAKeyWithDefaults(KeyUsageSign),
+		NewRSAKeyWithDefaults(KeyUsageDecrypt),
+		NewECCStorageKeyWithDefaults(),
+		NewRestrictedECCSigningKeyWithDefaults(),
+		NewECCKeyWithDefaults(KeyUsageSign),
+		NewECCKeyWithDefaults(KeyUsageDecrypt),
+		NewSymmetricStorageKeyWithDefaults(),
+		NewSymmetricKeyWithDefaults(KeyUsageEncrypt),
+		NewHMACKeyWithDefaults(),
+		NewDerivationParentKeyWithDefaults()} {
+		c.Check(Validate(template, nil, nil), IsNil)
+		c.Check(Validate(template, NewRSAStorageKeyWithDefaults(), nil), IsNil)
+	}
+}
+
+func (s *validateSuite) TestValidSealedObject(c *C) {
+	c.Check(Validate(NewSealedObject(tpm2.HashAlgorithmSHA256), NewRSAStorageKeyWithDefaults(),
+		&tpm2.SensitiveCreate{Data: []byte("secret")}), IsNil)
+}
+
+func (s *validateSuite) TestValidImportedKey(c *C) {
+	template := NewSymmetricKeyWithDefaults(KeyUsageEncrypt)
+	template.Attrs &^= tpm2.AttrSensitiveDataOrigin
+	c.Check(Validate(template, nil, &tpm2.SensitiveCreate{Data: make([]byte, 16)}), IsNil)
+}
+
+func (s *validateSuite) TestValidDuplicableKey(c *C) {
+	parent := NewRSAStorageKeyWithDefaults()
+	parent.Attrs &^= tpm2.AttrFixedTPM | tpm2.AttrFixedParent
+	parent.Attrs |= tpm2.AttrEncryptedDuplication
+
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Attrs &^= tpm2.AttrFixedTPM
+	template.Attrs |= tpm2.AttrEncryptedDuplication
+	c.Check(Validate(template, parent, nil), IsNil)
+}
+
+func (s *validateSuite) TestInvalidNameAlg(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.NameAlg = tpm2.HashAlgorithmNull
+	s.checkTemplateError(c, Validate(template, nil, nil), "NameAlg", "invalid NameAlg: TPM_ALG_NULL is not a valid digest algorithm")
+}
+
+func (s *validateSuite) TestInvalidAuthPolicySize(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.AuthPolicy = make(tpm2.Digest, 20)
+	s.checkTemplateError(c, Validate(template, nil, nil), "AuthPolicy", "invalid AuthPolicy: has the wrong size for the name algorithm")
+}
+
+func (s *validateSuite) TestReservedAttrs(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Attrs |= 1 << 20
+	s.checkTemplateError(c, Validate(template, nil, nil), "Attrs", "invalid Attrs: reserved bits 0x00100000 are set")
+}
+
+func (s *validateSuite) TestRestrictedSignAndDecrypt(c *C) {
+	template := NewRSAStorageKeyWithDefaults()
+	template.Attrs |= tpm2.AttrSign
+	s.checkTemplateError(c, Validate(template, nil, nil), "Attrs", "invalid Attrs: exactly one of AttrSign and AttrDecrypt must be set for a restricted key")
+}
+
+func (s *validateSuite) TestNoSignOrDecrypt(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Attrs &^= tpm2.AttrSign
+	s.checkTemplateError(c, Validate(template, nil, nil), "Attrs", "invalid Attrs: one of AttrSign or AttrDecrypt must be set for a key that isn't a data object")
+}
+
+func (s *validateSuite) TestFixedTPMWithoutFixedParent(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Attrs &^= tpm2.AttrFixedParent
+	s.checkTemplateError(c, Validate(template, nil, nil), "Attrs", "invalid Attrs: AttrFixedTPM and AttrFixedParent must have the same value when the parent has AttrFixedTPM set")
+}
+
+func (s *validateSuite) TestFixedTPMWithDuplicableParent(c *C) {
+	parent := NewRSAStorageKeyWithDefaults()
+	parent.Attrs &^= tpm2.AttrFixedTPM
+
+	s.checkTemplateError(c, Validate(NewRSAKeyWithDefaults(KeyUsageSign), parent, nil), "Attrs",
+		"invalid Attrs: AttrFixedTPM cannot be set when the parent has AttrFixedTPM clear")
+}
+
+func (s *validateSuite) TestEncryptedDuplicationWithFixedTPM(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Attrs |= tpm2.AttrEncryptedDuplication
+	s.checkTemplateError(c, Validate(template, nil, nil), "Attrs", "invalid Attrs: AttrEncryptedDuplication cannot be set for an object with AttrFixedTPM set")
+}
+
+func (s *validateSuite) TestEncryptedDuplicationInconsistentWithParent(c *C) {
+	parent := NewRSAStorageKeyWithDefaults()
+	parent.Attrs &^= tpm2.AttrFixedTPM
+	parent.Attrs |= tpm2.AttrEncryptedDuplication
+
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Attrs &^= tpm2.AttrFixedTPM
+	s.checkTemplateError(c, Validate(template, parent, nil), "Attrs",
+		"invalid Attrs: AttrEncryptedDuplication must have the same value as the parent when the parent has AttrFixedTPM clear")
+}
+
+func (s *validateSuite) TestParentNotStorageKey(c *C) {
+	c.Check(Validate(NewRSAKeyWithDefaults(KeyUsageSign), NewRestrictedRSASigningKeyWithDefaults(), nil), ErrorMatches,
+		"parent object is not a storage parent")
+}
+
+func (s *validateSuite) TestRestrictedDecryptNoSymmetric(c *C) {
+	template := NewRSAStorageKeyWithDefaults()
+	template.Params.RSADetail.Symmetric = tpm2.SymDefObject{Algorithm: tpm2.SymObjectAlgorithmNull}
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.RSADetail.Symmetric.Algorithm",
+		"invalid Params.RSADetail.Symmetric.Algorithm: TPM_ALG_NULL is not a valid block cipher")
+}
+
+func (s *validateSuite) TestRestrictedDecryptWrongMode(c *C) {
+	template := NewECCStorageKeyWithDefaults()
+	template.Params.ECCDetail.Symmetric.Mode.Sym = tpm2.SymModeCBC
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.ECCDetail.Symmetric.Mode",
+		"invalid Params.ECCDetail.Symmetric.Mode: must be SymModeCFB for a storage parent")
+}
+
+func (s *validateSuite) TestUnrestrictedWithSymmetric(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageDecrypt)
+	template.Params.RSADetail.Symmetric = NewRSAStorageKeyWithDefaults().Params.RSADetail.Symmetric
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.RSADetail.Symmetric.Algorithm",
+		"invalid Params.RSADetail.Symmetric.Algorithm: must be SymObjectAlgorithmNull for a key that isn't a restricted decrypt key")
+}
+
+func (s *validateSuite) TestRestrictedSigningNoScheme(c *C) {
+	template := NewRestrictedRSASigningKeyWithDefaults()
+	template.Params.RSADetail.Scheme = tpm2.RSAScheme{Scheme: tpm2.RSASchemeNull}
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.RSADetail.Scheme.Scheme",
+		"invalid Params.RSADetail.Scheme.Scheme: a restricted signing key requires a signing scheme")
+}
+
+func (s *validateSuite) TestSigningKeyWithDecryptScheme(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Params.RSADetail.Scheme = tpm2.RSAScheme{
+		Scheme:  tpm2.RSASchemeOAEP,
+		Details: &tpm2.AsymSchemeU{OAEP: &tpm2.EncSchemeOAEP{HashAlg: tpm2.HashAlgorithmSHA256}}}
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.RSADetail.Scheme.Scheme",
+		"invalid Params.RSADetail.Scheme.Scheme: TPM_ALG_OAEP is not a valid signing scheme")
+}
+
+func (s *validateSuite) TestDecryptKeyWithSigningScheme(c *C) {
+	template := NewECCKeyWithDefaults(KeyUsageDecrypt)
+	template.Params.ECCDetail.Scheme = tpm2.ECCScheme{
+		Scheme:  tpm2.ECCSchemeECDSA,
+		Details: &tpm2.AsymSchemeU{ECDSA: &tpm2.SigSchemeECDSA{HashAlg: tpm2.HashAlgorithmSHA256}}}
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.ECCDetail.Scheme.Scheme",
+		"invalid Params.ECCDetail.Scheme.Scheme: TPM_ALG_ECDSA is not a valid decrypt scheme")
+}
+
+func (s *validateSuite) TestSignAndDecryptWithScheme(c *C) {
+	template := NewRSAKeyWithDefaults(0)
+	template.Params.RSADetail.Scheme = tpm2.RSAScheme{
+		Scheme:  tpm2.RSASchemeRSASSA,
+		Details: &tpm2.AsymSchemeU{RSASSA: &tpm2.SigSchemeRSASSA{HashAlg: tpm2.HashAlgorithmSHA256}}}
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.RSADetail.Scheme.Scheme",
+		"invalid Params.RSADetail.Scheme.Scheme: must be null for a key with both AttrSign and AttrDecrypt set")
+}
+
+func (s *validateSuite) TestSchemeInvalidHash(c *C) {
+	template := NewRestrictedECCSigningKeyWithDefaults()
+	template.Params.ECCDetail.Scheme.Details.ECDSA.HashAlg = tpm2.HashAlgorithmNull
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.ECCDetail.Scheme.Details.ECDSA.HashAlg",
+		"invalid Params.ECCDetail.Scheme.Details.ECDSA.HashAlg: TPM_ALG_NULL is not a valid digest algorithm")
+}
+
+func (s *validateSuite) TestHMACKeyWithXORScheme(c *C) {
+	template := NewHMACKeyWithDefaults()
+	template.Params.KeyedHashDetail.Scheme = tpm2.KeyedHashScheme{
+		Scheme: tpm2.KeyedHashSchemeXOR,
+		Details: &tpm2.SchemeKeyedHashU{
+			XOR: &tpm2.SchemeXOR{HashAlg: tpm2.HashAlgorithmSHA256, KDF: tpm2.KDFAlgorithmKDF1_SP800_108}}}
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.KeyedHashDetail.Scheme.Scheme",
+		"invalid Params.KeyedHashDetail.Scheme.Scheme: must be KeyedHashSchemeHMAC for a keyedhash signing key")
+}
+
+func (s *validateSuite) TestDerivationParentWrongKDF(c *C) {
+	template := NewDerivationParentKeyWithDefaults()
+	template.Params.KeyedHashDetail.Scheme.Details.XOR.KDF = tpm2.KDFAlgorithmKDF2
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.KeyedHashDetail.Scheme.Details.XOR.KDF",
+		"invalid Params.KeyedHashDetail.Scheme.Details.XOR.KDF: must be KDFAlgorithmKDF1_SP800_108 for a derivation parent")
+}
+
+func (s *validateSuite) TestSealedObjectWithScheme(c *C) {
+	template := NewSealedObject(tpm2.HashAlgorithmSHA256)
+	template.Params.KeyedHashDetail.Scheme = NewHMACKeyWithDefaults().Params.KeyedHashDetail.Scheme
+	s.checkTemplateError(c, Validate(template, nil, &tpm2.SensitiveCreate{Data: []byte("secret")}), "Params.KeyedHashDetail.Scheme.Scheme",
+		"invalid Params.KeyedHashDetail.Scheme.Scheme: must be null for a keyedhash object with AttrSign and AttrDecrypt both set or both clear")
+}
+
+func (s *validateSuite) TestSealedObjectWithSensitiveDataOrigin(c *C) {
+	template := NewSealedObject(tpm2.HashAlgorithmSHA256)
+	template.Attrs |= tpm2.AttrSensitiveDataOrigin
+	s.checkTemplateError(c, Validate(template, nil, nil), "Attrs", "invalid Attrs: AttrSensitiveDataOrigin cannot be set for a data object")
+}
+
+func (s *validateSuite) TestAsymmetricKeyWithSensitiveData(c *C) {
+	s.checkTemplateError(c, Validate(NewRSAKeyWithDefaults(KeyUsageSign), nil, &tpm2.SensitiveCreate{Data: []byte("foo")}),
+		"Sensitive.Data", "invalid Sensitive.Data: must be empty for an asymmetric key")
+}
+
+func (s *validateSuite) TestAsymmetricKeyWithoutSensitiveDataOrigin(c *C) {
+	template := NewECCKeyWithDefaults(KeyUsageSign)
+	template.Attrs &^= tpm2.AttrSensitiveDataOrigin
+	s.checkTemplateError(c, Validate(template, nil, nil), "Attrs", "invalid Attrs: AttrSensitiveDataOrigin must be set for an asymmetric key")
+}
+
+func (s *validateSuite) TestSensitiveDataWithSensitiveDataOrigin(c *C) {
+	s.checkTemplateError(c, Validate(NewHMACKeyWithDefaults(), nil, &tpm2.SensitiveCreate{Data: []byte("foo")}),
+		"Sensitive.Data", "invalid Sensitive.Data: must be empty when AttrSensitiveDataOrigin is set")
+}
+
+func (s *validateSuite) TestNoSensitiveDataWithoutSensitiveDataOrigin(c *C) {
+	template := NewHMACKeyWithDefaults()
+	template.Attrs &^= tpm2.AttrSensitiveDataOrigin
+	s.checkTemplateError(c, Validate(template, nil, nil), "Sensitive.Data", "invalid Sensitive.Data: must be supplied when AttrSensitiveDataOrigin is clear")
+}
+
+func (s *validateSuite) TestSymmetricKeyWrongSize(c *C) {
+	template := NewSymmetricKeyWithDefaults(KeyUsageEncrypt)
+	template.Attrs &^= tpm2.AttrSensitiveDataOrigin
+	s.checkTemplateError(c, Validate(template, nil, &tpm2.SensitiveCreate{Data: make([]byte, 32)}), "Sensitive.Data",
+		"invalid Sensitive.Data: has the wrong size for a 128-bit key")
+}
+
+func (s *validateSuite) TestUserAuthTooLarge(c *C) {
+	s.checkTemplateError(c, Validate(NewRSAKeyWithDefaults(KeyUsageSign), nil, &tpm2.SensitiveCreate{UserAuth: make(tpm2.Auth, 33)}),
+		"Sensitive.UserAuth", "invalid Sensitive.UserAuth: larger than the size of the name algorithm")
+}
+
+func (s *validateSuite) TestInvalidType(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Type = tpm2.ObjectTypeId(tpm2.AlgorithmSHA256)
+	s.checkTemplateError(c, Validate(template, nil, nil), "Type", "invalid Type: TPM_ALG_SHA256 is not a valid object type")
+}
+
+func (s *validateSuite) TestMissingParams(c *C) {
+	template := NewRSAKeyWithDefaults(KeyUsageSign)
+	template.Type = tpm2.ObjectTypeECC
+	s.checkTemplateError(c, Validate(template, nil, nil), "Params.ECCDetail", "invalid Params.ECCDetail: no parameters for type TPM_ALG_ECC")
+}
+
+func (s *validateSuite) TestCheckAlgorithms(c *C) {
+	algs := tpm2.AlgorithmPropertyList{
+		{Alg: tpm2.AlgorithmRSA}, {Alg: tpm2.AlgorithmSHA256}, {Alg: tpm2.AlgorithmAES},
+		{Alg: tpm2.AlgorithmCFB}, {Alg: tpm2.AlgorithmRSASSA}}
+
+	c.Check(CheckAlgorithms(NewRSAStorageKeyWithDefaults(), algs), IsNil)
+	c.Check(CheckAlgorithms(NewRestrictedRSASigningKeyWithDefaults(), algs), IsNil)
+
+	template := NewRestrictedRSASigningKey(tpm2.HashAlgorithmSHA256, &tpm2.RSAScheme{
+		Scheme:  tpm2.RSASchemeRSAPSS,
+		Details: &tpm2.AsymSchemeU{RSAPSS: &tpm2.SigSchemeRSAPSS{HashAlg: tpm2.HashAlgorithmSHA256}}}, 2048)
+	s.checkTemplateError(c, CheckAlgorithms(template, algs), "Params.RSADetail.Scheme.Scheme",
+		"invalid Params.RSADetail.Scheme.Scheme: algorithm TPM_ALG_RSAPSS is not supported by the TPM")
+
+	s.checkTemplateError(c, CheckAlgorithms(NewRSAStorageKey(tpm2.HashAlgorithmSHA384, tpm2.SymObjectAlgorithmAES, 128, 2048), algs), "NameAlg",
+		"invalid NameAlg: algorithm TPM_ALG_SHA384 is not supported by the TPM")
+
+	s.checkTemplateError(c, CheckAlgorithms(NewECCStorageKeyWithDefaults(), algs), "Type",
+		"invalid Type: algorithm TPM_ALG_ECC is not supported by the TPM")
+}
+
+func (s *validateSuite) TestCheckAlgorithmsXORKDF(c *C) {
+	algs := tpm2.AlgorithmPropertyList{
+		{Alg: tpm2.AlgorithmKeyedHash}, {Alg: tpm2.AlgorithmSHA256}, {Alg: tpm2.AlgorithmXOR}}
+	s.checkTemplateError(c, CheckAlgorithms(NewDerivationParentKeyWithDefaults(), algs), "Params.KeyedHashDetail.Scheme.Details.XOR.KDF",
+		"invalid Params.KeyedHashDetail.Scheme.Details.XOR.KDF: algorithm TPM_ALG_KDF1_SP800_108 is not supported by the TPM")
+}
@@ -0,0 +1,329 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package testutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+
+	"golang.org/x/xerrors"
+)
+
+// FaultType describes the type of a fault injected by FaultInjectionTCTI.
+type FaultType int
+
+const (
+	// FaultResponseCode causes the command to not be submitted to the TPM, and a
+	// response containing only the response code specified by Fault.ResponseCode
+	// to be returned instead. This can be used to return TPM_RC_RETRY or
+	// TPM_RC_YIELDED a set number of times.
+	FaultResponseCode FaultType = iota
+
+	// FaultTruncateResponse causes the response to be truncated to the number of
+	// bytes specified by Fault.Length.
+	FaultTruncateResponse
+
+	// FaultCorruptResponse causes the byte in the response at the offset specified
+	// by Fault.Offset to be XORed with Fault.Mask.
+	FaultCorruptResponse
+
+	// FaultFlipResponseHMAC causes the first byte of the HMAC in the response auth
+	// area for the session at the index specified by Fault.Session to be XORed with
+	// Fault.Mask.
+	FaultFlipResponseHMAC
+
+	// FaultWriteError causes the command to not be submitted to the TPM, and the
+	// error specified by Fault.Err to be returned from FaultInjectionTCTI.Write.
+	FaultWriteError
+
+	// FaultReadError causes the command to be submitted to the TPM, but the error
+	// specified by Fault.Err to be returned from FaultInjectionTCTI.Read instead of
+	// the response, which is discarded.
+	FaultReadError
+
+	// FaultLatency causes the command to be submitted to the TPM after the delay
+	// specified by Fault.Delay.
+	FaultLatency
+)
+
+// Fault describes a fault to be injected by FaultInjectionTCTI.
+type Fault struct {
+	Type FaultType
+
+	// CommandCode is the command code of the commands that this fault is matched
+	// against. If zero, this fault is matched against all commands.
+	CommandCode tpm2.CommandCode
+
+	// Skip is the number of matching commands to skip before this fault is
+	// triggered, so that a value of N causes the fault to be triggered for the
+	// N+1th matching command.
+	Skip int
+
+	// Times is the number of consecutive matching commands for which this fault
+	// is triggered. If zero, it is triggered once. If negative, it is triggered
+	// for all subsequent matching commands.
+	Times int
+
+	ResponseCode tpm2.ResponseCode // Used by FaultResponseCode
+	Length       int               // Used by FaultTruncateResponse
+	Offset       int               // Used by FaultCorruptResponse
+	Session      int               // Used by FaultFlipResponseHMAC
+
+	// Mask is used by FaultCorruptResponse and FaultFlipResponseHMAC. If zero,
+	// all bits are flipped.
+	Mask byte
+
+	Err   error         // Used by FaultWriteError and FaultReadError
+	Delay time.Duration // Used by FaultLatency
+
+	matched   int
+	triggered int
+}
+
+// Triggered returns the number of times that this fault has been triggered.
+func (f *Fault) Triggered() int {
+	return f.triggered
+}
+
+func (f *Fault) mask() byte {
+	if f.Mask == 0 {
+		return 0xff
+	}
+	return f.Mask
+}
+
+// match determines whether this fault should be triggered for the specified command,
+// updating its counters.
+func (f *Fault) match(commandCode tpm2.CommandCode) bool {
+	if f.CommandCode != 0 && f.CommandCode != commandCode {
+		return false
+	}
+
+	f.matched++
+	if f.matched <= f.Skip {
+		return false
+	}
+
+	times := f.Times
+	if times == 0 {
+		times = 1
+	}
+	if times > 0 && f.triggered >= times {
+		return false
+	}
+
+	f.triggered++
+	return true
+}
+
+// FaultInjectionTCTI is a tpm2.TCTI implementation that wraps another TCTI and
+// injects faults in to commands and responses, in order to test error handling
+// paths that can't be exercised with a well behaved TPM.
+type FaultInjectionTCTI struct {
+	tcti   tpm2.TCTI
+	faults []*Fault
+
+	rsp     io.Reader
+	readErr error
+}
+
+// NewFaultInjectionTCTI returns a new FaultInjectionTCTI that wraps the supplied TCTI
+// and injects the supplied faults. Where more than one fault is triggered for a
+// command, they are applied in the order in which they were added. A triggered
+// FaultResponseCode or FaultWriteError fault takes precedence over other faults, and
+// as the command isn't submitted to the TPM, it isn't counted towards the Skip and
+// Times values of the other faults.
+func NewFaultInjectionTCTI(tcti tpm2.TCTI, faults ...*Fault) *FaultInjectionTCTI {
+	return &FaultInjectionTCTI{tcti: tcti, faults: faults}
+}
+
+// AddFault adds a new fault to inject.
+func (t *FaultInjectionTCTI) AddFault(fault *Fault) {
+	t.faults = append(t.faults, fault)
+}
+
+// ClearFaults removes all faults.
+func (t *FaultInjectionTCTI) ClearFaults() {
+	t.faults = nil
+}
+
+func (t *FaultInjectionTCTI) Read(data []byte) (int, error) {
+	if t.readErr != nil {
+		err := t.readErr
+		t.readErr = nil
+		return 0, err
+	}
+	if t.rsp == nil {
+		return t.tcti.Read(data)
+	}
+
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *FaultInjectionTCTI) Write(data []byte) (int, error) {
+	commandCode, err := tpm2.CommandPacket(data).GetCommandCode()
+	if err != nil {
+		return 0, xerrors.Errorf("cannot determine command code: %w", err)
+	}
+
+	t.rsp = nil
+	t.readErr = nil
+
+	// Faults that prevent the command from being submitted are resolved first, so that
+	// other faults are only counted against commands that are submitted to the TPM.
+	for _, f := range t.faults {
+		switch f.Type {
+		case FaultResponseCode, FaultWriteError:
+		default:
+			continue
+		}
+		if !f.match(commandCode) {
+			continue
+		}
+		if f.Type == FaultWriteError {
+			return 0, f.Err
+		}
+		rsp := mu.MustMarshalToBytes(tpm2.ResponseHeader{Tag: tpm2.TagNoSessions, ResponseSize: 10, ResponseCode: f.ResponseCode})
+		t.rsp = bytes.NewReader(rsp)
+		return len(data), nil
+	}
+
+	var faults []*Fault
+	for _, f := range t.faults {
+		switch f.Type {
+		case FaultResponseCode, FaultWriteError:
+			continue
+		}
+		if f.match(commandCode) {
+			faults = append(faults, f)
+		}
+	}
+
+	modifiesResponse := false
+	for _, f := range faults {
+		switch f.Type {
+		case FaultLatency:
+			time.Sleep(f.Delay)
+		case FaultTruncateResponse, FaultCorruptResponse, FaultFlipResponseHMAC, FaultReadError:
+			modifiesResponse = true
+		default:
+			return 0, fmt.Errorf("invalid fault type %d", f.Type)
+		}
+	}
+
+	n, err := t.tcti.Write(data)
+	if err != nil || !modifiesResponse {
+		return n, err
+	}
+
+	rsp, err := ioutil.ReadAll(t.tcti)
+	if err != nil {
+		return 0, xerrors.Errorf("cannot read response: %w", err)
+	}
+
+	for _, f := range faults {
+		switch f.Type {
+		case FaultTruncateResponse:
+			if f.Length < len(rsp) {
+				rsp = rsp[:f.Length]
+			}
+		case FaultCorruptResponse:
+			if f.Offset >= len(rsp) {
+				return 0, fmt.Errorf("cannot corrupt response: offset %d is out of range", f.Offset)
+			}
+			rsp[f.Offset] ^= f.mask()
+		case FaultFlipResponseHMAC:
+			offset, err := findResponseHMAC(commandCode, rsp, f.Session)
+			if err != nil {
+				return 0, xerrors.Errorf("cannot flip bits in response HMAC: %w", err)
+			}
+			rsp[offset] ^= f.mask()
+		case FaultReadError:
+			// The response is discarded.
+			t.readErr = f.Err
+			return n, nil
+		}
+	}
+
+	t.rsp = bytes.NewReader(rsp)
+	return n, nil
+}
+
+// findResponseHMAC returns the offset of the first byte of the HMAC for the session at the
+// specified index in the supplied response packet.
+func findResponseHMAC(commandCode tpm2.CommandCode, rsp tpm2.ResponsePacket, session int) (int, error) {
+	var authArea []tpm2.AuthResponse
+	var err error
+
+	var handle tpm2.Handle
+	if info, ok := commandInfoMap[commandCode]; ok {
+		var pHandle *tpm2.Handle
+		if info.rspHandle {
+			pHandle = &handle
+		}
+		_, _, authArea, err = rsp.Unmarshal(pHandle)
+	} else {
+		// The presence of a response handle isn't known, so try without one first.
+		_, _, authArea, err = rsp.Unmarshal(nil)
+		if err != nil {
+			_, _, authArea, err = rsp.Unmarshal(&handle)
+		}
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("cannot unmarshal response: %w", err)
+	}
+
+	if session >= len(authArea) {
+		return 0, fmt.Errorf("no session at index %d", session)
+	}
+	if len(authArea[session].HMAC) == 0 {
+		return 0, errors.New("empty HMAC")
+	}
+
+	// The auth area is at the end of the response.
+	offset := len(rsp)
+	for i := len(authArea) - 1; i > session; i-- {
+		offset -= len(mu.MustMarshalToBytes(authArea[i]))
+	}
+	return offset - len(authArea[session].HMAC), nil
+}
+
+func (t *FaultInjectionTCTI) Close() error {
+	return t.tcti.Close()
+}
+
+func (t *FaultInjectionTCTI) SetLocality(locality uint8) error {
+	return t.tcti.SetLocality(locality)
+}
+
+func (t *FaultInjectionTCTI) MakeSticky(handle tpm2.Handle, sticky bool) error {
+	return t.tcti.MakeSticky(handle, sticky)
+}
+
+// Cancel requests that the TPM cancels the currently executing command if the
+// underlying interface supports this.
+func (t *FaultInjectionTCTI) Cancel() error {
+	tcti, ok := t.tcti.(tpm2.CancellableTCTI)
+	if !ok {
+		return errors.New("not supported")
+	}
+	return tcti.Cancel()
+}
+
+// Unwrap returns the real interface that this one wraps.
+func (t *FaultInjectionTCTI) Unwrap() tpm2.TCTI {
+	return t.tcti
+}
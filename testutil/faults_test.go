@@ -0,0 +1,229 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package testutil_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	. "github.com/canonical/go-tpm2/testutil"
+)
+
+// fixedTCTI responds to every command with the same response.
+type fixedTCTI struct {
+	response []byte
+	rsp      io.Reader
+}
+
+func (t *fixedTCTI) Read(data []byte) (int, error) {
+	if t.rsp == nil {
+		return 0, io.EOF
+	}
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *fixedTCTI) Write(data []byte) (int, error) {
+	t.rsp = bytes.NewReader(t.response)
+	return len(data), nil
+}
+
+func (t *fixedTCTI) Close() error { return nil }
+
+func (t *fixedTCTI) SetLocality(locality uint8) error { return nil }
+
+func (t *fixedTCTI) MakeSticky(handle tpm2.Handle, sticky bool) error {
+	return errors.New("not implemented")
+}
+
+type faultsSuite struct {
+	echo *echoTCTI
+	tcti *FaultInjectionTCTI
+	tpm  *tpm2.TPMContext
+}
+
+var _ = Suite(&faultsSuite{})
+
+func (s *faultsSuite) SetUpTest(c *C) {
+	s.echo = new(echoTCTI)
+	s.tcti = NewFaultInjectionTCTI(s.echo)
+	s.tpm, _ = tpm2.NewTPMContext(s.tcti)
+}
+
+func (s *faultsSuite) runCommand(commandCode tpm2.CommandCode) error {
+	var code tpm2.CommandCode
+	var locality uint8
+	if err := s.tpm.RunCommand(commandCode, nil, tpm2.Delimiter, tpm2.Delimiter, tpm2.Delimiter, &code, &locality); err != nil {
+		return err
+	}
+	if code != commandCode {
+		return errors.New("unexpected response")
+	}
+	return nil
+}
+
+func (s *faultsSuite) TestResponseCode(c *C) {
+	fault := &Fault{Type: FaultResponseCode, CommandCode: tpm2.CommandReadClock, Skip: 1, ResponseCode: tpm2.ResponseBadTag}
+	s.tcti.AddFault(fault)
+
+	c.Check(s.runCommand(tpm2.CommandReadClock), IsNil)
+	c.Check(s.runCommand(tpm2.CommandGetTestResult), IsNil)
+	c.Check(s.runCommand(tpm2.CommandReadClock), ErrorMatches, "TPM returned an error whilst executing command TPM_CC_ReadClock: TPM_RC_BAD_TAG")
+	c.Check(s.runCommand(tpm2.CommandReadClock), IsNil)
+
+	c.Check(s.echo.writes, Equals, 3)
+	c.Check(fault.Triggered(), Equals, 1)
+}
+
+func (s *faultsSuite) TestRetry(c *C) {
+	retry := (&tpm2.TPMWarning{Code: tpm2.WarningRetry}).ResponseCode()
+	s.tcti.AddFault(&Fault{Type: FaultResponseCode, CommandCode: tpm2.CommandReadClock, Times: 2, ResponseCode: retry})
+
+	c.Check(s.runCommand(tpm2.CommandReadClock), IsNil)
+	c.Check(s.echo.writes, Equals, 1)
+}
+
+func (s *faultsSuite) TestRetryWithCorruptResponse(c *C) {
+	retry := (&tpm2.TPMWarning{Code: tpm2.WarningRetry}).ResponseCode()
+	rcFault := &Fault{Type: FaultResponseCode, CommandCode: tpm2.CommandReadClock, Times: 2, ResponseCode: retry}
+	corruptFault := &Fault{Type: FaultCorruptResponse, CommandCode: tpm2.CommandReadClock, Offset: 13, Mask: 0x01}
+	s.tcti.AddFault(rcFault)
+	s.tcti.AddFault(corruptFault)
+
+	// The corrupt fault should be applied to the first response from the TPM, after the
+	// retries have been consumed.
+	var code tpm2.CommandCode
+	var locality uint8
+	c.Check(s.tpm.RunCommand(tpm2.CommandReadClock, nil, tpm2.Delimiter, tpm2.Delimiter, tpm2.Delimiter, &code, &locality), IsNil)
+	c.Check(code, Equals, tpm2.CommandReadClock^0x01)
+
+	c.Check(s.echo.writes, Equals, 1)
+	c.Check(rcFault.Triggered(), Equals, 2)
+	c.Check(corruptFault.Triggered(), Equals, 1)
+}
+
+func (s *faultsSuite) TestResponseCodeWithLatencySkip(c *C) {
+	rcFault := &Fault{Type: FaultResponseCode, CommandCode: tpm2.CommandReadClock, ResponseCode: tpm2.ResponseBadTag}
+	latencyFault := &Fault{Type: FaultLatency, CommandCode: tpm2.CommandReadClock, Skip: 1, Delay: 50 * time.Millisecond}
+	s.tcti.AddFault(rcFault)
+	s.tcti.AddFault(latencyFault)
+
+	c.Check(s.runCommand(tpm2.CommandReadClock), ErrorMatches, "TPM returned an error whilst executing command TPM_CC_ReadClock: TPM_RC_BAD_TAG")
+	c.Check(latencyFault.Triggered(), Equals, 0)
+
+	// The command rejected by the response code fault shouldn't be counted towards
+	// the latency fault's Skip value.
+	start := time.Now()
+	c.Check(s.runCommand(tpm2.CommandReadClock), IsNil)
+	c.Check(time.Since(start) < 50*time.Millisecond, IsTrue)
+	c.Check(latencyFault.Triggered(), Equals, 0)
+
+	start = time.Now()
+	c.Check(s.runCommand(tpm2.CommandReadClock), IsNil)
+	c.Check(time.Since(start) >= 50*time.Millisecond, IsTrue)
+	c.Check(latencyFault.Triggered(), Equals, 1)
+
+	c.Check(s.echo.writes, Equals, 2)
+	c.Check(rcFault.Triggered(), Equals, 1)
+}
+
+func (s *faultsSuite) TestYieldedTooManyTimes(c *C) {
+	yielded := (&tpm2.TPMWarning{Code: tpm2.WarningYielded}).ResponseCode()
+	fault := &Fault{Type: FaultResponseCode, CommandCode: tpm2.CommandReadClock, Times: -1, ResponseCode: yielded}
+	s.tcti.AddFault(fault)
+	s.tpm.SetMaxSubmissions(3)
+
+	err := s.runCommand(tpm2.CommandReadClock)
+	c.Check(tpm2.IsTPMWarning(err, tpm2.WarningYielded, tpm2.CommandReadClock), IsTrue)
+	c.Check(fault.Triggered(), Equals, 3)
+	c.Check(s.echo.writes, Equals, 0)
+
+	s.tcti.ClearFaults()
+	c.Check(s.runCommand(tpm2.CommandReadClock), IsNil)
+}
+
+func (s *faultsSuite) TestTruncateResponse(c *C) {
+	s.tcti.AddFault(&Fault{Type: FaultTruncateResponse, CommandCode: tpm2.CommandReadClock, Length: 12})
+
+	err := s.runCommand(tpm2.CommandReadClock)
+	c.Check(err, FitsTypeOf, &tpm2.InvalidResponseError{})
+	c.Check(err, ErrorMatches, "TPM returned an invalid response for command TPM_CC_ReadClock: cannot unmarshal response packet: invalid responseSize value \\(got 15, packet length 12\\)")
+}
+
+func (s *faultsSuite) TestCorruptResponse(c *C) {
+	s.tcti.AddFault(&Fault{Type: FaultCorruptResponse, Offset: 13, Mask: 0x01})
+
+	var code tpm2.CommandCode
+	var locality uint8
+	c.Check(s.tpm.RunCommand(tpm2.CommandReadClock, nil, tpm2.Delimiter, tpm2.Delimiter, tpm2.Delimiter, &code, &locality), IsNil)
+	c.Check(code, Equals, tpm2.CommandReadClock^0x01)
+}
+
+func (s *faultsSuite) TestWriteError(c *C) {
+	s.tcti.AddFault(&Fault{Type: FaultWriteError, CommandCode: tpm2.CommandReadClock, Err: errors.New("write error")})
+
+	err := s.runCommand(tpm2.CommandReadClock)
+	c.Check(err, ErrorMatches, "cannot complete write operation on TCTI: write error")
+	c.Check(s.echo.writes, Equals, 0)
+}
+
+func (s *faultsSuite) TestReadError(c *C) {
+	s.tcti.AddFault(&Fault{Type: FaultReadError, CommandCode: tpm2.CommandReadClock, Err: errors.New("read error")})
+
+	err := s.runCommand(tpm2.CommandReadClock)
+	c.Check(err, ErrorMatches, "cannot complete read operation on TCTI: read error")
+	c.Check(s.echo.writes, Equals, 1)
+
+	// The response should have been consumed.
+	c.Check(s.runCommand(tpm2.CommandGetTestResult), IsNil)
+}
+
+func (s *faultsSuite) TestLatency(c *C) {
+	s.tcti.AddFault(&Fault{Type: FaultLatency, CommandCode: tpm2.CommandReadClock, Delay: 50 * time.Millisecond})
+
+	start := time.Now()
+	c.Check(s.runCommand(tpm2.CommandReadClock), IsNil)
+	c.Check(time.Since(start) >= 50*time.Millisecond, IsTrue)
+}
+
+func (s *faultsSuite) TestFlipResponseHMAC(c *C) {
+	params := mu.MustMarshalToBytes(uint32(2), tpm2.Digest{})
+	authArea := mu.MustMarshalToBytes(
+		tpm2.AuthResponse{Nonce: make(tpm2.Nonce, 16), SessionAttributes: tpm2.AttrContinueSession, HMAC: make(tpm2.Auth, 32)},
+		tpm2.AuthResponse{Nonce: make(tpm2.Nonce, 16), SessionAttributes: tpm2.AttrContinueSession, HMAC: make(tpm2.Auth, 20)})
+	hdr := tpm2.ResponseHeader{Tag: tpm2.TagSessions, ResponseSize: uint32(10 + len(params) + len(authArea))}
+	rsp := mu.MustMarshalToBytes(hdr, mu.RawBytes(params), mu.RawBytes(authArea))
+
+	tcti := NewFaultInjectionTCTI(&fixedTCTI{response: rsp}, &Fault{Type: FaultFlipResponseHMAC, CommandCode: tpm2.CommandUnseal, Session: 1, Mask: 0x80})
+
+	_, err := tcti.Write(tpm2.MarshalCommandPacket(tpm2.CommandUnseal, nil, nil, nil))
+	c.Assert(err, IsNil)
+
+	var b bytes.Buffer
+	_, err = b.ReadFrom(tcti)
+	c.Check(err, IsNil)
+
+	_, _, auths, err := tpm2.ResponsePacket(b.Bytes()).Unmarshal(nil)
+	c.Assert(err, IsNil)
+	c.Assert(auths, HasLen, 2)
+	c.Check(auths[0].HMAC, DeepEquals, make(tpm2.Auth, 32))
+	c.Check(auths[1].HMAC, DeepEquals, append(tpm2.Auth{0x80}, make(tpm2.Auth, 19)...))
+}
+
+func (s *faultsSuite) TestFlipResponseHMACNoSession(c *C) {
+	s.tcti.AddFault(&Fault{Type: FaultFlipResponseHMAC, CommandCode: tpm2.CommandReadClock})
+
+	err := s.runCommand(tpm2.CommandReadClock)
+	c.Check(err, ErrorMatches, "cannot complete write operation on TCTI: cannot flip bits in response HMAC: no session at index 0")
+}
@@ -0,0 +1,520 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package testutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+
+	"golang.org/x/xerrors"
+)
+
+// The recording format consists of a header followed by a sequence of records,
+// all in the TPM wire format:
+//
+//	header:
+//	  magic   uint32 (0x54524543, "TREC")
+//	  version uint32
+//	record:
+//	  type uint8 (0 for a command, 1 for random bytes)
+//	  data
+//	command record data:
+//	  locality uint8
+//	  cmdSize  uint32
+//	  command  [cmdSize]byte
+//	  rspSize  uint32
+//	  response [rspSize]byte
+//	random bytes record data:
+//	  size  uint32
+//	  bytes [size]byte
+//
+// Version 1 recordings contain only command records, without the type field.
+const (
+	recordingVersion    uint32 = 2
+	maxRecordPacketSize uint32 = 0x10000
+	recordingMagic      uint32 = 0x54524543
+
+	recordTypeCommand uint8 = 0
+	recordTypeRandom  uint8 = 1
+)
+
+type recordingHeader struct {
+	Magic   uint32
+	Version uint32
+}
+
+type record struct {
+	random   []byte // The random bytes for a random bytes record, or nil for a command record
+	locality uint8
+	command  tpm2.CommandPacket
+	response tpm2.ResponsePacket
+}
+
+// RecordingTCTI is a tpm2.TCTI implementation that wraps another TCTI and writes every
+// command and response pair to an io.Writer, so that a session against a real TPM can be
+// replayed later on with ReplayTCTI.
+//
+// In order to record commands that use HMAC or policy sessions, the source returned from
+// RandomSource must be supplied to tpm2.TPMContext.SetRandomSource so that the caller
+// nonces and salts are recorded as well.
+type RecordingTCTI struct {
+	tcti     tpm2.TCTI
+	w        io.Writer
+	locality uint8
+
+	cmd tpm2.CommandPacket
+	rsp *bytes.Buffer
+}
+
+// NewRecordingTCTI returns a new RecordingTCTI that wraps the supplied TCTI, and writes the
+// recording header to w. The caller is responsible for closing w once the recording is
+// complete.
+func NewRecordingTCTI(tcti tpm2.TCTI, w io.Writer) (*RecordingTCTI, error) {
+	if _, err := mu.MarshalToWriter(w, recordingHeader{Magic: recordingMagic, Version: recordingVersion}); err != nil {
+		return nil, xerrors.Errorf("cannot write header: %w", err)
+	}
+	return &RecordingTCTI{tcti: tcti, w: w}, nil
+}
+
+func (t *RecordingTCTI) Read(data []byte) (int, error) {
+	n, err := t.tcti.Read(data)
+	if t.rsp != nil {
+		t.rsp.Write(data[:n])
+	}
+	if err == io.EOF && t.cmd != nil {
+		if _, e := mu.MarshalToWriter(t.w, recordTypeCommand, t.locality, uint32(len(t.cmd)), mu.RawBytes(t.cmd), uint32(t.rsp.Len()), mu.RawBytes(t.rsp.Bytes())); e != nil {
+			return n, xerrors.Errorf("cannot write record: %w", e)
+		}
+		t.cmd = nil
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *RecordingTCTI) Write(data []byte) (int, error) {
+	n, err := t.tcti.Write(data)
+	if err != nil {
+		return n, err
+	}
+	t.cmd = append(tpm2.CommandPacket(nil), data...)
+	t.rsp = new(bytes.Buffer)
+	return n, nil
+}
+
+// Close closes the underlying TCTI. It doesn't close the writer supplied to
+// NewRecordingTCTI.
+func (t *RecordingTCTI) Close() error {
+	return t.tcti.Close()
+}
+
+func (t *RecordingTCTI) SetLocality(locality uint8) error {
+	if err := t.tcti.SetLocality(locality); err != nil {
+		return err
+	}
+	t.locality = locality
+	return nil
+}
+
+func (t *RecordingTCTI) MakeSticky(handle tpm2.Handle, sticky bool) error {
+	return t.tcti.MakeSticky(handle, sticky)
+}
+
+// Unwrap returns the real interface that this one wraps.
+func (t *RecordingTCTI) Unwrap() tpm2.TCTI {
+	return t.tcti
+}
+
+// RandomSource returns a source of random bytes, obtained from crypto/rand.Reader, that
+// writes every read to the recording. It should be supplied to
+// tpm2.TPMContext.SetRandomSource.
+func (t *RecordingTCTI) RandomSource() io.Reader {
+	return &recordingRandomSource{t: t}
+}
+
+type recordingRandomSource struct {
+	t *RecordingTCTI
+}
+
+func (r *recordingRandomSource) Read(data []byte) (int, error) {
+	n, err := rand.Read(data)
+	if err != nil {
+		return n, err
+	}
+	if _, err := mu.MarshalToWriter(r.t.w, recordTypeRandom, uint32(n), mu.RawBytes(data[:n])); err != nil {
+		return 0, xerrors.Errorf("cannot write record: %w", err)
+	}
+	return n, nil
+}
+
+// ReplayOptions provides options to NewReplayTCTI.
+type ReplayOptions struct {
+	// IgnoreNonces indicates that the caller nonces in the authorization area of
+	// commands should not be compared with the recording. Their sizes are still
+	// compared.
+	IgnoreNonces bool
+
+	// IgnoreHMACs indicates that the HMACs in the authorization area of commands
+	// should not be compared with the recording.
+	IgnoreHMACs bool
+
+	// IgnoreEncryptedParameters indicates that the contents of the first command
+	// parameter should not be compared with the recording where a session is used for
+	// command parameter encryption. Its size is still compared.
+	IgnoreEncryptedParameters bool
+}
+
+func (o *ReplayOptions) ignoresFields() bool {
+	return o.IgnoreNonces || o.IgnoreHMACs || o.IgnoreEncryptedParameters
+}
+
+// ReplayMismatchError is returned from ReplayTCTI.Write when a command does not match the
+// recording.
+type ReplayMismatchError struct {
+	Index       int              // The index of the record in the recording
+	CommandCode tpm2.CommandCode // The command code of the recorded command
+	Expected    tpm2.CommandPacket
+	Actual      tpm2.CommandPacket
+	msg         string
+}
+
+func (e *ReplayMismatchError) Error() string {
+	return fmt.Sprintf("command %d (%v) does not match the recording: %s", e.Index, e.CommandCode, e.msg)
+}
+
+// ReplayTCTI is a tpm2.TCTI implementation that serves responses from a recording created
+// by RecordingTCTI. Every command must match the corresponding command in the recording,
+// else an error is returned and the ReplayTCTI can no longer be used.
+//
+// Responses are replayed verbatim. This means that response HMACs will only verify if the
+// session keys and nonces are the same as those used when the recording was made. In order
+// to replay commands that use HMAC or policy sessions with a tpm2.TPMContext, the recording
+// must have been made with the random source returned from RecordingTCTI.RandomSource, and
+// the source returned from RandomSource must be supplied to tpm2.TPMContext.SetRandomSource
+// so that the recorded caller nonces and salts are reused. The recorded commands then match
+// exactly, and ReplayOptions isn't required.
+type ReplayTCTI struct {
+	opts     ReplayOptions
+	records  []*record
+	next     int
+	locality uint8
+
+	randomOffset int // The number of bytes already read from the current random bytes record
+
+	rsp *bytes.Reader
+	err error
+}
+
+// NewReplayTCTI returns a new ReplayTCTI which serves responses from the recording read
+// from r.
+func NewReplayTCTI(r io.Reader, opts *ReplayOptions) (*ReplayTCTI, error) {
+	if opts == nil {
+		opts = new(ReplayOptions)
+	}
+
+	var hdr recordingHeader
+	if _, err := mu.UnmarshalFromReader(r, &hdr); err != nil {
+		return nil, xerrors.Errorf("cannot read header: %w", err)
+	}
+	if hdr.Magic != recordingMagic {
+		return nil, errors.New("invalid magic")
+	}
+	if hdr.Version < 1 || hdr.Version > recordingVersion {
+		return nil, fmt.Errorf("unsupported version %d", hdr.Version)
+	}
+
+	t := &ReplayTCTI{opts: *opts}
+
+	readPacket := func() ([]byte, error) {
+		var size uint32
+		if _, err := mu.UnmarshalFromReader(r, &size); err != nil {
+			return nil, err
+		}
+		if size > maxRecordPacketSize {
+			return nil, fmt.Errorf("packet too large (%d bytes)", size)
+		}
+		packet := make([]byte, size)
+		if _, err := io.ReadFull(r, packet); err != nil {
+			return nil, err
+		}
+		return packet, nil
+	}
+
+	for {
+		recordType := recordTypeCommand
+		if hdr.Version > 1 {
+			var b [1]byte
+			_, err := io.ReadFull(r, b[:])
+			switch {
+			case err == io.EOF:
+				return t, nil
+			case err != nil:
+				return nil, xerrors.Errorf("cannot read record %d: %w", len(t.records), err)
+			}
+			recordType = b[0]
+		}
+
+		switch recordType {
+		case recordTypeCommand:
+		case recordTypeRandom:
+			random, err := readPacket()
+			if err != nil {
+				return nil, xerrors.Errorf("cannot read random bytes for record %d: %w", len(t.records), err)
+			}
+			t.records = append(t.records, &record{random: random})
+			continue
+		default:
+			return nil, fmt.Errorf("invalid type for record %d (%d)", len(t.records), recordType)
+		}
+
+		var locality [1]byte
+		_, err := io.ReadFull(r, locality[:])
+		switch {
+		case err == io.EOF && hdr.Version == 1:
+			return t, nil
+		case err != nil:
+			return nil, xerrors.Errorf("cannot read record %d: %w", len(t.records), err)
+		}
+
+		cmd, err := readPacket()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read command for record %d: %w", len(t.records), err)
+		}
+		rsp, err := readPacket()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read response for record %d: %w", len(t.records), err)
+		}
+
+		t.records = append(t.records, &record{locality: locality[0], command: cmd, response: rsp})
+	}
+}
+
+func (t *ReplayTCTI) Read(data []byte) (int, error) {
+	if t.rsp == nil {
+		return 0, io.EOF
+	}
+
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *ReplayTCTI) Write(data []byte) (int, error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+	if t.rsp != nil {
+		return 0, errors.New("previous response has not been read")
+	}
+	if t.next >= len(t.records) {
+		t.err = fmt.Errorf("command %d is beyond the end of the recording", t.next)
+		return 0, t.err
+	}
+
+	record := t.records[t.next]
+	if record.random != nil {
+		t.err = fmt.Errorf("record %d contains random bytes that haven't been read", t.next)
+		return 0, t.err
+	}
+
+	cmd := tpm2.CommandPacket(data)
+
+	if msg := t.compare(record, cmd); msg != "" {
+		commandCode, _ := record.command.GetCommandCode()
+		t.err = &ReplayMismatchError{
+			Index:       t.next,
+			CommandCode: commandCode,
+			Expected:    record.command,
+			Actual:      append(tpm2.CommandPacket(nil), cmd...),
+			msg:         msg}
+		return 0, t.err
+	}
+
+	t.next++
+	t.rsp = bytes.NewReader(record.response)
+	return len(data), nil
+}
+
+// compare compares the supplied command with the recorded one, and returns a description of
+// the first difference, or an empty string if they match.
+func (t *ReplayTCTI) compare(record *record, cmd tpm2.CommandPacket) string {
+	if record.locality != t.locality {
+		return fmt.Sprintf("locality mismatch (expected %d, got %d)", record.locality, t.locality)
+	}
+	if bytes.Equal(record.command, cmd) {
+		return ""
+	}
+
+	expectedCode, err := record.command.GetCommandCode()
+	if err != nil {
+		return fmt.Sprintf("invalid recorded command: %v", err)
+	}
+	actualCode, err := cmd.GetCommandCode()
+	if err != nil {
+		return fmt.Sprintf("invalid command: %v", err)
+	}
+	if expectedCode != actualCode {
+		return fmt.Sprintf("command code mismatch (got %v)", actualCode)
+	}
+
+	if !t.opts.ignoresFields() {
+		return "command packet mismatch"
+	}
+
+	expectedHandles, expectedAuthArea, expectedParams, err := unmarshalRecordedCommand(record.command)
+	if err != nil {
+		return fmt.Sprintf("invalid recorded command: %v", err)
+	}
+	actualHandles, actualAuthArea, actualParams, err := unmarshalRecordedCommand(cmd)
+	if err != nil {
+		return fmt.Sprintf("invalid command: %v", err)
+	}
+
+	if !reflect.DeepEqual(expectedHandles, actualHandles) {
+		return "handle mismatch"
+	}
+
+	if len(expectedAuthArea) != len(actualAuthArea) {
+		return fmt.Sprintf("number of sessions mismatch (expected %d, got %d)", len(expectedAuthArea), len(actualAuthArea))
+	}
+	for i := range expectedAuthArea {
+		expected := expectedAuthArea[i]
+		actual := actualAuthArea[i]
+		switch {
+		case expected.SessionHandle != actual.SessionHandle:
+			return fmt.Sprintf("session %d handle mismatch", i)
+		case expected.SessionAttributes != actual.SessionAttributes:
+			return fmt.Sprintf("session %d attributes mismatch", i)
+		case len(expected.Nonce) != len(actual.Nonce):
+			return fmt.Sprintf("session %d nonce size mismatch", i)
+		case !t.opts.IgnoreNonces && !bytes.Equal(expected.Nonce, actual.Nonce):
+			return fmt.Sprintf("session %d nonce mismatch", i)
+		case !t.opts.IgnoreHMACs && !bytes.Equal(expected.HMAC, actual.HMAC):
+			return fmt.Sprintf("session %d HMAC mismatch", i)
+		}
+	}
+
+	if t.opts.IgnoreEncryptedParameters && hasDecryptSession(actualAuthArea) {
+		// Only the first parameter is encrypted, and it must be a sized buffer.
+		var ok bool
+		expectedParams, actualParams, ok = skipSizedParameter(expectedParams, actualParams)
+		if !ok {
+			return "encrypted parameter size mismatch"
+		}
+	}
+	if !bytes.Equal(expectedParams, actualParams) {
+		return "parameter mismatch"
+	}
+
+	return ""
+}
+
+// skipSizedParameter skips the sized buffer at the start of the supplied expected and actual
+// parameters, returning false if they have different sizes.
+func skipSizedParameter(expected, actual []byte) (expectedRest, actualRest []byte, ok bool) {
+	if len(expected) < 2 || len(actual) < 2 || !bytes.Equal(expected[:2], actual[:2]) {
+		return nil, nil, false
+	}
+	var size uint16
+	if _, err := mu.UnmarshalFromBytes(expected, &size); err != nil || int(size)+2 > len(expected) || int(size)+2 > len(actual) {
+		return nil, nil, false
+	}
+	return expected[size+2:], actual[size+2:], true
+}
+
+// unmarshalRecordedCommand unmarshals the supplied command packet. If the number of
+// command handles isn't known for the command, the first count for which the packet
+// decodes with a valid authorization area is used.
+func unmarshalRecordedCommand(cmd tpm2.CommandPacket) (handles tpm2.HandleList, authArea []tpm2.AuthCommand, parameters []byte, err error) {
+	commandCode, err := cmd.GetCommandCode()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if info, ok := commandInfoMap[commandCode]; ok {
+		return cmd.Unmarshal(info.cmdHandles)
+	}
+
+	for n := 0; n <= 3; n++ {
+		handles, authArea, parameters, err = cmd.Unmarshal(n)
+		if err != nil {
+			continue
+		}
+		valid := true
+		for _, auth := range authArea {
+			switch auth.SessionHandle.Type() {
+			case tpm2.HandleTypeHMACSession, tpm2.HandleTypePolicySession:
+			default:
+				if auth.SessionHandle != tpm2.HandlePW {
+					valid = false
+				}
+			}
+		}
+		if valid {
+			return handles, authArea, parameters, nil
+		}
+	}
+	return nil, nil, nil, errors.New("cannot determine the number of command handles")
+}
+
+func (t *ReplayTCTI) Close() error {
+	return nil
+}
+
+// SetLocality sets the locality of subsequent commands, which must match the locality
+// of the corresponding commands in the recording.
+func (t *ReplayTCTI) SetLocality(locality uint8) error {
+	t.locality = locality
+	return nil
+}
+
+func (t *ReplayTCTI) MakeSticky(handle tpm2.Handle, sticky bool) error {
+	return errors.New("not implemented")
+}
+
+// Remaining returns the number of records that haven't been replayed yet, including
+// records of random bytes.
+func (t *ReplayTCTI) Remaining() int {
+	return len(t.records) - t.next
+}
+
+// RandomSource returns a source of random bytes that serves the random bytes recorded
+// with the source returned from RecordingTCTI.RandomSource. It should be supplied to
+// tpm2.TPMContext.SetRandomSource. Reading from it when the next record in the recording
+// is not a random bytes record returns an error, and the ReplayTCTI can no longer be used.
+func (t *ReplayTCTI) RandomSource() io.Reader {
+	return &replayRandomSource{t: t}
+}
+
+type replayRandomSource struct {
+	t *ReplayTCTI
+}
+
+func (r *replayRandomSource) Read(data []byte) (int, error) {
+	t := r.t
+	if t.err != nil {
+		return 0, t.err
+	}
+	if t.next >= len(t.records) || t.records[t.next].random == nil {
+		t.err = fmt.Errorf("record %d does not contain random bytes", t.next)
+		return 0, t.err
+	}
+
+	random := t.records[t.next].random
+	n := copy(data, random[t.randomOffset:])
+	t.randomOffset += n
+	if t.randomOffset == len(random) {
+		t.next++
+		t.randomOffset = 0
+	}
+	return n, nil
+}
@@ -0,0 +1,481 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package testutil_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/internal"
+	"github.com/canonical/go-tpm2/mu"
+	. "github.com/canonical/go-tpm2/testutil"
+)
+
+// echoTCTI responds to every command with a response containing the command code and
+// the locality it was submitted at.
+type echoTCTI struct {
+	locality uint8
+	writes   int
+	rsp      io.Reader
+}
+
+func (t *echoTCTI) Read(data []byte) (int, error) {
+	if t.rsp == nil {
+		return 0, io.EOF
+	}
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *echoTCTI) Write(data []byte) (int, error) {
+	commandCode, err := tpm2.CommandPacket(data).GetCommandCode()
+	if err != nil {
+		return 0, err
+	}
+	params := mu.MustMarshalToBytes(commandCode, t.locality)
+	rsp := mu.MustMarshalToBytes(tpm2.ResponseHeader{Tag: tpm2.TagNoSessions, ResponseSize: uint32(10 + len(params))}, mu.RawBytes(params))
+	t.rsp = bytes.NewReader(rsp)
+	t.writes++
+	return len(data), nil
+}
+
+func (t *echoTCTI) Close() error { return nil }
+
+func (t *echoTCTI) SetLocality(locality uint8) error {
+	t.locality = locality
+	return nil
+}
+
+func (t *echoTCTI) MakeSticky(handle tpm2.Handle, sticky bool) error {
+	return errors.New("not implemented")
+}
+
+// hmacSessionTCTI emulates a TPM that supports TPM2_StartAuthSession for unbound HMAC sessions
+// with SHA-256, optionally salted with the RSA key at handle 0x81000001, and TPM2_ClearControl.
+// The authorization value of the lockout hierarchy is assumed to be empty, so the HMAC key is
+// the session key. Command HMACs are checked, so commands are only accepted if the caller
+// computed the same session key.
+type hmacSessionTCTI struct {
+	key *rsa.PrivateKey
+
+	sessionKey []byte
+	nonceTPM   tpm2.Nonce
+	nonces     int
+
+	rsp io.Reader
+}
+
+func (t *hmacSessionTCTI) Read(data []byte) (int, error) {
+	if t.rsp == nil {
+		return 0, io.EOF
+	}
+	n, err := t.rsp.Read(data)
+	if err == io.EOF {
+		t.rsp = nil
+	}
+	return n, err
+}
+
+func (t *hmacSessionTCTI) newNonceTPM() tpm2.Nonce {
+	t.nonces++
+	t.nonceTPM = make(tpm2.Nonce, sha256.Size)
+	t.nonceTPM[0] = byte(t.nonces)
+	return t.nonceTPM
+}
+
+func (t *hmacSessionTCTI) startAuthSession(cmd tpm2.CommandPacket) ([]byte, error) {
+	handles, _, cpBytes, err := cmd.Unmarshal(2)
+	if err != nil {
+		return nil, err
+	}
+	var nonceCaller tpm2.Nonce
+	var encryptedSalt tpm2.EncryptedSecret
+	if _, err := mu.UnmarshalFromBytes(cpBytes, &nonceCaller, &encryptedSalt); err != nil {
+		return nil, err
+	}
+
+	nonceTPM := t.newNonceTPM()
+	t.sessionKey = nil
+	if handles[0] != tpm2.HandleNull {
+		salt, err := rsa.DecryptOAEP(sha256.New(), nil, t.key, encryptedSalt, []byte(tpm2.SecretKey+"\x00"))
+		if err != nil {
+			return nil, err
+		}
+		t.sessionKey = internal.KDFa(crypto.SHA256, salt, []byte(tpm2.SessionKey), nonceTPM, nonceCaller, sha256.Size*8)
+	}
+
+	params := mu.MustMarshalToBytes(tpm2.Handle(0x02000000), nonceTPM)
+	return mu.MustMarshalToBytes(tpm2.ResponseHeader{Tag: tpm2.TagNoSessions, ResponseSize: uint32(10 + len(params))}, mu.RawBytes(params)), nil
+}
+
+func (t *hmacSessionTCTI) clearControl(cmd tpm2.CommandPacket) ([]byte, error) {
+	handles, authArea, cpBytes, err := cmd.Unmarshal(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(authArea) != 1 {
+		return nil, errors.New("unexpected number of sessions")
+	}
+
+	cpHash := sha256.Sum256(mu.MustMarshalToBytes(tpm2.CommandClearControl, handles[0], mu.RawBytes(cpBytes)))
+	h := hmac.New(sha256.New, t.sessionKey)
+	h.Write(cpHash[:])
+	h.Write(authArea[0].Nonce)
+	h.Write(t.nonceTPM)
+	h.Write([]byte{uint8(authArea[0].SessionAttributes)})
+	if !hmac.Equal(h.Sum(nil), authArea[0].HMAC) {
+		return nil, errors.New("incorrect command HMAC")
+	}
+
+	rpHash := sha256.Sum256(mu.MustMarshalToBytes(tpm2.ResponseSuccess, tpm2.CommandClearControl))
+	auth := tpm2.AuthResponse{Nonce: t.newNonceTPM(), SessionAttributes: authArea[0].SessionAttributes}
+	h = hmac.New(sha256.New, t.sessionKey)
+	h.Write(rpHash[:])
+	h.Write(auth.Nonce)
+	h.Write(authArea[0].Nonce)
+	h.Write([]byte{uint8(auth.SessionAttributes)})
+	auth.HMAC = h.Sum(nil)
+
+	payload := mu.MustMarshalToBytes(uint32(0), auth)
+	return mu.MustMarshalToBytes(tpm2.ResponseHeader{Tag: tpm2.TagSessions, ResponseSize: uint32(10 + len(payload))}, mu.RawBytes(payload)), nil
+}
+
+func (t *hmacSessionTCTI) Write(data []byte) (int, error) {
+	cmd := tpm2.CommandPacket(data)
+	commandCode, err := cmd.GetCommandCode()
+	if err != nil {
+		return 0, err
+	}
+
+	var rsp []byte
+	switch commandCode {
+	case tpm2.CommandStartAuthSession:
+		rsp, err = t.startAuthSession(cmd)
+	case tpm2.CommandClearControl:
+		rsp, err = t.clearControl(cmd)
+	default:
+		err = errors.New("unexpected command")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	t.rsp = bytes.NewReader(rsp)
+	return len(data), nil
+}
+
+func (t *hmacSessionTCTI) Close() error { return nil }
+
+func (t *hmacSessionTCTI) SetLocality(locality uint8) error {
+	return errors.New("not implemented")
+}
+
+func (t *hmacSessionTCTI) MakeSticky(handle tpm2.Handle, sticky bool) error {
+	return errors.New("not implemented")
+}
+
+type replaySuite struct{}
+
+var _ = Suite(&replaySuite{})
+
+func (s *replaySuite) runCommand(c *C, tcti tpm2.TCTI, cmd tpm2.CommandPacket) (tpm2.ResponsePacket, error) {
+	if _, err := tcti.Write(cmd); err != nil {
+		return nil, err
+	}
+	var rsp bytes.Buffer
+	buf := make([]byte, 4)
+	for {
+		n, err := tcti.Read(buf)
+		rsp.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+	}
+	return rsp.Bytes(), nil
+}
+
+func (s *replaySuite) sessionCommand(nonce tpm2.Nonce, hmac tpm2.Auth, attrs tpm2.SessionAttributes, param tpm2.Digest) tpm2.CommandPacket {
+	authArea := []tpm2.AuthCommand{{SessionHandle: 0x02000000, Nonce: nonce, SessionAttributes: attrs, HMAC: hmac}}
+	return tpm2.MarshalCommandPacket(tpm2.CommandUnseal, tpm2.HandleList{0x80000001}, authArea, mu.MustMarshalToBytes(param))
+}
+
+func (s *replaySuite) record(c *C, cmds ...tpm2.CommandPacket) (recording []byte, responses []tpm2.ResponsePacket) {
+	var buf bytes.Buffer
+	tcti, err := NewRecordingTCTI(&echoTCTI{}, &buf)
+	c.Assert(err, IsNil)
+
+	for i, cmd := range cmds {
+		if i == len(cmds)-1 {
+			c.Check(tcti.SetLocality(3), IsNil)
+		}
+		rsp, err := s.runCommand(c, tcti, cmd)
+		c.Assert(err, IsNil)
+		responses = append(responses, rsp)
+	}
+	c.Check(tcti.Close(), IsNil)
+
+	return buf.Bytes(), responses
+}
+
+func (s *replaySuite) TestReplay(c *C) {
+	cmds := []tpm2.CommandPacket{
+		tpm2.MarshalCommandPacket(tpm2.CommandGetRandom, nil, nil, mu.MustMarshalToBytes(uint16(32))),
+		s.sessionCommand(make(tpm2.Nonce, 32), make(tpm2.Auth, 32), tpm2.AttrContinueSession, tpm2.Digest{1, 2, 3}),
+		tpm2.MarshalCommandPacket(tpm2.CommandReadClock, nil, nil, nil)}
+	recording, responses := s.record(c, cmds...)
+
+	tcti, err := NewReplayTCTI(bytes.NewReader(recording), nil)
+	c.Assert(err, IsNil)
+	c.Check(tcti.Remaining(), Equals, 3)
+
+	for i, cmd := range cmds {
+		if i == len(cmds)-1 {
+			c.Check(tcti.SetLocality(3), IsNil)
+		}
+		rsp, err := s.runCommand(c, tcti, cmd)
+		c.Check(err, IsNil)
+		c.Check(rsp, DeepEquals, responses[i])
+	}
+	c.Check(tcti.Remaining(), Equals, 0)
+
+	_, err = s.runCommand(c, tcti, cmds[0])
+	c.Check(err, ErrorMatches, "command 3 is beyond the end of the recording")
+}
+
+func (s *replaySuite) TestReplayWithTPMContext(c *C) {
+	var buf bytes.Buffer
+	recordTCTI, err := NewRecordingTCTI(&echoTCTI{}, &buf)
+	c.Assert(err, IsNil)
+	tpm, _ := tpm2.NewTPMContext(recordTCTI)
+
+	var recorded tpm2.CommandCode
+	var locality uint8
+	c.Check(tpm.RunCommand(tpm2.CommandGetRandom, nil, tpm2.Delimiter, uint16(8), tpm2.Delimiter, tpm2.Delimiter, &recorded, &locality), IsNil)
+	c.Check(tpm.Close(), IsNil)
+
+	replayTCTI, err := NewReplayTCTI(&buf, nil)
+	c.Assert(err, IsNil)
+	tpm, _ = tpm2.NewTPMContext(replayTCTI)
+
+	var replayed tpm2.CommandCode
+	c.Check(tpm.RunCommand(tpm2.CommandGetRandom, nil, tpm2.Delimiter, uint16(8), tpm2.Delimiter, tpm2.Delimiter, &replayed, &locality), IsNil)
+	c.Check(replayed, Equals, recorded)
+	c.Check(tpm.Close(), IsNil)
+}
+
+func (s *replaySuite) TestMismatch(c *C) {
+	cmds := []tpm2.CommandPacket{
+		tpm2.MarshalCommandPacket(tpm2.CommandGetRandom, nil, nil, mu.MustMarshalToBytes(uint16(32))),
+		tpm2.MarshalCommandPacket(tpm2.CommandGetRandom, nil, nil, mu.MustMarshalToBytes(uint16(16)))}
+	recording, _ := s.record(c, cmds...)
+
+	tcti, err := NewReplayTCTI(bytes.NewReader(recording), nil)
+	c.Assert(err, IsNil)
+
+	_, err = s.runCommand(c, tcti, cmds[0])
+	c.Check(err, IsNil)
+
+	cmd := tpm2.MarshalCommandPacket(tpm2.CommandGetRandom, nil, nil, mu.MustMarshalToBytes(uint16(8)))
+	_, err = s.runCommand(c, tcti, cmd)
+	c.Check(err, ErrorMatches, `command 1 \(TPM_CC_GetRandom\) does not match the recording: locality mismatch \(expected 3, got 0\)`)
+
+	c.Assert(err, FitsTypeOf, &ReplayMismatchError{})
+	e := err.(*ReplayMismatchError)
+	c.Check(e.Index, Equals, 1)
+	c.Check(e.CommandCode, Equals, tpm2.CommandGetRandom)
+	c.Check(e.Expected, DeepEquals, cmds[1])
+	c.Check(e.Actual, DeepEquals, cmd)
+
+	// Subsequent commands should fail.
+	c.Check(tcti.SetLocality(3), IsNil)
+	_, err = s.runCommand(c, tcti, cmds[1])
+	c.Check(err, Equals, e)
+}
+
+func (s *replaySuite) testMismatchWithOptions(c *C, opts *ReplayOptions, cmd tpm2.CommandPacket, expected string) {
+	recorded := s.sessionCommand(make(tpm2.Nonce, 32), make(tpm2.Auth, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{1, 2, 3})
+	recording, responses := s.record(c, recorded, tpm2.MarshalCommandPacket(tpm2.CommandReadClock, nil, nil, nil))
+
+	tcti, err := NewReplayTCTI(bytes.NewReader(recording), opts)
+	c.Assert(err, IsNil)
+
+	rsp, err := s.runCommand(c, tcti, cmd)
+	if expected == "" {
+		c.Check(err, IsNil)
+		c.Check(rsp, DeepEquals, responses[0])
+	} else {
+		c.Check(err, ErrorMatches, `command 0 \(TPM_CC_Unseal\) does not match the recording: `+expected)
+	}
+}
+
+func (s *replaySuite) TestMismatchNonce(c *C) {
+	s.testMismatchWithOptions(c, nil,
+		s.sessionCommand(bytes.Repeat([]byte{1}, 32), make(tpm2.Auth, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{1, 2, 3}),
+		"command packet mismatch")
+}
+
+func (s *replaySuite) TestIgnoreNonces(c *C) {
+	s.testMismatchWithOptions(c, &ReplayOptions{IgnoreNonces: true},
+		s.sessionCommand(bytes.Repeat([]byte{1}, 32), make(tpm2.Auth, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{1, 2, 3}),
+		"")
+}
+
+func (s *replaySuite) TestIgnoreNoncesHMACMismatch(c *C) {
+	s.testMismatchWithOptions(c, &ReplayOptions{IgnoreNonces: true},
+		s.sessionCommand(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{1, 2, 3}),
+		"session 0 HMAC mismatch")
+}
+
+func (s *replaySuite) TestIgnoreNoncesSizeMismatch(c *C) {
+	s.testMismatchWithOptions(c, &ReplayOptions{IgnoreNonces: true},
+		s.sessionCommand(make(tpm2.Nonce, 20), make(tpm2.Auth, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{1, 2, 3}),
+		"session 0 nonce size mismatch")
+}
+
+func (s *replaySuite) TestIgnoreNoncesAndHMACs(c *C) {
+	s.testMismatchWithOptions(c, &ReplayOptions{IgnoreNonces: true, IgnoreHMACs: true},
+		s.sessionCommand(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{1, 2, 3}),
+		"")
+}
+
+func (s *replaySuite) TestIgnoreHMACsAttrsMismatch(c *C) {
+	s.testMismatchWithOptions(c, &ReplayOptions{IgnoreHMACs: true},
+		s.sessionCommand(make(tpm2.Nonce, 32), bytes.Repeat([]byte{1}, 32), tpm2.AttrCommandEncrypt, tpm2.Digest{1, 2, 3}),
+		"session 0 attributes mismatch")
+}
+
+func (s *replaySuite) TestParameterMismatch(c *C) {
+	s.testMismatchWithOptions(c, &ReplayOptions{IgnoreNonces: true},
+		s.sessionCommand(make(tpm2.Nonce, 32), make(tpm2.Auth, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{4, 5, 6}),
+		"parameter mismatch")
+}
+
+func (s *replaySuite) TestIgnoreEncryptedParameters(c *C) {
+	s.testMismatchWithOptions(c, &ReplayOptions{IgnoreEncryptedParameters: true},
+		s.sessionCommand(make(tpm2.Nonce, 32), make(tpm2.Auth, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{4, 5, 6}),
+		"")
+}
+
+func (s *replaySuite) TestIgnoreEncryptedParametersSizeMismatch(c *C) {
+	s.testMismatchWithOptions(c, &ReplayOptions{IgnoreEncryptedParameters: true},
+		s.sessionCommand(make(tpm2.Nonce, 32), make(tpm2.Auth, 32), tpm2.AttrContinueSession|tpm2.AttrCommandEncrypt, tpm2.Digest{4, 5, 6, 7}),
+		"encrypted parameter size mismatch")
+}
+
+func (s *replaySuite) TestInvalidRecording(c *C) {
+	_, err := NewReplayTCTI(bytes.NewReader([]byte("XREC\x00\x00\x00\x01")), nil)
+	c.Check(err, ErrorMatches, "invalid magic")
+
+	_, err = NewReplayTCTI(bytes.NewReader([]byte("TREC\x00\x00\x00\x03")), nil)
+	c.Check(err, ErrorMatches, "unsupported version 3")
+
+	recording, _ := s.record(c, tpm2.MarshalCommandPacket(tpm2.CommandGetRandom, nil, nil, mu.MustMarshalToBytes(uint16(32))))
+	_, err = NewReplayTCTI(bytes.NewReader(recording[:len(recording)-1]), nil)
+	c.Check(err, ErrorMatches, "cannot read response for record 0: unexpected EOF")
+}
+
+func (s *replaySuite) runSessionCommands(tpm *tpm2.TPMContext, tpmKey *rsa.PublicKey) error {
+	var key tpm2.ResourceContext
+	if tpmKey != nil {
+		var err error
+		key, err = tpm2.CreateObjectResourceContextFromPublic(0x81000001, NewExternalRSAStoragePublicKey(tpmKey))
+		if err != nil {
+			return err
+		}
+	}
+
+	session, err := tpm.StartAuthSession(key, nil, tpm2.SessionTypeHMAC, nil, tpm2.HashAlgorithmSHA256)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := tpm.ClearControl(tpm.LockoutHandleContext(), false, session.WithAttrs(tpm2.AttrContinueSession)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *replaySuite) testReplaySessionWithTPMContext(c *C, tpmKey *rsa.PrivateKey) {
+	var pub *rsa.PublicKey
+	if tpmKey != nil {
+		pub = &tpmKey.PublicKey
+	}
+
+	var buf bytes.Buffer
+	recordTCTI, err := NewRecordingTCTI(&hmacSessionTCTI{key: tpmKey}, &buf)
+	c.Assert(err, IsNil)
+	tpm, _ := tpm2.NewTPMContext(recordTCTI)
+	tpm.SetRandomSource(recordTCTI.RandomSource())
+	c.Check(s.runSessionCommands(tpm, pub), IsNil)
+
+	replayTCTI, err := NewReplayTCTI(&buf, nil)
+	c.Assert(err, IsNil)
+	tpm, _ = tpm2.NewTPMContext(replayTCTI)
+	tpm.SetRandomSource(replayTCTI.RandomSource())
+	c.Check(s.runSessionCommands(tpm, pub), IsNil)
+	c.Check(replayTCTI.Remaining(), Equals, 0)
+}
+
+func (s *replaySuite) TestReplayHMACSessionWithTPMContext(c *C) {
+	s.testReplaySessionWithTPMContext(c, nil)
+}
+
+func (s *replaySuite) TestReplaySaltedHMACSessionWithTPMContext(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	s.testReplaySessionWithTPMContext(c, key)
+}
+
+func (s *replaySuite) TestReplaySessionWithoutRandomSource(c *C) {
+	var buf bytes.Buffer
+	recordTCTI, err := NewRecordingTCTI(&hmacSessionTCTI{}, &buf)
+	c.Assert(err, IsNil)
+	tpm, _ := tpm2.NewTPMContext(recordTCTI)
+	tpm.SetRandomSource(recordTCTI.RandomSource())
+	c.Check(s.runSessionCommands(tpm, nil), IsNil)
+
+	replayTCTI, err := NewReplayTCTI(&buf, nil)
+	c.Assert(err, IsNil)
+	tpm, _ = tpm2.NewTPMContext(replayTCTI)
+	c.Check(s.runSessionCommands(tpm, nil), ErrorMatches, "cannot complete write operation on TCTI: record 0 contains random bytes that haven't been read")
+}
+
+func (s *replaySuite) TestReplayUnexpectedRandomRead(c *C) {
+	recording, _ := s.record(c, tpm2.MarshalCommandPacket(tpm2.CommandGetRandom, nil, nil, mu.MustMarshalToBytes(uint16(32))))
+
+	tcti, err := NewReplayTCTI(bytes.NewReader(recording), nil)
+	c.Assert(err, IsNil)
+
+	_, err = tcti.RandomSource().Read(make([]byte, 16))
+	c.Check(err, ErrorMatches, "record 0 does not contain random bytes")
+}
+
+func (s *replaySuite) TestReplayVersion1(c *C) {
+	cmd := tpm2.MarshalCommandPacket(tpm2.CommandReadClock, nil, nil, nil)
+	rsp := mu.MustMarshalToBytes(tpm2.ResponseHeader{Tag: tpm2.TagNoSessions, ResponseSize: 10})
+	recording := mu.MustMarshalToBytes(uint32(0x54524543), uint32(1), uint8(0), uint32(len(cmd)), mu.RawBytes(cmd), uint32(len(rsp)), mu.RawBytes(rsp))
+
+	tcti, err := NewReplayTCTI(bytes.NewReader(recording), nil)
+	c.Assert(err, IsNil)
+	c.Check(tcti.Remaining(), Equals, 1)
+
+	replayed, err := s.runCommand(c, tcti, cmd)
+	c.Check(err, IsNil)
+	c.Check(replayed, DeepEquals, tpm2.ResponsePacket(rsp))
+}
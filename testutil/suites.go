@@ -353,7 +353,11 @@ func (b *TPMSimulatorTest) initTPMSimulatorConnectionIfNeeded(c *C) {
 		fallthrough
 	case b.TCTI != nil:
 		// Assert that it is a simulator
-		b.Mssim(c)
+		switch b.simulator(c).(type) {
+		case *tpm2.TctiMssim, *tpm2.TctiSwtpm:
+		default:
+			c.Fatal("not a simulator")
+		}
 		// TPMTest.SetUpTest will create a TPMContext.
 	default:
 		// No connection was created prior to calling SetUpTest.
@@ -365,7 +369,7 @@ func (b *TPMSimulatorTest) initTPMSimulatorConnectionIfNeeded(c *C) {
 // SetUpTest is called to set up the test fixture before each test. If the
 // TCTI member has not been set before this is called, a connection to the TPM
 // simulator and a TPMContext will be created automatically. If TPMBackend is
-// not TPMBackendMssim, then the test will be skipped.
+// not TPMBackendMssim or TPMBackendSwtpm, then the test will be skipped.
 //
 // If the TCTI member is set prior to calling SetUpTest, then a TPMContext is
 // created using this connection if necessary.
@@ -387,8 +391,7 @@ func (b *TPMSimulatorTest) SetUpTest(c *C) {
 	})
 }
 
-// Mssim returns the underlying simulator connection.
-func (b *TPMSimulatorTest) Mssim(c *C) *tpm2.TctiMssim {
+func (b *TPMSimulatorTest) simulator(c *C) tpm2.TCTI {
 	var tcti tpm2.TCTI = b.TCTI
 	for {
 		wrapper, isWrapper := tcti.(TCTIWrapper)
@@ -397,17 +400,30 @@ func (b *TPMSimulatorTest) Mssim(c *C) *tpm2.TctiMssim {
 		}
 		tcti = wrapper.Unwrap()
 	}
+	return tcti
+}
+
+// Mssim returns the underlying simulator connection.
+func (b *TPMSimulatorTest) Mssim(c *C) *tpm2.TctiMssim {
+	tcti := b.simulator(c)
 	c.Assert(tcti, ConvertibleTo, &tpm2.TctiMssim{})
 	return tcti.(*tpm2.TctiMssim)
 }
 
+// Swtpm returns the underlying swtpm connection.
+func (b *TPMSimulatorTest) Swtpm(c *C) *tpm2.TctiSwtpm {
+	tcti := b.simulator(c)
+	c.Assert(tcti, ConvertibleTo, &tpm2.TctiSwtpm{})
+	return tcti.(*tpm2.TctiSwtpm)
+}
+
 // ResetTPMSimulator issues a Shutdown -> Reset -> Startup cycle of the TPM simulator
 // and causes the test to fail if it is not successful.
 func (b *TPMSimulatorTest) ResetTPMSimulator(c *C) {
 	b.TCTI.disableCommandLogging = true
 	defer func() { b.TCTI.disableCommandLogging = false }()
 
-	c.Check(resetTPMSimulator(b.TPM, b.Mssim(c)), IsNil)
+	c.Check(resetTPMSimulator(b.TPM, b.simulator(c)), IsNil)
 }
 
 // ResetAndClearTPMSimulatorUsingPlatformHierarchy issues a Shutdown -> Reset ->
@@ -114,6 +114,7 @@ var commandInfoMap = map[tpm2.CommandCode]commandInfo{
 	tpm2.CommandPolicyAuthValue:            commandInfo{0, 1, false, false},
 	tpm2.CommandPolicyCommandCode:          commandInfo{0, 1, false, false},
 	tpm2.CommandPolicyCounterTimer:         commandInfo{0, 1, false, false},
+	tpm2.CommandPolicyLocality:             commandInfo{0, 1, false, false},
 	tpm2.CommandPolicyCpHash:               commandInfo{0, 1, false, false},
 	tpm2.CommandPolicyNameHash:             commandInfo{0, 1, false, false},
 	tpm2.CommandPolicyOR:                   commandInfo{0, 1, false, false},
@@ -931,6 +932,16 @@ func (t *TCTI) MakeSticky(handle tpm2.Handle, sticky bool) error {
 	return t.tcti.MakeSticky(handle, sticky)
 }
 
+// Cancel requests that the TPM cancels the currently executing command if the
+// underlying interface supports this.
+func (t *TCTI) Cancel() error {
+	tcti, ok := t.tcti.(tpm2.CancellableTCTI)
+	if !ok {
+		return errors.New("not supported")
+	}
+	return tcti.Cancel()
+}
+
 // Unwrap returns the real interface that this one wraps.
 func (t *TCTI) Unwrap() tpm2.TCTI {
 	return t.tcti
@@ -24,7 +24,7 @@ func Test(t *testing.T) { TestingT(t) }
 func TestMain(m *testing.M) {
 	flag.Parse()
 	os.Exit(func() int {
-		if TPMBackend == TPMBackendMssim {
+		if TPMBackend == TPMBackendMssim || TPMBackend == TPMBackendSwtpm {
 			simulatorCleanup, err := LaunchTPMSimulator(nil)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Cannot launch TPM simulator: %v\n", err)
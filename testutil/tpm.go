@@ -138,8 +138,12 @@ const (
 	TPMBackendNone TPMBackendType = iota
 	TPMBackendDevice
 	TPMBackendMssim
+	TPMBackendSwtpm
 )
 
+// swtpmPersistentFile is the name of the file in which swtpm stores the permanent state of the TPM.
+const swtpmPersistentFile = "tpm2-00.permall"
+
 var (
 	// TPMBackend defines the type of TPM connection that should be used for tests.
 	TPMBackend TPMBackendType = TPMBackendNone
@@ -156,6 +160,10 @@ var (
 	// MssimPort defines the port number of the TPM simulator command port where TPMBackend is TPMBackendMssim.
 	MssimPort uint = 2321
 
+	// SwtpmPort defines the port number of the swtpm server channel where TPMBackend is TPMBackendSwtpm. The
+	// control channel uses the next port number.
+	SwtpmPort uint = 2321
+
 	wrapMssimTCTI = WrapTCTI
 )
 
@@ -185,10 +193,12 @@ func (v tpmBackendFlag) IsBoolFlag() bool { return true }
 func AddCommandLineFlags() {
 	flag.Var(tpmBackendFlag(TPMBackendDevice), "use-tpm", "Whether to use a TPM character device for testing (eg, /dev/tpm0)")
 	flag.Var(tpmBackendFlag(TPMBackendMssim), "use-mssim", "Whether to use the TPM simulator for testing")
+	flag.Var(tpmBackendFlag(TPMBackendSwtpm), "use-swtpm", "Whether to use swtpm as the TPM simulator for testing")
 	flag.Var(&PermittedTPMFeatures, "tpm-permitted-features", "Comma-separated list of features that tests can use on a TPM character device")
 
 	flag.StringVar(&TPMDevicePath, "tpm-path", "/dev/tpm0", "The path of the TPM character device to use for testing (default: /dev/tpm0)")
 	flag.UintVar(&MssimPort, "mssim-port", 2321, "The port number of the TPM simulator command channel (default: 2321)")
+	flag.UintVar(&SwtpmPort, "swtpm-port", 2321, "The port number of the swtpm server channel (default: 2321)")
 }
 
 // TPMSimulatorOptions provide the options to LaunchTPMSimulator
@@ -205,6 +215,9 @@ type TPMSimulatorOptions struct {
 // temporary directory to the source directory on exit. This is useful for generating test data that
 // needs to be checked in to a repository.
 //
+// If TPMBackend is TPMBackendSwtpm, swtpm will be launched instead of the reference simulator, and its
+// persistent state will be stored in a file called "tpm2-00.permall".
+//
 // On success, it returns a function that can be used to stop the simulator and clean up its temporary
 // directory.
 func LaunchTPMSimulator(opts *TPMSimulatorOptions) (stop func(), err error) {
@@ -220,6 +233,10 @@ func LaunchTPMSimulator(opts *TPMSimulatorOptions) (stop func(), err error) {
 		opts.SourceDir = wd
 	}
 
+	if TPMBackend == TPMBackendSwtpm {
+		return launchSwtpm(opts)
+	}
+
 	// Search for a TPM simulator binary
 	mssimPath := ""
 	for _, p := range []string{"tpm2-simulator", "tpm2-simulator-chrisccoulson.tpm2-simulator"} {
@@ -428,6 +445,175 @@ Loop:
 	return cleanup, nil
 }
 
+// launchSwtpm is the implementation of LaunchTPMSimulator for swtpm.
+func launchSwtpm(opts *TPMSimulatorOptions) (stop func(), err error) {
+	swtpmPath, err := exec.LookPath("swtpm")
+	if err != nil {
+		return nil, xerrors.Errorf("cannot find swtpm: %w", err)
+	}
+
+	swtpmTmpDir, err := ioutil.TempDir("", "tpm2test.swtpm")
+	if err != nil {
+		return nil, xerrors.Errorf("cannot create temporary directory for swtpm: %w", err)
+	}
+
+	var cmd *exec.Cmd
+
+	// At this point, we have stuff to clean up on early failure.
+	cleanup := func() {
+		// Defer saving the persistent data and removing the temporary directory
+		defer func() {
+			// Defer removal of the temporary directory
+			defer os.RemoveAll(swtpmTmpDir)
+
+			if !opts.SavePersistent {
+				// Nothing else to do
+				return
+			}
+
+			// Open the updated persistent storage
+			src, err := os.Open(filepath.Join(swtpmTmpDir, swtpmPersistentFile))
+			switch {
+			case os.IsNotExist(err):
+				// No storage - this means we failed before swtpm started
+				return
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "Cannot open swtpm persistent data: %v\n", err)
+				return
+			}
+			defer src.Close()
+
+			// Atomically write to the source directory
+			dest, err := osutil.NewAtomicFile(filepath.Join(opts.SourceDir, swtpmPersistentFile), 0644, 0, sys.UserID(osutil.NoChown), sys.GroupID(osutil.NoChown))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot create new atomic file for saving swtpm persistent data: %v\n", err)
+				return
+			}
+			defer dest.Cancel()
+
+			if _, err := io.Copy(dest, src); err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot copy swtpm persistent data: %v\n", err)
+				return
+			}
+
+			if err := dest.Commit(); err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot commit swtpm persistent data: %v\n", err)
+			}
+		}()
+
+		if cmd != nil && cmd.Process != nil {
+			// If we've called exec.Cmd.Start, attempt to stop swtpm.
+			cleanShutdown := false
+			// Defer the call to exec.Cmd.Wait or os.Process.Kill until after we've initiated the shutdown.
+			defer func() {
+				if cleanShutdown {
+					if err := cmd.Wait(); err != nil {
+						fmt.Fprintf(os.Stderr, "swtpm finished with an error: %v", err)
+					}
+				} else {
+					fmt.Fprintf(os.Stderr, "Killing swtpm\n")
+					if err := cmd.Process.Kill(); err != nil {
+						fmt.Fprintf(os.Stderr, "Cannot send signal to swtpm: %v\n", err)
+					}
+				}
+			}()
+
+			tcti, err := openSwtpm()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot open swtpm connection for shutdown: %v\n", err)
+				return
+			}
+
+			tpm, _ := tpm2.NewTPMContext(tcti)
+			if err := tpm.Shutdown(tpm2.StartupClear); err != nil {
+				fmt.Fprintf(os.Stderr, "swtpm TPM shutdown failed: %v\n", err)
+			}
+			if err := tcti.Shutdown(); err != nil {
+				fmt.Fprintf(os.Stderr, "swtpm shutdown failed: %v\n", err)
+				return
+			}
+			if err := tpm.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "swtpm connection close failed: %v\n", err)
+				return
+			}
+			cleanShutdown = true
+		}
+	}
+
+	succeeded := false
+	// Defer cleanup on failure
+	defer func() {
+		if succeeded {
+			return
+		}
+		cleanup()
+	}()
+
+	// Copy any pre-existing persistent data in to the temporary directory. swtpm manufactures a
+	// new TPM if there is no persistent data.
+	if !opts.Manufacture {
+		source, err := os.Open(filepath.Join(opts.SourceDir, swtpmPersistentFile))
+		switch {
+		case err != nil && !os.IsNotExist(err):
+			return nil, xerrors.Errorf("cannot open source persistent storage: %w", err)
+		case err != nil:
+			// Nothing to do
+		default:
+			defer source.Close()
+			dest, err := os.Create(filepath.Join(swtpmTmpDir, swtpmPersistentFile))
+			if err != nil {
+				return nil, xerrors.Errorf("cannot create temporary storage for swtpm: %w", err)
+			}
+			defer dest.Close()
+			if _, err := io.Copy(dest, source); err != nil {
+				return nil, xerrors.Errorf("cannot copy persistent storage to temporary location for swtpm: %w", err)
+			}
+		}
+	}
+
+	cmd = exec.Command(swtpmPath, "socket", "--tpm2",
+		"--tpmstate", "dir="+swtpmTmpDir,
+		"--server", fmt.Sprintf("type=tcp,port=%d", SwtpmPort),
+		"--ctrl", fmt.Sprintf("type=tcp,port=%d", SwtpmPort+1))
+
+	if err := cmd.Start(); err != nil {
+		return nil, xerrors.Errorf("cannot start swtpm: %w", err)
+	}
+
+	var tcti *tpm2.TctiSwtpm
+	// Give swtpm 5 seconds to start up
+Loop:
+	for i := 0; ; i++ {
+		var err error
+		tcti, err = openSwtpm()
+		switch {
+		case err != nil && i == 4:
+			return nil, xerrors.Errorf("cannot open swtpm connection: %w", err)
+		case err != nil:
+			time.Sleep(time.Second)
+		default:
+			break Loop
+		}
+	}
+
+	tpm, _ := tpm2.NewTPMContext(tcti)
+	defer tpm.Close()
+
+	if err := tcti.Init(0); err != nil {
+		return nil, xerrors.Errorf("swtpm initialization failed: %w", err)
+	}
+	if err := tpm.Startup(tpm2.StartupClear); err != nil {
+		return nil, xerrors.Errorf("swtpm startup failed: %w", err)
+	}
+
+	succeeded = true
+	return cleanup, nil
+}
+
+func openSwtpm() (*tpm2.TctiSwtpm, error) {
+	return tpm2.OpenSwtpm("tcp", fmt.Sprintf("localhost:%d", SwtpmPort), fmt.Sprintf("localhost:%d", SwtpmPort+1))
+}
+
 func newTCTI(features TPMFeatureFlags) (*TCTI, error) {
 	switch TPMBackend {
 	case TPMBackendNone:
@@ -447,6 +633,12 @@ func newTCTI(features TPMFeatureFlags) (*TCTI, error) {
 			return nil, err
 		}
 		return WrapTCTI(tcti, features)
+	case TPMBackendSwtpm:
+		tcti, err := openSwtpm()
+		if err != nil {
+			return nil, err
+		}
+		return WrapTCTI(tcti, features)
 	}
 	panic("not reached")
 }
@@ -523,21 +715,31 @@ func NewTPMContextT(t *testing.T, features TPMFeatureFlags) (tpm *tpm2.TPMContex
 }
 
 func newSimulatorTCTI() (*TCTI, error) {
-	if TPMBackend != TPMBackendMssim {
+	var tcti tpm2.TCTI
+	switch TPMBackend {
+	case TPMBackendMssim:
+		mssim, err := tpm2.OpenMssim("", MssimPort)
+		if err != nil {
+			return nil, err
+		}
+		tcti = mssim
+	case TPMBackendSwtpm:
+		swtpm, err := openSwtpm()
+		if err != nil {
+			return nil, err
+		}
+		tcti = swtpm
+	default:
 		return nil, nil
 	}
 
-	mssim, err := tpm2.OpenMssim("", MssimPort)
-	if err != nil {
-		return nil, err
-	}
-
-	return wrapMssimTCTI(mssim, TPMFeatureFlags(math.MaxUint32))
+	return wrapMssimTCTI(tcti, TPMFeatureFlags(math.MaxUint32))
 }
 
 // NewSimulatorTCTI returns a new TCTI for testing that corresponds to a connection to the TPM simulator
-// on the port specified by the MssimPort variable. If TPMBackend is not TPMBackendMssim then the test
-// will be skipped.
+// on the port specified by the MssimPort variable, or to swtpm on the port specified by the SwtpmPort
+// variable if TPMBackend is TPMBackendSwtpm. If TPMBackend is not TPMBackendMssim or TPMBackendSwtpm then
+// the test will be skipped.
 //
 // The returned TCTI must be closed when it is no longer required.
 func NewSimulatorTCTI(c *C) *TCTI {
@@ -607,23 +809,28 @@ func ClearTPMUsingPlatformHierarchyT(t *testing.T, tpm *tpm2.TPMContext) {
 	}
 }
 
-func resetTPMSimulator(tpm *tpm2.TPMContext, tcti *tpm2.TctiMssim) error {
+func resetTPMSimulator(tpm *tpm2.TPMContext, tcti tpm2.TCTI) error {
 	if err := tpm.Shutdown(tpm2.StartupClear); err != nil {
 		return err
 	}
-	if err := tcti.Reset(); err != nil {
-		return xerrors.Errorf("resetting the simulator failed: %v", err)
+	switch t := tcti.(type) {
+	case *tpm2.TctiMssim:
+		if err := t.Reset(); err != nil {
+			return xerrors.Errorf("resetting the simulator failed: %v", err)
+		}
+	case *tpm2.TctiSwtpm:
+		if err := t.Init(0); err != nil {
+			return xerrors.Errorf("resetting swtpm failed: %v", err)
+		}
+	default:
+		return errors.New("not a simulator")
 	}
 	return tpm.Startup(tpm2.StartupClear)
 }
 
 // ResetTPMSimulatorT issues a Shutdown -> Reset -> Startup cycle of the TPM simulator.
 func ResetTPMSimulatorT(t *testing.T, tpm *tpm2.TPMContext, tcti *TCTI) {
-	mssim, ok := tcti.Unwrap().(*tpm2.TctiMssim)
-	if !ok {
-		t.Fatal("not a simulator")
-	}
-	if err := resetTPMSimulator(tpm, mssim); err != nil {
+	if err := resetTPMSimulator(tpm, tcti.Unwrap()); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -5,11 +5,15 @@
 package tpm2
 
 import (
-	"bytes"
+	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"reflect"
+	"sync"
+	"time"
 
 	"github.com/canonical/go-tpm2/mu"
 
@@ -38,11 +42,14 @@ func isSessionAllowed(commandCode CommandCode) bool {
 type cmdContext struct {
 	commandCode      CommandCode
 	sessionParams    *sessionParams
+	handleNames      []Name
+	cpBytes          []byte
 	responseCode     ResponseCode
 	responseAuthArea []AuthResponse
 	rpBytes          []byte
 }
 
+//mugen:skip
 type delimiterSentinel struct{}
 
 // Delimiter is a sentinel value used to delimit command handle, command parameter, response handle pointer and response
@@ -73,15 +80,68 @@ type ResourceContextWithSession struct {
 // Some methods also accept a variable number of optional SessionContext arguments - these are for sessions that don't provide
 // authorization for a corresponding TPM resource. These sessions may be used for the purposes of session based parameter encryption
 // or command auditing.
+//
+// TPMContext is safe to use from multiple goroutines. Commands are executed one at a time, and TPMContext.Transaction can be used
+// to execute a sequence of commands without commands from other goroutines being interleaved. Note that the state of
+// HandleContext and SessionContext instances is updated by the commands that use them, so modifying them (eg, with
+// ResourceContext.SetAuthValue or SessionContext.SetAttrs) whilst they are in use by another goroutine is not safe.
 type TPMContext struct {
-	tcti                  TCTI
-	permanentResources    map[Handle]*permanentContext
-	maxSubmissions        uint
-	propertiesInitialized bool
-	maxBufferSize         int
-	maxDigestSize         int
-	maxNVBufferSize       int
-	exclusiveSession      *sessionContext
+	*tpmContextState
+
+	// tx is the transaction that this TPMContext was created for by TPMContext.Transaction, if any. The command lock is
+	// already held for the duration of the transaction.
+	tx *transaction
+
+	// ctx is the context that this TPMContext was bound to by TPMContext.WithContext, if any.
+	ctx context.Context
+}
+
+// tpmContextState is the state shared between a TPMContext and the TPMContexts created for transactions.
+type tpmContextState struct {
+	tcti    TCTI
+	cmdLock chan struct{} // Held whilst a command or transaction is executing
+
+	abortedCommand chan commandResult // Protected by cmdLock
+
+	mu                 sync.Mutex // Protects the fields below
+	permanentResources map[Handle]*permanentContext
+	maxSubmissions     uint
+	properties         *tpmProperties
+	exclusiveSession   *sessionContext
+	defaultSession     *sessionContext
+	sessionAuditLogs   []*SessionAuditLog
+	commandAuditLogs   []*CommandAuditRecorder
+	commandObservers   []CommandObserver
+	random             io.Reader
+}
+
+// tpmProperties contains properties used internally by TPMContext.
+type tpmProperties struct {
+	maxBufferSize   int
+	maxDigestSize   int
+	maxNVBufferSize int
+}
+
+// WithContext returns a view of this TPMContext that is bound to the supplied context. Commands executed with the returned
+// TPMContext, including those executed by typed command methods such as CreatePrimary, are aborted if the context is
+// cancelled or its deadline expires before the response is received. See RunCommandBytesContext for details of how an
+// aborted command is handled. The context is also used when waiting for other commands or transactions to complete.
+//
+// The returned TPMContext shares all of its state with this TPMContext, which is not bound to the supplied context.
+// Commands executed with RunCommandContext or RunCommandBytesContext use the context passed to them instead.
+func (t *TPMContext) WithContext(ctx context.Context) *TPMContext {
+	if ctx == nil {
+		panic("nil context")
+	}
+	return &TPMContext{tpmContextState: t.tpmContextState, tx: t.tx, ctx: ctx}
+}
+
+// boundContext returns the context that this TPMContext is bound to, or context.Background() if it isn't bound to one.
+func (t *TPMContext) boundContext() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
 }
 
 // Close calls Close on the transmission interface.
@@ -93,12 +153,12 @@ func (t *TPMContext) Close() error {
 	return nil
 }
 
-// RunCommandBytes is a low-level interface for executing a command. The caller is responsible for supplying a properly
-// serialized command packet, which can be created with MarshalCommandPacket.
-//
-// If successful, this function will return the response packet. An error will only be returned if the transmission
-// interface returns an error.
-func (t *TPMContext) RunCommandBytes(packet CommandPacket) (ResponsePacket, error) {
+type commandResult struct {
+	resp ResponsePacket
+	err  error
+}
+
+func (t *TPMContext) transmitCommand(packet CommandPacket) (ResponsePacket, error) {
 	if _, err := t.tcti.Write(packet); err != nil {
 		return nil, &TctiError{"write", err}
 	}
@@ -111,7 +171,85 @@ func (t *TPMContext) RunCommandBytes(packet CommandPacket) (ResponsePacket, erro
 	return ResponsePacket(resp), nil
 }
 
-func (t *TPMContext) runCommandWithoutProcessingAuthResponse(commandCode CommandCode, sessionParams *sessionParams, inHandles []HandleContext, params []interface{}, outHandle *Handle) (*cmdContext, error) {
+// waitForAbortedCommand waits for the response to a previously aborted command to be drained from the TCTI.
+func (t *TPMContext) waitForAbortedCommand(ctx context.Context) error {
+	if t.abortedCommand == nil {
+		return nil
+	}
+	select {
+	case <-t.abortedCommand:
+		t.abortedCommand = nil
+		return nil
+	case <-ctx.Done():
+		return xerrors.Errorf("cannot wait for a previously aborted command to complete: %w", ctx.Err())
+	}
+}
+
+// RunCommandBytes is a low-level interface for executing a command. The caller is responsible for supplying a properly
+// serialized command packet, which can be created with MarshalCommandPacket.
+//
+// If successful, this function will return the response packet. An error will only be returned if the transmission
+// interface returns an error.
+func (t *TPMContext) RunCommandBytes(packet CommandPacket) (ResponsePacket, error) {
+	return t.RunCommandBytesContext(t.boundContext(), packet)
+}
+
+// RunCommandBytesContext is a variant of RunCommandBytes that aborts the command if the supplied context is cancelled or its
+// deadline expires before the response is received, in which case an error that wraps the context's error is returned.
+//
+// When a command is aborted, the TPM is asked to cancel it if the TCTI implements CancellableTCTI. The response is drained from
+// the TCTI in the background, and subsequent commands wait for this to complete, so that this TPMContext remains usable. Note
+// that the TPM may still complete an aborted command if it cannot be cancelled, in which case the response is discarded. Any
+// resources created by the command will not be known, and sessions used by the command may no longer be usable. Closing the
+// TPMContext interrupts a TPM that never responds.
+func (t *TPMContext) RunCommandBytesContext(ctx context.Context, packet CommandPacket) (ResponsePacket, error) {
+	unlock, err := t.lockCommands(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	start := time.Now()
+	resp, err := t.runCommandBytes(ctx, packet)
+	t.observeRawCommand(packet, resp, start, err)
+	return resp, err
+}
+
+func (t *TPMContext) runCommandBytes(ctx context.Context, packet CommandPacket) (ResponsePacket, error) {
+	if err := t.waitForAbortedCommand(ctx); err != nil {
+		return nil, err
+	}
+
+	if ctx.Done() == nil {
+		// This context can never be cancelled.
+		return t.transmitCommand(packet)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("cannot execute command: %w", err)
+	}
+
+	result := make(chan commandResult, 1)
+	go func() {
+		resp, err := t.transmitCommand(packet)
+		result <- commandResult{resp, err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.resp, r.err
+	case <-ctx.Done():
+	}
+
+	if tcti, ok := t.tcti.(CancellableTCTI); ok {
+		// This is best effort - the response will be drained regardless of whether this succeeds.
+		tcti.Cancel()
+	}
+	t.abortedCommand = result
+
+	return nil, xerrors.Errorf("command was aborted: %w", ctx.Err())
+}
+
+func (t *TPMContext) runCommandWithoutProcessingAuthResponse(ctx context.Context, commandCode CommandCode, sessionParams *sessionParams, inHandles []HandleContext, params []interface{}, outHandle *Handle, event *CommandEvent) (*cmdContext, error) {
 	handles := make(HandleList, 0, len(inHandles))
 	handleNames := make([]Name, 0, len(inHandles))
 
@@ -129,20 +267,39 @@ func (t *TPMContext) runCommandWithoutProcessingAuthResponse(commandCode Command
 		return nil, xerrors.Errorf("cannot marshal parameters for command %s: %w", commandCode, err)
 	}
 
-	cAuthArea, err := sessionParams.buildCommandAuthArea(commandCode, handleNames, cpBytes)
+	cAuthArea, err := sessionParams.buildCommandAuthArea(t.randomSource(), commandCode, handleNames, cpBytes)
 	if err != nil {
 		return nil, xerrors.Errorf("cannot build auth area for command %s: %w", commandCode, err)
 	}
 
 	cmd := MarshalCommandPacket(commandCode, handles, cAuthArea, cpBytes)
 
+	event.Handles = handles
+	for _, auth := range cAuthArea {
+		event.Sessions = append(event.Sessions, auth.SessionAttributes)
+	}
+	event.Parameters = params
+	event.ParametersEncrypted = sessionParams.hasDecryptSession()
+	encryptSession, _ := sessionParams.findEncryptSession()
+	event.ResponseParametersEncrypted = encryptSession != nil
+
+	start := time.Now()
+	defer func() {
+		event.Duration = time.Since(start)
+	}()
+
 	var responseCode ResponseCode
 	var rpBytes []byte
 	var rAuthArea []AuthResponse
 
+	t.mu.Lock()
+	maxSubmissions := t.maxSubmissions
+	t.mu.Unlock()
+
 	for tries := uint(1); ; tries++ {
 		var err error
-		resp, err := t.RunCommandBytes(cmd)
+		event.Submissions = tries
+		resp, err := t.runCommandBytes(ctx, cmd)
 		if err != nil {
 			return nil, err
 		}
@@ -151,6 +308,7 @@ func (t *TPMContext) runCommandWithoutProcessingAuthResponse(commandCode Command
 		if err != nil {
 			return nil, &InvalidResponseError{commandCode, fmt.Sprintf("cannot unmarshal response packet: %v", err)}
 		}
+		event.ResponseCode = responseCode
 
 		err = DecodeResponseCode(commandCode, responseCode)
 		if _, invalidRc := err.(InvalidResponseCodeError); invalidRc {
@@ -165,7 +323,7 @@ func (t *TPMContext) runCommandWithoutProcessingAuthResponse(commandCode Command
 			break
 		}
 
-		if tries >= t.maxSubmissions {
+		if tries >= maxSubmissions {
 			return nil, err
 		}
 		if !(IsTPMWarning(err, WarningYielded, commandCode) || IsTPMWarning(err, WarningTesting, commandCode) || IsTPMWarning(err, WarningRetry, commandCode)) {
@@ -176,19 +334,25 @@ func (t *TPMContext) runCommandWithoutProcessingAuthResponse(commandCode Command
 	return &cmdContext{
 		commandCode:      commandCode,
 		sessionParams:    sessionParams,
+		handleNames:      handleNames,
+		cpBytes:          cpBytes,
 		responseCode:     responseCode,
 		responseAuthArea: rAuthArea,
 		rpBytes:          rpBytes}, nil
 }
 
 func (t *TPMContext) processAuthResponse(cmd *cmdContext, params []interface{}) error {
+	// The audit digests are computed from the response parameters before they are decrypted.
+	audit := t.computeAuditEntries(cmd)
 	if len(cmd.responseAuthArea) > 0 {
 		if err := cmd.sessionParams.processResponseAuthArea(cmd.responseAuthArea, cmd.responseCode, cmd.rpBytes); err != nil {
 			return &InvalidResponseError{cmd.commandCode, fmt.Sprintf("cannot process response auth area: %v", err)}
 		}
 	}
+	audit()
 
 	if isSessionAllowed(cmd.commandCode) {
+		t.mu.Lock()
 		if t.exclusiveSession != nil {
 			t.exclusiveSession.Data().IsExclusive = false
 		}
@@ -206,16 +370,16 @@ func (t *TPMContext) processAuthResponse(cmd *cmdContext, params []interface{})
 		if t.exclusiveSession != nil {
 			t.exclusiveSession.Data().IsExclusive = true
 		}
+		t.mu.Unlock()
 	}
 
-	rpBuf := bytes.NewReader(cmd.rpBytes)
-
-	if _, err := mu.UnmarshalFromReader(rpBuf, params...); err != nil {
+	n, err := mu.UnmarshalFromBytesWithOptions(cmd.rpBytes, &responseUnmarshalOptions, params...)
+	if err != nil {
 		return &InvalidResponseError{cmd.commandCode, fmt.Sprintf("cannot unmarshal response parameters: %v", err)}
 	}
 
-	if rpBuf.Len() > 0 {
-		return &InvalidResponseError{cmd.commandCode, fmt.Sprintf("response parameter area contains %d trailing bytes", rpBuf.Len())}
+	if n < len(cmd.rpBytes) {
+		return &InvalidResponseError{cmd.commandCode, fmt.Sprintf("response parameter area contains %d trailing bytes", len(cmd.rpBytes)-n)}
 	}
 
 	return nil
@@ -252,9 +416,16 @@ func (t *TPMContext) processAuthResponse(cmd *cmdContext, params []interface{})
 // The caller can provide additional sessions that aren't associated with a TPM entity (and therefore not used for authorization) via
 // the sessions parameter, for the purposes of command auditing or session based parameter encryption.
 //
+// If a default session has been set with TPMContext.SetDefaultSession, it will be used for the first authorization that doesn't
+// have a session and for session based parameter encryption where the first command or response parameter can be encrypted.
+//
 // In addition to returning an error if any marshalling or unmarshalling fails, or if the transmission backend returns an error,
 // this function will also return an error if the TPM responds with any ResponseCode other than Success.
 func (t *TPMContext) RunCommandWithResponseCallback(commandCode CommandCode, sessions []SessionContext, responseCb func(), params ...interface{}) error {
+	return t.runCommandWithResponseCallback(t.boundContext(), commandCode, sessions, responseCb, params...)
+}
+
+func (t *TPMContext) runCommandWithResponseCallback(ctx context.Context, commandCode CommandCode, sessions []SessionContext, responseCb func(), params ...interface{}) (err error) {
 	var commandHandles []HandleContext
 	var commandParams []interface{}
 	var responseHandle *Handle
@@ -307,7 +478,26 @@ func (t *TPMContext) RunCommandWithResponseCallback(commandCode CommandCode, ses
 		return fmt.Errorf("cannot process non-auth SessionContext parameters for command %s: %v", commandCode, err)
 	}
 
-	ctx, err := t.runCommandWithoutProcessingAuthResponse(commandCode, &sessionParams, commandHandles, commandParams, responseHandle)
+	unlock, err := t.lockCommands(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := t.attachDefaultSession(commandCode, &sessionParams, commandHandles, commandParams, responseParams); err != nil {
+		return fmt.Errorf("cannot attach default session for command %s: %v", commandCode, err)
+	}
+
+	event := &CommandEvent{CommandCode: commandCode}
+	defer func() {
+		if err == nil {
+			event.ResponseParameters = responseParams
+		}
+		event.Err = err
+		t.notifyCommandObservers(event)
+	}()
+
+	cmd, err := t.runCommandWithoutProcessingAuthResponse(ctx, commandCode, &sessionParams, commandHandles, commandParams, responseHandle, event)
 	if err != nil {
 		return err
 	}
@@ -316,7 +506,7 @@ func (t *TPMContext) RunCommandWithResponseCallback(commandCode CommandCode, ses
 		responseCb()
 	}
 
-	return t.processAuthResponse(ctx, responseParams)
+	return t.processAuthResponse(cmd, responseParams)
 }
 
 // RunCommand is the high-level generic interface for executing the command specified by commandCode. All of the methods on TPMContext
@@ -348,18 +538,181 @@ func (t *TPMContext) RunCommandWithResponseCallback(commandCode CommandCode, ses
 // The caller can provide additional sessions that aren't associated with a TPM entity (and therefore not used for authorization) via
 // the sessions parameter, for the purposes of command auditing or session based parameter encryption.
 //
+// If a default session has been set with TPMContext.SetDefaultSession, it will be used for the first authorization that doesn't
+// have a session and for session based parameter encryption where the first command or response parameter can be encrypted.
+//
 // In addition to returning an error if any marshalling or unmarshalling fails, or if the transmission backend returns an error,
 // this function will also return an error if the TPM responds with any ResponseCode other than Success.
 func (t *TPMContext) RunCommand(commandCode CommandCode, sessions []SessionContext, params ...interface{}) error {
 	return t.RunCommandWithResponseCallback(commandCode, sessions, nil, params...)
 }
 
+// RunCommandContext is a variant of RunCommand that aborts the command if the supplied context is cancelled or its deadline
+// expires before the response is received. See RunCommandBytesContext for details of how an aborted command is handled.
+func (t *TPMContext) RunCommandContext(ctx context.Context, commandCode CommandCode, sessions []SessionContext, params ...interface{}) error {
+	return t.runCommandWithResponseCallback(ctx, commandCode, sessions, nil, params...)
+}
+
+func isPartialHandleContext(h HandleContext) bool {
+	switch c := h.(type) {
+	case *handleContext:
+		return c.Type == handleContextTypePartial
+	case *resourceContext:
+		return c.Type == handleContextTypePartial
+	default:
+		return false
+	}
+}
+
+func (t *TPMContext) attachDefaultSession(commandCode CommandCode, sessionParams *sessionParams, commandHandles []HandleContext, commandParams, responseParams []interface{}) error {
+	t.mu.Lock()
+	defaultSession := t.defaultSession
+	t.mu.Unlock()
+
+	if defaultSession == nil || !isSessionAllowed(commandCode) {
+		return nil
+	}
+	data := defaultSession.Data()
+	if data == nil || defaultSession.Handle() == HandleUnassigned {
+		// The session has been flushed or is incomplete.
+		return nil
+	}
+	for _, h := range commandHandles {
+		if isPartialHandleContext(h) {
+			// The name of the handle isn't known, so the command and response HMACs can't be computed.
+			return nil
+		}
+	}
+
+	var attrs SessionAttributes
+	if data.Symmetric != nil && data.Symmetric.Algorithm != SymAlgorithmNull {
+		if len(commandParams) > 0 && isParamEncryptable(commandParams[0]) {
+			attrs |= AttrCommandEncrypt
+		}
+		if len(responseParams) > 0 && isParamEncryptable(responseParams[0]) {
+			attrs |= AttrResponseEncrypt
+		}
+	}
+
+	return sessionParams.attachDefaultSession(defaultSession, attrs)
+}
+
+// SetDefaultSession sets a HMAC session to be used automatically by all commands executed by this TPMContext. Setting a nil
+// session disables this behaviour. The session should normally be salted so that the session key can't be determined by an
+// observer of the bus - see TPMContext.StartDefaultSession.
+//
+// Once set, the session is used for the first authorization of each command that is supplied without a session, in place of
+// a cleartext password authorization. If the first command parameter can be encrypted, it is also used for command parameter
+// encryption, and if the first response parameter can be encrypted, it is used for response parameter encryption, unless
+// another session provides these. Where the session isn't used for authorization but is required for parameter encryption,
+// it is added as an extra session. The session attributes of the supplied session are ignored, and nonces are refreshed for
+// each command.
+//
+// The session is not used for commands where the name of any command handle is unknown (eg, those created with
+// CreatePartialHandleContext), as the session HMACs can't be computed, or for commands that don't accept sessions. It is also
+// not used once it has been flushed. The session is not flushed by this function.
+func (t *TPMContext) SetDefaultSession(session SessionContext) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if session == nil {
+		t.defaultSession = nil
+		return
+	}
+	s := session.(*sessionContext)
+	if data := s.Data(); data != nil && data.SessionType != SessionTypeHMAC {
+		panic("default session must be a HMAC session")
+	}
+	t.defaultSession = s
+}
+
+// DefaultSession returns the session set by TPMContext.SetDefaultSession or TPMContext.StartDefaultSession, or nil if there
+// isn't one.
+func (t *TPMContext) DefaultSession() SessionContext {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.defaultSession == nil {
+		return nil
+	}
+	return t.defaultSession
+}
+
+func (t *TPMContext) findDefaultSessionSaltKey() (ResourceContext, error) {
+	// Try the storage primary key followed by the RSA endorsement key, at their well known persistent handles.
+	for _, handle := range []Handle{0x81000001, 0x81010001} {
+		rc, err := t.CreateResourceContextFromTPM(handle)
+		switch {
+		case IsResourceUnavailableError(err, handle):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		pub := rc.(*objectContext).GetPublic()
+		if pub.IsAsymmetric() && pub.Attrs&AttrDecrypt != 0 {
+			return rc, nil
+		}
+	}
+	return nil, errors.New("no suitable key found")
+}
+
+// StartDefaultSession starts a salted, unbound HMAC session with the SHA-256 digest algorithm and AES-128-CFB for parameter
+// encryption, and sets it as the default session with TPMContext.SetDefaultSession.
+//
+// The tpmKey argument is the asymmetric decrypt key used to encrypt the salt with CryptSecretEncrypt. If it is nil, then the
+// storage primary key at handle 0x81000001 is used if it exists, else the endorsement key at handle 0x81010001 is used. Note
+// that the public area of an auto-discovered key is read from the TPM without verification, and so the caller should supply
+// a key where the TPM is not trusted to provide an authentic one.
+//
+// The caller is responsible for flushing the returned session when it is no longer required.
+func (t *TPMContext) StartDefaultSession(tpmKey ResourceContext) (SessionContext, error) {
+	if tpmKey == nil {
+		var err error
+		tpmKey, err = t.findDefaultSessionSaltKey()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot find key for salting session: %w", err)
+		}
+	}
+
+	symmetric := &SymDef{
+		Algorithm: SymAlgorithmAES,
+		KeyBits:   &SymKeyBitsU{Sym: 128},
+		Mode:      &SymModeU{Sym: SymModeCFB}}
+	session, err := t.StartAuthSession(tpmKey, nil, SessionTypeHMAC, symmetric, HashAlgorithmSHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	t.SetDefaultSession(session)
+	return session, nil
+}
+
 // SetMaxSubmissions sets the maximum number of times that RunCommand will attempt to submit a command before failing with an error.
 // The default value is 5.
 func (t *TPMContext) SetMaxSubmissions(max uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.maxSubmissions = max
 }
 
+// SetRandomSource sets the source of random bytes used to generate caller nonces and salts for sessions. If r is nil, the
+// default source (crypto/rand.Reader) is restored. This is intended for tests that record and replay sessions, where the
+// session keys and HMACs must be reproducible, and it must not be used otherwise.
+func (t *TPMContext) SetRandomSource(r io.Reader) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.random = r
+}
+
+func (t *TPMContext) randomSource() io.Reader {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.random == nil {
+		return rand.Reader
+	}
+	return t.random
+}
+
 // InitProperties executes a TPM2_GetCapability command to initialize properties used internally by TPMContext. This is normally done
 // automatically by functions that require these properties when they are used for the first time, but this function is provided so
 // that the command can be audited, and so the exclusivity of an audit session can be preserved.
@@ -369,40 +722,58 @@ func (t *TPMContext) InitProperties(sessions ...SessionContext) error {
 		return err
 	}
 
+	_, err = t.initProperties(props)
+	return err
+}
+
+func (t *TPMContext) initProperties(props TaggedTPMPropertyList) (*tpmProperties, error) {
+	properties := new(tpmProperties)
 	for _, prop := range props {
 		switch prop.Property {
 		case PropertyInputBuffer:
-			t.maxBufferSize = int(prop.Value)
+			properties.maxBufferSize = int(prop.Value)
 		case PropertyMaxDigest:
-			t.maxDigestSize = int(prop.Value)
+			properties.maxDigestSize = int(prop.Value)
 		case PropertyNVBufferMax:
-			t.maxNVBufferSize = int(prop.Value)
+			properties.maxNVBufferSize = int(prop.Value)
 		}
 	}
 
-	if t.maxBufferSize == 0 {
-		t.maxBufferSize = 1024
+	if properties.maxBufferSize == 0 {
+		properties.maxBufferSize = 1024
 	}
-	if t.maxDigestSize == 0 {
-		return &InvalidResponseError{Command: CommandGetCapability, msg: "missing or invalid TPM_PT_MAX_DIGEST property"}
+	if properties.maxDigestSize == 0 {
+		return nil, &InvalidResponseError{Command: CommandGetCapability, msg: "missing or invalid TPM_PT_MAX_DIGEST property"}
 	}
-	if t.maxNVBufferSize == 0 {
-		return &InvalidResponseError{Command: CommandGetCapability, msg: "missing or invalid TPM_PT_NV_BUFFER_MAX property"}
+	if properties.maxNVBufferSize == 0 {
+		return nil, &InvalidResponseError{Command: CommandGetCapability, msg: "missing or invalid TPM_PT_NV_BUFFER_MAX property"}
 	}
-	t.propertiesInitialized = true
-	return nil
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.properties = properties
+	return properties, nil
 }
 
-func (t *TPMContext) initPropertiesIfNeeded() error {
-	if t.propertiesInitialized {
-		return nil
+func (t *TPMContext) initPropertiesIfNeeded() (*tpmProperties, error) {
+	t.mu.Lock()
+	properties := t.properties
+	t.mu.Unlock()
+	if properties != nil {
+		return properties, nil
+	}
+
+	props, err := t.GetCapabilityTPMProperties(PropertyFixed, CapabilityMaxProperties)
+	if err != nil {
+		return nil, err
 	}
-	return t.InitProperties()
+	return t.initProperties(props)
 }
 
 func newTpmContext(tcti TCTI) *TPMContext {
-	r := new(TPMContext)
+	r := &TPMContext{tpmContextState: new(tpmContextState)}
 	r.tcti = tcti
+	r.cmdLock = make(chan struct{}, 1)
 	r.permanentResources = make(map[Handle]*permanentContext)
 	r.maxSubmissions = 5
 
@@ -393,7 +393,7 @@ func verifyContextFlushed(t *testing.T, tpm *TPMContext, context HandleContext)
 func TestMain(m *testing.M) {
 	flag.Parse()
 	os.Exit(func() int {
-		if testutil.TPMBackend == testutil.TPMBackendMssim {
+		if testutil.TPMBackend == testutil.TPMBackendMssim || testutil.TPMBackend == testutil.TPMBackendSwtpm {
 			simulatorCleanup, err := testutil.LaunchTPMSimulator(nil)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Cannot launch TPM simulator: %v\n", err)
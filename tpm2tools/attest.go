@@ -0,0 +1,297 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// hashAlgString returns the representation of a hash algorithm used by tpm2_print, which
+// is the decimal value followed by the name in parentheses.
+func hashAlgString(alg tpm2.AlgorithmId) string {
+	return fmt.Sprintf("%d (%s)", uint16(alg), algName(alg))
+}
+
+func parseHashAlgString(s string) (tpm2.AlgorithmId, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, errors.New("empty value")
+	}
+	v, err := strconv.ParseUint(fields[0], 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	alg := tpm2.AlgorithmId(v)
+	if len(fields) > 1 && strings.Join(fields[1:], " ") != "("+algName(alg)+")" {
+		return 0, fmt.Errorf("value %q is inconsistent with algorithm %v", s, alg)
+	}
+	return alg, nil
+}
+
+func clockInfoNode(info *tpm2.ClockInfo) mapping {
+	var safe uint64
+	if info.Safe {
+		safe = 1
+	}
+	return mapping{
+		{key: "clock", value: info.Clock},
+		{key: "resetCount", value: uint64(info.ResetCount)},
+		{key: "restartCount", value: uint64(info.RestartCount)},
+		{key: "safe", value: safe}}
+}
+
+func pcrSelectionListNode(pcrs tpm2.PCRSelectionList) (mapping, error) {
+	var selections mapping
+	for i, s := range pcrs {
+		b, err := mu.MarshalToBytes(s.Select)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot marshal selection %d: %w", i, err)
+		}
+		selections.add(strconv.Itoa(i), mapping{
+			{key: "hash", value: hashAlgString(tpm2.AlgorithmId(s.Hash))},
+			{key: "sizeofSelect", value: uint64(b[0])},
+			{key: "pcrSelect", value: hex.EncodeToString(b[1:])}})
+	}
+
+	m := mapping{{key: "count", value: uint64(len(pcrs))}}
+	if len(selections) > 0 {
+		m.add("pcrSelections", selections)
+	}
+	return m, nil
+}
+
+func attestedNode(attest *tpm2.Attest) (mapping, error) {
+	a := attest.Attested
+	switch attest.Type {
+	case tpm2.TagAttestCertify:
+		return mapping{{key: "certify", value: mapping{
+			{key: "name", value: hex.EncodeToString(a.Certify.Name)},
+			{key: "qualifiedName", value: hex.EncodeToString(a.Certify.QualifiedName)}}}}, nil
+	case tpm2.TagAttestCreation:
+		return mapping{{key: "creation", value: mapping{
+			{key: "objectName", value: hex.EncodeToString(a.Creation.ObjectName)},
+			{key: "creationHash", value: hex.EncodeToString(a.Creation.CreationHash)}}}}, nil
+	case tpm2.TagAttestQuote:
+		pcrSelect, err := pcrSelectionListNode(a.Quote.PCRSelect)
+		if err != nil {
+			return nil, err
+		}
+		return mapping{{key: "quote", value: mapping{
+			{key: "pcrSelect", value: pcrSelect},
+			{key: "pcrDigest", value: hex.EncodeToString(a.Quote.PCRDigest)}}}}, nil
+	case tpm2.TagAttestCommandAudit:
+		return mapping{{key: "commandAudit", value: mapping{
+			{key: "auditCounter", value: a.CommandAudit.AuditCounter},
+			{key: "digestAlg", value: hashAlgString(a.CommandAudit.DigestAlg)},
+			{key: "auditDigest", value: hex.EncodeToString(a.CommandAudit.AuditDigest)},
+			{key: "commandDigest", value: hex.EncodeToString(a.CommandAudit.CommandDigest)}}}}, nil
+	case tpm2.TagAttestSessionAudit:
+		var exclusive uint64
+		if a.SessionAudit.ExclusiveSession {
+			exclusive = 1
+		}
+		return mapping{{key: "sessionAudit", value: mapping{
+			{key: "exclusiveSession", value: exclusive},
+			{key: "sessionDigest", value: hex.EncodeToString(a.SessionAudit.SessionDigest)}}}}, nil
+	case tpm2.TagAttestTime:
+		return mapping{{key: "time", value: mapping{
+			{key: "time", value: mapping{
+				{key: "time", value: a.Time.Time.Time},
+				{key: "clockInfo", value: clockInfoNode(&a.Time.Time.ClockInfo)}}},
+			{key: "firmwareVersion", value: fmt.Sprintf("%016x", a.Time.FirmwareVersion)}}}}, nil
+	case tpm2.TagAttestNV:
+		return mapping{{key: "nv", value: mapping{
+			{key: "indexName", value: hex.EncodeToString(a.NV.IndexName)},
+			{key: "offset", value: uint64(a.NV.Offset)},
+			{key: "nvContents", value: hex.EncodeToString(a.NV.NVContents)}}}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported attestation type %v", attest.Type)
+	}
+}
+
+// MarshalAttest encodes the supplied attestation structure in the layout used by
+// tpm2_print -t TPMS_ATTEST.
+func MarshalAttest(attest *tpm2.Attest, format Format) ([]byte, error) {
+	// Round-trip the structure so that the attested union is validated against the type.
+	b, err := mu.MarshalToBytes(attest)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot marshal attestation structure: %w", err)
+	}
+	attest = nil
+	if _, err := mu.UnmarshalFromBytes(b, &attest); err != nil {
+		return nil, xerrors.Errorf("invalid attestation structure: %w", err)
+	}
+
+	attested, err := attestedNode(attest)
+	if err != nil {
+		return nil, err
+	}
+
+	m := mapping{
+		{key: "magic", value: fmt.Sprintf("%08x", uint32(attest.Magic))},
+		{key: "type", value: fmt.Sprintf("%04x", uint16(attest.Type))},
+		{key: "qualifiedSigner", value: hex.EncodeToString(attest.QualifiedSigner)},
+		{key: "extraData", value: hex.EncodeToString(attest.ExtraData)},
+		{key: "clockInfo", value: clockInfoNode(&attest.ClockInfo)},
+		{key: "firmwareVersion", value: fmt.Sprintf("%016x", attest.FirmwareVersion)},
+		{key: "attested", value: attested}}
+	return encode(m, format, 0)
+}
+
+// MarshalCreationData encodes the supplied creation data. The PCR selection uses the same
+// layout as the PCR selection of a quote in tpm2_print -t TPMS_ATTEST, and the other fields
+// use the names from the TPMS_CREATION_DATA structure.
+func MarshalCreationData(data *tpm2.CreationData, format Format) ([]byte, error) {
+	pcrSelect, err := pcrSelectionListNode(data.PCRSelect)
+	if err != nil {
+		return nil, err
+	}
+
+	m := mapping{
+		{key: "pcrSelect", value: pcrSelect},
+		{key: "pcrDigest", value: hex.EncodeToString(data.PCRDigest)},
+		{key: "locality", value: algValue(localityNames.format(uint32(data.Locality)), uint32(data.Locality))},
+		{key: "parentNameAlg", value: algNode(data.ParentNameAlg)},
+		{key: "parentName", value: hex.EncodeToString(data.ParentName)},
+		{key: "parentQualifiedName", value: hex.EncodeToString(data.ParentQualifiedName)},
+		{key: "outsideInfo", value: hex.EncodeToString(data.OutsideInfo)}}
+	return encode(m, format, 0)
+}
+
+type pcrSelectionDoc struct {
+	Hash         string `yaml:"hash" json:"hash"`
+	SizeofSelect *uint8 `yaml:"sizeofSelect" json:"sizeofSelect"`
+	PCRSelect    string `yaml:"pcrSelect" json:"pcrSelect"`
+}
+
+type pcrSelectionListDoc struct {
+	Count         *uint32                     `yaml:"count" json:"count"`
+	PCRSelections map[string]*pcrSelectionDoc `yaml:"pcrSelections" json:"pcrSelections"`
+}
+
+func (d *pcrSelectionListDoc) selections() (tpm2.PCRSelectionList, error) {
+	if d == nil {
+		return nil, errors.New("missing pcrSelect")
+	}
+
+	type indexedSelection struct {
+		index     int
+		selection tpm2.PCRSelection
+	}
+	var selections []indexedSelection
+
+	for key, s := range d.PCRSelections {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid pcrSelections key %q", key)
+		}
+		if s == nil {
+			return nil, fmt.Errorf("invalid pcrSelections %d: no selection", i)
+		}
+		hashAlg, err := parseHashAlgString(s.Hash)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid pcrSelections %d hash: %w", i, err)
+		}
+		bitmap, err := decodeHex(fmt.Sprintf("pcrSelections %d pcrSelect", i), s.PCRSelect)
+		if err != nil {
+			return nil, err
+		}
+		if len(bitmap) > 0xff {
+			return nil, fmt.Errorf("invalid pcrSelections %d pcrSelect: too large", i)
+		}
+		if s.SizeofSelect != nil && int(*s.SizeofSelect) != len(bitmap) {
+			return nil, fmt.Errorf("invalid pcrSelections %d: sizeofSelect is inconsistent with pcrSelect", i)
+		}
+
+		var sel tpm2.PCRSelect
+		if _, err := mu.UnmarshalFromBytes(append([]byte{uint8(len(bitmap))}, bitmap...), &sel); err != nil {
+			return nil, xerrors.Errorf("invalid pcrSelections %d pcrSelect: %w", i, err)
+		}
+		selections = append(selections, indexedSelection{
+			index:     i,
+			selection: tpm2.PCRSelection{Hash: tpm2.HashAlgorithmId(hashAlg), Select: sel}})
+	}
+
+	if d.Count != nil && int(*d.Count) != len(selections) {
+		return nil, errors.New("invalid pcrSelect: count is inconsistent with pcrSelections")
+	}
+
+	sort.Slice(selections, func(i, j int) bool { return selections[i].index < selections[j].index })
+	out := make(tpm2.PCRSelectionList, 0, len(selections))
+	for _, s := range selections {
+		out = append(out, s.selection)
+	}
+	return out, nil
+}
+
+type creationDataDoc struct {
+	PCRSelect           *pcrSelectionListDoc `yaml:"pcrSelect" json:"pcrSelect"`
+	PCRDigest           string               `yaml:"pcrDigest" json:"pcrDigest"`
+	Locality            *valueField          `yaml:"locality" json:"locality"`
+	ParentNameAlg       *valueField          `yaml:"parentNameAlg" json:"parentNameAlg"`
+	ParentName          string               `yaml:"parentName" json:"parentName"`
+	ParentQualifiedName string               `yaml:"parentQualifiedName" json:"parentQualifiedName"`
+	OutsideInfo         string               `yaml:"outsideInfo" json:"outsideInfo"`
+}
+
+// UnmarshalCreationData decodes creation data from the layout produced by
+// MarshalCreationData.
+func UnmarshalCreationData(data []byte, format Format) (*tpm2.CreationData, error) {
+	var d creationDataDoc
+	if err := decode(data, format, &d); err != nil {
+		return nil, xerrors.Errorf("cannot decode %v: %w", format, err)
+	}
+
+	pcrSelect, err := d.PCRSelect.selections()
+	if err != nil {
+		return nil, err
+	}
+	pcrDigest, err := decodeHex("pcrDigest", d.PCRDigest)
+	if err != nil {
+		return nil, err
+	}
+	locality, err := d.Locality.attrs("locality", localityNames)
+	if err != nil {
+		return nil, err
+	}
+	if locality > 0xff {
+		return nil, errors.New("invalid locality: out of range")
+	}
+	parentNameAlg, err := d.ParentNameAlg.alg("parentNameAlg")
+	if err != nil {
+		return nil, err
+	}
+	parentName, err := decodeHex("parentName", d.ParentName)
+	if err != nil {
+		return nil, err
+	}
+	parentQualifiedName, err := decodeHex("parentQualifiedName", d.ParentQualifiedName)
+	if err != nil {
+		return nil, err
+	}
+	outsideInfo, err := decodeHex("outsideInfo", d.OutsideInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tpm2.CreationData{
+		PCRSelect:           pcrSelect,
+		PCRDigest:           pcrDigest,
+		Locality:            tpm2.Locality(locality),
+		ParentNameAlg:       parentNameAlg,
+		ParentName:          parentName,
+		ParentQualifiedName: parentQualifiedName,
+		OutsideInfo:         outsideInfo}, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/testutil"
+	. "github.com/canonical/go-tpm2/tpm2tools"
+)
+
+type attestSuite struct{}
+
+var _ = Suite(&attestSuite{})
+
+func (s *attestSuite) TestMarshalQuoteYAML(c *C) {
+	data, err := MarshalAttest(&tpm2.Attest{
+		Magic:           tpm2.TPMGeneratedValue,
+		Type:            tpm2.TagAttestQuote,
+		QualifiedSigner: testutil.DecodeHexString(c, "000b0102"),
+		ExtraData:       []byte("foo"),
+		ClockInfo:       tpm2.ClockInfo{Clock: 1000, ResetCount: 2, RestartCount: 3, Safe: true},
+		FirmwareVersion: 0x1234567890,
+		Attested: &tpm2.AttestU{
+			Quote: &tpm2.QuoteInfo{
+				PCRSelect: tpm2.PCRSelectionList{
+					{Hash: tpm2.HashAlgorithmSHA1, Select: []int{0}},
+					{Hash: tpm2.HashAlgorithmSHA256, Select: []int{0, 7, 23}}},
+				PCRDigest: testutil.DecodeHexString(c, "a0b1c2d3")}}}, YAML)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `magic: ff544347
+type: 8018
+qualifiedSigner: 000b0102
+extraData: 666f6f
+clockInfo:
+  clock: 1000
+  resetCount: 2
+  restartCount: 3
+  safe: 1
+firmwareVersion: 0000001234567890
+attested:
+  quote:
+    pcrSelect:
+      count: 2
+      pcrSelections:
+        0:
+          hash: 4 (sha1)
+          sizeofSelect: 3
+          pcrSelect: 010000
+        1:
+          hash: 11 (sha256)
+          sizeofSelect: 3
+          pcrSelect: 810080
+    pcrDigest: a0b1c2d3
+`)
+}
+
+func (s *attestSuite) TestMarshalCertifyJSON(c *C) {
+	data, err := MarshalAttest(&tpm2.Attest{
+		Magic:           tpm2.TPMGeneratedValue,
+		Type:            tpm2.TagAttestCertify,
+		QualifiedSigner: testutil.DecodeHexString(c, "000b0102"),
+		Attested: &tpm2.AttestU{
+			Certify: &tpm2.CertifyInfo{
+				Name:          testutil.DecodeHexString(c, "000b0304"),
+				QualifiedName: testutil.DecodeHexString(c, "000b0506")}}}, JSON)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `{
+  "magic": "ff544347",
+  "type": "8017",
+  "qualifiedSigner": "000b0102",
+  "extraData": "",
+  "clockInfo": {
+    "clock": 0,
+    "resetCount": 0,
+    "restartCount": 0,
+    "safe": 0
+  },
+  "firmwareVersion": "0000000000000000",
+  "attested": {
+    "certify": {
+      "name": "000b0304",
+      "qualifiedName": "000b0506"
+    }
+  }
+}
+`)
+}
+
+func (s *attestSuite) TestMarshalInvalidType(c *C) {
+	_, err := MarshalAttest(&tpm2.Attest{Type: tpm2.TagNoSessions, Attested: new(tpm2.AttestU)}, YAML)
+	c.Check(err, ErrorMatches, `(?s)invalid attestation structure: .*invalid selector value: 32769.*`)
+}
+
+func (s *attestSuite) TestCreationDataRoundTrip(c *C) {
+	data := &tpm2.CreationData{
+		PCRSelect:           tpm2.PCRSelectionList{{Hash: tpm2.HashAlgorithmSHA256, Select: []int{0, 7}}, {Hash: tpm2.HashAlgorithmSHA1, Select: []int{4}}},
+		PCRDigest:           testutil.DecodeHexString(c, "a0b1c2d3"),
+		Locality:            tpm2.Locality(0x5),
+		ParentNameAlg:       tpm2.AlgorithmSHA256,
+		ParentName:          tpm2.Name{0x40, 0x00, 0x00, 0x01},
+		ParentQualifiedName: tpm2.Name{0x40, 0x00, 0x00, 0x01},
+		OutsideInfo:         []byte("bar")}
+
+	for _, format := range []Format{YAML, JSON} {
+		b, err := MarshalCreationData(data, format)
+		c.Assert(err, IsNil)
+
+		decoded, err := UnmarshalCreationData(b, format)
+		c.Check(err, IsNil, Commentf("format: %v\n%s", format, b))
+		c.Check(mu.MustMarshalToBytes(decoded), DeepEquals, mu.MustMarshalToBytes(data), Commentf("format: %v", format))
+	}
+}
+
+func (s *attestSuite) TestMarshalCreationDataYAML(c *C) {
+	data, err := MarshalCreationData(&tpm2.CreationData{
+		PCRSelect:           tpm2.PCRSelectionList{{Hash: tpm2.HashAlgorithmSHA256, Select: []int{0, 7}}},
+		PCRDigest:           testutil.DecodeHexString(c, "a0b1c2d3"),
+		Locality:            tpm2.Locality(0x1),
+		ParentNameAlg:       tpm2.AlgorithmNull,
+		ParentName:          tpm2.Name{0x40, 0x00, 0x00, 0x01},
+		ParentQualifiedName: tpm2.Name{0x40, 0x00, 0x00, 0x01}}, YAML)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `pcrSelect:
+  count: 1
+  pcrSelections:
+    0:
+      hash: 11 (sha256)
+      sizeofSelect: 3
+      pcrSelect: 810000
+pcrDigest: a0b1c2d3
+locality:
+  value: zero
+  raw: 0x1
+parentNameAlg:
+  value: null
+  raw: 0x10
+parentName: 40000001
+parentQualifiedName: 40000001
+outsideInfo:
+`)
+}
+
+func (s *attestSuite) TestUnmarshalCreationDataInconsistentCount(c *C) {
+	_, err := UnmarshalCreationData([]byte(`
+pcrSelect:
+  count: 2
+  pcrSelections:
+    0:
+      hash: 11 (sha256)
+      pcrSelect: 810000
+pcrDigest: a0b1c2d3
+locality:
+  value: zero
+parentNameAlg:
+  value: null
+`), YAML)
+	c.Check(err, ErrorMatches, `invalid pcrSelect: count is inconsistent with pcrSelections`)
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/canonical/go-tpm2"
+)
+
+func bit(v uint32, n uint) uint64 {
+	return uint64((v >> n) & 1)
+}
+
+func algorithmPropertiesNode(props tpm2.AlgorithmPropertyList) mapping {
+	var m mapping
+	for _, p := range props {
+		attrs := uint32(p.Properties)
+		m.add(algName(p.Alg), mapping{
+			{key: "value", value: fmt.Sprintf("0x%x", uint16(p.Alg))},
+			{key: "asymmetric", value: bit(attrs, 0)},
+			{key: "symmetric", value: bit(attrs, 1)},
+			{key: "hash", value: bit(attrs, 2)},
+			{key: "object", value: bit(attrs, 3)},
+			{key: "reserved", value: fmt.Sprintf("0x%x", (attrs>>4)&0xf)},
+			{key: "signing", value: bit(attrs, 8)},
+			{key: "encrypting", value: bit(attrs, 9)},
+			{key: "method", value: bit(attrs, 10)}})
+	}
+	return m
+}
+
+func commandAttributesNode(cmds tpm2.CommandAttributesList) mapping {
+	var m mapping
+	for _, c := range cmds {
+		attrs := uint32(c)
+		m.add(commandName(c.CommandCode()), mapping{
+			{key: "value", value: fmt.Sprintf("0x%X", attrs)},
+			{key: "commandIndex", value: fmt.Sprintf("0x%x", attrs&0xffff)},
+			{key: "reserved1", value: fmt.Sprintf("0x%x", (attrs>>16)&0x3f)},
+			{key: "nv", value: bit(attrs, 22)},
+			{key: "extensive", value: bit(attrs, 23)},
+			{key: "flushed", value: bit(attrs, 24)},
+			{key: "cHandles", value: fmt.Sprintf("0x%x", (attrs>>25)&0x7)},
+			{key: "rHandle", value: bit(attrs, 28)},
+			{key: "V", value: bit(attrs, 29)},
+			{key: "Res", value: fmt.Sprintf("0x%x", (attrs>>30)&0x3)}})
+	}
+	return m
+}
+
+func commandCodesNode(cmds tpm2.CommandCodeList) mapping {
+	var m mapping
+	for _, c := range cmds {
+		m.add(commandName(c), fmt.Sprintf("0x%X", uint32(c)))
+	}
+	return m
+}
+
+func handlesNode(handles tpm2.HandleList) sequence {
+	var s sequence
+	for _, h := range handles {
+		s = append(s, fmt.Sprintf("0x%x", uint32(h)))
+	}
+	return s
+}
+
+func pcrSelectionsNode(pcrs tpm2.PCRSelectionList) mapping {
+	var s sequence
+	for _, p := range pcrs {
+		var selected flowSequence
+		for _, i := range p.Select {
+			selected = append(selected, uint64(i))
+		}
+		s = append(s, mapping{{key: algName(tpm2.AlgorithmId(p.Hash)), value: selected}})
+	}
+	return mapping{{key: "selected-pcrs", value: s}}
+}
+
+// isStringProperty indicates whether the specified property contains
+// 4 ASCII characters.
+func isStringProperty(p tpm2.Property) bool {
+	switch p {
+	case tpm2.PropertyFamilyIndicator, tpm2.PropertyManufacturer,
+		tpm2.PropertyVendorString1, tpm2.PropertyVendorString2,
+		tpm2.PropertyVendorString3, tpm2.PropertyVendorString4:
+		return true
+	default:
+		return false
+	}
+}
+
+func tpmPropertiesNode(props tpm2.TaggedTPMPropertyList) mapping {
+	var m mapping
+	for _, p := range props {
+		node := mapping{{key: "raw", value: fmt.Sprintf("0x%X", p.Value)}}
+		if isStringProperty(p.Property) {
+			var b [4]byte
+			binary.BigEndian.PutUint32(b[:], p.Value)
+			node.add("value", quotedString(strings.TrimRight(string(b[:]), "\x00")))
+		}
+		m.add(propertyName(p.Property), node)
+	}
+	return m
+}
+
+func pcrPropertiesNode(props tpm2.TaggedPCRPropertyList) mapping {
+	var m mapping
+	for _, p := range props {
+		selected := flowSequence{}
+		for _, i := range p.Select {
+			selected = append(selected, uint64(i))
+		}
+		m.add(pcrPropertyName(p.Tag), selected)
+	}
+	return m
+}
+
+func eccCurvesNode(curves tpm2.ECCCurveList) mapping {
+	var m mapping
+	for _, c := range curves {
+		m.add(curveIdName(c), fmt.Sprintf("0x%x", uint16(c)))
+	}
+	return m
+}
+
+func authPoliciesNode(policies tpm2.TaggedPolicyList) mapping {
+	var m mapping
+	for _, p := range policies {
+		m.add(fmt.Sprintf("0x%x", uint32(p.Handle)), mapping{
+			{key: "hash-alg", value: algNode(tpm2.AlgorithmId(p.PolicyHash.HashAlg))},
+			{key: "digest", value: hex.EncodeToString(p.PolicyHash.Digest)}})
+	}
+	return m
+}
+
+// MarshalCapabilityData encodes the supplied capability data in the layout used by
+// tpm2_getcap for the corresponding capability.
+func MarshalCapabilityData(data *tpm2.CapabilityData, format Format) ([]byte, error) {
+	if data.Data == nil {
+		return nil, fmt.Errorf("no data for capability %v", data.Capability)
+	}
+
+	var root interface{}
+	switch data.Capability {
+	case tpm2.CapabilityAlgs:
+		root = algorithmPropertiesNode(data.Data.Algorithms)
+	case tpm2.CapabilityHandles:
+		root = handlesNode(data.Data.Handles)
+	case tpm2.CapabilityCommands:
+		root = commandAttributesNode(data.Data.Command)
+	case tpm2.CapabilityPPCommands:
+		root = commandCodesNode(data.Data.PPCommands)
+	case tpm2.CapabilityAuditCommands:
+		root = commandCodesNode(data.Data.AuditCommands)
+	case tpm2.CapabilityPCRs:
+		root = pcrSelectionsNode(data.Data.AssignedPCR)
+	case tpm2.CapabilityTPMProperties:
+		root = tpmPropertiesNode(data.Data.TPMProperties)
+	case tpm2.CapabilityPCRProperties:
+		root = pcrPropertiesNode(data.Data.PCRProperties)
+	case tpm2.CapabilityECCCurves:
+		root = eccCurvesNode(data.Data.ECCCurves)
+	case tpm2.CapabilityAuthPolicies:
+		root = authPoliciesNode(data.Data.AuthPolicies)
+	default:
+		return nil, fmt.Errorf("unsupported capability %v", data.Capability)
+	}
+	return encode(root, format, 0)
+}
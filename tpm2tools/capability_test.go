@@ -0,0 +1,139 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	. "github.com/canonical/go-tpm2/tpm2tools"
+)
+
+type capabilitySuite struct{}
+
+var _ = Suite(&capabilitySuite{})
+
+func (s *capabilitySuite) testMarshal(c *C, data *tpm2.CapabilityData, format Format, expected string) {
+	b, err := MarshalCapabilityData(data, format)
+	c.Check(err, IsNil)
+	c.Check(string(b), Equals, expected)
+}
+
+func (s *capabilitySuite) TestAlgorithms(c *C) {
+	s.testMarshal(c, &tpm2.CapabilityData{
+		Capability: tpm2.CapabilityAlgs,
+		Data: &tpm2.CapabilitiesU{
+			Algorithms: tpm2.AlgorithmPropertyList{
+				{Alg: tpm2.AlgorithmRSA, Properties: tpm2.AttrAsymmetric | tpm2.AttrObject},
+				{Alg: tpm2.AlgorithmSHA256, Properties: tpm2.AttrHash}}}}, YAML, `rsa:
+  value: 0x1
+  asymmetric: 1
+  symmetric: 0
+  hash: 0
+  object: 1
+  reserved: 0x0
+  signing: 0
+  encrypting: 0
+  method: 0
+sha256:
+  value: 0xb
+  asymmetric: 0
+  symmetric: 0
+  hash: 1
+  object: 0
+  reserved: 0x0
+  signing: 0
+  encrypting: 0
+  method: 0
+`)
+}
+
+func (s *capabilitySuite) TestCommands(c *C) {
+	s.testMarshal(c, &tpm2.CapabilityData{
+		Capability: tpm2.CapabilityCommands,
+		Data: &tpm2.CapabilitiesU{
+			Command: tpm2.CommandAttributesList{
+				tpm2.CommandAttributes(tpm2.CommandNVUndefineSpaceSpecial) | tpm2.AttrNV | (2 << 25)}}}, YAML, `TPM2_CC_NV_UndefineSpaceSpecial:
+  value: 0x440011F
+  commandIndex: 0x11f
+  reserved1: 0x0
+  nv: 1
+  extensive: 0
+  flushed: 0
+  cHandles: 0x2
+  rHandle: 0
+  V: 0
+  Res: 0x0
+`)
+}
+
+func (s *capabilitySuite) TestHandles(c *C) {
+	s.testMarshal(c, &tpm2.CapabilityData{
+		Capability: tpm2.CapabilityHandles,
+		Data:       &tpm2.CapabilitiesU{Handles: tpm2.HandleList{0x81000001, 0x81000002}}}, YAML, `- 0x81000001
+- 0x81000002
+`)
+}
+
+func (s *capabilitySuite) TestPCRs(c *C) {
+	s.testMarshal(c, &tpm2.CapabilityData{
+		Capability: tpm2.CapabilityPCRs,
+		Data: &tpm2.CapabilitiesU{
+			AssignedPCR: tpm2.PCRSelectionList{
+				{Hash: tpm2.HashAlgorithmSHA1, Select: []int{0, 1, 2}},
+				{Hash: tpm2.HashAlgorithmSHA256, Select: []int{0, 1, 2}}}}}, YAML, `selected-pcrs:
+  - sha1: [ 0, 1, 2 ]
+  - sha256: [ 0, 1, 2 ]
+`)
+}
+
+func (s *capabilitySuite) TestPCRsJSON(c *C) {
+	s.testMarshal(c, &tpm2.CapabilityData{
+		Capability: tpm2.CapabilityPCRs,
+		Data: &tpm2.CapabilitiesU{
+			AssignedPCR: tpm2.PCRSelectionList{
+				{Hash: tpm2.HashAlgorithmSHA256, Select: []int{0, 1}}}}}, JSON, `{
+  "selected-pcrs": [
+    {
+      "sha256": [
+        0,
+        1
+      ]
+    }
+  ]
+}
+`)
+}
+
+func (s *capabilitySuite) TestTPMProperties(c *C) {
+	s.testMarshal(c, &tpm2.CapabilityData{
+		Capability: tpm2.CapabilityTPMProperties,
+		Data: &tpm2.CapabilitiesU{
+			TPMProperties: tpm2.TaggedTPMPropertyList{
+				{Property: tpm2.PropertyFamilyIndicator, Value: 0x322e3000},
+				{Property: tpm2.PropertyLevel, Value: 0},
+				{Property: tpm2.PropertyManufacturer, Value: 0x49424d00}}}}, YAML, `TPM2_PT_FAMILY_INDICATOR:
+  raw: 0x322E3000
+  value: "2.0"
+TPM2_PT_LEVEL:
+  raw: 0x0
+TPM2_PT_MANUFACTURER:
+  raw: 0x49424D00
+  value: "IBM"
+`)
+}
+
+func (s *capabilitySuite) TestECCCurves(c *C) {
+	s.testMarshal(c, &tpm2.CapabilityData{
+		Capability: tpm2.CapabilityECCCurves,
+		Data:       &tpm2.CapabilitiesU{ECCCurves: tpm2.ECCCurveList{tpm2.ECCCurveNIST_P256, tpm2.ECCCurveBN_P256}}}, YAML, `TPM2_ECC_NIST_P256: 0x3
+TPM2_ECC_BN_P256: 0x10
+`)
+}
+
+func (s *capabilitySuite) TestUnsupported(c *C) {
+	_, err := MarshalCapabilityData(&tpm2.CapabilityData{Capability: tpm2.Capability(0x100), Data: new(tpm2.CapabilitiesU)}, YAML)
+	c.Check(err, ErrorMatches, `unsupported capability .*`)
+}
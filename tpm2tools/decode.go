@@ -0,0 +1,112 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/xerrors"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// decode unmarshals data in the specified format to v, which is a pointer to a
+// structure with yaml and json tags. Unknown fields are rejected so that mistakes
+// in handwritten input are detected.
+func decode(data []byte, format Format, v interface{}) error {
+	switch format {
+	case YAML:
+		return yaml.UnmarshalStrict(data, v)
+	case JSON:
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		return d.Decode(v)
+	default:
+		return fmt.Errorf("invalid format %v", format)
+	}
+}
+
+func decodeHex(field, s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid %s: %w", field, err)
+	}
+	return b, nil
+}
+
+// valueField corresponds to the value/raw pair that tpm2-tools uses to represent
+// algorithms and attributes.
+type valueField struct {
+	Value string `yaml:"value" json:"value"`
+	Raw   string `yaml:"raw" json:"raw"`
+}
+
+// resolve returns the raw value of this field. If both the value and raw value are
+// supplied, they must be consistent. The name of the field is used in errors.
+func (f *valueField) resolve(field string, parse func(string) (uint32, error), format func(uint32) string) (uint32, error) {
+	switch {
+	case f == nil || (f.Value == "" && f.Raw == ""):
+		return 0, fmt.Errorf("missing %s", field)
+	case f.Raw != "":
+		raw, err := strconv.ParseUint(f.Raw, 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: cannot parse raw value: %v", field, err)
+		}
+		if f.Value != "" && f.Value != format(uint32(raw)) {
+			return 0, fmt.Errorf("invalid %s: value %q is inconsistent with raw value %s", field, f.Value, f.Raw)
+		}
+		return uint32(raw), nil
+	default:
+		v, err := parse(f.Value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %v", field, err)
+		}
+		return v, nil
+	}
+}
+
+func parseAlg(s string) (uint32, error) {
+	if s == nullName {
+		return 0, nil
+	}
+	alg, ok := algNames[s]
+	if !ok {
+		return 0, fmt.Errorf("unrecognized algorithm %q", s)
+	}
+	return uint32(alg), nil
+}
+
+func formatAlg(v uint32) string {
+	return algName(tpm2.AlgorithmId(v))
+}
+
+// alg returns the algorithm described by this field.
+func (f *valueField) alg(field string) (tpm2.AlgorithmId, error) {
+	v, err := f.resolve(field, parseAlg, formatAlg)
+	return tpm2.AlgorithmId(v), err
+}
+
+// optionalAlg returns the algorithm described by this field, or AlgorithmNull if
+// it is not supplied.
+func (f *valueField) optionalAlg(field string) (tpm2.AlgorithmId, error) {
+	if f == nil {
+		return tpm2.AlgorithmNull, nil
+	}
+	return f.alg(field)
+}
+
+// attrs returns the attributes described by this field.
+func (f *valueField) attrs(field string, names attributeNames) (uint32, error) {
+	return f.resolve(field, names.parse, names.format)
+}
@@ -0,0 +1,15 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package tpm2tools renders TPM structures in the YAML layout produced by the tpm2-tools utilities
+(tpm2_readpublic, tpm2_nvreadpublic, tpm2_print, tpm2_getcap and tpm2_pcrread), so that output
+can be compared directly. The same structure and field names can also be encoded as JSON.
+
+Algorithms and attributes are rendered with both their name and their numeric value. Where
+practical, decoders are provided for the same layout. These accept either the name or the
+numeric value of an algorithm or attribute, which permits object templates to be written by
+hand as YAML.
+*/
+package tpm2tools
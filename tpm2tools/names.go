@@ -0,0 +1,274 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// nullName is the name that tpm2-tools prints for an unrecognized algorithm.
+const nullName = "(null)"
+
+// algNames maps tpm2-tools algorithm names to algorithm IDs. All of the algorithms defined by the
+// TCG have an ID less than 0x100.
+var algNames = func() map[string]tpm2.AlgorithmId {
+	names := make(map[string]tpm2.AlgorithmId)
+	for i := 0; i < 0x100; i++ {
+		alg := tpm2.AlgorithmId(i)
+		if name := algName(alg); name != nullName {
+			names[name] = alg
+		}
+	}
+	return names
+}()
+
+// algName returns the tpm2-tools name of the specified algorithm.
+func algName(alg tpm2.AlgorithmId) string {
+	s := alg.String()
+	if !strings.HasPrefix(s, "TPM_ALG_") || alg == tpm2.AlgorithmError {
+		return nullName
+	}
+	return strings.ToLower(strings.TrimPrefix(s, "TPM_ALG_"))
+}
+
+var curveNames = map[tpm2.ECCCurve]string{
+	tpm2.ECCCurveNIST_P192: "NIST p192",
+	tpm2.ECCCurveNIST_P224: "NIST p224",
+	tpm2.ECCCurveNIST_P256: "NIST p256",
+	tpm2.ECCCurveNIST_P384: "NIST p384",
+	tpm2.ECCCurveNIST_P521: "NIST p521",
+	tpm2.ECCCurveBN_P256:   "BN P256",
+	tpm2.ECCCurveBN_P638:   "BN P638",
+	tpm2.ECCCurveSM2_P256:  "SM2 P256",
+}
+
+var curveIdNames = map[tpm2.ECCCurve]string{
+	tpm2.ECCCurveNIST_P192: "TPM2_ECC_NIST_P192",
+	tpm2.ECCCurveNIST_P224: "TPM2_ECC_NIST_P224",
+	tpm2.ECCCurveNIST_P256: "TPM2_ECC_NIST_P256",
+	tpm2.ECCCurveNIST_P384: "TPM2_ECC_NIST_P384",
+	tpm2.ECCCurveNIST_P521: "TPM2_ECC_NIST_P521",
+	tpm2.ECCCurveBN_P256:   "TPM2_ECC_BN_P256",
+	tpm2.ECCCurveBN_P638:   "TPM2_ECC_BN_P638",
+	tpm2.ECCCurveSM2_P256:  "TPM2_ECC_SM2_P256",
+}
+
+func curveName(curve tpm2.ECCCurve) string {
+	if name, ok := curveNames[curve]; ok {
+		return name
+	}
+	return nullName
+}
+
+func curveIdName(curve tpm2.ECCCurve) string {
+	if name, ok := curveIdNames[curve]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", uint16(curve))
+}
+
+func commandName(code tpm2.CommandCode) string {
+	s := code.String()
+	if !strings.HasPrefix(s, "TPM_CC_") {
+		return fmt.Sprintf("0x%x", uint32(code))
+	}
+	return "TPM2_" + strings.TrimPrefix(s, "TPM_")
+}
+
+// attributeNames describes the names of the bits in an attribute type.
+type attributeNames map[uint]string
+
+// format returns a '|' separated list of the names of the bits set in attrs.
+func (n attributeNames) format(attrs uint32) string {
+	var names []string
+	for i := uint(0); i < 32; i++ {
+		if attrs&(1<<i) == 0 {
+			continue
+		}
+		name, ok := n[i]
+		if !ok {
+			name = fmt.Sprintf("<reserved(%d)>", i)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "|")
+}
+
+// parse parses a '|' separated list of attribute names.
+func (n attributeNames) parse(s string) (uint32, error) {
+	var attrs uint32
+	for _, name := range strings.Split(s, "|") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for bit, candidate := range n {
+			if candidate == name {
+				attrs |= 1 << bit
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unrecognized attribute %q", name)
+		}
+	}
+	return attrs, nil
+}
+
+var objectAttributeNames = attributeNames{
+	1:  "fixedtpm",
+	2:  "stclear",
+	4:  "fixedparent",
+	5:  "sensitivedataorigin",
+	6:  "userwithauth",
+	7:  "adminwithpolicy",
+	10: "noda",
+	11: "encryptedduplication",
+	16: "restricted",
+	17: "decrypt",
+	18: "sign",
+	19: "x509sign",
+}
+
+// nvAttributeNames excludes the bits used to encode the index type, which are
+// handled separately.
+var nvAttributeNames = attributeNames{
+	0:  "ppwrite",
+	1:  "ownerwrite",
+	2:  "authwrite",
+	3:  "policywrite",
+	10: "policy_delete",
+	11: "writelocked",
+	12: "writeall",
+	13: "writedefine",
+	14: "write_stclear",
+	15: "globallock",
+	16: "ppread",
+	17: "ownerread",
+	18: "authread",
+	19: "policyread",
+	25: "no_da",
+	26: "orderly",
+	27: "clear_stclear",
+	28: "readlocked",
+	29: "written",
+	30: "platformcreate",
+	31: "read_stclear",
+}
+
+var localityNames = attributeNames{
+	0: "zero",
+	1: "one",
+	2: "two",
+	3: "three",
+	4: "four",
+}
+
+func propertyName(p tpm2.Property) string {
+	if name, ok := propertyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", uint32(p))
+}
+
+func pcrPropertyName(p tpm2.PropertyPCR) string {
+	if name, ok := pcrPropertyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", uint32(p))
+}
+
+var propertyNames = map[tpm2.Property]string{
+	tpm2.PropertyFamilyIndicator:   "TPM2_PT_FAMILY_INDICATOR",
+	tpm2.PropertyLevel:             "TPM2_PT_LEVEL",
+	tpm2.PropertyRevision:          "TPM2_PT_REVISION",
+	tpm2.PropertyDayOfYear:         "TPM2_PT_DAY_OF_YEAR",
+	tpm2.PropertyYear:              "TPM2_PT_YEAR",
+	tpm2.PropertyManufacturer:      "TPM2_PT_MANUFACTURER",
+	tpm2.PropertyVendorString1:     "TPM2_PT_VENDOR_STRING_1",
+	tpm2.PropertyVendorString2:     "TPM2_PT_VENDOR_STRING_2",
+	tpm2.PropertyVendorString3:     "TPM2_PT_VENDOR_STRING_3",
+	tpm2.PropertyVendorString4:     "TPM2_PT_VENDOR_STRING_4",
+	tpm2.PropertyVendorTPMType:     "TPM2_PT_VENDOR_TPM_TYPE",
+	tpm2.PropertyFirmwareVersion1:  "TPM2_PT_FIRMWARE_VERSION_1",
+	tpm2.PropertyFirmwareVersion2:  "TPM2_PT_FIRMWARE_VERSION_2",
+	tpm2.PropertyInputBuffer:       "TPM2_PT_INPUT_BUFFER",
+	tpm2.PropertyHRTransientMin:    "TPM2_PT_HR_TRANSIENT_MIN",
+	tpm2.PropertyHRPersistentMin:   "TPM2_PT_HR_PERSISTENT_MIN",
+	tpm2.PropertyHRLoadedMin:       "TPM2_PT_HR_LOADED_MIN",
+	tpm2.PropertyActiveSessionsMax: "TPM2_PT_ACTIVE_SESSIONS_MAX",
+	tpm2.PropertyPCRCount:          "TPM2_PT_PCR_COUNT",
+	tpm2.PropertyPCRSelectMin:      "TPM2_PT_PCR_SELECT_MIN",
+	tpm2.PropertyContextGapMax:     "TPM2_PT_CONTEXT_GAP_MAX",
+	tpm2.PropertyNVCountersMax:     "TPM2_PT_NV_COUNTERS_MAX",
+	tpm2.PropertyNVIndexMax:        "TPM2_PT_NV_INDEX_MAX",
+	tpm2.PropertyMemory:            "TPM2_PT_MEMORY",
+	tpm2.PropertyClockUpdate:       "TPM2_PT_CLOCK_UPDATE",
+	tpm2.PropertyContextHash:       "TPM2_PT_CONTEXT_HASH",
+	tpm2.PropertyContextSym:        "TPM2_PT_CONTEXT_SYM",
+	tpm2.PropertyContextSymSize:    "TPM2_PT_CONTEXT_SYM_SIZE",
+	tpm2.PropertyOrderlyCount:      "TPM2_PT_ORDERLY_COUNT",
+	tpm2.PropertyMaxCommandSize:    "TPM2_PT_MAX_COMMAND_SIZE",
+	tpm2.PropertyMaxResponseSize:   "TPM2_PT_MAX_RESPONSE_SIZE",
+	tpm2.PropertyMaxDigest:         "TPM2_PT_MAX_DIGEST",
+	tpm2.PropertyMaxObjectContext:  "TPM2_PT_MAX_OBJECT_CONTEXT",
+	tpm2.PropertyMaxSessionContext: "TPM2_PT_MAX_SESSION_CONTEXT",
+	tpm2.PropertyPSFamilyIndicator: "TPM2_PT_PS_FAMILY_INDICATOR",
+	tpm2.PropertyPSLevel:           "TPM2_PT_PS_LEVEL",
+	tpm2.PropertyPSRevision:        "TPM2_PT_PS_REVISION",
+	tpm2.PropertyPSDayOfYear:       "TPM2_PT_PS_DAY_OF_YEAR",
+	tpm2.PropertyPSYear:            "TPM2_PT_PS_YEAR",
+	tpm2.PropertySplitMax:          "TPM2_PT_SPLIT_MAX",
+	tpm2.PropertyTotalCommands:     "TPM2_PT_TOTAL_COMMANDS",
+	tpm2.PropertyLibraryCommands:   "TPM2_PT_LIBRARY_COMMANDS",
+	tpm2.PropertyVendorCommands:    "TPM2_PT_VENDOR_COMMANDS",
+	tpm2.PropertyNVBufferMax:       "TPM2_PT_NV_BUFFER_MAX",
+	tpm2.PropertyModes:             "TPM2_PT_MODES",
+	tpm2.PropertyMaxCapBuffer:      "TPM2_PT_MAX_CAP_BUFFER",
+	tpm2.PropertyPermanent:         "TPM2_PT_PERMANENT",
+	tpm2.PropertyStartupClear:      "TPM2_PT_STARTUP_CLEAR",
+	tpm2.PropertyHRNVIndex:         "TPM2_PT_HR_NV_INDEX",
+	tpm2.PropertyHRLoaded:          "TPM2_PT_HR_LOADED",
+	tpm2.PropertyHRLoadedAvail:     "TPM2_PT_HR_LOADED_AVAIL",
+	tpm2.PropertyHRActive:          "TPM2_PT_HR_ACTIVE",
+	tpm2.PropertyHRActiveAvail:     "TPM2_PT_HR_ACTIVE_AVAIL",
+	tpm2.PropertyHRTransientAvail:  "TPM2_PT_HR_TRANSIENT_AVAIL",
+	tpm2.PropertyHRPersistent:      "TPM2_PT_HR_PERSISTENT",
+	tpm2.PropertyHRPersistentAvail: "TPM2_PT_HR_PERSISTENT_AVAIL",
+	tpm2.PropertyNVCounters:        "TPM2_PT_NV_COUNTERS",
+	tpm2.PropertyNVCountersAvail:   "TPM2_PT_NV_COUNTERS_AVAIL",
+	tpm2.PropertyAlgorithmSet:      "TPM2_PT_ALGORITHM_SET",
+	tpm2.PropertyLoadedCurves:      "TPM2_PT_LOADED_CURVES",
+	tpm2.PropertyLockoutCounter:    "TPM2_PT_LOCKOUT_COUNTER",
+	tpm2.PropertyMaxAuthFail:       "TPM2_PT_MAX_AUTH_FAIL",
+	tpm2.PropertyLockoutInterval:   "TPM2_PT_LOCKOUT_INTERVAL",
+	tpm2.PropertyLockoutRecovery:   "TPM2_PT_LOCKOUT_RECOVERY",
+	tpm2.PropertyNVWriteRecovery:   "TPM2_PT_NV_WRITE_RECOVERY",
+	tpm2.PropertyAuditCounter0:     "TPM2_PT_AUDIT_COUNTER_0",
+	tpm2.PropertyAuditCounter1:     "TPM2_PT_AUDIT_COUNTER_1",
+}
+
+var pcrPropertyNames = map[tpm2.PropertyPCR]string{
+	tpm2.PropertyPCRSave:        "TPM2_PT_PCR_SAVE",
+	tpm2.PropertyPCRExtendL0:    "TPM2_PT_PCR_EXTEND_L0",
+	tpm2.PropertyPCRResetL0:     "TPM2_PT_PCR_RESET_L0",
+	tpm2.PropertyPCRExtendL1:    "TPM2_PT_PCR_EXTEND_L1",
+	tpm2.PropertyPCRResetL1:     "TPM2_PT_PCR_RESET_L1",
+	tpm2.PropertyPCRExtendL2:    "TPM2_PT_PCR_EXTEND_L2",
+	tpm2.PropertyPCRResetL2:     "TPM2_PT_PCR_RESET_L2",
+	tpm2.PropertyPCRExtendL3:    "TPM2_PT_PCR_EXTEND_L3",
+	tpm2.PropertyPCRResetL3:     "TPM2_PT_PCR_RESET_L3",
+	tpm2.PropertyPCRExtendL4:    "TPM2_PT_PCR_EXTEND_L4",
+	tpm2.PropertyPCRResetL4:     "TPM2_PT_PCR_RESET_L4",
+	tpm2.PropertyPCRNoIncrement: "TPM2_PT_PCR_NO_INCREMENT",
+	tpm2.PropertyPCRDRTMReset:   "TPM2_PT_PCR_DRTM_RESET",
+	tpm2.PropertyPCRPolicy:      "TPM2_PT_PCR_POLICY",
+	tpm2.PropertyPCRAuth:        "TPM2_PT_PCR_AUTH",
+}
@@ -0,0 +1,220 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// Format specifies the format of encoded data.
+type Format int
+
+const (
+	// YAML corresponds to the YAML layout used by the tpm2-tools utilities.
+	YAML Format = iota
+
+	// JSON corresponds to the same structure and field names as YAML, but encoded as JSON.
+	JSON
+)
+
+func (f Format) String() string {
+	switch f {
+	case YAML:
+		return "YAML"
+	case JSON:
+		return "JSON"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// The encoders build a tree from the following types, so that the order of fields is
+// preserved for both YAML and JSON output:
+//  - mapping: an ordered mapping.
+//  - sequence: a block sequence.
+//  - flowSequence: a sequence that is rendered on a single line in YAML.
+//  - string: a scalar that is written to YAML as-is, and as a string to JSON.
+//  - quotedString: a scalar that is written to YAML as a double-quoted string.
+//  - uint64: a scalar that is written to YAML in decimal, and as a number to JSON.
+
+type entry struct {
+	key   string
+	value interface{}
+
+	// yamlKey overrides the key when written to YAML.
+	yamlKey string
+}
+
+type mapping []entry
+
+func (m *mapping) add(key string, value interface{}) {
+	*m = append(*m, entry{key: key, value: value})
+}
+
+type quotedString string
+
+type sequence []interface{}
+
+type flowSequence []interface{}
+
+// algValue returns the value/raw pair that tpm2-tools uses to represent algorithms and
+// attributes.
+func algValue(value string, raw uint32) mapping {
+	return mapping{{key: "value", value: value}, {key: "raw", value: fmt.Sprintf("0x%x", raw)}}
+}
+
+// algNode returns the value/raw pair for the specified algorithm.
+func algNode(alg tpm2.AlgorithmId) mapping {
+	return algValue(algName(alg), uint32(alg))
+}
+
+func writeScalar(w io.Writer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		io.WriteString(w, v)
+	case quotedString:
+		io.WriteString(w, strconv.Quote(string(v)))
+	case uint64:
+		fmt.Fprintf(w, "%d", v)
+	default:
+		panic(fmt.Sprintf("invalid scalar type %T", v))
+	}
+}
+
+func writeYAMLMapping(w io.Writer, m mapping, indent int, first string) {
+	for i, e := range m {
+		prefix := strings.Repeat(" ", indent)
+		if i == 0 && first != "" {
+			prefix = first
+		}
+		key := e.key
+		if e.yamlKey != "" {
+			key = e.yamlKey
+		}
+		fmt.Fprintf(w, "%s%s:", prefix, key)
+		writeYAMLValue(w, e.value, indent)
+	}
+}
+
+// writeYAMLValue writes the value of a mapping entry with the specified indentation,
+// after the key has been written.
+func writeYAMLValue(w io.Writer, v interface{}, indent int) {
+	switch v := v.(type) {
+	case mapping:
+		io.WriteString(w, "\n")
+		writeYAMLMapping(w, v, indent+2, "")
+	case sequence:
+		io.WriteString(w, "\n")
+		writeYAMLSequence(w, v, indent+2)
+	case flowSequence:
+		io.WriteString(w, " [ ")
+		for i, e := range v {
+			if i > 0 {
+				io.WriteString(w, ", ")
+			}
+			writeScalar(w, e)
+		}
+		io.WriteString(w, " ]\n")
+	case string:
+		// Avoid trailing whitespace for empty values.
+		if v != "" {
+			io.WriteString(w, " "+v)
+		}
+		io.WriteString(w, "\n")
+	default:
+		io.WriteString(w, " ")
+		writeScalar(w, v)
+		io.WriteString(w, "\n")
+	}
+}
+
+func writeYAMLSequence(w io.Writer, s sequence, indent int) {
+	prefix := strings.Repeat(" ", indent)
+	for _, e := range s {
+		switch e := e.(type) {
+		case mapping:
+			writeYAMLMapping(w, e, indent+2, prefix+"- ")
+		default:
+			io.WriteString(w, prefix+"- ")
+			writeScalar(w, e)
+			io.WriteString(w, "\n")
+		}
+	}
+}
+
+func writeJSON(w *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case mapping:
+		w.WriteString("{")
+		for i, e := range v {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			writeJSON(w, e.key)
+			w.WriteString(":")
+			writeJSON(w, e.value)
+		}
+		w.WriteString("}")
+	case sequence:
+		writeJSONArray(w, v)
+	case flowSequence:
+		writeJSONArray(w, v)
+	case quotedString:
+		writeJSON(w, string(v))
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			panic(err)
+		}
+		w.Write(b)
+	}
+}
+
+func writeJSONArray(w *bytes.Buffer, s []interface{}) {
+	w.WriteString("[")
+	for i, e := range s {
+		if i > 0 {
+			w.WriteString(",")
+		}
+		writeJSON(w, e)
+	}
+	w.WriteString("]")
+}
+
+// encode renders the supplied tree in the specified format. The top-level YAML
+// mapping or sequence is indented by the specified number of spaces.
+func encode(root interface{}, format Format, indent int) ([]byte, error) {
+	switch format {
+	case YAML:
+		buf := new(bytes.Buffer)
+		switch root := root.(type) {
+		case mapping:
+			writeYAMLMapping(buf, root, indent, "")
+		case sequence:
+			writeYAMLSequence(buf, root, indent)
+		default:
+			panic(fmt.Sprintf("invalid root type %T", root))
+		}
+		return buf.Bytes(), nil
+	case JSON:
+		buf := new(bytes.Buffer)
+		writeJSON(buf, root)
+		out := new(bytes.Buffer)
+		if err := json.Indent(out, buf.Bytes(), "", "  "); err != nil {
+			return nil, err
+		}
+		out.WriteString("\n")
+		return out.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("invalid format %v", format)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2"
+)
+
+var nvTypeNames = map[string]tpm2.NVType{
+	"ordinary": tpm2.NVTypeOrdinary,
+	"counter":  tpm2.NVTypeCounter,
+	"bits":     tpm2.NVTypeBits,
+	"extend":   tpm2.NVTypeExtend,
+	"pinfail":  tpm2.NVTypePinFail,
+	"pinpass":  tpm2.NVTypePinPass,
+}
+
+// formatNVAttrs returns the tpm2-tools representation of the supplied NV attributes. The
+// index type is represented as "nt=<value>" if it is not an ordinary index.
+func formatNVAttrs(v uint32) string {
+	attrs := tpm2.NVAttributes(v)
+	s := nvAttributeNames.format(uint32(attrs.AttrsOnly()))
+	if attrs.Type() == tpm2.NVTypeOrdinary {
+		return s
+	}
+	nt := fmt.Sprintf("nt=0x%x", uint32(attrs.Type()))
+	if s == "" {
+		return nt
+	}
+	return s + "|" + nt
+}
+
+func parseNVAttrs(s string) (uint32, error) {
+	var names []string
+	var nt tpm2.NVType
+	for _, name := range strings.Split(s, "|") {
+		name = strings.TrimSpace(name)
+		if !strings.HasPrefix(name, "nt=") {
+			names = append(names, name)
+			continue
+		}
+		value := strings.TrimPrefix(name, "nt=")
+		if t, ok := nvTypeNames[value]; ok {
+			nt = t
+			continue
+		}
+		t, err := strconv.ParseUint(value, 0, 4)
+		if err != nil {
+			return 0, fmt.Errorf("invalid index type %q", value)
+		}
+		nt = tpm2.NVType(t)
+	}
+	attrs, err := nvAttributeNames.parse(strings.Join(names, "|"))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(nt.WithAttrs(tpm2.NVAttributes(attrs))), nil
+}
+
+// friendlyValue returns the friendly/value pair that tpm2_nvreadpublic uses to represent
+// algorithms and attributes.
+func friendlyValue(friendly string, value uint32) mapping {
+	return mapping{{key: "friendly", value: friendly}, {key: "value", value: fmt.Sprintf("0x%X", value)}}
+}
+
+func nvPublicNode(pub *tpm2.NVPublic) (mapping, error) {
+	var m mapping
+	if pub.NameAlg.Available() {
+		name, err := pub.Name()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot compute name: %w", err)
+		}
+		m.add("name", hex.EncodeToString(name))
+	}
+	m.add("hash algorithm", friendlyValue(algName(tpm2.AlgorithmId(pub.NameAlg)), uint32(pub.NameAlg)))
+	m.add("attributes", friendlyValue(formatNVAttrs(uint32(pub.Attrs)), uint32(pub.Attrs)))
+	m.add("size", uint64(pub.Size))
+	if len(pub.AuthPolicy) > 0 {
+		m.add("authorization policy", hex.EncodeToString(pub.AuthPolicy))
+	}
+	return m, nil
+}
+
+// MarshalNVPublic encodes the supplied NV index public areas in the layout used by
+// tpm2_nvreadpublic, where each index is keyed by its handle. The name of each index is
+// included if its name algorithm is available.
+func MarshalNVPublic(pubs []*tpm2.NVPublic, format Format) ([]byte, error) {
+	var m mapping
+	for _, pub := range pubs {
+		node, err := nvPublicNode(pub)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot encode index %v: %w", pub.Index, err)
+		}
+		m.add(fmt.Sprintf("0x%x", uint32(pub.Index)), node)
+	}
+	return encode(m, format, 0)
+}
+
+// friendlyField corresponds to the friendly/value pair used by tpm2_nvreadpublic.
+type friendlyField struct {
+	Friendly string `yaml:"friendly" json:"friendly"`
+	Value    string `yaml:"value" json:"value"`
+}
+
+func (f *friendlyField) valueField() *valueField {
+	if f == nil {
+		return nil
+	}
+	return &valueField{Value: f.Friendly, Raw: f.Value}
+}
+
+type nvPublicDoc struct {
+	Name       string         `yaml:"name" json:"name"`
+	NameAlg    *friendlyField `yaml:"hash algorithm" json:"hash algorithm"`
+	Attributes *friendlyField `yaml:"attributes" json:"attributes"`
+	Size       *uint16        `yaml:"size" json:"size"`
+	AuthPolicy string         `yaml:"authorization policy" json:"authorization policy"`
+}
+
+func (d *nvPublicDoc) public(index tpm2.Handle) (*tpm2.NVPublic, error) {
+	nameAlg, err := d.NameAlg.valueField().alg("hash algorithm")
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := d.Attributes.valueField().resolve("attributes", parseNVAttrs, formatNVAttrs)
+	if err != nil {
+		return nil, err
+	}
+	if d.Size == nil {
+		return nil, errors.New("missing size")
+	}
+	authPolicy, err := decodeHex("authorization policy", d.AuthPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	pub := &tpm2.NVPublic{
+		Index:      index,
+		NameAlg:    tpm2.HashAlgorithmId(nameAlg),
+		Attrs:      tpm2.NVAttributes(attrs),
+		AuthPolicy: authPolicy,
+		Size:       *d.Size}
+
+	if d.Name != "" && pub.NameAlg.Available() {
+		expected, err := decodeHex("name", d.Name)
+		if err != nil {
+			return nil, err
+		}
+		name, err := pub.Name()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot compute name: %w", err)
+		}
+		if !bytes.Equal(name, expected) {
+			return nil, errors.New("invalid name: name does not match the public area")
+		}
+	}
+
+	return pub, nil
+}
+
+// UnmarshalNVPublic decodes NV index public areas from the layout used by tpm2_nvreadpublic.
+// The returned public areas are sorted by handle. Algorithms and attributes can be supplied as
+// a friendly or raw value or both, in which case they must be consistent. If the name of an
+// index is supplied, it must match the public area.
+func UnmarshalNVPublic(data []byte, format Format) ([]*tpm2.NVPublic, error) {
+	var docs map[string]*nvPublicDoc
+	if err := decode(data, format, &docs); err != nil {
+		return nil, xerrors.Errorf("cannot decode %v: %w", format, err)
+	}
+
+	var pubs []*tpm2.NVPublic
+	for key, d := range docs {
+		index, err := strconv.ParseUint(key, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index %q", key)
+		}
+		if d == nil {
+			return nil, fmt.Errorf("invalid index %s: no public area", key)
+		}
+		pub, err := d.public(tpm2.Handle(index))
+		if err != nil {
+			return nil, xerrors.Errorf("invalid index %s: %w", key, err)
+		}
+		pubs = append(pubs, pub)
+	}
+	sort.Slice(pubs, func(i, j int) bool { return pubs[i].Index < pubs[j].Index })
+	return pubs, nil
+}
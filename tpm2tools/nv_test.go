@@ -0,0 +1,107 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/testutil"
+	. "github.com/canonical/go-tpm2/tpm2tools"
+)
+
+type nvSuite struct{}
+
+var _ = Suite(&nvSuite{})
+
+func (s *nvSuite) TestMarshalYAML(c *C) {
+	data, err := MarshalNVPublic([]*tpm2.NVPublic{
+		{
+			Index:   0x01500016,
+			NameAlg: tpm2.HashAlgorithmSHA256,
+			Attrs:   tpm2.NVTypeOrdinary.WithAttrs(tpm2.AttrNVOwnerWrite | tpm2.AttrNVOwnerRead),
+			Size:    32},
+		{
+			Index:      0x0181f000,
+			NameAlg:    tpm2.HashAlgorithmSHA1,
+			Attrs:      tpm2.NVTypeCounter.WithAttrs(tpm2.AttrNVAuthWrite | tpm2.AttrNVAuthRead | tpm2.AttrNVWritten),
+			AuthPolicy: testutil.DecodeHexString(c, "0102030405"),
+			Size:       8}}, YAML)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `0x1500016:
+  name: 000b2a87953c4eb3c448ae9f6667d00d24db408bbe6a0639160d14f1ed6bc4714aaa
+  hash algorithm:
+    friendly: sha256
+    value: 0xB
+  attributes:
+    friendly: ownerwrite|ownerread
+    value: 0x20002
+  size: 32
+0x181f000:
+  name: 00045cca58be422b11a9bf6eb898bf78b6a555998553
+  hash algorithm:
+    friendly: sha1
+    value: 0x4
+  attributes:
+    friendly: authwrite|authread|written|nt=0x1
+    value: 0x20040014
+  size: 8
+  authorization policy: 0102030405
+`)
+}
+
+func (s *nvSuite) TestRoundTrip(c *C) {
+	pubs := []*tpm2.NVPublic{
+		{
+			Index:   0x01500016,
+			NameAlg: tpm2.HashAlgorithmSHA256,
+			Attrs:   tpm2.NVTypeOrdinary.WithAttrs(tpm2.AttrNVOwnerWrite | tpm2.AttrNVOwnerRead),
+			Size:    32},
+		{
+			Index:      0x0181f000,
+			NameAlg:    tpm2.HashAlgorithmSHA1,
+			Attrs:      tpm2.NVTypePinPass.WithAttrs(tpm2.AttrNVPolicyWrite | tpm2.AttrNVAuthRead | tpm2.AttrNVNoDA),
+			AuthPolicy: testutil.DecodeHexString(c, "0102030405"),
+			Size:       8}}
+
+	for _, format := range []Format{YAML, JSON} {
+		data, err := MarshalNVPublic(pubs, format)
+		c.Assert(err, IsNil)
+
+		decoded, err := UnmarshalNVPublic(data, format)
+		c.Check(err, IsNil)
+		c.Check(decoded, DeepEquals, pubs)
+	}
+}
+
+func (s *nvSuite) TestUnmarshalTemplate(c *C) {
+	pubs, err := UnmarshalNVPublic([]byte(`
+0x1800001:
+  hash algorithm:
+    friendly: sha256
+  attributes:
+    friendly: authwrite|authread|nt=counter
+  size: 8
+`), YAML)
+	c.Check(err, IsNil)
+	c.Check(pubs, DeepEquals, []*tpm2.NVPublic{{
+		Index:   0x01800001,
+		NameAlg: tpm2.HashAlgorithmSHA256,
+		Attrs:   tpm2.NVTypeCounter.WithAttrs(tpm2.AttrNVAuthWrite | tpm2.AttrNVAuthRead),
+		Size:    8}})
+}
+
+func (s *nvSuite) TestUnmarshalInvalidAttributes(c *C) {
+	_, err := UnmarshalNVPublic([]byte(`
+0x1800001:
+  hash algorithm:
+    friendly: sha256
+  attributes:
+    friendly: authwrite|authread
+    value: 0x20002
+  size: 8
+`), YAML)
+	c.Check(err, ErrorMatches, `invalid index 0x1800001: invalid attributes: value "authwrite\|authread" is inconsistent with raw value 0x20002`)
+}
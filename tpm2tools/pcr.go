@@ -0,0 +1,81 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// MarshalPCRValues encodes the supplied PCR values in the layout used by tpm2_pcrread.
+// Banks are ordered by algorithm ID and PCRs are ordered by index.
+func MarshalPCRValues(values tpm2.PCRValues, format Format) ([]byte, error) {
+	var algs []tpm2.HashAlgorithmId
+	for alg := range values {
+		algs = append(algs, alg)
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+
+	var m mapping
+	for _, alg := range algs {
+		var pcrs []int
+		for pcr := range values[alg] {
+			pcrs = append(pcrs, pcr)
+		}
+		sort.Ints(pcrs)
+
+		var bank mapping
+		for _, pcr := range pcrs {
+			bank = append(bank, entry{
+				key:     strconv.Itoa(pcr),
+				value:   "0x" + strings.ToUpper(hex.EncodeToString(values[alg][pcr])),
+				yamlKey: fmt.Sprintf("%-2d", pcr)})
+		}
+		m.add(algName(tpm2.AlgorithmId(alg)), bank)
+	}
+	return encode(m, format, 2)
+}
+
+// UnmarshalPCRValues decodes PCR values from the layout used by tpm2_pcrread.
+func UnmarshalPCRValues(data []byte, format Format) (tpm2.PCRValues, error) {
+	var banks map[string]map[string]string
+	if err := decode(data, format, &banks); err != nil {
+		return nil, xerrors.Errorf("cannot decode %v: %w", format, err)
+	}
+
+	values := make(tpm2.PCRValues)
+	for name, bank := range banks {
+		alg, err := parseAlg(name)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid bank: %w", err)
+		}
+		hashAlg := tpm2.HashAlgorithmId(alg)
+		values[hashAlg] = make(map[int]tpm2.Digest)
+
+		for key, value := range bank {
+			pcr, err := strconv.Atoi(strings.TrimSpace(key))
+			if err != nil || pcr < 0 {
+				return nil, fmt.Errorf("invalid PCR index %q in bank %s", key, name)
+			}
+			digest, err := decodeHex(fmt.Sprintf("value for PCR %d in bank %s", pcr, name),
+				strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X"))
+			if err != nil {
+				return nil, err
+			}
+			if hashAlg.IsValid() && len(digest) != hashAlg.Size() {
+				return nil, fmt.Errorf("invalid value for PCR %d in bank %s: wrong digest size", pcr, name)
+			}
+			values[hashAlg][pcr] = digest
+		}
+	}
+	return values, nil
+}
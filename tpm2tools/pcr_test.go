@@ -0,0 +1,64 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/testutil"
+	. "github.com/canonical/go-tpm2/tpm2tools"
+)
+
+type pcrSuite struct{}
+
+var _ = Suite(&pcrSuite{})
+
+func (s *pcrSuite) TestMarshalYAML(c *C) {
+	data, err := MarshalPCRValues(tpm2.PCRValues{
+		tpm2.HashAlgorithmSHA256: {
+			10: testutil.DecodeHexString(c, "b0a7e1a93e93c3ea1da6c9f38b8f33ba1a6d35fddd1d7c1c1de6e2a1c8e7ad5a"),
+			0:  make(tpm2.Digest, 32)},
+		tpm2.HashAlgorithmSHA1: {
+			7: testutil.DecodeHexString(c, "b2a83b0ebf2f8374299a5b2bdfc31ea955ad7236")}}, YAML)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `  sha1:
+    7 : 0xB2A83B0EBF2F8374299A5B2BDFC31EA955AD7236
+  sha256:
+    0 : 0x0000000000000000000000000000000000000000000000000000000000000000
+    10: 0xB0A7E1A93E93C3EA1DA6C9F38B8F33BA1A6D35FDDD1D7C1C1DE6E2A1C8E7AD5A
+`)
+}
+
+func (s *pcrSuite) TestRoundTrip(c *C) {
+	values := tpm2.PCRValues{
+		tpm2.HashAlgorithmSHA256: {
+			10: testutil.DecodeHexString(c, "b0a7e1a93e93c3ea1da6c9f38b8f33ba1a6d35fddd1d7c1c1de6e2a1c8e7ad5a"),
+			0:  make(tpm2.Digest, 32)},
+		tpm2.HashAlgorithmSHA1: {
+			7: testutil.DecodeHexString(c, "b2a83b0ebf2f8374299a5b2bdfc31ea955ad7236")}}
+
+	for _, format := range []Format{YAML, JSON} {
+		data, err := MarshalPCRValues(values, format)
+		c.Assert(err, IsNil)
+
+		decoded, err := UnmarshalPCRValues(data, format)
+		c.Check(err, IsNil)
+		c.Check(decoded, DeepEquals, values)
+	}
+}
+
+func (s *pcrSuite) TestUnmarshalWrongDigestSize(c *C) {
+	_, err := UnmarshalPCRValues([]byte(`
+  sha256:
+    0 : 0x0000
+`), YAML)
+	c.Check(err, ErrorMatches, `invalid value for PCR 0 in bank sha256: wrong digest size`)
+}
+
+func (s *pcrSuite) TestUnmarshalUnknownBank(c *C) {
+	_, err := UnmarshalPCRValues([]byte(`{"foo": {"0": "0x00"}}`), JSON)
+	c.Check(err, ErrorMatches, `invalid bank: unrecognized algorithm "foo"`)
+}
@@ -0,0 +1,472 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// defaultRSAExponent is the exponent that tpm2-tools prints when the exponent field
+// of a RSA key is zero.
+const defaultRSAExponent = 65537
+
+// normalizePublic returns a copy of the supplied public area, with all of the union
+// members that are required by the selectors populated.
+func normalizePublic(pub *tpm2.Public) (*tpm2.Public, error) {
+	b, err := mu.MarshalToBytes(pub)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot marshal public area: %w", err)
+	}
+	var out *tpm2.Public
+	if _, err := mu.UnmarshalFromBytes(b, &out); err != nil {
+		return nil, xerrors.Errorf("invalid public area: %w", err)
+	}
+	return out, nil
+}
+
+func addSymNodes(m *mapping, sym *tpm2.SymDefObject) {
+	var mode tpm2.AlgorithmId
+	if sym.Mode != nil {
+		mode = tpm2.AlgorithmId(sym.Mode.Sym)
+	}
+	var keyBits uint16
+	if sym.KeyBits != nil {
+		keyBits = sym.KeyBits.Sym
+	}
+	m.add("sym-alg", algNode(tpm2.AlgorithmId(sym.Algorithm)))
+	m.add("sym-mode", algNode(mode))
+	m.add("sym-keybits", uint64(keyBits))
+}
+
+func addAsymSchemeNodes(m *mapping, scheme tpm2.AsymSchemeId, details *tpm2.AsymSchemeU) {
+	var hashAlg tpm2.HashAlgorithmId
+	if scheme.HasDigest() {
+		hashAlg = details.Any(scheme).HashAlg
+	}
+	m.add("scheme", algNode(tpm2.AlgorithmId(scheme)))
+	m.add("scheme-halg", algNode(tpm2.AlgorithmId(hashAlg)))
+	if scheme == tpm2.AsymSchemeECDAA {
+		m.add("scheme-count", uint64(details.ECDAA.Count))
+	}
+}
+
+func kdfHashAlg(kdf *tpm2.KDFScheme) tpm2.HashAlgorithmId {
+	switch kdf.Scheme {
+	case tpm2.KDFAlgorithmMGF1:
+		return kdf.Details.MGF1.HashAlg
+	case tpm2.KDFAlgorithmKDF1_SP800_56A:
+		return kdf.Details.KDF1_SP800_56A.HashAlg
+	case tpm2.KDFAlgorithmKDF2:
+		return kdf.Details.KDF2.HashAlg
+	case tpm2.KDFAlgorithmKDF1_SP800_108:
+		return kdf.Details.KDF1_SP800_108.HashAlg
+	default:
+		return 0
+	}
+}
+
+func publicNode(pub *tpm2.Public) (mapping, error) {
+	pub, err := normalizePublic(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	var m mapping
+	if pub.NameAlg.Available() {
+		name, err := pub.Name()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot compute name: %w", err)
+		}
+		m.add("name", hex.EncodeToString(name))
+	}
+	m.add("name-alg", algNode(tpm2.AlgorithmId(pub.NameAlg)))
+	m.add("attributes", algValue(objectAttributeNames.format(uint32(pub.Attrs)), uint32(pub.Attrs)))
+	m.add("type", algNode(tpm2.AlgorithmId(pub.Type)))
+
+	switch pub.Type {
+	case tpm2.ObjectTypeRSA:
+		params := pub.Params.RSADetail
+		exponent := params.Exponent
+		if exponent == 0 {
+			exponent = defaultRSAExponent
+		}
+		m.add("exponent", uint64(exponent))
+		m.add("bits", uint64(params.KeyBits))
+		addAsymSchemeNodes(&m, tpm2.AsymSchemeId(params.Scheme.Scheme), params.Scheme.Details)
+		addSymNodes(&m, &params.Symmetric)
+		m.add("rsa", hex.EncodeToString(pub.Unique.RSA))
+	case tpm2.ObjectTypeECC:
+		params := pub.Params.ECCDetail
+		m.add("curve-id", algValue(curveName(params.CurveID), uint32(params.CurveID)))
+		m.add("kdfa-alg", algNode(tpm2.AlgorithmId(params.KDF.Scheme)))
+		m.add("kdfa-halg", algNode(tpm2.AlgorithmId(kdfHashAlg(&params.KDF))))
+		addAsymSchemeNodes(&m, tpm2.AsymSchemeId(params.Scheme.Scheme), params.Scheme.Details)
+		addSymNodes(&m, &params.Symmetric)
+		m.add("x", hex.EncodeToString(pub.Unique.ECC.X))
+		m.add("y", hex.EncodeToString(pub.Unique.ECC.Y))
+	case tpm2.ObjectTypeKeyedHash:
+		scheme := &pub.Params.KeyedHashDetail.Scheme
+		m.add("algorithm", algNode(tpm2.AlgorithmId(scheme.Scheme)))
+		switch scheme.Scheme {
+		case tpm2.KeyedHashSchemeHMAC:
+			m.add("hash-alg", algNode(tpm2.AlgorithmId(scheme.Details.HMAC.HashAlg)))
+		case tpm2.KeyedHashSchemeXOR:
+			m.add("hash-alg", algNode(tpm2.AlgorithmId(scheme.Details.XOR.HashAlg)))
+			m.add("kdfa-alg", algNode(tpm2.AlgorithmId(scheme.Details.XOR.KDF)))
+		}
+		m.add("keyedhash", hex.EncodeToString(pub.Unique.KeyedHash))
+	case tpm2.ObjectTypeSymCipher:
+		addSymNodes(&m, &pub.Params.SymDetail.Sym)
+		m.add("symcipher", hex.EncodeToString(pub.Unique.Sym))
+	default:
+		return nil, fmt.Errorf("unsupported object type %v", pub.Type)
+	}
+
+	if len(pub.AuthPolicy) > 0 {
+		m.add("authorization policy", hex.EncodeToString(pub.AuthPolicy))
+	}
+
+	return m, nil
+}
+
+// MarshalPublic encodes the supplied public area in the layout used by tpm2_readpublic.
+// The name is included if the name algorithm is available.
+//
+// A RSA exponent of zero is rendered as 65537, as it is by tpm2-tools.
+func MarshalPublic(pub *tpm2.Public, format Format) ([]byte, error) {
+	m, err := publicNode(pub)
+	if err != nil {
+		return nil, err
+	}
+	return encode(m, format, 0)
+}
+
+type publicDoc struct {
+	Name          string      `yaml:"name" json:"name"`
+	QualifiedName string      `yaml:"qualified name" json:"qualified name"`
+	NameAlg       *valueField `yaml:"name-alg" json:"name-alg"`
+	Attributes    *valueField `yaml:"attributes" json:"attributes"`
+	Type          *valueField `yaml:"type" json:"type"`
+
+	Exponent    *uint32     `yaml:"exponent" json:"exponent"`
+	Bits        *uint16     `yaml:"bits" json:"bits"`
+	CurveID     *valueField `yaml:"curve-id" json:"curve-id"`
+	KDFAlg      *valueField `yaml:"kdfa-alg" json:"kdfa-alg"`
+	KDFHashAlg  *valueField `yaml:"kdfa-halg" json:"kdfa-halg"`
+	Scheme      *valueField `yaml:"scheme" json:"scheme"`
+	SchemeHash  *valueField `yaml:"scheme-halg" json:"scheme-halg"`
+	SchemeCount *uint16     `yaml:"scheme-count" json:"scheme-count"`
+	Algorithm   *valueField `yaml:"algorithm" json:"algorithm"`
+	HashAlg     *valueField `yaml:"hash-alg" json:"hash-alg"`
+	SymAlg      *valueField `yaml:"sym-alg" json:"sym-alg"`
+	SymMode     *valueField `yaml:"sym-mode" json:"sym-mode"`
+	SymKeyBits  *uint16     `yaml:"sym-keybits" json:"sym-keybits"`
+
+	RSA       string `yaml:"rsa" json:"rsa"`
+	X         string `yaml:"x" json:"x"`
+	Y         string `yaml:"y" json:"y"`
+	KeyedHash string `yaml:"keyedhash" json:"keyedhash"`
+	SymCipher string `yaml:"symcipher" json:"symcipher"`
+
+	AuthPolicy string `yaml:"authorization policy" json:"authorization policy"`
+}
+
+func (d *publicDoc) sym() (tpm2.SymDefObject, error) {
+	alg, err := d.SymAlg.optionalAlg("sym-alg")
+	if err != nil {
+		return tpm2.SymDefObject{}, err
+	}
+	if alg == tpm2.AlgorithmNull {
+		return tpm2.SymDefObject{Algorithm: tpm2.SymObjectAlgorithmNull}, nil
+	}
+	mode, err := d.SymMode.alg("sym-mode")
+	if err != nil {
+		return tpm2.SymDefObject{}, err
+	}
+	if d.SymKeyBits == nil {
+		return tpm2.SymDefObject{}, errors.New("missing sym-keybits")
+	}
+	return tpm2.SymDefObject{
+		Algorithm: tpm2.SymObjectAlgorithmId(alg),
+		KeyBits:   &tpm2.SymKeyBitsU{Sym: *d.SymKeyBits},
+		Mode:      &tpm2.SymModeU{Sym: tpm2.SymModeId(mode)}}, nil
+}
+
+func (d *publicDoc) asymScheme() (tpm2.AsymSchemeId, *tpm2.AsymSchemeU, error) {
+	alg, err := d.Scheme.optionalAlg("scheme")
+	if err != nil {
+		return 0, nil, err
+	}
+	scheme := tpm2.AsymSchemeId(alg)
+	if scheme == tpm2.AsymSchemeNull {
+		return scheme, nil, nil
+	}
+
+	var hashAlg tpm2.HashAlgorithmId
+	if scheme.HasDigest() {
+		alg, err := d.SchemeHash.alg("scheme-halg")
+		if err != nil {
+			return 0, nil, err
+		}
+		hashAlg = tpm2.HashAlgorithmId(alg)
+	}
+
+	switch scheme {
+	case tpm2.AsymSchemeRSASSA:
+		return scheme, &tpm2.AsymSchemeU{RSASSA: &tpm2.SigSchemeRSASSA{HashAlg: hashAlg}}, nil
+	case tpm2.AsymSchemeRSAES:
+		return scheme, &tpm2.AsymSchemeU{RSAES: new(tpm2.EncSchemeRSAES)}, nil
+	case tpm2.AsymSchemeRSAPSS:
+		return scheme, &tpm2.AsymSchemeU{RSAPSS: &tpm2.SigSchemeRSAPSS{HashAlg: hashAlg}}, nil
+	case tpm2.AsymSchemeOAEP:
+		return scheme, &tpm2.AsymSchemeU{OAEP: &tpm2.EncSchemeOAEP{HashAlg: hashAlg}}, nil
+	case tpm2.AsymSchemeECDSA:
+		return scheme, &tpm2.AsymSchemeU{ECDSA: &tpm2.SigSchemeECDSA{HashAlg: hashAlg}}, nil
+	case tpm2.AsymSchemeECDH:
+		return scheme, &tpm2.AsymSchemeU{ECDH: &tpm2.KeySchemeECDH{HashAlg: hashAlg}}, nil
+	case tpm2.AsymSchemeECDAA:
+		if d.SchemeCount == nil {
+			return 0, nil, errors.New("missing scheme-count")
+		}
+		return scheme, &tpm2.AsymSchemeU{ECDAA: &tpm2.SigSchemeECDAA{HashAlg: hashAlg, Count: *d.SchemeCount}}, nil
+	case tpm2.AsymSchemeSM2:
+		return scheme, &tpm2.AsymSchemeU{SM2: &tpm2.SigSchemeSM2{HashAlg: hashAlg}}, nil
+	case tpm2.AsymSchemeECSCHNORR:
+		return scheme, &tpm2.AsymSchemeU{ECSCHNORR: &tpm2.SigSchemeECSCHNORR{HashAlg: hashAlg}}, nil
+	case tpm2.AsymSchemeECMQV:
+		return scheme, &tpm2.AsymSchemeU{ECMQV: &tpm2.KeySchemeECMQV{HashAlg: hashAlg}}, nil
+	default:
+		return 0, nil, fmt.Errorf("invalid scheme: unsupported asymmetric scheme %v", scheme)
+	}
+}
+
+func (d *publicDoc) kdf() (tpm2.KDFScheme, error) {
+	alg, err := d.KDFAlg.optionalAlg("kdfa-alg")
+	if err != nil {
+		return tpm2.KDFScheme{}, err
+	}
+	scheme := tpm2.KDFAlgorithmId(alg)
+	if scheme == tpm2.KDFAlgorithmNull {
+		return tpm2.KDFScheme{Scheme: scheme}, nil
+	}
+
+	hashAlg, err := d.KDFHashAlg.alg("kdfa-halg")
+	if err != nil {
+		return tpm2.KDFScheme{}, err
+	}
+	h := tpm2.HashAlgorithmId(hashAlg)
+
+	switch scheme {
+	case tpm2.KDFAlgorithmMGF1:
+		return tpm2.KDFScheme{Scheme: scheme, Details: &tpm2.KDFSchemeU{MGF1: &tpm2.SchemeMGF1{HashAlg: h}}}, nil
+	case tpm2.KDFAlgorithmKDF1_SP800_56A:
+		return tpm2.KDFScheme{Scheme: scheme, Details: &tpm2.KDFSchemeU{KDF1_SP800_56A: &tpm2.SchemeKDF1_SP800_56A{HashAlg: h}}}, nil
+	case tpm2.KDFAlgorithmKDF2:
+		return tpm2.KDFScheme{Scheme: scheme, Details: &tpm2.KDFSchemeU{KDF2: &tpm2.SchemeKDF2{HashAlg: h}}}, nil
+	case tpm2.KDFAlgorithmKDF1_SP800_108:
+		return tpm2.KDFScheme{Scheme: scheme, Details: &tpm2.KDFSchemeU{KDF1_SP800_108: &tpm2.SchemeKDF1_SP800_108{HashAlg: h}}}, nil
+	default:
+		return tpm2.KDFScheme{}, fmt.Errorf("invalid kdfa-alg: unsupported KDF %v", scheme)
+	}
+}
+
+func (d *publicDoc) keyedHashScheme() (tpm2.KeyedHashScheme, error) {
+	alg, err := d.Algorithm.optionalAlg("algorithm")
+	if err != nil {
+		return tpm2.KeyedHashScheme{}, err
+	}
+	scheme := tpm2.KeyedHashSchemeId(alg)
+
+	switch scheme {
+	case tpm2.KeyedHashSchemeNull:
+		return tpm2.KeyedHashScheme{Scheme: scheme}, nil
+	case tpm2.KeyedHashSchemeHMAC:
+		hashAlg, err := d.HashAlg.alg("hash-alg")
+		if err != nil {
+			return tpm2.KeyedHashScheme{}, err
+		}
+		return tpm2.KeyedHashScheme{
+			Scheme:  scheme,
+			Details: &tpm2.SchemeKeyedHashU{HMAC: &tpm2.SchemeHMAC{HashAlg: tpm2.HashAlgorithmId(hashAlg)}}}, nil
+	case tpm2.KeyedHashSchemeXOR:
+		hashAlg, err := d.HashAlg.alg("hash-alg")
+		if err != nil {
+			return tpm2.KeyedHashScheme{}, err
+		}
+		kdf, err := d.KDFAlg.alg("kdfa-alg")
+		if err != nil {
+			return tpm2.KeyedHashScheme{}, err
+		}
+		return tpm2.KeyedHashScheme{
+			Scheme: scheme,
+			Details: &tpm2.SchemeKeyedHashU{
+				XOR: &tpm2.SchemeXOR{HashAlg: tpm2.HashAlgorithmId(hashAlg), KDF: tpm2.KDFAlgorithmId(kdf)}}}, nil
+	default:
+		return tpm2.KeyedHashScheme{}, fmt.Errorf("invalid algorithm: unsupported keyedhash scheme %v", scheme)
+	}
+}
+
+func parseCurve(s string) (uint32, error) {
+	for curve, name := range curveNames {
+		if name == s {
+			return uint32(curve), nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognized curve %q", s)
+}
+
+func formatCurve(v uint32) string {
+	return curveName(tpm2.ECCCurve(v))
+}
+
+func (d *publicDoc) public() (*tpm2.Public, error) {
+	nameAlg, err := d.NameAlg.alg("name-alg")
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := d.Attributes.attrs("attributes", objectAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+	objectType, err := d.Type.alg("type")
+	if err != nil {
+		return nil, err
+	}
+	authPolicy, err := decodeHex("authorization policy", d.AuthPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	pub := &tpm2.Public{
+		Type:       tpm2.ObjectTypeId(objectType),
+		NameAlg:    tpm2.HashAlgorithmId(nameAlg),
+		Attrs:      tpm2.ObjectAttributes(attrs),
+		AuthPolicy: authPolicy}
+
+	switch pub.Type {
+	case tpm2.ObjectTypeRSA:
+		sym, err := d.sym()
+		if err != nil {
+			return nil, err
+		}
+		scheme, details, err := d.asymScheme()
+		if err != nil {
+			return nil, err
+		}
+		if d.Bits == nil {
+			return nil, errors.New("missing bits")
+		}
+		var exponent uint32
+		if d.Exponent != nil && *d.Exponent != defaultRSAExponent {
+			exponent = *d.Exponent
+		}
+		unique, err := decodeHex("rsa", d.RSA)
+		if err != nil {
+			return nil, err
+		}
+		pub.Params = &tpm2.PublicParamsU{
+			RSADetail: &tpm2.RSAParams{
+				Symmetric: sym,
+				Scheme:    tpm2.RSAScheme{Scheme: tpm2.RSASchemeId(scheme), Details: details},
+				KeyBits:   *d.Bits,
+				Exponent:  exponent}}
+		pub.Unique = &tpm2.PublicIDU{RSA: unique}
+	case tpm2.ObjectTypeECC:
+		sym, err := d.sym()
+		if err != nil {
+			return nil, err
+		}
+		scheme, details, err := d.asymScheme()
+		if err != nil {
+			return nil, err
+		}
+		curve, err := d.CurveID.resolve("curve-id", parseCurve, formatCurve)
+		if err != nil {
+			return nil, err
+		}
+		kdf, err := d.kdf()
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeHex("x", d.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeHex("y", d.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub.Params = &tpm2.PublicParamsU{
+			ECCDetail: &tpm2.ECCParams{
+				Symmetric: sym,
+				Scheme:    tpm2.ECCScheme{Scheme: tpm2.ECCSchemeId(scheme), Details: details},
+				CurveID:   tpm2.ECCCurve(curve),
+				KDF:       kdf}}
+		pub.Unique = &tpm2.PublicIDU{ECC: &tpm2.ECCPoint{X: x, Y: y}}
+	case tpm2.ObjectTypeKeyedHash:
+		scheme, err := d.keyedHashScheme()
+		if err != nil {
+			return nil, err
+		}
+		unique, err := decodeHex("keyedhash", d.KeyedHash)
+		if err != nil {
+			return nil, err
+		}
+		pub.Params = &tpm2.PublicParamsU{KeyedHashDetail: &tpm2.KeyedHashParams{Scheme: scheme}}
+		pub.Unique = &tpm2.PublicIDU{KeyedHash: unique}
+	case tpm2.ObjectTypeSymCipher:
+		sym, err := d.sym()
+		if err != nil {
+			return nil, err
+		}
+		unique, err := decodeHex("symcipher", d.SymCipher)
+		if err != nil {
+			return nil, err
+		}
+		pub.Params = &tpm2.PublicParamsU{SymDetail: &tpm2.SymCipherParams{Sym: sym}}
+		pub.Unique = &tpm2.PublicIDU{Sym: unique}
+	default:
+		return nil, fmt.Errorf("invalid type: unsupported object type %v", pub.Type)
+	}
+
+	if d.Name != "" && pub.NameAlg.Available() {
+		expected, err := decodeHex("name", d.Name)
+		if err != nil {
+			return nil, err
+		}
+		name, err := pub.Name()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot compute name: %w", err)
+		}
+		if !bytes.Equal(name, expected) {
+			return nil, errors.New("invalid name: name does not match the public area")
+		}
+	}
+
+	return pub, nil
+}
+
+// UnmarshalPublic decodes a public area from the layout used by tpm2_readpublic. This can be
+// used to write object templates as YAML. Algorithms and attributes can be supplied as a value
+// or raw field or both, in which case they must be consistent. Optional algorithms that are
+// omitted default to TPM_ALG_NULL. If the name is supplied, it must match the public area.
+//
+// An RSA exponent of 65537 is decoded as zero, which the TPM interprets as the default
+// exponent.
+func UnmarshalPublic(data []byte, format Format) (*tpm2.Public, error) {
+	var d publicDoc
+	if err := decode(data, format, &d); err != nil {
+		return nil, xerrors.Errorf("cannot decode %v: %w", format, err)
+	}
+	return d.public()
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/templates"
+	"github.com/canonical/go-tpm2/testutil"
+	. "github.com/canonical/go-tpm2/tpm2tools"
+)
+
+type publicSuite struct{}
+
+var _ = Suite(&publicSuite{})
+
+func (s *publicSuite) testRoundTrip(c *C, pub *tpm2.Public) {
+	for _, format := range []Format{YAML, JSON} {
+		data, err := MarshalPublic(pub, format)
+		c.Assert(err, IsNil)
+
+		decoded, err := UnmarshalPublic(data, format)
+		c.Assert(err, IsNil, Commentf("format: %v\n%s", format, data))
+		c.Check(mu.MustMarshalToBytes(decoded), DeepEquals, mu.MustMarshalToBytes(pub), Commentf("format: %v", format))
+	}
+}
+
+func (s *publicSuite) TestMarshalRSAStorageKeyYAML(c *C) {
+	data, err := MarshalPublic(testutil.NewRSAStorageKeyTemplate(), YAML)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `name: 000b9e7ad6dcfa20f03935e9200d649a297669ef1cdebc631a53d90521bf0fe0a16b
+name-alg:
+  value: sha256
+  raw: 0xb
+attributes:
+  value: fixedtpm|fixedparent|sensitivedataorigin|userwithauth|noda|restricted|decrypt
+  raw: 0x30472
+type:
+  value: rsa
+  raw: 0x1
+exponent: 65537
+bits: 2048
+scheme:
+  value: null
+  raw: 0x10
+scheme-halg:
+  value: (null)
+  raw: 0x0
+sym-alg:
+  value: aes
+  raw: 0x6
+sym-mode:
+  value: cfb
+  raw: 0x43
+sym-keybits: 128
+rsa:
+`)
+}
+
+func (s *publicSuite) TestMarshalECCSigningKeyJSON(c *C) {
+	pub := templates.NewRestrictedECCSigningKeyWithDefaults()
+	pub.Unique = &tpm2.PublicIDU{ECC: &tpm2.ECCPoint{X: []byte{0x01, 0x02}, Y: []byte{0x03, 0x04}}}
+
+	data, err := MarshalPublic(pub, JSON)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `{
+  "name": "000b557b435b7676e373715c20df3b40e60663dad9c4fbc14466c5f9e6e223d9bd3c",
+  "name-alg": {
+    "value": "sha256",
+    "raw": "0xb"
+  },
+  "attributes": {
+    "value": "fixedtpm|fixedparent|sensitivedataorigin|userwithauth|restricted|sign",
+    "raw": "0x50072"
+  },
+  "type": {
+    "value": "ecc",
+    "raw": "0x23"
+  },
+  "curve-id": {
+    "value": "NIST p256",
+    "raw": "0x3"
+  },
+  "kdfa-alg": {
+    "value": "null",
+    "raw": "0x10"
+  },
+  "kdfa-halg": {
+    "value": "(null)",
+    "raw": "0x0"
+  },
+  "scheme": {
+    "value": "ecdsa",
+    "raw": "0x18"
+  },
+  "scheme-halg": {
+    "value": "sha256",
+    "raw": "0xb"
+  },
+  "sym-alg": {
+    "value": "null",
+    "raw": "0x10"
+  },
+  "sym-mode": {
+    "value": "(null)",
+    "raw": "0x0"
+  },
+  "sym-keybits": 0,
+  "x": "0102",
+  "y": "0304"
+}
+`)
+}
+
+func (s *publicSuite) TestRoundTripRSAStorageKey(c *C) {
+	s.testRoundTrip(c, testutil.NewRSAStorageKeyTemplate())
+}
+
+func (s *publicSuite) TestRoundTripRSASigningKey(c *C) {
+	pub := templates.NewRSAKey(tpm2.HashAlgorithmSHA256, templates.KeyUsageSign, &tpm2.RSAScheme{
+		Scheme:  tpm2.RSASchemeRSAPSS,
+		Details: &tpm2.AsymSchemeU{RSAPSS: &tpm2.SigSchemeRSAPSS{HashAlg: tpm2.HashAlgorithmSHA384}}}, 3072)
+	pub.Params.RSADetail.Exponent = 3
+	pub.AuthPolicy = testutil.DecodeHexString(c, "8fcd2169ab92694e0c633f1ab772842b8241bbc20288981fc7ac1eddc1fddb0e")
+	pub.Unique = &tpm2.PublicIDU{RSA: testutil.DecodeHexString(c, "a1b2c3d4")}
+	s.testRoundTrip(c, pub)
+}
+
+func (s *publicSuite) TestRoundTripECCStorageKey(c *C) {
+	s.testRoundTrip(c, templates.NewECCStorageKeyWithDefaults())
+}
+
+func (s *publicSuite) TestRoundTripECDAAKey(c *C) {
+	pub := templates.NewECCKey(tpm2.HashAlgorithmSHA256, templates.KeyUsageSign, &tpm2.ECCScheme{
+		Scheme:  tpm2.ECCSchemeECDAA,
+		Details: &tpm2.AsymSchemeU{ECDAA: &tpm2.SigSchemeECDAA{HashAlg: tpm2.HashAlgorithmSHA256, Count: 5}}}, tpm2.ECCCurveBN_P256)
+	s.testRoundTrip(c, pub)
+}
+
+func (s *publicSuite) TestRoundTripSealedObject(c *C) {
+	s.testRoundTrip(c, testutil.NewSealedObjectTemplate())
+}
+
+func (s *publicSuite) TestRoundTripHMACKey(c *C) {
+	s.testRoundTrip(c, templates.NewHMACKey(tpm2.HashAlgorithmSHA256, tpm2.HashAlgorithmSHA512))
+}
+
+func (s *publicSuite) TestRoundTripSymmetricKey(c *C) {
+	s.testRoundTrip(c, templates.NewSymmetricKey(tpm2.HashAlgorithmSHA256, templates.KeyUsageEncrypt, tpm2.SymObjectAlgorithmAES, 256, tpm2.SymModeCFB))
+}
+
+func (s *publicSuite) TestUnmarshalTemplate(c *C) {
+	// Only the names of algorithms and attributes are supplied, and optional algorithms
+	// are omitted.
+	pub, err := UnmarshalPublic([]byte(`
+name-alg:
+  value: sha256
+attributes:
+  value: fixedtpm|fixedparent|sensitivedataorigin|userwithauth|noda|restricted|decrypt
+type:
+  value: rsa
+bits: 2048
+sym-alg:
+  value: aes
+sym-mode:
+  value: cfb
+sym-keybits: 128
+`), YAML)
+	c.Assert(err, IsNil)
+	c.Check(mu.MustMarshalToBytes(pub), DeepEquals, mu.MustMarshalToBytes(testutil.NewRSAStorageKeyTemplate()))
+}
+
+func (s *publicSuite) TestUnmarshalRawOnly(c *C) {
+	pub, err := UnmarshalPublic([]byte(`
+name-alg:
+  raw: 0xb
+attributes:
+  raw: 0x452
+type:
+  raw: 0x8
+keyedhash:
+`), YAML)
+	c.Assert(err, IsNil)
+	c.Check(mu.MustMarshalToBytes(pub), DeepEquals, mu.MustMarshalToBytes(testutil.NewSealedObjectTemplate()))
+}
+
+func (s *publicSuite) TestUnmarshalInconsistentValue(c *C) {
+	_, err := UnmarshalPublic([]byte(`
+name-alg:
+  value: sha1
+  raw: 0xb
+attributes:
+  raw: 0x452
+type:
+  raw: 0x8
+`), YAML)
+	c.Check(err, ErrorMatches, `invalid name-alg: value "sha1" is inconsistent with raw value 0xb`)
+}
+
+func (s *publicSuite) TestUnmarshalUnknownAttribute(c *C) {
+	_, err := UnmarshalPublic([]byte(`
+name-alg:
+  value: sha256
+attributes:
+  value: fixedtpm|foo
+type:
+  value: keyedhash
+`), YAML)
+	c.Check(err, ErrorMatches, `invalid attributes: unrecognized attribute "foo"`)
+}
+
+func (s *publicSuite) TestUnmarshalMissingField(c *C) {
+	_, err := UnmarshalPublic([]byte(`
+name-alg:
+  value: sha256
+attributes:
+  value: fixedtpm
+type:
+  value: rsa
+sym-alg:
+  value: aes
+sym-mode:
+  value: cfb
+sym-keybits: 128
+`), YAML)
+	c.Check(err, ErrorMatches, `missing bits`)
+}
+
+func (s *publicSuite) TestUnmarshalUnknownField(c *C) {
+	_, err := UnmarshalPublic([]byte(`{"name-alg": {"value": "sha256"}, "foo": 1}`), JSON)
+	c.Check(err, ErrorMatches, `cannot decode JSON: json: unknown field "foo"`)
+}
+
+func (s *publicSuite) TestUnmarshalNameMismatch(c *C) {
+	data, err := MarshalPublic(testutil.NewRSAStorageKeyTemplate(), YAML)
+	c.Assert(err, IsNil)
+	data = append(data, []byte("authorization policy: 0102\n")...)
+
+	_, err = UnmarshalPublic(data, YAML)
+	c.Check(err, ErrorMatches, `invalid name: name does not match the public area`)
+}
@@ -0,0 +1,294 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TPMSpecVersion describes the version of the TPM 2.0 Library Specification that a TPM
+// implements.
+//
+//mugen:skip
+type TPMSpecVersion struct {
+	Family   string    // Family indicator, eg "2.0"
+	Level    uint32    // Specification level
+	Revision uint32    // Specification revision multiplied by 100, eg 138 for revision 1.38
+	Date     time.Time // Specification date, or the zero time if the TPM doesn't report it
+}
+
+// TPMMemoryInfo describes the memory and handle limits of a TPM. Values that the TPM
+// doesn't report are zero.
+//
+//mugen:skip
+type TPMMemoryInfo struct {
+	Attrs MemoryAttributes // Value of PropertyMemory
+
+	InputBuffer       uint32 // Maximum size of a MaxBuffer argument
+	MaxCommandSize    uint32 // Maximum size of a command
+	MaxResponseSize   uint32 // Maximum size of a response
+	MaxDigest         uint32 // Size of the largest supported digest
+	MaxObjectContext  uint32 // Maximum size of an object context
+	MaxSessionContext uint32 // Maximum size of a session context
+	MaxCapBuffer      uint32 // Maximum size of the capability data in a GetCapability response
+
+	HRTransientMin    uint32 // Minimum number of transient objects that can be loaded
+	HRTransientAvail  uint32 // Estimate of the number of additional transient objects that can be loaded
+	HRLoadedMin       uint32 // Minimum number of sessions that can be loaded
+	HRLoaded          uint32 // Number of sessions currently loaded
+	HRLoadedAvail     uint32 // Estimate of the number of additional sessions that can be loaded
+	ActiveSessionsMax uint32 // Maximum number of active sessions
+	HRActive          uint32 // Number of active sessions
+	HRActiveAvail     uint32 // Estimate of the number of additional sessions that can be created
+	ContextGapMax     uint32 // Maximum allowed difference between the oldest and newest session context
+}
+
+// TPMNVInfo describes the NV limits of a TPM. Values that the TPM doesn't report are zero.
+//
+//mugen:skip
+type TPMNVInfo struct {
+	IndexMax          uint32 // Maximum size of a NV index
+	BufferMax         uint32 // Maximum size of a NV read or write
+	HRNVIndex         uint32 // Number of NV indexes currently defined
+	CountersMax       uint32 // Maximum number of NV counter indexes
+	Counters          uint32 // Number of NV counter indexes currently defined
+	CountersAvail     uint32 // Estimate of the number of additional NV counter indexes that can be defined
+	HRPersistentMin   uint32 // Minimum number of persistent objects that can be stored
+	HRPersistent      uint32 // Number of persistent objects currently stored
+	HRPersistentAvail uint32 // Estimate of the number of additional persistent objects that can be stored
+	WriteRecovery     uint32 // Number of milliseconds before the TPM accepts another NV write after a rate limited write
+}
+
+// TPMLockoutInfo describes the dictionary attack protection settings of a TPM.
+//
+//mugen:skip
+type TPMLockoutInfo struct {
+	InLockout      bool   // Whether the TPM is in lockout, from PermanentAttributes
+	Counter        uint32 // Current value of the lockout counter
+	MaxAuthFail    uint32 // Number of authorization failures before the TPM enters lockout
+	Interval       uint32 // Number of seconds before the lockout counter is decremented
+	Recovery       uint32 // Number of seconds after a lockout hierarchy authorization failure before it can be used again
+	LockoutAuthSet bool   // Whether the lockout hierarchy authorization value is set, from PermanentAttributes
+	DisableClear   bool   // Whether TPM2_Clear is disabled, from PermanentAttributes
+}
+
+// TPMInfo is a typed report of the capabilities of a TPM, as returned by
+// TPMContext.GetTPMInfo.
+//
+//mugen:skip
+type TPMInfo struct {
+	Manufacturer    TPMManufacturer // Value of PropertyManufacturer
+	VendorString    string          // Concatenation of PropertyVendorString1 to PropertyVendorString4
+	VendorTPMType   uint32          // Value of PropertyVendorTPMType
+	FirmwareVersion uint64          // PropertyFirmwareVersion1 in the upper 32 bits and PropertyFirmwareVersion2 in the lower 32 bits
+
+	Spec TPMSpecVersion
+
+	Permanent    PermanentAttributes    // Value of PropertyPermanent
+	StartupClear StartupClearAttributes // Value of PropertyStartupClear
+	Modes        ModeAttributes         // Value of PropertyModes
+
+	Memory  TPMMemoryInfo
+	NV      TPMNVInfo
+	Lockout TPMLockoutInfo
+
+	PCRCount     uint32            // Number of PCRs
+	PCRSelectMin uint32            // Minimum number of octets in a PCR selection
+	PCRBanks     PCRSelectionList  // PCRs assigned to each bank
+	ActiveBanks  []HashAlgorithmId // Banks that have at least one PCR assigned
+
+	Algorithms AlgorithmPropertyList
+	Commands   CommandAttributesList
+	ECCCurves  ECCCurveList
+
+	// Properties contains all of the properties in the fixed and variable groups, including
+	// those that are decoded to other fields.
+	Properties TaggedTPMPropertyList
+}
+
+// FirmwareVersionString returns the firmware version in the format used by most tools,
+// which is the upper and lower 16 bits of PropertyFirmwareVersion1 followed by the upper
+// and lower 16 bits of PropertyFirmwareVersion2, separated by a period. The interpretation
+// of the firmware version is vendor specific.
+func (i *TPMInfo) FirmwareVersionString() string {
+	return fmt.Sprintf("%d.%d.%d.%d", uint16(i.FirmwareVersion>>48), uint16(i.FirmwareVersion>>32),
+		uint16(i.FirmwareVersion>>16), uint16(i.FirmwareVersion))
+}
+
+// propertyString decodes a property that contains up to 4 ASCII characters.
+func propertyString(values ...uint32) string {
+	var b []byte
+	for _, v := range values {
+		var x [4]byte
+		binary.BigEndian.PutUint32(x[:], v)
+		b = append(b, x[:]...)
+	}
+	return strings.TrimRight(strings.Replace(string(b), "\x00", "", -1), " ")
+}
+
+// GetTPMInfo is a convenience function for TPMContext.GetCapability that pages through the
+// TPM properties, algorithms, commands, ECC curves and PCR banks, and returns a typed report
+// with the values decoded.
+//
+// As the report is built from multiple TPM2_GetCapability commands, any SessionContext
+// instances provided should have the AttrContinueSession attribute defined.
+func (t *TPMContext) GetTPMInfo(sessions ...SessionContext) (*TPMInfo, error) {
+	fixed, err := t.GetCapabilityTPMProperties(PropertyFixed, CapabilityMaxProperties, sessions...)
+	if err != nil {
+		return nil, err
+	}
+	variable, err := t.GetCapabilityTPMProperties(PropertyVar, CapabilityMaxProperties, sessions...)
+	if err != nil {
+		return nil, err
+	}
+	pcrs, err := t.GetCapabilityPCRs(sessions...)
+	if err != nil {
+		return nil, err
+	}
+	algs, err := t.GetCapabilityAlgs(AlgorithmFirst, CapabilityMaxProperties, sessions...)
+	if err != nil {
+		return nil, err
+	}
+	commands, err := t.GetCapabilityCommands(CommandFirst, CapabilityMaxProperties, sessions...)
+	if err != nil {
+		return nil, err
+	}
+	curves, err := t.GetCapabilityECCCurves(sessions...)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TPMInfo{
+		PCRBanks:   pcrs,
+		Algorithms: algs,
+		Commands:   commands,
+		ECCCurves:  curves}
+
+	for _, p := range pcrs {
+		if len(p.Select) > 0 {
+			info.ActiveBanks = append(info.ActiveBanks, p.Hash)
+		}
+	}
+
+	// A TPM may continue into the variable group when asked for the fixed group.
+	for _, p := range fixed {
+		if p.Property < PropertyVar {
+			info.Properties = append(info.Properties, p)
+		}
+	}
+	info.Properties = append(info.Properties, variable...)
+
+	var vendor [4]uint32
+	var specDay, specYear uint32
+	for _, p := range info.Properties {
+		switch p.Property {
+		case PropertyFamilyIndicator:
+			info.Spec.Family = propertyString(p.Value)
+		case PropertyLevel:
+			info.Spec.Level = p.Value
+		case PropertyRevision:
+			info.Spec.Revision = p.Value
+		case PropertyDayOfYear:
+			specDay = p.Value
+		case PropertyYear:
+			specYear = p.Value
+		case PropertyManufacturer:
+			info.Manufacturer = TPMManufacturer(p.Value)
+		case PropertyVendorString1, PropertyVendorString2, PropertyVendorString3, PropertyVendorString4:
+			vendor[p.Property-PropertyVendorString1] = p.Value
+		case PropertyVendorTPMType:
+			info.VendorTPMType = p.Value
+		case PropertyFirmwareVersion1:
+			info.FirmwareVersion |= uint64(p.Value) << 32
+		case PropertyFirmwareVersion2:
+			info.FirmwareVersion |= uint64(p.Value)
+		case PropertyInputBuffer:
+			info.Memory.InputBuffer = p.Value
+		case PropertyHRTransientMin:
+			info.Memory.HRTransientMin = p.Value
+		case PropertyHRPersistentMin:
+			info.NV.HRPersistentMin = p.Value
+		case PropertyHRLoadedMin:
+			info.Memory.HRLoadedMin = p.Value
+		case PropertyActiveSessionsMax:
+			info.Memory.ActiveSessionsMax = p.Value
+		case PropertyPCRCount:
+			info.PCRCount = p.Value
+		case PropertyPCRSelectMin:
+			info.PCRSelectMin = p.Value
+		case PropertyContextGapMax:
+			info.Memory.ContextGapMax = p.Value
+		case PropertyNVCountersMax:
+			info.NV.CountersMax = p.Value
+		case PropertyNVIndexMax:
+			info.NV.IndexMax = p.Value
+		case PropertyMemory:
+			info.Memory.Attrs = MemoryAttributes(p.Value)
+		case PropertyMaxCommandSize:
+			info.Memory.MaxCommandSize = p.Value
+		case PropertyMaxResponseSize:
+			info.Memory.MaxResponseSize = p.Value
+		case PropertyMaxDigest:
+			info.Memory.MaxDigest = p.Value
+		case PropertyMaxObjectContext:
+			info.Memory.MaxObjectContext = p.Value
+		case PropertyMaxSessionContext:
+			info.Memory.MaxSessionContext = p.Value
+		case PropertyNVBufferMax:
+			info.NV.BufferMax = p.Value
+		case PropertyModes:
+			info.Modes = ModeAttributes(p.Value)
+		case PropertyMaxCapBuffer:
+			info.Memory.MaxCapBuffer = p.Value
+		case PropertyPermanent:
+			info.Permanent = PermanentAttributes(p.Value)
+		case PropertyStartupClear:
+			info.StartupClear = StartupClearAttributes(p.Value)
+		case PropertyHRNVIndex:
+			info.NV.HRNVIndex = p.Value
+		case PropertyHRLoaded:
+			info.Memory.HRLoaded = p.Value
+		case PropertyHRLoadedAvail:
+			info.Memory.HRLoadedAvail = p.Value
+		case PropertyHRActive:
+			info.Memory.HRActive = p.Value
+		case PropertyHRActiveAvail:
+			info.Memory.HRActiveAvail = p.Value
+		case PropertyHRTransientAvail:
+			info.Memory.HRTransientAvail = p.Value
+		case PropertyHRPersistent:
+			info.NV.HRPersistent = p.Value
+		case PropertyHRPersistentAvail:
+			info.NV.HRPersistentAvail = p.Value
+		case PropertyNVCounters:
+			info.NV.Counters = p.Value
+		case PropertyNVCountersAvail:
+			info.NV.CountersAvail = p.Value
+		case PropertyLockoutCounter:
+			info.Lockout.Counter = p.Value
+		case PropertyMaxAuthFail:
+			info.Lockout.MaxAuthFail = p.Value
+		case PropertyLockoutInterval:
+			info.Lockout.Interval = p.Value
+		case PropertyLockoutRecovery:
+			info.Lockout.Recovery = p.Value
+		case PropertyNVWriteRecovery:
+			info.NV.WriteRecovery = p.Value
+		}
+	}
+
+	info.VendorString = propertyString(vendor[:]...)
+	if specYear > 0 {
+		info.Spec.Date = time.Date(int(specYear), time.January, int(specDay), 0, 0, 0, 0, time.UTC)
+	}
+	info.Lockout.InLockout = info.Permanent&AttrInLockout != 0
+	info.Lockout.LockoutAuthSet = info.Permanent&AttrLockoutAuthSet != 0
+	info.Lockout.DisableClear = info.Permanent&AttrDisableClear != 0
+
+	return info, nil
+}
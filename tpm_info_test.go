@@ -0,0 +1,230 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// capabilityTCTI is a TCTI that responds to TPM2_GetCapability commands from a fixed set of
+// capabilities, returning at most pageSize values in each response.
+type capabilityTCTI struct {
+	mockTCTI
+	pageSize int
+
+	props    TaggedTPMPropertyList
+	pcrs     PCRSelectionList
+	algs     AlgorithmPropertyList
+	commands CommandAttributesList
+	curves   ECCCurveList
+
+	requests int
+}
+
+func (t *capabilityTCTI) page(n int, first func(i int) bool) (start, end int, moreData bool) {
+	start = sort.Search(n, first)
+	end = start + t.pageSize
+	if end >= n {
+		return start, n, false
+	}
+	return start, end, true
+}
+
+func (t *capabilityTCTI) getCapability(cmd CommandPacket) (ResponsePacket, error) {
+	t.requests++
+
+	_, _, params, err := cmd.Unmarshal(0)
+	if err != nil {
+		return nil, err
+	}
+	var capability Capability
+	var property, count uint32
+	if _, err := mu.UnmarshalFromBytes(params, &capability, &property, &count); err != nil {
+		return nil, err
+	}
+
+	rsp := &CapabilityData{Capability: capability, Data: new(CapabilitiesU)}
+	var moreData bool
+	switch capability {
+	case CapabilityTPMProperties:
+		start, end, more := t.page(len(t.props), func(i int) bool { return uint32(t.props[i].Property) >= property })
+		rsp.Data.TPMProperties, moreData = t.props[start:end], more
+	case CapabilityPCRs:
+		rsp.Data.AssignedPCR = t.pcrs
+	case CapabilityAlgs:
+		start, end, more := t.page(len(t.algs), func(i int) bool { return uint32(t.algs[i].Alg) >= property })
+		rsp.Data.Algorithms, moreData = t.algs[start:end], more
+	case CapabilityCommands:
+		start, end, more := t.page(len(t.commands), func(i int) bool { return uint32(t.commands[i].CommandCode()) >= property })
+		rsp.Data.Command, moreData = t.commands[start:end], more
+	case CapabilityECCCurves:
+		start, end, more := t.page(len(t.curves), func(i int) bool { return uint32(t.curves[i]) >= property })
+		rsp.Data.ECCCurves, moreData = t.curves[start:end], more
+	default:
+		return nil, errors.New("unexpected capability")
+	}
+
+	return makeTestResponse(ResponseSuccess, moreData, rsp), nil
+}
+
+type tpmInfoSuite struct {
+	tcti *capabilityTCTI
+	tpm  *TPMContext
+}
+
+var _ = Suite(&tpmInfoSuite{})
+
+func (s *tpmInfoSuite) SetUpTest(c *C) {
+	s.tcti = &capabilityTCTI{
+		pageSize: 3,
+		props: TaggedTPMPropertyList{
+			{Property: PropertyFamilyIndicator, Value: 0x322e3000},
+			{Property: PropertyLevel, Value: 0},
+			{Property: PropertyRevision, Value: 138},
+			{Property: PropertyDayOfYear, Value: 319},
+			{Property: PropertyYear, Value: 2010},
+			{Property: PropertyManufacturer, Value: uint32(TPMManufacturerIBM)},
+			{Property: PropertyVendorString1, Value: 0x53572020},
+			{Property: PropertyVendorString2, Value: 0x2054504d},
+			{Property: PropertyVendorString3, Value: 0},
+			{Property: PropertyVendorString4, Value: 0},
+			{Property: PropertyVendorTPMType, Value: 1},
+			{Property: PropertyFirmwareVersion1, Value: 0x20191023},
+			{Property: PropertyFirmwareVersion2, Value: 0x00163636},
+			{Property: PropertyInputBuffer, Value: 1024},
+			{Property: PropertyHRTransientMin, Value: 3},
+			{Property: PropertyHRPersistentMin, Value: 7},
+			{Property: PropertyActiveSessionsMax, Value: 64},
+			{Property: PropertyPCRCount, Value: 24},
+			{Property: PropertyPCRSelectMin, Value: 3},
+			{Property: PropertyNVCountersMax, Value: 0},
+			{Property: PropertyNVIndexMax, Value: 2048},
+			{Property: PropertyMemory, Value: uint32(AttrSharedNV | AttrObjectCopiedToRAM)},
+			{Property: PropertyMaxCommandSize, Value: 4096},
+			{Property: PropertyMaxResponseSize, Value: 4096},
+			{Property: PropertyMaxDigest, Value: 64},
+			{Property: PropertyNVBufferMax, Value: 1024},
+			{Property: PropertyModes, Value: 0},
+			{Property: PropertyPermanent, Value: uint32(AttrLockoutAuthSet | AttrInLockout | AttrTPMGeneratedEPS)},
+			{Property: PropertyStartupClear, Value: uint32(AttrPhEnable | AttrShEnable | AttrEhEnable | AttrPhEnableNV | AttrOrderly)},
+			{Property: PropertyHRNVIndex, Value: 5},
+			{Property: PropertyHRPersistent, Value: 2},
+			{Property: PropertyHRPersistentAvail, Value: 5},
+			{Property: PropertyNVCounters, Value: 1},
+			{Property: PropertyLockoutCounter, Value: 2},
+			{Property: PropertyMaxAuthFail, Value: 32},
+			{Property: PropertyLockoutInterval, Value: 7200},
+			{Property: PropertyLockoutRecovery, Value: 86400},
+			{Property: PropertyNVWriteRecovery, Value: 0}},
+		pcrs: PCRSelectionList{
+			{Hash: HashAlgorithmSHA1, Select: []int{}},
+			{Hash: HashAlgorithmSHA256, Select: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23}}},
+		algs: AlgorithmPropertyList{
+			{Alg: AlgorithmRSA, Properties: AttrAsymmetric | AttrObject},
+			{Alg: AlgorithmSHA1, Properties: AttrHash},
+			{Alg: AlgorithmHMAC, Properties: AttrHash | AttrSigning},
+			{Alg: AlgorithmAES, Properties: AttrSymmetric},
+			{Alg: AlgorithmKeyedHash, Properties: AttrHash | AttrEncrypting | AttrSigning},
+			{Alg: AlgorithmSHA256, Properties: AttrHash},
+			{Alg: AlgorithmECC, Properties: AttrAsymmetric | AttrObject}},
+		commands: CommandAttributesList{
+			makeCommandAttributes(CommandNVUndefineSpaceSpecial, AttrNV, 2),
+			makeCommandAttributes(CommandEvictControl, AttrNV, 2),
+			makeCommandAttributes(CommandClear, AttrNV, 1),
+			makeCommandAttributes(CommandCreatePrimary, 0, 1),
+			makeCommandAttributes(CommandGetCapability, 0, 0)},
+		curves: ECCCurveList{ECCCurveNIST_P256, ECCCurveNIST_P384, ECCCurveBN_P256}}
+	s.tcti.handler = s.tcti.getCapability
+	s.tpm, _ = NewTPMContext(s.tcti)
+}
+
+// TestGetCapabilityTPMPropertiesPaging is a regression test for GetCapability paging, which used
+// to add the last returned property to the previous start property rather than starting from
+// the property after it, and which couldn't detect the end of the request because the remaining
+// count is unsigned.
+func (s *tpmInfoSuite) TestGetCapabilityTPMPropertiesPaging(c *C) {
+	props, err := s.tpm.GetCapabilityTPMProperties(PropertyFixed, CapabilityMaxProperties)
+	c.Check(err, IsNil)
+	c.Check(props, DeepEquals, s.tcti.props)
+	c.Check(s.tcti.requests, Equals, (len(s.tcti.props)+s.tcti.pageSize-1)/s.tcti.pageSize)
+}
+
+func (s *tpmInfoSuite) TestGetCapabilityTPMPropertiesPagingLimited(c *C) {
+	props, err := s.tpm.GetCapabilityTPMProperties(PropertyManufacturer, 5)
+	c.Check(err, IsNil)
+	c.Check(props, DeepEquals, s.tcti.props[5:11])
+}
+
+func (s *tpmInfoSuite) TestGetTPMInfo(c *C) {
+	info, err := s.tpm.GetTPMInfo()
+	c.Assert(err, IsNil)
+
+	c.Check(info.Manufacturer, Equals, TPMManufacturerIBM)
+	c.Check(info.VendorString, Equals, "SW   TPM")
+	c.Check(info.VendorTPMType, Equals, uint32(1))
+	c.Check(info.FirmwareVersion, Equals, uint64(0x2019102300163636))
+	c.Check(info.FirmwareVersionString(), Equals, "8217.4131.22.13878")
+
+	c.Check(info.Spec, DeepEquals, TPMSpecVersion{
+		Family:   "2.0",
+		Level:    0,
+		Revision: 138,
+		Date:     time.Date(2010, time.November, 15, 0, 0, 0, 0, time.UTC)})
+
+	c.Check(info.Permanent, Equals, AttrLockoutAuthSet|AttrInLockout|AttrTPMGeneratedEPS)
+	c.Check(info.StartupClear, Equals, AttrPhEnable|AttrShEnable|AttrEhEnable|AttrPhEnableNV|AttrOrderly)
+	c.Check(info.Modes, Equals, ModeAttributes(0))
+
+	c.Check(info.Memory, DeepEquals, TPMMemoryInfo{
+		Attrs:             AttrSharedNV | AttrObjectCopiedToRAM,
+		InputBuffer:       1024,
+		MaxCommandSize:    4096,
+		MaxResponseSize:   4096,
+		MaxDigest:         64,
+		HRTransientMin:    3,
+		ActiveSessionsMax: 64})
+	c.Check(info.NV, DeepEquals, TPMNVInfo{
+		IndexMax:          2048,
+		BufferMax:         1024,
+		HRNVIndex:         5,
+		Counters:          1,
+		HRPersistentMin:   7,
+		HRPersistent:      2,
+		HRPersistentAvail: 5})
+	c.Check(info.Lockout, DeepEquals, TPMLockoutInfo{
+		InLockout:      true,
+		Counter:        2,
+		MaxAuthFail:    32,
+		Interval:       7200,
+		Recovery:       86400,
+		LockoutAuthSet: true})
+
+	c.Check(info.PCRCount, Equals, uint32(24))
+	c.Check(info.PCRSelectMin, Equals, uint32(3))
+	c.Check(info.PCRBanks, DeepEquals, s.tcti.pcrs)
+	c.Check(info.ActiveBanks, DeepEquals, []HashAlgorithmId{HashAlgorithmSHA256})
+
+	c.Check(info.Algorithms, DeepEquals, s.tcti.algs)
+	c.Check(info.Commands, DeepEquals, s.tcti.commands)
+	c.Check(info.ECCCurves, DeepEquals, s.tcti.curves)
+	c.Check(info.Properties, DeepEquals, s.tcti.props)
+}
+
+func (s *tpmInfoSuite) TestGetTPMInfoNoVariableProperties(c *C) {
+	s.tcti.props = s.tcti.props[:27]
+
+	info, err := s.tpm.GetTPMInfo()
+	c.Assert(err, IsNil)
+	c.Check(info.Properties, DeepEquals, s.tcti.props)
+	c.Check(info.Permanent, Equals, PermanentAttributes(0))
+	c.Check(info.Lockout, DeepEquals, TPMLockoutInfo{})
+}
@@ -0,0 +1,344 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"golang.org/x/xerrors"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/go-tpm2/testutil"
+)
+
+type defaultSessionSuite struct {
+	testutil.TPMTest
+}
+
+func (s *defaultSessionSuite) SetUpSuite(c *C) {
+	s.TPMFeatures = testutil.TPMFeatureOwnerHierarchy | testutil.TPMFeatureNV
+}
+
+var _ = Suite(&defaultSessionSuite{})
+
+func (s *defaultSessionSuite) lastCommandAuthArea(c *C) []AuthCommand {
+	_, authArea, _ := s.LastCommand(c).UnmarshalCommand(c)
+	return authArea
+}
+
+func (s *defaultSessionSuite) TestUsedForAuthAndParamEncryption(c *C) {
+	primary := s.CreateStoragePrimaryKeyRSA(c)
+
+	session, err := s.TPM.StartDefaultSession(primary)
+	c.Assert(err, IsNil)
+	c.Check(s.TPM.DefaultSession(), Equals, session)
+
+	secret := []byte("super secret data")
+	priv, pub, _, _, _, err := s.TPM.Create(primary, &SensitiveCreate{Data: secret}, testutil.NewSealedObjectTemplate(), nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	authArea := s.lastCommandAuthArea(c)
+	c.Assert(authArea, HasLen, 1)
+	c.Check(authArea[0].SessionHandle, Equals, session.Handle())
+	c.Check(authArea[0].SessionAttributes, Equals, AttrContinueSession|AttrCommandEncrypt|AttrResponseEncrypt)
+
+	object, err := s.TPM.Load(primary, priv, pub, nil)
+	c.Assert(err, IsNil)
+
+	data, err := s.TPM.Unseal(object, nil)
+	c.Check(err, IsNil)
+	c.Check(data, DeepEquals, SensitiveData(secret))
+
+	authArea = s.lastCommandAuthArea(c)
+	c.Assert(authArea, HasLen, 1)
+	c.Check(authArea[0].SessionHandle, Equals, session.Handle())
+	c.Check(authArea[0].SessionAttributes, Equals, AttrContinueSession|AttrResponseEncrypt)
+}
+
+func (s *defaultSessionSuite) TestUsedForParamEncryptionOnly(c *C) {
+	primary := s.CreateStoragePrimaryKeyRSA(c)
+
+	session, err := s.TPM.StartDefaultSession(primary)
+	c.Assert(err, IsNil)
+
+	_, err = s.TPM.GetRandom(32)
+	c.Check(err, IsNil)
+
+	authArea := s.lastCommandAuthArea(c)
+	c.Assert(authArea, HasLen, 1)
+	c.Check(authArea[0].SessionHandle, Equals, session.Handle())
+	c.Check(authArea[0].SessionAttributes, Equals, AttrContinueSession|AttrResponseEncrypt)
+
+	_, err = s.TPM.ReadClock()
+	c.Check(err, IsNil)
+	c.Check(s.lastCommandAuthArea(c), HasLen, 0)
+}
+
+func (s *defaultSessionSuite) TestExplicitEncryptSession(c *C) {
+	primary := s.CreateStoragePrimaryKeyRSA(c)
+
+	session, err := s.TPM.StartDefaultSession(primary)
+	c.Assert(err, IsNil)
+
+	symmetric := &SymDef{
+		Algorithm: SymAlgorithmAES,
+		KeyBits:   &SymKeyBitsU{Sym: 128},
+		Mode:      &SymModeU{Sym: SymModeCFB}}
+	other := s.StartAuthSession(c, primary, nil, SessionTypeHMAC, symmetric, HashAlgorithmSHA256)
+
+	_, err = s.TPM.GetRandom(32, other.WithAttrs(AttrContinueSession|AttrResponseEncrypt))
+	c.Check(err, IsNil)
+
+	authArea := s.lastCommandAuthArea(c)
+	c.Assert(authArea, HasLen, 1)
+	c.Check(authArea[0].SessionHandle, Equals, other.Handle())
+
+	_, _, _, _, _, err = s.TPM.Create(primary, nil, testutil.NewSealedObjectTemplate(), nil, nil, nil, other.WithAttrs(AttrContinueSession|AttrResponseEncrypt))
+	c.Check(err, IsNil)
+
+	authArea = s.lastCommandAuthArea(c)
+	c.Assert(authArea, HasLen, 2)
+	c.Check(authArea[0].SessionHandle, Equals, session.Handle())
+	c.Check(authArea[0].SessionAttributes, Equals, AttrContinueSession|AttrCommandEncrypt)
+	c.Check(authArea[1].SessionHandle, Equals, other.Handle())
+}
+
+func (s *defaultSessionSuite) TestAutoDiscoverKey(c *C) {
+	if s.NextAvailableHandle(c, 0x81000001) != 0x81000001 {
+		c.Skip("persistent handle 0x81000001 is in use")
+	}
+	primary := s.CreateStoragePrimaryKeyRSA(c)
+	s.EvictControl(c, HandleOwner, primary, 0x81000001)
+
+	session, err := s.TPM.StartDefaultSession(nil)
+	c.Assert(err, IsNil)
+	c.Check(session.Handle().Type(), Equals, HandleTypeHMACSession)
+
+	_, err = s.TPM.GetRandom(32)
+	c.Check(err, IsNil)
+}
+
+func (s *defaultSessionSuite) TestNotUsedAfterFlush(c *C) {
+	primary := s.CreateStoragePrimaryKeyRSA(c)
+
+	session, err := s.TPM.StartDefaultSession(primary)
+	c.Assert(err, IsNil)
+	c.Check(s.TPM.FlushContext(session), IsNil)
+
+	_, err = s.TPM.GetRandom(32)
+	c.Check(err, IsNil)
+	c.Check(s.lastCommandAuthArea(c), HasLen, 0)
+}
+
+func (s *defaultSessionSuite) TestSetDefaultSessionNil(c *C) {
+	primary := s.CreateStoragePrimaryKeyRSA(c)
+
+	_, err := s.TPM.StartDefaultSession(primary)
+	c.Assert(err, IsNil)
+	s.TPM.SetDefaultSession(nil)
+	c.Check(s.TPM.DefaultSession(), IsNil)
+
+	_, err = s.TPM.GetRandom(32)
+	c.Check(err, IsNil)
+	c.Check(s.lastCommandAuthArea(c), HasLen, 0)
+}
+
+// slowTCTI is a TCTI that doesn't return a response until it is released.
+type slowTCTI struct {
+	mockTCTI
+	release chan ResponsePacket
+	writes  int32
+}
+
+func newSlowTCTI() *slowTCTI {
+	t := &slowTCTI{release: make(chan ResponsePacket, 1)}
+	t.handler = func(_ CommandPacket) (ResponsePacket, error) {
+		atomic.AddInt32(&t.writes, 1)
+		return <-t.release, nil
+	}
+	return t
+}
+
+type cancellableTCTI struct {
+	*slowTCTI
+	cancelled bool
+}
+
+func (t *cancellableTCTI) Cancel() error {
+	t.cancelled = true
+	t.release <- makeTestResponse((&TPMWarning{Code: WarningCanceled}).ResponseCode())
+	return nil
+}
+
+type commandContextSuite struct{}
+
+var _ = Suite(&commandContextSuite{})
+
+func (s *commandContextSuite) TestRunCommandContext(c *C) {
+	tcti := newSlowTCTI()
+	tpm, _ := NewTPMContext(tcti)
+
+	tcti.release <- makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var random Digest
+	c.Check(tpm.RunCommandContext(ctx, CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random), IsNil)
+	c.Check(random, DeepEquals, Digest{1, 2, 3, 4})
+}
+
+func (s *commandContextSuite) TestRunCommandContextAlreadyCancelled(c *C) {
+	tcti := newSlowTCTI()
+	tpm, _ := NewTPMContext(tcti)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := tpm.RunCommandContext(ctx, CommandGetRandom, nil, Delimiter, uint16(4))
+	c.Check(err, ErrorMatches, "cannot execute command: context canceled")
+	c.Check(xerrors.Is(err, context.Canceled), testutil.IsTrue)
+	c.Check(atomic.LoadInt32(&tcti.writes), Equals, int32(0))
+}
+
+func (s *commandContextSuite) TestAbortWithCancel(c *C) {
+	tcti := &cancellableTCTI{slowTCTI: newSlowTCTI()}
+	tpm, _ := NewTPMContext(tcti)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := tpm.RunCommandBytesContext(ctx, MarshalCommandPacket(CommandCreatePrimary, nil, nil, nil))
+	c.Check(err, ErrorMatches, "command was aborted: context deadline exceeded")
+	c.Check(xerrors.Is(err, context.DeadlineExceeded), testutil.IsTrue)
+	c.Check(tcti.cancelled, testutil.IsTrue)
+
+	// The TPMContext should still be usable, and the response to the aborted command should have been drained.
+	tcti.release <- makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4})
+	var random Digest
+	c.Check(tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random), IsNil)
+	c.Check(random, DeepEquals, Digest{1, 2, 3, 4})
+}
+
+func (s *commandContextSuite) TestAbortWithoutCancel(c *C) {
+	tcti := newSlowTCTI()
+	tpm, _ := NewTPMContext(tcti)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := tpm.RunCommandBytesContext(ctx, MarshalCommandPacket(CommandCreatePrimary, nil, nil, nil))
+	c.Check(err, ErrorMatches, "command was aborted: context deadline exceeded")
+
+	// A subsequent command with a deadline should fail if the aborted command hasn't completed.
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel2()
+	_, err = tpm.RunCommandBytesContext(ctx2, MarshalCommandPacket(CommandGetRandom, nil, nil, nil))
+	c.Check(err, ErrorMatches, "cannot wait for a previously aborted command to complete: context deadline exceeded")
+	c.Check(atomic.LoadInt32(&tcti.writes), Equals, int32(1))
+
+	// Complete the aborted command, and check that its response is discarded.
+	tcti.release <- makeTestResponse(ResponseSuccess, Handle(0x80000000))
+	go func() {
+		tcti.release <- makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4})
+	}()
+
+	var random Digest
+	c.Check(tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random), IsNil)
+	c.Check(random, DeepEquals, Digest{1, 2, 3, 4})
+}
+
+func (s *commandContextSuite) TestWithContext(c *C) {
+	tcti := newSlowTCTI()
+	tpm, _ := NewTPMContext(tcti)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tcti.release <- makeTestResponse(ResponseSuccess)
+	c.Check(tpm.WithContext(ctx).StirRandom(SensitiveData("foo")), IsNil)
+	c.Check(atomic.LoadInt32(&tcti.writes), Equals, int32(1))
+}
+
+func (s *commandContextSuite) TestWithContextAbortsTypedCommand(c *C) {
+	tcti := &cancellableTCTI{slowTCTI: newSlowTCTI()}
+	tpm, _ := NewTPMContext(tcti)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _, _, _, _, err := tpm.WithContext(ctx).CreatePrimary(tpm.OwnerHandleContext(), nil, testutil.NewRSAStorageKeyTemplate(), nil, nil, nil)
+	c.Check(err, ErrorMatches, "command was aborted: context deadline exceeded")
+	c.Check(xerrors.Is(err, context.DeadlineExceeded), testutil.IsTrue)
+	c.Check(tcti.cancelled, testutil.IsTrue)
+
+	// The original TPMContext isn't bound to the context.
+	tcti.release <- makeTestResponse(ResponseSuccess)
+	c.Check(tpm.StirRandom(SensitiveData("foo")), IsNil)
+}
+
+func (s *commandContextSuite) TestWithContextAlreadyCancelled(c *C) {
+	tcti := newSlowTCTI()
+	tpm, _ := NewTPMContext(tcti)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := tpm.WithContext(ctx).StirRandom(SensitiveData("foo"))
+	c.Check(err, ErrorMatches, "cannot execute command: context canceled")
+	c.Check(xerrors.Is(err, context.Canceled), testutil.IsTrue)
+	c.Check(atomic.LoadInt32(&tcti.writes), Equals, int32(0))
+}
+
+func (s *commandContextSuite) TestWithContextTransaction(c *C) {
+	tcti := newSlowTCTI()
+	tpm, _ := NewTPMContext(tcti)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := tpm.WithContext(ctx).Transaction(func(tpm *TPMContext) error {
+		cancel()
+		return tpm.StirRandom(SensitiveData("foo"))
+	})
+	c.Check(err, ErrorMatches, "cannot execute command: context canceled")
+	c.Check(atomic.LoadInt32(&tcti.writes), Equals, int32(0))
+}
+
+type mssimCancelSuite struct {
+	testutil.TPMSimulatorTest
+}
+
+var _ = Suite(&mssimCancelSuite{})
+
+func (s *mssimCancelSuite) TestAbortCreatePrimary(c *C) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	var objectHandle Handle
+	var outPublic *Public
+	var creationData *CreationData
+	var creationHash Digest
+	var creationTicket TkCreation
+	var name Name
+	err := s.TPM.RunCommandContext(ctx, CommandCreatePrimary, nil,
+		ResourceContextWithSession{Context: s.TPM.OwnerHandleContext()}, Delimiter,
+		mu.Sized((*SensitiveCreate)(nil)), mu.Sized(testutil.NewRSAStorageKeyTemplate()), Data(nil), PCRSelectionList(nil), Delimiter,
+		&objectHandle, Delimiter,
+		mu.Sized(&outPublic), mu.Sized(&creationData), &creationHash, &creationTicket, &name)
+	if err == nil {
+		c.Skip("command completed before it could be aborted")
+	}
+	c.Check(xerrors.Is(err, context.DeadlineExceeded), testutil.IsTrue)
+
+	// The TPMContext should still be usable.
+	_, err = s.TPM.GetRandom(16)
+	c.Check(err, IsNil)
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+)
+
+type transaction struct {
+	done bool
+}
+
+// lockCommands acquires the lock that serializes command execution, returning a function that releases it. If this
+// TPMContext was created for a transaction, the lock is already held and this does nothing.
+func (t *TPMContext) lockCommands(ctx context.Context) (unlock func(), err error) {
+	if t.tx != nil {
+		if t.tx.done {
+			panic("TPMContext used after the end of the transaction for which it was created")
+		}
+		return func() {}, nil
+	}
+
+	if ctx.Done() == nil {
+		t.cmdLock <- struct{}{}
+	} else {
+		if err := ctx.Err(); err != nil {
+			return nil, xerrors.Errorf("cannot execute command: %w", err)
+		}
+		select {
+		case t.cmdLock <- struct{}{}:
+		case <-ctx.Done():
+			return nil, xerrors.Errorf("cannot wait for another command or transaction to complete: %w", ctx.Err())
+		}
+	}
+
+	return func() { <-t.cmdLock }, nil
+}
+
+// Transaction executes the supplied function with exclusive access to the TPM, so that commands executed by other goroutines
+// are not interleaved with the commands that it executes. This is useful for sequences of commands that depend on each other,
+// such as starting a policy session, executing policy assertions and then using the session for authorization.
+//
+// The function is supplied with a TPMContext that must be used to execute all commands for the duration of the transaction. It
+// shares all of its state with this TPMContext. Whilst the transaction is in progress, executing commands with this TPMContext
+// or calling Transaction on it blocks until the transaction completes, regardless of which goroutine does so. Doing this from
+// inside the function will therefore deadlock. The supplied TPMContext must not be retained, and it will panic if it is used to
+// execute commands after the function returns. Calling Transaction on the supplied TPMContext executes the function as part of
+// the current transaction.
+//
+// The error returned from the function is returned to the caller.
+func (t *TPMContext) Transaction(fn func(tpm *TPMContext) error) error {
+	return t.TransactionContext(t.boundContext(), fn)
+}
+
+// TransactionContext is a variant of Transaction that returns an error if the supplied context is cancelled or its deadline
+// expires before the transaction can begin. The context is not used for commands executed as part of the transaction. If this
+// TPMContext is bound to a context with WithContext, the TPMContext supplied to the function is bound to the same context.
+func (t *TPMContext) TransactionContext(ctx context.Context, fn func(tpm *TPMContext) error) error {
+	if t.tx != nil {
+		return fn(t)
+	}
+
+	unlock, err := t.lockCommands(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tx := new(transaction)
+	defer func() { tx.done = true }()
+	return fn(&TPMContext{tpmContextState: t.tpmContextState, tx: tx, ctx: t.ctx})
+}
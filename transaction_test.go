@@ -0,0 +1,329 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/testutil"
+	"github.com/canonical/go-tpm2/util"
+)
+
+// loggingTCTI responds to TPM2_GetCapability with TPM properties that limit digests and NV buffers to 4 bytes, and to
+// every other command with a 4-byte digest. It records the command code of each command.
+type loggingTCTI struct {
+	mockTCTI
+	delay    time.Duration
+	commands []CommandCode
+}
+
+func newLoggingTCTI(delay time.Duration) *loggingTCTI {
+	t := &loggingTCTI{delay: delay}
+	t.handler = func(cmd CommandPacket) (ResponsePacket, error) {
+		commandCode, err := cmd.GetCommandCode()
+		if err != nil {
+			return nil, err
+		}
+		t.commands = append(t.commands, commandCode)
+		time.Sleep(t.delay)
+		if commandCode == CommandGetCapability {
+			return makeTestResponse(ResponseSuccess, false, &CapabilityData{
+				Capability: CapabilityTPMProperties,
+				Data: &CapabilitiesU{TPMProperties: TaggedTPMPropertyList{
+					{Property: PropertyMaxDigest, Value: 4},
+					{Property: PropertyNVBufferMax, Value: 4}}}}), nil
+		}
+		return makeTestResponse(ResponseSuccess, Digest{1, 2, 3, 4}), nil
+	}
+	return t
+}
+
+type transactionSuite struct {
+	tcti *loggingTCTI
+	tpm  *TPMContext
+}
+
+var _ = Suite(&transactionSuite{})
+
+func (s *transactionSuite) SetUpTest(c *C) {
+	s.tcti = newLoggingTCTI(time.Millisecond)
+	s.tpm, _ = NewTPMContext(s.tcti)
+}
+
+func (s *transactionSuite) getRandom(tpm *TPMContext) error {
+	var random Digest
+	return tpm.RunCommand(CommandGetRandom, nil, Delimiter, uint16(4), Delimiter, Delimiter, &random)
+}
+
+func (s *transactionSuite) TestConcurrentCommands(c *C) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				errs <- s.getRandom(s.tpm)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		c.Check(err, IsNil)
+	}
+	c.Check(s.tcti.commands, HasLen, 40)
+}
+
+func (s *transactionSuite) TestTransactionIsNotInterleaved(c *C) {
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				c.Check(s.getRandom(s.tpm), IsNil)
+			}
+		}()
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	err := s.tpm.Transaction(func(tpm *TPMContext) error {
+		for i := 0; i < 3; i++ {
+			if err := tpm.RunCommand(CommandReadClock, nil, Delimiter, Delimiter, Delimiter, new(Digest)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	c.Check(err, IsNil)
+
+	close(done)
+	wg.Wait()
+
+	first := -1
+	for i, cc := range s.tcti.commands {
+		if cc == CommandReadClock {
+			first = i
+			break
+		}
+	}
+	c.Assert(first, Not(Equals), -1)
+	c.Assert(len(s.tcti.commands) >= first+3, testutil.IsTrue)
+	c.Check(s.tcti.commands[first:first+3], DeepEquals, []CommandCode{CommandReadClock, CommandReadClock, CommandReadClock})
+}
+
+func (s *transactionSuite) TestTransactionReturnsError(c *C) {
+	err := s.tpm.Transaction(func(tpm *TPMContext) error {
+		return errors.New("some error")
+	})
+	c.Check(err, ErrorMatches, "some error")
+
+	// The lock should have been released.
+	c.Check(s.getRandom(s.tpm), IsNil)
+}
+
+func (s *transactionSuite) TestNestedTransaction(c *C) {
+	err := s.tpm.Transaction(func(tpm *TPMContext) error {
+		return tpm.Transaction(func(tpm2 *TPMContext) error {
+			c.Check(tpm2, Equals, tpm)
+			return s.getRandom(tpm2)
+		})
+	})
+	c.Check(err, IsNil)
+	c.Check(s.tcti.commands, DeepEquals, []CommandCode{CommandGetRandom})
+}
+
+func (s *transactionSuite) TestTransactionSharesState(c *C) {
+	s.tpm.SetMaxSubmissions(2)
+	err := s.tpm.Transaction(func(tpm *TPMContext) error {
+		c.Check(tpm.OwnerHandleContext(), Equals, s.tpm.OwnerHandleContext())
+		return nil
+	})
+	c.Check(err, IsNil)
+}
+
+func (s *transactionSuite) TestUseAfterTransaction(c *C) {
+	var tx *TPMContext
+	c.Check(s.tpm.Transaction(func(tpm *TPMContext) error {
+		tx = tpm
+		return nil
+	}), IsNil)
+
+	c.Check(func() { s.getRandom(tx) }, PanicMatches, "TPMContext used after the end of the transaction for which it was created")
+}
+
+func (s *transactionSuite) TestTransactionContextWaitCancelled(c *C) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := s.tpm.Transaction(func(tpm *TPMContext) error {
+		return s.tpm.TransactionContext(ctx, func(tpm *TPMContext) error {
+			return nil
+		})
+	})
+	c.Check(err, ErrorMatches, "cannot wait for another command or transaction to complete: context deadline exceeded")
+}
+
+func (s *transactionSuite) TestOuterTPMContextBlocksDuringTransaction(c *C) {
+	errs := make(chan error)
+	err := s.tpm.Transaction(func(tpm *TPMContext) error {
+		go func() {
+			errs <- s.getRandom(s.tpm)
+		}()
+
+		time.Sleep(5 * time.Millisecond)
+		if err := tpm.RunCommand(CommandReadClock, nil, Delimiter, Delimiter, Delimiter, new(Digest)); err != nil {
+			return err
+		}
+
+		select {
+		case err := <-errs:
+			c.Errorf("command completed during transaction (err: %v)", err)
+		default:
+		}
+		return nil
+	})
+	c.Check(err, IsNil)
+	c.Check(<-errs, IsNil)
+	c.Check(s.tcti.commands, DeepEquals, []CommandCode{CommandReadClock, CommandGetRandom})
+}
+
+func (s *transactionSuite) TestGetRandomChunksAreNotInterleaved(c *C) {
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				c.Check(s.tpm.RunCommand(CommandReadClock, nil, Delimiter, Delimiter, Delimiter, new(Digest)), IsNil)
+			}
+		}()
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	random, err := s.tpm.GetRandom(16)
+	c.Check(err, IsNil)
+	c.Check(random, DeepEquals, []byte{1, 2, 3, 4, 1, 2, 3, 4, 1, 2, 3, 4, 1, 2, 3, 4})
+
+	close(done)
+	wg.Wait()
+
+	first := -1
+	for i, cc := range s.tcti.commands {
+		if cc == CommandGetRandom {
+			first = i
+			break
+		}
+	}
+	c.Assert(first, Not(Equals), -1)
+	c.Assert(len(s.tcti.commands) >= first+4, testutil.IsTrue)
+	c.Check(s.tcti.commands[first:first+4], DeepEquals, []CommandCode{CommandGetRandom, CommandGetRandom, CommandGetRandom, CommandGetRandom})
+}
+
+type transactionTPMSuite struct {
+	testutil.TPMTest
+}
+
+func (s *transactionTPMSuite) SetUpSuite(c *C) {
+	s.TPMFeatures = testutil.TPMFeatureOwnerHierarchy
+}
+
+var _ = Suite(&transactionTPMSuite{})
+
+func (s *transactionTPMSuite) TestConcurrentSessionUse(c *C) {
+	session := s.StartAuthSession(c, nil, nil, SessionTypeHMAC, nil, HashAlgorithmSHA256).WithAttrs(AttrContinueSession)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := s.TPM.GetRandom(16, session)
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		c.Check(err, IsNil)
+	}
+}
+
+func (s *transactionTPMSuite) TestConcurrentPolicySequences(c *C) {
+	primary := s.CreateStoragePrimaryKeyRSA(c)
+
+	trial := util.ComputeAuthPolicy(HashAlgorithmSHA256)
+	trial.PolicyAuthValue()
+
+	template := testutil.NewSealedObjectTemplate()
+	template.Attrs &^= AttrUserWithAuth
+	template.AuthPolicy = trial.GetDigest()
+
+	priv, pub, _, _, _, err := s.TPM.Create(primary, &SensitiveCreate{Data: []byte("secret")}, template, nil, nil, nil)
+	c.Assert(err, IsNil)
+	object, err := s.TPM.Load(primary, priv, pub, nil)
+	c.Assert(err, IsNil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				errs <- s.TPM.Transaction(func(tpm *TPMContext) error {
+					session, err := tpm.StartAuthSession(nil, nil, SessionTypePolicy, nil, HashAlgorithmSHA256)
+					if err != nil {
+						return err
+					}
+					defer tpm.FlushContext(session)
+
+					if err := tpm.PolicyAuthValue(session); err != nil {
+						return err
+					}
+					data, err := tpm.Unseal(object, session)
+					if err != nil {
+						return err
+					}
+					if !bytes.Equal(data, []byte("secret")) {
+						return errors.New("unexpected data")
+					}
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		c.Check(err, IsNil)
+	}
+}
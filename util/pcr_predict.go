@@ -0,0 +1,233 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// PCREvent corresponds to a single measurement from a replayed event log. It
+// contains the index of the PCR that was extended and the digests that were
+// extended to each PCR bank.
+type PCREvent struct {
+	PCR     int                 // The PCR that was extended
+	Digests tpm2.TaggedHashList // The digests extended to each PCR bank
+}
+
+// Digest returns the digest extended to the PCR bank associated with the
+// specified algorithm, or nil if this event doesn't contain one.
+func (e *PCREvent) Digest(alg tpm2.HashAlgorithmId) tpm2.Digest {
+	for _, d := range e.Digests {
+		if d.HashAlg == alg {
+			return d.Digest
+		}
+	}
+	return nil
+}
+
+// PCREventReplacement describes a change to a replayed event log, and is used
+// to predict the PCR values for a future boot configuration.
+type PCREventReplacement struct {
+	// Match is called for every event in the log, and should return true if
+	// the supplied event is to be replaced. The index of the event in the log
+	// is supplied along with the event.
+	Match func(index int, event *PCREvent) bool
+
+	// Events are the events that a matched event is replaced with. If this is
+	// empty, the matched event is removed from the log.
+	Events []*PCREvent
+}
+
+// MatchPCREventDigest returns a function for PCREventReplacement.Match that
+// matches events for the specified PCR that contain the specified digest.
+// This is useful for replacing the measurement of a specific component, such
+// as a boot loader or kernel.
+func MatchPCREventDigest(pcr int, digest tpm2.TaggedHash) func(int, *PCREvent) bool {
+	return func(_ int, event *PCREvent) bool {
+		if event.PCR != pcr {
+			return false
+		}
+		return bytes.Equal(event.Digest(digest.HashAlg), digest.Digest)
+	}
+}
+
+// PCRPredictor computes the PCR values that would result from replaying an
+// event log, with optional changes applied to it. This makes it possible to
+// compute the expected PCR values for a future boot configuration, such as
+// after an update to a boot component, without having to reboot. The predicted
+// values can be supplied to ComputePCRDigest in order to compute inputs for
+// TrialAuthPolicy.PolicyPCR.
+type PCRPredictor struct {
+	algs     []tpm2.HashAlgorithmId
+	log      []*PCREvent
+	initial  tpm2.PCRValues
+	pcrCount int
+}
+
+// pcClientPCRCount is the number of PCRs in each bank defined by the TCG PC
+// Client Platform TPM Profile specification.
+const pcClientPCRCount = 24
+
+// NewPCRPredictor creates a new context for predicting PCR values for the
+// specified PCR banks from the supplied event log. It will panic if any of
+// the specified algorithms are not available.
+//
+// The initial value of each PCR is zero, except for PCRs 17 to 22 which
+// are initialized to all ones as defined in the TCG PC Client Platform
+// Firmware Profile specification. The initial values can be changed with
+// SetInitialValue.
+//
+// Each PCR bank is assumed to contain the 24 PCRs defined by the TCG PC
+// Client Platform TPM Profile specification. This can be changed with
+// SetPCRCount.
+func NewPCRPredictor(algs []tpm2.HashAlgorithmId, log []*PCREvent) *PCRPredictor {
+	for _, alg := range algs {
+		if !alg.Available() {
+			panic(fmt.Sprintf("digest algorithm %v is not available", alg))
+		}
+	}
+	return &PCRPredictor{algs: algs, log: log, initial: make(tpm2.PCRValues), pcrCount: pcClientPCRCount}
+}
+
+// SetPCRCount sets the number of PCRs in each PCR bank, for platforms that
+// don't implement 24 PCRs. This can be obtained from the TPM with the
+// tpm2.PropertyPCRCount property. It will panic if n is not positive.
+func (p *PCRPredictor) SetPCRCount(n int) {
+	if n <= 0 {
+		panic("invalid PCR count")
+	}
+	p.pcrCount = n
+}
+
+func (p *PCRPredictor) validPCR(pcr int) bool {
+	return pcr >= 0 && pcr < p.pcrCount
+}
+
+// SetInitialValue sets the value that the specified PCR in the specified PCR
+// bank has before any events are replayed. This is useful for PCR 0, which
+// may be initialized with the locality from which the TPM was started. It will
+// panic if the PCR index is out of range or the digest has the wrong size.
+func (p *PCRPredictor) SetInitialValue(alg tpm2.HashAlgorithmId, pcr int, value tpm2.Digest) {
+	if !p.validPCR(pcr) {
+		panic("invalid PCR index")
+	}
+	if len(value) != alg.Size() {
+		panic("invalid digest length")
+	}
+	p.initial.SetValue(alg, pcr, value)
+}
+
+func (p *PCRPredictor) initialValue(alg tpm2.HashAlgorithmId, pcr int) tpm2.Digest {
+	if v, ok := p.initial[alg][pcr]; ok {
+		return v
+	}
+
+	v := make(tpm2.Digest, alg.Size())
+	if pcr >= 17 && pcr <= 22 {
+		for i := range v {
+			v[i] = 0xff
+		}
+	}
+	return v
+}
+
+func (p *PCRPredictor) replay(replacements []*PCREventReplacement) []*PCREvent {
+	var log []*PCREvent
+
+	for i, event := range p.log {
+		replaced := false
+		for _, r := range replacements {
+			if !r.Match(i, event) {
+				continue
+			}
+			log = append(log, r.Events...)
+			replaced = true
+			break
+		}
+		if !replaced {
+			log = append(log, event)
+		}
+	}
+
+	return log
+}
+
+// Predict computes the PCR values for each of the PCR banks associated with
+// this predictor, after applying the supplied replacements to the event log.
+// The first replacement that matches an event is used. The returned values
+// contain an entry for each PCR that is extended by the event log or that
+// has an initial value set with SetInitialValue.
+//
+// An error will be returned if any event is for a PCR index that is out of
+// range, if any event does not contain a digest for one of the PCR banks
+// associated with this predictor, or if any digest has the wrong size.
+func (p *PCRPredictor) Predict(replacements ...*PCREventReplacement) (tpm2.PCRValues, error) {
+	values := make(tpm2.PCRValues)
+	for _, alg := range p.algs {
+		values[alg] = make(map[int]tpm2.Digest)
+		for pcr, v := range p.initial[alg] {
+			values[alg][pcr] = v
+		}
+	}
+
+	for i, event := range p.replay(replacements) {
+		if !p.validPCR(event.PCR) {
+			return nil, fmt.Errorf("invalid PCR index %d for event %d", event.PCR, i)
+		}
+
+		for _, alg := range p.algs {
+			digest := event.Digest(alg)
+			switch {
+			case digest == nil:
+				return nil, fmt.Errorf("event %d has no digest for PCR bank %v", i, alg)
+			case len(digest) != alg.Size():
+				return nil, fmt.Errorf("event %d has an invalid digest size for PCR bank %v", i, alg)
+			}
+
+			current, ok := values[alg][event.PCR]
+			if !ok {
+				current = p.initialValue(alg, event.PCR)
+			}
+
+			h := alg.NewHash()
+			h.Write(current)
+			h.Write(digest)
+			values[alg][event.PCR] = h.Sum(nil)
+		}
+	}
+
+	return values, nil
+}
+
+// PredictDigest computes the PCR values for the selected PCRs after applying
+// the supplied replacements to the event log, and then computes a PCR digest
+// from them using the specified algorithm. The result is suitable for passing
+// to TrialAuthPolicy.PolicyPCR along with the supplied selection.
+func (p *PCRPredictor) PredictDigest(alg tpm2.HashAlgorithmId, pcrs tpm2.PCRSelectionList, replacements ...*PCREventReplacement) (tpm2.Digest, error) {
+	values, err := p.Predict(replacements...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range pcrs {
+		if _, ok := values[s.Hash]; !ok {
+			return nil, errors.New("selection contains a PCR bank that is not associated with this predictor")
+		}
+		for _, pcr := range s.Select {
+			if !p.validPCR(pcr) {
+				return nil, fmt.Errorf("selection contains an invalid PCR index %d", pcr)
+			}
+			if _, ok := values[s.Hash][pcr]; !ok {
+				values[s.Hash][pcr] = p.initialValue(s.Hash, pcr)
+			}
+		}
+	}
+
+	return ComputePCRDigest(alg, pcrs, values)
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util_test
+
+import (
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	. "github.com/canonical/go-tpm2/util"
+)
+
+type pcrPredictSuite struct{}
+
+var _ = Suite(&pcrPredictSuite{})
+
+func extend(alg crypto.Hash, initial []byte, digests ...[]byte) tpm2.Digest {
+	v := initial
+	if v == nil {
+		v = make([]byte, alg.Size())
+	}
+	for _, d := range digests {
+		h := alg.New()
+		h.Write(v)
+		h.Write(d)
+		v = h.Sum(nil)
+	}
+	return v
+}
+
+func measure(alg crypto.Hash, data string) tpm2.Digest {
+	h := alg.New()
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func makeEvent(pcr int, data string) *PCREvent {
+	return &PCREvent{
+		PCR: pcr,
+		Digests: tpm2.TaggedHashList{
+			{HashAlg: tpm2.HashAlgorithmSHA1, Digest: measure(crypto.SHA1, data)},
+			{HashAlg: tpm2.HashAlgorithmSHA256, Digest: measure(crypto.SHA256, data)}}}
+}
+
+func (s *pcrPredictSuite) TestPredictNoReplacements(c *C) {
+	log := []*PCREvent{makeEvent(4, "shim"), makeEvent(7, "db"), makeEvent(4, "kernel")}
+
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA1, tpm2.HashAlgorithmSHA256}, log)
+	values, err := p.Predict()
+	c.Check(err, IsNil)
+	c.Check(values, DeepEquals, tpm2.PCRValues{
+		tpm2.HashAlgorithmSHA1: {
+			4: extend(crypto.SHA1, nil, measure(crypto.SHA1, "shim"), measure(crypto.SHA1, "kernel")),
+			7: extend(crypto.SHA1, nil, measure(crypto.SHA1, "db"))},
+		tpm2.HashAlgorithmSHA256: {
+			4: extend(crypto.SHA256, nil, measure(crypto.SHA256, "shim"), measure(crypto.SHA256, "kernel")),
+			7: extend(crypto.SHA256, nil, measure(crypto.SHA256, "db"))}})
+}
+
+func (s *pcrPredictSuite) TestPredictWithReplacement(c *C) {
+	log := []*PCREvent{makeEvent(4, "shim"), makeEvent(7, "db"), makeEvent(4, "kernel")}
+
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, log)
+	values, err := p.Predict(&PCREventReplacement{
+		Match:  MatchPCREventDigest(4, tpm2.TaggedHash{HashAlg: tpm2.HashAlgorithmSHA256, Digest: measure(crypto.SHA256, "kernel")}),
+		Events: []*PCREvent{makeEvent(4, "kernel2")}})
+	c.Check(err, IsNil)
+	c.Check(values, DeepEquals, tpm2.PCRValues{
+		tpm2.HashAlgorithmSHA256: {
+			4: extend(crypto.SHA256, nil, measure(crypto.SHA256, "shim"), measure(crypto.SHA256, "kernel2")),
+			7: extend(crypto.SHA256, nil, measure(crypto.SHA256, "db"))}})
+}
+
+func (s *pcrPredictSuite) TestPredictWithRemovedEvent(c *C) {
+	log := []*PCREvent{makeEvent(4, "shim"), makeEvent(7, "db"), makeEvent(7, "dbx")}
+
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, log)
+	values, err := p.Predict(&PCREventReplacement{
+		Match: func(i int, _ *PCREvent) bool { return i == 2 }})
+	c.Check(err, IsNil)
+	c.Check(values, DeepEquals, tpm2.PCRValues{
+		tpm2.HashAlgorithmSHA256: {
+			4: extend(crypto.SHA256, nil, measure(crypto.SHA256, "shim")),
+			7: extend(crypto.SHA256, nil, measure(crypto.SHA256, "db"))}})
+}
+
+func (s *pcrPredictSuite) TestPredictInitialValues(c *C) {
+	log := []*PCREvent{makeEvent(0, "crtm"), makeEvent(17, "acm")}
+
+	locality := make(tpm2.Digest, 32)
+	locality[31] = 3
+
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, log)
+	p.SetInitialValue(tpm2.HashAlgorithmSHA256, 0, locality)
+	values, err := p.Predict()
+	c.Check(err, IsNil)
+
+	ones := make([]byte, 32)
+	for i := range ones {
+		ones[i] = 0xff
+	}
+	c.Check(values, DeepEquals, tpm2.PCRValues{
+		tpm2.HashAlgorithmSHA256: {
+			0:  extend(crypto.SHA256, locality, measure(crypto.SHA256, "crtm")),
+			17: extend(crypto.SHA256, ones, measure(crypto.SHA256, "acm"))}})
+}
+
+func (s *pcrPredictSuite) TestPredictMissingBank(c *C) {
+	log := []*PCREvent{{PCR: 4, Digests: tpm2.TaggedHashList{{HashAlg: tpm2.HashAlgorithmSHA1, Digest: measure(crypto.SHA1, "shim")}}}}
+
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, log)
+	_, err := p.Predict()
+	c.Check(err, ErrorMatches, "event 0 has no digest for PCR bank TPM_ALG_SHA256")
+}
+
+func (s *pcrPredictSuite) TestPredictDigest(c *C) {
+	log := []*PCREvent{makeEvent(4, "shim"), makeEvent(4, "kernel")}
+	pcrs := tpm2.PCRSelectionList{{Hash: tpm2.HashAlgorithmSHA256, Select: []int{4, 7}}}
+
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, log)
+	digest, err := p.PredictDigest(tpm2.HashAlgorithmSHA256, pcrs)
+	c.Check(err, IsNil)
+
+	expected, err := ComputePCRDigest(tpm2.HashAlgorithmSHA256, pcrs, tpm2.PCRValues{
+		tpm2.HashAlgorithmSHA256: {
+			4: extend(crypto.SHA256, nil, measure(crypto.SHA256, "shim"), measure(crypto.SHA256, "kernel")),
+			7: make(tpm2.Digest, 32)}})
+	c.Check(err, IsNil)
+	c.Check(digest, DeepEquals, expected)
+}
+
+func (s *pcrPredictSuite) TestPredictInvalidPCR(c *C) {
+	for _, pcr := range []int{-1, 24} {
+		p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, []*PCREvent{makeEvent(0, "crtm"), makeEvent(pcr, "foo")})
+		_, err := p.Predict()
+		c.Check(err, ErrorMatches, "invalid PCR index -?[0-9]+ for event 1")
+	}
+}
+
+func (s *pcrPredictSuite) TestPredictInvalidPCRFromReplacement(c *C) {
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, []*PCREvent{makeEvent(4, "shim")})
+	_, err := p.Predict(&PCREventReplacement{
+		Match:  func(_ int, _ *PCREvent) bool { return true },
+		Events: []*PCREvent{makeEvent(30, "shim")}})
+	c.Check(err, ErrorMatches, "invalid PCR index 30 for event 0")
+}
+
+func (s *pcrPredictSuite) TestPredictWithPCRCount(c *C) {
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, []*PCREvent{makeEvent(24, "foo")})
+	p.SetPCRCount(32)
+	values, err := p.Predict()
+	c.Check(err, IsNil)
+	c.Check(values, DeepEquals, tpm2.PCRValues{
+		tpm2.HashAlgorithmSHA256: {24: extend(crypto.SHA256, nil, measure(crypto.SHA256, "foo"))}})
+}
+
+func (s *pcrPredictSuite) TestSetInitialValueInvalidPCR(c *C) {
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, nil)
+	c.Check(func() { p.SetInitialValue(tpm2.HashAlgorithmSHA256, -1, make(tpm2.Digest, 32)) }, PanicMatches, "invalid PCR index")
+	c.Check(func() { p.SetInitialValue(tpm2.HashAlgorithmSHA256, 24, make(tpm2.Digest, 32)) }, PanicMatches, "invalid PCR index")
+}
+
+func (s *pcrPredictSuite) TestPredictDigestInvalidPCR(c *C) {
+	p := NewPCRPredictor([]tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA256}, nil)
+	_, err := p.PredictDigest(tpm2.HashAlgorithmSHA256, tpm2.PCRSelectionList{{Hash: tpm2.HashAlgorithmSHA256, Select: []int{24}}})
+	c.Check(err, ErrorMatches, "selection contains an invalid PCR index 24")
+}
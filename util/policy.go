@@ -128,6 +128,14 @@ func (p *TrialAuthPolicy) PolicyPCR(pcrDigest tpm2.Digest, pcrs tpm2.PCRSelectio
 	end()
 }
 
+// PolicyLocality computes a TPM2_PolicyLocality assertion for the specified
+// localities.
+func (p *TrialAuthPolicy) PolicyLocality(locality tpm2.Locality) {
+	h, end := p.beginUpdateForCommand(tpm2.CommandPolicyLocality)
+	h.Write([]byte{uint8(locality)})
+	end()
+}
+
 // PolicyNV computes a TPM2_PolicyNV assertion executed for an index for the
 // specified name, with the specified comparison operation.
 func (p *TrialAuthPolicy) PolicyNV(nvIndexName tpm2.Name, operandB tpm2.Operand, offset uint16, operation tpm2.ArithmeticOp) {
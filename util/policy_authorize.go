@@ -0,0 +1,83 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// ComputePolicyAuthorizeDigest computes the digest that is signed by an
+// authority in order to approve a policy for a TPM2_PolicyAuthorize
+// assertion. The specified algorithm must be the name algorithm of the
+// authority's key.
+func ComputePolicyAuthorizeDigest(alg tpm2.HashAlgorithmId, approvedPolicy tpm2.Digest, policyRef tpm2.Nonce) (tpm2.Digest, error) {
+	if !alg.Available() {
+		return nil, fmt.Errorf("digest algorithm %v is not available", alg)
+	}
+
+	h := alg.NewHash()
+	h.Write(approvedPolicy)
+	h.Write(policyRef)
+	return h.Sum(nil), nil
+}
+
+// SignPolicyAuthorization approves the supplied policy digest for the
+// specified policyRef by signing it with the supplied signer, using the
+// specified scheme. The keyNameAlg argument must be the name algorithm of the
+// public area of the signing key when it is loaded in to the TPM, and the
+// digest algorithm of the scheme must match it.
+//
+// The returned signature can be supplied to VerifyPolicyAuthorization or
+// PolicyAuthorizeWithSignature on the device.
+func SignPolicyAuthorization(rand io.Reader, signer crypto.Signer, keyNameAlg tpm2.HashAlgorithmId, scheme *tpm2.SigScheme, approvedPolicy tpm2.Digest, policyRef tpm2.Nonce) (*tpm2.Signature, error) {
+	digest, err := ComputePolicyAuthorizeDigest(keyNameAlg, approvedPolicy, policyRef)
+	if err != nil {
+		return nil, err
+	}
+	return SignDigest(rand, signer, digest, scheme)
+}
+
+// VerifyPolicyAuthorization loads the public area of an authority's key in to
+// the owner hierarchy of the TPM and verifies the supplied signature for the
+// specified policy digest and policyRef. On success, it returns the ticket
+// required by TPMContext.PolicyAuthorize and the name of the authority's key.
+// The key is flushed from the TPM before returning.
+func VerifyPolicyAuthorization(tpm *tpm2.TPMContext, authKey *tpm2.Public, approvedPolicy tpm2.Digest, policyRef tpm2.Nonce, signature *tpm2.Signature) (*tpm2.TkVerified, tpm2.Name, error) {
+	digest, err := ComputePolicyAuthorizeDigest(authKey.NameAlg, approvedPolicy, policyRef)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyContext, err := tpm.LoadExternal(nil, authKey, tpm2.HandleOwner)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("cannot load authority key: %w", err)
+	}
+	defer tpm.FlushContext(keyContext)
+
+	ticket, err := tpm.VerifySignature(keyContext, digest, signature)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("cannot verify signature: %w", err)
+	}
+
+	return ticket, keyContext.Name(), nil
+}
+
+// PolicyAuthorizeWithSignature executes a TPM2_PolicyAuthorize assertion on
+// the supplied policy session, using a signature created by
+// SignPolicyAuthorization. The approved policy must already have been
+// executed on the session, so that its digest matches approvedPolicy.
+func PolicyAuthorizeWithSignature(tpm *tpm2.TPMContext, policySession tpm2.SessionContext, authKey *tpm2.Public, approvedPolicy tpm2.Digest, policyRef tpm2.Nonce, signature *tpm2.Signature) error {
+	ticket, keySign, err := VerifyPolicyAuthorization(tpm, authKey, approvedPolicy, policyRef, signature)
+	if err != nil {
+		return err
+	}
+	return tpm.PolicyAuthorize(policySession, approvedPolicy, policyRef, keySign, ticket)
+}
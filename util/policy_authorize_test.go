@@ -0,0 +1,87 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/templates"
+	"github.com/canonical/go-tpm2/testutil"
+	. "github.com/canonical/go-tpm2/util"
+)
+
+type policyAuthorizeSuite struct{}
+
+var _ = Suite(&policyAuthorizeSuite{})
+
+var rsassaSHA256 = &tpm2.SigScheme{
+	Scheme:  tpm2.SigSchemeAlgRSASSA,
+	Details: &tpm2.SigSchemeU{RSASSA: &tpm2.SigSchemeRSASSA{HashAlg: tpm2.HashAlgorithmSHA256}}}
+
+func (s *policyAuthorizeSuite) TestComputePolicyAuthorizeDigest(c *C) {
+	approvedPolicy := measure(crypto.SHA256, "policy")
+
+	digest, err := ComputePolicyAuthorizeDigest(tpm2.HashAlgorithmSHA256, approvedPolicy, tpm2.Nonce("foo"))
+	c.Check(err, IsNil)
+
+	h := crypto.SHA256.New()
+	h.Write(approvedPolicy)
+	h.Write([]byte("foo"))
+	c.Check(digest, DeepEquals, tpm2.Digest(h.Sum(nil)))
+}
+
+func (s *policyAuthorizeSuite) TestSignPolicyAuthorization(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	approvedPolicy := measure(crypto.SHA256, "policy")
+	sig, err := SignPolicyAuthorization(rand.Reader, key, tpm2.HashAlgorithmSHA256, rsassaSHA256, approvedPolicy, tpm2.Nonce("foo"))
+	c.Assert(err, IsNil)
+
+	digest, err := ComputePolicyAuthorizeDigest(tpm2.HashAlgorithmSHA256, approvedPolicy, tpm2.Nonce("foo"))
+	c.Check(err, IsNil)
+	c.Check(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest, sig.Signature.RSASSA.Sig), IsNil)
+}
+
+type policyAuthorizeTPMSuite struct {
+	testutil.TPMTest
+}
+
+func (s *policyAuthorizeTPMSuite) SetUpSuite(c *C) {
+	s.TPMFeatures = testutil.TPMFeatureOwnerHierarchy
+}
+
+var _ = Suite(&policyAuthorizeTPMSuite{})
+
+func (s *policyAuthorizeTPMSuite) TestPolicyAuthorizeWithSignature(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	authKey := NewExternalRSAPublicKeyWithDefaults(templates.KeyUsageSign, &key.PublicKey)
+
+	approved := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	approved.PolicyAuthValue()
+
+	sig, err := SignPolicyAuthorization(rand.Reader, key, authKey.NameAlg, rsassaSHA256, approved.GetDigest(), tpm2.Nonce("foo"))
+	c.Assert(err, IsNil)
+
+	session := s.StartAuthSession(c, nil, nil, tpm2.SessionTypePolicy, nil, tpm2.HashAlgorithmSHA256)
+	c.Check(s.TPM.PolicyAuthValue(session), IsNil)
+	c.Check(PolicyAuthorizeWithSignature(s.TPM, session, authKey, approved.GetDigest(), tpm2.Nonce("foo"), sig), IsNil)
+
+	authKeyName, err := authKey.Name()
+	c.Assert(err, IsNil)
+
+	expected := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	expected.PolicyAuthorize(tpm2.Nonce("foo"), authKeyName)
+
+	digest, err := s.TPM.PolicyGetDigest(session)
+	c.Check(err, IsNil)
+	c.Check(digest, DeepEquals, expected.GetDigest())
+}
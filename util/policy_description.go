@@ -0,0 +1,319 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// PolicyElementType identifies the type of a PolicyElementDescription.
+type PolicyElementType string
+
+const (
+	PolicyElementOR                PolicyElementType = "or"
+	PolicyElementPCR               PolicyElementType = "pcr"
+	PolicyElementNV                PolicyElementType = "nv"
+	PolicyElementCounterTimer      PolicyElementType = "counter-timer"
+	PolicyElementCommandCode       PolicyElementType = "command-code"
+	PolicyElementLocality          PolicyElementType = "locality"
+	PolicyElementCpHash            PolicyElementType = "cp-hash"
+	PolicyElementNameHash          PolicyElementType = "name-hash"
+	PolicyElementDuplicationSelect PolicyElementType = "duplication-select"
+	PolicyElementSigned            PolicyElementType = "signed"
+	PolicyElementSecret            PolicyElementType = "secret"
+	PolicyElementAuthorize         PolicyElementType = "authorize"
+	PolicyElementAuthValue         PolicyElementType = "auth-value"
+	PolicyElementPassword          PolicyElementType = "password"
+	PolicyElementNvWritten         PolicyElementType = "nv-written"
+)
+
+// PolicyDuplicationSelectDescription contains the arguments of a
+// TPM2_PolicyDuplicationSelect assertion.
+type PolicyDuplicationSelectDescription struct {
+	ObjectName    tpm2.Name `json:"object-name,omitempty"`
+	NewParentName tpm2.Name `json:"new-parent-name"`
+	IncludeObject bool      `json:"include-object,omitempty"`
+}
+
+// PCRSelectionDescription is the serializable form of a tpm2.PCRSelection.
+type PCRSelectionDescription struct {
+	Hash   tpm2.HashAlgorithmId `json:"hash"`
+	Select []int                `json:"select"`
+}
+
+func describePCRSelections(pcrs tpm2.PCRSelectionList) (out []PCRSelectionDescription) {
+	for _, s := range pcrs {
+		out = append(out, PCRSelectionDescription{Hash: s.Hash, Select: s.Select})
+	}
+	return out
+}
+
+func pcrSelectionsFromDescriptions(descs []PCRSelectionDescription) (out tpm2.PCRSelectionList) {
+	for _, d := range descs {
+		out = append(out, tpm2.PCRSelection{Hash: d.Hash, Select: d.Select})
+	}
+	return out
+}
+
+// NVPublicDescription is the serializable form of a tpm2.NVPublic.
+type NVPublicDescription struct {
+	Index      tpm2.Handle          `json:"index"`
+	NameAlg    tpm2.HashAlgorithmId `json:"name-alg"`
+	Attrs      tpm2.NVAttributes    `json:"attrs"`
+	AuthPolicy tpm2.Digest          `json:"auth-policy,omitempty"`
+	Size       uint16               `json:"size"`
+}
+
+func describeNVPublic(pub *tpm2.NVPublic) *NVPublicDescription {
+	return &NVPublicDescription{
+		Index:      pub.Index,
+		NameAlg:    pub.NameAlg,
+		Attrs:      pub.Attrs,
+		AuthPolicy: pub.AuthPolicy,
+		Size:       pub.Size}
+}
+
+func (d *NVPublicDescription) nvPublic() *tpm2.NVPublic {
+	return &tpm2.NVPublic{
+		Index:      d.Index,
+		NameAlg:    d.NameAlg,
+		Attrs:      d.Attrs,
+		AuthPolicy: d.AuthPolicy,
+		Size:       d.Size}
+}
+
+// PolicyElementDescription is the serializable form of a single element of a
+// policy. The fields that are used depend on Type:
+//   - PolicyElementOR: Branches.
+//   - PolicyElementPCR: PCRs and Digest, which is the PCR digest.
+//   - PolicyElementNV: NVIndex, OperandB, Offset and Operation.
+//   - PolicyElementCounterTimer: OperandB, Offset and Operation.
+//   - PolicyElementCommandCode: CommandCode.
+//   - PolicyElementLocality: Locality.
+//   - PolicyElementCpHash and PolicyElementNameHash: Digest.
+//   - PolicyElementDuplicationSelect: DuplicationSelect.
+//   - PolicyElementSigned and PolicyElementSecret: AuthName and PolicyRef.
+//   - PolicyElementAuthorize: AuthName, which is the name of the signing key,
+//     and PolicyRef.
+//   - PolicyElementNvWritten: WrittenSet.
+type PolicyElementDescription struct {
+	Type PolicyElementType `json:"type"`
+
+	Branches [][]*PolicyElementDescription `json:"branches,omitempty"`
+
+	PCRs   []PCRSelectionDescription `json:"pcrs,omitempty"`
+	Digest tpm2.Digest               `json:"digest,omitempty"`
+
+	NVIndex   *NVPublicDescription `json:"nv-index,omitempty"`
+	OperandB  tpm2.Operand         `json:"operand-b,omitempty"`
+	Offset    uint16               `json:"offset,omitempty"`
+	Operation tpm2.ArithmeticOp    `json:"operation,omitempty"`
+
+	CommandCode tpm2.CommandCode `json:"command-code,omitempty"`
+	Locality    tpm2.Locality    `json:"locality,omitempty"`
+
+	DuplicationSelect *PolicyDuplicationSelectDescription `json:"duplication-select,omitempty"`
+
+	AuthName  tpm2.Name  `json:"auth-name,omitempty"`
+	PolicyRef tpm2.Nonce `json:"policy-ref,omitempty"`
+
+	WrittenSet bool `json:"written-set,omitempty"`
+}
+
+// PolicyDescription is a serializable description of a policy tree for a
+// specific digest algorithm. Values that depend on the digest algorithm,
+// such as PCR digests and command parameter digests, are stored in their
+// computed form. It can be encoded with MarshalPolicyDescription and
+// decoded with UnmarshalPolicyDescription.
+type PolicyDescription struct {
+	Alg      tpm2.HashAlgorithmId        `json:"alg"`
+	Elements []*PolicyElementDescription `json:"elements"`
+}
+
+// DescribePolicy creates a serializable description of the supplied policy
+// tree for the specified digest algorithm.
+func DescribePolicy(alg tpm2.HashAlgorithmId, policy PolicyElement) (*PolicyDescription, error) {
+	if !alg.Available() {
+		return nil, fmt.Errorf("digest algorithm %v is not available", alg)
+	}
+	elements, err := policy.describe(alg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyDescription{Alg: alg, Elements: elements}, nil
+}
+
+func (d *PolicyElementDescription) policy() (PolicyElement, error) {
+	switch d.Type {
+	case PolicyElementOR:
+		var branches []PolicyElement
+		for i, b := range d.Branches {
+			branch, err := policyFromDescriptions(b)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot decode branch %d: %w", i, err)
+			}
+			branches = append(branches, branch)
+		}
+		return PolicyOR(branches...), nil
+	case PolicyElementPCR:
+		return PCRDigestAssertion(pcrSelectionsFromDescriptions(d.PCRs), d.Digest), nil
+	case PolicyElementNV:
+		if d.NVIndex == nil {
+			return nil, errors.New("missing NV index")
+		}
+		return NVAssertion(d.NVIndex.nvPublic(), d.OperandB, d.Offset, d.Operation), nil
+	case PolicyElementCounterTimer:
+		return CounterTimerAssertion(d.OperandB, d.Offset, d.Operation), nil
+	case PolicyElementCommandCode:
+		return CommandCodeAssertion(d.CommandCode), nil
+	case PolicyElementLocality:
+		return LocalityAssertion(d.Locality), nil
+	case PolicyElementCpHash, PolicyElementNameHash:
+		command := tpm2.CommandPolicyCpHash
+		if d.Type == PolicyElementNameHash {
+			command = tpm2.CommandPolicyNameHash
+		}
+		return policyHashDigest(command, fixedDigest(d.Digest)), nil
+	case PolicyElementDuplicationSelect:
+		if d.DuplicationSelect == nil {
+			return nil, errors.New("missing duplication select arguments")
+		}
+		return DuplicationSelectAssertion(d.DuplicationSelect.ObjectName, d.DuplicationSelect.NewParentName, d.DuplicationSelect.IncludeObject), nil
+	case PolicyElementSigned:
+		return SignedAuthorizationAssertion(d.AuthName, d.PolicyRef), nil
+	case PolicyElementSecret:
+		return SecretAuthorizationAssertion(d.AuthName, d.PolicyRef), nil
+	case PolicyElementAuthorize:
+		return AuthorizeAssertion(d.PolicyRef, d.AuthName), nil
+	case PolicyElementAuthValue:
+		return AuthValueAssertion(), nil
+	case PolicyElementPassword:
+		return PasswordAssertion(), nil
+	case PolicyElementNvWritten:
+		return NvWrittenAssertion(d.WrittenSet), nil
+	default:
+		return nil, fmt.Errorf("unrecognized element type %q", d.Type)
+	}
+}
+
+func policyFromDescriptions(descs []*PolicyElementDescription) (PolicyElement, error) {
+	var elements []PolicyElement
+	for i, d := range descs {
+		if d == nil {
+			return nil, fmt.Errorf("element %d is missing", i)
+		}
+		element, err := d.policy()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode element %d: %w", i, err)
+		}
+		elements = append(elements, element)
+	}
+	return PolicyAND(elements...), nil
+}
+
+// Policy returns the policy tree associated with this description. If the
+// description contains PCR, command parameter or name digests, the returned
+// policy can only be used with the digest algorithm of this description.
+func (d *PolicyDescription) Policy() (PolicyElement, error) {
+	return policyFromDescriptions(d.Elements)
+}
+
+// ComputeDigest computes the digest of the policy associated with this
+// description, using the digest algorithm of this description. The result is
+// the same as executing the equivalent sequence of assertions with
+// TrialAuthPolicy.
+func (d *PolicyDescription) ComputeDigest() (tpm2.Digest, error) {
+	policy, err := d.Policy()
+	if err != nil {
+		return nil, err
+	}
+	return ComputePolicyDigest(d.Alg, policy)
+}
+
+// MarshalPolicyDescription encodes the supplied policy description as JSON.
+func MarshalPolicyDescription(d *PolicyDescription) ([]byte, error) {
+	return json.Marshal(d)
+}
+
+// UnmarshalPolicyDescription decodes a policy description from the supplied
+// JSON. An error is returned if the description is invalid or if the digest
+// of the policy cannot be computed with the digest algorithm of the
+// description.
+func UnmarshalPolicyDescription(data []byte) (*PolicyDescription, error) {
+	var d PolicyDescription
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, xerrors.Errorf("cannot decode JSON: %w", err)
+	}
+	if _, err := d.ComputeDigest(); err != nil {
+		return nil, xerrors.Errorf("invalid policy description: %w", err)
+	}
+	return &d, nil
+}
+
+func (e policyAND) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	for _, element := range e {
+		var err error
+		elements, err = element.describe(alg, elements)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+func (e policyOR) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	desc := &PolicyElementDescription{Type: PolicyElementOR}
+	for i, branch := range e {
+		b, err := branch.describe(alg, nil)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot describe branch %d: %w", i, err)
+		}
+		desc.Branches = append(desc.Branches, b)
+	}
+	return append(elements, desc), nil
+}
+
+func (e *policyPCR) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	digest, err := e.pcrDigest(alg)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot compute PCR digest: %w", err)
+	}
+	return append(elements, &PolicyElementDescription{Type: PolicyElementPCR, PCRs: describePCRSelections(e.pcrs), Digest: digest}), nil
+}
+
+func (e *policyNV) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	return append(elements, &PolicyElementDescription{
+		Type:      PolicyElementNV,
+		NVIndex:   describeNVPublic(e.index),
+		OperandB:  e.operandB,
+		Offset:    e.offset,
+		Operation: e.operation}), nil
+}
+
+func (e *policyCounterTimer) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	return append(elements, &PolicyElementDescription{
+		Type:      PolicyElementCounterTimer,
+		OperandB:  e.operandB,
+		Offset:    e.offset,
+		Operation: e.operation}), nil
+}
+
+func (e *policySecret) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	return append(elements, &PolicyElementDescription{Type: PolicyElementSecret, AuthName: e.authName, PolicyRef: e.policyRef}), nil
+}
+
+func (e *policySigned) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	return append(elements, &PolicyElementDescription{Type: PolicyElementSigned, AuthName: e.authName, PolicyRef: e.policyRef}), nil
+}
+
+func (e *policyAuthorize) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	return append(elements, &PolicyElementDescription{Type: PolicyElementAuthorize, AuthName: e.keySign, PolicyRef: e.policyRef}), nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util_test
+
+import (
+	"crypto"
+	"encoding/base64"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	. "github.com/canonical/go-tpm2/util"
+)
+
+type policyDescriptionSuite struct{}
+
+var _ = Suite(&policyDescriptionSuite{})
+
+func (s *policyDescriptionSuite) TestRoundTrip(c *C) {
+	nvPub := &tpm2.NVPublic{
+		Index:   0x01800000,
+		NameAlg: tpm2.HashAlgorithmSHA256,
+		Attrs:   tpm2.NVTypeOrdinary.WithAttrs(tpm2.AttrNVAuthWrite | tpm2.AttrNVAuthRead | tpm2.AttrNVWritten),
+		Size:    8}
+
+	policy := PolicyAND(
+		PolicyOR(
+			PCRAssertion(tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "foo")}}),
+			PolicyAND(
+				NVAssertion(nvPub, tpm2.Operand{0x00, 0x01}, 6, tpm2.OpUnsignedGE),
+				SecretAuthorizationAssertion(tpm2.Name{0x40, 0x00, 0x00, 0x01}, nil))),
+		CpHashAssertion(tpm2.CommandUnseal, []tpm2.Name{{0x80, 0x00, 0x00, 0x01}}),
+		CommandCodeAssertion(tpm2.CommandUnseal),
+		NvWrittenAssertion(true),
+		PasswordAssertion())
+
+	expected, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, policy)
+	c.Assert(err, IsNil)
+
+	desc, err := DescribePolicy(tpm2.HashAlgorithmSHA256, policy)
+	c.Assert(err, IsNil)
+	c.Check(desc.Elements, HasLen, 5)
+	c.Check(desc.Elements[0].Type, Equals, PolicyElementOR)
+	c.Check(desc.Elements[0].Branches, HasLen, 2)
+
+	data, err := MarshalPolicyDescription(desc)
+	c.Check(err, IsNil)
+
+	decoded, err := UnmarshalPolicyDescription(data)
+	c.Assert(err, IsNil)
+	c.Check(decoded, DeepEquals, desc)
+
+	digest, err := decoded.ComputeDigest()
+	c.Check(err, IsNil)
+	c.Check(digest, DeepEquals, expected)
+}
+
+func (s *policyDescriptionSuite) TestComputeDigestMatchesTrial(c *C) {
+	keySign := tpm2.Name{0x00, 0x0b, 0x01, 0x02}
+	pcrs, pcrDigest := ComputePCRDigestSimple(tpm2.HashAlgorithmSHA1, tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "foo")}})
+
+	data := []byte(`{"alg":4,"elements":[` +
+		`{"type":"authorize","auth-name":"AAsBAg==","policy-ref":"Zm9v"},` +
+		`{"type":"pcr","pcrs":[{"hash":11,"select":[7]}],"digest":"` + base64.StdEncoding.EncodeToString(pcrDigest) + `"},` +
+		`{"type":"auth-value"}]}`)
+	desc, err := UnmarshalPolicyDescription(data)
+	c.Assert(err, IsNil)
+
+	digest, err := desc.ComputeDigest()
+	c.Check(err, IsNil)
+
+	trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA1)
+	trial.PolicyAuthorize(tpm2.Nonce("foo"), keySign)
+	trial.PolicyPCR(pcrDigest, pcrs)
+	trial.PolicyAuthValue()
+	c.Check(digest, DeepEquals, trial.GetDigest())
+}
+
+func (s *policyDescriptionSuite) TestUnmarshalInvalidType(c *C) {
+	_, err := UnmarshalPolicyDescription([]byte(`{"alg":11,"elements":[{"type":"foo"}]}`))
+	c.Check(err, ErrorMatches, `invalid policy description: cannot decode element 0: unrecognized element type "foo"`)
+}
+
+func (s *policyDescriptionSuite) TestUnmarshalWrongDigestSize(c *C) {
+	_, err := UnmarshalPolicyDescription([]byte(`{"alg":11,"elements":[{"type":"cp-hash","digest":"AAAA"}]}`))
+	c.Check(err, ErrorMatches, `invalid policy description: invalid digest length`)
+}
+
+func (s *policyDescriptionSuite) TestMarshalSchema(c *C) {
+	nvPub := &tpm2.NVPublic{
+		Index:   0x01800000,
+		NameAlg: tpm2.HashAlgorithmSHA256,
+		Attrs:   tpm2.NVTypeOrdinary.WithAttrs(tpm2.AttrNVAuthRead | tpm2.AttrNVWritten),
+		Size:    8}
+	policy := PolicyAND(
+		PCRDigestAssertion(tpm2.PCRSelectionList{{Hash: tpm2.HashAlgorithmSHA256, Select: []int{7}}}, make(tpm2.Digest, 32)),
+		NVAssertion(nvPub, tpm2.Operand{0x01}, 0, tpm2.OpEq))
+
+	desc, err := DescribePolicy(tpm2.HashAlgorithmSHA256, policy)
+	c.Assert(err, IsNil)
+	data, err := MarshalPolicyDescription(desc)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `{"alg":11,"elements":[`+
+		`{"type":"pcr","pcrs":[{"hash":11,"select":[7]}],"digest":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},`+
+		`{"type":"nv","nv-index":{"index":25165824,"name-alg":11,"attrs":537133056,"size":8},"operand-b":"AQ=="}]}`)
+}
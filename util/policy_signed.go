@@ -0,0 +1,108 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util
+
+import (
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// ComputePolicySignedDigest computes the digest that is signed by an authority
+// in order to authorize a TPM2_PolicySigned assertion. The nonceTPM argument
+// should be empty if the authorization isn't bound to a session. The
+// specified algorithm must be the digest algorithm of the signature scheme.
+func ComputePolicySignedDigest(alg tpm2.HashAlgorithmId, nonceTPM tpm2.Nonce, expiration int32, cpHashA tpm2.Digest, policyRef tpm2.Nonce) (tpm2.Digest, error) {
+	if !alg.Available() {
+		return nil, fmt.Errorf("digest algorithm %v is not available", alg)
+	}
+
+	h := alg.NewHash()
+	h.Write(nonceTPM)
+	binary.Write(h, binary.BigEndian, expiration)
+	h.Write(cpHashA)
+	h.Write(policyRef)
+	return h.Sum(nil), nil
+}
+
+// SignPolicySignedAuthorization creates a signed authorization for a
+// TPM2_PolicySigned assertion with the supplied signer, using the specified
+// scheme. This can be used by a remote authority that has been supplied with
+// the nonce of the policy session, or with an empty nonce if the
+// authorization isn't bound to a session.
+func SignPolicySignedAuthorization(rand io.Reader, signer crypto.Signer, scheme *tpm2.SigScheme, nonceTPM tpm2.Nonce, expiration int32, cpHashA tpm2.Digest, policyRef tpm2.Nonce) (*tpm2.Signature, error) {
+	hashAlg, err := signatureSchemeHashAlg(scheme)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := ComputePolicySignedDigest(hashAlg, nonceTPM, expiration, cpHashA, policyRef)
+	if err != nil {
+		return nil, err
+	}
+	return SignDigest(rand, signer, digest, scheme)
+}
+
+// NewPolicySignedAuthorization creates a signed authorization for a
+// TPM2_PolicySigned assertion on the supplied policy session with the
+// supplied signer, using the specified scheme. The authKey argument
+// corresponds to the public part of the signing key, loaded in to the TPM. If
+// includeNonceTPM is true, the authorization is bound to the current nonce of
+// the session.
+//
+// The result can be supplied to ExecutePolicySigned or returned from
+// PolicyAuthorizer.SignedAuth.
+func NewPolicySignedAuthorization(rand io.Reader, signer crypto.Signer, authKey tpm2.ResourceContext, scheme *tpm2.SigScheme, policySession tpm2.SessionContext, includeNonceTPM bool, cpHashA tpm2.Digest, policyRef tpm2.Nonce, expiration int32) (*PolicySignedAuthorization, error) {
+	var nonceTPM tpm2.Nonce
+	if includeNonceTPM {
+		nonceTPM = policySession.NonceTPM()
+	}
+	sig, err := SignPolicySignedAuthorization(rand, signer, scheme, nonceTPM, expiration, cpHashA, policyRef)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicySignedAuthorization{
+		AuthKey:         authKey,
+		IncludeNonceTPM: includeNonceTPM,
+		CpHash:          cpHashA,
+		Expiration:      expiration,
+		Signature:       sig}, nil
+}
+
+// PolicyTicket contains the arguments required to replay a TPM2_PolicySigned
+// or TPM2_PolicySecret assertion with TPM2_PolicyTicket.
+type PolicyTicket struct {
+	AuthName  tpm2.Name    // The name of the authorizing entity
+	PolicyRef tpm2.Nonce   // The policyRef of the original assertion
+	CpHash    tpm2.Digest  // The command parameter digest of the original assertion
+	Timeout   tpm2.Timeout // The timeout returned from the original assertion
+	Ticket    *tpm2.TkAuth // The ticket returned from the original assertion
+}
+
+// Execute replays the assertion associated with this ticket on the supplied
+// policy session, using TPMContext.PolicyTicket.
+func (t *PolicyTicket) Execute(tpm *tpm2.TPMContext, policySession tpm2.SessionContext, sessions ...tpm2.SessionContext) error {
+	return tpm.PolicyTicket(policySession, t.Timeout, t.CpHash, t.PolicyRef, t.AuthName, t.Ticket, sessions...)
+}
+
+// ExecutePolicySigned executes a TPM2_PolicySigned assertion on the supplied
+// policy session using the supplied signed authorization. The TPM only
+// returns a usable ticket if the authorization has a negative expiration
+// time, in which case the returned PolicyTicket can be used to replay the
+// assertion on another session with PolicyTicket.Execute.
+func ExecutePolicySigned(tpm *tpm2.TPMContext, policySession tpm2.SessionContext, policyRef tpm2.Nonce, auth *PolicySignedAuthorization, sessions ...tpm2.SessionContext) (*PolicyTicket, error) {
+	timeout, ticket, err := tpm.PolicySigned(auth.AuthKey, policySession, auth.IncludeNonceTPM, auth.CpHash, policyRef, auth.Expiration, auth.Signature, sessions...)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyTicket{
+		AuthName:  auth.AuthKey.Name(),
+		PolicyRef: policyRef,
+		CpHash:    auth.CpHash,
+		Timeout:   timeout,
+		Ticket:    ticket}, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/templates"
+	"github.com/canonical/go-tpm2/testutil"
+	. "github.com/canonical/go-tpm2/util"
+)
+
+type policySignedSuite struct{}
+
+var _ = Suite(&policySignedSuite{})
+
+func (s *policySignedSuite) TestComputePolicySignedDigest(c *C) {
+	nonce := tpm2.Nonce{0x01, 0x02, 0x03, 0x04}
+	cpHash := measure(crypto.SHA256, "cpHash")
+
+	digest, err := ComputePolicySignedDigest(tpm2.HashAlgorithmSHA256, nonce, -100, cpHash, tpm2.Nonce("foo"))
+	c.Check(err, IsNil)
+
+	h := crypto.SHA256.New()
+	h.Write(nonce)
+	h.Write([]byte{0xff, 0xff, 0xff, 0x9c})
+	h.Write(cpHash)
+	h.Write([]byte("foo"))
+	c.Check(digest, DeepEquals, tpm2.Digest(h.Sum(nil)))
+}
+
+func (s *policySignedSuite) TestSignPolicySignedAuthorization(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	nonce := tpm2.Nonce{0x01, 0x02, 0x03, 0x04}
+	sig, err := SignPolicySignedAuthorization(rand.Reader, key, rsassaSHA256, nonce, 100, nil, tpm2.Nonce("foo"))
+	c.Assert(err, IsNil)
+
+	digest, err := ComputePolicySignedDigest(tpm2.HashAlgorithmSHA256, nonce, 100, nil, tpm2.Nonce("foo"))
+	c.Check(err, IsNil)
+	c.Check(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest, sig.Signature.RSASSA.Sig), IsNil)
+}
+
+func (s *policySignedSuite) TestSignPolicySignedAuthorizationInvalidScheme(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	_, err = SignPolicySignedAuthorization(rand.Reader, key, &tpm2.SigScheme{Scheme: tpm2.SigSchemeAlgRSASSA, Details: &tpm2.SigSchemeU{}}, nil, 0, nil, nil)
+	c.Check(err, ErrorMatches, "unsupported signature scheme TPM_ALG_RSASSA")
+}
+
+type policySignedTPMSuite struct {
+	testutil.TPMTest
+}
+
+func (s *policySignedTPMSuite) SetUpSuite(c *C) {
+	s.TPMFeatures = testutil.TPMFeatureOwnerHierarchy
+}
+
+var _ = Suite(&policySignedTPMSuite{})
+
+func (s *policySignedTPMSuite) TestExecutePolicySignedAndTicket(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	authKey, err := s.TPM.LoadExternal(nil, NewExternalRSAPublicKeyWithDefaults(templates.KeyUsageSign, &key.PublicKey), tpm2.HandleOwner)
+	c.Assert(err, IsNil)
+
+	session := s.StartAuthSession(c, nil, nil, tpm2.SessionTypePolicy, nil, tpm2.HashAlgorithmSHA256)
+
+	auth, err := NewPolicySignedAuthorization(rand.Reader, key, authKey, rsassaSHA256, session, true, nil, tpm2.Nonce("foo"), -100)
+	c.Assert(err, IsNil)
+
+	ticket, err := ExecutePolicySigned(s.TPM, session, tpm2.Nonce("foo"), auth)
+	c.Assert(err, IsNil)
+	c.Check(ticket.AuthName, DeepEquals, authKey.Name())
+	c.Check(ticket.Ticket.Tag, Equals, tpm2.TagAuthSigned)
+
+	expected := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	expected.PolicySigned(authKey.Name(), tpm2.Nonce("foo"))
+
+	digest, err := s.TPM.PolicyGetDigest(session)
+	c.Check(err, IsNil)
+	c.Check(digest, DeepEquals, expected.GetDigest())
+
+	c.Check(s.TPM.PolicyRestart(session), IsNil)
+	c.Check(ticket.Execute(s.TPM, session), IsNil)
+
+	digest, err = s.TPM.PolicyGetDigest(session)
+	c.Check(err, IsNil)
+	c.Check(digest, DeepEquals, expected.GetDigest())
+}
@@ -0,0 +1,258 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// PolicySimulatorState describes the TPM state that a policy is simulated
+// against by SimulatePolicy.
+type PolicySimulatorState struct {
+	PCRValues   tpm2.PCRValues         // The current PCR values
+	NVIndices   map[tpm2.Handle][]byte // The contents of NV indices, keyed by handle
+	Time        *tpm2.TimeInfo         // The current time, as returned from TPMContext.ReadClock
+	Locality    uint8                  // The locality at which the authorized command is executed
+	CommandCode tpm2.CommandCode       // The command that the session is used to authorize, or zero if not known
+}
+
+func (s *PolicySimulatorState) pcrValues(pcrs tpm2.PCRSelectionList) (tpm2.PCRValues, error) {
+	values := make(tpm2.PCRValues)
+	for _, selection := range pcrs {
+		for _, pcr := range selection.Select {
+			value, ok := s.PCRValues[selection.Hash][pcr]
+			if !ok {
+				return nil, fmt.Errorf("no value for PCR %d in bank %v", pcr, selection.Hash)
+			}
+			values.SetValue(selection.Hash, pcr, value)
+		}
+	}
+	return values, nil
+}
+
+func (s *PolicySimulatorState) nvData(index *tpm2.NVPublic, size, offset uint16) ([]byte, error) {
+	data, ok := s.NVIndices[index.Index]
+	if !ok {
+		return nil, errors.New("no contents")
+	}
+	end := int(offset) + int(size)
+	if end > len(data) {
+		return nil, errors.New("read is outside of the bounds of the contents")
+	}
+	return data[offset:end], nil
+}
+
+func (s *PolicySimulatorState) timeInfo() (*tpm2.TimeInfo, error) {
+	if s.Time == nil {
+		return nil, errors.New("no time info")
+	}
+	return s.Time, nil
+}
+
+func (s *PolicySimulatorState) locality() (uint8, bool) {
+	return s.Locality, true
+}
+
+func (s *PolicySimulatorState) commandCode() (tpm2.CommandCode, bool) {
+	return s.CommandCode, s.CommandCode != 0
+}
+
+// PolicySimulationStep corresponds to a single assertion replayed by
+// SimulatePolicy.
+type PolicySimulationStep struct {
+	Command tpm2.CommandCode // The command code of the assertion
+	Digest  tpm2.Digest      // The session digest after this assertion
+	Err     error            // The reason that this assertion fails with the supplied state, or nil if it succeeds
+}
+
+// PolicySimulationError is returned from PolicySimulation.Err if an assertion
+// fails with the supplied state.
+type PolicySimulationError struct {
+	Step    int              // The index of the failing step
+	Command tpm2.CommandCode // The command code of the failing assertion
+	Err     error
+}
+
+func (e *PolicySimulationError) Error() string {
+	return fmt.Sprintf("assertion %d (%v) fails: %v", e.Step, e.Command, e.Err)
+}
+
+func (e *PolicySimulationError) Unwrap() error {
+	return e.Err
+}
+
+// PolicyDivergenceError is returned from PolicySimulation.Err if the session
+// digest after an assertion differs from the expected trace.
+type PolicyDivergenceError struct {
+	Step     int              // The index of the first step whose session digest differs
+	Command  tpm2.CommandCode // The command code of the assertion, or zero if there are fewer assertions than expected
+	Digest   tpm2.Digest      // The session digest after this step, or nil if there are fewer assertions than expected
+	Expected tpm2.Digest      // The expected session digest after this step, or nil if there are more assertions than expected
+}
+
+func (e *PolicyDivergenceError) Error() string {
+	switch {
+	case e.Digest == nil:
+		return fmt.Sprintf("assertion %d is missing (expected session digest %x)", e.Step, e.Expected)
+	case e.Expected == nil:
+		return fmt.Sprintf("assertion %d (%v) is not expected", e.Step, e.Command)
+	default:
+		return fmt.Sprintf("assertion %d (%v) produces session digest %x, expected %x", e.Step, e.Command, e.Digest, e.Expected)
+	}
+}
+
+// PolicySimulation is the result of simulating a policy session with
+// SimulatePolicy.
+type PolicySimulation struct {
+	Alg        tpm2.HashAlgorithmId
+	Steps      []*PolicySimulationStep
+	Expected   []tpm2.Digest // The expected session digest after each assertion, if supplied
+	AuthPolicy tpm2.Digest   // The authorization policy of the object, if supplied
+}
+
+// Trace returns the session digest after each assertion. This can be supplied
+// to SimulatePolicy as the expected trace for another policy.
+func (s *PolicySimulation) Trace() []tpm2.Digest {
+	var trace []tpm2.Digest
+	for _, step := range s.Steps {
+		trace = append(trace, step.Digest)
+	}
+	return trace
+}
+
+// divergence returns the first step whose session digest differs from the
+// expected trace, or nil if there isn't one or no trace was supplied.
+func (s *PolicySimulation) divergence() *PolicyDivergenceError {
+	if s.Expected == nil {
+		return nil
+	}
+	for i, step := range s.Steps {
+		if i >= len(s.Expected) {
+			return &PolicyDivergenceError{Step: i, Command: step.Command, Digest: step.Digest}
+		}
+		if !bytes.Equal(step.Digest, s.Expected[i]) {
+			return &PolicyDivergenceError{Step: i, Command: step.Command, Digest: step.Digest, Expected: s.Expected[i]}
+		}
+	}
+	if len(s.Expected) > len(s.Steps) {
+		return &PolicyDivergenceError{Step: len(s.Steps), Expected: s.Expected[len(s.Steps)]}
+	}
+	return nil
+}
+
+// Digest returns the session digest once all of the assertions have been
+// replayed.
+func (s *PolicySimulation) Digest() tpm2.Digest {
+	if len(s.Steps) == 0 {
+		return make(tpm2.Digest, s.Alg.Size())
+	}
+	return s.Steps[len(s.Steps)-1].Digest
+}
+
+// Err returns an error if the policy session would not satisfy the
+// authorization policy of the object. The steps are checked in order, and an
+// error is returned for the first step that either fails with the supplied
+// state, in which case a *PolicySimulationError is returned, or whose session
+// digest differs from the expected trace, in which case a
+// *PolicyDivergenceError is returned. If there is no such step but the
+// resulting session digest doesn't match the authorization policy of the
+// object, an error indicating this is returned.
+func (s *PolicySimulation) Err() error {
+	divergence := s.divergence()
+	for i, step := range s.Steps {
+		if step.Err != nil {
+			return &PolicySimulationError{Step: i, Command: step.Command, Err: step.Err}
+		}
+		if divergence != nil && divergence.Step == i {
+			return divergence
+		}
+	}
+	if divergence != nil {
+		return divergence
+	}
+	if s.AuthPolicy != nil && !bytes.Equal(s.Digest(), s.AuthPolicy) {
+		return fmt.Errorf("session digest %x doesn't match authorization policy %x", s.Digest(), s.AuthPolicy)
+	}
+	return nil
+}
+
+// String returns a report of the simulation, with the running session digest
+// after each assertion.
+func (s *PolicySimulation) String() string {
+	divergence := s.divergence()
+
+	var b bytes.Buffer
+	for i, step := range s.Steps {
+		fmt.Fprintf(&b, "%d: %v %x", i, step.Command, step.Digest)
+		if step.Err != nil {
+			fmt.Fprintf(&b, " (FAIL: %v)", step.Err)
+		}
+		if divergence != nil && divergence.Step == i {
+			if divergence.Expected == nil {
+				fmt.Fprintf(&b, " (DIVERGES: not expected)")
+			} else {
+				fmt.Fprintf(&b, " (DIVERGES: expected %x)", divergence.Expected)
+			}
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+	if divergence != nil && divergence.Step == len(s.Steps) {
+		fmt.Fprintf(&b, "%d: missing (DIVERGES: expected %x)\n", divergence.Step, divergence.Expected)
+	}
+	if s.AuthPolicy != nil {
+		match := "matches"
+		if !bytes.Equal(s.Digest(), s.AuthPolicy) {
+			match = "doesn't match"
+		}
+		fmt.Fprintf(&b, "session digest %s authorization policy %x\n", match, s.AuthPolicy)
+	}
+	return b.String()
+}
+
+// SimulatePolicy replays the supplied policy tree against the supplied TPM
+// state in a software model of a policy session that uses the specified
+// digest algorithm, without using the TPM. The running session digest is
+// computed in the same way as TrialAuthPolicy.
+//
+// Where the tree contains branches, the first branch that can be satisfied
+// with the supplied state is selected. If no branch can be satisfied, the
+// first branch is selected and the TPM2_PolicyOR assertions record the
+// reason that each branch fails.
+//
+// If expected is supplied, it is the expected session digest after each
+// assertion, and PolicySimulation.Err will identify the first assertion whose
+// session digest differs from it. The expected trace for a policy tree, such
+// as the one that the authorization policy of the object was computed from,
+// can be obtained by simulating it and calling PolicySimulation.Trace on the
+// result. If authPolicy is supplied, the final session digest is compared with
+// it and PolicySimulation.Err will return an error if they differ.
+//
+// The supplied authorizer is only required if the policy contains
+// TPM2_PolicyAuthorize assertions, in which case it must provide the approved
+// policies. An error is only returned if the simulation cannot be performed.
+func SimulatePolicy(alg tpm2.HashAlgorithmId, policy PolicyElement, expected []tpm2.Digest, authPolicy tpm2.Digest, state *PolicySimulatorState, auth *PolicyAuthorizer) (*PolicySimulation, error) {
+	trial, err := newTrialAuthPolicy(alg)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = new(PolicySimulatorState)
+	}
+
+	p := &policyPlanner{state: state, auth: auth, trial: trial, simulate: true}
+	if err := policy.plan(p); err != nil {
+		return nil, err
+	}
+
+	sim := &PolicySimulation{Alg: alg, Expected: expected, AuthPolicy: authPolicy}
+	for _, step := range p.steps {
+		sim.Steps = append(sim.Steps, &PolicySimulationStep{Command: step.Command, Digest: step.Digest, Err: step.err})
+	}
+	return sim, nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util_test
+
+import (
+	"crypto"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	. "github.com/canonical/go-tpm2/util"
+)
+
+type policySimulatorSuite struct{}
+
+var _ = Suite(&policySimulatorSuite{})
+
+func (s *policySimulatorSuite) TestSimulateSatisfied(c *C) {
+	nvPub := &tpm2.NVPublic{
+		Index:   0x01800000,
+		NameAlg: tpm2.HashAlgorithmSHA256,
+		Attrs:   tpm2.NVTypeOrdinary.WithAttrs(tpm2.AttrNVAuthWrite | tpm2.AttrNVAuthRead | tpm2.AttrNVWritten),
+		Size:    8}
+
+	policy := PolicyAND(
+		PCRAssertion(tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "foo")}}),
+		NVAssertion(nvPub, tpm2.Operand{0x00, 0x05}, 6, tpm2.OpUnsignedGE),
+		LocalityAssertion(1<<3),
+		CommandCodeAssertion(tpm2.CommandUnseal))
+
+	authPolicy, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, policy)
+	c.Assert(err, IsNil)
+
+	state := &PolicySimulatorState{
+		PCRValues:   tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "foo")}},
+		NVIndices:   map[tpm2.Handle][]byte{0x01800000: {0, 0, 0, 0, 0, 0, 0, 0x10}},
+		Locality:    3,
+		CommandCode: tpm2.CommandUnseal}
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, policy, nil, authPolicy, state, nil)
+	c.Assert(err, IsNil)
+	c.Check(sim.Err(), IsNil)
+	c.Assert(sim.Steps, HasLen, 4)
+	c.Check(sim.Steps[0].Command, Equals, tpm2.CommandPolicyPCR)
+	c.Check(sim.Steps[1].Command, Equals, tpm2.CommandPolicyNV)
+	c.Check(sim.Steps[2].Command, Equals, tpm2.CommandPolicyLocality)
+	c.Check(sim.Steps[3].Command, Equals, tpm2.CommandPolicyCommandCode)
+	c.Check(sim.Digest(), DeepEquals, authPolicy)
+
+	trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	pcrs, pcrDigest := ComputePCRDigestSimple(tpm2.HashAlgorithmSHA256, state.PCRValues)
+	trial.PolicyPCR(pcrDigest, pcrs)
+	c.Check(sim.Steps[0].Digest, DeepEquals, trial.GetDigest())
+}
+
+func (s *policySimulatorSuite) TestSimulateFailingAssertion(c *C) {
+	policy := PolicyAND(
+		PCRAssertion(tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "foo")}}),
+		LocalityAssertion(1<<3),
+		CommandCodeAssertion(tpm2.CommandUnseal))
+
+	authPolicy, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, policy)
+	c.Assert(err, IsNil)
+
+	state := &PolicySimulatorState{
+		PCRValues: tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "foo")}},
+		Locality:  0}
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, policy, nil, authPolicy, state, nil)
+	c.Assert(err, IsNil)
+	c.Check(sim.Steps[0].Err, IsNil)
+	c.Check(sim.Steps[1].Err, ErrorMatches, "policy is not satisfied at locality 0")
+	c.Check(sim.Steps[2].Err, IsNil)
+	c.Check(sim.Digest(), DeepEquals, authPolicy)
+
+	err = sim.Err()
+	c.Assert(err, FitsTypeOf, &PolicySimulationError{})
+	c.Check(err.(*PolicySimulationError).Step, Equals, 1)
+	c.Check(err, ErrorMatches, `assertion 1 \(TPM_CC_PolicyLocality\) fails: policy is not satisfied at locality 0`)
+}
+
+func (s *policySimulatorSuite) TestSimulateNoBranchSatisfied(c *C) {
+	policy := PolicyOR(
+		PCRAssertion(tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "foo")}}),
+		PCRAssertion(tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "bar")}}))
+
+	state := &PolicySimulatorState{
+		PCRValues: tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "baz")}}}
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, policy, nil, nil, state, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sim.Steps, HasLen, 2)
+	c.Check(sim.Steps[0].Command, Equals, tpm2.CommandPolicyPCR)
+	c.Check(sim.Steps[0].Err, NotNil)
+	c.Check(sim.Steps[1].Command, Equals, tpm2.CommandPolicyOR)
+	c.Check(sim.Steps[1].Err, ErrorMatches, `no branch of PolicyOR element can be satisfied \(branch 0: .*; branch 1: .*\)`)
+}
+
+func (s *policySimulatorSuite) TestSimulateAuthPolicyMismatch(c *C) {
+	policy := CommandCodeAssertion(tpm2.CommandUnseal)
+
+	authPolicy, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, CommandCodeAssertion(tpm2.CommandSign))
+	c.Assert(err, IsNil)
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, policy, nil, authPolicy, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(sim.Steps[0].Err, IsNil)
+	c.Check(sim.Err(), ErrorMatches, "session digest [[:xdigit:]]+ doesn't match authorization policy [[:xdigit:]]+")
+}
+
+func (s *policySimulatorSuite) TestSimulateMissingState(c *C) {
+	nvPub := &tpm2.NVPublic{
+		Index:   0x01800000,
+		NameAlg: tpm2.HashAlgorithmSHA256,
+		Attrs:   tpm2.NVTypeOrdinary.WithAttrs(tpm2.AttrNVAuthWrite | tpm2.AttrNVAuthRead | tpm2.AttrNVWritten),
+		Size:    8}
+
+	policy := PolicyAND(
+		NVAssertion(nvPub, tpm2.Operand{0x00, 0x05}, 6, tpm2.OpUnsignedGE),
+		CounterTimerAssertion(tpm2.Operand{0x01}, 0, tpm2.OpEq))
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, policy, nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(sim.Steps[0].Err, ErrorMatches, "cannot read NV index 0x01800000: no contents")
+	c.Check(sim.Steps[1].Err, ErrorMatches, "cannot obtain time info: no time info")
+}
+
+func (s *policySimulatorSuite) TestSimulateDivergence(c *C) {
+	expectedPolicy := PolicyAND(
+		LocalityAssertion(1<<3),
+		CommandCodeAssertion(tpm2.CommandUnseal),
+		AuthValueAssertion())
+	policy := PolicyAND(
+		LocalityAssertion(1<<3),
+		CommandCodeAssertion(tpm2.CommandSign),
+		AuthValueAssertion())
+
+	state := &PolicySimulatorState{Locality: 3}
+
+	expected, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, expectedPolicy, nil, nil, state, nil)
+	c.Assert(err, IsNil)
+	c.Check(expected.Err(), IsNil)
+	c.Assert(expected.Trace(), HasLen, 3)
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, policy, expected.Trace(), expected.Digest(), state, nil)
+	c.Assert(err, IsNil)
+
+	err = sim.Err()
+	c.Assert(err, FitsTypeOf, &PolicyDivergenceError{})
+	e := err.(*PolicyDivergenceError)
+	c.Check(e.Step, Equals, 1)
+	c.Check(e.Command, Equals, tpm2.CommandPolicyCommandCode)
+	c.Check(e.Digest, DeepEquals, sim.Steps[1].Digest)
+	c.Check(e.Expected, DeepEquals, expected.Trace()[1])
+	c.Check(err, ErrorMatches, `assertion 1 \(TPM_CC_PolicyCommandCode\) produces session digest [[:xdigit:]]+, expected [[:xdigit:]]+`)
+	c.Check(sim.String(), Matches, `(?s)0: TPM_CC_PolicyLocality [[:xdigit:]]+\n`+
+		`1: TPM_CC_PolicyCommandCode [[:xdigit:]]+ \(DIVERGES: expected [[:xdigit:]]+\)\n`+
+		`2: TPM_CC_PolicyAuthValue [[:xdigit:]]+\n`+
+		`session digest doesn't match authorization policy [[:xdigit:]]+\n`)
+}
+
+func (s *policySimulatorSuite) TestSimulateDivergenceMissingAssertion(c *C) {
+	expected, err := SimulatePolicy(tpm2.HashAlgorithmSHA256,
+		PolicyAND(CommandCodeAssertion(tpm2.CommandUnseal), AuthValueAssertion()), nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, CommandCodeAssertion(tpm2.CommandUnseal), expected.Trace(), nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	err = sim.Err()
+	c.Assert(err, FitsTypeOf, &PolicyDivergenceError{})
+	c.Check(err.(*PolicyDivergenceError).Step, Equals, 1)
+	c.Check(err, ErrorMatches, `assertion 1 is missing \(expected session digest [[:xdigit:]]+\)`)
+	c.Check(sim.String(), Matches, `(?s).*\n1: missing \(DIVERGES: expected [[:xdigit:]]+\)\n`)
+}
+
+func (s *policySimulatorSuite) TestSimulateDivergenceUnexpectedAssertion(c *C) {
+	expected, err := SimulatePolicy(tpm2.HashAlgorithmSHA256, CommandCodeAssertion(tpm2.CommandUnseal), nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256,
+		PolicyAND(CommandCodeAssertion(tpm2.CommandUnseal), AuthValueAssertion()), expected.Trace(), nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	err = sim.Err()
+	c.Assert(err, FitsTypeOf, &PolicyDivergenceError{})
+	c.Check(err.(*PolicyDivergenceError).Step, Equals, 1)
+	c.Check(err, ErrorMatches, `assertion 1 \(TPM_CC_PolicyAuthValue\) is not expected`)
+}
+
+func (s *policySimulatorSuite) TestSimulateFailureBeforeDivergence(c *C) {
+	expected, err := SimulatePolicy(tpm2.HashAlgorithmSHA256,
+		PolicyAND(LocalityAssertion(1<<3), CommandCodeAssertion(tpm2.CommandUnseal)), nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	sim, err := SimulatePolicy(tpm2.HashAlgorithmSHA256,
+		PolicyAND(LocalityAssertion(1<<3), CommandCodeAssertion(tpm2.CommandSign)), expected.Trace(), nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	err = sim.Err()
+	c.Assert(err, FitsTypeOf, &PolicySimulationError{})
+	c.Check(err.(*PolicySimulationError).Step, Equals, 0)
+}
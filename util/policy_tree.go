@@ -0,0 +1,1111 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+)
+
+// PolicyElement is a node in a policy tree. A policy tree is made up of
+// sequences of assertions created with PolicyAND, branches created with
+// PolicyOR and leaf assertions, and can be used to compute a policy digest
+// for any hash algorithm with ComputePolicyDigest or to execute the policy
+// with a policy session with PlanPolicyExecution.
+type PolicyElement interface {
+	// computeDigest updates the supplied trial policy with this element.
+	computeDigest(p *TrialAuthPolicy) error
+
+	// check returns an error if this element cannot be satisfied with the
+	// TPM state known to the supplied planner.
+	check(p *policyPlanner) error
+
+	// plan appends the steps required to execute this element to the
+	// supplied planner.
+	plan(p *policyPlanner) error
+
+	// describe appends the description of this element for the specified
+	// algorithm to the supplied list.
+	describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error)
+}
+
+// PolicyAuthorizer supplies the resources required to execute assertions
+// that require an authorization.
+type PolicyAuthorizer struct {
+	// NVAuth returns the entity and associated session used to authorize
+	// TPM2_PolicyNV assertions and the NV reads required to select a branch.
+	// If this is nil, the NV index is used to authorize itself without a
+	// session.
+	NVAuth func(index tpm2.ResourceContext) (tpm2.ResourceContext, tpm2.SessionContext, error)
+
+	// SecretAuth returns the entity with the specified name and the
+	// session used to authorize it, and is required for TPM2_PolicySecret
+	// assertions.
+	SecretAuth func(authName tpm2.Name) (tpm2.ResourceContext, tpm2.SessionContext, error)
+
+	// SignedAuth returns a signed authorization for the key with the
+	// specified name, and is required for TPM2_PolicySigned assertions. It
+	// is called during execution so that the signature can be bound to the
+	// nonce of the supplied session.
+	SignedAuth func(session tpm2.SessionContext, authName tpm2.Name, policyRef tpm2.Nonce) (*PolicySignedAuthorization, error)
+
+	// AuthorizedPolicy returns a policy that has been approved by the key
+	// with the specified name along with the ticket returned from
+	// TPMContext.VerifySignature for it, and is required for
+	// TPM2_PolicyAuthorize assertions.
+	AuthorizedPolicy func(keySign tpm2.Name, policyRef tpm2.Nonce) (PolicyElement, *tpm2.TkVerified, error)
+}
+
+// PolicySignedAuthorization corresponds to a signed authorization for a
+// TPM2_PolicySigned assertion.
+type PolicySignedAuthorization struct {
+	AuthKey         tpm2.ResourceContext // The key that signed the authorization, loaded in to the TPM
+	IncludeNonceTPM bool                 // Whether the signature covers the session nonce
+	CpHash          tpm2.Digest          // The command parameter digest covered by the signature
+	Expiration      int32                // The expiration time covered by the signature
+	Signature       *tpm2.Signature      // The signature
+}
+
+func (a *PolicyAuthorizer) nvAuth(index tpm2.ResourceContext) (tpm2.ResourceContext, tpm2.SessionContext, error) {
+	if a == nil || a.NVAuth == nil {
+		return index, nil, nil
+	}
+	return a.NVAuth(index)
+}
+
+// PolicyExecutionStep corresponds to a single command executed as part of a
+// PolicyExecutionPlan.
+type PolicyExecutionStep struct {
+	Command tpm2.CommandCode // The command code of the assertion
+	Digest  tpm2.Digest      // The expected session digest after this step is executed
+
+	run func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error
+	err error // Only set when simulating
+}
+
+// PolicyExecutionPlan is a sequence of assertions that satisfy a policy tree,
+// created by PlanPolicyExecution.
+type PolicyExecutionPlan struct {
+	Alg   tpm2.HashAlgorithmId
+	Steps []*PolicyExecutionStep
+
+	tpm *tpm2.TPMContext
+}
+
+// Digest returns the expected session digest once all of the steps in this
+// plan have been executed.
+func (p *PolicyExecutionPlan) Digest() tpm2.Digest {
+	if len(p.Steps) == 0 {
+		return make(tpm2.Digest, p.Alg.Size())
+	}
+	return p.Steps[len(p.Steps)-1].Digest
+}
+
+// Execute executes each of the steps in this plan with the supplied policy
+// session, which should be freshly started or restarted with
+// TPMContext.PolicyRestart.
+func (p *PolicyExecutionPlan) Execute(session tpm2.SessionContext) error {
+	if p.tpm == nil {
+		return errors.New("no TPM context")
+	}
+	for i, step := range p.Steps {
+		if err := step.run(p.tpm, session); err != nil {
+			return xerrors.Errorf("cannot execute step %d (%v): %w", i, step.Command, err)
+		}
+	}
+	return nil
+}
+
+// policyState provides access to the TPM state that determines whether
+// assertions can be satisfied.
+type policyState interface {
+	pcrValues(pcrs tpm2.PCRSelectionList) (tpm2.PCRValues, error)
+	nvData(index *tpm2.NVPublic, size, offset uint16) ([]byte, error)
+	timeInfo() (*tpm2.TimeInfo, error)
+
+	// locality and commandCode return false if the value isn't known.
+	locality() (uint8, bool)
+	commandCode() (tpm2.CommandCode, bool)
+}
+
+// tpmPolicyState is a policyState that reads the state from the TPM.
+type tpmPolicyState struct {
+	tpm  *tpm2.TPMContext
+	auth *PolicyAuthorizer
+}
+
+func (s *tpmPolicyState) pcrValues(pcrs tpm2.PCRSelectionList) (tpm2.PCRValues, error) {
+	_, values, err := s.tpm.PCRRead(pcrs)
+	return values, err
+}
+
+func (s *tpmPolicyState) nvData(index *tpm2.NVPublic, size, offset uint16) ([]byte, error) {
+	rc, err := tpm2.CreateNVIndexResourceContextFromPublic(index)
+	if err != nil {
+		return nil, err
+	}
+	authContext, authSession, err := s.auth.nvAuth(rc)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot obtain authorization for NV index: %w", err)
+	}
+	return s.tpm.NVRead(authContext, rc, size, offset, authSession)
+}
+
+func (s *tpmPolicyState) timeInfo() (*tpm2.TimeInfo, error) {
+	return s.tpm.ReadClock()
+}
+
+func (s *tpmPolicyState) locality() (uint8, bool) {
+	return 0, false
+}
+
+func (s *tpmPolicyState) commandCode() (tpm2.CommandCode, bool) {
+	return 0, false
+}
+
+type policyPlanner struct {
+	state policyState // The TPM state, or nil if it isn't known
+	auth  *PolicyAuthorizer
+	trial *TrialAuthPolicy
+	steps []*PolicyExecutionStep
+
+	// requireAuth indicates that assertions that require an authorization
+	// can only be satisfied if the authorizer can provide it.
+	requireAuth bool
+
+	// simulate indicates that each assertion is checked against the state
+	// as it is planned, and that planning continues if no branch of a
+	// PolicyOR element can be satisfied.
+	simulate bool
+
+	// authorized caches the result of PolicyAuthorizer.AuthorizedPolicy
+	// for each TPM2_PolicyAuthorize element.
+	authorized map[*policyAuthorize]*authorizedPolicyResult
+}
+
+func (p *policyPlanner) addStep(command tpm2.CommandCode, run func(*tpm2.TPMContext, tpm2.SessionContext) error) *PolicyExecutionStep {
+	digest := make(tpm2.Digest, len(p.trial.digest))
+	copy(digest, p.trial.digest)
+	step := &PolicyExecutionStep{Command: command, Digest: digest, run: run}
+	p.steps = append(p.steps, step)
+	return step
+}
+
+// addCheckedStep adds a step for the supplied assertion, which is checked
+// against the state when simulating.
+func (p *policyPlanner) addCheckedStep(e PolicyElement, command tpm2.CommandCode, run func(*tpm2.TPMContext, tpm2.SessionContext) error) {
+	step := p.addStep(command, run)
+	if p.simulate {
+		step.err = e.check(p)
+	}
+}
+
+func newTrialAuthPolicy(alg tpm2.HashAlgorithmId) (*TrialAuthPolicy, error) {
+	if !alg.Available() {
+		return nil, fmt.Errorf("digest algorithm %v is not available", alg)
+	}
+	return ComputeAuthPolicy(alg), nil
+}
+
+// ComputePolicyDigest computes the digest of the supplied policy tree for the
+// specified algorithm. This produces the same result as executing the
+// equivalent sequence of assertions with TrialAuthPolicy.
+func ComputePolicyDigest(alg tpm2.HashAlgorithmId, policy PolicyElement) (tpm2.Digest, error) {
+	trial, err := newTrialAuthPolicy(alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.computeDigest(trial); err != nil {
+		return nil, err
+	}
+	return trial.GetDigest(), nil
+}
+
+// PlanPolicyExecution computes the sequence of assertions required to satisfy
+// the supplied policy tree with a policy session that uses the specified
+// digest algorithm. Where the tree contains branches, the first branch that
+// can be satisfied with the current PCR, NV index and clock state of the TPM
+// is selected. If tpm is nil, the first branch of each PolicyOR is selected
+// and the resulting plan can be inspected but not executed.
+//
+// The supplied authorizer provides the resources required for assertions
+// that need an authorization, and can be nil if the policy doesn't contain
+// any of these.
+func PlanPolicyExecution(tpm *tpm2.TPMContext, alg tpm2.HashAlgorithmId, policy PolicyElement, auth *PolicyAuthorizer) (*PolicyExecutionPlan, error) {
+	trial, err := newTrialAuthPolicy(alg)
+	if err != nil {
+		return nil, err
+	}
+	p := &policyPlanner{auth: auth, trial: trial}
+	if tpm != nil {
+		p.state = &tpmPolicyState{tpm: tpm, auth: auth}
+		p.requireAuth = true
+	}
+	if err := policy.plan(p); err != nil {
+		return nil, err
+	}
+	return &PolicyExecutionPlan{Alg: alg, Steps: p.steps, tpm: tpm}, nil
+}
+
+// ExecutePolicy plans the execution of the supplied policy tree with
+// PlanPolicyExecution, and then executes the plan with the supplied policy
+// session which uses the specified digest algorithm.
+func ExecutePolicy(tpm *tpm2.TPMContext, session tpm2.SessionContext, alg tpm2.HashAlgorithmId, policy PolicyElement, auth *PolicyAuthorizer) error {
+	plan, err := PlanPolicyExecution(tpm, alg, policy, auth)
+	if err != nil {
+		return xerrors.Errorf("cannot plan policy execution: %w", err)
+	}
+	return plan.Execute(session)
+}
+
+type policyAND []PolicyElement
+
+// PolicyAND returns a policy element that corresponds to the supplied
+// elements executed in sequence.
+func PolicyAND(elements ...PolicyElement) PolicyElement {
+	return policyAND(elements)
+}
+
+func (e policyAND) computeDigest(p *TrialAuthPolicy) error {
+	for _, element := range e {
+		if err := element.computeDigest(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e policyAND) check(p *policyPlanner) error {
+	for _, element := range e {
+		if err := element.check(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e policyAND) plan(p *policyPlanner) error {
+	for _, element := range e {
+		if err := element.plan(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type policyOR []PolicyElement
+
+// PolicyOR returns a policy element that can be satisfied by any one of the
+// supplied branches. The digest of each branch is computed from the session
+// digest at the point at which the element appears. Where there are more
+// than 8 branches, the digests are combined with nested layers of
+// TPM2_PolicyOR assertions, in groups of up to 8. A single branch is
+// equivalent to executing that branch without a TPM2_PolicyOR assertion.
+func PolicyOR(branches ...PolicyElement) PolicyElement {
+	return policyOR(branches)
+}
+
+// branchDigests computes the digest of each branch starting from the
+// current digest of the supplied trial policy, without modifying it.
+func (e policyOR) branchDigests(p *TrialAuthPolicy) (tpm2.DigestList, error) {
+	if len(e) == 0 {
+		return nil, errors.New("PolicyOR element with no branches")
+	}
+
+	var digests tpm2.DigestList
+	for i, branch := range e {
+		trial := &TrialAuthPolicy{alg: p.alg, digest: make(tpm2.Digest, len(p.digest)), hashOccupied: p.hashOccupied}
+		copy(trial.digest, p.digest)
+		if err := branch.computeDigest(trial); err != nil {
+			return nil, xerrors.Errorf("cannot compute digest for branch %d: %w", i, err)
+		}
+		digests = append(digests, trial.GetDigest())
+	}
+	return digests, nil
+}
+
+// orLayers returns the groups of digests required to produce the final
+// digest from the supplied branch digests. Each layer contains the groups
+// of digests that are combined with TPM2_PolicyOR to produce the next
+// layer. A group with a single digest is passed unmodified to the next
+// layer.
+func orLayers(alg tpm2.HashAlgorithmId, digests tpm2.DigestList) [][]tpm2.DigestList {
+	var layers [][]tpm2.DigestList
+	for len(digests) > 1 {
+		var groups []tpm2.DigestList
+		var next tpm2.DigestList
+		for len(digests) > 0 {
+			n := len(digests)
+			if n > 8 {
+				n = 8
+			}
+			group := digests[:n]
+			digests = digests[n:]
+
+			groups = append(groups, group)
+			if len(group) == 1 {
+				next = append(next, group[0])
+				continue
+			}
+			trial := ComputeAuthPolicy(alg)
+			trial.PolicyOR(group)
+			next = append(next, trial.GetDigest())
+		}
+		layers = append(layers, groups)
+		digests = next
+	}
+	return layers
+}
+
+func (e policyOR) computeDigest(p *TrialAuthPolicy) error {
+	digests, err := e.branchDigests(p)
+	if err != nil {
+		return err
+	}
+	if len(digests) == 1 {
+		p.digest = digests[0]
+		return nil
+	}
+	layers := orLayers(p.alg, digests)
+	final := layers[len(layers)-1][0]
+	p.PolicyOR(final)
+	return nil
+}
+
+// selectBranch returns the index of the first branch that can be satisfied.
+func (e policyOR) selectBranch(p *policyPlanner) (int, error) {
+	var errs []string
+	for i, branch := range e {
+		err := branch.check(p)
+		if err == nil {
+			return i, nil
+		}
+		errs = append(errs, fmt.Sprintf("branch %d: %v", i, err))
+	}
+	return -1, fmt.Errorf("no branch of PolicyOR element can be satisfied (%s)", strings.Join(errs, "; "))
+}
+
+func (e policyOR) check(p *policyPlanner) error {
+	_, err := e.selectBranch(p)
+	return err
+}
+
+func (e policyOR) plan(p *policyPlanner) error {
+	digests, err := e.branchDigests(p.trial)
+	if err != nil {
+		return err
+	}
+
+	selected, selectErr := e.selectBranch(p)
+	switch {
+	case selectErr == nil:
+	case p.simulate:
+		// Continue with the first branch so that the remaining
+		// assertions can be simulated.
+		selected = 0
+	default:
+		return selectErr
+	}
+
+	if err := e[selected].plan(p); err != nil {
+		return xerrors.Errorf("cannot plan branch %d: %w", selected, err)
+	}
+	if !bytes.Equal(p.trial.digest, digests[selected]) {
+		return fmt.Errorf("unexpected digest after planning branch %d", selected)
+	}
+
+	index := selected
+	for _, groups := range orLayers(p.trial.alg, digests) {
+		group := groups[index/8]
+		index /= 8
+		if len(group) == 1 {
+			continue
+		}
+		p.trial.PolicyOR(group)
+		step := p.addStep(tpm2.CommandPolicyOR, func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error {
+			return tpm.PolicyOR(session, group)
+		})
+		step.err = selectErr
+	}
+	return nil
+}
+
+type policyPCR struct {
+	pcrs   tpm2.PCRSelectionList
+	values tpm2.PCRValues
+	digest tpm2.Digest
+}
+
+// PCRAssertion returns a policy element that corresponds to a TPM2_PolicyPCR
+// assertion for the supplied PCR values. The PCR digest is computed for the
+// algorithm of the policy.
+func PCRAssertion(values tpm2.PCRValues) PolicyElement {
+	return &policyPCR{pcrs: values.SelectionList(), values: values}
+}
+
+// PCRDigestAssertion returns a policy element that corresponds to a
+// TPM2_PolicyPCR assertion for the specified PCR selection and PCR digest,
+// as computed by ComputePCRDigest. The policy can only be computed for the
+// algorithm that the PCR digest was computed with.
+func PCRDigestAssertion(pcrs tpm2.PCRSelectionList, digest tpm2.Digest) PolicyElement {
+	return &policyPCR{pcrs: pcrs, digest: digest}
+}
+
+func (e *policyPCR) pcrDigest(alg tpm2.HashAlgorithmId) (tpm2.Digest, error) {
+	if e.values == nil {
+		if len(e.digest) != alg.Size() {
+			return nil, errors.New("invalid PCR digest length")
+		}
+		return e.digest, nil
+	}
+	return ComputePCRDigest(alg, e.pcrs, e.values)
+}
+
+func (e *policyPCR) computeDigest(p *TrialAuthPolicy) error {
+	digest, err := e.pcrDigest(p.alg)
+	if err != nil {
+		return xerrors.Errorf("cannot compute PCR digest: %w", err)
+	}
+	p.PolicyPCR(digest, e.pcrs)
+	return nil
+}
+
+func (e *policyPCR) check(p *policyPlanner) error {
+	if p.state == nil {
+		return nil
+	}
+	expected, err := e.pcrDigest(p.trial.alg)
+	if err != nil {
+		return xerrors.Errorf("cannot compute PCR digest: %w", err)
+	}
+	values, err := p.state.pcrValues(e.pcrs)
+	if err != nil {
+		return xerrors.Errorf("cannot obtain PCR values: %w", err)
+	}
+	digest, err := ComputePCRDigest(p.trial.alg, e.pcrs, values)
+	if err != nil {
+		return xerrors.Errorf("cannot compute PCR digest from current values: %w", err)
+	}
+	if bytes.Equal(digest, expected) {
+		return nil
+	}
+
+	if e.values != nil {
+		// Identify the first PCR with an unexpected value.
+		for _, s := range e.pcrs {
+			for _, pcr := range s.Select {
+				if !bytes.Equal(values[s.Hash][pcr], e.values[s.Hash][pcr]) {
+					return fmt.Errorf("PCR %d in bank %v has an unexpected value", pcr, s.Hash)
+				}
+			}
+		}
+	}
+	return errors.New("PCR values don't match")
+}
+
+func (e *policyPCR) plan(p *policyPlanner) error {
+	digest, err := e.pcrDigest(p.trial.alg)
+	if err != nil {
+		return xerrors.Errorf("cannot compute PCR digest: %w", err)
+	}
+	p.trial.PolicyPCR(digest, e.pcrs)
+	p.addCheckedStep(e, tpm2.CommandPolicyPCR, func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error {
+		return tpm.PolicyPCR(session, digest, e.pcrs)
+	})
+	return nil
+}
+
+// compareOperands performs the comparison of operandA and operandB specified
+// by operation in the same way as TPM2_PolicyNV and TPM2_PolicyCounterTimer.
+func compareOperands(operandA, operandB []byte, operation tpm2.ArithmeticOp) bool {
+	if len(operandA) != len(operandB) {
+		return false
+	}
+
+	signedCmp := func() int {
+		if len(operandA) > 0 {
+			negA := operandA[0]&0x80 != 0
+			negB := operandB[0]&0x80 != 0
+			switch {
+			case negA && !negB:
+				return -1
+			case !negA && negB:
+				return 1
+			}
+		}
+		return bytes.Compare(operandA, operandB)
+	}
+
+	switch operation {
+	case tpm2.OpEq:
+		return bytes.Equal(operandA, operandB)
+	case tpm2.OpNeq:
+		return !bytes.Equal(operandA, operandB)
+	case tpm2.OpSignedGT:
+		return signedCmp() > 0
+	case tpm2.OpUnsignedGT:
+		return bytes.Compare(operandA, operandB) > 0
+	case tpm2.OpSignedLT:
+		return signedCmp() < 0
+	case tpm2.OpUnsignedLT:
+		return bytes.Compare(operandA, operandB) < 0
+	case tpm2.OpSignedGE:
+		return signedCmp() >= 0
+	case tpm2.OpUnsignedGE:
+		return bytes.Compare(operandA, operandB) >= 0
+	case tpm2.OpSignedLE:
+		return signedCmp() <= 0
+	case tpm2.OpUnsignedLE:
+		return bytes.Compare(operandA, operandB) <= 0
+	case tpm2.OpBitset:
+		for i := range operandA {
+			if operandA[i]&operandB[i] != operandB[i] {
+				return false
+			}
+		}
+		return true
+	case tpm2.OpBitclear:
+		for i := range operandA {
+			if operandA[i]&operandB[i] != 0 {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+type policyNV struct {
+	index     *tpm2.NVPublic
+	operandB  tpm2.Operand
+	offset    uint16
+	operation tpm2.ArithmeticOp
+}
+
+// NVAssertion returns a policy element that corresponds to a TPM2_PolicyNV
+// assertion for the NV index with the supplied public area.
+func NVAssertion(index *tpm2.NVPublic, operandB tpm2.Operand, offset uint16, operation tpm2.ArithmeticOp) PolicyElement {
+	return &policyNV{index: index, operandB: operandB, offset: offset, operation: operation}
+}
+
+func (e *policyNV) computeDigest(p *TrialAuthPolicy) error {
+	name, err := e.index.Name()
+	if err != nil {
+		return xerrors.Errorf("cannot compute name of NV index: %w", err)
+	}
+	p.PolicyNV(name, e.operandB, e.offset, e.operation)
+	return nil
+}
+
+func (e *policyNV) check(p *policyPlanner) error {
+	if p.state == nil {
+		return nil
+	}
+	operandA, err := p.state.nvData(e.index, uint16(len(e.operandB)), e.offset)
+	if err != nil {
+		return xerrors.Errorf("cannot read NV index %v: %w", e.index.Index, err)
+	}
+	if !compareOperands(operandA, e.operandB, e.operation) {
+		return fmt.Errorf("comparison with contents of NV index %v failed", e.index.Index)
+	}
+	return nil
+}
+
+func (e *policyNV) plan(p *policyPlanner) error {
+	if err := e.computeDigest(p.trial); err != nil {
+		return err
+	}
+	p.addCheckedStep(e, tpm2.CommandPolicyNV, func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error {
+		index, err := tpm2.CreateNVIndexResourceContextFromPublic(e.index)
+		if err != nil {
+			return err
+		}
+		authContext, authSession, err := p.auth.nvAuth(index)
+		if err != nil {
+			return xerrors.Errorf("cannot obtain authorization for NV index: %w", err)
+		}
+		return tpm.PolicyNV(authContext, index, session, e.operandB, e.offset, e.operation, authSession)
+	})
+	return nil
+}
+
+type policyCounterTimer struct {
+	operandB  tpm2.Operand
+	offset    uint16
+	operation tpm2.ArithmeticOp
+}
+
+// CounterTimerAssertion returns a policy element that corresponds to a
+// TPM2_PolicyCounterTimer assertion.
+func CounterTimerAssertion(operandB tpm2.Operand, offset uint16, operation tpm2.ArithmeticOp) PolicyElement {
+	return &policyCounterTimer{operandB: operandB, offset: offset, operation: operation}
+}
+
+func (e *policyCounterTimer) computeDigest(p *TrialAuthPolicy) error {
+	p.PolicyCounterTimer(e.operandB, e.offset, e.operation)
+	return nil
+}
+
+func (e *policyCounterTimer) check(p *policyPlanner) error {
+	if p.state == nil {
+		return nil
+	}
+	time, err := p.state.timeInfo()
+	if err != nil {
+		return xerrors.Errorf("cannot obtain time info: %w", err)
+	}
+	timeBytes, err := mu.MarshalToBytes(time)
+	if err != nil {
+		return xerrors.Errorf("cannot marshal time info: %w", err)
+	}
+	end := int(e.offset) + len(e.operandB)
+	if end > len(timeBytes) {
+		return errors.New("operand is outside of the bounds of the time info")
+	}
+	if !compareOperands(timeBytes[e.offset:end], e.operandB, e.operation) {
+		return errors.New("comparison with time info failed")
+	}
+	return nil
+}
+
+func (e *policyCounterTimer) plan(p *policyPlanner) error {
+	p.trial.PolicyCounterTimer(e.operandB, e.offset, e.operation)
+	p.addCheckedStep(e, tpm2.CommandPolicyCounterTimer, func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error {
+		return tpm.PolicyCounterTimer(session, e.operandB, e.offset, e.operation)
+	})
+	return nil
+}
+
+// policySimple is a leaf that doesn't require access to the TPM when
+// planning.
+type policySimple struct {
+	command tpm2.CommandCode
+	update  func(p *TrialAuthPolicy) error
+	run     func(tpm *tpm2.TPMContext, session tpm2.SessionContext, alg tpm2.HashAlgorithmId) error
+	desc    func(alg tpm2.HashAlgorithmId) (*PolicyElementDescription, error)
+
+	// checkState is optional, and checks the assertion against the state.
+	checkState func(s policyState) error
+}
+
+func (e *policySimple) computeDigest(p *TrialAuthPolicy) error {
+	return e.update(p)
+}
+
+func (e *policySimple) check(p *policyPlanner) error {
+	if p.state == nil || e.checkState == nil {
+		return nil
+	}
+	return e.checkState(p.state)
+}
+
+func (e *policySimple) plan(p *policyPlanner) error {
+	if err := e.update(p.trial); err != nil {
+		return err
+	}
+	alg := p.trial.alg
+	p.addCheckedStep(e, e.command, func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error {
+		return e.run(tpm, session, alg)
+	})
+	return nil
+}
+
+func (e *policySimple) describe(alg tpm2.HashAlgorithmId, elements []*PolicyElementDescription) ([]*PolicyElementDescription, error) {
+	desc, err := e.desc(alg)
+	if err != nil {
+		return nil, err
+	}
+	return append(elements, desc), nil
+}
+
+// CommandCodeAssertion returns a policy element that corresponds to a
+// TPM2_PolicyCommandCode assertion.
+func CommandCodeAssertion(code tpm2.CommandCode) PolicyElement {
+	return &policySimple{
+		command: tpm2.CommandPolicyCommandCode,
+		update: func(p *TrialAuthPolicy) error {
+			p.PolicyCommandCode(code)
+			return nil
+		},
+		run: func(tpm *tpm2.TPMContext, session tpm2.SessionContext, _ tpm2.HashAlgorithmId) error {
+			return tpm.PolicyCommandCode(session, code)
+		},
+		desc: func(_ tpm2.HashAlgorithmId) (*PolicyElementDescription, error) {
+			return &PolicyElementDescription{Type: PolicyElementCommandCode, CommandCode: code}, nil
+		},
+		checkState: func(s policyState) error {
+			if current, ok := s.commandCode(); ok && current != code {
+				return fmt.Errorf("policy is limited to command %v", code)
+			}
+			return nil
+		}}
+}
+
+// LocalityAssertion returns a policy element that corresponds to a
+// TPM2_PolicyLocality assertion for the specified localities. See
+// TPMContext.PolicyLocality for the encoding of locality.
+func LocalityAssertion(locality tpm2.Locality) PolicyElement {
+	return &policySimple{
+		command: tpm2.CommandPolicyLocality,
+		update: func(p *TrialAuthPolicy) error {
+			p.PolicyLocality(locality)
+			return nil
+		},
+		run: func(tpm *tpm2.TPMContext, session tpm2.SessionContext, _ tpm2.HashAlgorithmId) error {
+			return tpm.PolicyLocality(session, locality)
+		},
+		desc: func(_ tpm2.HashAlgorithmId) (*PolicyElementDescription, error) {
+			return &PolicyElementDescription{Type: PolicyElementLocality, Locality: locality}, nil
+		},
+		checkState: func(s policyState) error {
+			current, ok := s.locality()
+			if !ok {
+				return nil
+			}
+			switch {
+			case locality > 31 && uint8(locality) == current:
+				return nil
+			case locality <= 31 && current < 5 && uint8(locality)&(1<<current) != 0:
+				return nil
+			}
+			return fmt.Errorf("policy is not satisfied at locality %d", current)
+		}}
+}
+
+// policyHashDigest returns a leaf that corresponds to a TPM2_PolicyCpHash or
+// TPM2_PolicyNameHash assertion for the digest computed by the supplied
+// function.
+func policyHashDigest(command tpm2.CommandCode, digest func(alg tpm2.HashAlgorithmId) (tpm2.Digest, error)) *policySimple {
+	typ := PolicyElementCpHash
+	if command == tpm2.CommandPolicyNameHash {
+		typ = PolicyElementNameHash
+	}
+	return &policySimple{
+		command: command,
+		update: func(p *TrialAuthPolicy) error {
+			if p.hashOccupied {
+				return errors.New("policy already has a hash")
+			}
+			d, err := digest(p.alg)
+			if err != nil {
+				return err
+			}
+			if command == tpm2.CommandPolicyNameHash {
+				p.PolicyNameHash(d)
+			} else {
+				p.PolicyCpHash(d)
+			}
+			return nil
+		},
+		run: func(tpm *tpm2.TPMContext, session tpm2.SessionContext, alg tpm2.HashAlgorithmId) error {
+			d, err := digest(alg)
+			if err != nil {
+				return err
+			}
+			if command == tpm2.CommandPolicyNameHash {
+				return tpm.PolicyNameHash(session, d)
+			}
+			return tpm.PolicyCpHash(session, d)
+		},
+		desc: func(alg tpm2.HashAlgorithmId) (*PolicyElementDescription, error) {
+			d, err := digest(alg)
+			if err != nil {
+				return nil, err
+			}
+			return &PolicyElementDescription{Type: typ, Digest: d}, nil
+		}}
+}
+
+// fixedDigest returns a function that returns the supplied digest if it is
+// the correct size for the requested algorithm.
+func fixedDigest(digest tpm2.Digest) func(tpm2.HashAlgorithmId) (tpm2.Digest, error) {
+	return func(alg tpm2.HashAlgorithmId) (tpm2.Digest, error) {
+		if len(digest) != alg.Size() {
+			return nil, errors.New("invalid digest length")
+		}
+		return digest, nil
+	}
+}
+
+// CpHashAssertion returns a policy element that corresponds to a
+// TPM2_PolicyCpHash assertion for the specified command, handles and
+// parameters. The command parameter digest is computed with ComputeCpHash for
+// the algorithm of the policy.
+func CpHashAssertion(command tpm2.CommandCode, handles []tpm2.Name, params ...interface{}) PolicyElement {
+	return policyHashDigest(tpm2.CommandPolicyCpHash, func(alg tpm2.HashAlgorithmId) (tpm2.Digest, error) {
+		digest, err := ComputeCpHash(alg, command, handles, params...)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot compute cpHash: %w", err)
+		}
+		return digest, nil
+	})
+}
+
+func computeNameHash(alg tpm2.HashAlgorithmId, names []tpm2.Name) tpm2.Digest {
+	h := alg.NewHash()
+	for _, name := range names {
+		h.Write(name)
+	}
+	return h.Sum(nil)
+}
+
+// NameHashAssertion returns a policy element that corresponds to a
+// TPM2_PolicyNameHash assertion for the entities with the specified names.
+func NameHashAssertion(names ...tpm2.Name) PolicyElement {
+	return policyHashDigest(tpm2.CommandPolicyNameHash, func(alg tpm2.HashAlgorithmId) (tpm2.Digest, error) {
+		return computeNameHash(alg, names), nil
+	})
+}
+
+// DuplicationSelectAssertion returns a policy element that corresponds to a
+// TPM2_PolicyDuplicationSelect assertion.
+func DuplicationSelectAssertion(objectName, newParentName tpm2.Name, includeObject bool) PolicyElement {
+	return &policySimple{
+		command: tpm2.CommandPolicyDuplicationSelect,
+		update: func(p *TrialAuthPolicy) error {
+			p.PolicyDuplicationSelect(objectName, newParentName, includeObject)
+			return nil
+		},
+		run: func(tpm *tpm2.TPMContext, session tpm2.SessionContext, _ tpm2.HashAlgorithmId) error {
+			return tpm.PolicyDuplicationSelect(session, objectName, newParentName, includeObject)
+		},
+		desc: func(_ tpm2.HashAlgorithmId) (*PolicyElementDescription, error) {
+			return &PolicyElementDescription{
+				Type: PolicyElementDuplicationSelect,
+				DuplicationSelect: &PolicyDuplicationSelectDescription{
+					ObjectName:    objectName,
+					NewParentName: newParentName,
+					IncludeObject: includeObject}}, nil
+		}}
+}
+
+// AuthValueAssertion returns a policy element that corresponds to a
+// TPM2_PolicyAuthValue assertion.
+func AuthValueAssertion() PolicyElement {
+	return &policySimple{
+		command: tpm2.CommandPolicyAuthValue,
+		update: func(p *TrialAuthPolicy) error {
+			p.PolicyAuthValue()
+			return nil
+		},
+		run: func(tpm *tpm2.TPMContext, session tpm2.SessionContext, _ tpm2.HashAlgorithmId) error {
+			return tpm.PolicyAuthValue(session)
+		},
+		desc: func(_ tpm2.HashAlgorithmId) (*PolicyElementDescription, error) {
+			return &PolicyElementDescription{Type: PolicyElementAuthValue}, nil
+		}}
+}
+
+// PasswordAssertion returns a policy element that corresponds to a
+// TPM2_PolicyPassword assertion.
+func PasswordAssertion() PolicyElement {
+	return &policySimple{
+		command: tpm2.CommandPolicyPassword,
+		update: func(p *TrialAuthPolicy) error {
+			p.PolicyPassword()
+			return nil
+		},
+		run: func(tpm *tpm2.TPMContext, session tpm2.SessionContext, _ tpm2.HashAlgorithmId) error {
+			return tpm.PolicyPassword(session)
+		},
+		desc: func(_ tpm2.HashAlgorithmId) (*PolicyElementDescription, error) {
+			return &PolicyElementDescription{Type: PolicyElementPassword}, nil
+		}}
+}
+
+// NvWrittenAssertion returns a policy element that corresponds to a
+// TPM2_PolicyNvWritten assertion.
+func NvWrittenAssertion(writtenSet bool) PolicyElement {
+	return &policySimple{
+		command: tpm2.CommandPolicyNvWritten,
+		update: func(p *TrialAuthPolicy) error {
+			p.PolicyNvWritten(writtenSet)
+			return nil
+		},
+		run: func(tpm *tpm2.TPMContext, session tpm2.SessionContext, _ tpm2.HashAlgorithmId) error {
+			return tpm.PolicyNvWritten(session, writtenSet)
+		},
+		desc: func(_ tpm2.HashAlgorithmId) (*PolicyElementDescription, error) {
+			return &PolicyElementDescription{Type: PolicyElementNvWritten, WrittenSet: writtenSet}, nil
+		}}
+}
+
+type policySecret struct {
+	authName  tpm2.Name
+	policyRef tpm2.Nonce
+}
+
+// SecretAuthorizationAssertion returns a policy element that corresponds to
+// a TPM2_PolicySecret assertion for the entity with the specified name.
+// Executing this requires PolicyAuthorizer.SecretAuth.
+func SecretAuthorizationAssertion(authName tpm2.Name, policyRef tpm2.Nonce) PolicyElement {
+	return &policySecret{authName: authName, policyRef: policyRef}
+}
+
+func (e *policySecret) computeDigest(p *TrialAuthPolicy) error {
+	p.PolicySecret(e.authName, e.policyRef)
+	return nil
+}
+
+func (e *policySecret) check(p *policyPlanner) error {
+	if p.requireAuth && (p.auth == nil || p.auth.SecretAuth == nil) {
+		return errors.New("no authorizer for TPM2_PolicySecret")
+	}
+	return nil
+}
+
+func (e *policySecret) plan(p *policyPlanner) error {
+	p.trial.PolicySecret(e.authName, e.policyRef)
+	p.addCheckedStep(e, tpm2.CommandPolicySecret, func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error {
+		if p.auth == nil || p.auth.SecretAuth == nil {
+			return errors.New("no authorizer for TPM2_PolicySecret")
+		}
+		authContext, authSession, err := p.auth.SecretAuth(e.authName)
+		if err != nil {
+			return xerrors.Errorf("cannot obtain authorization: %w", err)
+		}
+		if !bytes.Equal(authContext.Name(), e.authName) {
+			return errors.New("authorizer returned an entity with the wrong name")
+		}
+		_, _, err = tpm.PolicySecret(authContext, session, nil, e.policyRef, 0, authSession)
+		return err
+	})
+	return nil
+}
+
+type policySigned struct {
+	authName  tpm2.Name
+	policyRef tpm2.Nonce
+}
+
+// SignedAuthorizationAssertion returns a policy element that corresponds to
+// a TPM2_PolicySigned assertion for the key with the specified name.
+// Executing this requires PolicyAuthorizer.SignedAuth.
+func SignedAuthorizationAssertion(authName tpm2.Name, policyRef tpm2.Nonce) PolicyElement {
+	return &policySigned{authName: authName, policyRef: policyRef}
+}
+
+func (e *policySigned) computeDigest(p *TrialAuthPolicy) error {
+	p.PolicySigned(e.authName, e.policyRef)
+	return nil
+}
+
+func (e *policySigned) check(p *policyPlanner) error {
+	if p.requireAuth && (p.auth == nil || p.auth.SignedAuth == nil) {
+		return errors.New("no authorizer for TPM2_PolicySigned")
+	}
+	return nil
+}
+
+func (e *policySigned) plan(p *policyPlanner) error {
+	p.trial.PolicySigned(e.authName, e.policyRef)
+	p.addCheckedStep(e, tpm2.CommandPolicySigned, func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error {
+		if p.auth == nil || p.auth.SignedAuth == nil {
+			return errors.New("no authorizer for TPM2_PolicySigned")
+		}
+		auth, err := p.auth.SignedAuth(session, e.authName, e.policyRef)
+		if err != nil {
+			return xerrors.Errorf("cannot obtain signed authorization: %w", err)
+		}
+		if !bytes.Equal(auth.AuthKey.Name(), e.authName) {
+			return errors.New("authorizer returned a key with the wrong name")
+		}
+		_, err = ExecutePolicySigned(tpm, session, e.policyRef, auth)
+		return err
+	})
+	return nil
+}
+
+type policyAuthorize struct {
+	policyRef tpm2.Nonce
+	keySign   tpm2.Name
+}
+
+// AuthorizeAssertion returns a policy element that corresponds to a
+// TPM2_PolicyAuthorize assertion for the key with the specified name. As with
+// the TPM, the session digest is reset before it is extended, so this should
+// normally be the first element of a policy. Executing this requires
+// PolicyAuthorizer.AuthorizedPolicy, and the approved policy is executed
+// before the TPM2_PolicyAuthorize assertion.
+func AuthorizeAssertion(policyRef tpm2.Nonce, keySign tpm2.Name) PolicyElement {
+	return &policyAuthorize{policyRef: policyRef, keySign: keySign}
+}
+
+func (e *policyAuthorize) computeDigest(p *TrialAuthPolicy) error {
+	p.Reset()
+	p.PolicyAuthorize(e.policyRef, e.keySign)
+	return nil
+}
+
+type authorizedPolicyResult struct {
+	policy PolicyElement
+	ticket *tpm2.TkVerified
+	err    error
+}
+
+// authorizedPolicy obtains the authorized policy from the authorizer. The
+// result is cached so that the authorizer is only called once for this
+// element when it is both checked and planned.
+func (e *policyAuthorize) authorizedPolicy(p *policyPlanner) (PolicyElement, *tpm2.TkVerified, error) {
+	if r, ok := p.authorized[e]; ok {
+		return r.policy, r.ticket, r.err
+	}
+
+	policy, ticket, err := p.auth.AuthorizedPolicy(e.keySign, e.policyRef)
+	if err != nil {
+		err = xerrors.Errorf("cannot obtain authorized policy: %w", err)
+	}
+	if p.authorized == nil {
+		p.authorized = make(map[*policyAuthorize]*authorizedPolicyResult)
+	}
+	p.authorized[e] = &authorizedPolicyResult{policy: policy, ticket: ticket, err: err}
+	return policy, ticket, err
+}
+
+func (e *policyAuthorize) check(p *policyPlanner) error {
+	if p.auth == nil || p.auth.AuthorizedPolicy == nil {
+		if !p.requireAuth {
+			// Without a TPM, assume that this can be satisfied.
+			return nil
+		}
+		return errors.New("no authorizer for TPM2_PolicyAuthorize")
+	}
+	policy, _, err := e.authorizedPolicy(p)
+	if err != nil {
+		return err
+	}
+	if err := policy.check(p); err != nil {
+		return xerrors.Errorf("authorized policy cannot be satisfied: %w", err)
+	}
+	return nil
+}
+
+func (e *policyAuthorize) plan(p *policyPlanner) error {
+	if p.auth == nil || p.auth.AuthorizedPolicy == nil {
+		return errors.New("no authorizer for TPM2_PolicyAuthorize")
+	}
+	policy, ticket, err := e.authorizedPolicy(p)
+	if err != nil {
+		return err
+	}
+	if err := policy.plan(p); err != nil {
+		return xerrors.Errorf("cannot plan authorized policy: %w", err)
+	}
+
+	approvedPolicy := p.trial.GetDigest()
+	e.computeDigest(p.trial)
+	p.addStep(tpm2.CommandPolicyAuthorize, func(tpm *tpm2.TPMContext, session tpm2.SessionContext) error {
+		return tpm.PolicyAuthorize(session, approvedPolicy, e.policyRef, e.keySign, ticket)
+	})
+	return nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util_test
+
+import (
+	"crypto"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/testutil"
+	. "github.com/canonical/go-tpm2/util"
+)
+
+type policyTreeSuite struct{}
+
+var _ = Suite(&policyTreeSuite{})
+
+func (s *policyTreeSuite) pcrValues(data string) tpm2.PCRValues {
+	return tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, data)}}
+}
+
+func (s *policyTreeSuite) TestComputeSequence(c *C) {
+	values := s.pcrValues("foo")
+	policy := PolicyAND(
+		PCRAssertion(values),
+		CommandCodeAssertion(tpm2.CommandUnseal),
+		AuthValueAssertion())
+
+	for _, alg := range []tpm2.HashAlgorithmId{tpm2.HashAlgorithmSHA1, tpm2.HashAlgorithmSHA256} {
+		digest, err := ComputePolicyDigest(alg, policy)
+		c.Check(err, IsNil)
+
+		pcrDigest, err := ComputePCRDigest(alg, values.SelectionList(), values)
+		c.Check(err, IsNil)
+
+		trial := ComputeAuthPolicy(alg)
+		trial.PolicyPCR(pcrDigest, values.SelectionList())
+		trial.PolicyCommandCode(tpm2.CommandUnseal)
+		trial.PolicyAuthValue()
+		c.Check(digest, DeepEquals, trial.GetDigest())
+	}
+}
+
+func (s *policyTreeSuite) TestComputeOR(c *C) {
+	policy := PolicyAND(
+		PolicyOR(
+			PCRAssertion(s.pcrValues("foo")),
+			PCRAssertion(s.pcrValues("bar"))),
+		CommandCodeAssertion(tpm2.CommandUnseal))
+
+	digest, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, policy)
+	c.Check(err, IsNil)
+
+	var digests tpm2.DigestList
+	for _, data := range []string{"foo", "bar"} {
+		pcrs, pcrDigest := ComputePCRDigestSimple(tpm2.HashAlgorithmSHA256, s.pcrValues(data))
+		trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+		trial.PolicyPCR(pcrDigest, pcrs)
+		digests = append(digests, trial.GetDigest())
+	}
+
+	trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	trial.PolicyOR(digests)
+	trial.PolicyCommandCode(tpm2.CommandUnseal)
+	c.Check(digest, DeepEquals, trial.GetDigest())
+}
+
+func (s *policyTreeSuite) TestComputeORSingleBranch(c *C) {
+	digest, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, PolicyOR(AuthValueAssertion()))
+	c.Check(err, IsNil)
+
+	trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	trial.PolicyAuthValue()
+	c.Check(digest, DeepEquals, trial.GetDigest())
+}
+
+func (s *policyTreeSuite) TestComputeORNoBranches(c *C) {
+	_, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, PolicyOR())
+	c.Check(err, ErrorMatches, "PolicyOR element with no branches")
+}
+
+func (s *policyTreeSuite) TestComputeORNested(c *C) {
+	var branches []PolicyElement
+	var digests tpm2.DigestList
+	for i := 0; i < 10; i++ {
+		code := tpm2.CommandCode(uint32(tpm2.CommandNVUndefineSpaceSpecial) + uint32(i))
+		branches = append(branches, CommandCodeAssertion(code))
+
+		trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+		trial.PolicyCommandCode(code)
+		digests = append(digests, trial.GetDigest())
+	}
+
+	digest, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, PolicyOR(branches...))
+	c.Check(err, IsNil)
+
+	trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	trial.PolicyOR(digests[:8])
+	first := trial.GetDigest()
+	trial.PolicyOR(digests[8:])
+	second := trial.GetDigest()
+	trial.PolicyOR(tpm2.DigestList{first, second})
+	c.Check(digest, DeepEquals, trial.GetDigest())
+
+	plan, err := PlanPolicyExecution(nil, tpm2.HashAlgorithmSHA256, PolicyOR(branches...), nil)
+	c.Assert(err, IsNil)
+	c.Assert(plan.Steps, HasLen, 3)
+	c.Check(plan.Steps[0].Command, Equals, tpm2.CommandPolicyCommandCode)
+	c.Check(plan.Steps[0].Digest, DeepEquals, digests[0])
+	c.Check(plan.Steps[1].Command, Equals, tpm2.CommandPolicyOR)
+	c.Check(plan.Steps[1].Digest, DeepEquals, first)
+	c.Check(plan.Steps[2].Command, Equals, tpm2.CommandPolicyOR)
+	c.Check(plan.Digest(), DeepEquals, digest)
+}
+
+func (s *policyTreeSuite) TestComputeCpHash(c *C) {
+	policy := PolicyAND(
+		CpHashAssertion(tpm2.CommandUnseal, []tpm2.Name{tpm2.Name{0x40, 0x00, 0x00, 0x01}}),
+		CommandCodeAssertion(tpm2.CommandUnseal))
+
+	digest, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, policy)
+	c.Check(err, IsNil)
+
+	cpHash, err := ComputeCpHash(tpm2.HashAlgorithmSHA256, tpm2.CommandUnseal, []tpm2.Name{tpm2.Name{0x40, 0x00, 0x00, 0x01}})
+	c.Check(err, IsNil)
+
+	trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	trial.PolicyCpHash(cpHash)
+	trial.PolicyCommandCode(tpm2.CommandUnseal)
+	c.Check(digest, DeepEquals, trial.GetDigest())
+
+	_, err = ComputePolicyDigest(tpm2.HashAlgorithmSHA256, PolicyAND(policy, NameHashAssertion(tpm2.Name{0x40, 0x00, 0x00, 0x01})))
+	c.Check(err, ErrorMatches, "policy already has a hash")
+}
+
+func (s *policyTreeSuite) TestComputeAuthorize(c *C) {
+	keySign := tpm2.Name{0x00, 0x0b, 0x01, 0x02}
+	policy := AuthorizeAssertion(tpm2.Nonce("foo"), keySign)
+
+	digest, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, policy)
+	c.Check(err, IsNil)
+
+	trial := ComputeAuthPolicy(tpm2.HashAlgorithmSHA256)
+	trial.PolicyAuthorize(tpm2.Nonce("foo"), keySign)
+	c.Check(digest, DeepEquals, trial.GetDigest())
+
+	approved := PolicyAND(PCRAssertion(s.pcrValues("foo")), AuthValueAssertion())
+	plan, err := PlanPolicyExecution(nil, tpm2.HashAlgorithmSHA256, policy, &PolicyAuthorizer{
+		AuthorizedPolicy: func(name tpm2.Name, policyRef tpm2.Nonce) (PolicyElement, *tpm2.TkVerified, error) {
+			c.Check(name, DeepEquals, keySign)
+			c.Check(policyRef, DeepEquals, tpm2.Nonce("foo"))
+			return approved, nil, nil
+		}})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Steps, HasLen, 3)
+	c.Check(plan.Steps[0].Command, Equals, tpm2.CommandPolicyPCR)
+	c.Check(plan.Steps[1].Command, Equals, tpm2.CommandPolicyAuthValue)
+	c.Check(plan.Steps[2].Command, Equals, tpm2.CommandPolicyAuthorize)
+	c.Check(plan.Digest(), DeepEquals, digest)
+}
+
+func (s *policyTreeSuite) TestPlanWithoutTPMSelectsFirstBranch(c *C) {
+	policy := PolicyOR(
+		AuthorizeAssertion(nil, tpm2.Name{0x00, 0x0b, 0x01, 0x02}),
+		AuthValueAssertion())
+
+	_, err := PlanPolicyExecution(nil, tpm2.HashAlgorithmSHA256, policy, nil)
+	c.Check(err, ErrorMatches, "cannot plan branch 0: no authorizer for TPM2_PolicyAuthorize")
+}
+
+func (s *policyTreeSuite) TestPlanAuthorizeCallsAuthorizerOnce(c *C) {
+	keySign := tpm2.Name{0x00, 0x0b, 0x01, 0x02}
+	policy := PolicyOR(
+		AuthorizeAssertion(tpm2.Nonce("foo"), keySign),
+		AuthValueAssertion())
+
+	calls := 0
+	_, err := PlanPolicyExecution(nil, tpm2.HashAlgorithmSHA256, policy, &PolicyAuthorizer{
+		AuthorizedPolicy: func(name tpm2.Name, policyRef tpm2.Nonce) (PolicyElement, *tpm2.TkVerified, error) {
+			calls++
+			return AuthValueAssertion(), nil, nil
+		}})
+	c.Check(err, IsNil)
+	c.Check(calls, Equals, 1)
+}
+
+type policyTreeTPMSuite struct {
+	testutil.TPMTest
+}
+
+var _ = Suite(&policyTreeTPMSuite{})
+
+func (s *policyTreeTPMSuite) TestExecuteSelectsMatchingBranch(c *C) {
+	_, values, err := s.TPM.PCRRead(tpm2.PCRSelectionList{{Hash: tpm2.HashAlgorithmSHA256, Select: []int{7}}})
+	c.Assert(err, IsNil)
+
+	other := tpm2.PCRValues{tpm2.HashAlgorithmSHA256: {7: measure(crypto.SHA256, "foo")}}
+	policy := PolicyAND(
+		PolicyOR(PCRAssertion(other), PCRAssertion(values)),
+		CommandCodeAssertion(tpm2.CommandUnseal))
+
+	expected, err := ComputePolicyDigest(tpm2.HashAlgorithmSHA256, policy)
+	c.Check(err, IsNil)
+
+	plan, err := PlanPolicyExecution(s.TPM, tpm2.HashAlgorithmSHA256, policy, nil)
+	c.Assert(err, IsNil)
+	c.Check(plan.Steps, HasLen, 3)
+
+	session := s.StartAuthSession(c, nil, nil, tpm2.SessionTypePolicy, nil, tpm2.HashAlgorithmSHA256)
+	c.Check(plan.Execute(session), IsNil)
+
+	digest, err := s.TPM.PolicyGetDigest(session)
+	c.Check(err, IsNil)
+	c.Check(digest, DeepEquals, expected)
+}
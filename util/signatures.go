@@ -0,0 +1,108 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// signatureSchemeHashAlg returns the digest algorithm of the supplied
+// signature scheme.
+func signatureSchemeHashAlg(scheme *tpm2.SigScheme) (tpm2.HashAlgorithmId, error) {
+	if scheme == nil || scheme.Details == nil {
+		return tpm2.HashAlgorithmNull, errors.New("no signature scheme")
+	}
+
+	switch {
+	case scheme.Scheme == tpm2.SigSchemeAlgRSASSA && scheme.Details.RSASSA != nil:
+		return scheme.Details.RSASSA.HashAlg, nil
+	case scheme.Scheme == tpm2.SigSchemeAlgRSAPSS && scheme.Details.RSAPSS != nil:
+		return scheme.Details.RSAPSS.HashAlg, nil
+	case scheme.Scheme == tpm2.SigSchemeAlgECDSA && scheme.Details.ECDSA != nil:
+		return scheme.Details.ECDSA.HashAlg, nil
+	default:
+		return tpm2.HashAlgorithmNull, fmt.Errorf("unsupported signature scheme %v", scheme.Scheme)
+	}
+}
+
+// SignDigest signs the supplied digest with the supplied signer using the
+// specified scheme, and returns the signature in the form expected by the
+// TPM. The signer must be backed by a RSA key for the SigSchemeAlgRSASSA and
+// SigSchemeAlgRSAPSS schemes, or by an ECC key for the SigSchemeAlgECDSA
+// scheme. The digest must have been computed with the digest algorithm of the
+// scheme.
+func SignDigest(rand io.Reader, signer crypto.Signer, digest []byte, scheme *tpm2.SigScheme) (*tpm2.Signature, error) {
+	hashAlg, err := signatureSchemeHashAlg(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	switch scheme.Scheme {
+	case tpm2.SigSchemeAlgRSASSA, tpm2.SigSchemeAlgRSAPSS:
+		if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+			return nil, errors.New("signature scheme requires a RSA key")
+		}
+	case tpm2.SigSchemeAlgECDSA:
+		if _, ok := signer.Public().(*ecdsa.PublicKey); !ok {
+			return nil, errors.New("signature scheme requires an ECC key")
+		}
+	}
+
+	if !hashAlg.Available() {
+		return nil, fmt.Errorf("digest algorithm %v is not available", hashAlg)
+	}
+	if len(digest) != hashAlg.Size() {
+		return nil, errors.New("invalid digest length")
+	}
+
+	var opts crypto.SignerOpts = hashAlg.GetHash()
+	if scheme.Scheme == tpm2.SigSchemeAlgRSAPSS {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hashAlg.GetHash()}
+	}
+
+	sig, err := signer.Sign(rand, digest, opts)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot sign digest: %w", err)
+	}
+
+	switch scheme.Scheme {
+	case tpm2.SigSchemeAlgRSASSA:
+		return &tpm2.Signature{
+			SigAlg: tpm2.SigSchemeAlgRSASSA,
+			Signature: &tpm2.SignatureU{
+				RSASSA: &tpm2.SignatureRSASSA{Hash: hashAlg, Sig: sig}}}, nil
+	case tpm2.SigSchemeAlgRSAPSS:
+		return &tpm2.Signature{
+			SigAlg: tpm2.SigSchemeAlgRSAPSS,
+			Signature: &tpm2.SignatureU{
+				RSAPSS: &tpm2.SignatureRSAPSS{Hash: hashAlg, Sig: sig}}}, nil
+	default:
+		var ecdsaSig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(sig, &ecdsaSig); err != nil {
+			return nil, xerrors.Errorf("cannot decode ECDSA signature: %w", err)
+		}
+		size := (signer.Public().(*ecdsa.PublicKey).Params().BitSize + 7) / 8
+		return &tpm2.Signature{
+			SigAlg: tpm2.SigSchemeAlgECDSA,
+			Signature: &tpm2.SignatureU{
+				ECDSA: &tpm2.SignatureECDSA{
+					Hash:       hashAlg,
+					SignatureR: zeroExtendBytes(ecdsaSig.R, size),
+					SignatureS: zeroExtendBytes(ecdsaSig.S, size)}}}, nil
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package util_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/testutil"
+	. "github.com/canonical/go-tpm2/util"
+)
+
+type signaturesSuite struct{}
+
+var _ = Suite(&signaturesSuite{})
+
+func (s *signaturesSuite) TestSignDigestRSASSA(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	digest := measure(crypto.SHA256, "foo")
+	sig, err := SignDigest(rand.Reader, key, digest, &tpm2.SigScheme{
+		Scheme:  tpm2.SigSchemeAlgRSASSA,
+		Details: &tpm2.SigSchemeU{RSASSA: &tpm2.SigSchemeRSASSA{HashAlg: tpm2.HashAlgorithmSHA256}}})
+	c.Assert(err, IsNil)
+	c.Check(sig.SigAlg, Equals, tpm2.SigSchemeAlgRSASSA)
+	c.Check(sig.Signature.RSASSA.Hash, Equals, tpm2.HashAlgorithmSHA256)
+	c.Check(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest, sig.Signature.RSASSA.Sig), IsNil)
+}
+
+func (s *signaturesSuite) TestSignDigestRSAPSS(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	digest := measure(crypto.SHA1, "foo")
+	sig, err := SignDigest(rand.Reader, key, digest, &tpm2.SigScheme{
+		Scheme:  tpm2.SigSchemeAlgRSAPSS,
+		Details: &tpm2.SigSchemeU{RSAPSS: &tpm2.SigSchemeRSAPSS{HashAlg: tpm2.HashAlgorithmSHA1}}})
+	c.Assert(err, IsNil)
+	c.Check(sig.SigAlg, Equals, tpm2.SigSchemeAlgRSAPSS)
+	c.Check(sig.Signature.RSAPSS.Hash, Equals, tpm2.HashAlgorithmSHA1)
+	c.Check(rsa.VerifyPSS(&key.PublicKey, crypto.SHA1, digest, sig.Signature.RSAPSS.Sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}), IsNil)
+}
+
+func (s *signaturesSuite) TestSignDigestECDSA(c *C) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+
+	digest := measure(crypto.SHA256, "foo")
+	sig, err := SignDigest(rand.Reader, key, digest, &tpm2.SigScheme{
+		Scheme:  tpm2.SigSchemeAlgECDSA,
+		Details: &tpm2.SigSchemeU{ECDSA: &tpm2.SigSchemeECDSA{HashAlg: tpm2.HashAlgorithmSHA256}}})
+	c.Assert(err, IsNil)
+	c.Check(sig.SigAlg, Equals, tpm2.SigSchemeAlgECDSA)
+	c.Check(sig.Signature.ECDSA.SignatureR, HasLen, 32)
+	c.Check(sig.Signature.ECDSA.SignatureS, HasLen, 32)
+	r := new(big.Int).SetBytes(sig.Signature.ECDSA.SignatureR)
+	ss := new(big.Int).SetBytes(sig.Signature.ECDSA.SignatureS)
+	c.Check(ecdsa.Verify(&key.PublicKey, digest, r, ss), testutil.IsTrue)
+}
+
+func (s *signaturesSuite) TestSignDigestWrongKeyType(c *C) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+
+	_, err = SignDigest(rand.Reader, key, measure(crypto.SHA256, "foo"), &tpm2.SigScheme{
+		Scheme:  tpm2.SigSchemeAlgRSASSA,
+		Details: &tpm2.SigSchemeU{RSASSA: &tpm2.SigSchemeRSASSA{HashAlg: tpm2.HashAlgorithmSHA256}}})
+	c.Check(err, ErrorMatches, "signature scheme requires a RSA key")
+}
+
+func (s *signaturesSuite) TestSignDigestWrongDigestSize(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	_, err = SignDigest(rand.Reader, key, measure(crypto.SHA1, "foo"), &tpm2.SigScheme{
+		Scheme:  tpm2.SigSchemeAlgRSASSA,
+		Details: &tpm2.SigSchemeU{RSASSA: &tpm2.SigSchemeRSASSA{HashAlg: tpm2.HashAlgorithmSHA256}}})
+	c.Check(err, ErrorMatches, "invalid digest length")
+}